@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Prompt is a named prompt template for the assistant command. {hunk} is
+// replaced with the current hunk's patch and {diff} with the whole diff.
+type Prompt struct {
+	Name     string
+	Template string
+}
+
+// defaultPrompts are offered when the config defines no assistant.<name>
+// prompts.
+var defaultPrompts = []Prompt{
+	{"explain", "Explain what this change does and why it might have been made.\n\n{hunk}"},
+	{"review", "Review this diff. Point out bugs, risky changes and missing tests.\n\n{diff}"},
+	{"commit", "Write a concise git commit message (subject line and body) for this diff.\n\n{diff}"},
+}
+
+// diffText returns the diff of all hunks as one patch.
+func (s *State) diffText() string {
+	var sb strings.Builder
+	file := ""
+	for i := range s.Hunks {
+		h := &s.Hunks[i]
+		if h.File != file {
+			file = h.File
+			fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", file, file, file, file)
+		}
+		sb.WriteString(h.AsPatch())
+	}
+	return sb.String()
+}
+
+// expandPrompt fills in a prompt template for the current hunk and diff. A
+// diff that itself contains {hunk} is sent as it is.
+func (s *State) expandPrompt(tmpl string) string {
+	vars := map[string]string{"diff": s.diffText()}
+	if len(s.Hunks) > 0 {
+		vars["hunk"] = s.Hunks[s.CurrentHunkIndex()].AsFullPatch()
+	}
+	return expandPlaceholders(tmpl, vars)
+}
+
+// assistantRun is a run of the assistant command, shown in the panel on
+// the right of the diff.
+type assistantRun struct {
+	name   string // the prompt's name
+	cmd    *exec.Cmd
+	output strings.Builder
+	scroll int // rows scrolled back from the end of the response
+	done   bool
+	err    error
+}
+
+// EventAssistantOutput carries a chunk of assistant output (or its end) to
+// the main loop.
+type EventAssistantOutput struct {
+	t    time.Time
+	run  *assistantRun
+	text string
+	done bool
+	err  error
+}
+
+func (e *EventAssistantOutput) When() time.Time { return e.t }
+
+// openAssistantPicker lets the user pick a prompt to send to the assistant.
+func openAssistantPicker(s *State) {
+	if s.Config.Assistant == "" {
+		s.FlashMsg = "Set assistant = <command> in the config (e.g. llm)"
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	if len(s.Hunks) == 0 {
+		return
+	}
+	prompts := s.Config.Prompts
+	if len(prompts) == 0 {
+		prompts = defaultPrompts
+	}
+	items := make([]string, len(prompts))
+	for i, p := range prompts {
+		items[i] = p.Name
+	}
+	OpenPopup(s, &Popup{
+		Title: "Ask " + s.Config.Assistant,
+		Items: items,
+		OnSelect: func(s *State, idx int) {
+			startAssistant(s, prompts[idx])
+		},
+	})
+}
+
+// startAssistant runs the assistant command with the expanded prompt on
+// stdin and streams its output into the assistant panel, stopping a run
+// still going. Closing the panel stops it.
+func startAssistant(s *State, p Prompt) {
+	args := expandCommand(s.Config.Assistant, nil)
+	if len(args) == 0 {
+		s.FlashMsg = "Set assistant = <command> in the config (e.g. llm)"
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(s.expandPrompt(p.Template))
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		s.FlashMsg = fmt.Sprintf("%s: %v", args[0], err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.Assistant.stop()
+	run := &assistantRun{name: p.Name, cmd: cmd}
+	s.Assistant = run
+
+	post := func(ev *EventAssistantOutput) {
+		if s.Screen != nil {
+			_ = s.Screen.PostEvent(ev)
+		}
+	}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				post(&EventAssistantOutput{t: time.Now(), run: run, text: string(buf[:n])})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		err := cmd.Wait()
+		_ = pw.Close()
+		post(&EventAssistantOutput{t: time.Now(), run: run, done: true, err: err})
+	}()
+}
+
+// stop kills the assistant command if it is still running.
+func (r *assistantRun) stop() {
+	if r != nil && !r.done && r.cmd.Process != nil {
+		_ = r.cmd.Process.Kill()
+	}
+}
+
+// closeAssistant closes the assistant panel, stopping its command.
+func closeAssistant(s *State) {
+	s.Assistant.stop()
+	s.Assistant = nil
+}
+
+// handleAssistantOutput appends streamed output to its run. Output of a
+// run whose panel was closed or replaced stops the command.
+func handleAssistantOutput(s *State, ev *EventAssistantOutput) {
+	run := ev.run
+	if s.Assistant != run {
+		run.stop()
+		return
+	}
+	run.output.WriteString(ev.text)
+	if ev.done {
+		run.done, run.err = true, ev.err
+	}
+}
+
+// assistantPanelWidth returns the columns the assistant panel takes on the
+// right of the diff, not counting its divider: two fifths of the screen,
+// but no more than half of it.
+func (s *State) assistantPanelWidth() int {
+	if s.Assistant == nil {
+		return 0
+	}
+	return min(max(s.Width*2/5, 30), s.Width/2)
+}
+
+// inAssistantPanel reports whether column x is in the assistant panel or
+// on its divider.
+func (s *State) inAssistantPanel(x int) bool {
+	w := s.assistantPanelWidth()
+	return w > 0 && x >= s.Width-w-1
+}
+
+// scrollAssistant scrolls the assistant panel by delta rows, toward the
+// end of the response for a positive delta. Scrolled to the end, the panel
+// follows the response as it streams in.
+func scrollAssistant(s *State, delta int) {
+	s.Assistant.scroll = max(s.Assistant.scroll-delta, 0)
+}
+
+// drawAssistantPanel draws the assistant panel on the right of the diff:
+// the prompt's name and the run's state, then the response wrapped to the
+// panel.
+func drawAssistantPanel(s *State) {
+	w := s.assistantPanelWidth()
+	if w == 0 {
+		return
+	}
+	run := s.Assistant
+	x := s.Width - w
+	rows := s.viewRows()
+	for y := 0; y < rows; y++ {
+		s.Screen.SetContent(x-1, y, '│', nil, s.Theme.Dim)
+	}
+	title := run.name
+	switch {
+	case !run.done:
+		title += " · running…"
+	case run.err != nil:
+		title += " · " + run.err.Error()
+	}
+	col := drawText(s.Screen, x+1, 0, title, s.Theme.Default.Bold(true), s.Width)
+	clearToEnd(s, s.Screen, col, 0, s.Width)
+
+	text := strings.ReplaceAll(strings.ReplaceAll(run.output.String(), "\r", ""), "\t", "    ")
+	lines := wrapText(strings.TrimSuffix(text, "\n"), max(w-2, 1))
+	height := rows - 1
+	run.scroll = min(run.scroll, max(len(lines)-height, 0))
+	first := max(len(lines)-height-run.scroll, 0)
+	for i := 0; i < height; i++ {
+		text := ""
+		if first+i < len(lines) {
+			text = lines[first+i]
+		}
+		s.Screen.SetContent(x, 1+i, ' ', nil, s.Theme.Default)
+		col := drawText(s.Screen, x+1, 1+i, text, s.Theme.Default, s.Width)
+		clearToEnd(s, s.Screen, col, 1+i, s.Width)
+	}
+}
+
+// wrapText splits text into lines no wider than width runes, breaking at
+// spaces where possible.
+func wrapText(text string, width int) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		r := []rune(line)
+		for len(r) > width {
+			cut := width
+			for i := width; i > width/2; i-- {
+				if r[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			out = append(out, string(r[:cut]))
+			r = []rune(strings.TrimLeft(string(r[cut:]), " "))
+		}
+		out = append(out, string(r))
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestExpandPrompt(t *testing.T) {
+	s := &State{Hunks: []Hunk{
+		{File: "a.go", Header: "@@ -1 +1 @@", Lines: []Line{{Op: '-', Content: "x"}, {Op: '+', Content: "y"}}},
+		{File: "a.go", Header: "@@ -9 +9 @@", Lines: []Line{{Op: '+', Content: "z"}}},
+	}, Width: 80, Height: 20}
+	s.BuildLines()
+	got := s.expandPrompt("Explain:\n{diff}")
+	if strings.Count(got, "diff --git a/a.go b/a.go") != 1 {
+		t.Errorf("expected one file header in %q", got)
+	}
+	if !strings.Contains(got, "-x\n+y\n") || !strings.Contains(got, "@@ -9 +9 @@\n+z\n") {
+		t.Errorf("missing hunks in %q", got)
+	}
+	if got := s.expandPrompt("{hunk}"); !strings.HasPrefix(got, "diff --git") || !strings.Contains(got, "+y") {
+		t.Errorf("hunk prompt = %q", got)
+	}
+}
+
+func TestExpandPromptKeepsPlaceholdersInDiff(t *testing.T) {
+	s := &State{Hunks: []Hunk{
+		{File: "a.go", Header: "@@ -1 +1 @@", Lines: []Line{{Op: '+', Content: `vars["hunk"] // fills {hunk}`}}},
+	}, Width: 80, Height: 20}
+	s.BuildLines()
+	for range 20 {
+		got := s.expandPrompt("Review:\n{diff}")
+		if !strings.Contains(got, "+vars[\"hunk\"] // fills {hunk}\n") || strings.Count(got, "@@ -1 +1 @@") != 1 {
+			t.Fatalf("{hunk} in the diff was expanded:\n%s", got)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("the quick brown fox\n\njumps", 10)
+	want := []string{"the quick", "brown fox", "", "jumps"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
+
+func TestAssistantPanel(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(100, 24)
+	s := &State{Screen: sim, Width: 100, Height: 24, Theme: NewUITheme(""), Hunks: []Hunk{{File: "a.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "package a"}}}}}
+	s.BuildLines()
+
+	s.Config.Assistant = "  "
+	startAssistant(s, Prompt{"explain", "{hunk}"})
+	if s.Assistant != nil || s.FlashMsg == "" {
+		t.Fatalf("a blank assistant command started a run")
+	}
+
+	s.Config.Assistant = "sed s/^/answer:/"
+	startAssistant(s, Prompt{"explain", "{hunk}"})
+	if s.Assistant == nil {
+		t.Fatalf("no run started: %s", s.FlashMsg)
+	}
+	deadline := time.After(5 * time.Second)
+	for !s.Assistant.done {
+		select {
+		case <-deadline:
+			t.Fatal("assistant never finished")
+		default:
+		}
+		if ev, ok := sim.PollEvent().(*EventAssistantOutput); ok {
+			handleAssistantOutput(s, ev)
+		}
+	}
+	Render(s)
+	if s.DiffWidth != 100-40-1 {
+		t.Errorf("diff width with the panel open: %d", s.DiffWidth)
+	}
+	screen := strings.Join(screenText(sim, 100, 24), "\n")
+	if !strings.Contains(screen, "answer:diff --git") || !strings.Contains(screen, "explain") {
+		t.Errorf("panel not drawn:\n%s", screen)
+	}
+
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if s.Assistant != nil {
+		t.Error("Esc kept the panel open")
+	}
+	s.updateLayout()
+	if s.DiffWidth != 100 {
+		t.Errorf("diff width with the panel closed: %d", s.DiffWidth)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Default themes picked when no theme is given on the command line or in
+// WIFF_THEME, based on the detected terminal background.
+const (
+	defaultDarkTheme  = "monokai"
+	defaultLightTheme = "monokailight"
+)
+
+// queryTimeout bounds how long we wait for the terminal to answer the
+// background color query. The query is followed by a DA1 request, which
+// every terminal answers, so the wait normally ends as soon as the replies
+// are in, and a slow link doesn't leave them to arrive as keystrokes.
+const queryTimeout = time.Second
+
+// defaultTheme returns the theme for the detected terminal background,
+// falling back to the dark default when it can't be determined.
+func defaultTheme() string {
+	if dark, ok := backgroundFromColorFGBG(os.Getenv("COLORFGBG")); ok {
+		return themeFor(dark)
+	}
+	if dark, ok := queryBackground(); ok {
+		return themeFor(dark)
+	}
+	return defaultDarkTheme
+}
+
+func themeFor(dark bool) string {
+	if dark {
+		return defaultDarkTheme
+	}
+	return defaultLightTheme
+}
+
+// backgroundFromColorFGBG interprets the COLORFGBG variable set by rxvt,
+// Konsole and others ("fg;bg" or "fg;default;bg"). The background is an
+// ANSI color index: 7 and 9–15 are light, everything else is dark.
+func backgroundFromColorFGBG(v string) (dark, ok bool) {
+	if v == "" {
+		return false, false
+	}
+	parts := strings.Split(v, ";")
+	bg, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || bg < 0 || bg > 15 {
+		return false, false
+	}
+	return !(bg == 7 || bg >= 9), true
+}
+
+// queryBackground asks the terminal for its background color with OSC 11,
+// then reads up to the reply to the DA1 request sent after it. Terminals
+// that don't support OSC 11 only answer the DA1. It must run before tcell
+// takes over the terminal.
+func queryBackground() (dark, ok bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, false
+	}
+	defer func() { _ = tty.Close() }()
+
+	fd := int(tty.Fd())
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return false, false
+	}
+	defer func() { _ = term.Restore(fd, old) }()
+
+	if err := tty.SetReadDeadline(time.Now().Add(queryTimeout)); err != nil {
+		return false, false
+	}
+	if _, err := tty.WriteString("\033]11;?\033\\\033[c"); err != nil {
+		return false, false
+	}
+
+	var reply []byte
+	buf := make([]byte, 64)
+	for len(reply) < 256 {
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if i := da1Reply(string(reply)); i >= 0 {
+			return parseOSC11(string(reply[:i]))
+		}
+		if err != nil {
+			break
+		}
+	}
+	return parseOSC11(string(reply))
+}
+
+// da1Reply returns where the terminal's complete reply to DA1, such as
+// "\033[?62;22c", starts in reply, or -1 if it hasn't all arrived.
+func da1Reply(reply string) int {
+	i := strings.Index(reply, "\033[?")
+	if i < 0 {
+		return -1
+	}
+	for _, c := range reply[i+3:] {
+		switch {
+		case c == 'c':
+			return i
+		case c != ';' && (c < '0' || c > '9'):
+			return -1
+		}
+	}
+	return -1
+}
+
+// parseOSC11 extracts the background brightness from a reply such as
+// "\033]11;rgb:1e1e/1e1e/2e2e\033\\".
+func parseOSC11(reply string) (dark, ok bool) {
+	i := strings.Index(reply, "rgb:")
+	if i < 0 {
+		return false, false
+	}
+	spec := strings.TrimRight(reply[i+4:], "\a\033\\")
+	comps := strings.Split(spec, "/")
+	if len(comps) != 3 {
+		return false, false
+	}
+	var rgb [3]float64
+	for j, c := range comps {
+		if c == "" || len(c) > 4 {
+			return false, false
+		}
+		v, err := strconv.ParseUint(c, 16, 16)
+		if err != nil {
+			return false, false
+		}
+		// Components have 1–4 hex digits; normalize to 0..1
+		rgb[j] = float64(v) / float64(uint64(1)<<(4*len(c))-1)
+	}
+	lum := 0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]
+	return lum < 0.5, true
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestBackgroundFromColorFGBG(t *testing.T) {
+	tests := []struct {
+		in       string
+		dark, ok bool
+	}{
+		{"15;0", true, true},
+		{"0;15", false, true},
+		{"0;default;7", false, true},
+		{"7;8", true, true},
+		{"", false, false},
+		{"15;default", false, false},
+	}
+	for _, tt := range tests {
+		dark, ok := backgroundFromColorFGBG(tt.in)
+		if dark != tt.dark || ok != tt.ok {
+			t.Errorf("backgroundFromColorFGBG(%q) = %v, %v; want %v, %v", tt.in, dark, ok, tt.dark, tt.ok)
+		}
+	}
+}
+
+func TestDA1Reply(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"\033]11;rgb:1e1e/1e1e/2e2e\033\\\033[?62;22c", 25},
+		{"\033[?1;2c", 0},
+		{"\033]11;rgb:1e1e/1e1e/2e2e\033\\\033[?62;2", -1},
+		{"\033]11;rgb:1e1e/1e1e/2e2e\a", -1},
+		{"", -1},
+	}
+	for _, tt := range tests {
+		if got := da1Reply(tt.in); got != tt.want {
+			t.Errorf("da1Reply(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseOSC11(t *testing.T) {
+	tests := []struct {
+		in       string
+		dark, ok bool
+	}{
+		{"\033]11;rgb:1e1e/1e1e/2e2e\033\\", true, true},
+		{"\033]11;rgb:ffff/ffff/ffff\a", false, true},
+		{"\033]11;rgb:fd/f6/e3\a", false, true},
+		{"\033]11;rgb:0/0/0\a", true, true},
+		{"", false, false},
+		{"\033]11;rgb:zz/00/00\a", false, false},
+	}
+	for _, tt := range tests {
+		dark, ok := parseOSC11(tt.in)
+		if dark != tt.dark || ok != tt.ok {
+			t.Errorf("parseOSC11(%q) = %v, %v; want %v, %v", tt.in, dark, ok, tt.dark, tt.ok)
+		}
+	}
+}
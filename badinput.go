@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// exitBadInput is the exit status when the piped input isn't a diff, as
+// diff(1) exits 2 on trouble.
+const exitBadInput = 2
+
+// InputError is piped input that isn't a diff: it failed to parse, or has
+// text but nothing that looks like a diff in it.
+type InputError struct {
+	Err  error  // the parse error, nil when nothing looked like a diff
+	Head string // the first bytes of the input, for showing
+}
+
+func (e *InputError) Error() string {
+	if e.Err != nil {
+		return "input is not a diff: " + e.Err.Error()
+	}
+	return "input is not a diff"
+}
+
+// exitStatus returns the exit status for an error that stops wiff.
+func exitStatus(err error) int {
+	if _, ok := err.(*InputError); ok {
+		return exitBadInput
+	}
+	return 1
+}
+
+// inputHeadBytes and inputHeadLines bound how much of bad input is shown.
+const (
+	inputHeadBytes = 400
+	inputHeadLines = 8
+)
+
+// checkInput returns an *InputError when raw, read from stdin, isn't a
+// diff; err is what parsing it returned. Empty input is an empty diff.
+// Input without hunks is still a diff when it has diff headers, such as
+// a diff of binary files or of modes only.
+func checkInput(raw []byte, err error) error {
+	if err == nil && (len(bytes.TrimSpace(raw)) == 0 || looksLikeDiff(raw)) {
+		return nil
+	}
+	return &InputError{Err: err, Head: inputHead(raw)}
+}
+
+// looksLikeDiff reports whether raw has a line that starts a diff, a file
+// or a hunk.
+func looksLikeDiff(raw []byte) bool {
+	for _, line := range strings.Split(string(raw), "\n") {
+		for _, prefix := range []string{"diff ", "--- ", "+++ ", "@@ ", "Index: ", "Binary files "} {
+			if strings.HasPrefix(line, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inputHead returns the first lines of raw, with tabs expanded and other
+// control characters and invalid UTF-8 shown as "?".
+func inputHead(raw []byte) string {
+	head := string(raw[:min(len(raw), inputHeadBytes)])
+	lines := strings.Split(strings.TrimRight(head, "\n"), "\n")
+	if len(lines) > inputHeadLines {
+		lines = lines[:inputHeadLines]
+	}
+	for i, line := range lines {
+		line = strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", "    ")
+		lines[i] = strings.Map(func(r rune) rune {
+			if r == unicode.ReplacementChar || unicode.IsControl(r) {
+				return '?'
+			}
+			return r
+		}, line)
+	}
+	return strings.Join(lines, "\n")
+}
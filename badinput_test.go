@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestCheckInput(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		err  error
+		bad  bool
+	}{
+		{"empty", "", nil, false},
+		{"blank", "\n  \n", nil, false},
+		{"diff", "--- a\n+++ b\n@@ -1 +1 @@\n-x\n+y\n", nil, false},
+		{"binary only", "diff --git a/x.png b/x.png\nBinary files a/x.png and b/x.png differ\n", nil, false},
+		{"text", "hello world\n", nil, true},
+		{"parse error", "diff --git a/x b/x\n@@ junk\n", errors.New("bad hunk"), true},
+	}
+	for _, tt := range tests {
+		err := checkInput([]byte(tt.raw), tt.err)
+		var ie *InputError
+		if got := errors.As(err, &ie); got != tt.bad {
+			t.Errorf("%s: checkInput = %v, want bad input %v", tt.name, err, tt.bad)
+		}
+		if tt.bad && exitStatus(err) != exitBadInput {
+			t.Errorf("%s: exit status %d", tt.name, exitStatus(err))
+		}
+	}
+}
+
+func TestInputHead(t *testing.T) {
+	raw := "a\tb\x1b[31m\r\n" + strings.Repeat("line\n", 20)
+	head := strings.Split(inputHead([]byte(raw)), "\n")
+	if len(head) != inputHeadLines {
+		t.Fatalf("got %d lines, want %d", len(head), inputHeadLines)
+	}
+	if head[0] != "a    b?[31m" {
+		t.Errorf("first line = %q", head[0])
+	}
+}
+
+func TestDrawEmptyState(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(80, 12)
+
+	s := &State{Screen: sim, Width: 80, Height: 12, HL: NewHighlighter(), PipeMode: true}
+	s.InputErr = checkInput([]byte("usage: frob [-x]\n"), nil).(*InputError)
+	s.BuildLines()
+	Render(s)
+	text := strings.Join(screenText(sim, 80, 11), "\n")
+	for _, want := range []string{"input is not a diff", "usage: frob [-x]"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("screen lacks %q:\n%s", want, text)
+		}
+	}
+
+	s.InputErr = nil
+	Render(s)
+	if text := strings.Join(screenText(sim, 80, 11), "\n"); !strings.Contains(text, "No changes") {
+		t.Errorf("empty diff screen lacks No changes:\n%s", text)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCheck is the check command of Go modules without one configured.
+const defaultCheck = "go test {packages}"
+
+// checkRun is a run of the check command over the touched packages, its
+// output streamed into the bottom panel.
+type checkRun struct {
+	command string // as expanded, for the panel title
+	cmd     *exec.Cmd
+	lines   []string // output so far, the last line possibly unfinished
+	done    bool
+	err     error
+}
+
+// EventCheckOutput carries a chunk of check output (or its end) to the main
+// loop.
+type EventCheckOutput struct {
+	t    time.Time
+	run  *checkRun
+	text string
+	done bool
+	err  error
+}
+
+func (e *EventCheckOutput) When() time.Time { return e.t }
+
+// touchedDirs returns the directories, relative to the repository root, of
+// the files of the diff that have a suffix in exts (any file without exts),
+// leaving out directories the change removed.
+func (s *State) touchedDirs(root string, exts ...string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, h := range s.Hunks {
+		if len(exts) > 0 && !hasAnySuffix(h.File, exts) {
+			continue
+		}
+		dir := path.Dir(h.File)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if fi, err := os.Stat(filepath.Join(root, dir)); err != nil || !fi.IsDir() {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// hasAnySuffix reports whether s ends in one of suffixes.
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suf := range suffixes {
+		if strings.HasSuffix(s, suf) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVars returns the lists the check command's placeholders stand for:
+// {packages}, the Go packages with changed .go files ("./pkg/x", "."),
+// {dirs}, the directories of all changed files, and {files}, the changed
+// files themselves.
+func (s *State) checkVars(root string) map[string][]string {
+	var packages []string
+	for _, dir := range s.touchedDirs(root, ".go") {
+		if dir == "." {
+			packages = append(packages, ".")
+		} else {
+			packages = append(packages, "./"+dir)
+		}
+	}
+	var files []string
+	for _, f := range s.orderedFiles() {
+		if _, err := os.Stat(filepath.Join(root, f)); err == nil {
+			files = append(files, f)
+		}
+	}
+	return map[string][]string{
+		"packages": packages,
+		"dirs":     s.touchedDirs(root),
+		"files":    files,
+	}
+}
+
+// expandLists expands the placeholders of tmpl, a shell command, to their
+// lists, each item shell-quoted and separated by spaces. The quoted lists
+// are not scanned for placeholders again, so a file named {dirs} stays one
+// quoted word.
+func expandLists(tmpl string, lists map[string][]string) string {
+	quoted := make(map[string]string, len(lists))
+	for k, v := range lists {
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = shellQuote(item)
+		}
+		quoted[k] = strings.Join(items, " ")
+	}
+	return expandPlaceholders(tmpl, quoted)
+}
+
+// shellQuote quotes s for sh, leaving words that need no quoting as they are.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./-+=:,@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// checkCommand returns the configured check command, or for a Go module the
+// default one.
+func checkCommand(s *State, root string) string {
+	if s.Config.Check != "" {
+		return s.Config.Check
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+		return defaultCheck
+	}
+	return ""
+}
+
+// toggleCheck starts the check command on the touched packages, or hides
+// the panel when it is showing; the run goes on without it.
+func toggleCheck(s *State) {
+	if s.CheckPanel {
+		s.CheckPanel = false
+		return
+	}
+	startCheck(s)
+}
+
+// startCheck runs the check command with sh -c on the packages the diff
+// touches in the repository root, stopping a run still going, and opens the panel.
+func startCheck(s *State) {
+	root, err := repo.Root()
+	if err != nil || s.PipeMode || s.NoIndex {
+		s.FlashMsg = "Checks run on the packages of a repository's diff"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	tmpl := checkCommand(s, root)
+	if tmpl == "" {
+		s.FlashMsg = "Set check = <command> in the config (e.g. make test)"
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	vars := s.checkVars(root)
+	for name, list := range vars {
+		if strings.Contains(tmpl, "{"+name+"}") && len(list) == 0 {
+			s.FlashMsg = "No " + name + " touched by this diff"
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+			return
+		}
+	}
+	command := expandLists(tmpl, vars)
+	s.Check.stop()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = root
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	run := &checkRun{command: command, cmd: cmd}
+	if err := cmd.Start(); err != nil {
+		s.FlashMsg = fmt.Sprintf("check: %v", err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.Check = run
+	s.CheckPanel = true
+
+	post := func(ev *EventCheckOutput) {
+		if s.Screen != nil {
+			_ = s.Screen.PostEvent(ev)
+		}
+	}
+	// The end is posted by the reader, after the last of the output
+	waitErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				post(&EventCheckOutput{t: time.Now(), run: run, text: string(buf[:n])})
+			}
+			if err != nil {
+				post(&EventCheckOutput{t: time.Now(), run: run, done: true, err: <-waitErr})
+				return
+			}
+		}
+	}()
+	go func() {
+		waitErr <- cmd.Wait()
+		_ = pw.Close()
+	}()
+}
+
+// stop kills the check command if it is still running.
+func (r *checkRun) stop() {
+	if r != nil && !r.done && r.cmd.Process != nil {
+		_ = r.cmd.Process.Kill()
+	}
+}
+
+// handleCheckOutput adds streamed output to its run.
+func handleCheckOutput(ev *EventCheckOutput) {
+	run := ev.run
+	if ev.text != "" {
+		parts := strings.Split(ev.text, "\n")
+		if n := len(run.lines); n > 0 {
+			run.lines[n-1] += parts[0]
+			parts = parts[1:]
+		}
+		run.lines = append(run.lines, parts...)
+	}
+	if ev.done {
+		run.done, run.err = true, ev.err
+	}
+}
+
+// output returns the run's output, without the empty line after a final
+// newline.
+func (r *checkRun) output() []string {
+	lines := r.lines
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	return lines
+}
+
+// status describes the state of the run for the status bar and the panel.
+func (r *checkRun) status() string {
+	switch {
+	case !r.done:
+		return "⟳ check running"
+	case r.err != nil:
+		if ee, ok := r.err.(*exec.ExitError); ok {
+			return fmt.Sprintf("✗ check failed (exit %d)", ee.ExitCode())
+		}
+		return "✗ check failed"
+	}
+	return "✓ check passed"
+}
+
+// checkPanelRows returns the rows the check panel takes above the status
+// bar: a title row and a third of the screen for output.
+func (s *State) checkPanelRows() int {
+	if !s.CheckPanel || s.Check == nil {
+		return 0
+	}
+	return min(max(s.Height/3, 4), max(s.Height-4, 0))
+}
+
+// viewRows returns the rows the diff is drawn in: all but the status bar,
+// the check panel and the tutorial.
+func (s *State) viewRows() int {
+	return s.Height - s.statusRows() - s.checkPanelRows() - s.tutorRows()
+}
+
+// drawCheckPanel draws the check panel above the status bar: the command
+// and its status, then the last lines of its output.
+func drawCheckPanel(s *State) {
+	rows := s.checkPanelRows()
+	if rows == 0 {
+		return
+	}
+	top := s.Height - s.statusRows() - rows
+	title := "─ " + s.Check.command + " · " + s.Check.status() + " "
+	col := 0
+	for _, r := range title {
+		if col >= s.Width {
+			break
+		}
+		s.Screen.SetContent(col, top, r, nil, s.Theme.Dim)
+		col++
+	}
+	for ; col < s.Width; col++ {
+		s.Screen.SetContent(col, top, '─', nil, s.Theme.Dim)
+	}
+	lines := s.Check.output()
+	if len(lines) > rows-1 {
+		lines = lines[len(lines)-(rows-1):]
+	}
+	for i := 0; i < rows-1; i++ {
+		text := ""
+		if i < len(lines) {
+			text = strings.ReplaceAll(strings.TrimRight(lines[i], "\r"), "\t", "    ")
+		}
+		col := drawText(s.Screen, 0, top+1+i, text, s.Theme.Default, s.Width)
+		clearToEnd(s, s.Screen, col, top+1+i, s.Width)
+	}
+}
+
+// showCheckOutput opens the whole output of the last check in a panel.
+func showCheckOutput(s *State) {
+	if s.Check == nil {
+		s.FlashMsg = "No check run yet (& runs one)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	showCommandOutput(s, s.Check.command, strings.Join(s.Check.output(), "\n"), s.Check.err)
+}
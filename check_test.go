@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestExpandLists(t *testing.T) {
+	lists := map[string][]string{"packages": {".", "./pkg/a"}, "files": {"a.go", "it's here.go"}}
+	got := expandLists("go test -run X {packages} && gofmt -l {files}", lists)
+	want := `go test -run X . ./pkg/a && gofmt -l a.go 'it'\''s here.go'`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// A name with a placeholder in it is not expanded inside its quotes
+	lists = map[string][]string{"files": {"x{dirs}"}, "dirs": {"$(touch pwn)"}}
+	for range 20 {
+		got := expandLists("echo {files} {dirs}", lists)
+		if want := `echo 'x{dirs}' '$(touch pwn)'`; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestCheckVars(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	for _, f := range []string{"main.go", "pkg/a/a.go", "docs/README.md"} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &State{Hunks: []Hunk{{File: "pkg/a/a.go"}, {File: "main.go"}, {File: "docs/README.md"}, {File: "gone/b.go", Status: 'D'}}}
+	root, _ := repo.Root()
+	vars := s.checkVars(root)
+	if want := []string{".", "./pkg/a"}; !reflect.DeepEqual(vars["packages"], want) {
+		t.Errorf("packages %q, want %q", vars["packages"], want)
+	}
+	if want := []string{".", "docs", "pkg/a"}; !reflect.DeepEqual(vars["dirs"], want) {
+		t.Errorf("dirs %q, want %q", vars["dirs"], want)
+	}
+	if want := []string{"pkg/a/a.go", "main.go", "docs/README.md"}; !reflect.DeepEqual(vars["files"], want) {
+		t.Errorf("files %q, want %q", vars["files"], want)
+	}
+}
+
+func TestCheckPanel(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	if err := os.WriteFile("a.go", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("check.sh", []byte("echo checking \"$@\"\nexit 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(80, 24)
+	s := &State{Screen: sim, Width: 80, Height: 24, Theme: NewUITheme(""), Hunks: []Hunk{{File: "a.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "package a"}}}}}
+	s.Config.Check = "sh check.sh {files}"
+	s.BuildLines()
+
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, '&', tcell.ModNone))
+	if s.Check == nil || !s.CheckPanel {
+		t.Fatalf("& started no check: %s", s.FlashMsg)
+	}
+	if s.Check.command != "sh check.sh a.go" {
+		t.Errorf("command %q", s.Check.command)
+	}
+	deadline := time.After(5 * time.Second)
+	for !s.Check.done {
+		select {
+		case <-deadline:
+			t.Fatal("check never finished")
+		default:
+		}
+		if ev, ok := sim.PollEvent().(*EventCheckOutput); ok {
+			handleCheckOutput(ev)
+		}
+	}
+	if got := s.Check.status(); got != "✗ check failed (exit 1)" {
+		t.Errorf("status %q", got)
+	}
+	if got := s.viewRows(); got != 24-1-8 {
+		t.Errorf("diff rows with the panel open: %d", got)
+	}
+	Render(s)
+	screen := strings.Join(screenText(sim, 80, 24), "\n")
+	if !strings.Contains(screen, "checking a.go") || !strings.Contains(screen, "✗ check failed") {
+		t.Errorf("panel not drawn:\n%s", screen)
+	}
+
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, '&', tcell.ModNone))
+	if s.CheckPanel || s.viewRows() != 23 {
+		t.Errorf("second & kept the panel open")
+	}
+}
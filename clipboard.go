@@ -2,20 +2,279 @@ package main
 
 import (
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
-// copyToClipboard copies text to clipboard using OSC 52.
-// Writes directly to /dev/tty to bypass tcell buffering.
-// Returns true on success, false if the write failed.
-func copyToClipboard(text string) bool {
+// Clipboard backends, tried in the order of clipboard in the config until
+// one works: the OSC 52 escape sequence through the terminal, a system
+// clipboard program, and a temporary file whose path is shown.
+var clipboardBackendNames = []string{"osc52", "tool", "file"}
+
+// defaultClipboard is the order of the backends without clipboard in the
+// config.
+const defaultClipboard = "osc52 tool file"
+
+// defaultOSC52Limit is the longest OSC 52 sequence sent, in bytes. Many
+// terminals cap it around 100K and drop or cut what is longer without a
+// word, so longer text goes to the next backend.
+const defaultOSC52Limit = 100000
+
+// osc52Limit returns the longest OSC 52 sequence to send, or 0 for any.
+func (c *Config) osc52Limit() int {
+	switch {
+	case c.OSC52Limit == 0:
+		return defaultOSC52Limit
+	case c.OSC52Limit < 0:
+		return 0
+	}
+	return c.OSC52Limit
+}
+
+// clipboardBackend is a way of copying text. Programs run off the main
+// goroutine when wiff runs interactively, as they may be slow to start.
+type clipboardBackend struct {
+	name    string // what the flash says the text was copied with
+	program bool
+	copy    func(text string) (note string, err error)
+}
+
+// parseClipboard checks the backend names of clipboard in the config.
+func parseClipboard(value string) error {
+	names := strings.Fields(value)
+	if len(names) == 0 {
+		return fmt.Errorf("want some of %s", strings.Join(clipboardBackendNames, ", "))
+	}
+	for _, n := range names {
+		if !slices.Contains(clipboardBackendNames, n) {
+			return fmt.Errorf("want some of %s, got %q", strings.Join(clipboardBackendNames, ", "), n)
+		}
+	}
+	return nil
+}
+
+// clipboardChain returns the backends to try, in order. A system program
+// is only tried when one is found.
+func (s *State) clipboardChain() []clipboardBackend {
+	order := s.Config.Clipboard
+	if order == "" {
+		order = defaultClipboard
+	}
+	var chain []clipboardBackend
+	for _, name := range strings.Fields(order) {
+		switch name {
+		case "osc52":
+			limit := s.Config.osc52Limit()
+			chain = append(chain, clipboardBackend{name: "OSC 52", copy: func(text string) (string, error) {
+				return osc52Copy(text, limit)
+			}})
+		case "tool":
+			if args := clipboardTool(s.Config.ClipboardCommand); len(args) > 0 {
+				chain = append(chain, clipboardBackend{name: filepath.Base(args[0]), program: true, copy: func(text string) (string, error) {
+					return "", runClipboardTool(args, text)
+				}})
+			}
+		case "file":
+			chain = append(chain, clipboardBackend{name: "file", copy: fileCopy})
+		}
+	}
+	return chain
+}
+
+// copyText copies text with the first clipboard backend that works and
+// flashes msg with the backend, or why none did.
+func copyText(s *State, text, msg string) {
+	s.copyWith(s.clipboardChain(), text, msg, nil)
+}
+
+// EventCopied is posted when a clipboard program run for copyText ends,
+// so the main loop flashes the result or tries the next backend.
+type EventCopied struct {
+	t      time.Time
+	state  *State
+	chain  []clipboardBackend
+	text   string
+	msg    string
+	failed []string
+	err    error
+}
+
+func (e *EventCopied) When() time.Time { return e.t }
+
+// copyWith tries the backends of chain in order, noting each failure in
+// failed. A program runs in the background on the terminal, and the chain
+// goes on from handleCopied.
+func (s *State) copyWith(chain []clipboardBackend, text, msg string, failed []string) {
+	for i, b := range chain {
+		if b.program && ttyScreen != nil {
+			go func() {
+				_, err := b.copy(text)
+				_ = ttyScreen.PostEvent(&EventCopied{t: time.Now(), state: s, chain: chain[i:], text: text, msg: msg, failed: failed, err: err})
+			}()
+			return
+		}
+		note, err := b.copy(text)
+		if err == nil {
+			s.flashCopied(msg, b, note)
+			return
+		}
+		logger.Warn("copy failed", "backend", b.name, "err", err)
+		failed = append(failed, b.name+": "+err.Error())
+	}
+	if len(failed) == 0 {
+		failed = []string{"no clipboard backend"}
+	}
+	s.FlashMsg = "Copy failed (" + strings.Join(failed, "; ") + ")"
+	s.FlashExpiry = time.Now().Add(3 * time.Second)
+}
+
+// handleCopied flashes the result of a clipboard program, or goes on to
+// the backends after it when it failed.
+func handleCopied(ev *EventCopied) {
+	s, b := ev.state, ev.chain[0]
+	if ev.err == nil {
+		s.flashCopied(ev.msg, b, "")
+		return
+	}
+	logger.Warn("copy failed", "backend", b.name, "err", ev.err)
+	s.copyWith(ev.chain[1:], ev.text, ev.msg, append(ev.failed, b.name+": "+ev.err.Error()))
+}
+
+// flashCopied flashes msg with the backend that copied the text.
+func (s *State) flashCopied(msg string, b clipboardBackend, note string) {
+	if note != "" {
+		s.FlashMsg = msg + " (" + note + ")"
+	} else {
+		s.FlashMsg = msg + " (" + b.name + ")"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// osc52Copy copies text to the clipboard using OSC 52, written directly to
+// /dev/tty to bypass tcell buffering. Where the sequence is known to be
+// dropped without a word, or is longer than limit bytes (unless 0), it
+// fails instead, so the next backend is tried.
+func osc52Copy(text string, limit int) (string, error) {
+	seq := "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if limit > 0 && len(seq) > limit {
+		return "", fmt.Errorf("%s is over the limit of %s (osc52_limit)", formatBytes(len(seq)), formatBytes(limit))
+	}
+	if err := osc52Unsupported(); err != nil {
+		return "", err
+	}
 	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
 	if err != nil {
-		return false
+		return "", err
 	}
 	defer func() { _ = tty.Close() }()
 
-	encoded := base64.StdEncoding.EncodeToString([]byte(text))
-	_, err = tty.WriteString("\033]52;c;" + encoded + "\a")
-	return err == nil
+	_, err = tty.WriteString(seq)
+	return "", err
+}
+
+// osc52Unsupported returns why the terminal ignores OSC 52, when it is
+// known to: the Linux console, Apple's Terminal, GNU screen, and tmux
+// unless set-clipboard is on (it takes the sequence from programs only
+// then).
+var osc52Unsupported = sync.OnceValue(func() error {
+	term := os.Getenv("TERM")
+	switch {
+	case term == "linux" || term == "dumb":
+		return fmt.Errorf("not supported by TERM=%s", term)
+	case os.Getenv("TERM_PROGRAM") == "Apple_Terminal":
+		return errors.New("not supported by Terminal.app")
+	case os.Getenv("TMUX") != "":
+		cmd := exec.Command("tmux", "show-options", "-gv", "set-clipboard")
+		start := time.Now()
+		out, err := cmd.Output()
+		logCommand(cmd, start, err)
+		if v := strings.TrimSpace(string(out)); err == nil && v != "on" {
+			return fmt.Errorf("tmux set-clipboard is %s", v)
+		}
+	case os.Getenv("STY") != "":
+		return errors.New("not passed on by GNU screen")
+	}
+	return nil
+})
+
+// clipboardTool returns the command that copies its standard input to the
+// system clipboard: command when set (clipboard_command in the config),
+// otherwise the first program found for this system, or nil.
+func clipboardTool(command string) []string {
+	if command != "" {
+		return strings.Fields(command)
+	}
+	var candidates [][]string
+	if runtime.GOOS == "darwin" {
+		candidates = append(candidates, []string{"pbcopy"})
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		candidates = append(candidates, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	candidates = append(candidates, []string{"clip.exe"}) // WSL
+	if os.Getenv("TMUX") != "" {
+		candidates = append(candidates, []string{"tmux", "load-buffer", "-w", "-"})
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// clipboardToolTimeout bounds a clipboard program, which may wait for a
+// display that isn't there.
+const clipboardToolTimeout = 3 * time.Second
+
+// runClipboardTool runs the clipboard program args with text as its input.
+func runClipboardTool(args []string, text string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	// No output is captured: xclip and wl-copy stay behind to serve the
+	// clipboard, and would hold a pipe open
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		logCommand(cmd, start, err)
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(clipboardToolTimeout):
+		_ = cmd.Process.Kill()
+		err = fmt.Errorf("no answer after %v", clipboardToolTimeout)
+	}
+	logCommand(cmd, start, err)
+	return err
+}
+
+// fileCopy writes text to a temporary file, the last resort when there is
+// no clipboard, and notes its path.
+func fileCopy(text string) (string, error) {
+	f, err := os.CreateTemp("", "wiff-clipboard-*.txt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return "no clipboard, saved to " + f.Name(), nil
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyTextFallsBackToFile(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	s := &State{Config: Config{Clipboard: "tool file", ClipboardCommand: "false"}}
+	copyText(s, "hello\n", "Copied line")
+	path, ok := strings.CutPrefix(s.FlashMsg, "Copied line (no clipboard, saved to ")
+	if !ok {
+		t.Fatalf("flash = %q, want the file the text was saved to", s.FlashMsg)
+	}
+	data, err := os.ReadFile(strings.TrimSuffix(path, ")"))
+	if err != nil || string(data) != "hello\n" {
+		t.Errorf("saved %q, %v", data, err)
+	}
+}
+
+func TestCopyTextNamesTheTool(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "clip")
+	tool := filepath.Join(dir, "mycopy")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\ncat > "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := &State{Config: Config{Clipboard: "tool file", ClipboardCommand: tool}}
+	copyText(s, "text", "Yanked line")
+	if s.FlashMsg != "Yanked line (mycopy)" {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "text" {
+		t.Errorf("tool got %q", data)
+	}
+}
+
+func TestCopyTextFailure(t *testing.T) {
+	s := &State{Config: Config{Clipboard: "tool", ClipboardCommand: "false"}}
+	copyText(s, "text", "Copied line")
+	if !strings.HasPrefix(s.FlashMsg, "Copy failed (false: ") {
+		t.Errorf("flash = %q, want the failure of each backend", s.FlashMsg)
+	}
+}
+
+func TestCopyTextOverOSC52Limit(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	text := strings.Repeat("x", 300)
+	s := &State{Config: Config{Clipboard: "osc52", OSC52Limit: 100}}
+	copyText(s, text, "Yanked")
+	if want := "Copy failed (OSC 52: 408B is over the limit of 100B (osc52_limit))"; s.FlashMsg != want {
+		t.Errorf("flash = %q, want %q", s.FlashMsg, want)
+	}
+	s.Config.Clipboard = "osc52 file"
+	copyText(s, text, "Yanked")
+	if !strings.Contains(s.FlashMsg, "saved to") {
+		t.Errorf("flash = %q, want the text saved to a file", s.FlashMsg)
+	}
+}
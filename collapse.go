@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Built-in safety limits; see Config.MaxHighlightLine, Config.CollapseLines
+// and Config.TruncateHunk.
+const (
+	defaultMaxHighlightLine = 2000
+	defaultCollapseLines    = 5000
+	defaultTruncateHunk     = 300
+)
+
+// maxHighlightLine returns the longest line (in bytes) that still gets
+// syntax highlighting, or 0 for no limit.
+func (c *Config) maxHighlightLine() int {
+	switch {
+	case c.MaxHighlightLine == 0:
+		return defaultMaxHighlightLine
+	case c.MaxHighlightLine < 0:
+		return 0
+	}
+	return c.MaxHighlightLine
+}
+
+// collapseGenerated reports whether generated files start collapsed.
+func (c *Config) collapseGenerated() bool {
+	return !c.NoCollapseGenerated
+}
+
+// collapseLines returns how many changed lines a file may have before it is
+// collapsed, or 0 to never collapse.
+func (c *Config) collapseLines() int {
+	switch {
+	case c.CollapseLines == 0:
+		return defaultCollapseLines
+	case c.CollapseLines < 0:
+		return 0
+	}
+	return c.CollapseLines
+}
+
+// truncateHunk returns how many rows of a hunk are shown before the rest is
+// hidden behind a placeholder row, or 0 to show every row.
+func (c *Config) truncateHunk() int {
+	switch {
+	case c.TruncateHunk == 0:
+		return defaultTruncateHunk
+	case c.TruncateHunk < 0:
+		return 0
+	}
+	return c.TruncateHunk
+}
+
+// highlightable reports whether text is short enough to syntax highlight.
+func (s *State) highlightable(text string) bool {
+	limit := s.Config.maxHighlightLine()
+	return limit == 0 || len(text) <= limit
+}
+
+// collapsedFiles returns the files shown as a single placeholder row, mapped
+// to the reason shown in the placeholder. Files the user expanded are left
+// out.
+func (s *State) collapsedFiles() map[string]string {
+	collapsed := make(map[string]string)
+	for file := range s.Generated {
+		if s.Generated[file] && !s.Expanded[file] {
+			collapsed[file] = "generated file"
+		}
+	}
+	limit := s.Config.collapseLines()
+	if limit == 0 {
+		return collapsed
+	}
+	changed := make(map[string]int)
+	for _, h := range s.Hunks {
+		for _, l := range h.Lines {
+			if l.Op != ' ' {
+				changed[h.File]++
+			}
+		}
+	}
+	for file, n := range changed {
+		if n > limit && !s.Expanded[file] {
+			collapsed[file] = fmt.Sprintf("%d changed lines", n)
+		}
+	}
+	return collapsed
+}
+
+// appendCollapsed emits the placeholder row for a collapsed file on its
+// first hunk and hides the rest. It reports false when hunk i's file is not
+// collapsed and its lines should be built normally.
+func (s *State) appendCollapsed(lines []DisplayLine, collapsed map[string]string, i int) ([]DisplayLine, bool) {
+	h := &s.Hunks[i]
+	reason, ok := collapsed[h.File]
+	if !ok {
+		return lines, false
+	}
+	if i > 0 && s.Hunks[i-1].File == h.File {
+		h.StartLine = -1
+		return lines, true
+	}
+	lines = append(lines, DisplayLine{Style: StyleNormal})
+	h.StartLine = len(lines)
+	lines = append(lines, DisplayLine{
+		Text:    "… " + reason + " collapsed (Enter to expand)",
+		Style:   StyleCollapsed,
+		HunkIdx: i,
+	})
+	return lines, true
+}
+
+// hunkKey identifies a hunk across reloads by its file and where it starts
+// on the old side, which editing the working tree doesn't move.
+func hunkKey(h *Hunk) string {
+	return fmt.Sprintf("%s:%d", h.File, h.OldStart)
+}
+
+// truncateRows keeps the first truncate_hunk rows of hunk i, followed by a
+// placeholder row counting the rest, unless the user expanded the hunk. It
+// reports whether rows were hidden.
+func (s *State) truncateRows(rows []DisplayLine, i int) ([]DisplayLine, bool) {
+	limit := s.Config.truncateHunk()
+	if limit == 0 || len(rows) <= limit || s.ExpandedHunks[hunkKey(&s.Hunks[i])] {
+		return rows, false
+	}
+	return append(rows[:limit:limit], DisplayLine{
+		Text:    fmt.Sprintf("… %d more lines (Enter to expand)", len(rows)-limit),
+		Style:   StyleCollapsed,
+		HunkIdx: i,
+	}), true
+}
+
+// ExpandCollapsed expands the collapsed file or truncated hunk at the focus
+// line, or else the first one visible on screen, reporting whether there
+// was one.
+func (s *State) ExpandCollapsed() bool {
+	idx := -1
+	if f := s.focusLine(); f >= 0 && f < len(s.Lines) && s.Lines[f].Style == StyleCollapsed {
+		idx = f
+	}
+	for i := s.Scroll; idx < 0 && i < s.Scroll+s.viewRows() && i < len(s.Lines); i++ {
+		if s.Lines[i].Style == StyleCollapsed {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return false
+	}
+	hIdx := s.Lines[idx].HunkIdx
+	if hIdx < 0 || hIdx >= len(s.Hunks) {
+		return false
+	}
+	h := &s.Hunks[hIdx]
+	if _, ok := s.collapsedFiles()[h.File]; ok {
+		if s.Expanded == nil {
+			s.Expanded = make(map[string]bool)
+		}
+		s.Expanded[h.File] = true
+		s.FlashMsg = "Expanded " + h.File
+	} else {
+		if s.ExpandedHunks == nil {
+			s.ExpandedHunks = make(map[string]bool)
+		}
+		s.ExpandedHunks[hunkKey(h)] = true
+		s.FlashMsg = "Expanded hunk " + h.Label
+	}
+	s.BuildLines()
+	s.ClampScroll()
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	return true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func bigHunk(file string, n int) Hunk {
+	lines := make([]Line, n)
+	for i := range lines {
+		lines[i] = Line{Op: '+', Content: "x"}
+	}
+	return Hunk{File: file, NewStart: 1, Lines: lines}
+}
+
+func TestLargeFileCollapsedAndExpanded(t *testing.T) {
+	s := &State{Height: 40, Width: 80, Config: Config{CollapseLines: 10}}
+	s.Hunks = []Hunk{
+		{File: "small.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "a"}}},
+		bigHunk("big.js", 8),
+		bigHunk("big.js", 8),
+	}
+	s.BuildLines()
+
+	var placeholders int
+	for _, l := range s.Lines {
+		if l.Style == StyleCollapsed {
+			placeholders++
+			if !strings.Contains(l.Text, "16 changed lines") {
+				t.Errorf("placeholder text = %q", l.Text)
+			}
+		}
+		if l.Style == StyleAdded && l.HunkIdx > 0 {
+			t.Fatal("collapsed file lines should not be built")
+		}
+	}
+	if placeholders != 1 {
+		t.Fatalf("placeholders = %d, want 1", placeholders)
+	}
+	if s.Hunks[2].StartLine != -1 {
+		t.Errorf("second hunk of collapsed file StartLine = %d, want -1", s.Hunks[2].StartLine)
+	}
+
+	s.JumpTo(s.Hunks[1].StartLine)
+	s.ExpandCollapsed()
+	if !s.Expanded["big.js"] {
+		t.Fatal("big.js should be expanded")
+	}
+	for _, l := range s.Lines {
+		if l.Style == StyleCollapsed {
+			t.Fatal("placeholder should be gone after expanding")
+		}
+	}
+	// Expansion survives rebuilds
+	s.BuildLines()
+	if s.Hunks[2].StartLine < 0 {
+		t.Error("expanded file should stay expanded after rebuild")
+	}
+}
+
+func TestHighlightable(t *testing.T) {
+	s := &State{}
+	if !s.highlightable(strings.Repeat("a", defaultMaxHighlightLine)) {
+		t.Error("line at the limit should be highlighted")
+	}
+	if s.highlightable(strings.Repeat("a", defaultMaxHighlightLine+1)) {
+		t.Error("line over the limit should not be highlighted")
+	}
+	s.Config.MaxHighlightLine = -1
+	if !s.highlightable(strings.Repeat("a", 100000)) {
+		t.Error("no limit when disabled")
+	}
+}
+
+func TestLongHunkTruncatedAndExpanded(t *testing.T) {
+	s := &State{Height: 40, Width: 80, Config: Config{TruncateHunk: 5}}
+	s.Hunks = []Hunk{bigHunk("a.go", 3), bigHunk("b.go", 12)}
+	s.Hunks[1].OldStart = 7
+	s.BuildLines()
+
+	var placeholder DisplayLine
+	added := 0
+	for _, l := range s.Lines {
+		switch l.Style {
+		case StyleCollapsed:
+			placeholder = l
+		case StyleAdded:
+			added++
+		}
+	}
+	if placeholder.Text != "… 7 more lines (Enter to expand)" || placeholder.HunkIdx != 1 {
+		t.Fatalf("placeholder = %+v", placeholder)
+	}
+	if added != 3+5 {
+		t.Errorf("%d added rows shown, want 8", added)
+	}
+
+	s.JumpTo(len(s.Lines) - 1)
+	if !s.ExpandCollapsed() || !s.ExpandedHunks["b.go:7"] || s.Expanded["b.go"] {
+		t.Fatalf("expanded hunks %v, files %v", s.ExpandedHunks, s.Expanded)
+	}
+	// Expansion survives rebuilds, also side by side
+	s.SideBySide = true
+	s.BuildLines()
+	for _, l := range s.Lines {
+		if l.Style == StyleCollapsed {
+			t.Fatal("expanded hunk truncated again after a rebuild")
+		}
+	}
+	s.Config.TruncateHunk = -1
+	s.ExpandedHunks = nil
+	s.BuildLines()
+	if n := len(s.Lines); s.Lines[n-1].Style == StyleCollapsed {
+		t.Error("truncate_hunk = off still truncates")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// colorDepth is how many colors the terminal can show.
+type colorDepth int
+
+const (
+	colorNone colorDepth = iota // no color, attributes only
+	color16
+	color256
+	colorTrue // 24-bit RGB
+)
+
+// parseColorDepth parses a --color value. "auto" (or "") means detect.
+func parseColorDepth(v string) (depth colorDepth, auto bool, err error) {
+	switch strings.ToLower(v) {
+	case "", "auto":
+		return colorTrue, true, nil
+	case "truecolor", "24bit":
+		return colorTrue, false, nil
+	case "256":
+		return color256, false, nil
+	case "16":
+		return color16, false, nil
+	case "none", "never", "off":
+		return colorNone, false, nil
+	}
+	return colorTrue, false, fmt.Errorf("invalid --color value %q (want auto, truecolor, 256, 16 or none)", v)
+}
+
+// detectColorDepth derives the color depth from NO_COLOR, COLORTERM and
+// the number of colors the screen reports from terminfo.
+func detectColorDepth(screen tcell.Screen) colorDepth {
+	if os.Getenv("NO_COLOR") != "" {
+		return colorNone
+	}
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return colorTrue
+	}
+	n := screen.Colors()
+	switch {
+	case n >= 1<<24:
+		return colorTrue
+	case n >= 256:
+		return color256
+	case n >= 8:
+		return color16
+	}
+	return colorNone
+}
+
+// colorScreen wraps a screen and maps every RGB color written to it down to
+// the nearest color of a 256 or 16 color palette, or strips colors entirely,
+// so themes render predictably on terminals without truecolor.
+type colorScreen struct {
+	tcell.Screen
+	depth   colorDepth
+	palette []tcell.Color
+	cache   map[tcell.Color]tcell.Color
+}
+
+func newColorScreen(screen tcell.Screen, depth colorDepth) *colorScreen {
+	n := 256
+	if depth == color16 {
+		n = 16
+	}
+	palette := make([]tcell.Color, n)
+	for i := range palette {
+		palette[i] = tcell.PaletteColor(i)
+	}
+	return &colorScreen{
+		Screen:  screen,
+		depth:   depth,
+		palette: palette,
+		cache:   make(map[tcell.Color]tcell.Color),
+	}
+}
+
+func (c *colorScreen) SetContent(x, y int, mainc rune, combc []rune, style tcell.Style) {
+	c.Screen.SetContent(x, y, mainc, combc, c.mapStyle(style))
+}
+
+// mapStyle degrades the foreground and background colors of style.
+func (c *colorScreen) mapStyle(style tcell.Style) tcell.Style {
+	fg, bg, attrs := style.Decompose()
+	if c.depth == colorNone {
+		return tcell.StyleDefault.Attributes(attrs)
+	}
+	return style.Foreground(c.mapColor(fg)).Background(c.mapColor(bg))
+}
+
+func (c *colorScreen) mapColor(col tcell.Color) tcell.Color {
+	if !col.Valid() {
+		return col
+	}
+	if !col.IsRGB() && int(col-tcell.ColorValid) < len(c.palette) {
+		return col
+	}
+	if m, ok := c.cache[col]; ok {
+		return m
+	}
+	m := tcell.FindColor(col, c.palette)
+	c.cache[col] = m
+	return m
+}
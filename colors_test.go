@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseColorDepth(t *testing.T) {
+	tests := []struct {
+		in    string
+		depth colorDepth
+		auto  bool
+	}{
+		{"", colorTrue, true},
+		{"auto", colorTrue, true},
+		{"truecolor", colorTrue, false},
+		{"256", color256, false},
+		{"16", color16, false},
+		{"none", colorNone, false},
+	}
+	for _, tt := range tests {
+		depth, auto, err := parseColorDepth(tt.in)
+		if err != nil || depth != tt.depth || auto != tt.auto {
+			t.Errorf("parseColorDepth(%q) = %v, %v, %v", tt.in, depth, auto, err)
+		}
+	}
+	if _, _, err := parseColorDepth("lots"); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func TestColorScreenMapsRGB(t *testing.T) {
+	c := newColorScreen(nil, color16)
+	style := tcell.StyleDefault.Foreground(tcell.NewRGBColor(250, 10, 10)).Background(tcell.ColorDefault).Bold(true)
+	fg, bg, attrs := c.mapStyle(style).Decompose()
+	if fg.IsRGB() || int(fg-tcell.ColorValid) >= 16 {
+		t.Errorf("fg = %v, want one of the 16 palette colors", fg)
+	}
+	if bg != tcell.ColorDefault {
+		t.Errorf("bg = %v, want default", bg)
+	}
+	if attrs&tcell.AttrBold == 0 {
+		t.Error("attributes should be kept")
+	}
+
+	c = newColorScreen(nil, colorNone)
+	fg, _, attrs = c.mapStyle(style).Decompose()
+	if fg != tcell.ColorDefault || attrs&tcell.AttrBold == 0 {
+		t.Errorf("none: fg = %v, attrs = %v", fg, attrs)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Side-by-side column ratio: the percent of the width the old column gets,
+// moved in steps by ^W < and ^W >.
+const (
+	defaultColumnRatio = 50
+	minColumnRatio     = 20
+	maxColumnRatio     = 80
+	columnRatioStep    = 5
+)
+
+// columnRatio returns the percent of the side-by-side width given to the
+// old column.
+func (s *State) columnRatio() int {
+	if s.ColumnRatio == 0 {
+		return defaultColumnRatio
+	}
+	return s.ColumnRatio
+}
+
+// sideBySideCols returns the widths of the old and new columns in
+// side-by-side mode, line numbers included, around the one-column divider.
+func (s *State) sideBySideCols() (left, right int) {
+	total := s.DiffWidth - s.LabelGutter - 1
+	left = total * s.columnRatio() / 100
+	return left, total - left
+}
+
+// dividerX returns the screen column of the side-by-side divider.
+func (s *State) dividerX() int {
+	left, _ := s.sideBySideCols()
+	return s.DiffX + s.LabelGutter + left
+}
+
+// AdjustColumnRatio widens the old column by delta percent, narrowing the
+// new one, or splits the width evenly again when delta is 0. The ratio is
+// saved for the next run.
+func (s *State) AdjustColumnRatio(delta int) {
+	if !s.SideBySide {
+		s.FlashMsg = "Column widths apply to the side-by-side view (s)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	ratio := defaultColumnRatio
+	if delta != 0 {
+		ratio = min(max(s.columnRatio()+delta, minColumnRatio), maxColumnRatio)
+	}
+	s.ColumnRatio = ratio
+	s.BuildLines()
+	s.ClampScroll()
+	s.FlashMsg = fmt.Sprintf("Columns %d:%d", ratio, 100-ratio)
+	if err := saveColumnRatio(ratio); err != nil {
+		logger.Warn("saving the column ratio failed", "err", err)
+		s.FlashMsg += " (not saved: " + err.Error() + ")"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// statePath returns the file wiff keeps view settings in between runs,
+// $XDG_STATE_HOME/wiff/state, or "" without a home directory.
+func statePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "wiff", "state")
+}
+
+// loadColumnRatio returns the column ratio saved by the last run, or 0
+// when there is none.
+func loadColumnRatio() int {
+	f, err := os.Open(statePath())
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), "=")
+		if !ok || strings.TrimSpace(key) != "column_ratio" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < minColumnRatio || n > maxColumnRatio {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+// saveColumnRatio writes the column ratio to the state file.
+func saveColumnRatio(ratio int) error {
+	path := statePath()
+	if path == "" {
+		return fmt.Errorf("no home directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(fmt.Sprintf("column_ratio = %d\n", ratio)), 0o644)
+}
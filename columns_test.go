@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestColumnRatio(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(64, 12)
+	s := &State{Screen: sim, Width: 64, Height: 12, SideBySide: true, Hunks: []Hunk{
+		{Label: "q", File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{{Op: '+', Content: "added"}}},
+	}}
+	s.BuildLines()
+	Render(s)
+	left, right := s.sideBySideCols()
+	if left != right && left+1 != right {
+		t.Fatalf("default columns %d and %d, want even", left, right)
+	}
+
+	HandleKey(s, tcell.NewEventKey(tcell.KeyCtrlW, 0, tcell.ModCtrl))
+	HandleKey(s, makeKeyEvent('<'))
+	if s.ColumnRatio != 45 || s.FlashMsg != "Columns 45:55" {
+		t.Fatalf("ratio %d, flash %q", s.ColumnRatio, s.FlashMsg)
+	}
+	for range 10 {
+		s.AdjustColumnRatio(-columnRatioStep)
+	}
+	if s.ColumnRatio != minColumnRatio {
+		t.Errorf("ratio %d, want it clamped at %d", s.ColumnRatio, minColumnRatio)
+	}
+	if got := loadColumnRatio(); got != minColumnRatio {
+		t.Errorf("saved ratio %d, want %d", got, minColumnRatio)
+	}
+
+	// The divider moves and runs to the bottom of the pane
+	Render(s)
+	x := s.dividerX()
+	rows := screenText(sim, 64, s.viewRows())
+	for y, row := range rows[2:] {
+		if r := []rune(row); r[x] != '│' {
+			t.Errorf("row %d: %q has no divider at %d", y+2, row, x)
+		}
+	}
+	if !strings.Contains(string([]rune(rows[3])[x:]), "+added") {
+		t.Errorf("added line not in the new column: %q", rows[3])
+	}
+
+	HandleKey(s, tcell.NewEventKey(tcell.KeyCtrlW, 0, tcell.ModCtrl))
+	HandleKey(s, makeKeyEvent('='))
+	if s.columnRatio() != defaultColumnRatio {
+		t.Errorf("^W = left ratio %d", s.columnRatio())
+	}
+}
+
+func TestLoadColumnRatioIgnoresBadValues(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	if got := loadColumnRatio(); got != 0 {
+		t.Errorf("without a state file: %d", got)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "wiff"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "wiff", "state"), []byte("column_ratio = 95\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadColumnRatio(); got != 0 {
+		t.Errorf("out of range ratio loaded as %d", got)
+	}
+}
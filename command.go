@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// expandCommand splits a command template into arguments and substitutes
+// {name} placeholders from vars in each argument. Splitting happens before
+// substitution, so values containing spaces stay a single argument.
+func expandCommand(tmpl string, vars map[string]string) []string {
+	fields := strings.Fields(tmpl)
+	args := make([]string, 0, len(fields))
+	for _, f := range fields {
+		args = append(args, expandPlaceholders(f, vars))
+	}
+	return args
+}
+
+// expandPlaceholders replaces every {name} in text with vars[name], in one
+// pass from left to right: values are copied as they are, never expanded
+// again. Unknown placeholders are left as-is.
+func expandPlaceholders(text string, vars map[string]string) string {
+	var sb strings.Builder
+	for {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(text[open+1:], '}')
+		if end < 0 {
+			break
+		}
+		end += open + 1
+		v, ok := vars[text[open+1:end]]
+		if !ok {
+			// Not a placeholder; a { after this one may start one
+			sb.WriteString(text[:open+1])
+			text = text[open+1:]
+			continue
+		}
+		sb.WriteString(text[:open])
+		sb.WriteString(v)
+		text = text[end+1:]
+	}
+	sb.WriteString(text)
+	return sb.String()
+}
+
+// runSuspended suspends the TUI, runs a command attached to the terminal,
+// and resumes the TUI when it exits.
+func runSuspended(s *State, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	s.Screen.Fini()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	// Resume TUI
+	if err := s.Screen.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal: failed to reinitialize screen: %v\n", err)
+		os.Exit(1)
+	}
+	s.enableMouse()
+	s.Screen.Sync()
+	return runErr
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandCommand(t *testing.T) {
+	got := expandCommand("code -g {file}:{line}", map[string]string{
+		"file": "/repo/my file.go",
+		"line": "42",
+	})
+	want := []string{"code", "-g", "/repo/my file.go:42"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestExpandCommandUnknownPlaceholder(t *testing.T) {
+	got := expandCommand("idea --line {line} {other}", map[string]string{"line": "7"})
+	want := []string{"idea", "--line", "7", "{other}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestExpandPlaceholdersOnce(t *testing.T) {
+	vars := map[string]string{"input": "a{file}", "file": "F"}
+	// Run it a few times: an expansion depending on map order would vary
+	for range 20 {
+		if got := expandPlaceholders("{input} {file} {{file}} {x}", vars); got != "a{file} F {F} {x}" {
+			t.Fatalf("expandPlaceholders() = %q", got)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commitFile is one file of the staged diff, as the message heuristics see it.
+type commitFile struct {
+	Path           string
+	Status         byte
+	Added, Removed int
+}
+
+// commitFiles summarizes hunks per file, in diff order.
+func commitFiles(hunks []Hunk) []commitFile {
+	var files []commitFile
+	index := make(map[string]int)
+	for _, h := range hunks {
+		i, ok := index[h.File]
+		if !ok {
+			i = len(files)
+			index[h.File] = i
+			files = append(files, commitFile{Path: h.File, Status: h.Status})
+		}
+		for _, l := range h.Lines {
+			switch l.Op {
+			case '+':
+				files[i].Added++
+			case '-':
+				files[i].Removed++
+			}
+		}
+	}
+	return files
+}
+
+// commitType guesses the conventional-commit type of a change, or returns
+// "" when the files don't tell.
+func commitType(files []commitFile) string {
+	all := func(pred func(p string) bool) bool {
+		for _, f := range files {
+			if !pred(f.Path) {
+				return false
+			}
+		}
+		return true
+	}
+	isDoc := func(p string) bool {
+		ext := strings.ToLower(path.Ext(p))
+		return ext == ".md" || ext == ".rst" || ext == ".txt" || strings.HasPrefix(p, "docs/") || strings.HasPrefix(p, "doc/")
+	}
+	isTest := func(p string) bool {
+		base := path.Base(p)
+		return strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+			strings.HasPrefix(base, "test_") || strings.HasPrefix(p, "test/") || strings.HasPrefix(p, "tests/") || strings.Contains(p, "/testdata/")
+	}
+	isCI := func(p string) bool {
+		return strings.HasPrefix(p, ".github/") || strings.HasPrefix(p, ".gitlab-ci") || strings.HasPrefix(p, ".circleci/")
+	}
+	isBuild := func(p string) bool {
+		switch path.Base(p) {
+		case "go.mod", "go.sum", "package.json", "Cargo.toml", "Makefile", "Dockerfile", "pyproject.toml":
+			return true
+		}
+		return excluded(generatedPatterns, p)
+	}
+
+	switch {
+	case len(files) == 0:
+		return "chore"
+	case all(isDoc):
+		return "docs"
+	case all(isTest):
+		return "test"
+	case all(isCI):
+		return "ci"
+	case all(isBuild):
+		return "build"
+	}
+	added, removed, renamed := 0, 0, 0
+	for _, f := range files {
+		switch f.Status {
+		case 'A':
+			added++
+		case 'D':
+			removed++
+		case 'R':
+			renamed++
+		}
+	}
+	switch {
+	case added > 0:
+		return "feat"
+	case removed+renamed == len(files):
+		return "refactor"
+	}
+	// An edit may be a fix, a feature or a refactor; better no type than a
+	// wrong one
+	return ""
+}
+
+// commitScope returns the directory all files share, or the file name of a
+// single file, or "" when the change is spread out.
+func commitScope(files []commitFile) string {
+	if len(files) == 1 {
+		base := path.Base(files[0].Path)
+		return strings.TrimSuffix(base, path.Ext(base))
+	}
+	scope := ""
+	for i, f := range files {
+		dir, _, ok := strings.Cut(f.Path, "/")
+		if !ok {
+			return ""
+		}
+		if i == 0 {
+			scope = dir
+		} else if dir != scope {
+			return ""
+		}
+	}
+	return scope
+}
+
+// commitSubject describes what happened to the files in a few words.
+func commitSubject(files []commitFile) string {
+	if len(files) == 1 {
+		f := files[0]
+		switch f.Status {
+		case 'A':
+			return "add " + path.Base(f.Path)
+		case 'D':
+			return "remove " + path.Base(f.Path)
+		case 'R':
+			return "rename " + path.Base(f.Path)
+		}
+		return "update " + path.Base(f.Path)
+	}
+	return fmt.Sprintf("update %d files", len(files))
+}
+
+// suggestCommitMessage builds a conventional-commit message for hunks:
+// a "type(scope): subject" line and a body listing the files. Without a
+// type the line is "scope: subject", or the subject alone.
+func suggestCommitMessage(hunks []Hunk) string {
+	files := commitFiles(hunks)
+	kind, scope := commitType(files), commitScope(files)
+	prefix := kind
+	switch {
+	case kind != "" && scope != "":
+		prefix = kind + "(" + scope + ")"
+	case kind == "":
+		prefix = scope
+	}
+	if prefix != "" {
+		prefix += ": "
+	}
+	var sb strings.Builder
+	sb.WriteString(prefix + commitSubject(files) + "\n")
+	if len(files) > 1 {
+		sorted := append([]commitFile(nil), files...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+		sb.WriteString("\n")
+		for _, f := range sorted {
+			fmt.Fprintf(&sb, "- %s (+%d -%d)\n", f.Path, f.Added, f.Removed)
+		}
+	}
+	return sb.String()
+}
+
+// stagedDiff returns the raw staged diff.
+func stagedDiff() ([]byte, error) {
+	return exec.Command("git", "diff", "--cached", "--no-color").Output()
+}
+
+// stagedCommitMessage returns the suggested message for the staged
+// changes, or flashes why there is none.
+func stagedCommitMessage(s *State) (string, bool) {
+	if s.PipeMode || s.NoIndex {
+		s.FlashMsg = "Commit needs a git repository diff"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return "", false
+	}
+	raw, err := stagedDiff()
+	if err != nil || len(raw) == 0 {
+		s.FlashMsg = "Nothing staged to commit"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return "", false
+	}
+	if s.Config.CommitMessage != "" {
+		msg, err := pipeThrough(s.Config.CommitMessage, string(raw))
+		if err == nil && strings.TrimSpace(msg) == "" {
+			err = errors.New("no message written")
+		}
+		if err != nil {
+			s.FlashMsg = fmt.Sprintf("commit_message: %v", err)
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+			return "", false
+		}
+		return strings.TrimSpace(msg), true
+	}
+	hunks, err := parseDiff(raw)
+	if err != nil {
+		s.FlashMsg = fmt.Sprintf("Commit: %v", err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return "", false
+	}
+	return strings.TrimSpace(suggestCommitMessage(hunks)), true
+}
+
+// commitStaged suggests a message for the staged changes and opens git's
+// commit editor pre-filled with it. The commit_message command, when
+// configured, writes the suggestion from the staged diff on its stdin.
+func commitStaged(s *State) {
+	msg, ok := stagedCommitMessage(s)
+	if !ok {
+		return
+	}
+	if err := runSuspended(s, []string{"git", "commit", "--edit", "-m", msg}); err != nil {
+		s.FlashMsg = "Commit aborted"
+	} else {
+		s.FlashMsg = "Committed"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	reloadDiff(s)
+}
+
+// quickCommit commits the staged changes without an editor (gK): the
+// subject of the suggested message is edited in a prompt, and the rest of
+// the message, the list of files, follows it.
+func quickCommit(s *State) {
+	msg, ok := stagedCommitMessage(s)
+	if !ok {
+		return
+	}
+	subject, body, _ := strings.Cut(msg, "\n")
+	OpenPrompt(s, &InputPrompt{
+		Label:   "commit: ",
+		Input:   subject,
+		History: "commit",
+		OnSubmit: func(s *State, subject string) {
+			if strings.TrimSpace(subject) == "" {
+				s.FlashMsg = "Empty commit message, nothing committed"
+				s.FlashExpiry = time.Now().Add(2 * time.Second)
+				return
+			}
+			commitWithMessage(s, strings.TrimSpace(subject+"\n"+body))
+		},
+	})
+}
+
+// commitWithMessage commits the staged changes with msg and reloads.
+func commitWithMessage(s *State, msg string) {
+	cmd := exec.Command("git", "commit", "-q", "-F", "-")
+	if root, err := repo.Root(); err == nil {
+		cmd.Dir = root
+	}
+	cmd.Stdin = strings.NewReader(msg + "\n")
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
+		logger.Warn("commit failed", "err", err, "output", string(out))
+		s.FlashMsg = "Commit failed: " + firstLine(strings.TrimSpace(string(out)))
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.FlashMsg = "Committed: " + firstLine(msg)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	reloadDiff(s)
+}
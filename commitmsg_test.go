@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestSuggestCommitMessage(t *testing.T) {
+	hunk := func(file string, status byte, ops string) Hunk {
+		h := Hunk{File: file, Status: status}
+		for _, op := range ops {
+			h.Lines = append(h.Lines, Line{Op: op, Content: "x"})
+		}
+		return h
+	}
+	tests := []struct {
+		name  string
+		hunks []Hunk
+		want  string
+	}{
+		{"single modified file", []Hunk{hunk("render.go", 'M', "+-")}, "render: update render.go\n"},
+		{"new file", []Hunk{hunk("cmd/tool/main.go", 'A', "++")}, "feat(main): add main.go\n"},
+		{"docs only", []Hunk{hunk("README.md", 'M', "+"), hunk("docs/usage.md", 'M', "-")},
+			"docs: update 2 files\n\n- README.md (+1 -0)\n- docs/usage.md (+0 -1)\n"},
+		{"tests share a dir", []Hunk{hunk("pkg/a_test.go", 'M', "+"), hunk("pkg/b_test.go", 'M', "+"), hunk("pkg/b_test.go", 'M', "+")},
+			"test(pkg): update 2 files\n\n- pkg/a_test.go (+1 -0)\n- pkg/b_test.go (+2 -0)\n"},
+		{"deleted file", []Hunk{hunk("old.go", 'D', "--")}, "refactor(old): remove old.go\n"},
+		{"edits across dirs", []Hunk{hunk("a/x.go", 'M', "+"), hunk("b/y.go", 'M', "-")},
+			"update 2 files\n\n- a/x.go (+1 -0)\n- b/y.go (+0 -1)\n"},
+		{"dependencies", []Hunk{hunk("go.mod", 'M', "+"), hunk("go.sum", 'M', "+")},
+			"build: update 2 files\n\n- go.mod (+1 -0)\n- go.sum (+1 -0)\n"},
+	}
+	for _, tt := range tests {
+		if got := suggestCommitMessage(tt.hunks); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCommitMessageCommandWithoutOutput(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.txt", []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "a.txt"}} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	s := &State{Config: Config{CommitMessage: "true"}}
+	if _, ok := stagedCommitMessage(s); ok || s.FlashMsg != "commit_message: no message written" {
+		t.Errorf("ok %v, flash %q", ok, s.FlashMsg)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// ToggleChangesOnly hides the context lines of every hunk, leaving only the
+// added and removed lines under the file and hunk headers, or shows them
+// again. The view stays on the current hunk.
+func (s *State) ToggleChangesOnly() {
+	hunk := -1
+	if len(s.Hunks) > 0 {
+		hunk = s.CurrentHunkIndex()
+	}
+	s.ChangesOnly = !s.ChangesOnly
+	s.BuildLines()
+	s.ClampScroll()
+	if hunk >= 0 && s.Hunks[hunk].StartLine >= 0 {
+		s.JumpTo(s.Hunks[hunk].StartLine)
+	}
+	if s.ChangesOnly {
+		s.FlashMsg = "Showing changed lines only"
+	} else {
+		s.FlashMsg = "Showing context lines"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// changedRows returns the rows of a hunk without its context rows in
+// changes-only mode, and rows as they are otherwise.
+func (s *State) changedRows(rows []DisplayLine) []DisplayLine {
+	if !s.ChangesOnly {
+		return rows
+	}
+	changed := rows[:0:0]
+	for _, r := range rows {
+		if r.Style != StyleContext {
+			changed = append(changed, r)
+		}
+	}
+	return changed
+}
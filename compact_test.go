@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestChangesOnlyHidesContext(t *testing.T) {
+	s := &State{Height: 40, Width: 80}
+	s.Hunks = []Hunk{{File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{
+		{Op: ' ', Content: "before"},
+		{Op: '-', Content: "old"},
+		{Op: '+', Content: "new"},
+		{Op: ' ', Content: "after"},
+	}}}
+	count := func() (context, changed int) {
+		for _, l := range s.Lines {
+			switch l.Style {
+			case StyleContext:
+				context++
+			case StyleAdded, StyleRemoved:
+				changed++
+			}
+		}
+		return
+	}
+
+	for _, sbs := range []bool{false, true} {
+		s.SideBySide, s.ChangesOnly = sbs, false
+		s.BuildLines()
+		if context, _ := count(); context == 0 {
+			t.Fatalf("side by side %v: context lines should show by default", sbs)
+		}
+		s.ToggleChangesOnly()
+		context, changed := count()
+		if context != 0 || changed == 0 {
+			t.Errorf("side by side %v: changes only has %d context and %d changed rows", sbs, context, changed)
+		}
+		if s.Hunks[0].StartLine < 0 || s.Lines[s.Hunks[0].StartLine].Style != StyleHunkHeader {
+			t.Errorf("side by side %v: hunk header should stay", sbs)
+		}
+		s.ToggleChangesOnly()
+		if context, _ := count(); context == 0 {
+			t.Errorf("side by side %v: context lines should come back", sbs)
+		}
+	}
+}
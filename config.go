@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// Config holds user settings read from the config file. The zero value is
+// a valid config: every accessor falls back to the built-in default.
+type Config struct {
+	StatusLeft       []string      // status bar segments drawn from the left edge
+	StatusRight      []string      // status bar segments right-aligned
+	Scrollbar        bool          // draw a thin scrollbar at the right edge of the diff pane
+	CenterJumps      bool          // center hunk/file/match jump targets vertically
+	Openers          []Opener      // opener.<name> commands, in config order
+	Difftool         string        // command template with {old} and {new} temp files
+	EditorServer     string        // editor_server: "nvim <address>" or "emacs [<server>]" for o
+	EditorPane       string        // editor_pane: auto, tmux, kitty or a command opening a pane
+	Theme            string        // chroma style used when -t and WIFF_THEME are unset
+	ColorMoved       bool          // color moved lines like git diff --color-moved
+	Highlighter      string        // syntax highlighter backend, "" or "chroma" for the default, "tree-sitter" with -tags treesitter
+	DiffAlgorithm    string        // diff_algorithm used when --diff-algorithm is not given
+	Excludes         []string      // exclude patterns, hidden from the view
+	Generated        []string      // generated patterns, collapsed like lock files
+	TreeIcons        string        // tree_icons: letter, symbol, nerd or none
+	Labels           string        // labels: gutter, header or hidden
+	SearchCase       string        // search_case: smart, ignore or match
+	Clipboard        string        // clipboard: backends to copy with, in order; "" = osc52 tool file
+	ClipboardCommand string        // clipboard_command: program copying its input, instead of the detected one
+	SearchWord       bool          // search_word: match whole words only
+	TodoMarkers      []string      // todo_markers flagged on added lines; nil = defaults, empty = off
+	Commands         []UserCommand // command.<key> user commands, in config order
+	Assistant        string        // assistant command; the prompt is written to its stdin
+	Prompts          []Prompt      // assistant.<name> prompt templates, in config order
+	CommitMessage    string        // commit_message command; reads the staged diff, writes a message
+	Diagnostics      string        // diagnostics command; its issues are marked on the diff's lines
+	Check            string        // check shell command run on the touched packages by &
+
+	NoCollapseGenerated bool // collapse_generated = false: show generated files expanded
+	NoAltScreen         bool // alt_screen = false: draw on the main screen, like --no-alt-screen
+	ConfirmApply        bool // confirm_apply = true: confirm each stage, like --confirm-apply
+
+	Languages []LangOverride // lang.<pattern> lexer mappings, in config order
+
+	HideSplitOps    bool // side_by_side_ops = false: no +/- column in side-by-side mode
+	BothLineNumbers bool // inline_line_numbers = both: old and new numbers inline
+
+	MaxHighlightLine int // skip highlighting longer lines; 0 = default, <0 = no limit
+	CollapseLines    int // collapse files with more changed lines; 0 = default, <0 = never
+	TruncateHunk     int // rows of a hunk shown before the rest is hidden; 0 = default, <0 = all
+	OSC52Limit       int // longest OSC 52 sequence sent, in bytes; 0 = default, <0 = any
+
+	ScrollOff   int // lines kept between the cursor or a jump target and the view's edges
+	PageOverlap int // lines of the last page kept by PgDn/PgUp; 0 = default, <0 = none
+	WheelStep   int // lines per mouse wheel notch; 0 = default
+}
+
+// configPath returns the location of the config file: $WIFF_CONFIG if set,
+// otherwise $XDG_CONFIG_HOME/wiff/config (or ~/.config/wiff/config).
+func configPath() string {
+	if p := os.Getenv("WIFF_CONFIG"); p != "" {
+		return p
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "wiff", "config")
+}
+
+// LoadConfig reads the config file. A missing file is not an error.
+func LoadConfig() (Config, error) {
+	path := configPath()
+	if path == "" {
+		return Config{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Config{}, nil
+		}
+		return Config{}, err
+	}
+	defer func() { _ = f.Close() }()
+	cfg, err := parseConfig(f)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parseConfig parses "key = value" lines. Blank lines and lines starting
+// with '#' are ignored.
+func parseConfig(r io.Reader) (Config, error) {
+	var cfg Config
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return cfg, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "status.left":
+			cfg.StatusLeft = strings.Fields(value)
+		case key == "status.right":
+			cfg.StatusRight = strings.Fields(value)
+		case key == "scrollbar":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.Scrollbar = b
+		case key == "center_jumps":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.CenterJumps = b
+		case key == "color_moved":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.ColorMoved = b
+		case key == "side_by_side_ops":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.HideSplitOps = !b
+		case key == "inline_line_numbers":
+			switch value {
+			case "single":
+				cfg.BothLineNumbers = false
+			case "both":
+				cfg.BothLineNumbers = true
+			default:
+				return cfg, fmt.Errorf("line %d: %s: want single or both, got %q", lineNo, key, value)
+			}
+		case key == "max_highlight_line" || key == "collapse_lines" || key == "truncate_hunk" || key == "osc52_limit":
+			n, err := parseLimit(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			switch key {
+			case "max_highlight_line":
+				cfg.MaxHighlightLine = n
+			case "collapse_lines":
+				cfg.CollapseLines = n
+			case "osc52_limit":
+				cfg.OSC52Limit = n
+			default:
+				cfg.TruncateHunk = n
+			}
+		case key == "scrolloff" || key == "page_overlap" || key == "wheel_step":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || (n == 0 && key == "wheel_step") {
+				return cfg, fmt.Errorf("line %d: %s: invalid count %q", lineNo, key, value)
+			}
+			switch key {
+			case "scrolloff":
+				cfg.ScrollOff = n
+			case "page_overlap":
+				if n == 0 {
+					n = -1
+				}
+				cfg.PageOverlap = n
+			default:
+				cfg.WheelStep = n
+			}
+		case key == "diff_algorithm":
+			if _, err := parseDiffAlgorithm(value); err != nil {
+				return cfg, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			cfg.DiffAlgorithm = value
+		case key == "generated":
+			cfg.Generated = append(cfg.Generated, strings.Fields(value)...)
+		case key == "collapse_generated":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.NoCollapseGenerated = !b
+		case key == "confirm_apply":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.ConfirmApply = b
+		case key == "alt_screen":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.NoAltScreen = !b
+		case key == "tree_icons":
+			if _, ok := treeIconSets[value]; !ok {
+				return cfg, fmt.Errorf("line %d: %s: want letter, symbol, nerd or none, got %q", lineNo, key, value)
+			}
+			cfg.TreeIcons = value
+		case key == "labels":
+			if _, err := parseLabelPlacement(value); err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.Labels = value
+		case key == "clipboard":
+			if err := parseClipboard(value); err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.Clipboard = value
+		case key == "clipboard_command":
+			if value == "" {
+				return cfg, fmt.Errorf("line %d: %s needs a command", lineNo, key)
+			}
+			cfg.ClipboardCommand = value
+		case key == "search_case":
+			if _, err := parseSearchCase(value); err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.SearchCase = value
+		case key == "search_word":
+			b, err := parseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+			}
+			cfg.SearchWord = b
+		case key == "todo_markers":
+			cfg.TodoMarkers = strings.Fields(value)
+			if b, err := parseBool(value); err == nil && !b {
+				cfg.TodoMarkers = []string{}
+			}
+		case key == "exclude":
+			cfg.Excludes = append(cfg.Excludes, strings.Fields(value)...)
+		case key == "highlighter":
+			cfg.Highlighter = value
+		case key == "difftool":
+			cfg.Difftool = value
+		case key == "editor_server":
+			f := strings.Fields(value)
+			if len(f) == 0 || (f[0] != "nvim" && f[0] != "emacs") || (f[0] == "nvim" && len(f) != 2) || len(f) > 2 {
+				return cfg, fmt.Errorf("line %d: %s: want \"nvim <address>\" or \"emacs [<server>]\", got %q", lineNo, key, value)
+			}
+			cfg.EditorServer = value
+		case key == "editor_pane":
+			if value == "" {
+				return cfg, fmt.Errorf("line %d: %s needs auto, tmux, kitty or a command", lineNo, key)
+			}
+			cfg.EditorPane = value
+		case key == "theme":
+			cfg.Theme = value
+		case strings.HasPrefix(key, "lang."):
+			pattern := strings.TrimPrefix(key, "lang.")
+			if pattern == "" || value == "" {
+				return cfg, fmt.Errorf("line %d: lang needs a pattern and a language", lineNo)
+			}
+			if lexers.Get(value) == nil {
+				return cfg, fmt.Errorf("line %d: unknown language %q", lineNo, value)
+			}
+			cfg.Languages = append(cfg.Languages, LangOverride{Pattern: pattern, Lexer: value})
+		case strings.HasPrefix(key, "command."):
+			r, popup, err := parseCommandKey(strings.TrimPrefix(key, "command."))
+			if err != nil {
+				return cfg, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if value == "" {
+				return cfg, fmt.Errorf("line %d: %s needs a command", lineNo, key)
+			}
+			for _, uc := range cfg.Commands {
+				if uc.Key == r {
+					return cfg, fmt.Errorf("line %d: key %q has two commands", lineNo, string(r))
+				}
+			}
+			cfg.Commands = append(cfg.Commands, UserCommand{Key: r, Command: value, Popup: popup})
+			if labelLettersLeft(cfg.commandKeys()) < minLabelLetters {
+				return cfg, fmt.Errorf("line %d: key %q would leave fewer than %d letters for hunk labels; bind a punctuation key", lineNo, string(r), minLabelLetters)
+			}
+		case key == "commit_message":
+			cfg.CommitMessage = value
+		case key == "diagnostics":
+			cfg.Diagnostics = value
+		case key == "check":
+			cfg.Check = value
+		case key == "assistant":
+			cfg.Assistant = value
+		case strings.HasPrefix(key, "assistant."):
+			name := strings.TrimPrefix(key, "assistant.")
+			if name == "" || value == "" {
+				return cfg, fmt.Errorf("line %d: assistant prompt needs a name and a template", lineNo)
+			}
+			cfg.Prompts = append(cfg.Prompts, Prompt{Name: name, Template: strings.ReplaceAll(value, `\n`, "\n")})
+		case strings.HasPrefix(key, "opener."):
+			name := strings.TrimPrefix(key, "opener.")
+			if name == "" || value == "" {
+				return cfg, fmt.Errorf("line %d: opener needs a name and a command", lineNo)
+			}
+			cfg.Openers = append(cfg.Openers, Opener{Name: name, Command: value})
+		default:
+			return cfg, fmt.Errorf("line %d: unknown key %q", lineNo, key)
+		}
+	}
+	return cfg, sc.Err()
+}
+
+// parseLimit parses a positive size limit, or "off" (returned as -1).
+func parseLimit(v string) (int, error) {
+	if b, err := parseBool(v); err == nil && !b {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid limit %q (want a positive number or off)", v)
+	}
+	return n, nil
+}
+
+// parseBool accepts the usual spellings of a boolean config value.
+func parseBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", v)
+}
+
+// SaveConfigValue sets key to value in the config file, replacing the first
+// existing assignment or appending one. Comments and other lines are kept.
+func SaveConfigValue(key, value string) error {
+	path := configPath()
+	if path == "" {
+		return fmt.Errorf("no config path")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(setConfigLine(string(data), key, value)), 0o644)
+}
+
+// setConfigLine returns content with key's assignment set to value.
+func setConfigLine(content, key, value string) string {
+	assign := key + " = " + value
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if k, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(k) == key {
+			lines[i] = assign
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+	lines = append(lines, assign)
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigEmpty(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if len(cfg.StatusLeft) != 0 || len(cfg.StatusRight) != 0 {
+		t.Errorf("expected zero config, got %+v", cfg)
+	}
+}
+
+func TestParseConfigStatusSegments(t *testing.T) {
+	input := `
+# status bar layout
+status.left  = ref branch diffstat
+status.right = position clock
+`
+	cfg, err := parseConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if got := strings.Join(cfg.StatusLeft, ","); got != "ref,branch,diffstat" {
+		t.Errorf("StatusLeft = %q, want %q", got, "ref,branch,diffstat")
+	}
+	if got := strings.Join(cfg.StatusRight, ","); got != "position,clock" {
+		t.Errorf("StatusRight = %q, want %q", got, "position,clock")
+	}
+}
+
+func TestParseConfigUnknownKey(t *testing.T) {
+	_, err := parseConfig(strings.NewReader("bogus = 1\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected line-numbered error for unknown key, got %v", err)
+	}
+}
+
+func TestParseConfigMissingEquals(t *testing.T) {
+	_, err := parseConfig(strings.NewReader("status.left ref\n"))
+	if err == nil {
+		t.Error("expected error for line without '='")
+	}
+}
+
+func TestParseConfigScrollbar(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("scrollbar = yes\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if !cfg.Scrollbar {
+		t.Error("expected Scrollbar to be true")
+	}
+	if _, err := parseConfig(strings.NewReader("scrollbar = maybe\n")); err == nil {
+		t.Error("expected error for invalid boolean")
+	}
+}
+
+func TestParseConfigOpeners(t *testing.T) {
+	input := "opener.code = code -g {file}:{line}\nopener.idea = idea --line {line} {file}\n"
+	cfg, err := parseConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if len(cfg.Openers) != 2 {
+		t.Fatalf("len(Openers) = %d, want 2", len(cfg.Openers))
+	}
+	if cfg.Openers[0].Name != "code" || cfg.Openers[1].Command != "idea --line {line} {file}" {
+		t.Errorf("unexpected openers %+v", cfg.Openers)
+	}
+	if _, err := parseConfig(strings.NewReader("opener. = vim\n")); err == nil {
+		t.Error("expected error for opener without a name")
+	}
+}
+
+func TestSetConfigLine(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "theme = nord\n"},
+		{"scrollbar = true\n", "scrollbar = true\ntheme = nord\n"},
+		{"# theme = old\ntheme = dracula\nscrollbar = true\n", "# theme = old\ntheme = nord\nscrollbar = true\n"},
+	}
+	for _, tt := range tests {
+		if got := setConfigLine(tt.in, "theme", "nord"); got != tt.want {
+			t.Errorf("setConfigLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSaveConfigValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wiff", "config")
+	t.Setenv("WIFF_CONFIG", path)
+	if err := SaveConfigValue("theme", "nord"); err != nil {
+		t.Fatalf("SaveConfigValue: %v", err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Theme != "nord" {
+		t.Errorf("Theme = %q, want nord", cfg.Theme)
+	}
+}
+
+func TestParseConfigLanguages(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("lang.*.gotmpl = go-template\nlang.Jenkinsfile = groovy\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	want := []LangOverride{{"*.gotmpl", "go-template"}, {"Jenkinsfile", "groovy"}}
+	if len(cfg.Languages) != 2 || cfg.Languages[0] != want[0] || cfg.Languages[1] != want[1] {
+		t.Errorf("Languages = %+v, want %+v", cfg.Languages, want)
+	}
+	if _, err := parseConfig(strings.NewReader("lang.*.x = klingon\n")); err == nil {
+		t.Error("expected error for unknown language")
+	}
+}
+
+func TestParseConfigLimits(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("max_highlight_line = 500\ncollapse_lines = off\ntruncate_hunk = 40\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if cfg.MaxHighlightLine != 500 || cfg.CollapseLines != -1 || cfg.TruncateHunk != 40 {
+		t.Errorf("limits = %d, %d, %d; want 500, -1, 40", cfg.MaxHighlightLine, cfg.CollapseLines, cfg.TruncateHunk)
+	}
+	if _, err := parseConfig(strings.NewReader("collapse_lines = -3\n")); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestParseConfigAltScreen(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("alt_screen = false\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if !cfg.NoAltScreen {
+		t.Error("alt_screen = false should set NoAltScreen")
+	}
+}
+
+func TestParseConfigSearch(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("search_case = match\nsearch_word = yes\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if cfg.SearchCase != "match" || !cfg.SearchWord {
+		t.Errorf("search_case = %q, search_word = %v", cfg.SearchCase, cfg.SearchWord)
+	}
+	if _, err := parseConfig(strings.NewReader("search_case = sometimes\n")); err == nil {
+		t.Error("an unknown search_case should be an error")
+	}
+}
+
+func TestParseConfigScrolling(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("scrolloff = 4\npage_overlap = 0\nwheel_step = 1\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if cfg.ScrollOff != 4 || cfg.pageOverlap() != 0 || cfg.wheelStep() != 1 {
+		t.Errorf("scrolloff, overlap, wheel = %d, %d, %d; want 4, 0, 1", cfg.ScrollOff, cfg.pageOverlap(), cfg.wheelStep())
+	}
+	var defaults Config
+	if defaults.pageOverlap() != 2 || defaults.wheelStep() != 3 {
+		t.Errorf("default overlap, wheel = %d, %d; want 2, 3", defaults.pageOverlap(), defaults.wheelStep())
+	}
+	for _, bad := range []string{"scrolloff = -1\n", "wheel_step = 0\n", "page_overlap = two\n"} {
+		if _, err := parseConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseConfigLineNumberOptions(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("side_by_side_ops = false\ninline_line_numbers = both\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if !cfg.HideSplitOps || !cfg.BothLineNumbers {
+		t.Errorf("got HideSplitOps=%v BothLineNumbers=%v, want both true", cfg.HideSplitOps, cfg.BothLineNumbers)
+	}
+	if _, err := parseConfig(strings.NewReader("inline_line_numbers = old\n")); err == nil {
+		t.Error("expected error for invalid inline_line_numbers")
+	}
+}
+
+func TestParseConfigTreeIcons(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("tree_icons = nerd\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if cfg.TreeIcons != "nerd" {
+		t.Errorf("TreeIcons = %q, want nerd", cfg.TreeIcons)
+	}
+	if _, err := parseConfig(strings.NewReader("tree_icons = emoji\n")); err == nil {
+		t.Error("expected error for unknown icon set")
+	}
+}
+
+func TestParseConfigTodoMarkers(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("todo_markers = TODO HACK\n"))
+	if err != nil || len(cfg.TodoMarkers) != 2 {
+		t.Fatalf("got %v, %v", cfg.TodoMarkers, err)
+	}
+	cfg, _ = parseConfig(strings.NewReader("todo_markers = off\n"))
+	if cfg.TodoMarkers == nil || len(cfg.TodoMarkers) != 0 {
+		t.Errorf("off: got %#v", cfg.TodoMarkers)
+	}
+}
+
+func TestParseConfigCommandsLeaveLabels(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("command.i = ls\ncommand.l = ls\ncommand.( = ls\ncommand.5 = ls\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	reserveKeys(cfg.commandKeys()...)
+	defer reserveKeys()
+	if len(availableLabels) < minLabelLetters || indexToLabel(1000) == "" {
+		t.Errorf("labels left: %q", string(availableLabels))
+	}
+}
+
+func TestParseConfigCommands(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("command.x = make test {file}\ncommand.Z.popup = gh browse {file}:{line}\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	want := []UserCommand{
+		{Key: 'x', Command: "make test {file}"},
+		{Key: 'Z', Command: "gh browse {file}:{line}", Popup: true},
+	}
+	if len(cfg.Commands) != len(want) {
+		t.Fatalf("got %+v", cfg.Commands)
+	}
+	for i := range want {
+		if cfg.Commands[i] != want[i] {
+			t.Errorf("command %d: got %+v, want %+v", i, cfg.Commands[i], want[i])
+		}
+	}
+	tooMany := "command.i = ls\ncommand.l = ls\ncommand.r = ls\ncommand.t = ls\ncommand.v = ls\n"
+	for _, bad := range []string{"command.j = ls\n", "command.xy = ls\n", "command.x = ls\ncommand.x.popup = ls\n", tooMany} {
+		if _, err := parseConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseConfigAssistant(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("assistant = llm\nassistant.tests = Suggest tests:\\n{hunk}\ncommit_message = llm -s commit\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if cfg.Assistant != "llm" || len(cfg.Prompts) != 1 || cfg.Prompts[0].Template != "Suggest tests:\n{hunk}" {
+		t.Errorf("got %q %+v", cfg.Assistant, cfg.Prompts)
+	}
+	if cfg.CommitMessage != "llm -s commit" {
+		t.Errorf("CommitMessage = %q", cfg.CommitMessage)
+	}
+}
+
+func TestParseConfigClipboard(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("clipboard = tool osc52\nclipboard_command = wl-copy -n\nosc52_limit = off\n"))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if cfg.osc52Limit() != 0 {
+		t.Errorf("osc52_limit = off should lift the limit, got %d", cfg.osc52Limit())
+	}
+	if cfg.Clipboard != "tool osc52" || cfg.ClipboardCommand != "wl-copy -n" {
+		t.Errorf("clipboard = %q, clipboard_command = %q", cfg.Clipboard, cfg.ClipboardCommand)
+	}
+	if _, err := parseConfig(strings.NewReader("clipboard = osc52 magic\n")); err == nil {
+		t.Error("an unknown backend should be an error")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Merge states of an unmerged file.
+const (
+	conflictOpen   = "conflict" // still to resolve
+	conflictRerere = "rerere"   // resolved by rerere from a recorded resolution, not yet added
+)
+
+// conflictFiles returns the unmerged files of a merge (or rebase, cherry-pick
+// or revert) in progress that are in hunks, mapped to their state. Without
+// conflicts, or outside git, it is nil.
+func (s *State) conflictFiles(hunks []Hunk) map[string]string {
+	if _, git := repo.(gitVCS); s.NoIndex || s.PipeMode || !git || len(hunks) == 0 {
+		return nil
+	}
+	root, err := repo.Root()
+	if err != nil {
+		return nil
+	}
+	out, err := commandOutput(root, "git", "diff", "--name-only", "--diff-filter=U")
+	if err != nil || out == "" {
+		return nil
+	}
+	unmerged := make(map[string]bool)
+	for _, f := range strings.Split(out, "\n") {
+		unmerged[f] = true
+	}
+	// rerere remaining lists the files rerere left conflicted; the others
+	// it resolved. Without rerere, every file is left.
+	remaining := unmerged
+	if rerereEnabled(root) {
+		remaining = make(map[string]bool)
+		if out, err := commandOutput(root, "git", "rerere", "remaining"); err == nil && out != "" {
+			for _, f := range strings.Split(out, "\n") {
+				remaining[f] = true
+			}
+		}
+	}
+	conflicts := make(map[string]string)
+	for _, h := range hunks {
+		switch {
+		case !unmerged[h.File]:
+		case remaining[h.File]:
+			conflicts[h.File] = conflictOpen
+		default:
+			conflicts[h.File] = conflictRerere
+		}
+	}
+	return conflicts
+}
+
+// rerereEnabled reports whether git rerere records and replays resolutions
+// in the repository at root: rerere.enabled, or by default when its cache
+// directory exists.
+func rerereEnabled(root string) bool {
+	switch gitConfig("rerere.enabled") {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0":
+		return false
+	}
+	dir, err := commandOutput(root, "git", "rev-parse", "--git-path", "rr-cache")
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir()
+}
+
+// isConflictStart reports whether a display row opens a conflict region: an
+// added "<<<<<<<" marker line.
+func (s *State) isConflictStart(line DisplayLine) bool {
+	if line.Continuation || line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+		return false
+	}
+	text := line.Text
+	switch {
+	case line.Style == StyleAdded && text != "":
+		text = text[max(1, s.Hunks[line.HunkIdx].Parents):]
+	case line.Right.Style == StyleAdded:
+		text = line.Right.Text[1:]
+	default:
+		return false
+	}
+	return strings.HasPrefix(text, "<<<<<<<")
+}
+
+// conflictLines returns the indices of the rows in s.Lines that open a
+// conflict region.
+func (s *State) conflictLines() []int {
+	var idx []int
+	for i, line := range s.Lines {
+		if s.isConflictStart(line) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// JumpToConflict moves to the next (dir > 0) or previous unresolved
+// conflict region, wrapping around the ends of the diff.
+func (s *State) JumpToConflict(dir int) {
+	conflicts := s.conflictLines()
+	if len(conflicts) == 0 {
+		s.FlashMsg = "No conflict markers left"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	n := nextIndex(conflicts, s.focusLine(), dir)
+	s.JumpTo(conflicts[n])
+	s.FlashMsg = fmt.Sprintf("Conflict %d/%d", n+1, len(conflicts))
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// conflictSummary describes the merge for the status bar: the conflict
+// regions left and the files rerere resolved.
+func (s *State) conflictSummary() string {
+	if len(s.Conflicts) == 0 {
+		return ""
+	}
+	open, rerere := 0, 0
+	for _, state := range s.Conflicts {
+		if state == conflictRerere {
+			rerere++
+		} else {
+			open++
+		}
+	}
+	var parts []string
+	if open > 0 {
+		parts = append(parts, plural(len(s.conflictLines()), "conflict")+" in "+plural(open, "file"))
+	}
+	if rerere > 0 {
+		parts = append(parts, plural(rerere, "file")+" by rerere")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// plural returns n and noun, with an s unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeConflicts(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	git := func(args ...string) {
+		t.Helper()
+		// merge exits 1 on conflicts
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if _, ok := err.(*exec.ExitError); err != nil && !(ok && args[0] == "merge") {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	write := func(name, text string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("config", "user.name", "t")
+	git("config", "user.email", "t@t")
+	git("config", "rerere.enabled", "true")
+	write("f.txt", "a\nb\nc\n")
+	write("g.txt", "x\n")
+	git("add", ".")
+	git("commit", "-qm", "base")
+	git("checkout", "-qb", "side")
+	write("f.txt", "a\nSIDE\nc\n")
+	write("g.txt", "side\n")
+	git("commit", "-qam", "side")
+	git("checkout", "-q", "main")
+	write("f.txt", "a\nMAIN\nc\n")
+	write("g.txt", "main\n")
+	git("commit", "-qam", "main")
+
+	// Record a resolution of f.txt only, then merge again
+	git("merge", "side")
+	write("f.txt", "a\nBOTH\nc\n")
+	git("rerere")
+	git("merge", "--abort")
+	git("merge", "side")
+
+	s := &State{ContextLines: 3, Width: 80, Height: 24}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Conflicts["f.txt"] != conflictRerere || s.Conflicts["g.txt"] != conflictOpen {
+		t.Fatalf("Conflicts = %v", s.Conflicts)
+	}
+	if got := s.conflictSummary(); got != "1 conflict in 1 file, 1 file by rerere" {
+		t.Errorf("conflictSummary() = %q", got)
+	}
+	s.JumpToConflict(1)
+	if line := s.Lines[s.focusLine()]; s.Hunks[line.HunkIdx].File != "g.txt" || !s.isConflictStart(line) {
+		t.Errorf("]x landed on %+v", line)
+	}
+}
+
+func TestJumpToConflictNone(t *testing.T) {
+	s := &State{}
+	s.JumpToConflict(1)
+	if s.FlashMsg != "No conflict markers left" {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+}
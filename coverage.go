@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Coverage maps the files of a coverage report to their executable lines
+// and whether tests ran them. Paths are as the report gives them: import
+// paths for Go, usually absolute for lcov, under a source root for
+// cobertura.
+type Coverage map[string]map[int]bool
+
+// loadCoverage reads a coverage report, telling the format from its
+// content: a Go coverprofile ("mode:" first), cobertura XML or lcov.
+func loadCoverage(path string) (Coverage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cov Coverage
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("mode:")):
+		cov = parseGoCover(data)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		if cov, err = parseCobertura(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case bytes.HasPrefix(trimmed, []byte("TN:")), bytes.HasPrefix(trimmed, []byte("SF:")):
+		cov = parseLcov(data)
+	default:
+		return nil, fmt.Errorf("%s: not a Go coverprofile, lcov or cobertura report", path)
+	}
+	if len(cov) == 0 {
+		return nil, fmt.Errorf("%s: no covered files", path)
+	}
+	return cov, nil
+}
+
+// parseGoCover reads a go test -coverprofile file. Its blocks span lines;
+// a line counts as covered when every block on it ran, so a line holding
+// the start of an untested branch isn't. Blocks listed more than once (by
+// several test binaries with -coverpkg) add up.
+func parseGoCover(data []byte) Coverage {
+	counts := make(map[string]int) // "file:block" to times run
+	var order []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// file:startLine.startCol,endLine.endCol statements count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		if _, ok := counts[fields[0]]; !ok {
+			order = append(order, fields[0])
+		}
+		counts[fields[0]] += n
+	}
+	cov := make(Coverage)
+	for _, block := range order {
+		i := strings.LastIndex(block, ":")
+		if i < 0 {
+			continue
+		}
+		from, to, ok := strings.Cut(block[i+1:], ",")
+		if !ok {
+			continue
+		}
+		start, err1 := strconv.Atoi(strings.Split(from, ".")[0])
+		end, err2 := strconv.Atoi(strings.Split(to, ".")[0])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		file := block[:i]
+		for l := start; l <= end; l++ {
+			cov.mark(file, l, counts[block] > 0, false)
+		}
+	}
+	return cov
+}
+
+// parseLcov reads an lcov tracefile: SF: starts a file, DA:line,count
+// records a line. A line is covered when any record of it ran.
+func parseLcov(data []byte) Coverage {
+	cov := make(Coverage)
+	file := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = strings.TrimPrefix(line, "SF:")
+		case line == "end_of_record":
+			file = ""
+		case strings.HasPrefix(line, "DA:") && file != "":
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				continue
+			}
+			n, err1 := strconv.Atoi(fields[0])
+			hits, err2 := strconv.ParseFloat(fields[1], 64) // some tools write 1.0
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			cov.mark(file, n, hits > 0, true)
+		}
+	}
+	return cov
+}
+
+// coberturaReport is the part of a cobertura XML report wiff reads.
+type coberturaReport struct {
+	Sources []string `xml:"sources>source"`
+	Classes []struct {
+		Filename string `xml:"filename,attr"`
+		Lines    []struct {
+			Number int     `xml:"number,attr"`
+			Hits   float64 `xml:"hits,attr"`
+		} `xml:"lines>line"`
+	} `xml:"packages>package>classes>class"`
+}
+
+// parseCobertura reads a cobertura XML report. Relative file names are
+// joined to the first source root.
+func parseCobertura(data []byte) (Coverage, error) {
+	var report coberturaReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	if report.Classes == nil {
+		return nil, errors.New("no classes in cobertura report")
+	}
+	cov := make(Coverage)
+	for _, class := range report.Classes {
+		file := class.Filename
+		if !filepath.IsAbs(file) && len(report.Sources) > 0 {
+			file = filepath.Join(strings.TrimSpace(report.Sources[0]), file)
+		}
+		for _, l := range class.Lines {
+			cov.mark(file, l.Number, l.Hits > 0, true)
+		}
+	}
+	return cov, nil
+}
+
+// mark records whether line n of file ran. With anyRun, one run is enough;
+// without, every record of the line must have run.
+func (c Coverage) mark(file string, n int, ran, anyRun bool) {
+	if n <= 0 {
+		return
+	}
+	if c[file] == nil {
+		c[file] = make(map[int]bool)
+	}
+	prev, seen := c[file][n]
+	switch {
+	case !seen:
+		c[file][n] = ran
+	case anyRun:
+		c[file][n] = prev || ran
+	default:
+		c[file][n] = prev && ran
+	}
+}
+
+// lookup returns the lines of the report's entry for file, a path relative
+// to the repository root: the entry of that path, or else the shortest one
+// ending in it (an import path or an absolute path).
+func (c Coverage) lookup(file string) map[int]bool {
+	if lines, ok := c[file]; ok {
+		return lines
+	}
+	best := ""
+	for path := range c {
+		if strings.HasSuffix(filepath.ToSlash(path), "/"+file) && (best == "" || len(path) < len(best)) {
+			best = path
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return c[best]
+}
+
+// coverageFiles returns the report's lines for each file of hunks that it
+// covers, keyed by the diff's paths.
+func (c Coverage) coverageFiles(hunks []Hunk) Coverage {
+	if len(c) == 0 {
+		return nil
+	}
+	files := make(Coverage)
+	for _, h := range hunks {
+		if _, done := files[h.File]; done {
+			continue
+		}
+		files[h.File] = c.lookup(h.File)
+	}
+	return files
+}
+
+// lineCoverage reports whether tests ran the added line on a display row:
+// known is false for rows that aren't the first row of an added line, and
+// for lines the report has no entry for (comments, declarations).
+func (s *State) lineCoverage(line DisplayLine) (covered, known bool) {
+	if len(s.Covered) == 0 || line.Continuation || line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+		return false, false
+	}
+	n := 0
+	switch {
+	case line.Style == StyleAdded && line.Text != "":
+		n = line.NewLineNo
+	case line.Right.Style == StyleAdded:
+		n = line.Right.LineNo
+	}
+	if n <= 0 {
+		return false, false
+	}
+	covered, known = s.Covered[s.Hunks[line.HunkIdx].File][n]
+	return covered, known
+}
+
+// fileCoverage counts the added lines of file the report knows and those of
+// them tests ran.
+func (s *State) fileCoverage(file string) (covered, total int) {
+	lines := s.Covered[file]
+	if len(lines) == 0 {
+		return 0, 0
+	}
+	for _, h := range s.Hunks {
+		if h.File != file {
+			continue
+		}
+		n := h.NewStart
+		for _, l := range h.Lines {
+			if l.Op == '-' {
+				continue
+			}
+			if ran, ok := lines[n]; ok && l.Op == '+' {
+				total++
+				if ran {
+					covered++
+				}
+			}
+			n++
+		}
+	}
+	return covered, total
+}
+
+// coveragePercent formats the share of covered new lines of file, or ""
+// when the report knows none of them.
+func (s *State) coveragePercent(file string) string {
+	covered, total := s.fileCoverage(file)
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", covered*100/total)
+}
+
+// coverageSummary describes the coverage of the new lines of the whole diff
+// for the status bar.
+func (s *State) coverageSummary() string {
+	if len(s.Covered) == 0 {
+		return ""
+	}
+	covered, total := 0, 0
+	for file := range s.Covered {
+		c, t := s.fileCoverage(file)
+		covered, total = covered+c, total+t
+	}
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d new lines covered", covered, total)
+}
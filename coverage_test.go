@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeReport(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCoverage(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		file   string // as in the diff
+		want   map[int]bool
+	}{
+		{"go", `mode: set
+example.com/m/pkg/a.go:3.14,5.2 1 1
+example.com/m/pkg/a.go:5.2,7.3 1 0
+example.com/m/pkg/a.go:9.1,9.20 1 0
+example.com/m/pkg/a.go:9.1,9.20 1 1
+`, "pkg/a.go", map[int]bool{3: true, 4: true, 5: false, 6: false, 7: false, 9: true}},
+		{"lcov", `TN:
+SF:/home/me/m/src/a.js
+DA:1,3
+DA:2,0
+DA:4,1.0
+end_of_record
+SF:/home/me/m/src/b.js
+DA:1,0
+end_of_record
+`, "src/a.js", map[int]bool{1: true, 2: false, 4: true}},
+		{"cobertura", `<?xml version="1.0" ?>
+<coverage line-rate="0.5">
+	<sources><source>/home/me/m</source></sources>
+	<packages><package name="app"><classes>
+		<class name="a" filename="app/a.py"><lines>
+			<line number="1" hits="1"/>
+			<line number="2" hits="0"/>
+		</lines></class>
+	</classes></package></packages>
+</coverage>
+`, "app/a.py", map[int]bool{1: true, 2: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cov, err := loadCoverage(writeReport(t, tt.report))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := cov.lookup(tt.file)
+			if len(got) != len(tt.want) {
+				t.Fatalf("lines %v, want %v", got, tt.want)
+			}
+			for n, ran := range tt.want {
+				if got[n] != ran {
+					t.Errorf("line %d covered %v, want %v", n, got[n], ran)
+				}
+			}
+		})
+	}
+
+	if _, err := loadCoverage(writeReport(t, "not a report\n")); err == nil {
+		t.Error("no error for a file that isn't a report")
+	}
+}
+
+func TestCoverageLookupPrefersShortestPath(t *testing.T) {
+	cov := Coverage{
+		"example.com/m/cmd/tool/main.go": {1: true},
+		"example.com/m/main.go":          {1: false},
+	}
+	if got := cov.lookup("main.go"); got[1] {
+		t.Errorf("main.go matched the nested package: %v", got)
+	}
+	if got := cov.lookup("tool/main.go"); !got[1] {
+		t.Errorf("tool/main.go: %v", got)
+	}
+	if got := cov.lookup("other.go"); got != nil {
+		t.Errorf("other.go matched %v", got)
+	}
+}
+
+func TestCoverageOfAddedLines(t *testing.T) {
+	s := &State{Width: 80, Height: 24, Hunks: []Hunk{{File: "a.go", OldStart: 10, NewStart: 10, Lines: []Line{
+		{Op: ' ', Content: "x := 1"},
+		{Op: '-', Content: "y := 1"},
+		{Op: '+', Content: "y := 2"},
+		{Op: '+', Content: "// why"},
+		{Op: '+', Content: "z := 3"},
+	}}}}
+	s.Coverage = Coverage{"example.com/m/a.go": {10: true, 11: true, 13: false}}
+	s.Covered = s.Coverage.coverageFiles(s.Hunks)
+	s.BuildLines()
+
+	if got := s.coveragePercent("a.go"); got != "50%" {
+		t.Errorf("percent %q, want 50%%", got)
+	}
+	if got := s.coverageSummary(); got != "1/2 new lines covered" {
+		t.Errorf("summary %q", got)
+	}
+	marks := map[int][2]bool{} // new line to covered, known
+	for _, line := range s.Lines {
+		if covered, known := s.lineCoverage(line); known {
+			marks[line.NewLineNo] = [2]bool{covered, known}
+		}
+	}
+	// Line 10 is context: only added lines are marked
+	want := map[int][2]bool{11: {true, true}, 13: {false, true}}
+	if len(marks) != len(want) || marks[11] != want[11] || marks[13] != want[13] {
+		t.Errorf("marked %v, want %v", marks, want)
+	}
+}
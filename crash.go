@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// exitCrash is the exit status after a panic, as for an uncaught one.
+const exitCrash = 2
+
+// lastAction describes the last event the main loop handled, for crash
+// reports.
+var lastAction string
+
+// describeEvent names ev for lastAction: the key pressed, or the event's
+// kind.
+func describeEvent(ev tcell.Event) string {
+	switch ev := ev.(type) {
+	case *tcell.EventKey:
+		return "key " + ev.Name()
+	case *tcell.EventMouse:
+		x, y := ev.Position()
+		return fmt.Sprintf("mouse %d at %d,%d", ev.Buttons(), x, y)
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", ev), "*")
+}
+
+// recoverCrash handles a panic r in the main loop: it puts the terminal
+// back, writes a report with the stack and a summary of s to a temporary
+// file, and exits, telling the user where the report is. It does nothing
+// when r is nil.
+func recoverCrash(r any, s *State) {
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	if ttyScreen != nil {
+		ttyScreen.Fini()
+	}
+	fmt.Fprintf(os.Stderr, "wiff crashed: %v\n", r)
+	if path, err := writeCrashReport(crashReport(r, s, stack)); err == nil {
+		fmt.Fprintf(os.Stderr, "The report is in %s; please attach it to an issue.\n", path)
+	} else {
+		fmt.Fprintf(os.Stderr, "Writing the report failed (%v):\n%s", err, stack)
+	}
+	os.Exit(exitCrash)
+}
+
+// crashReport formats the report of panic r: what wiff was showing, what it
+// was doing and where it failed.
+func crashReport(r any, s *State, stack []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "wiff %s crashed: %v\n\n", version, r)
+	fmt.Fprintf(&b, "time:        %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "go:          %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "args:        %q\n", os.Args[1:])
+	fmt.Fprintf(&b, "last action: %s\n", lastAction)
+	if s != nil {
+		fmt.Fprintf(&b, "diff:        %s\n", s.RefDisplay())
+		fmt.Fprintf(&b, "hunks:       %d in %s\n", len(s.Hunks), plural(s.UniqueFiles(), "file"))
+		fmt.Fprintf(&b, "view:        %s, %dx%d, %d lines, scroll %d\n", s.modeSummary(), s.Width, s.Height, len(s.Lines), s.Scroll)
+	}
+	fmt.Fprintf(&b, "\n%s", stack)
+	return b.String()
+}
+
+// modeSummary lists the view modes of s that change how lines are laid out.
+func (s *State) modeSummary() string {
+	modes := []string{"inline"}
+	if s.SideBySide {
+		modes[0] = "side-by-side"
+	}
+	for _, m := range []struct {
+		on   bool
+		name string
+	}{
+		{s.PipeMode, "pipe"},
+		{s.FullFile, "full file"},
+		{s.Wrap, "wrap"},
+		{s.CursorMode, "cursor"},
+		{s.TreeOpen, "tree"},
+		{s.Split != nil, "split"},
+		{s.Tabs != nil, "tabs"},
+		{s.Review, "review"},
+	} {
+		if m.on {
+			modes = append(modes, m.name)
+		}
+	}
+	return strings.Join(modes, ", ")
+}
+
+// writeCrashReport writes report to a new temporary file and returns its
+// path.
+func writeCrashReport(report string) (string, error) {
+	f, err := os.CreateTemp("", "wiff-crash-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(report); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestCrashReport(t *testing.T) {
+	s := &State{Width: 80, Height: 24, SideBySide: true, Wrap: true, Refs: []string{"main"}}
+	s.Hunks = []Hunk{{File: "a.go"}, {File: "a.go"}, {File: "b.go"}}
+	lastAction = describeEvent(tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone))
+
+	report := crashReport("index out of range", s, []byte("goroutine 1 [running]:\n"))
+	for _, want := range []string{
+		"crashed: index out of range",
+		"last action: key Rune[j]",
+		"hunks:       3 in 2 files",
+		"view:        side-by-side, wrap, 80x24",
+		"goroutine 1 [running]:",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+
+	t.Setenv("TMPDIR", t.TempDir())
+	path, err := writeCrashReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != report {
+		t.Errorf("report file %s: %v", path, err)
+	}
+}
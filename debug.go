@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+// watchEvents and watchReloads count file watcher events and the reloads
+// they led to, for the debug overlay.
+var watchEvents, watchReloads atomic.Int64
+
+// debugStats are the per-view numbers shown by the --debug overlay.
+type debugStats struct {
+	frame     time.Duration // time of the last Render
+	builds    int           // BuildLines calls
+	lines     int           // display lines of the last build
+	buildTime time.Duration // time of the last BuildLines
+	rows      int           // diff rows in the last frame
+	rowsDrawn int           // of those, rows not copied from the frame before
+}
+
+// startProfiles starts a CPU profile into cpuPath when set. The returned
+// stop ends it and writes a heap profile to memPath when set.
+func startProfiles(cpuPath, memPath string) (stop func(), err error) {
+	var cpu *os.File
+	if cpuPath != "" {
+		if cpu, err = os.Create(cpuPath); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+		}
+		if memPath == "" {
+			return
+		}
+		f, err := os.Create(memPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --memprofile: %v\n", err)
+			return
+		}
+		defer f.Close()
+		runtime.GC() // up-to-date allocation statistics
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --memprofile: %v\n", err)
+		}
+	}, nil
+}
+
+// debugLines returns the rows of the debug overlay for s.
+func debugLines(s *State) []string {
+	hits, misses := s.HL.CacheStats()
+	latency, canceled := reloads.stats()
+	rate := "-"
+	if hits+misses > 0 {
+		rate = fmt.Sprintf("%.0f%%", 100*float64(hits)/float64(hits+misses))
+	}
+	return []string{
+		fmt.Sprintf("render   %v", s.stats.frame.Round(time.Microsecond)),
+		fmt.Sprintf("build    %v (#%d)", s.stats.buildTime.Round(time.Microsecond), s.stats.builds),
+		fmt.Sprintf("lines    %d", s.stats.lines),
+		fmt.Sprintf("rows     %d/%d drawn", s.stats.rowsDrawn, s.stats.rows),
+		fmt.Sprintf("hl cache %s (%d/%d)", rate, hits, hits+misses),
+		fmt.Sprintf("watch    %d events, %d reloads", watchEvents.Load(), watchReloads.Load()),
+		fmt.Sprintf("reload   %v (%d canceled)", latency.Round(time.Millisecond), canceled),
+	}
+}
+
+// drawDebugOverlay draws the debug numbers in the top right corner.
+func drawDebugOverlay(s *State) {
+	lines := debugLines(s)
+	w := 0
+	for _, l := range lines {
+		w = max(w, len(l))
+	}
+	x0 := max(s.Width-w-2, 0)
+	style := s.Theme.Dim.Reverse(true)
+	for row, l := range lines {
+		if row >= s.Height-1 {
+			break
+		}
+		drawText(s.Screen, x0, row, " "+l+" ", style, s.Width)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartProfilesWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	cpu, mem := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out")
+	stop, err := startProfiles(cpu, mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	for _, path := range []string{cpu, mem} {
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			t.Errorf("%s: want a non-empty profile (err %v)", path, err)
+		}
+	}
+}
+
+func TestDebugLinesCountBuildsAndLexerCache(t *testing.T) {
+	s := &State{HL: NewHighlighter(), Width: 80, Height: 24, Hunks: []Hunk{
+		{File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{{Op: '+', Content: "x"}}},
+	}}
+	s.BuildLines()
+	s.HL.Highlight("a.go", "package main")
+	s.HL.Highlight("b.go", "package main")
+	got := strings.Join(debugLines(s), "\n")
+	for _, want := range []string{"lines    ", "hl cache 50% (1/2)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("debug lines missing %q:\n%s", want, got)
+		}
+	}
+	if s.stats.builds == 0 || s.stats.lines != len(s.Lines) {
+		t.Errorf("stats = %+v, want a build of %d lines", s.stats, len(s.Lines))
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Diagnostic is one issue a linter or compiler reported on a line.
+type Diagnostic struct {
+	File     string // relative to the repository root once resolved
+	Line     int
+	Col      int    // 0 when not given
+	Severity string // "error", "warning" or "note"
+	Source   string // linter name, when the tool gives one
+	Message  string
+}
+
+// String formats d as tools print it, without the path.
+func (d Diagnostic) String() string {
+	msg := d.Message
+	if d.Source != "" {
+		msg = d.Source + ": " + msg
+	}
+	if d.Severity == "error" {
+		msg = "error: " + msg
+	}
+	return msg
+}
+
+// LineDiagnostics maps files to their lines with diagnostics.
+type LineDiagnostics map[string]map[int][]Diagnostic
+
+// diagLineRe matches the "file:line:col: message" lines of compilers and
+// most linters; the column is optional.
+var diagLineRe = regexp.MustCompile(`^([^\s:][^:]*):(\d+):(?:(\d+):)?\s*(.+)$`)
+
+// parseDiagnostics reads golangci-lint's JSON output, or else text with one
+// "file:line:col: message" per line. Other lines are skipped.
+func parseDiagnostics(data []byte) ([]Diagnostic, error) {
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		return parseGolangciJSON(trimmed)
+	}
+	var diags []Diagnostic
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		m := diagLineRe.FindStringSubmatch(strings.TrimRight(sc.Text(), "\r"))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		severity, msg := "warning", m[4]
+		for _, sev := range []string{"error", "warning", "note"} {
+			if rest, ok := strings.CutPrefix(msg, sev+":"); ok {
+				severity, msg = sev, strings.TrimSpace(rest)
+				break
+			}
+		}
+		diags = append(diags, Diagnostic{File: m[1], Line: n, Col: col, Severity: severity, Message: msg})
+	}
+	return diags, nil
+}
+
+// golangciReport is the part of golangci-lint's JSON output wiff reads.
+type golangciReport struct {
+	Issues []struct {
+		FromLinter string
+		Text       string
+		Severity   string
+		Pos        struct {
+			Filename string
+			Line     int
+			Column   int
+		}
+	}
+}
+
+// parseGolangciJSON reads golangci-lint's JSON output.
+func parseGolangciJSON(data []byte) ([]Diagnostic, error) {
+	// Later versions print a text summary after the JSON object
+	var report golangciReport
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&report); err != nil {
+		return nil, fmt.Errorf("golangci-lint JSON: %w", err)
+	}
+	diags := make([]Diagnostic, 0, len(report.Issues))
+	for _, is := range report.Issues {
+		severity := strings.ToLower(is.Severity)
+		if severity == "" {
+			severity = "warning"
+		}
+		diags = append(diags, Diagnostic{
+			File:     is.Pos.Filename,
+			Line:     is.Pos.Line,
+			Col:      is.Pos.Column,
+			Severity: severity,
+			Source:   is.FromLinter,
+			Message:  is.Text,
+		})
+	}
+	return diags, nil
+}
+
+// readDiagnostics reads the diagnostics in file, with paths relative to the
+// current directory.
+func readDiagnostics(file string) ([]Diagnostic, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	diags, err := parseDiagnostics(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	wd, _ := os.Getwd()
+	return resolveDiagnostics(diags, wd), nil
+}
+
+// resolveDiagnostics makes the paths of diags, relative to dir, relative to
+// the repository root like the diff's.
+func resolveDiagnostics(diags []Diagnostic, dir string) []Diagnostic {
+	root, err := repo.Root()
+	if err != nil {
+		return diags
+	}
+	for i, d := range diags {
+		path := d.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			diags[i].File = filepath.ToSlash(rel)
+		}
+	}
+	return diags
+}
+
+// runDiagnostics runs the diagnostics command of the config in the
+// repository root and keeps what it reports. Linters exit non-zero when
+// they find issues, so the exit status only matters without any.
+func runDiagnostics(s *State) {
+	if s.Config.Diagnostics == "" || s.PipeMode || s.NoIndex {
+		return
+	}
+	out, runErr := pipeThrough(s.Config.Diagnostics, "")
+	diags, err := parseDiagnostics([]byte(out))
+	if err == nil && len(diags) == 0 && runErr != nil {
+		err = runErr
+	}
+	if err != nil {
+		s.FlashMsg = "Diagnostics: " + err.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	root, _ := repo.Root()
+	s.Diagnostics = resolveDiagnostics(diags, root)
+}
+
+// diagnosticFiles groups diags by file and line, for the files of hunks.
+func diagnosticFiles(diags []Diagnostic, hunks []Hunk) LineDiagnostics {
+	if len(diags) == 0 {
+		return nil
+	}
+	files := make(map[string]bool)
+	for _, h := range hunks {
+		files[h.File] = true
+	}
+	byLine := make(LineDiagnostics)
+	for _, d := range diags {
+		if !files[d.File] {
+			continue
+		}
+		if byLine[d.File] == nil {
+			byLine[d.File] = make(map[int][]Diagnostic)
+		}
+		byLine[d.File][d.Line] = append(byLine[d.File][d.Line], d)
+	}
+	return byLine
+}
+
+// lineDiagnostics returns the diagnostics on the new-side line of a display
+// row, for the first row of a line.
+func (s *State) lineDiagnostics(line DisplayLine) []Diagnostic {
+	if len(s.DiagLines) == 0 || line.Continuation || line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+		return nil
+	}
+	n := line.NewLineNo
+	if s.SideBySide {
+		n = line.Right.LineNo
+	}
+	if n <= 0 {
+		return nil
+	}
+	return s.DiagLines[s.Hunks[line.HunkIdx].File][n]
+}
+
+// isAddedLine reports whether a display row shows an added line.
+func (s *State) isAddedLine(line DisplayLine) bool {
+	if s.SideBySide {
+		return line.Right.Style == StyleAdded
+	}
+	return line.Style == StyleAdded
+}
+
+// diagnosticLines returns the indices of the rows in s.Lines with
+// diagnostics.
+func (s *State) diagnosticLines() []int {
+	var idx []int
+	for i, line := range s.Lines {
+		if len(s.lineDiagnostics(line)) > 0 {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// diagnosticSummary counts the diagnostics on added lines for the status
+// bar: the issues the change brings in.
+func (s *State) diagnosticSummary() string {
+	n := 0
+	for _, i := range s.diagnosticLines() {
+		if s.isAddedLine(s.Lines[i]) {
+			n += len(s.lineDiagnostics(s.Lines[i]))
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	return plural(n, "issue") + " on new lines"
+}
+
+// JumpToDiagnostic moves to the next (dir > 0) or previous line with
+// diagnostics, wrapping around, and shows the first of them.
+func (s *State) JumpToDiagnostic(dir int) {
+	lines := s.diagnosticLines()
+	if len(lines) == 0 {
+		s.FlashMsg = "No diagnostics on lines of this diff"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	target := lines[nextIndex(lines, s.focusLine(), dir)]
+	s.JumpTo(target)
+	diags := s.lineDiagnostics(s.Lines[target])
+	s.FlashMsg = "! " + diags[0].String()
+	if len(diags) > 1 {
+		s.FlashMsg += fmt.Sprintf(" (+%d more)", len(diags)-1)
+	}
+	s.FlashExpiry = time.Now().Add(4 * time.Second)
+}
+
+// openDiagnosticsPanel lists the diagnostics on lines of the diff, those on
+// added lines marked +; selecting one jumps to its line.
+func openDiagnosticsPanel(s *State) {
+	type entry struct {
+		row  int
+		text string
+	}
+	var entries []entry
+	for _, i := range s.diagnosticLines() {
+		mark := " "
+		if s.isAddedLine(s.Lines[i]) {
+			mark = "+"
+		}
+		for _, d := range s.lineDiagnostics(s.Lines[i]) {
+			entries = append(entries, entry{i, fmt.Sprintf("%s %s:%d  %s", mark, d.File, d.Line, d)})
+		}
+	}
+	if len(entries) == 0 {
+		s.FlashMsg = "No diagnostics on lines of this diff"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	items := make([]string, len(entries))
+	for i, e := range entries {
+		items[i] = e.text
+	}
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("Diagnostics (%d)", len(entries)),
+		Items: items,
+		OnSelect: func(s *State, i int) {
+			s.JumpTo(entries[i].row)
+		},
+	})
+}
+
+// diagnosticStyle is the style of the gutter mark of a line with diags:
+// red when any is an error.
+func diagnosticStyle(s *State, diags []Diagnostic) tcell.Style {
+	for _, d := range diags {
+		if d.Severity == "error" {
+			return s.Theme.Dim.Foreground(s.Theme.Removed).Bold(true)
+		}
+	}
+	return s.Theme.Dim.Foreground(s.Theme.Highlight).Bold(true)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	text := `# example.com/m
+./a.go:12:3: error: undefined: x
+pkg/b.go:4: warning: unused variable
+vet: checking done
+`
+	diags, err := parseDiagnostics([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Diagnostic{
+		{File: "./a.go", Line: 12, Col: 3, Severity: "error", Message: "undefined: x"},
+		{File: "pkg/b.go", Line: 4, Severity: "warning", Message: "unused variable"},
+	}
+	if len(diags) != len(want) || diags[0] != want[0] || diags[1] != want[1] {
+		t.Errorf("got %+v, want %+v", diags, want)
+	}
+
+	golangci := `{"Issues":[{"FromLinter":"errcheck","Text":"Error return value is not checked","Severity":"","Pos":{"Filename":"a.go","Line":7,"Column":2}}],"Report":{}}
+0 issues.`
+	diags, err = parseDiagnostics([]byte(golangci))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Source != "errcheck" || diags[0].Line != 7 || diags[0].Severity != "warning" {
+		t.Errorf("golangci-lint: %+v", diags)
+	}
+	if got := diags[0].String(); got != "errcheck: Error return value is not checked" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestResolveDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	root, err := repo.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := resolveDiagnostics([]Diagnostic{
+		{File: "./a.go"},
+		{File: filepath.Join(root, "pkg", "b.go")},
+		{File: "/elsewhere/c.go"},
+	}, filepath.Join(root, "sub"))
+	for i, want := range []string{"sub/a.go", "pkg/b.go", "/elsewhere/c.go"} {
+		if diags[i].File != want {
+			t.Errorf("diagnostic %d in %q, want %q", i, diags[i].File, want)
+		}
+	}
+}
+
+func TestDiagnosticMarks(t *testing.T) {
+	s := &State{Width: 80, Height: 24, Hunks: []Hunk{{File: "a.go", OldStart: 10, NewStart: 10, Lines: []Line{
+		{Op: ' ', Content: "x := 1"},
+		{Op: '+', Content: "y := 2"},
+	}}}}
+	s.Diagnostics = []Diagnostic{
+		{File: "a.go", Line: 10, Severity: "warning", Message: "x is unused"},
+		{File: "a.go", Line: 11, Severity: "error", Message: "y redeclared"},
+		{File: "other.go", Line: 1, Message: "not in the diff"},
+	}
+	s.DiagLines = diagnosticFiles(s.Diagnostics, s.Hunks)
+	s.BuildLines()
+
+	if got := s.diagnosticSummary(); got != "1 issue on new lines" {
+		t.Errorf("summary %q", got)
+	}
+	if got := len(s.diagnosticLines()); got != 2 {
+		t.Errorf("%d lines with diagnostics, want 2", got)
+	}
+	s.CursorMode, s.Cursor = true, 0
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, ']', tcell.ModNone))
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModNone))
+	if s.FlashMsg != "! x is unused" {
+		t.Errorf("]d flash = %q", s.FlashMsg)
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, 'g', tcell.ModNone))
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModNone))
+	if s.Popup == nil || len(s.Popup.Items) != 2 || s.Popup.Items[1] != "+ a.go:11  error: y redeclared" {
+		t.Errorf("gd popup: %+v", s.Popup)
+	}
+}
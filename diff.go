@@ -1,96 +1,16 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
 	"os/exec"
-	"strings"
 
-	"github.com/bluekeyes/go-gitdiff/gitdiff"
+	"github.com/h0rv/wiff/pkg/wiff"
 )
 
-// Hunk represents a diff hunk with a label for quick reference
-type Hunk struct {
-	Label     string
-	File      string
-	Header    string // raw @@ header for AsPatch
-	Comment   string // function/context from header (clean display)
-	OldStart  int    // starting line number in old file
-	NewStart  int    // starting line number in new file
-	Lines     []Line
-	StartLine int
-	Staged    bool // true if this hunk has been staged via git apply --cached
-}
-
-// Line represents a single line in a diff hunk
-type Line struct {
-	Op      rune // '+', '-', ' '
-	Content string
-}
-
-// AddedLines returns all added lines joined by newlines
-func (h *Hunk) AddedLines() string {
-	return h.filterLines('+')
-}
-
-// RemovedLines returns all removed lines joined by newlines
-func (h *Hunk) RemovedLines() string {
-	return h.filterLines('-')
-}
-
-func (h *Hunk) filterLines(op rune) string {
-	var lines []string
-	for _, l := range h.Lines {
-		if l.Op == op {
-			lines = append(lines, l.Content)
-		}
-	}
-	return strings.Join(lines, "\n")
-}
-
-// ResultLines returns the new/result version of the code section:
-// context lines plus added lines, without removed lines.
-func (h *Hunk) ResultLines() string {
-	var lines []string
-	for _, l := range h.Lines {
-		if l.Op != '-' {
-			lines = append(lines, l.Content)
-		}
-	}
-	return strings.Join(lines, "\n")
-}
-
-// AsPatch formats the hunk as a unified diff patch
-func (h *Hunk) AsPatch() string {
-	var sb strings.Builder
-	sb.WriteString(h.Header)
-	sb.WriteByte('\n')
-	for _, l := range h.Lines {
-		sb.WriteRune(l.Op)
-		sb.WriteString(l.Content)
-		sb.WriteByte('\n')
-	}
-	return sb.String()
-}
-
-// AsFullPatch formats the hunk as a full patch suitable for git apply,
-// including the file header lines that git apply requires.
-func (h *Hunk) AsFullPatch() string {
-	var sb strings.Builder
-	sb.WriteString("diff --git a/")
-	sb.WriteString(h.File)
-	sb.WriteString(" b/")
-	sb.WriteString(h.File)
-	sb.WriteByte('\n')
-	sb.WriteString("--- a/")
-	sb.WriteString(h.File)
-	sb.WriteByte('\n')
-	sb.WriteString("+++ b/")
-	sb.WriteString(h.File)
-	sb.WriteByte('\n')
-	sb.WriteString(h.AsPatch())
-	return sb.String()
-}
+// The diff model lives in pkg/wiff; the viewer uses it under its own names.
+type (
+	Hunk = wiff.Hunk
+	Line = wiff.Line
+)
 
 // RunDiff executes git diff and updates state
 func RunDiff(s *State) error {
@@ -99,6 +19,9 @@ func RunDiff(s *State) error {
 		args = append(args, "--staged")
 	}
 	args = append(args, s.Refs...)
+	if len(s.Paths) > 0 {
+		args = append(append(args, "--"), s.Paths...)
+	}
 
 	cmd := exec.Command("git", args...)
 	out, err := cmd.Output()
@@ -118,72 +41,21 @@ func RunDiff(s *State) error {
 	return nil
 }
 
+// parseDiff parses a diff into labeled hunks with moved lines marked.
 func parseDiff(data []byte) ([]Hunk, error) {
-	files, _, err := gitdiff.Parse(bytes.NewReader(data))
+	hunks, err := wiff.Parse(data)
 	if err != nil {
 		return nil, err
 	}
-
-	var hunks []Hunk
-	for _, file := range files {
-		filename := file.NewName
-		if filename == "" || filename == "/dev/null" {
-			filename = file.OldName
-		}
-		for _, frag := range file.TextFragments {
-			hunks = append(hunks, Hunk{
-				Label:    indexToLabel(len(hunks)),
-				File:     filename,
-				Header:   formatHeader(frag),
-				Comment:  strings.TrimSpace(frag.Comment),
-				OldStart: int(frag.OldPosition),
-				NewStart: int(frag.NewPosition),
-				Lines:    parseLines(frag),
-			})
-		}
+	for i := range hunks {
+		hunks[i].Label = indexToLabel(i)
 	}
+	markMovedLines(hunks)
 	return hunks, nil
 }
 
 // reservedKeys and availableLabels are defined in keys.go
 
 func indexToLabel(idx int) string {
-	n := len(availableLabels)
-	if idx < n {
-		return string(availableLabels[idx])
-	}
-	// Fallback: two-char labels for very large diffs
-	over := idx - n
-	first := over / n
-	second := over % n
-	if first >= n {
-		first = n - 1
-	}
-	return string(availableLabels[first]) + string(availableLabels[second])
-}
-
-func formatHeader(frag *gitdiff.TextFragment) string {
-	comment := ""
-	if frag.Comment != "" {
-		comment = " " + frag.Comment
-	}
-	return fmt.Sprintf("@@ -%d,%d +%d,%d @@%s",
-		frag.OldPosition, frag.OldLines,
-		frag.NewPosition, frag.NewLines,
-		comment)
-}
-
-func parseLines(frag *gitdiff.TextFragment) []Line {
-	lines := make([]Line, 0, len(frag.Lines))
-	for _, l := range frag.Lines {
-		op := ' '
-		switch l.Op {
-		case gitdiff.OpAdd:
-			op = '+'
-		case gitdiff.OpDelete:
-			op = '-'
-		}
-		lines = append(lines, Line{Op: op, Content: strings.TrimRight(l.Line, "\n")})
-	}
-	return lines
+	return wiff.Label(idx, availableLabels)
 }
@@ -96,6 +96,16 @@ func TestParseDiffFileNames(t *testing.T) {
 	}
 }
 
+func TestParseDiffStatus(t *testing.T) {
+	hunks := helperParseFakeDiff(t)
+	want := []byte{'M', 'M', 'A', 'D'}
+	for i, w := range want {
+		if hunks[i].Status != w {
+			t.Errorf("hunk %d: status = %c, want %c", i, hunks[i].Status, w)
+		}
+	}
+}
+
 func TestParseDiffLabels(t *testing.T) {
 	hunks := helperParseFakeDiff(t)
 	// Labels should be sequential from the availableLabels list.
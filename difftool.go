@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// openDifftool compares the current file in an external tool. With a
+// difftool command configured, the old and new versions are written to temp
+// files and substituted for {old} and {new}; otherwise git difftool is run
+// with the same refs as the diff.
+func openDifftool(s *State) {
+	file := s.CurrentFile()
+	if file == "" {
+		return
+	}
+
+	if s.Config.Difftool == "" {
+		if s.PipeMode {
+			s.FlashMsg = "Set difftool in the config to compare piped diffs"
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+			return
+		}
+		args := []string{"git", "difftool", "--no-prompt"}
+		switch {
+		case s.NoIndex:
+			args = append(args, "--no-index", "--", s.Refs[0], s.Refs[1])
+		default:
+			if s.Staged {
+				args = append(args, "--staged")
+			}
+			args = append(args, s.Refs...)
+			args = append(args, "--", file)
+		}
+		if err := runSuspended(s, args); err != nil {
+			s.FlashMsg = fmt.Sprintf("difftool error: %v", err)
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+		}
+		return
+	}
+
+	oldText, newText := s.fileSides(file)
+	dir, err := os.MkdirTemp("", "wiff-difftool-")
+	if err != nil {
+		s.FlashMsg = fmt.Sprintf("difftool error: %v", err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// Keep the base name so tools can pick a syntax from the extension
+	base := filepath.Base(file)
+	oldPath := filepath.Join(dir, "old", base)
+	newPath := filepath.Join(dir, "new", base)
+	for path, text := range map[string]string{oldPath: oldText, newPath: newText} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = os.WriteFile(path, []byte(text), 0o644)
+		}
+		if err != nil {
+			s.FlashMsg = fmt.Sprintf("difftool error: %v", err)
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+			return
+		}
+	}
+
+	args := expandCommand(s.Config.Difftool, map[string]string{
+		"old":  oldPath,
+		"new":  newPath,
+		"file": file,
+	})
+	if err := runSuspended(s, args); err != nil {
+		s.FlashMsg = fmt.Sprintf("difftool error: %v", err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+	}
+}
+
+// fileSides returns the old and new content of file. Outside pipe mode the
+// full revisions are read from git; piped diffs only have the hunk bodies.
+func (s *State) fileSides(file string) (oldText, newText string) {
+	if !s.PipeMode {
+		oldRev, newRev := s.sideRevs()
+		oldOut, _ := fileAt(oldRev, file)
+		newOut, _ := fileAt(newRev, file)
+		if oldOut != nil || newOut != nil {
+			return string(oldOut), string(newOut)
+		}
+	}
+	var oldB, newB strings.Builder
+	for _, h := range s.Hunks {
+		if h.File != file {
+			continue
+		}
+		for _, l := range h.Lines {
+			if l.Op != '+' {
+				oldB.WriteString(l.Content + "\n")
+			}
+			if l.Op != '-' {
+				newB.WriteString(l.Content + "\n")
+			}
+		}
+	}
+	return oldB.String(), newB.String()
+}
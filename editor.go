@@ -5,26 +5,82 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// openInEditor suspends the TUI and opens the given file in the user's
-// preferred editor ($EDITOR, $VISUAL, or "vi" as fallback). The optional
-// lineNo places the cursor at that line (works with vim, nvim, nano, emacs, etc.).
-// When the editor exits the TUI is resumed.
-func openInEditor(s *State, file string, lineNo int) {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = os.Getenv("VISUAL")
+// Opener is a named command template for opening a file at a line, e.g.
+// "code -g {file}:{line}". Configured with opener.<name> = <command>.
+type Opener struct {
+	Name    string
+	Command string
+}
+
+// openFile opens file at lineNo using the configured openers: the running
+// editor of editor_server (or $NVIM) or else $EDITOR when none are
+// configured, the only one when there is one, or a picker when there are
+// several. The diff is reloaded afterwards.
+func openFile(s *State, file string, lineNo int) {
+	openers := s.Config.Openers
+	switch len(openers) {
+	case 0:
+		if openInRemoteEditor(s, file, lineNo) {
+			return // the watcher reloads once the editor saves
+		}
+		openInEditor(s, file, lineNo)
+	case 1:
+		runOpener(s, openers[0], file, lineNo)
+	default:
+		items := make([]string, len(openers))
+		for i, o := range openers {
+			items[i] = fmt.Sprintf("%d  %-10s %s", i+1, o.Name, o.Command)
+		}
+		OpenPopup(s, &Popup{
+			Title: "Open " + file + " with",
+			Items: items,
+			OnSelect: func(s *State, idx int) {
+				runOpener(s, openers[idx], file, lineNo)
+			},
+		})
 	}
-	if editor == "" {
-		editor = "vi"
+}
+
+// reloadAfterOpen refreshes the diff once an opener returns, since the
+// file may have been edited.
+func reloadAfterOpen(s *State) {
+	if !s.PipeMode {
+		reloadDiff(s)
 	}
+}
 
-	// Resolve file relative to git repo root
+// runOpener expands an opener template for file/line and runs it with the
+// TUI suspended, or in another pane with editor_pane.
+func runOpener(s *State, o Opener, file string, lineNo int) {
+	path, ok := resolveFile(s, file)
+	if !ok {
+		return
+	}
+	if lineNo < 1 {
+		lineNo = 1
+	}
+	args := expandCommand(o.Command, map[string]string{
+		"file": path,
+		"line": strconv.Itoa(lineNo),
+	})
+	if err := runEditor(s, args, path, lineNo); err != nil {
+		s.FlashMsg = fmt.Sprintf("%s error: %v", o.Name, err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+	}
+}
+
+// resolveFile resolves a diff path relative to the repo root and checks that
+// it exists, flashing an error when it does not.
+func resolveFile(s *State, file string) (string, bool) {
 	path := file
-	if !filepath.IsAbs(path) {
-		if root, err := gitRoot(); err == nil {
+	// No-index diffs name files as given on the command line
+	if !filepath.IsAbs(path) && !s.NoIndex {
+		if root, err := repo.Root(); err == nil {
 			path = filepath.Join(root, file)
 		}
 	}
@@ -32,45 +88,99 @@ func openInEditor(s *State, file string, lineNo int) {
 	if _, err := os.Stat(path); err != nil {
 		s.FlashMsg = fmt.Sprintf("File not found: %s", file)
 		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return "", false
+	}
+	return path, true
+}
+
+// openInEditor suspends the TUI and opens the given file in the user's
+// preferred editor ($EDITOR, $VISUAL, or "vi" as fallback). The optional
+// lineNo places the cursor at that line (works with vim, nvim, nano, emacs, etc.).
+// When the editor exits the TUI is resumed; with editor_pane it opens in
+// another pane instead.
+func openInEditor(s *State, file string, lineNo int) {
+	editor := editorCommand()
+	path, ok := resolveFile(s, file)
+	if !ok {
 		return
 	}
 
 	// Build args: editor +line file
-	args := []string{}
+	args := []string{editor}
 	if lineNo > 0 {
 		args = append(args, fmt.Sprintf("+%d", lineNo))
 	}
 	args = append(args, path)
 
-	s.Screen.Fini()
-
-	cmd := exec.Command(editor, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if err := runEditor(s, args, path, lineNo); err != nil {
 		s.FlashMsg = fmt.Sprintf("Editor error: %v", err)
 		s.FlashExpiry = time.Now().Add(3 * time.Second)
 	}
+}
 
-	// Resume TUI
-	if err := s.Screen.Init(); err != nil {
-		fmt.Fprintf(os.Stderr, "Fatal: failed to reinitialize screen: %v\n", err)
-		os.Exit(1)
+// editorCommand returns the user's editor: $EDITOR, $VISUAL or vi.
+func editorCommand() string {
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
 	}
-	s.Screen.Sync()
+	if editor := os.Getenv("VISUAL"); editor != "" {
+		return editor
+	}
+	return "vi"
 }
 
-// gitRoot returns the top-level directory of the current git repository.
-func gitRoot() (string, error) {
-	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
-	if err != nil {
-		return "", err
+// remoteEditorArgs returns the command that opens path at line in an
+// editor that is already running, by editor_server or, inside a Neovim
+// terminal, $NVIM: nvim --server with the address, or emacsclient with an
+// optional server name. It returns nil when there is none.
+func (s *State) remoteEditorArgs(path string, line int) []string {
+	server := strings.Fields(s.Config.EditorServer)
+	if len(server) == 0 {
+		if addr := os.Getenv("NVIM"); addr != "" {
+			server = []string{"nvim", addr}
+		}
+	}
+	if len(server) == 0 {
+		return nil
 	}
-	// Trim trailing newline
-	root := string(out)
-	if len(root) > 0 && root[len(root)-1] == '\n' {
-		root = root[:len(root)-1]
+	line = max(line, 1)
+	switch server[0] {
+	case "nvim":
+		// Leave terminal or insert mode first, then edit; spaces are escaped
+		// for the Ex command line
+		cmd := fmt.Sprintf(`<C-\><C-N>:edit +%d %s<CR>`, line, strings.ReplaceAll(path, " ", `\ `))
+		return []string{"nvim", "--server", server[1], "--remote-send", cmd}
+	case "emacs":
+		args := []string{"emacsclient", "--no-wait"}
+		if len(server) > 1 {
+			args = append(args, "--socket-name="+server[1])
+		}
+		return append(args, fmt.Sprintf("+%d", line), path)
+	}
+	return nil
+}
+
+// openInRemoteEditor sends file at lineNo to the running editor of
+// remoteEditorArgs, leaving the view open. It reports false when there is
+// no such editor.
+func openInRemoteEditor(s *State, file string, lineNo int) bool {
+	path, ok := resolveFile(s, file)
+	if !ok {
+		return true
+	}
+	args := s.remoteEditorArgs(path, lineNo)
+	if args == nil {
+		return false
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
+		s.FlashMsg = fmt.Sprintf("%s: %v %s", args[0], err, strings.TrimSpace(string(out)))
+	} else {
+		s.FlashMsg = fmt.Sprintf("Opened %s:%d in %s", file, max(lineNo, 1), args[0])
 	}
-	return root, nil
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	return true
 }
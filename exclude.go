@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// wiffignoreName is the per-repository exclusion file, read from the
+// repository root (or the current directory outside a repository).
+const wiffignoreName = ".wiffignore"
+
+// loadWiffignore returns the patterns in .wiffignore, one per line. Blank
+// lines and lines starting with '#' are skipped; a missing file is fine.
+func loadWiffignore() []string {
+	dir := "."
+	if root, err := repo.Root(); err == nil {
+		dir = root
+	}
+	f, err := os.Open(filepath.Join(dir, wiffignoreName))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	var patterns []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// excluded reports whether file matches any of the exclusion patterns.
+func excluded(patterns []string, file string) bool {
+	for _, p := range patterns {
+		if matchExclude(p, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExclude matches a gitignore-style pattern against a slash-separated
+// path. "**" matches any number of directories. A pattern without a slash
+// matches the name of the file or of any directory it is in, and a trailing
+// slash only matches directories.
+func matchExclude(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	if dirOnly {
+		parts = parts[:len(parts)-1]
+	}
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+	segs := strings.Split(pattern, "/")
+	// A directory pattern also covers everything below the directory
+	for n := len(parts); n > 0; n-- {
+		if matchSegments(segs, parts[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches pattern segments against path segments, with "**"
+// standing for zero or more segments.
+func matchSegments(segs, parts []string) bool {
+	if len(segs) == 0 {
+		return len(parts) == 0
+	}
+	if segs[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(segs[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(segs[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(segs[1:], parts[1:])
+}
+
+// excludeHunks drops hunks of excluded files, records how many files were
+// hidden and relabels the rest so labels stay dense.
+func (s *State) excludeHunks(hunks []Hunk) []Hunk {
+	s.HiddenFiles = 0
+	if len(s.Excludes) == 0 {
+		return hunks
+	}
+	hidden := make(map[string]bool)
+	kept := hunks[:0]
+	for _, h := range hunks {
+		if excluded(s.Excludes, h.File) {
+			hidden[h.File] = true
+			continue
+		}
+		kept = append(kept, h)
+	}
+	if len(hidden) == 0 {
+		return kept
+	}
+	s.HiddenFiles = len(hidden)
+	for i := range kept {
+		kept[i].Label = indexToLabel(i)
+		for j := range kept[i].Lines {
+			kept[i].Lines[j].Moved = false
+		}
+	}
+	markMovedLines(kept)
+	return kept
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestMatchExclude(t *testing.T) {
+	cases := []struct {
+		pattern, file string
+		want          bool
+	}{
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/b.go", false},
+		{"**/vendor/**", "src/vendor/b.go", true},
+		{"*.lock", "Cargo.lock", true},
+		{"*.lock", "sub/dir/yarn.lock", true},
+		{"*.lock", "lock.go", false},
+		{"node_modules", "web/node_modules/x/index.js", true},
+		{"docs/", "docs/index.md", true},
+		{"docs/", "docs", false},
+		{"internal/gen", "internal/gen/api.go", true},
+		{"/main.go", "main.go", true},
+	}
+	for _, c := range cases {
+		if got := matchExclude(c.pattern, c.file); got != c.want {
+			t.Errorf("matchExclude(%q, %q) = %v, want %v", c.pattern, c.file, got, c.want)
+		}
+	}
+}
+
+func TestExcludeHunksRelabels(t *testing.T) {
+	s := &State{Excludes: []string{"*.lock"}}
+	hunks := []Hunk{
+		{Label: indexToLabel(0), File: "go.sum.lock"},
+		{Label: indexToLabel(1), File: "main.go"},
+		{Label: indexToLabel(2), File: "go.sum.lock"},
+	}
+	kept := s.excludeHunks(hunks)
+	if len(kept) != 1 || kept[0].File != "main.go" || kept[0].Label != indexToLabel(0) {
+		t.Fatalf("kept = %+v, want main.go relabeled %q", kept, indexToLabel(0))
+	}
+	if s.HiddenFiles != 1 {
+		t.Errorf("HiddenFiles = %d, want 1", s.HiddenFiles)
+	}
+	if got := renderSegments(s, []string{"hidden"}); got != "1 file hidden" {
+		t.Errorf("hidden segment = %q", got)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// expandStep is how many context lines '<' and '>' reveal at a time.
+const expandStep = 10
+
+// hunkCounts returns the number of old-side and new-side lines in h.
+func hunkCounts(h *Hunk) (oldCount, newCount int) {
+	for _, l := range h.Lines {
+		switch l.Op {
+		case '+':
+			newCount++
+		case '-':
+			oldCount++
+		default:
+			oldCount++
+			newCount++
+		}
+	}
+	return
+}
+
+// hunkSpan returns the first and last 1-based line numbers h covers on one
+// side, given its start and count. An empty side (count 0) sits just after
+// line start, so the span is [start+1, start].
+func hunkSpan(start, count int) (top, end int) {
+	top = start
+	if count == 0 {
+		top = start + 1
+	}
+	return top, top + count - 1
+}
+
+// expandedLines returns the display rows for the extra context spliced above
+// (or below) hunk i by ExpandHunk.
+func (s *State) expandedLines(i int, above bool) []DisplayLine {
+	h := &s.Hunks[i]
+	extra := h.Below
+	if above {
+		extra = h.Above
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	oldCount, newCount := hunkCounts(h)
+	oldTop, oldEnd := hunkSpan(h.OldStart, oldCount)
+	newTop, newEnd := hunkSpan(h.NewStart, newCount)
+	oldNo, newNo := oldEnd+1, newEnd+1
+	if above {
+		oldNo, newNo = oldTop-len(extra), newTop-len(extra)
+	}
+
+	lines := make([]DisplayLine, 0, len(extra))
+	for k, text := range extra {
+		dl := DisplayLine{
+			Text:      " " + text,
+			Style:     StyleContext,
+			HunkIdx:   i,
+			OldLineNo: oldNo + k,
+			NewLineNo: newNo + k,
+		}
+		if s.SideBySide {
+			dl.Text = ""
+			dl.OldLineNo, dl.NewLineNo = 0, 0
+			dl.Left = HalfLine{Text: " " + text, Style: StyleContext, LineNo: oldNo + k}
+			dl.Right = HalfLine{Text: " " + text, Style: StyleContext, LineNo: newNo + k}
+		}
+		lines = append(lines, dl)
+	}
+	return lines
+}
+
+// contextSource returns the lines of file on the side context is read from:
+// the new side, or the old side when the file was deleted.
+func (s *State) contextSource(h *Hunk) ([]string, error) {
+	oldRev, newRev := s.sideRevs()
+	rev := newRev
+	_, newCount := hunkCounts(h)
+	if h.NewStart == 0 && newCount == 0 {
+		rev = oldRev // deleted file: only the old side exists
+	}
+	if s.PipeMode {
+		rev = revWorktree
+	}
+	data, err := fileAt(rev, h.File)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// ExpandHunk reveals more unchanged lines around the current hunk, read
+// from the file itself: dir '<' adds expandStep lines above, '>' below, and
+// 'E' fills the whole gap to the neighbouring hunks (or file edges) on both
+// sides. Expansion never overlaps a neighbouring hunk's lines.
+func (s *State) ExpandHunk(dir rune) {
+	if len(s.Hunks) == 0 || s.FullFile {
+		return
+	}
+	idx := s.CurrentHunkIndex()
+	h := &s.Hunks[idx]
+	fileLines, err := s.contextSource(h)
+	if err != nil {
+		s.FlashMsg = "Expand failed: cannot read " + h.File
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+
+	if s.expandHunkFrom(idx, fileLines, dir) == 0 {
+		s.FlashMsg = "No more context to expand"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.BuildLines()
+	s.ClampScroll()
+}
+
+// expandHunkFrom splices lines of fileLines around hunk idx as ExpandHunk
+// describes and returns how many lines were added.
+func (s *State) expandHunkFrom(idx int, fileLines []string, dir rune) int {
+	h := &s.Hunks[idx]
+	oldCount, newCount := hunkCounts(h)
+	deleted := h.NewStart == 0 && newCount == 0
+	start, count := h.NewStart, newCount
+	if deleted {
+		start, count = h.OldStart, oldCount
+	}
+	top, end := hunkSpan(start, count)
+
+	// Neighbouring hunks in the same file bound the gap
+	first, last := 1, len(fileLines)
+	if idx > 0 && s.Hunks[idx-1].File == h.File {
+		p := &s.Hunks[idx-1]
+		_, pc := hunkCounts(p)
+		_, pEnd := hunkSpan(p.NewStart, pc)
+		first = pEnd + len(p.Below) + 1
+	}
+	if idx+1 < len(s.Hunks) && s.Hunks[idx+1].File == h.File {
+		n := &s.Hunks[idx+1]
+		_, nc := hunkCounts(n)
+		nTop, _ := hunkSpan(n.NewStart, nc)
+		last = nTop - len(n.Above) - 1
+	}
+
+	added := 0
+	if dir == '<' || dir == 'E' {
+		cur := top - len(h.Above) // first line shown
+		want := first
+		if dir == '<' && cur-expandStep > first {
+			want = cur - expandStep
+		}
+		if want < cur && cur-1 <= len(fileLines) {
+			h.Above = append(append([]string{}, fileLines[want-1:cur-1]...), h.Above...)
+			added += cur - want
+		}
+	}
+	if dir == '>' || dir == 'E' {
+		cur := end + len(h.Below) // last line shown
+		want := last
+		if dir == '>' && cur+expandStep < last {
+			want = cur + expandStep
+		}
+		if want > cur && cur >= 0 {
+			h.Below = append(h.Below, fileLines[cur:want]...)
+			added += want - cur
+		}
+	}
+
+	return added
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return lines
+}
+
+func TestExpandHunkAboveAndBelow(t *testing.T) {
+	file := numberedLines(40)
+	s := &State{Hunks: []Hunk{{
+		File: "f.go", OldStart: 20, NewStart: 20,
+		Lines: []Line{{Op: ' ', Content: "line 20"}, {Op: '+', Content: "new"}, {Op: ' ', Content: "line 21"}},
+	}}}
+
+	if n := s.expandHunkFrom(0, file, '<'); n != expandStep {
+		t.Fatalf("expand above added %d, want %d", n, expandStep)
+	}
+	h := &s.Hunks[0]
+	if h.Above[0] != "line 10" || h.Above[len(h.Above)-1] != "line 19" {
+		t.Errorf("Above = %q..%q, want line 10..line 19", h.Above[0], h.Above[len(h.Above)-1])
+	}
+	if n := s.expandHunkFrom(0, file, '>'); n != expandStep {
+		t.Fatalf("expand below added %d", n)
+	}
+	if h.Below[0] != "line 23" {
+		t.Errorf("Below[0] = %q, want line 23", h.Below[0])
+	}
+
+	// E fills the rest of the file on both sides
+	s.expandHunkFrom(0, file, 'E')
+	if len(h.Above) != 19 || len(h.Below) != 18 {
+		t.Errorf("after E: above %d, below %d; want 19, 18", len(h.Above), len(h.Below))
+	}
+	if n := s.expandHunkFrom(0, file, 'E'); n != 0 {
+		t.Errorf("nothing left to expand, added %d", n)
+	}
+
+	rows := s.expandedLines(0, true)
+	if rows[0].NewLineNo != 1 || rows[0].OldLineNo != 1 || rows[0].Text != " line 1" {
+		t.Errorf("first expanded row = %+v", rows[0])
+	}
+}
+
+func TestExpandStopsAtNeighbourHunk(t *testing.T) {
+	file := numberedLines(40)
+	s := &State{Hunks: []Hunk{
+		{File: "f.go", OldStart: 5, NewStart: 5, Lines: []Line{{Op: ' ', Content: "line 5"}}},
+		{File: "f.go", OldStart: 12, NewStart: 12, Lines: []Line{{Op: ' ', Content: "line 12"}}},
+	}}
+	s.expandHunkFrom(1, file, '<')
+	if got := s.Hunks[1].Above; len(got) != 6 || got[0] != "line 6" {
+		t.Errorf("Above = %q, want line 6..line 11", got)
+	}
+}
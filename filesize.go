@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fileSide is the size of one side of a changed file. Exists is false when
+// the file is missing on that side (added or deleted) or couldn't be read.
+type fileSide struct {
+	Bytes  int
+	Lines  int
+	Exists bool
+}
+
+// FileSize holds the old and new sizes of a changed file.
+type FileSize struct {
+	Old, New fileSide
+}
+
+// Thresholds for flagging a file that grew unexpectedly: at least
+// balloonFactor times its old line count and balloonLines more lines, or a
+// new file of at least balloonNewLines lines.
+const (
+	balloonFactor   = 2
+	balloonLines    = 100
+	balloonNewLines = 1000
+)
+
+// Ballooned reports whether the file grew a lot more than a typical edit.
+func (f FileSize) Ballooned() bool {
+	if !f.New.Exists {
+		return false
+	}
+	if !f.Old.Exists {
+		return f.New.Lines >= balloonNewLines
+	}
+	return f.New.Lines >= balloonFactor*f.Old.Lines && f.New.Lines-f.Old.Lines >= balloonLines
+}
+
+// String formats the sizes as "old→new lines (±n), old→new (±bytes)", with
+// ∅ for a side that doesn't exist.
+func (f FileSize) String() string {
+	lines := func(fs fileSide) string {
+		if !fs.Exists {
+			return "∅"
+		}
+		return strconv.Itoa(fs.Lines)
+	}
+	size := func(fs fileSide) string {
+		if !fs.Exists {
+			return "∅"
+		}
+		return formatBytes(fs.Bytes)
+	}
+	dl, db := f.New.Lines-f.Old.Lines, f.New.Bytes-f.Old.Bytes
+	sign := "+"
+	if db < 0 {
+		sign, db = "-", -db
+	}
+	return fmt.Sprintf("%s→%s lines (%+d), %s→%s (%s%s)",
+		lines(f.Old), lines(f.New), dl, size(f.Old), size(f.New), sign, formatBytes(db))
+}
+
+// formatBytes formats a byte count with a binary unit (B, K, M, G).
+func formatBytes(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	for _, unit := range []string{"K", "M", "G"} {
+		v /= 1024
+		if v < 1024 || unit == "G" {
+			if v < 10 {
+				return fmt.Sprintf("%.1f%s", v, unit)
+			}
+			return fmt.Sprintf("%.0f%s", v, unit)
+		}
+	}
+	return ""
+}
+
+// measure returns the size of content.
+func measure(content []byte) fileSide {
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return fileSide{Bytes: len(content), Lines: lines, Exists: true}
+}
+
+// loadFileSizes measures the old and new side of every changed file.
+func (s *State) loadFileSizes() {
+	s.FileSizes = nil
+	if job := s.fileSizesJob(); job != nil {
+		s.FileSizes = job(s.Hunks)
+	}
+}
+
+// fileSizesJob returns a function measuring the files of hunks on the sides
+// of the diff, for running off the main goroutine. Piped diffs have no
+// sides to read and get none (nil).
+func (s *State) fileSizesJob() func(hunks []Hunk) map[string]FileSize {
+	if s.PipeMode {
+		return nil
+	}
+	oldRev, newRev := s.sideRevs()
+	return func(hunks []Hunk) map[string]FileSize {
+		return fileSizes(hunks, oldRev, newRev)
+	}
+}
+
+// fileSizes measures the files of hunks at oldRev and newRev, the old side
+// of a renamed file under its old name.
+func fileSizes(hunks []Hunk, oldRev, newRev string) map[string]FileSize {
+	var files, oldFiles []string
+	seen := make(map[string]bool)
+	for _, h := range hunks {
+		if !seen[h.File] {
+			seen[h.File] = true
+			files = append(files, h.File)
+			old := h.File
+			if h.OldFile != "" {
+				old = h.OldFile
+			}
+			oldFiles = append(oldFiles, old)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	oldSides, newSides := sidesAt(oldRev, oldFiles), sidesAt(newRev, files)
+	sizes := make(map[string]FileSize, len(files))
+	for i, f := range files {
+		sizes[f] = FileSize{Old: oldSides[oldFiles[i]], New: newSides[f]}
+	}
+	return sizes
+}
+
+// sidesAt measures files at rev (see sideRevs for the special revisions).
+// Revisions and the index are read with one git cat-file --batch.
+func sidesAt(rev string, files []string) map[string]fileSide {
+	sides := make(map[string]fileSide, len(files))
+	if path, ok := strings.CutPrefix(rev, revPath); ok {
+		if content, err := os.ReadFile(path); err == nil {
+			for _, f := range files {
+				sides[f] = measure(content)
+			}
+		}
+		return sides
+	}
+	if rev == revWorktree {
+		root, err := repo.Root()
+		if err != nil {
+			return sides
+		}
+		for _, f := range files {
+			if content, err := os.ReadFile(filepath.Join(root, f)); err == nil {
+				sides[f] = measure(content)
+			}
+		}
+		return sides
+	}
+
+	prefix := rev + ":"
+	if rev == revIndex {
+		prefix = ":"
+	}
+	var specs strings.Builder
+	for _, f := range files {
+		specs.WriteString(prefix + f + "\n")
+	}
+	cmd := exec.Command("git", "cat-file", "--batch")
+	if root, err := repo.Root(); err == nil {
+		cmd.Dir = root
+	}
+	cmd.Stdin = strings.NewReader(specs.String())
+	out, err := cmd.Output()
+	if err != nil {
+		return sides
+	}
+	return parseCatFileBatch(out, files)
+}
+
+// parseCatFileBatch reads git cat-file --batch output, one object per file
+// in order: "<oid> <type> <size>\n<content>\n" or "<spec> missing\n".
+func parseCatFileBatch(out []byte, files []string) map[string]fileSide {
+	sides := make(map[string]fileSide, len(files))
+	r := bufio.NewReader(bytes.NewReader(out))
+	for _, f := range files {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 || fields[2] == "missing" {
+			continue // missing or ambiguous
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			break
+		}
+		content := make([]byte, size+1) // content plus trailing newline
+		if _, err := io.ReadFull(r, content); err != nil {
+			break
+		}
+		if fields[1] == "blob" {
+			sides[f] = measure(content[:size])
+		}
+	}
+	return sides
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestParseCatFileBatch(t *testing.T) {
+	out := []byte("1111 blob 6\na\nb\nc\n\nHEAD:gone.go missing\n2222 blob 3\nxyz\n")
+	got := parseCatFileBatch(out, []string{"a.txt", "gone.go", "b.txt"})
+	if got["a.txt"] != (fileSide{Bytes: 6, Lines: 3, Exists: true}) {
+		t.Errorf("a.txt = %+v", got["a.txt"])
+	}
+	if got["gone.go"].Exists {
+		t.Errorf("gone.go should be missing")
+	}
+	if got["b.txt"] != (fileSide{Bytes: 3, Lines: 1, Exists: true}) {
+		t.Errorf("b.txt = %+v", got["b.txt"])
+	}
+}
+
+func TestFileSize(t *testing.T) {
+	side := func(lines, bytes int) fileSide { return fileSide{Bytes: bytes, Lines: lines, Exists: true} }
+	tests := []struct {
+		size      FileSize
+		want      string
+		ballooned bool
+	}{
+		{FileSize{Old: side(120, 3300), New: side(135, 3700)}, "120→135 lines (+15), 3.2K→3.6K (+400B)", false},
+		{FileSize{Old: side(50, 2000), New: side(400, 16000)}, "50→400 lines (+350), 2.0K→16K (+14K)", true},
+		{FileSize{Old: side(10, 300), New: side(4, 100)}, "10→4 lines (-6), 300B→100B (-200B)", false},
+		{FileSize{New: side(1500, 60000)}, "∅→1500 lines (+1500), ∅→59K (+59K)", true},
+		{FileSize{Old: side(3, 30)}, "3→∅ lines (-3), 30B→∅ (-30B)", false},
+	}
+	for _, tt := range tests {
+		if got := tt.size.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		if got := tt.size.Ballooned(); got != tt.ballooned {
+			t.Errorf("%s: Ballooned() = %v, want %v", tt.want, got, tt.ballooned)
+		}
+	}
+}
+
+func TestFileSizesOfRename(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("old.txt", []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"}, {"add", "old.txt"},
+		{"-c", "user.name=Ann", "-c", "user.email=ann@example.com", "commit", "-qm", "add"},
+		{"mv", "old.txt", "new.txt"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	if err := os.WriteFile("new.txt", []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &State{Refs: []string{"HEAD"}, Hunks: []Hunk{{File: "new.txt", OldFile: "old.txt"}}}
+	s.loadFileSizes()
+	if got := s.FileSizes["new.txt"].String(); got != "2→3 lines (+1), 4B→6B (+2B)" {
+		t.Errorf("size of the renamed file = %s", got)
+	}
+}
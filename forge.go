@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// parseRemote splits a git remote URL (https, ssh or scp-like) into the
+// web host and the owner/repo path.
+func parseRemote(remote string) (host, repo string, err error) {
+	remote = strings.TrimSpace(remote)
+	switch {
+	case strings.Contains(remote, "://"):
+		u, perr := url.Parse(remote)
+		if perr != nil {
+			return "", "", perr
+		}
+		host, repo = u.Hostname(), u.Path
+	case strings.Contains(remote, ":"):
+		// scp-like: git@github.com:owner/repo.git
+		at := strings.LastIndex(remote, "@")
+		colon := strings.Index(remote, ":")
+		if at > colon {
+			at = -1
+		}
+		host, repo = remote[at+1:colon], remote[colon+1:]
+	default:
+		return "", "", fmt.Errorf("unrecognised remote %q", remote)
+	}
+	repo = strings.TrimSuffix(strings.Trim(repo, "/"), ".git")
+	if host == "" || repo == "" {
+		return "", "", fmt.Errorf("unrecognised remote %q", remote)
+	}
+	return host, repo, nil
+}
+
+// forgeURL returns the web URL of file at lines start to end in ref on the
+// forge hosting remote (end <= start links a single line). GitLab and
+// Bitbucket are recognised by host name; anything else gets GitHub-style
+// URLs, which Gitea and Forgejo share.
+func forgeURL(remote, ref, file string, start, end int) (string, error) {
+	host, repo, err := parseRemote(remote)
+	if err != nil {
+		return "", err
+	}
+	base := "https://" + host + "/" + repo
+	ref, file = escapePath(ref), escapePath(file)
+	switch {
+	case strings.Contains(host, "gitlab"):
+		anchor := fmt.Sprintf("L%d", start)
+		if end > start {
+			anchor += fmt.Sprintf("-%d", end)
+		}
+		return fmt.Sprintf("%s/-/blob/%s/%s#%s", base, ref, file, anchor), nil
+	case strings.Contains(host, "bitbucket"):
+		anchor := fmt.Sprintf("lines-%d", start)
+		if end > start {
+			anchor += fmt.Sprintf(":%d", end)
+		}
+		return fmt.Sprintf("%s/src/%s/%s#%s", base, ref, file, anchor), nil
+	}
+	anchor := fmt.Sprintf("L%d", start)
+	if end > start {
+		anchor += fmt.Sprintf("-L%d", end)
+	}
+	return fmt.Sprintf("%s/blob/%s/%s#%s", base, ref, file, anchor), nil
+}
+
+// escapePath escapes each segment of the slash-separated path p for a URL,
+// so that a space, '#', '?' or '%' in a name stays part of it.
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		segs[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segs, "/")
+}
+
+// forgeRef returns the commit the new side of the diff points at. Working
+// tree and index diffs use HEAD, the closest thing the forge knows about;
+// their lines are numbered as in HEAD by headLines.
+func (s *State) forgeRef() (string, error) {
+	_, rev := s.sideRevs()
+	if rev == revWorktree || rev == revIndex {
+		rev = "HEAD"
+	}
+	out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s", rev)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// currentForgeURL builds the forge URL for the current file and line.
+func (s *State) currentForgeURL() (string, error) {
+	file := s.CurrentFile()
+	if file == "" {
+		return "", fmt.Errorf("no file here")
+	}
+	line := s.CurrentLineNo()
+	return s.forgeLink(file, line, line)
+}
+
+// forgeLink builds the forge URL for lines start to end of file on the new
+// side of the diff.
+func (s *State) forgeLink(file string, start, end int) (string, error) {
+	if s.PipeMode || s.NoIndex {
+		return "", fmt.Errorf("forge links need a git diff")
+	}
+	remote, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", fmt.Errorf("no origin remote")
+	}
+	ref, err := s.forgeRef()
+	if err != nil {
+		return "", err
+	}
+	if _, rev := s.sideRevs(); rev == revWorktree || rev == revIndex {
+		if start, end, err = headLines(file, rev == revIndex, start, end); err != nil {
+			return "", err
+		}
+	}
+	return forgeURL(string(remote), ref, file, start, end)
+}
+
+// headLines numbers lines start to end of file in the working tree (or in
+// the index, with cached) as they are in HEAD, for a link to HEAD. It fails
+// when one of them isn't committed, as HEAD doesn't have it.
+func headLines(file string, cached bool, start, end int) (int, int, error) {
+	argv := []string{"git", "diff", "--no-color", "--no-ext-diff", "-U0"}
+	if cached {
+		argv = append(argv, "--cached")
+	}
+	raw, err := runDiffCommand(context.Background(), append(argv, "HEAD", "--", ":(top)"+file))
+	if err != nil {
+		return 0, 0, err
+	}
+	hunks, err := parseDiff(raw)
+	if err != nil {
+		return 0, 0, err
+	}
+	toHead := func(n int) (int, bool) {
+		shift := 0
+		for _, h := range hunks {
+			added, removed := 0, 0
+			for _, l := range h.Lines {
+				switch l.Op {
+				case '+':
+					added++
+				case '-':
+					removed++
+				}
+			}
+			// Without added lines NewStart is the line before the removal
+			if n < h.NewStart || (added == 0 && n == h.NewStart) {
+				break
+			}
+			if n < h.NewStart+added {
+				return 0, false
+			}
+			shift += removed - added
+		}
+		return n + shift, true
+	}
+	for n := start; n <= max(start, end); n++ {
+		if _, ok := toHead(n); !ok {
+			return 0, 0, fmt.Errorf("line %d of %s is not committed", n, file)
+		}
+	}
+	headStart, _ := toHead(start)
+	headEnd, _ := toHead(end)
+	return headStart, headEnd, nil
+}
+
+// openBrowser opens u with $BROWSER or the platform's opener, without
+// waiting for it. The opener is reaped in the background once it exits.
+func openBrowser(u string) error {
+	name := os.Getenv("BROWSER")
+	if name == "" {
+		name = "xdg-open"
+		if runtime.GOOS == "darwin" {
+			name = "open"
+		}
+	}
+	cmd := exec.Command(name, u)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// openForgePicker offers to open the forge URL of the current line in the
+// browser or copy it.
+func openForgePicker(s *State) {
+	u, err := s.currentForgeURL()
+	if err != nil {
+		s.FlashMsg = "Forge link: " + err.Error()
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	OpenPopup(s, &Popup{
+		Title: u,
+		Items: []string{"Open in browser", "Copy URL"},
+		OnSelect: func(s *State, idx int) {
+			if idx == 1 {
+				copyText(s, u, "Copied "+u)
+				return
+			}
+			if err := openBrowser(u); err != nil {
+				s.FlashMsg = "Browser: " + err.Error()
+			} else {
+				s.FlashMsg = "Opened " + u
+			}
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+		},
+	})
+}
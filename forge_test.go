@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestForgeURL(t *testing.T) {
+	cases := []struct {
+		remote, want string
+	}{
+		{"git@github.com:h0rv/wiff.git\n", "https://github.com/h0rv/wiff/blob/abc/src/a.go#L7"},
+		{"https://github.com/h0rv/wiff", "https://github.com/h0rv/wiff/blob/abc/src/a.go#L7"},
+		{"ssh://git@gitlab.com:2222/group/sub/proj.git", "https://gitlab.com/group/sub/proj/-/blob/abc/src/a.go#L7"},
+		{"https://user@bitbucket.org/team/repo.git", "https://bitbucket.org/team/repo/src/abc/src/a.go#lines-7"},
+	}
+	for _, c := range cases {
+		got, err := forgeURL(c.remote, "abc", "src/a.go", 7, 7)
+		if err != nil {
+			t.Errorf("forgeURL(%q): %v", c.remote, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("forgeURL(%q) = %q, want %q", c.remote, got, c.want)
+		}
+	}
+	got, _ := forgeURL("git@gitlab.com:g/p.git", "abc", "a.go", 3, 9)
+	if want := "https://gitlab.com/g/p/-/blob/abc/a.go#L3-9"; got != want {
+		t.Errorf("range: got %q, want %q", got, want)
+	}
+	got, _ = forgeURL("git@github.com:o/r.git", "abc", "a.go", 3, 9)
+	if want := "https://github.com/o/r/blob/abc/a.go#L3-L9"; got != want {
+		t.Errorf("range: got %q, want %q", got, want)
+	}
+	got, _ = forgeURL("git@github.com:o/r.git", "abc", "my dir/a#1?%.go", 2, 2)
+	if want := "https://github.com/o/r/blob/abc/my%20dir/a%231%3F%25.go#L2"; got != want {
+		t.Errorf("escaping: got %q, want %q", got, want)
+	}
+	got, _ = forgeURL("git@gitlab.com:g/p.git", "abc", "a b.go", 2, 2)
+	if want := "https://gitlab.com/g/p/-/blob/abc/a%20b.go#L2"; got != want {
+		t.Errorf("escaping: got %q, want %q", got, want)
+	}
+	if _, err := forgeURL("/local/path/repo", "abc", "a.go", 1, 1); err == nil {
+		t.Error("expected error for a local path remote")
+	}
+}
+
+func TestForgeLinkNumbersWorktreeLinesAsInHead(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("remote", "add", "origin", "git@github.com:h0rv/wiff.git")
+	if err := os.WriteFile("a.txt", []byte("1\n2\n3\n4\n5\n6\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-qm", "Add a")
+	// Two lines inserted after 1 and line 5 removed, in the working tree
+	if err := os.WriteFile("a.txt", []byte("1\nnew\nnew\n2\n3\n4\n6\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &State{}
+	u, err := s.forgeLink("a.txt", 6, 7)
+	if err != nil || !strings.HasSuffix(u, "/a.txt#L4-L6") {
+		t.Errorf("lines 6-7 of the working tree: %s %v, want lines 4-6 of HEAD", u, err)
+	}
+	if u, err := s.forgeLink("a.txt", 1, 1); err != nil || !strings.HasSuffix(u, "#L1") {
+		t.Errorf("line 1: %s %v", u, err)
+	}
+	if _, err := s.forgeLink("a.txt", 1, 3); err == nil || !strings.Contains(err.Error(), "not committed") {
+		t.Errorf("uncommitted lines: %v", err)
+	}
+}
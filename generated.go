@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// generatedPatterns are paths treated as generated without any
+// configuration: lock files, protobuf output and minified assets.
+var generatedPatterns = []string{
+	"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "npm-shrinkwrap.json",
+	"go.sum", "Cargo.lock", "Gemfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock",
+	"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.cc", "*.pb.h",
+	"zz_generated*.go", "*_generated.go", "*.gen.go",
+	"*.min.js", "*.min.css", "*.map",
+}
+
+// generatedFiles returns the files in hunks that are generated: marked
+// linguist-generated in .gitattributes, matching a built-in pattern, or
+// matching a pattern from the generated config key.
+func (s *State) generatedFiles(hunks []Hunk) map[string]bool {
+	if !s.Config.collapseGenerated() {
+		return nil
+	}
+	var files []string
+	seen := make(map[string]bool)
+	for _, h := range hunks {
+		if !seen[h.File] {
+			seen[h.File] = true
+			files = append(files, h.File)
+		}
+	}
+	generated := make(map[string]bool)
+	for _, f := range files {
+		if excluded(generatedPatterns, f) || excluded(s.Config.Generated, f) {
+			generated[f] = true
+		}
+	}
+	if !s.NoIndex {
+		for f, set := range linguistGenerated(files) {
+			// An explicit linguist-generated=false overrides the built-in patterns
+			generated[f] = set
+		}
+	}
+	return generated
+}
+
+// linguistGenerated asks git for the linguist-generated attribute of files.
+// Only files with the attribute set or explicitly unset are returned.
+func linguistGenerated(files []string) map[string]bool {
+	attrs := make(map[string]bool)
+	for f, v := range checkAttr(files, "linguist-generated") {
+		switch v {
+		case "set", "true":
+			attrs[f] = true
+		case "unset", "false":
+			attrs[f] = false
+		}
+	}
+	return attrs
+}
+
+// checkAttr asks git for the value of a gitattributes attribute of files:
+// "set", "unset", "unspecified" or the value it was given.
+func checkAttr(files []string, attr string) map[string]string {
+	if len(files) == 0 {
+		return nil
+	}
+	cmd := exec.Command("git", "check-attr", "-z", "--stdin", attr)
+	cmd.Stdin = strings.NewReader(strings.Join(files, "\x00") + "\x00")
+	if root, err := repo.Root(); err == nil {
+		cmd.Dir = root
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	// Output is NUL-separated triples: path, attribute, value
+	fields := bytes.Split(out, []byte{0})
+	values := make(map[string]string)
+	for i := 0; i+2 < len(fields); i += 3 {
+		values[string(fields[i])] = string(fields[i+2])
+	}
+	return values
+}
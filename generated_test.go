@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestGeneratedFilesCollapse(t *testing.T) {
+	s := &State{Config: Config{Generated: []string{"*.snap"}}}
+	s.Hunks = []Hunk{
+		{File: "main.go", Lines: []Line{{Op: '+', Content: "x"}}},
+		{File: "web/package-lock.json", Lines: []Line{{Op: '+', Content: "{}"}}},
+		{File: "api/user.pb.go", Lines: []Line{{Op: '+', Content: "y"}}},
+		{File: "ui/__snapshots__/app.snap", Lines: []Line{{Op: '+', Content: "z"}}},
+	}
+	s.Generated = s.generatedFiles(s.Hunks)
+	collapsed := s.collapsedFiles()
+	for _, f := range []string{"web/package-lock.json", "api/user.pb.go", "ui/__snapshots__/app.snap"} {
+		if collapsed[f] != "generated file" {
+			t.Errorf("%s: collapsed reason = %q, want generated file", f, collapsed[f])
+		}
+	}
+	if _, ok := collapsed["main.go"]; ok {
+		t.Error("main.go should not be collapsed")
+	}
+
+	s.Expanded = map[string]bool{"api/user.pb.go": true}
+	if _, ok := s.collapsedFiles()["api/user.pb.go"]; ok {
+		t.Error("expanded generated file should not be collapsed")
+	}
+
+	s.Config.NoCollapseGenerated = true
+	if got := s.generatedFiles(s.Hunks); len(got) != 0 {
+		t.Errorf("collapse_generated = false still detected %v", got)
+	}
+}
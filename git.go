@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Revision specs used by sideRevs: revWorktree is the working tree on disk,
+// revIndex is the staging area and revPath followed by a path is that plain
+// file (no-index diffs); anything else is a revision of the repository.
+const (
+	revWorktree = ""
+	revIndex    = ":"
+	revPath     = "path:"
+)
+
+// splitRange splits "a..b" or "a...b" into its endpoints. ok is false when
+// ref is not a range.
+func splitRange(ref string) (from, to string, ok bool) {
+	if i := strings.Index(ref, "..."); i >= 0 {
+		return ref[:i], ref[i+3:], true
+	}
+	if i := strings.Index(ref, ".."); i >= 0 {
+		return ref[:i], ref[i+2:], true
+	}
+	return "", "", false
+}
+
+// sideRevs returns the revisions the old and new sides of the diff come
+// from, mirroring how git diff interprets the refs and --staged flag.
+func (s *State) sideRevs() (oldRev, newRev string) {
+	switch {
+	case s.NoIndex:
+		return revPath + s.Refs[0], revPath + s.Refs[1]
+	case len(s.Refs) >= 2:
+		return s.Refs[0], s.Refs[1]
+	case len(s.Refs) == 1:
+		if from, to, ok := splitRange(s.Refs[0]); ok {
+			from, to = orRev(from, repo.Head()), orRev(to, repo.Head())
+			// a...b compares b against the merge base of a and b
+			if _, git := repo.(gitVCS); git && strings.Contains(s.Refs[0], "...") {
+				if out, err := exec.Command("git", "merge-base", from, to).Output(); err == nil {
+					from = strings.TrimSpace(string(out))
+				}
+			}
+			return from, to
+		}
+		if s.Staged {
+			return s.Refs[0], revIndex
+		}
+		return s.Refs[0], revWorktree
+	case s.Staged:
+		return repo.Head(), revIndex
+	}
+	return repo.Base(), revWorktree
+}
+
+// fileAt returns the content of file at rev (see sideRevs for the special
+// revisions). The working tree is read relative to the repository root.
+func fileAt(rev, file string) ([]byte, error) {
+	if path, ok := strings.CutPrefix(rev, revPath); ok {
+		return os.ReadFile(path)
+	}
+	switch rev {
+	case revWorktree:
+		root, err := repo.Root()
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(filepath.Join(root, file))
+	}
+	return repo.Show(rev, file)
+}
+
+// fileLinesAt returns the lines of file at rev, or nil when it doesn't exist
+// there.
+func fileLinesAt(rev, file string) []string {
+	content, err := fileAt(rev, file)
+	if err != nil {
+		return nil
+	}
+	text := strings.TrimRight(string(content), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// alignLines maps each line of from to the matching line of to: align[i]
+// is the 1-based line in to for line i of from, or 0 when the line was
+// changed or removed. align[0] is unused.
+func alignLines(from, to []string, algo string) []int {
+	align := make([]int, len(from)+1)
+	for _, m := range diffLines(from, to, algo) {
+		align[m.a+1] = m.b + 1
+	}
+	return align
+}
+
+// alignedLine returns where line n of the aligned-from side lands in the
+// other side: its match, or just after the nearest matched line above it.
+func alignedLine(align []int, n int) int {
+	if n > 0 && n < len(align) && align[n] > 0 {
+		return align[n]
+	}
+	for k := n - 1; k > 0; k-- {
+		if k < len(align) && align[k] > 0 {
+			return align[k] + 1
+		}
+	}
+	return 1
+}
+
+// revName describes a revision spec for display.
+func revName(rev string) string {
+	switch rev {
+	case revWorktree:
+		return "working tree"
+	case revIndex:
+		return "index"
+	}
+	return strings.TrimPrefix(rev, revPath)
+}
+
+// openRevisionPicker lets the user choose which revision the inline
+// full-file view shows: either diff side, the working tree, the index, or
+// any ref the diff was given. Side by side, it picks the two revisions to
+// compare instead.
+func openRevisionPicker(s *State) {
+	if s.PipeMode {
+		s.FlashMsg = "No revisions for piped diffs"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	if s.SideBySide {
+		openRevisionPairPicker(s)
+		return
+	}
+	oldRev, newRev := s.sideRevs()
+	current := newRev
+	switch {
+	case s.FullFileRevSet:
+		current = s.FullFileRev
+	case s.FullFileOld:
+		current = oldRev
+	}
+	pickRevision(s, "Full file revision", current, func(s *State, rev string) {
+		s.SetFullFileRev(rev)
+	})
+}
+
+// openRevisionPairPicker lets the user choose the revisions side-by-side
+// full-file mode compares: first the left column, then the right.
+func openRevisionPairPicker(s *State) {
+	left, right := s.sideRevs()
+	if s.FullFilePair {
+		left, right = s.FullFilePairRevs[0], s.FullFilePairRevs[1]
+	}
+	pickRevision(s, "Left (old) revision", left, func(s *State, left string) {
+		pickRevision(s, "Right (new) revision", right, func(s *State, right string) {
+			s.SetFullFilePair(left, right)
+		})
+	})
+}
+
+// pickRevision opens a picker of the revisions a full-file view can show
+// (see revisionChoices) with the cursor on current.
+func pickRevision(s *State, title, current string, onSelect func(s *State, rev string)) {
+	revs, items := s.revisionChoices()
+	p := &Popup{
+		Title: title,
+		Items: items,
+		OnSelect: func(s *State, idx int) {
+			onSelect(s, revs[idx])
+		},
+	}
+	for i, rev := range revs {
+		if rev == current {
+			p.Cursor = i
+		}
+	}
+	OpenPopup(s, p)
+	movePopupCursor(s, p, 0)
+}
+
+// revisionChoices returns the revisions a full-file view can show, each
+// once, and their labels: both diff sides, the working tree, the index
+// (the working copy's parent without git), the current commit and the refs
+// the diff was given.
+func (s *State) revisionChoices() (revs, labels []string) {
+	oldRev, newRev := s.sideRevs()
+	all := []string{oldRev, newRev, revWorktree, repo.Base(), repo.Head()}
+	for _, ref := range s.Refs {
+		if from, to, ok := splitRange(ref); ok {
+			all = append(all, orRev(from, repo.Head()), orRev(to, repo.Head()))
+		} else {
+			all = append(all, ref)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, rev := range all {
+		if seen[rev] {
+			continue
+		}
+		seen[rev] = true
+		label := revName(rev)
+		switch i {
+		case 0:
+			label += " (old side)"
+		case 1:
+			label += " (new side)"
+		}
+		revs = append(revs, rev)
+		labels = append(labels, label)
+	}
+	return revs, labels
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitRange(t *testing.T) {
+	cases := []struct {
+		ref, from, to string
+		ok            bool
+	}{
+		{"HEAD~3..HEAD", "HEAD~3", "HEAD", true},
+		{"main...feature", "main", "feature", true},
+		{"HEAD", "", "", false},
+	}
+	for _, c := range cases {
+		from, to, ok := splitRange(c.ref)
+		if from != c.from || to != c.to || ok != c.ok {
+			t.Errorf("splitRange(%q) = (%q, %q, %v), want (%q, %q, %v)", c.ref, from, to, ok, c.from, c.to, c.ok)
+		}
+	}
+}
+
+func TestSideRevs(t *testing.T) {
+	cases := []struct {
+		s        *State
+		old, new string
+	}{
+		{&State{}, revIndex, revWorktree},
+		{&State{Staged: true}, "HEAD", revIndex},
+		{&State{Refs: []string{"HEAD~1"}}, "HEAD~1", revWorktree},
+		{&State{Refs: []string{"HEAD~1"}, Staged: true}, "HEAD~1", revIndex},
+		{&State{Refs: []string{"main", "feature"}}, "main", "feature"},
+		{&State{Refs: []string{"HEAD~3..HEAD"}}, "HEAD~3", "HEAD"},
+	}
+	for _, c := range cases {
+		old, new := c.s.sideRevs()
+		if old != c.old || new != c.new {
+			t.Errorf("sideRevs(refs=%v staged=%v) = (%q, %q), want (%q, %q)", c.s.Refs, c.s.Staged, old, new, c.old, c.new)
+		}
+	}
+}
+
+func TestFileSidesPipeMode(t *testing.T) {
+	s := &State{PipeMode: true, Hunks: []Hunk{
+		{File: "a.go", Lines: []Line{
+			{Op: ' ', Content: "ctx"},
+			{Op: '-', Content: "old"},
+			{Op: '+', Content: "new"},
+		}},
+		{File: "b.go", Lines: []Line{{Op: '+', Content: "other"}}},
+	}}
+	oldText, newText := s.fileSides("a.go")
+	if oldText != "ctx\nold\n" {
+		t.Errorf("old side = %q, want %q", oldText, "ctx\nold\n")
+	}
+	if newText != "ctx\nnew\n" {
+		t.Errorf("new side = %q, want %q", newText, "ctx\nnew\n")
+	}
+}
+
+func TestAlignLines(t *testing.T) {
+	from := []string{"a", "b", "c", "d", "e"}
+	to := []string{"x", "a", "b", "d", "e", "f"}
+	align := alignLines(from, to, "")
+	want := []int{0, 2, 3, 0, 4, 5}
+	for i := 1; i < len(want); i++ {
+		if align[i] != want[i] {
+			t.Fatalf("alignLines = %v, want %v", align, want)
+		}
+	}
+	// A changed line lands just after the matched line above it
+	if got := alignedLine(align, 3); got != 4 {
+		t.Errorf("alignedLine(3) = %d, want 4", got)
+	}
+	// Past the end resumes after the last match
+	if got := alignedLine(align, 6); got != 6 {
+		t.Errorf("alignedLine(6) = %d, want 6", got)
+	}
+}
+
+func TestNoIndexDiff(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(a, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("one\n2\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !bothFiles([]string{a, b}) || bothFiles([]string{a, dir}) {
+		t.Fatal("bothFiles should accept two files and reject a directory")
+	}
+
+	s := &State{Refs: []string{a, b}, NoIndex: true, ContextLines: 3}
+	raw, err := s.runDiff()
+	if err != nil {
+		t.Fatalf("runDiff: %v", err)
+	}
+	hunks, err := s.parseHunks(raw)
+	if err != nil || len(hunks) != 1 {
+		t.Fatalf("parseHunks = %d hunks, %v; want 1", len(hunks), err)
+	}
+	if hunks[0].File != b {
+		t.Errorf("hunk file = %q, want %q", hunks[0].File, b)
+	}
+
+	s.Hunks = hunks
+	newLines, oldLines, ok := s.fullFileSides(b)
+	if !ok || strings.Join(newLines, ",") != "one,2,three" || strings.Join(oldLines, ",") != "one,two,three" {
+		t.Errorf("fullFileSides = %v, %v, %v", newLines, oldLines, ok)
+	}
+}
+
+func TestGitDiffArgs(t *testing.T) {
+	s := &State{Refs: []string{"HEAD~1"}, ContextLines: 5, DiffAlgorithm: algoPatience, FindRenames: "on", Staged: true}
+	got := strings.Join(s.gitDiffArgs(), " ")
+	want := "diff --no-color -U5 --diff-algorithm=patience --find-renames --staged --textconv --no-ext-diff HEAD~1"
+	if got != want {
+		t.Errorf("gitDiffArgs = %q, want %q", got, want)
+	}
+	s.FindRenames = "50%"
+	if got := s.gitDiffArgs(); got[4] != "--find-renames=50%" {
+		t.Errorf("threshold arg = %q", got[4])
+	}
+}
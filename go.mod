@@ -7,6 +7,8 @@ require (
 	github.com/bluekeyes/go-gitdiff v0.8.1
 	github.com/gdamore/tcell/v2 v2.13.8
 	github.com/radovskyb/watcher v1.0.7
+	github.com/smacker/go-tree-sitter v0.0.0-20240827094217-dd81d9e9be82
+	golang.org/x/term v0.39.0
 )
 
 require (
@@ -15,6 +17,5 @@ require (
 	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 )
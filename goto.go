@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startGoto asks for a place to jump to (:): a line of the current file,
+// file:line or a file. Tab completes the files of the diff.
+func startGoto(s *State) {
+	if len(s.Hunks) == 0 {
+		return
+	}
+	OpenPrompt(s, &InputPrompt{
+		Label:    ":",
+		History:  "goto",
+		Complete: completeDiffFiles,
+		OnSubmit: gotoInput,
+	})
+}
+
+// gotoInput jumps to the line, file:line or file of input.
+func gotoInput(s *State, input string) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return
+	}
+	file, lineText, hasLine := strings.Cut(input, ":")
+	if n, err := strconv.Atoi(input); err == nil {
+		file, lineText, hasLine = s.CurrentFile(), strconv.Itoa(n), true
+	}
+	line := 0
+	if hasLine {
+		n, err := strconv.Atoi(lineText)
+		if err != nil || n < 0 {
+			s.FlashMsg = "Go to: want a line, file:line or a file, got " + input
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+			return
+		}
+		line = n
+	}
+	s.JumpToLocation(file, line)
+}
+
+// completeDiffFiles completes input to the files of the diff whose path
+// contains it, followed by the colon a line number goes after.
+func completeDiffFiles(s *State, input string) []string {
+	if strings.Contains(input, ":") {
+		return nil
+	}
+	var out []string
+	for _, f := range s.orderedFiles() {
+		if strings.Contains(f, input) {
+			out = append(out, f+":")
+		}
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// helpLine is a row of the help pane: a group heading or a key binding.
+type helpLine struct {
+	Heading bool
+	Keys    string
+	Name    string
+}
+
+// helpLines returns the rows of the help pane: keyGroups followed by the
+// user commands of the config, keeping only the bindings whose keys or
+// description contain query (case-insensitively) and the headings of
+// groups with a match. A query matching a group name keeps the whole group.
+func helpLines(s *State, query string) []helpLine {
+	groups := keyGroups
+	if len(s.Config.Commands) > 0 {
+		user := KeyGroup{Name: "User commands"}
+		for _, uc := range s.Config.Commands {
+			user.Keys = append(user.Keys, KeyBinding{Keys: string(uc.Key), Name: uc.Command})
+		}
+		groups = append(groups[:len(groups):len(groups)], user)
+	}
+	query = strings.ToLower(query)
+	var lines []helpLine
+	for _, g := range groups {
+		groupMatch := strings.Contains(strings.ToLower(g.Name), query)
+		var keys []helpLine
+		for _, kb := range g.Keys {
+			if groupMatch || strings.Contains(strings.ToLower(kb.Keys+" "+kb.Name), query) {
+				keys = append(keys, helpLine{Keys: kb.Keys, Name: kb.Name})
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, helpLine{Heading: true})
+		}
+		lines = append(lines, helpLine{Heading: true, Name: g.Name})
+		lines = append(lines, keys...)
+	}
+	return lines
+}
+
+// helpRect returns the position and size of the help pane.
+func helpRect(s *State) (x0, y0, w, h int) {
+	w = min(s.Width-4, 80)
+	h = s.Height - 4
+	return (s.Width - w) / 2, (s.Height - h) / 2, max(w, 10), max(h, 4)
+}
+
+// helpRows returns how many lines fit in the help pane, between its title
+// and the search bar.
+func helpRows(s *State) int {
+	_, _, _, h := helpRect(s)
+	return h - 3
+}
+
+// scrollHelp moves the help pane by delta lines, within its content.
+func scrollHelp(s *State, delta int) {
+	s.HelpScroll += delta
+	if last := len(helpLines(s, s.HelpQuery)) - helpRows(s); s.HelpScroll > last {
+		s.HelpScroll = last
+	}
+	if s.HelpScroll < 0 {
+		s.HelpScroll = 0
+	}
+}
+
+// OpenHelp shows the help pane from the top, without a filter.
+func OpenHelp(s *State) {
+	s.ShowHelp = true
+	s.HelpScroll = 0
+	s.HelpQuery = ""
+	s.HelpSearch = false
+}
+
+// HandleHelpKey handles key input while the help pane is open: j/k and the
+// page keys scroll, / types a filter (Enter keeps it, Esc drops it), and
+// q, ? or Esc close the pane.
+func HandleHelpKey(s *State, ev *tcell.EventKey) {
+	if s.HelpSearch {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			s.HelpSearch = false
+		case tcell.KeyEscape:
+			s.HelpSearch = false
+			s.HelpQuery = ""
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if s.HelpQuery != "" {
+				_, size := utf8.DecodeLastRuneInString(s.HelpQuery)
+				s.HelpQuery = s.HelpQuery[:len(s.HelpQuery)-size]
+			}
+		case tcell.KeyRune:
+			s.HelpQuery += string(ev.Rune())
+		}
+		s.HelpScroll = 0
+		return
+	}
+	page := helpRows(s)
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		if s.HelpQuery != "" {
+			s.HelpQuery = ""
+			s.HelpScroll = 0
+		} else {
+			s.ShowHelp = false
+		}
+	case tcell.KeyDown:
+		scrollHelp(s, 1)
+	case tcell.KeyUp:
+		scrollHelp(s, -1)
+	case tcell.KeyCtrlD:
+		scrollHelp(s, page/2)
+	case tcell.KeyCtrlU:
+		scrollHelp(s, -page/2)
+	case tcell.KeyPgDn, tcell.KeyCtrlF:
+		scrollHelp(s, page)
+	case tcell.KeyPgUp, tcell.KeyCtrlB:
+		scrollHelp(s, -page)
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'j':
+			scrollHelp(s, 1)
+		case 'k':
+			scrollHelp(s, -1)
+		case 'd':
+			scrollHelp(s, page/2)
+		case 'u':
+			scrollHelp(s, -page/2)
+		case 'g':
+			s.HelpScroll = 0
+		case 'G':
+			scrollHelp(s, len(helpLines(s, s.HelpQuery)))
+		case '/':
+			s.HelpSearch = true
+			s.HelpQuery = ""
+			s.HelpScroll = 0
+		case 'q', '?':
+			s.ShowHelp = false
+		}
+	}
+}
+
+// drawHelpOverlay draws the help pane: the keymap in two columns under a
+// title, and a search bar or key hint at the bottom.
+func drawHelpOverlay(s *State) {
+	screen := s.Screen
+	x0, y0, w, h := helpRect(s)
+	border := s.Theme.Dim
+	body := s.Theme.Default
+
+	for row := y0; row < y0+h && row < s.Height; row++ {
+		for col := x0; col < x0+w && col < s.Width; col++ {
+			ch := ' '
+			style := body
+			switch {
+			case row == y0 && col == x0:
+				ch, style = '┌', border
+			case row == y0 && col == x0+w-1:
+				ch, style = '┐', border
+			case row == y0+h-1 && col == x0:
+				ch, style = '└', border
+			case row == y0+h-1 && col == x0+w-1:
+				ch, style = '┘', border
+			case row == y0 || row == y0+h-1:
+				ch, style = '─', border
+			case col == x0 || col == x0+w-1:
+				ch, style = '│', border
+			}
+			screen.SetContent(col, row, ch, nil, style)
+		}
+	}
+	drawText := func(col, row int, text string, style tcell.Style) int {
+		for _, r := range text {
+			if col >= x0+w-2 {
+				break
+			}
+			screen.SetContent(col, row, r, nil, style)
+			col++
+		}
+		return col
+	}
+	drawText(x0+2, y0, " wiff - keyboard shortcuts ", body.Bold(true))
+
+	lines := helpLines(s, s.HelpQuery)
+	rows := helpRows(s)
+	const keysW = 18
+	for i := 0; i < rows; i++ {
+		idx := s.HelpScroll + i
+		if idx >= len(lines) {
+			break
+		}
+		row := y0 + 1 + i
+		line := lines[idx]
+		if line.Heading {
+			drawText(x0+2, row, line.Name, body.Bold(true))
+			continue
+		}
+		drawText(x0+4, row, line.Keys, s.Theme.Label)
+		col := max(x0+4+keysW, x0+4+utf8.RuneCountInString(line.Keys)+2)
+		drawText(col, row, line.Name, body)
+	}
+	if len(lines) == 0 {
+		drawText(x0+2, y0+1, fmt.Sprintf("No keys match %q", s.HelpQuery), border)
+	}
+
+	// Search bar, or the filter in use and a hint
+	row := y0 + h - 2
+	switch {
+	case s.HelpSearch:
+		col := drawText(x0+2, row, "/"+s.HelpQuery, body)
+		if col < x0+w-2 {
+			screen.SetContent(col, row, '▏', nil, body)
+		}
+	case s.HelpQuery != "":
+		drawText(x0+2, row, fmt.Sprintf("/%s  (Esc clears, / searches again)", s.HelpQuery), border)
+	default:
+		drawText(x0+2, row, "j/k ^D/^U scroll  / search  q close", border)
+	}
+}
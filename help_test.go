@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestHelpListsEveryBinding(t *testing.T) {
+	s := &State{Config: Config{Commands: []UserCommand{{Key: 'X', Command: "make lint"}}}}
+	lines := helpLines(s, "")
+	var bindings int
+	for _, l := range lines {
+		if !l.Heading {
+			bindings++
+		}
+	}
+	if bindings != len(keyBindings)+1 {
+		t.Errorf("help pane shows %d bindings, keymap has %d plus a user command", bindings, len(keyBindings))
+	}
+	if last := lines[len(lines)-1]; last.Keys != "X" || last.Name != "make lint" {
+		t.Errorf("last line %+v, want the user command", last)
+	}
+}
+
+func TestHelpSearch(t *testing.T) {
+	s := &State{Width: 80, Height: 40}
+	HandleKey(s, makeKeyEvent('?'))
+	if !s.ShowHelp {
+		t.Fatal("? should open the help pane")
+	}
+	for _, r := range "/STAGE" {
+		HandleKey(s, makeKeyEvent(r))
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if s.HelpSearch || s.HelpQuery != "STAGE" {
+		t.Fatalf("after Enter: searching %v, query %q", s.HelpSearch, s.HelpQuery)
+	}
+	lines := helpLines(s, s.HelpQuery)
+	if len(lines) == 0 {
+		t.Fatal("no keys match stage")
+	}
+	for _, l := range lines {
+		if !l.Heading && !strings.Contains(strings.ToLower(l.Keys+l.Name), "stage") {
+			t.Errorf("%+v doesn't match stage", l)
+		}
+	}
+	// The whole Staging group matches by name
+	for _, g := range keyGroups {
+		if got := len(helpLines(s, "staging")); g.Name == "Staging" && got != len(g.Keys)+1 {
+			t.Errorf("staging shows %d lines, want the group's %d and its heading", got, len(g.Keys))
+		}
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if !s.ShowHelp || s.HelpQuery != "" {
+		t.Errorf("first Esc should drop the filter: shown %v, query %q", s.ShowHelp, s.HelpQuery)
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if s.ShowHelp {
+		t.Error("second Esc should close the pane")
+	}
+}
+
+func TestHelpScroll(t *testing.T) {
+	s := &State{Width: 80, Height: 20}
+	OpenHelp(s)
+	HandleKey(s, makeKeyEvent('j'))
+	if s.HelpScroll != 1 {
+		t.Errorf("j scrolls to %d", s.HelpScroll)
+	}
+	HandleKey(s, makeKeyEvent('G'))
+	if want := len(helpLines(s, "")) - helpRows(s); s.HelpScroll != want {
+		t.Errorf("G scrolls to %d, want %d", s.HelpScroll, want)
+	}
+	HandleKey(s, makeKeyEvent('g'))
+	HandleKey(s, makeKeyEvent('k'))
+	if s.HelpScroll != 0 {
+		t.Errorf("g then k scrolls to %d", s.HelpScroll)
+	}
+	HandleKey(s, makeKeyEvent('q'))
+	if s.ShowHelp {
+		t.Error("q should close the pane")
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/lexers"
@@ -25,6 +29,98 @@ type Highlighter struct {
 	lexers    map[string]chroma.Lexer // keyed by extension (e.g. ".go")
 	style     *chroma.Style
 	themeName string
+	backend   TokenBackend // nil uses the chroma lexers only
+
+	overrides []LangOverride          // config lang.<pattern> mappings, first match wins
+	fileLangs map[string]chroma.Lexer // per-file languages set at runtime
+
+	hits, misses atomic.Int64 // lexer cache lookups, for the debug overlay
+	version      int          // bumped whenever highlighting output may change
+}
+
+// LangOverride maps a filename glob (matched against the base name and the
+// full path) to a chroma lexer name.
+type LangOverride struct {
+	Pattern string
+	Lexer   string
+}
+
+// SetOverrides installs filename-pattern language mappings.
+func (h *Highlighter) SetOverrides(overrides []LangOverride) {
+	h.mu.Lock()
+	h.overrides = overrides
+	h.lexers = make(map[string]chroma.Lexer)
+	h.version++
+	h.mu.Unlock()
+}
+
+// SetFileLanguage forces the lexer used for one file. ok is false when the
+// language name is unknown.
+func (h *Highlighter) SetFileLanguage(filename, lang string) bool {
+	lex := lexers.Get(lang)
+	if lex == nil {
+		return false
+	}
+	h.mu.Lock()
+	if h.fileLangs == nil {
+		h.fileLangs = make(map[string]chroma.Lexer)
+	}
+	h.fileLangs[filename] = chroma.Coalesce(lex)
+	h.version++
+	h.mu.Unlock()
+	return true
+}
+
+// LanguageFor returns the name of the lexer used for filename, or "".
+func (h *Highlighter) LanguageFor(filename string) string {
+	if lex := h.lexerFor(filename); lex != nil {
+		return lex.Config().Name
+	}
+	return ""
+}
+
+// FenceLanguage returns the markdown code fence language for filename: the
+// lexer's first alias, or "" when no lexer matches.
+func (h *Highlighter) FenceLanguage(filename string) string {
+	lex := h.lexerFor(filename)
+	if lex == nil {
+		return ""
+	}
+	if cfg := lex.Config(); len(cfg.Aliases) > 0 {
+		return cfg.Aliases[0]
+	}
+	return strings.ToLower(lex.Config().Name)
+}
+
+// TokenBackend is an alternative tokenizer (e.g. tree-sitter) that emits
+// chroma token types, so theme colors and the StyledSpan output are shared
+// with the chroma path. ok is false for files the backend doesn't handle,
+// which then fall back to chroma.
+type TokenBackend interface {
+	Tokens(filename, text string) (tokens []chroma.Token, ok bool)
+}
+
+// tokenBackends lists the optional highlighter backends compiled into this
+// build, keyed by the name used in the highlighter config key.
+var tokenBackends = map[string]TokenBackend{}
+
+// SetBackend selects the highlighter backend by name. "" and "chroma"
+// select the built-in chroma lexers.
+func (h *Highlighter) SetBackend(name string) error {
+	h.version++
+	if name == "" || name == "chroma" {
+		h.backend = nil
+		return nil
+	}
+	b, ok := tokenBackends[name]
+	if !ok && name == "tree-sitter" {
+		return fmt.Errorf("highlighter %q needs a build with -tags treesitter", name)
+	}
+	if !ok {
+		return fmt.Errorf("highlighter %q is not available in this build", name)
+	}
+	h.backend = b
+	return nil
 }
 
 // NewHighlighter returns a ready-to-use Highlighter with the "monokai" theme.
@@ -45,9 +141,18 @@ func (h *Highlighter) SetTheme(name string) {
 	if s := styles.Get(name); s != nil {
 		h.style = s
 		h.themeName = name
+		h.version++
 	}
 }
 
+// Version changes whenever the spans Highlight returns may have changed
+// (theme, languages, backend), so callers can tell when to re-highlight.
+func (h *Highlighter) Version() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.version
+}
+
 // ThemeName returns the name of the active theme.
 func (h *Highlighter) ThemeName() string {
 	return h.themeName
@@ -61,6 +166,12 @@ func (h *Highlighter) Highlight(filename, text string) []StyledSpan {
 		return nil
 	}
 
+	if h.backend != nil {
+		if tokens, ok := h.backend.Tokens(filename, text); ok {
+			return h.spans(tokens)
+		}
+	}
+
 	lex := h.lexerFor(filename)
 	if lex == nil {
 		return []StyledSpan{{Text: text, Style: tcell.StyleDefault}}
@@ -70,9 +181,13 @@ func (h *Highlighter) Highlight(filename, text string) []StyledSpan {
 	if err != nil {
 		return []StyledSpan{{Text: text, Style: tcell.StyleDefault}}
 	}
+	return h.spans(iter.Tokens())
+}
 
+// spans converts tokens to styled spans using the active theme.
+func (h *Highlighter) spans(tokens []chroma.Token) []StyledSpan {
 	var spans []StyledSpan
-	for _, tok := range iter.Tokens() {
+	for _, tok := range tokens {
 		if tok.Value == "" {
 			continue
 		}
@@ -92,17 +207,34 @@ func (h *Highlighter) Highlight(filename, text string) []StyledSpan {
 // lexerFor returns a (possibly cached) lexer for the given filename.
 // Returns nil when no lexer matches.
 func (h *Highlighter) lexerFor(filename string) chroma.Lexer {
+	h.mu.RLock()
+	lex, ok := h.fileLangs[filename]
+	overrides := h.overrides
+	h.mu.RUnlock()
+	if ok {
+		return lex
+	}
+	for _, o := range overrides {
+		if matchLangPattern(o.Pattern, filename) {
+			if lex := lexers.Get(o.Lexer); lex != nil {
+				return chroma.Coalesce(lex)
+			}
+		}
+	}
+
 	ext := filepath.Ext(filename)
 	if ext == "" {
 		ext = filepath.Base(filename) // handle Makefile, Dockerfile, etc.
 	}
 
 	h.mu.RLock()
-	lex, ok := h.lexers[ext]
+	lex, ok = h.lexers[ext]
 	h.mu.RUnlock()
 	if ok {
+		h.hits.Add(1)
 		return lex // may be nil (negative cache)
 	}
+	h.misses.Add(1)
 
 	// Lookup and cache.
 	lex = lexers.Match(filename)
@@ -117,6 +249,21 @@ func (h *Highlighter) lexerFor(filename string) chroma.Lexer {
 	return lex
 }
 
+// CacheStats returns the lexer cache hits and misses so far.
+func (h *Highlighter) CacheStats() (hits, misses int64) {
+	return h.hits.Load(), h.misses.Load()
+}
+
+// matchLangPattern reports whether a lang.<pattern> glob matches filename,
+// trying the base name first and then the whole path.
+func matchLangPattern(pattern, filename string) bool {
+	if ok, _ := filepath.Match(pattern, filepath.Base(filename)); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filename)
+	return ok
+}
+
 // tokenStyle converts a chroma token type to a tcell style using the active theme.
 // Only foreground color is applied (no background) so diff coloring is preserved.
 func (h *Highlighter) tokenStyle(t chroma.TokenType) tcell.Style {
@@ -145,3 +292,34 @@ func (h *Highlighter) tokenStyle(t chroma.TokenType) tcell.Style {
 
 	return style
 }
+
+// openLanguagePicker lets the user choose the syntax language for the
+// current file for the rest of the session.
+func openLanguagePicker(s *State) {
+	file := s.CurrentFile()
+	if file == "" || s.HL == nil {
+		return
+	}
+	names := lexers.Names(false)
+	sort.Strings(names)
+	p := &Popup{
+		Title: "Language for " + filepath.Base(file),
+		Items: names,
+		OnSelect: func(s *State, idx int) {
+			if s.HL.SetFileLanguage(file, names[idx]) {
+				s.FlashMsg = "Highlighting " + file + " as " + names[idx]
+			} else {
+				s.FlashMsg = "Unknown language " + names[idx]
+			}
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+		},
+	}
+	current := s.HL.LanguageFor(file)
+	for i, n := range names {
+		if strings.EqualFold(n, current) {
+			p.Cursor = i
+		}
+	}
+	OpenPopup(s, p)
+	movePopupCursor(s, p, 0)
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/alecthomas/chroma/v2"
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -128,3 +129,60 @@ func TestHighlightCachesLexer(t *testing.T) {
 		t.Error("expected non-nil spans from second call")
 	}
 }
+
+type fakeBackend struct{}
+
+func (fakeBackend) Tokens(filename, text string) ([]chroma.Token, bool) {
+	if filename != "x.fake" {
+		return nil, false
+	}
+	return []chroma.Token{{Type: chroma.Keyword, Value: text}}, true
+}
+
+func TestSetBackend(t *testing.T) {
+	h := NewHighlighter()
+	if err := h.SetBackend("chroma"); err != nil {
+		t.Errorf("chroma backend: %v", err)
+	}
+	if err := h.SetBackend("nope"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+
+	tokenBackends["fake"] = fakeBackend{}
+	defer delete(tokenBackends, "fake")
+	if err := h.SetBackend("fake"); err != nil {
+		t.Fatalf("SetBackend(fake): %v", err)
+	}
+	spans := h.Highlight("x.fake", "anything")
+	if len(spans) != 1 || spans[0].Style != h.tokenStyle(chroma.Keyword) {
+		t.Errorf("backend spans = %+v", spans)
+	}
+	// Files the backend declines fall back to chroma
+	if spans := h.Highlight("main.go", "package main"); len(spans) < 2 {
+		t.Errorf("fallback spans = %+v", spans)
+	}
+}
+
+func TestLanguageOverrides(t *testing.T) {
+	h := NewHighlighter()
+	if got := h.LanguageFor("Jenkinsfile"); got == "Groovy" {
+		t.Fatalf("Jenkinsfile already detected as Groovy; pick another test file")
+	}
+	h.SetOverrides([]LangOverride{{Pattern: "Jenkinsfile", Lexer: "groovy"}, {Pattern: "*.gotmpl", Lexer: "go-template"}})
+	if got := h.LanguageFor("ci/Jenkinsfile"); got != "Groovy" {
+		t.Errorf("Jenkinsfile language = %q, want Groovy", got)
+	}
+	if got := h.LanguageFor("templates/page.gotmpl"); got == "" {
+		t.Error("*.gotmpl should match an override")
+	}
+
+	if !h.SetFileLanguage("notes.txt", "go") {
+		t.Fatal("SetFileLanguage(go) failed")
+	}
+	if got := h.LanguageFor("notes.txt"); got != "Go" {
+		t.Errorf("runtime language = %q, want Go", got)
+	}
+	if h.SetFileLanguage("notes.txt", "no-such-language") {
+		t.Error("unknown language should be rejected")
+	}
+}
@@ -0,0 +1,258 @@
+//go:build treesitter
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2"
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/bash"
+	"github.com/smacker/go-tree-sitter/c"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+// Built with -tags treesitter, highlighter = tree-sitter in the config
+// parses the whole file of a diff with tree-sitter, so that lines inside a
+// block comment or a multi-line string are colored as such, which chroma
+// can't know from the line alone.
+func init() {
+	tokenBackends["tree-sitter"] = &treeSitterBackend{files: make(map[string]*treeSitterFile)}
+}
+
+// treeSitterLanguages are the grammars built in, by file extension.
+var treeSitterLanguages = map[string]func() *sitter.Language{
+	".go":   golang.GetLanguage,
+	".py":   python.GetLanguage,
+	".js":   javascript.GetLanguage,
+	".mjs":  javascript.GetLanguage,
+	".jsx":  javascript.GetLanguage,
+	".ts":   typescript.GetLanguage,
+	".rs":   rust.GetLanguage,
+	".c":    c.GetLanguage,
+	".h":    c.GetLanguage,
+	".sh":   bash.GetLanguage,
+	".bash": bash.GetLanguage,
+}
+
+// treeSitterBackend highlights lines from the parse of the file they are
+// in, as it is in the working tree. Lines it doesn't find there (removed
+// ones, parts of wrapped or scrolled lines) and files without a grammar
+// are left to chroma.
+type treeSitterBackend struct {
+	mu    sync.Mutex
+	files map[string]*treeSitterFile
+}
+
+// treeSitterFile is the parse of a file: the tokens of each of its lines,
+// by the text of the line. A text found on lines colored differently maps
+// to nil, as it can't be told which one is meant.
+type treeSitterFile struct {
+	modTime time.Time
+	size    int64
+	lines   map[string][]chroma.Token
+}
+
+func (b *treeSitterBackend) Tokens(filename, text string) ([]chroma.Token, bool) {
+	lang := treeSitterLanguages[filepath.Ext(filename)]
+	if lang == nil {
+		return nil, false
+	}
+	path := filename
+	if root, err := repo.Root(); err == nil {
+		path = filepath.Join(root, filename)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f := b.files[path]
+	if f == nil || !f.modTime.Equal(fi.ModTime()) || f.size != fi.Size() {
+		f = &treeSitterFile{modTime: fi.ModTime(), size: fi.Size()}
+		if src, err := os.ReadFile(path); err == nil {
+			f.lines = parseTreeSitter(lang(), src)
+		} else {
+			logger.Warn("tree-sitter: reading the file failed", "file", path, "err", err)
+		}
+		b.files[path] = f
+	}
+	tokens := f.lines[text]
+	return tokens, tokens != nil
+}
+
+// parseTreeSitter parses src and returns the tokens of each line.
+func parseTreeSitter(lang *sitter.Language, src []byte) map[string][]chroma.Token {
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(lang)
+	tree, err := parser.ParseCtx(context.Background(), nil, src)
+	if err != nil {
+		logger.Warn("tree-sitter: parsing failed", "err", err)
+		return nil
+	}
+	defer tree.Close()
+
+	// The token type of every byte, Text where no node says otherwise
+	types := make([]chroma.TokenType, len(src))
+	for i := range types {
+		types[i] = chroma.Text
+	}
+	fill := func(n *sitter.Node, t chroma.TokenType) {
+		for i := int(n.StartByte()); i < int(n.EndByte()) && i < len(types); i++ {
+			types[i] = t
+		}
+	}
+	var walk func(n *sitter.Node, inString bool)
+	walk = func(n *sitter.Node, inString bool) {
+		typ := n.Type()
+		switch {
+		case inString && typ == "escape_sequence":
+			fill(n, chroma.LiteralStringEscape)
+			return
+		case inString && !n.IsNamed():
+			return // quotes, colored with the string
+		case inString && strings.Contains(typ, "string"):
+			for i := 0; i < int(n.ChildCount()); i++ {
+				walk(n.Child(i), true) // escapes in the text of the string
+			}
+			return
+		case isStringNode(typ):
+			// The text between the quotes is often no node at all
+			fill(n, chroma.LiteralString)
+			for i := 0; i < int(n.ChildCount()); i++ {
+				walk(n.Child(i), true)
+			}
+			return
+		}
+		if t, ok := treeSitterToken(n); ok {
+			fill(n, t)
+			return
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			walk(n.Child(i), false)
+		}
+	}
+	walk(tree.RootNode(), false)
+
+	lines := make(map[string][]chroma.Token)
+	for start := 0; start <= len(src); {
+		end := start + bytes.IndexByte(src[start:], '\n')
+		if end < start {
+			end = len(src)
+		}
+		text := strings.TrimSuffix(string(src[start:end]), "\r")
+		var tokens []chroma.Token
+		for i := start; i < start+len(text); {
+			j := i + 1
+			for j < start+len(text) && types[j] == types[i] {
+				j++
+			}
+			tokens = append(tokens, chroma.Token{Type: types[i], Value: string(src[i:j])})
+			i = j
+		}
+		if seen, ok := lines[text]; !ok {
+			lines[text] = tokens
+		} else if seen != nil && !sameTokens(seen, tokens) {
+			lines[text] = nil
+		}
+		start = end + 1
+	}
+	delete(lines, "")
+	return lines
+}
+
+// treeSitterToken returns the token type a node outside strings is colored
+// with as a whole, or ok false for a node whose children are looked at
+// instead.
+func treeSitterToken(n *sitter.Node) (chroma.TokenType, bool) {
+	typ := n.Type()
+	switch {
+	case strings.Contains(typ, "comment"):
+		return chroma.Comment, true
+	case n.ChildCount() > 0:
+		return 0, false
+	case strings.Contains(typ, "int") && strings.Contains(typ, "literal"),
+		strings.Contains(typ, "float"), typ == "number", typ == "integer", typ == "imaginary_literal":
+		return chroma.LiteralNumber, true
+	case typ == "true" || typ == "false" || typ == "nil" || typ == "null" || typ == "none" || typ == "None" || typ == "undefined":
+		return chroma.KeywordConstant, true
+	case typ == "type_identifier" || typ == "primitive_type":
+		return chroma.KeywordType, true
+	case typ == "field_identifier" || typ == "property_identifier":
+		return chroma.NameAttribute, true
+	case strings.HasSuffix(typ, "identifier"):
+		if isFunctionName(n) {
+			return chroma.NameFunction, true
+		}
+		return chroma.Name, true
+	case !n.IsNamed() && isWordToken(typ):
+		return chroma.Keyword, true
+	case !n.IsNamed() && strings.ContainsAny(typ, "+-*/%=<>!&|^~:?"):
+		return chroma.Operator, true
+	case !n.IsNamed() && strings.TrimSpace(typ) != "":
+		return chroma.Punctuation, true
+	}
+	return 0, false
+}
+
+// isStringNode reports whether a node of type typ is a string or character
+// literal, whose escapes and interpolations are its only children colored
+// differently.
+func isStringNode(typ string) bool {
+	return strings.Contains(typ, "string") || strings.HasSuffix(typ, "char_literal") || typ == "rune_literal"
+}
+
+// isFunctionName reports whether n names the function a declaration
+// declares or a call calls.
+func isFunctionName(n *sitter.Node) bool {
+	p := n.Parent()
+	if p == nil {
+		return false
+	}
+	typ := p.Type()
+	switch {
+	case strings.Contains(typ, "function") || strings.Contains(typ, "method"):
+		name := p.ChildByFieldName("name")
+		return name != nil && name.Equal(n)
+	case strings.Contains(typ, "call"):
+		fn := p.ChildByFieldName("function")
+		return fn != nil && fn.Equal(n)
+	}
+	return false
+}
+
+// isWordToken reports whether an anonymous node is a keyword, spelled
+// with letters only.
+func isWordToken(typ string) bool {
+	for _, r := range typ {
+		if !unicode.IsLetter(r) && r != '_' {
+			return false
+		}
+	}
+	return typ != ""
+}
+
+func sameTokens(a, b []chroma.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+//go:build treesitter
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2"
+)
+
+func TestTreeSitterWholeFile(t *testing.T) {
+	src := "package main\n\n/*\nfunc inComment() {}\n*/\nfunc f() string {\n\treturn `raw\nreturn x` + \"a\\n\"\n}\n"
+	lines := parseTreeSitter(treeSitterLanguages[".go"](), []byte(src))
+
+	// A line of a block comment is a comment, not code
+	if got := lines["func inComment() {}"]; len(got) != 1 || got[0].Type != chroma.Comment {
+		t.Errorf("line in a block comment = %v", got)
+	}
+	// So is the second line of a raw string
+	if got := lines["return x` + \"a\\n\""]; len(got) < 4 || got[0].Type != chroma.LiteralString ||
+		got[0].Value != "return x`" {
+		t.Errorf("end of a raw string = %v", got)
+	}
+	want := []chroma.Token{
+		{Type: chroma.Keyword, Value: "func"},
+		{Type: chroma.Text, Value: " "},
+		{Type: chroma.NameFunction, Value: "f"},
+		{Type: chroma.Punctuation, Value: "()"},
+		{Type: chroma.Text, Value: " "},
+		{Type: chroma.KeywordType, Value: "string"},
+		{Type: chroma.Text, Value: " "},
+		{Type: chroma.Punctuation, Value: "{"},
+	}
+	if got := lines["func f() string {"]; !sameTokens(got, want) {
+		t.Errorf("declaration = %v", got)
+	}
+}
+
+func TestTreeSitterBackend(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\n/*\nx := 1\n*/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHighlighter()
+	if err := h.SetBackend("tree-sitter"); err != nil {
+		t.Fatal(err)
+	}
+	spans := h.Highlight("a.go", "x := 1")
+	if len(spans) != 1 || spans[0].Style != h.tokenStyle(chroma.Comment) {
+		t.Errorf("line of a block comment = %+v", spans)
+	}
+	// A line not in the file (removed) is left to chroma
+	if spans := h.Highlight("a.go", "y := 2"); len(spans) < 2 {
+		t.Errorf("removed line = %+v", spans)
+	}
+}
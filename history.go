@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// historyCommits is how many commits the line history lists.
+const historyCommits = 15
+
+// historyRange returns the file and lines of the old side of hunk h: what
+// the history of the hunk is the history of. A hunk that only adds lines
+// has none; the line it follows stands in for them.
+func historyRange(h *Hunk) (file string, start, end int) {
+	file = h.File
+	if h.OldFile != "" {
+		file = h.OldFile
+	}
+	n := 0
+	for _, l := range h.Lines {
+		if l.Op != '+' {
+			n++
+		}
+	}
+	start = max(h.OldStart, 1)
+	return file, start, start + max(n, 1) - 1
+}
+
+// openLineHistory lists the last commits that touched the old lines of the
+// current hunk, from the old side of the diff back. Selecting one opens
+// it in a new tab.
+func openLineHistory(s *State) {
+	if len(s.Hunks) == 0 {
+		return
+	}
+	if s.PipeMode || s.NoIndex {
+		s.FlashMsg = "No history for files outside a repository"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	h := &s.Hunks[s.CurrentHunkIndex()]
+	if h.Status == 'A' {
+		s.FlashMsg = "New file: no history before this change"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	oldRev, _ := s.sideRevs()
+	if oldRev == revIndex {
+		oldRev = repo.Head() // history starts at the last commit
+	}
+	file, start, end := historyRange(h)
+	commits, err := repo.LineLog(oldRev, file, start, end, historyCommits)
+	if err != nil {
+		s.FlashMsg = "History: " + err.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	if len(commits) == 0 {
+		s.FlashMsg = fmt.Sprintf("No commits touched %s:%d-%d", file, start, end)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	items := make([]string, len(commits))
+	for i, c := range commits {
+		items[i] = fmt.Sprintf("%s  %s, %s  %s", c.Rev, c.Author, c.Date, c.Subject)
+	}
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("History of %s:%d-%d", file, start, end),
+		Items: items,
+		OnSelect: func(s *State, i int) {
+			rev := commits[i].Rev
+			if err := OpenTab(s, repo.Parent(rev)+" "+rev); err != nil {
+				s.FlashMsg = err.Error()
+				s.FlashExpiry = time.Now().Add(3 * time.Second)
+			}
+		},
+	})
+}
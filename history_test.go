@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestHistoryRange(t *testing.T) {
+	tests := []struct {
+		hunk       Hunk
+		start, end int
+	}{
+		{Hunk{File: "a", OldStart: 10, Lines: []Line{{Op: ' '}, {Op: '-'}, {Op: '+'}, {Op: ' '}}}, 10, 12},
+		{Hunk{File: "a", OldStart: 5, Lines: []Line{{Op: '+'}, {Op: '+'}}}, 5, 5},
+		{Hunk{File: "a", OldStart: 0, Lines: []Line{{Op: '+'}}}, 1, 1},
+	}
+	for _, tt := range tests {
+		if _, start, end := historyRange(&tt.hunk); start != tt.start || end != tt.end {
+			t.Errorf("%v: %d-%d, want %d-%d", tt.hunk.Lines, start, end, tt.start, tt.end)
+		}
+	}
+	if file, _, _ := historyRange(&Hunk{File: "new", OldFile: "old"}); file != "old" {
+		t.Errorf("renamed file's history follows %q", file)
+	}
+}
+
+func TestLineHistory(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	write := func(text string) {
+		t.Helper()
+		if err := os.WriteFile("a.txt", []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("one\ntwo\nthree\nfour\n")
+	git("add", ".")
+	git("commit", "-qm", "Add a")
+	write("one\n2\nthree\nfour\n")
+	git("commit", "-qam", "Change two")
+	write("one\n2\nthree\nFOUR\n")
+	git("commit", "-qam", "Change four")
+	write("one\n2\nthree\nFOUR!\n")
+
+	s := &State{Width: 80, Height: 24, ContextLines: 3}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	openLineHistory(s)
+	if s.Popup == nil {
+		t.Fatalf("no history popup: %q", s.FlashMsg)
+	}
+	// The hunk spans lines 1-4 of the index; all three commits touched them
+	if len(s.Popup.Items) != 3 || !strings.Contains(s.Popup.Items[0], "Ann") || !strings.HasSuffix(s.Popup.Items[0], "Change four") {
+		t.Errorf("history %q", s.Popup.Items)
+	}
+
+	s.Hunks[0].OldStart, s.Hunks[0].Lines = 2, []Line{{Op: '-', Content: "2"}, {Op: '+', Content: "two"}}
+	openLineHistory(s)
+	if len(s.Popup.Items) != 2 || !strings.HasSuffix(s.Popup.Items[0], "Change two") {
+		t.Errorf("history of line 2: %q", s.Popup.Items)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hunkMenuItem is an entry of the hunk menu: what it does, the keys that
+// do the same without the menu, and the action.
+type hunkMenuItem struct {
+	Name string
+	Keys string
+	Run  func(s *State, h *Hunk)
+}
+
+// hunkMenu returns the actions that apply to h in this diff.
+func hunkMenu(s *State, h *Hunk) []hunkMenuItem {
+	action := func(cmd rune) func(s *State, h *Hunk) {
+		return func(s *State, h *Hunk) { runHunkAction(s, cmd, h) }
+	}
+	items := []hunkMenuItem{
+		{"Yank the added lines", "y" + h.Label, action('y')},
+		{"Yank the removed lines", "Y" + h.Label, action('Y')},
+		{"Yank as a patch", "p" + h.Label, action('p')},
+		{"Copy the result (new code)", "c" + h.Label, action('c')},
+		{"Copy as markdown", "M" + h.Label, action('M')},
+	}
+	if _, git := repo.(gitVCS); git && !s.NoIndex && !s.PipeMode {
+		name := "Stage the hunk"
+		if h.Staged {
+			name = "Unstage the hunk"
+		}
+		items = append(items, hunkMenuItem{name, "A" + h.Label, action('A')})
+	}
+	if canApplyToWorktree(s) {
+		name := "Apply to the working tree"
+		if h.Applied {
+			name = "Revert in the working tree"
+		}
+		items = append(items, hunkMenuItem{name, "a" + h.Label, action('a')})
+	}
+	if worktreeDiff(s) && !h.Staged {
+		items = append(items, hunkMenuItem{"Discard from the working tree", "", confirmDiscardHunk})
+		if _, git := repo.(gitVCS); git {
+			items = append(items, hunkMenuItem{"Edit as a patch and stage it", "", editHunkPatch})
+		}
+	}
+	return append(items,
+		hunkMenuItem{"Pipe through a command", "|" + h.Label, action('|')},
+		hunkMenuItem{"Open in $EDITOR", "o", func(s *State, h *Hunk) {
+			openFile(s, h.File, firstChangeLine(h))
+		}},
+		hunkMenuItem{"Copy the file path", "", copyHunkPath},
+	)
+}
+
+// openHunkMenu lists the actions on hunk in a popup, each with the keys
+// that run it directly.
+func openHunkMenu(s *State, hunk *Hunk) {
+	menu := hunkMenu(s, hunk)
+	width := 0
+	for _, it := range menu {
+		width = max(width, len(it.Name))
+	}
+	items := make([]string, len(menu))
+	for i, it := range menu {
+		items[i] = fmt.Sprintf("%-*s  %s", width, it.Name, it.Keys)
+	}
+	label := hunk.Label
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("Hunk %s of %s", label, hunk.File),
+		Items: items,
+		OnSelect: func(s *State, idx int) {
+			// The hunk is looked up again, as the menu may outlive a reload
+			if h := s.HunkByLabel(label); h != nil {
+				menu[idx].Run(s, h)
+			}
+		},
+	})
+}
+
+// openCurrentHunkMenu opens the hunk menu of the current hunk.
+func openCurrentHunkMenu(s *State) {
+	if len(s.Hunks) == 0 {
+		s.FlashMsg = "No hunks"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	openHunkMenu(s, &s.Hunks[s.CurrentHunkIndex()])
+}
+
+// worktreeDiff reports whether the diff is of the working tree against the
+// index (or the working copy of jj and hg), so its hunks can be discarded.
+func worktreeDiff(s *State) bool {
+	return !s.NoIndex && !s.PipeMode && !s.Staged && len(s.Refs) == 0
+}
+
+// confirmDiscardHunk asks before reverting hunk in the working tree, as
+// the change is lost.
+func confirmDiscardHunk(s *State, hunk *Hunk) {
+	label := hunk.Label
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("Discard hunk %s of %s? The change is lost", label, hunk.File),
+		Items: []string{"Discard", "Keep"},
+		OnSelect: func(s *State, idx int) {
+			if h := s.HunkByLabel(label); idx == 0 && h != nil {
+				discardHunk(s, h)
+			}
+		},
+	})
+}
+
+// discardHunk reverts hunk in the working tree and reloads the diff.
+func discardHunk(s *State, hunk *Hunk) {
+	if textconvRefused(s, hunk) || staleRefused(s, hunk) {
+		return
+	}
+	if out, err := repo.Apply(hunk.AsFullPatch(), true); err != nil {
+		logger.Warn("discard failed", "file", hunk.File, "hunk", hunk.Label, "err", err, "output", string(out))
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		s.FlashMsg = fmt.Sprintf("Discard failed for hunk %s: %s", hunk.Label, firstLine(msg))
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.FlashMsg = fmt.Sprintf("Discarded hunk %s", hunk.Label)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	reloadDiff(s)
+}
+
+// editHunkPatch opens the patch of hunk in $EDITOR and stages what is left
+// of it, like the e of git add -p. Emptying the file stages nothing.
+func editHunkPatch(s *State, hunk *Hunk) {
+	if stageRefused(s, hunk) || staleRefused(s, hunk) {
+		return
+	}
+	f, err := os.CreateTemp("", "wiff-hunk-*.patch")
+	if err != nil {
+		s.FlashMsg = "Edit: " + err.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(hunk.AsFullPatch())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = runSuspended(s, []string{editorCommand(), f.Name()})
+	}
+	var patch []byte
+	if err == nil {
+		patch, err = os.ReadFile(f.Name())
+	}
+	if err != nil {
+		s.FlashMsg = "Edit: " + err.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	if strings.TrimSpace(string(patch)) == "" {
+		s.FlashMsg = "Empty patch, nothing staged"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	// --recount lets the edit add and drop lines without fixing the header
+	cmd := exec.Command("git", "apply", "--cached", "--recount")
+	if root, err := repo.Root(); err == nil {
+		cmd.Dir = root
+	}
+	cmd.Stdin = strings.NewReader(string(patch))
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
+		logger.Warn("staging the edited patch failed", "file", hunk.File, "hunk", hunk.Label, "err", err, "output", string(out))
+		s.FlashMsg = fmt.Sprintf("Edited patch of hunk %s does not apply: %s", hunk.Label, firstLine(strings.TrimSpace(string(out))))
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.FlashMsg = fmt.Sprintf("Staged the edited hunk %s", hunk.Label)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	reloadDiff(s)
+}
+
+// copyHunkPath copies the path of hunk's file, relative to the repository
+// root.
+func copyHunkPath(s *State, hunk *Hunk) {
+	copyText(s, hunk.File, "Copied "+hunk.File)
+}
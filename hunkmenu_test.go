@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func menuNames(s *State, h *Hunk) []string {
+	var names []string
+	for _, it := range hunkMenu(s, h) {
+		names = append(names, it.Name)
+	}
+	return names
+}
+
+func TestHunkMenuFitsTheDiff(t *testing.T) {
+	h := &Hunk{Label: "a", File: "a.go", Lines: []Line{{Op: '+', Content: "x"}}}
+	names := strings.Join(menuNames(&State{}, h), "|")
+	for _, want := range []string{"Yank the added lines", "Stage the hunk", "Discard from the working tree", "Edit as a patch", "Copy the file path"} {
+		if !strings.Contains(names, want) {
+			t.Errorf("unstaged diff menu lacks %q: %s", want, names)
+		}
+	}
+	h.Staged = true
+	if names := strings.Join(menuNames(&State{}, h), "|"); !strings.Contains(names, "Unstage the hunk") || strings.Contains(names, "Discard") {
+		t.Errorf("staged hunk menu: %s", names)
+	}
+	names = strings.Join(menuNames(&State{PipeMode: true}, h), "|")
+	if strings.Contains(names, "Stage") || strings.Contains(names, "Discard") || !strings.Contains(names, "Apply to the working tree") {
+		t.Errorf("piped diff menu: %s", names)
+	}
+}
+
+func TestHunkMenuKeysAndRightClick(t *testing.T) {
+	s := &State{Width: 80, Height: 20, ContextLines: 3, Hunks: []Hunk{
+		{Label: "a", File: "a.go", Header: "@@ -1 +1 @@", OldStart: 1, NewStart: 1,
+			Lines: []Line{{Op: '-', Content: "x"}, {Op: '+', Content: "y"}}},
+	}}
+	s.BuildLines()
+	HandleKey(s, makeKeyEvent(','))
+	if s.Popup == nil || s.Popup.Title != "Hunk a of a.go" || !strings.HasSuffix(s.Popup.Items[0], "ya") {
+		t.Fatalf("popup = %+v", s.Popup)
+	}
+	ClosePopup(s)
+	for i, l := range s.Lines {
+		if l.Style == StyleHunkHeader {
+			HandleDiffRightClick(s, 10, i-s.Scroll)
+		}
+	}
+	if s.Popup == nil || s.Popup.Title != "Hunk a of a.go" {
+		t.Errorf("right-click on the header: %+v", s.Popup)
+	}
+}
+
+func TestDiscardAndEditHunk(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+		return string(out)
+	}
+	body := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n"
+	if err := os.WriteFile("a.txt", []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "Add a")
+	edited := strings.Replace(strings.Replace(body, "2\n", "two\n", 1), "19\n", "nineteen\n", 1)
+	if err := os.WriteFile("a.txt", []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	s := &State{Screen: sim, Width: 80, Height: 24, ContextLines: 3}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Hunks) != 2 {
+		t.Fatalf("%d hunks, want 2", len(s.Hunks))
+	}
+
+	confirmDiscardHunk(s, &s.Hunks[0])
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if got, _ := os.ReadFile("a.txt"); strings.Contains(string(got), "two") || !strings.Contains(string(got), "nineteen") {
+		t.Fatalf("after discarding the first hunk:\n%s", got)
+	}
+	if len(s.Hunks) != 1 {
+		t.Fatalf("%d hunks after the reload, want 1", len(s.Hunks))
+	}
+
+	t.Setenv("EDITOR", "true") // keep the patch as it is
+	editHunkPatch(s, &s.Hunks[0])
+	if !strings.Contains(git("diff", "--cached"), "+nineteen") {
+		t.Errorf("edited hunk not staged (%q)", s.FlashMsg)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Sizes of an exported diff image, in pixels: a window with a title bar
+// and rows of monospace text, like carbon.now.sh.
+const (
+	imageFontSize = 14
+	imageCharW    = 8.4 // advance of a 14px monospace glyph
+	imageRowH     = 20
+	imagePad      = 20
+	imageTitleH   = 40
+)
+
+// imageRow is one row of an exported diff: a hunk header or a diff line.
+type imageRow struct {
+	header bool
+	op     rune
+	lineNo int
+	text   string
+}
+
+// imageRows returns the rows of hunks: each header, then its lines
+// numbered on the new side (the old side for removed lines).
+func imageRows(hunks []*Hunk) []imageRow {
+	var rows []imageRow
+	for _, h := range hunks {
+		rows = append(rows, imageRow{header: true, text: h.Header})
+		oldNo, newNo := h.OldStart, h.NewStart
+		for _, l := range h.Lines {
+			row := imageRow{op: l.Op, text: strings.ReplaceAll(l.Content, "\t", "    ")}
+			switch l.Op {
+			case '-':
+				row.lineNo = oldNo
+				oldNo++
+			case '+':
+				row.lineNo = newNo
+				newNo++
+			default:
+				row.lineNo = newNo
+				oldNo++
+				newNo++
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// svgColor returns c as an SVG color, or fallback when c has no RGB value
+// (the terminal's default color).
+func svgColor(c tcell.Color, fallback string) string {
+	r, g, b := c.RGB()
+	if r < 0 {
+		return fallback
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// diffSVG renders hunks of file as an SVG image in the active theme: a
+// window titled with the file name, syntax-highlighted lines with tinted
+// backgrounds for added and removed ones, and line numbers.
+func diffSVG(s *State, file string, hunks []*Hunk) []byte {
+	rows := imageRows(hunks)
+	fg, bg, _ := s.Theme.Default.Decompose()
+	bgColor := svgColor(bg, "#282a36")
+	fgColor := svgColor(fg, "#f8f8f2")
+	dimFg, _, _ := s.Theme.Dim.Decompose()
+	dimColor := svgColor(dimFg, "#6272a4")
+	hunkFg, _, _ := s.Theme.HunkHeader.Decompose()
+	addedBg := svgColor(s.Theme.BgAdded, "#1e3a24")
+	removedBg := svgColor(s.Theme.BgRemoved, "#3f1f24")
+	addedFg := svgColor(s.Theme.Added, "#50fa7b")
+	removedFg := svgColor(s.Theme.Removed, "#ff5555")
+
+	numW := len(fmt.Sprint(maxLineNo(rows)))
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len([]rune(r.text)))
+	}
+	textX := float64(imagePad) + float64(numW+3)*imageCharW
+	width := int(textX+float64(cols)*imageCharW) + imagePad
+	width = max(width, 400)
+	height := imageTitleH + len(rows)*imageRowH + imagePad
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" rx="10" fill="%s"/>`+"\n", width, height, bgColor)
+	for i, c := range []string{"#ff5f56", "#ffbd2e", "#27c93f"} {
+		fmt.Fprintf(&b, `<circle cx="%d" cy="20" r="6" fill="%s"/>`+"\n", imagePad+i*20, c)
+	}
+	fmt.Fprintf(&b, `<g font-family="ui-monospace,SFMono-Regular,Menlo,Consolas,monospace" font-size="%d" xml:space="preserve">`+"\n", imageFontSize)
+	fmt.Fprintf(&b, `<text x="%d" y="25" fill="%s" text-anchor="middle">%s</text>`+"\n", width/2, dimColor, html.EscapeString(file))
+	for i, r := range rows {
+		top := imageTitleH + i*imageRowH
+		y := top + imageRowH - 6
+		if r.header {
+			fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", imagePad, y, svgColor(hunkFg, dimColor), html.EscapeString(r.text))
+			continue
+		}
+		opColor := dimColor
+		switch r.op {
+		case '+':
+			fmt.Fprintf(&b, `<rect x="0" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", top, width, imageRowH, addedBg)
+			opColor = addedFg
+		case '-':
+			fmt.Fprintf(&b, `<rect x="0" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", top, width, imageRowH, removedBg)
+			opColor = removedFg
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%*d</text>`, imagePad, y, dimColor, numW, r.lineNo)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s">%c</text>`, float64(imagePad)+float64(numW+1)*imageCharW, y, opColor, r.op)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="%s">`, textX, y, fgColor)
+		for _, span := range s.imageSpans(file, r.text) {
+			spanFg, _, attrs := span.Style.Decompose()
+			fmt.Fprintf(&b, `<tspan fill="%s"`, svgColor(spanFg, fgColor))
+			if attrs&tcell.AttrBold != 0 {
+				b.WriteString(` font-weight="bold"`)
+			}
+			if attrs&tcell.AttrItalic != 0 {
+				b.WriteString(` font-style="italic"`)
+			}
+			fmt.Fprintf(&b, ">%s</tspan>", html.EscapeString(span.Text))
+		}
+		b.WriteString("</text>\n")
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.Bytes()
+}
+
+// imageSpans returns the highlighted spans of a line, or the plain line
+// when highlighting is off.
+func (s *State) imageSpans(file, text string) []StyledSpan {
+	if s.SyntaxHighlight && s.HL != nil && s.highlightable(text) {
+		return s.HL.Highlight(file, text)
+	}
+	return []StyledSpan{{Text: text, Style: tcell.StyleDefault}}
+}
+
+func maxLineNo(rows []imageRow) int {
+	n := 1
+	for _, r := range rows {
+		n = max(n, r.lineNo)
+	}
+	return n
+}
+
+// svgToPNG converts an SVG file to a PNG at twice its size, with
+// rsvg-convert (librsvg) or ImageMagick, whichever is installed.
+func svgToPNG(svgPath, pngPath string) error {
+	var cmd *exec.Cmd
+	switch {
+	case commandExists("rsvg-convert"):
+		cmd = exec.Command("rsvg-convert", "--zoom=2", "--output", pngPath, svgPath)
+	case commandExists("magick"):
+		cmd = exec.Command("magick", "-density", "192", svgPath, pngPath)
+	default:
+		return errors.New("PNG export needs rsvg-convert (librsvg) or ImageMagick")
+	}
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
+		return fmt.Errorf("%s: %v %s", cmd.Args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// exportImage writes the diff of hunks to a new image named after name in
+// the temporary directory, as PNG when png is set and SVG otherwise, and
+// returns its path.
+func exportImage(s *State, file, name string, hunks []*Hunk, png bool) (string, error) {
+	svgPath, err := writeTemp(name+"-*.svg", diffSVG(s, file, hunks))
+	if err != nil || !png {
+		return svgPath, err
+	}
+	defer os.Remove(svgPath)
+	// Created first, so the converter writes to a file of ours
+	pngPath, err := writeTemp(name+"-*.png", nil)
+	if err != nil {
+		return "", err
+	}
+	if err := svgToPNG(svgPath, pngPath); err != nil {
+		os.Remove(pngPath)
+		return "", err
+	}
+	return pngPath, nil
+}
+
+// writeTemp writes data to a new file in the temporary directory named
+// after pattern, as os.CreateTemp takes it, and returns its path.
+func writeTemp(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// openImageExport offers to export the current hunk or the diff of its
+// file as an SVG or PNG image.
+func openImageExport(s *State) {
+	if len(s.Hunks) == 0 {
+		s.FlashMsg = "No hunks to export"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	hunk := &s.Hunks[s.CurrentHunkIndex()]
+	label, file := hunk.Label, hunk.File
+	base := "wiff-" + strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	OpenPopup(s, &Popup{
+		Title: "Export an image of",
+		Items: []string{
+			"hunk " + label + " as SVG",
+			"hunk " + label + " as PNG",
+			file + " as SVG",
+			file + " as PNG",
+		},
+		OnSelect: func(s *State, idx int) {
+			hunks, name := s.fileHunks(file), base
+			if idx < 2 {
+				hunks, name = []*Hunk{hunk}, base+"-"+label
+			}
+			path, err := exportImage(s, file, name, hunks, idx%2 == 1)
+			if err != nil {
+				logger.Warn("image export failed", "file", file, "err", err)
+				s.FlashMsg = "Export: " + err.Error()
+			} else {
+				s.FlashMsg = "Saved " + path
+			}
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+		},
+	})
+}
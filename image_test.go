@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestDiffSVG(t *testing.T) {
+	s := &State{Theme: NewUITheme("dracula"), HL: NewHighlighter(), SyntaxHighlight: true}
+	s.HL.SetTheme("dracula")
+	h := &Hunk{File: "a.go", Header: "@@ -9,2 +9,2 @@ func f()", OldStart: 9, NewStart: 9,
+		Lines: []Line{{Op: ' ', Content: "\tx := 1"}, {Op: '-', Content: "if a < b {"}, {Op: '+', Content: "if a <= b && c {"}}}
+	svg := diffSVG(s, "a.go", []*Hunk{h})
+
+	dec := xml.NewDecoder(strings.NewReader(string(svg)))
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("invalid SVG: %v\n%s", err, svg)
+			}
+			break
+		}
+	}
+	for _, want := range []string{"a.go", "@@ -9,2 +9,2 @@ func f()", "&lt;=", "&amp;&amp;", ">10<"} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("SVG lacks %q", want)
+		}
+	}
+	if strings.Contains(string(svg), "\t") {
+		t.Error("tabs should be expanded")
+	}
+	// One tinted row each for the added and the removed line
+	if n := strings.Count(string(svg), `<rect x="0"`); n != 2 {
+		t.Errorf("%d tinted rows, want 2", n)
+	}
+}
+
+func TestImageExport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	s := &State{Width: 80, Height: 20, Theme: NewUITheme("dracula"),
+		Hunks: []Hunk{{Label: "a", File: "src/main.go", Header: "@@ -1 +1 @@", OldStart: 1, NewStart: 1,
+			Lines: []Line{{Op: '+', Content: "x"}}}}}
+	s.BuildLines()
+
+	HandleKey(s, makeKeyEvent('g'))
+	HandleKey(s, makeKeyEvent('E'))
+	if s.Popup == nil {
+		t.Fatal("gE should open the export picker")
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	paths, _ := filepath.Glob(filepath.Join(dir, "wiff-main-a-*.svg"))
+	if len(paths) != 1 || s.FlashMsg != "Saved "+paths[0] {
+		t.Fatalf("flash %q, files %q", s.FlashMsg, paths)
+	}
+	// Another export leaves the first alone
+	HandleKey(s, makeKeyEvent('g'))
+	HandleKey(s, makeKeyEvent('E'))
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if again, _ := filepath.Glob(filepath.Join(dir, "wiff-main-a-*.svg")); len(again) != 2 {
+		t.Errorf("second export: files %q", again)
+	}
+
+	t.Setenv("PATH", "")
+	HandleKey(s, makeKeyEvent('g'))
+	HandleKey(s, makeKeyEvent('E'))
+	HandleKey(s, makeKeyEvent('4'))
+	if !strings.Contains(s.FlashMsg, "rsvg-convert") {
+		t.Errorf("PNG without a converter: %q", s.FlashMsg)
+	}
+	if left, _ := filepath.Glob(filepath.Join(dir, "wiff-main-*.png")); len(left) != 0 {
+		t.Errorf("failed PNG export left %q", left)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// IndexSplit tells which changed lines of a diff from a ref to the working
+// tree are staged: the added lines the index doesn't have yet, by new line
+// number, and the removed lines it no longer has, by old line number, per
+// file. Every other changed line is on the other side of the index.
+type IndexSplit struct {
+	UnstagedAdded map[string]map[int]bool
+	StagedRemoved map[string]map[int]bool
+}
+
+// Index filters for hunks of a split diff, cycled with gi.
+const (
+	indexFilterAll = iota
+	indexFilterStaged
+	indexFilterUnstaged
+	indexFilterModes
+)
+
+var indexFilterNames = []string{"", "staged", "unstaged"}
+
+// splitsIndex reports whether the diff compares a git revision with the
+// working tree, so that some of its changes may already be staged.
+func (s *State) splitsIndex() bool {
+	if _, git := repo.(gitVCS); !git || s.PipeMode || s.NoIndex || s.Series != nil {
+		return false
+	}
+	oldRev, newRev := s.sideRevs()
+	return newRev == revWorktree && oldRev != revIndex
+}
+
+// loadIndexSplit places each changed line of hunks on one side of the
+// index. It returns nil when the diff has no such sides.
+func (s *State) loadIndexSplit(hunks []Hunk) *IndexSplit {
+	if job := s.indexSplitJob(); job != nil && len(hunks) > 0 {
+		return job()
+	}
+	return nil
+}
+
+// indexSplitJob returns a function that diffs the revision against the
+// index and the index against the working tree without context, for running
+// off the main goroutine, or nil when the diff has no sides of the index.
+func (s *State) indexSplitJob() func() *IndexSplit {
+	if !s.splitsIndex() {
+		return nil
+	}
+	oldRev, _ := s.sideRevs()
+	args := []string{"git", "diff", "--no-color", "-U0", "--no-ext-diff"}
+	if s.FindRenames != "" {
+		args = append(args, findRenamesArg(s.FindRenames))
+	}
+	return func() *IndexSplit {
+		staged, err := runDiffCommand(context.Background(), append(slices.Clip(args), "--cached", oldRev))
+		if err != nil {
+			return nil
+		}
+		unstaged, err := runDiffCommand(context.Background(), args)
+		if err != nil {
+			return nil
+		}
+		split := &IndexSplit{
+			UnstagedAdded: make(map[string]map[int]bool),
+			StagedRemoved: make(map[string]map[int]bool),
+		}
+		if err := collectLines(staged, '-', split.StagedRemoved); err != nil {
+			return nil
+		}
+		if err := collectLines(unstaged, '+', split.UnstagedAdded); err != nil {
+			return nil
+		}
+		return split
+	}
+}
+
+// refreshIndexSplit places the lines of a split diff on the sides of the
+// index again after staging moved some, and applies the index filter to
+// the new split.
+func (s *State) refreshIndexSplit() {
+	if s.IndexSplit == nil {
+		return
+	}
+	s.IndexSplit = s.loadIndexSplit(s.Hunks)
+	if s.IndexFilter != indexFilterAll {
+		s.BuildLines()
+		s.ClampScroll()
+	}
+}
+
+// collectLines adds the numbers of the lines with op in raw, a diff, to
+// lines by file: old line numbers for removed lines, new ones for added.
+func collectLines(raw []byte, op rune, lines map[string]map[int]bool) error {
+	hunks, err := parseDiff(raw)
+	if err != nil {
+		return err
+	}
+	for _, h := range hunks {
+		n := h.NewStart
+		if op == '-' {
+			n = h.OldStart
+		}
+		for _, l := range h.Lines {
+			if l.Op != op && l.Op != ' ' {
+				continue
+			}
+			if l.Op == op {
+				if lines[h.File] == nil {
+					lines[h.File] = make(map[int]bool)
+				}
+				lines[h.File][n] = true
+			}
+			n++
+		}
+	}
+	return nil
+}
+
+// hunkIndexState counts the changed lines of h already staged and those
+// not, both 0 without an index split.
+func (s *State) hunkIndexState(h *Hunk) (staged, unstaged int) {
+	if s.IndexSplit == nil {
+		return 0, 0
+	}
+	oldNo, newNo := h.OldStart, h.NewStart
+	for _, l := range h.Lines {
+		switch l.Op {
+		case '+':
+			if s.IndexSplit.UnstagedAdded[h.File][newNo] {
+				unstaged++
+			} else {
+				staged++
+			}
+			newNo++
+		case '-':
+			if s.IndexSplit.StagedRemoved[h.File][oldNo] {
+				staged++
+			} else {
+				unstaged++
+			}
+			oldNo++
+		default:
+			oldNo++
+			newNo++
+		}
+	}
+	return staged, unstaged
+}
+
+// indexFilterShows reports whether the index filter lets h through: a
+// partly staged hunk shows under both filters.
+func (s *State) indexFilterShows(h *Hunk) bool {
+	if s.IndexFilter == indexFilterAll || s.IndexSplit == nil {
+		return true
+	}
+	staged, unstaged := s.hunkIndexState(h)
+	if s.IndexFilter == indexFilterStaged {
+		return staged > 0
+	}
+	return unstaged > 0
+}
+
+// lineStaged reports whether the changed lines of a display row are all
+// staged; known is false for rows without changes or an index split.
+func (s *State) lineStaged(line DisplayLine) (staged, known bool) {
+	if s.IndexSplit == nil || line.Continuation || line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+		return false, false
+	}
+	file := s.Hunks[line.HunkIdx].File
+	staged = true
+	check := func(style LineStyle, n int) {
+		switch style {
+		case StyleAdded:
+			known = true
+			staged = staged && !s.IndexSplit.UnstagedAdded[file][n]
+		case StyleRemoved:
+			known = true
+			staged = staged && s.IndexSplit.StagedRemoved[file][n]
+		}
+	}
+	if s.SideBySide {
+		check(line.Left.Style, line.Left.LineNo)
+		check(line.Right.Style, line.Right.LineNo)
+	} else {
+		n := line.NewLineNo
+		if line.Style == StyleRemoved {
+			n = line.OldLineNo
+		}
+		check(line.Style, n)
+	}
+	return staged && known, known
+}
+
+// indexMark returns the gutter mark of a row in a split diff: ● on staged
+// lines and on hunk headers of fully staged hunks, ◐ on partly staged ones.
+func (s *State) indexMark(line DisplayLine) (rune, bool) {
+	if s.IndexSplit == nil {
+		return 0, false
+	}
+	if line.Style == StyleHunkHeader {
+		if line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+			return 0, false
+		}
+		switch staged, unstaged := s.hunkIndexState(&s.Hunks[line.HunkIdx]); {
+		case staged > 0 && unstaged == 0:
+			return '●', true
+		case staged > 0:
+			return '◐', true
+		}
+		return 0, false
+	}
+	if staged, _ := s.lineStaged(line); staged {
+		return '●', true
+	}
+	return 0, false
+}
+
+// CycleIndexFilter shows all hunks, then only those with staged changes,
+// then only those with unstaged ones.
+func (s *State) CycleIndexFilter() {
+	if s.IndexSplit == nil {
+		s.FlashMsg = "Staged/unstaged filters need a diff of a ref against the working tree (wiff HEAD)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.IndexFilter = (s.IndexFilter + 1) % indexFilterModes
+	s.BuildLines()
+	s.ClampScroll()
+	if s.IndexFilter == indexFilterAll {
+		s.FlashMsg = "Showing all hunks"
+	} else {
+		s.FlashMsg = "Showing hunks with " + indexFilterNames[s.IndexFilter] + " changes"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
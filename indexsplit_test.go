@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestIndexSplit(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	write := func(edit map[int]string) {
+		t.Helper()
+		var sb strings.Builder
+		for i := 1; i <= 40; i++ {
+			if e, ok := edit[i]; ok {
+				sb.WriteString(e + "\n")
+			} else {
+				fmt.Fprintf(&sb, "line %d\n", i)
+			}
+		}
+		if err := os.WriteFile("a.txt", []byte(sb.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write(nil)
+	git("add", ".")
+	git("commit", "-qm", "Add a")
+	// Staged: line 2 and line 20; then unstaged: line 22 and line 38
+	write(map[int]string{2: "two", 20: "twenty"})
+	git("add", "a.txt")
+	write(map[int]string{2: "two", 20: "twenty", 22: "twenty-two", 38: "thirty-eight"})
+
+	s := &State{Width: 80, Height: 40, ContextLines: 3, Refs: []string{"HEAD"}}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if s.IndexSplit == nil || len(s.Hunks) != 3 {
+		t.Fatalf("split = %v with %d hunks, want 3", s.IndexSplit, len(s.Hunks))
+	}
+	want := []struct{ staged, unstaged int }{{2, 0}, {2, 2}, {0, 2}}
+	for i, w := range want {
+		if staged, unstaged := s.hunkIndexState(&s.Hunks[i]); staged != w.staged || unstaged != w.unstaged {
+			t.Errorf("hunk %d: %d staged, %d unstaged, want %d and %d", i, staged, unstaged, w.staged, w.unstaged)
+		}
+	}
+	marks := map[rune]int{}
+	for _, line := range s.Lines {
+		if m, ok := s.indexMark(line); ok {
+			marks[m]++
+		}
+	}
+	// Two staged lines in each of the first two hunks, one full hunk, one partial
+	if marks['●'] != 5 || marks['◐'] != 1 {
+		t.Errorf("gutter marks = %v", marks)
+	}
+
+	shown := func() (files []int) {
+		for i := range s.Hunks {
+			if s.Hunks[i].StartLine >= 0 {
+				files = append(files, i)
+			}
+		}
+		return files
+	}
+	s.CycleIndexFilter()
+	if got := shown(); len(got) != 2 || got[0] != 0 || got[1] != 1 {
+		t.Errorf("staged filter shows hunks %v, want 0 and 1", got)
+	}
+	if got := statusSegments["filter"].render(s); got != "staged only" {
+		t.Errorf("filter segment = %q", got)
+	}
+	s.CycleIndexFilter()
+	if got := shown(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("unstaged filter shows hunks %v, want 1 and 2", got)
+	}
+	s.CycleIndexFilter()
+	if got := shown(); len(got) != 3 {
+		t.Errorf("all shows hunks %v", got)
+	}
+
+	// Staging the last hunk moves its lines to the staged side at once
+	s.IndexFilter = indexFilterUnstaged
+	s.BuildLines()
+	handleStageHunk(s, &s.Hunks[2])
+	if staged, unstaged := s.hunkIndexState(&s.Hunks[2]); staged != 2 || unstaged != 0 {
+		t.Errorf("staged hunk 2: %d staged, %d unstaged, want 2 and 0 (%s)", staged, unstaged, s.FlashMsg)
+	}
+	if got := shown(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("unstaged filter after staging shows hunks %v, want 1", got)
+	}
+}
+
+func TestIndexSplitOnlyForWorktreeDiffs(t *testing.T) {
+	s := &State{Staged: true}
+	if s.splitsIndex() {
+		t.Error("--staged diffs don't mix the index and the working tree")
+	}
+	s = &State{}
+	if s.splitsIndex() {
+		t.Error("the unstaged diff is all unstaged")
+	}
+	s.CycleIndexFilter()
+	if s.IndexFilter != indexFilterAll || !strings.Contains(s.FlashMsg, "wiff HEAD") {
+		t.Errorf("filter %d, flash %q", s.IndexFilter, s.FlashMsg)
+	}
+}
@@ -24,18 +24,38 @@ var labelTimer *time.Timer
 
 // HandleKey processes a key event, returns true if should quit
 func HandleKey(s *State, ev *tcell.EventKey) bool {
-	// Dismiss help overlay on any key
+	// Ctrl-Z works in every mode, as in the shell
+	if ev.Key() == tcell.KeyCtrlZ {
+		suspend(s)
+		return false
+	}
+
+	if s.MacroRecording && !s.replayingMacro {
+		s.Macro = append(s.Macro, ev)
+	}
+
+	// The help pane is modal
 	if s.ShowHelp {
-		s.ShowHelp = false
+		HandleHelpKey(s, ev)
 		return false
 	}
 
-	// When in search mode, route all keys to search handler
-	if s.SearchMode {
-		return HandleSearchKey(s, ev)
+	// Popups (pickers and panels) are modal
+	if s.Popup != nil {
+		return HandlePopupKey(s, ev)
+	}
+
+	// So are prompts: search, replace, notes and the like
+	if s.Prompt != nil {
+		HandlePromptKey(s, ev)
+		return false
 	}
 
 	// When tree is focused, route keys to tree handler
+	if s.TreeFocused && s.TreeSearchMode {
+		HandleTreeSearchKey(s, ev)
+		return false
+	}
 	if s.TreeFocused {
 		return handleTreeKey(s, ev)
 	}
@@ -51,6 +71,14 @@ func HandleKey(s *State, ev *tcell.EventKey) bool {
 			ClearSearch(s)
 			return false
 		}
+		if s.Replace != nil {
+			ClearReplace(s)
+			return false
+		}
+		if s.Assistant != nil {
+			closeAssistant(s)
+			return false
+		}
 		return true
 	case tcell.KeyTab:
 		if s.TreeOpen {
@@ -71,24 +99,27 @@ func HandleKey(s *State, ev *tcell.EventKey) bool {
 			s.JumpToPrevFile()
 		}
 	case tcell.KeyUp:
-		s.ScrollBy(-1)
+		s.MoveLines(-1)
 	case tcell.KeyDown:
-		s.ScrollBy(1)
+		s.MoveLines(1)
 	case tcell.KeyLeft:
-		if !s.Wrap || s.SideBySide {
-			s.ScrollX -= 4
-			if s.ScrollX < 0 {
-				s.ScrollX = 0
-			}
-		}
+		s.ScrollHorizontal(-4, ev.Modifiers()&tcell.ModShift != 0)
 	case tcell.KeyRight:
-		if !s.Wrap || s.SideBySide {
-			s.ScrollX += 4
+		s.ScrollHorizontal(4, ev.Modifiers()&tcell.ModShift != 0)
+	case tcell.KeyEnter:
+		if !s.ExpandCollapsed() && s.Review {
+			s.ReviewDoneNext()
 		}
 	case tcell.KeyCtrlD:
-		s.ScrollBy(s.Height / 2)
+		s.MoveBy(s.Height / 2)
 	case tcell.KeyCtrlU:
-		s.ScrollBy(-s.Height / 2)
+		s.MoveBy(-s.Height / 2)
+	case tcell.KeyPgDn, tcell.KeyCtrlF:
+		s.PageBy(1)
+	case tcell.KeyPgUp, tcell.KeyCtrlB:
+		s.PageBy(-1)
+	case tcell.KeyCtrlW:
+		s.PendingKey = windowKey
 	case tcell.KeyRune:
 		return handleRune(s, ev.Rune())
 	}
@@ -101,13 +132,13 @@ func handleRune(s *State, r rune) bool {
 	case 'q':
 		return true
 	case 'j':
-		s.ScrollBy(1)
+		s.MoveLines(1)
 	case 'k':
-		s.ScrollBy(-1)
+		s.MoveLines(-1)
 	case 'd':
-		s.ScrollBy(s.Height / 2)
+		s.MoveBy(s.Height / 2)
 	case 'u':
-		s.ScrollBy(-s.Height / 2)
+		s.MoveBy(-s.Height / 2)
 	case 's':
 		s.SideBySide = !s.SideBySide
 		s.BuildLines()
@@ -124,6 +155,7 @@ func handleRune(s *State, r rune) bool {
 		s.Wrap = !s.Wrap
 		if s.Wrap {
 			s.ScrollX = 0
+			s.ScrollXRight = 0
 		}
 		s.BuildLines()
 		s.ClampScroll()
@@ -136,8 +168,10 @@ func handleRune(s *State, r rune) bool {
 		s.ClampScroll()
 	case 'h':
 		s.SyntaxHighlight = !s.SyntaxHighlight
+		s.lowBandwidthSaved[1] = s.SyntaxHighlight // kept when low-bandwidth mode goes off
 	case 'b':
 		s.DiffBg = !s.DiffBg
+		s.lowBandwidthSaved[0] = s.DiffBg
 	case '+', '=':
 		if !s.PipeMode {
 			s.ContextLines++
@@ -149,9 +183,18 @@ func handleRune(s *State, r rune) bool {
 			_ = loadDiff(s)
 		}
 	case 'g':
-		s.ScrollTo(0)
+		// g waits for gt/gT/gn/gx; alone (or as gg) it goes to the top
+		s.PendingKey = r
+		s.PendingTime = time.Now()
+		startLabelTimer(s)
 	case 'G':
-		s.ScrollTo(s.MaxScroll())
+		s.MoveTo(len(s.Lines) - 1)
+	case 'C':
+		s.ToggleCursorMode()
+	case 'V':
+		s.ToggleRowNav()
+	case 'S':
+		s.ToggleLinkedScroll()
 	case '/':
 		StartSearch(s)
 	case 'N':
@@ -159,11 +202,22 @@ func handleRune(s *State, r rune) bool {
 	case 'o':
 		file := s.CurrentFile()
 		if file != "" {
-			openInEditor(s, file, s.CurrentLineNo())
-			if !s.PipeMode {
-				reloadDiff(s)
-			}
+			openFile(s, file, s.CurrentLineNo())
 		}
+	case 'B':
+		openForgePicker(s)
+	case 'I':
+		openAssistantPicker(s)
+	case 'K':
+		commitStaged(s)
+	case 'D':
+		openDifftool(s)
+	case 'T':
+		openThemePicker(s)
+	case 'L':
+		openLanguagePicker(s)
+	case '<', '>', 'E':
+		s.ExpandHunk(r)
 	case 'W':
 		if !s.PipeMode {
 			s.WatchEnabled = !s.WatchEnabled
@@ -174,22 +228,64 @@ func handleRune(s *State, r rune) bool {
 			}
 			s.FlashExpiry = time.Now().Add(2 * time.Second)
 		}
+	case 'P':
+		s.ToggleLowBandwidth()
 	case 'f':
 		s.FullFile = !s.FullFile
 		if s.FullFile {
-			if s.FilterFile != "" {
-				s.FullFileName = s.FilterFile
-			} else {
-				s.FullFileName = s.CurrentFile()
-			}
-			if s.FullFileName == "" && len(s.Hunks) > 0 {
-				s.FullFileName = s.Hunks[0].File
-			}
+			s.enterFullFile()
+		}
+		s.BuildLines()
+		s.ClampScroll()
+	case 'O':
+		// Toggle which version full-file mode shows, entering it if needed
+		if !s.FullFile {
+			s.FullFile = true
+			s.FullFileOld = true
+			s.enterFullFile()
+		} else {
+			s.FullFileOld = !s.FullFileOld
 		}
+		s.FullFileRevSet = false
+		if s.FullFileOld {
+			s.FlashMsg = "Full file: old version"
+		} else {
+			s.FlashMsg = "Full file: new version"
+		}
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
 		s.BuildLines()
 		s.ClampScroll()
+	case 'R':
+		if s.FullFile {
+			openRevisionPicker(s)
+		} else {
+			refreshDiff(s)
+		}
+	case '~':
+		s.ToggleZen()
 	case '?':
-		s.ShowHelp = true
+		OpenHelp(s)
+	case '#':
+		openTodoPanel(s)
+	case ':':
+		startGoto(s)
+	case ';':
+		startNote(s)
+	case '*':
+		openMovedPanel(s)
+	case '&':
+		toggleCheck(s)
+	case 'H':
+		openLineHistory(s)
+	case '%':
+		StartReplace(s)
+	case '!':
+		if s.Replace == nil {
+			s.FlashMsg = "No replace preview (% starts one)"
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+		} else {
+			ApplyReplace(s)
+		}
 	case 'F':
 		if !s.PipeMode {
 			s.FollowMode = !s.FollowMode
@@ -200,8 +296,20 @@ func handleRune(s *State, r rune) bool {
 			}
 			s.FlashExpiry = time.Now().Add(2 * time.Second)
 		}
-	case ']', '[', 'y', 'Y', 'p', 'c', 'A':
+	case '.':
+		repeatLastAction(s)
+	case ',':
+		openCurrentHunkMenu(s)
+	case 'Q':
+		toggleMacroRecording(s)
+	case '@':
+		replayMacro(s)
+	case ']', '[', 'y', 'Y', 'p', 'c', 'A', 'a', 'z', '|', 'M', 'm', '\'':
 		s.PendingKey = r
+	default:
+		if uc, ok := s.userCommand(r); ok {
+			runUserCommand(s, uc)
+		}
 	}
 	return false
 }
@@ -210,8 +318,10 @@ func handleRune(s *State, r rune) bool {
 func handleTreeKey(s *State, ev *tcell.EventKey) bool {
 	switch ev.Key() {
 	case tcell.KeyEscape:
-		// If filter is active, clear the filter first
-		if s.FilterFile != "" {
+		// Clear a tree search, then the filter, then leave the tree
+		if s.TreeQuery != "" {
+			s.SetTreeQuery("")
+		} else if s.FilterFile != "" {
 			s.FilterFile = ""
 			s.BuildLines()
 			s.ClampScroll()
@@ -253,14 +363,20 @@ func handleTreeRune(s *State, r rune) bool {
 			s.BuildLines()
 			s.ClampScroll()
 		}
+	case 's':
+		s.CycleTreeSort()
+	case 'A':
+		StageFile(s, s.TreeCursorPath())
+	case 'S':
+		confirmStageAll(s)
+	case '/':
+		s.TreeSearchMode = true
+		s.SetTreeQuery("")
 	case 'o':
 		// Open selected file in editor
 		file := s.TreeCursorPath()
 		if file != "" {
-			openInEditor(s, file, 0)
-			if !s.PipeMode {
-				reloadDiff(s)
-			}
+			openFile(s, file, 0)
 		}
 	case 'e':
 		// Close tree
@@ -294,11 +410,14 @@ func treeMoveCursor(s *State, delta int) {
 }
 
 func handleTreeSelect(s *State) {
-	path := s.TreeCursorPath()
-	if path == "" {
-		return
+	if path := s.TreeCursorPath(); path != "" {
+		toggleFileFilter(s, path)
 	}
+}
 
+// toggleFileFilter shows only the hunks of path, or every file again when
+// the view already shows only path.
+func toggleFileFilter(s *State, path string) {
 	// Toggle: if already filtered to this file, deselect
 	if s.FilterFile == path {
 		s.FilterFile = ""
@@ -327,6 +446,12 @@ func handlePending(s *State, ev *tcell.EventKey) bool {
 		return false // cancel
 	}
 
+	if pending == windowKey && ev.Key() == tcell.KeyCtrlW {
+		s.PendingKey = 0
+		FocusNextPane(s)
+		return false
+	}
+
 	if ev.Key() != tcell.KeyRune {
 		s.PendingKey = 0
 		s.PendingLabel = ""
@@ -342,8 +467,20 @@ func handlePending(s *State, ev *tcell.EventKey) bool {
 		switch r {
 		case 'c':
 			s.JumpToNextHunk()
+		case 't':
+			s.JumpToTodo(1)
+		case 'x':
+			s.JumpToConflict(1)
+		case 'n':
+			s.JumpToNote(1)
+		case 'd':
+			s.JumpToDiagnostic(1)
+		case 'p':
+			s.NextPatch(1)
 		case 'f':
-			if s.FullFile {
+			if s.Review {
+				s.ReviewDoneNext()
+			} else if s.FullFile {
 				s.NextFullFile()
 			} else {
 				s.JumpToNextFile()
@@ -354,21 +491,97 @@ func handlePending(s *State, ev *tcell.EventKey) bool {
 		switch r {
 		case 'c':
 			s.JumpToPrevHunk()
+		case 't':
+			s.JumpToTodo(-1)
+		case 'x':
+			s.JumpToConflict(-1)
+		case 'n':
+			s.JumpToNote(-1)
+		case 'd':
+			s.JumpToDiagnostic(-1)
+		case 'p':
+			s.NextPatch(-1)
 		case 'f':
-			if s.FullFile {
+			if s.Review {
+				s.ReviewPrev()
+			} else if s.FullFile {
 				s.PrevFullFile()
 			} else {
 				s.JumpToPrevFile()
 			}
 		}
-	case 'y', 'Y', 'p', 'c':
+	case 'z':
+		s.PendingKey = 0
+		s.Recenter(r)
+	case 'm':
+		s.PendingKey = 0
+		s.SetMark(r)
+	case '\'':
+		s.PendingKey = 0
+		s.JumpToMark(r)
+	case windowKey:
+		s.PendingKey = 0
+		handleWindowKey(s, r)
+	case 'g':
+		s.PendingKey = 0
+		cancelLabelTimer()
+		switch r {
+		case 't':
+			SwitchTab(s, 1)
+		case 'T':
+			SwitchTab(s, -1)
+		case 'n':
+			openTabPrompt(s, false)
+		case 'x':
+			CloseTab(s)
+		case 'p':
+			OpenPatchList(s)
+		case 'o':
+			OpenStartMenu(s)
+		case 'd':
+			openDiagnosticsPanel(s)
+		case '&':
+			showCheckOutput(s)
+		case 'r':
+			s.ToggleReview()
+		case 'm':
+			openMarksPanel(s)
+		case 's':
+			StageFile(s, s.CurrentFile())
+		case 'S':
+			confirmStageAll(s)
+		case 'i':
+			s.CycleIndexFilter()
+		case 'l':
+			s.CycleLabelPlacement()
+		case 'c':
+			s.ToggleChangesOnly()
+		case 'K':
+			quickCommit(s)
+		case 'E':
+			openImageExport(s)
+		case 'q':
+			copyQuickfix(s)
+		case 'g':
+			s.MoveTo(0)
+		default:
+			s.MoveTo(0)
+			return handleRune(s, r)
+		}
+	case 'y', 'Y', 'p', 'c', '|', 'M':
+		// yy yanks the single line under the cursor (labels never use y)
+		if pending == 'y' && r == 'y' && s.PendingLabel == "" {
+			s.PendingKey = 0
+			yankCurrentLine(s)
+			return false
+		}
 		candidate := s.PendingLabel + string(r)
 		// Exact match with no longer labels — yank immediately
 		if h := s.HunkByLabel(candidate); h != nil && !s.hasLabelPrefix(candidate) {
 			s.PendingKey = 0
 			s.PendingLabel = ""
 			cancelLabelTimer()
-			handleYankHunk(s, pending, h)
+			runHunkAction(s, pending, h)
 			return false
 		}
 		// Exact match AND prefix of longer labels — accumulate, start timeout
@@ -385,20 +598,20 @@ func handlePending(s *State, ev *tcell.EventKey) bool {
 				s.PendingKey = 0
 				s.PendingLabel = ""
 				cancelLabelTimer()
-				handleYankHunk(s, pending, h)
+				runHunkAction(s, pending, h)
 				return false
 			}
 		}
 		s.PendingKey = 0
 		s.PendingLabel = ""
 		cancelLabelTimer()
-	case 'A':
+	case 'A', 'a':
 		candidate := s.PendingLabel + string(r)
 		if h := s.HunkByLabel(candidate); h != nil && !s.hasLabelPrefix(candidate) {
 			s.PendingKey = 0
 			s.PendingLabel = ""
 			cancelLabelTimer()
-			handleStageHunk(s, h)
+			runHunkAction(s, pending, h)
 			return false
 		}
 		if s.hasLabelPrefix(candidate) || s.HunkByLabel(candidate) != nil {
@@ -412,7 +625,7 @@ func handlePending(s *State, ev *tcell.EventKey) bool {
 				s.PendingKey = 0
 				s.PendingLabel = ""
 				cancelLabelTimer()
-				handleStageHunk(s, h)
+				runHunkAction(s, pending, h)
 				return false
 			}
 		}
@@ -445,32 +658,94 @@ func handleTreeClick(s *State, y int) {
 	}
 }
 
-// HandleDiffClick handles a click on the diff area. Returns true if it was
-// a double-click that triggered a copy action.
+// HandleDiffClick handles a click on the diff area: a single click moves
+// the cursor to the clicked line, a double-click copies that line, and a
+// click on the breadcrumb runs its segment. Returns true if a copy action
+// was triggered.
 func HandleDiffClick(s *State, x, y int) bool {
+	if y == 0 && s.breadcrumbLine() >= 0 && HandleBreadcrumbClick(s, x) {
+		return false
+	}
 	now := time.Now()
 	isDouble := now.Sub(lastClickTime) < 400*time.Millisecond && y == lastClickY
 	lastClickTime = now
 	lastClickY = y
 
-	if !isDouble {
+	lineIdx, ok := clickedLine(s, y)
+	if !ok {
 		return false
 	}
+	s.CursorMode = true
+	s.Cursor = lineIdx
+	s.clampCursor()
 
-	return copyClickedChunk(s, x, y)
+	if !isDouble {
+		return false
+	}
+	return copyClickedLine(s, x, lineIdx)
 }
 
-// HandleDiffRightClick copies the chunk at the clicked line.
+// HandleDiffRightClick opens the hunk menu on a hunk header and copies the
+// chunk at any other line.
 func HandleDiffRightClick(s *State, x, y int) bool {
+	if lineIdx, ok := clickedLine(s, y); ok {
+		if line := s.Lines[lineIdx]; line.Style == StyleHunkHeader && line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) {
+			openHunkMenu(s, &s.Hunks[line.HunkIdx])
+			return true
+		}
+	}
 	return copyClickedChunk(s, x, y)
 }
 
+// HandleDiffMiddleClick opens the clicked line's file at that line.
+func HandleDiffMiddleClick(s *State, y int) {
+	lineIdx, ok := clickedLine(s, y)
+	if !ok {
+		return
+	}
+	s.CursorMode = true
+	s.Cursor = lineIdx
+	if file := s.CurrentFile(); file != "" {
+		openFile(s, file, s.CurrentLineNo())
+	}
+}
+
+// clickedLine maps screen row y to a display line index. The top row is
+// not a line when the sticky file header covers it.
+func clickedLine(s *State, y int) (int, bool) {
+	if y == 0 && s.StickyFileHeader() != "" {
+		return 0, false
+	}
+	lineIdx := s.Scroll + y
+	if lineIdx < 0 || lineIdx >= len(s.Lines) {
+		return 0, false
+	}
+	return lineIdx, true
+}
+
+// clickedNewSide reports whether column x falls on the right (new) half in
+// side-by-side mode.
+func clickedNewSide(s *State, x int) bool {
+	return x >= s.dividerX()
+}
+
+// copyClickedLine copies the content of a single display line. In
+// side-by-side mode x picks the old or new half.
+func copyClickedLine(s *State, x, lineIdx int) bool {
+	text, ok := s.LineText(lineIdx, !s.SideBySide || clickedNewSide(s, x))
+	if !ok {
+		return false
+	}
+	copyText(s, text, "Copied line")
+	return true
+}
+
 // copyClickedChunk finds the hunk at screen row y and copies the appropriate
 // lines (added or removed) to the clipboard. In side-by-side mode, x position
 // determines whether the left (removed) or right (added) side is copied.
 func copyClickedChunk(s *State, x, y int) bool {
-	lineIdx := s.Scroll + y
-	if lineIdx < 0 || lineIdx >= len(s.Lines) {
+	lineIdx, ok := clickedLine(s, y)
+	if !ok {
 		return false
 	}
 	line := s.Lines[lineIdx]
@@ -496,13 +771,7 @@ func copyClickedChunk(s *State, x, y int) bool {
 
 	// In side-by-side mode, use x position to determine left (removed) vs right (added)
 	if s.SideBySide {
-		lnoExtra := 0
-		if s.LineNumbers {
-			lnoExtra = 5 // lineNoWidth
-		}
-		colWidth := (s.DiffWidth - s.LabelGutter - 1) / 2
-		midpoint := s.DiffX + s.LabelGutter + lnoExtra + colWidth
-		wantAdded = x >= midpoint
+		wantAdded = clickedNewSide(s, x)
 	}
 
 	var text, kind string
@@ -518,12 +787,7 @@ func copyClickedChunk(s *State, x, y int) bool {
 		return false
 	}
 
-	if copyToClipboard(text) {
-		s.FlashMsg = fmt.Sprintf("Copied %s lines from hunk %s", kind, hunk.Label)
-	} else {
-		s.FlashMsg = "Copy failed: could not write to terminal"
-	}
-	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	copyText(s, text, fmt.Sprintf("Copied %s lines from hunk %s", kind, hunk.Label))
 	return true
 }
 
@@ -536,16 +800,17 @@ func (e *EventLabelTimeout) When() time.Time { return e.t }
 
 // ResolvePendingLabel auto-resolves an ambiguous pending label on timeout.
 func ResolvePendingLabel(s *State) {
+	if s.PendingKey == 'g' {
+		s.PendingKey = 0
+		s.MoveTo(0)
+		return
+	}
 	if s.PendingKey == 0 || s.PendingLabel == "" {
 		return
 	}
 	cmd := s.PendingKey
 	if h := s.HunkByLabel(s.PendingLabel); h != nil {
-		if cmd == 'A' {
-			handleStageHunk(s, h)
-		} else {
-			handleYankHunk(s, cmd, h)
-		}
+		runHunkAction(s, cmd, h)
 	}
 	s.PendingKey = 0
 	s.PendingLabel = ""
@@ -569,6 +834,115 @@ func startLabelTimer(s *State) {
 	})
 }
 
+// runHunkAction performs a label-targeted hunk command (y, Y, p, c or A)
+// and remembers it so '.' can repeat it on another hunk.
+func runHunkAction(s *State, cmd rune, hunk *Hunk) {
+	// Toggling commands remember their direction so repeating never flips back
+	switch cmd {
+	case 'A':
+		if s.ConfirmApply {
+			confirmStageHunk(s, hunk) // repeatable once confirmed
+			return
+		}
+		handleStageHunk(s, hunk)
+		s.LastAction = HunkAction{Cmd: cmd, On: hunk.Staged}
+		return
+	case 'a':
+		handleApplyHunk(s, hunk)
+		s.LastAction = HunkAction{Cmd: cmd, On: hunk.Applied}
+		return
+	case '|':
+		startPipe(s, hunk) // interactive, so not repeatable
+		return
+	case 'M':
+		openSnippetPicker(s, hunk)
+		return
+	}
+	handleYankHunk(s, cmd, hunk)
+	s.LastAction = HunkAction{Cmd: cmd}
+}
+
+// repeatLastAction re-runs the last hunk action on the current hunk.
+func repeatLastAction(s *State) {
+	act := s.LastAction
+	if act.Cmd == 0 {
+		s.FlashMsg = "Nothing to repeat"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	if len(s.Hunks) == 0 {
+		return
+	}
+	h := &s.Hunks[s.CurrentHunkIndex()]
+	if act.Cmd == 'A' && h.Staged == act.On {
+		state := "unstaged"
+		if h.Staged {
+			state = "staged"
+		}
+		s.FlashMsg = fmt.Sprintf("Hunk %s already %s", h.Label, state)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	if act.Cmd == 'a' && h.Applied == act.On {
+		state := "not applied"
+		if h.Applied {
+			state = "applied"
+		}
+		s.FlashMsg = fmt.Sprintf("Hunk %s already %s", h.Label, state)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	runHunkAction(s, act.Cmd, h)
+}
+
+// toggleMacroRecording starts or stops recording keystrokes into the macro.
+func toggleMacroRecording(s *State) {
+	if s.MacroRecording {
+		s.MacroRecording = false
+		// Drop the Q that stopped the recording
+		if n := len(s.Macro); n > 0 {
+			s.Macro = s.Macro[:n-1]
+		}
+		s.FlashMsg = fmt.Sprintf("Recorded macro (%d keys), @ to replay", len(s.Macro))
+	} else {
+		s.MacroRecording = true
+		s.Macro = nil
+		s.FlashMsg = "Recording macro, Q to stop"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// replayMacro feeds the recorded keystrokes back through HandleKey.
+func replayMacro(s *State) {
+	if s.MacroRecording {
+		// Don't replay into the macro being recorded
+		if n := len(s.Macro); n > 0 {
+			s.Macro = s.Macro[:n-1]
+		}
+		return
+	}
+	if s.replayingMacro || len(s.Macro) == 0 {
+		return
+	}
+	s.replayingMacro = true
+	defer func() { s.replayingMacro = false }()
+	for _, ev := range s.Macro {
+		HandleKey(s, ev)
+	}
+}
+
+// yankCurrentLine copies the content of the focused display line (the cursor
+// line in cursor mode), without its +/- prefix.
+func yankCurrentLine(s *State) {
+	text, ok := s.FocusLineText()
+	if !ok {
+		s.FlashMsg = "No diff line under cursor"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	copyText(s, text, "Yanked line")
+}
+
 func handleYankHunk(s *State, cmd rune, hunk *Hunk) {
 	var text string
 	switch cmd {
@@ -582,43 +956,108 @@ func handleYankHunk(s *State, cmd rune, hunk *Hunk) {
 		text = hunk.ResultLines()
 	}
 
-	if text != "" {
-		if copyToClipboard(text) {
-			switch cmd {
-			case 'y':
-				s.FlashMsg = fmt.Sprintf("Yanked added lines from hunk %s", hunk.Label)
-			case 'Y':
-				s.FlashMsg = fmt.Sprintf("Yanked removed lines from hunk %s", hunk.Label)
-			case 'p':
-				s.FlashMsg = fmt.Sprintf("Yanked patch from hunk %s", hunk.Label)
-			case 'c':
-				s.FlashMsg = fmt.Sprintf("Copied result from hunk %s", hunk.Label)
-			}
-		} else {
-			s.FlashMsg = fmt.Sprintf("Yank failed for hunk %s: could not write to terminal", hunk.Label)
-		}
-		s.FlashExpiry = time.Now().Add(2 * time.Second)
+	if text == "" {
+		return
 	}
+	msg := map[rune]string{
+		'y': "Yanked added lines from hunk %s",
+		'Y': "Yanked removed lines from hunk %s",
+		'p': "Yanked patch from hunk %s",
+		'c': "Copied result from hunk %s",
+	}[cmd]
+	copyText(s, text, fmt.Sprintf(msg, hunk.Label))
 }
 
 func handleStageHunk(s *State, hunk *Hunk) {
-	patch := hunk.AsFullPatch()
+	if stageRefused(s, hunk) {
+		return
+	}
+	stageHunk(s, hunk, hunk.AsFullPatch(), stageArgs(hunk))
+}
+
+// confirmStageHunk shows the git command and the exact patch staging (or
+// unstaging) hunk would apply to the index, and applies it on Enter.
+func confirmStageHunk(s *State, hunk *Hunk) {
+	if stageRefused(s, hunk) {
+		return
+	}
+	patch, args := hunk.AsFullPatch(), stageArgs(hunk)
+	action := "Stage"
+	if hunk.Staged {
+		action = "Unstage"
+	}
+	items := append([]string{"$ git " + strings.Join(args, " ") + " <<patch", ""},
+		strings.Split(strings.TrimSuffix(patch, "\n"), "\n")...)
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("%s hunk %s? Enter applies, Esc cancels", action, hunk.Label),
+		Items: items,
+		OnSelect: func(s *State, _ int) {
+			stageHunk(s, hunk, patch, args)
+			s.LastAction = HunkAction{Cmd: 'A', On: hunk.Staged}
+		},
+		OnCancel: func(s *State) {
+			s.FlashMsg = action + " canceled"
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+		},
+	})
+}
+
+// stageRefused reports whether hunk can't be staged, flashing why.
+func stageRefused(s *State, hunk *Hunk) bool {
+	if s.NoIndex {
+		s.FlashMsg = "Staging needs a git diff, not two files"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return true
+	}
+	if _, git := repo.(gitVCS); !git {
+		s.FlashMsg = fmt.Sprintf("Staging needs git's index, %s has none", repo.Name())
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return true
+	}
+	if textconvRefused(s, hunk) {
+		return true
+	}
+	if hunk.Parents > 0 {
+		s.FlashMsg = fmt.Sprintf("Hunk %s is from a combined (merge) diff and can't be staged", hunk.Label)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return true
+	}
+	return false
+}
+
+// stageArgs returns the git arguments staging hunk, or unstaging it once
+// staged.
+func stageArgs(hunk *Hunk) []string {
 	args := []string{"apply", "--cached"}
 	if hunk.Staged {
 		args = append(args, "-R") // reverse to unstage
 	}
+	return args
+}
+
+// stageHunk applies patch to the index with git args and flips hunk's
+// staged state, unless the files changed under hunk since it was loaded.
+func stageHunk(s *State, hunk *Hunk, patch string, args []string) {
+	if staleRefused(s, hunk) {
+		return
+	}
 	cmd := exec.Command("git", args...)
 	cmd.Stdin = strings.NewReader(patch)
-	if err := cmd.Run(); err != nil {
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
 		action := "Stage"
 		if hunk.Staged {
 			action = "Unstage"
 		}
+		logger.Warn(strings.ToLower(action)+" failed", "file", hunk.File, "hunk", hunk.Label, "err", err, "output", string(out))
 		s.FlashMsg = fmt.Sprintf("%s failed for hunk %s: %v", action, hunk.Label, err)
 		s.FlashExpiry = time.Now().Add(2 * time.Second)
 		return
 	}
 	hunk.Staged = !hunk.Staged
+	s.refreshIndexSplit()
 	if hunk.Staged {
 		s.FlashMsg = fmt.Sprintf("Staged hunk %s", hunk.Label)
 	} else {
@@ -626,3 +1065,60 @@ func handleStageHunk(s *State, hunk *Hunk) {
 	}
 	s.FlashExpiry = time.Now().Add(2 * time.Second)
 }
+
+// canApplyToWorktree reports whether the diff is foreign to the working tree
+// (piped in, or between two refs), so applying its hunks makes sense.
+func canApplyToWorktree(s *State) bool {
+	if s.NoIndex {
+		return false
+	}
+	return s.PipeMode || len(s.Refs) >= 2 || (len(s.Refs) == 1 && strings.Contains(s.Refs[0], ".."))
+}
+
+// handleApplyHunk applies a hunk to the working tree (git apply, or
+// patch(1) without git), or reverts it if it was applied before.
+func handleApplyHunk(s *State, hunk *Hunk) {
+	if !canApplyToWorktree(s) {
+		s.FlashMsg = "Apply only works on piped or two-ref diffs"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	if textconvRefused(s, hunk) {
+		return
+	}
+	if hunk.Parents > 0 {
+		s.FlashMsg = fmt.Sprintf("Hunk %s is from a combined (merge) diff and can't be applied", hunk.Label)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	// Applied hunks are reversed to undo them
+	if out, err := repo.Apply(hunk.AsFullPatch(), hunk.Applied); err != nil {
+		action := "Apply"
+		if hunk.Applied {
+			action = "Revert"
+		}
+		logger.Warn(strings.ToLower(action)+" failed", "file", hunk.File, "hunk", hunk.Label, "err", err, "output", string(out))
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		s.FlashMsg = fmt.Sprintf("%s failed for hunk %s: %s", action, hunk.Label, firstLine(msg))
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	hunk.Applied = !hunk.Applied
+	if hunk.Applied {
+		s.FlashMsg = fmt.Sprintf("Applied hunk %s to working tree", hunk.Label)
+	} else {
+		s.FlashMsg = fmt.Sprintf("Reverted hunk %s in working tree", hunk.Label)
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// firstLine returns the first line of a possibly multi-line message.
+func firstLine(msg string) string {
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		return msg[:i]
+	}
+	return msg
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
+	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -237,3 +240,185 @@ func TestHunkStagedDefault(t *testing.T) {
 		t.Error("Hunk.Staged should default to false")
 	}
 }
+
+func TestRepeatLastActionTargetsCurrentHunk(t *testing.T) {
+	s := makeTestState(80, false, false, []Line{{Op: '+', Content: "first"}})
+	s.Height = 40
+	second := s.Hunks[0]
+	second.Label = "c"
+	second.NewStart = 30
+	s.Hunks = append(s.Hunks, second)
+	s.BuildLines()
+
+	HandleKey(s, makeKeyEvent('y'))
+	HandleKey(s, makeKeyEvent('b'))
+	if s.LastAction.Cmd != 'y' {
+		t.Fatalf("LastAction.Cmd = %q, want 'y'", s.LastAction.Cmd)
+	}
+
+	s.JumpToNextHunk()
+	HandleKey(s, makeKeyEvent('.'))
+	if !strings.Contains(s.FlashMsg, "hunk c") {
+		t.Errorf("expected repeat to act on hunk c, got %q", s.FlashMsg)
+	}
+}
+
+func TestRepeatWithoutAction(t *testing.T) {
+	s := &State{Width: 80, Height: 40}
+	HandleKey(s, makeKeyEvent('.'))
+	if s.FlashMsg != "Nothing to repeat" {
+		t.Errorf("FlashMsg = %q, want %q", s.FlashMsg, "Nothing to repeat")
+	}
+}
+
+func TestMacroRecordAndReplay(t *testing.T) {
+	s := &State{Width: 80, Height: 10}
+	s.Lines = make([]DisplayLine, 100)
+
+	HandleKey(s, makeKeyEvent('Q'))
+	if !s.MacroRecording {
+		t.Fatal("expected recording after Q")
+	}
+	HandleKey(s, makeKeyEvent('j'))
+	HandleKey(s, makeKeyEvent('j'))
+	HandleKey(s, makeKeyEvent('Q'))
+	if s.MacroRecording {
+		t.Fatal("expected recording to stop after second Q")
+	}
+	if len(s.Macro) != 2 {
+		t.Fatalf("len(Macro) = %d, want 2", len(s.Macro))
+	}
+
+	HandleKey(s, makeKeyEvent('@'))
+	if s.Scroll != 4 {
+		t.Errorf("Scroll = %d after replay, want 4", s.Scroll)
+	}
+	if len(s.Macro) != 2 {
+		t.Errorf("replay must not modify the macro, len = %d", len(s.Macro))
+	}
+}
+
+func TestCanApplyToWorktree(t *testing.T) {
+	cases := []struct {
+		s    *State
+		want bool
+	}{
+		{&State{}, false},
+		{&State{Refs: []string{"HEAD"}}, false},
+		{&State{PipeMode: true}, true},
+		{&State{Refs: []string{"main", "feature"}}, true},
+		{&State{Refs: []string{"HEAD~3..HEAD"}}, true},
+	}
+	for _, c := range cases {
+		if got := canApplyToWorktree(c.s); got != c.want {
+			t.Errorf("canApplyToWorktree(refs=%v pipe=%v) = %v, want %v", c.s.Refs, c.s.PipeMode, got, c.want)
+		}
+	}
+}
+
+func TestApplyPendingKeyRejectedForWorktreeDiff(t *testing.T) {
+	hunks := []Hunk{{Label: "b", File: "test.go", Lines: []Line{{Op: '+', Content: "x"}}}}
+	s := &State{Hunks: hunks, Width: 80, Height: 40}
+	HandleKey(s, makeKeyEvent('a'))
+	if s.PendingKey != 'a' {
+		t.Fatalf("after 'a', PendingKey = %q, want 'a'", s.PendingKey)
+	}
+	HandleKey(s, makeKeyEvent('b'))
+	if s.Hunks[0].Applied {
+		t.Error("hunk must not be applied for an unstaged worktree diff")
+	}
+	if !strings.Contains(s.FlashMsg, "two-ref") {
+		t.Errorf("unexpected FlashMsg %q", s.FlashMsg)
+	}
+}
+
+func TestDiffClickSetsCursor(t *testing.T) {
+	lastClickTime = time.Time{}
+	s := &State{Height: 20}
+	s.Lines = []DisplayLine{
+		{Text: "file.go", Style: StyleFileHeader, HunkIdx: -1},
+		{Text: "+one", Style: StyleAdded},
+		{Text: "+two", Style: StyleAdded},
+	}
+	if HandleDiffClick(s, 5, 2) {
+		t.Error("single click should not copy")
+	}
+	if !s.CursorMode || s.Cursor != 2 {
+		t.Errorf("cursor mode = %v, cursor = %d, want true, 2", s.CursorMode, s.Cursor)
+	}
+	HandleDiffClick(s, 5, 10)
+	if s.Cursor != 2 {
+		t.Errorf("click past the end moved cursor to %d", s.Cursor)
+	}
+}
+
+func TestLineTextSideBySide(t *testing.T) {
+	s := &State{SideBySide: true}
+	s.Lines = []DisplayLine{{
+		Style: StyleRemoved,
+		Left:  HalfLine{Text: "-old", Style: StyleRemoved},
+		Right: HalfLine{Text: "+new", Style: StyleAdded},
+	}, {
+		Style: StyleRemoved,
+		Left:  HalfLine{Text: "-gone", Style: StyleRemoved},
+	}}
+	if text, ok := s.LineText(0, false); !ok || text != "old" {
+		t.Errorf("old side = %q, %v", text, ok)
+	}
+	if text, ok := s.LineText(0, true); !ok || text != "new" {
+		t.Errorf("new side = %q, %v", text, ok)
+	}
+	if _, ok := s.LineText(1, true); ok {
+		t.Error("empty new half should not be copyable")
+	}
+}
+
+func TestConfirmStage(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	if err := os.WriteFile("a.txt", []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-qm", "Add a")
+	if err := os.WriteFile("a.txt", []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &State{Width: 80, Height: 24, ContextLines: 3, ConfirmApply: true}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	h := &s.Hunks[0]
+	runHunkAction(s, 'A', h)
+	if s.Popup == nil {
+		t.Fatalf("no confirmation: %q", s.FlashMsg)
+	}
+	items := strings.Join(s.Popup.Items, "\n")
+	if !strings.HasPrefix(items, "$ git apply --cached <<patch") || !strings.Contains(items, "+two") {
+		t.Errorf("confirmation shows:\n%s", items)
+	}
+
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if h.Staged || git("diff", "--cached") != "" {
+		t.Fatal("canceling should leave the index alone")
+	}
+
+	runHunkAction(s, 'A', h)
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if !h.Staged || !strings.Contains(git("diff", "--cached"), "+two") {
+		t.Errorf("Enter should stage the hunk: %q", s.FlashMsg)
+	}
+	if s.LastAction != (HunkAction{Cmd: 'A', On: true}) {
+		t.Errorf("LastAction = %+v", s.LastAction)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/h0rv/wiff/pkg/wiff"
+)
+
+// jsonDiff is the parsed diff printed by --json.
+type jsonDiff struct {
+	Refs    []string   `json:"refs"`
+	Paths   []string   `json:"paths,omitempty"`
+	Staged  bool       `json:"staged"`
+	Files   []jsonFile `json:"files"`
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Hidden  int        `json:"hidden"` // files dropped by exclude patterns
+}
+
+type jsonFile struct {
+	Path      string     `json:"path"`
+	OldPath   string     `json:"old_path,omitempty"` // renames and copies
+	Status    string     `json:"status"`             // A, M, D, R or C
+	Generated bool       `json:"generated,omitempty"`
+	Textconv  string     `json:"textconv,omitempty"` // diff driver that converted the file
+	Added     int        `json:"added"`
+	Removed   int        `json:"removed"`
+	Hunks     []jsonHunk `json:"hunks"`
+}
+
+type jsonHunk struct {
+	Label    string     `json:"label"`
+	Header   string     `json:"header"`
+	Comment  string     `json:"comment,omitempty"`
+	OldStart int        `json:"old_start"`
+	NewStart int        `json:"new_start"`
+	Added    int        `json:"added"`
+	Removed  int        `json:"removed"`
+	Parents  int        `json:"parents,omitempty"` // parents of a combined (merge) diff
+	Lines    []jsonLine `json:"lines"`
+}
+
+type jsonLine struct {
+	Op      string      `json:"op"`            // "+", "-" or " "
+	Ops     string      `json:"ops,omitempty"` // one op per parent of a combined diff
+	Text    string      `json:"text"`
+	Old     int         `json:"old,omitempty"` // line number on the old side
+	New     int         `json:"new,omitempty"` // line number on the new side
+	Moved   bool        `json:"moved,omitempty"`
+	Changed []wiff.Span `json:"changed,omitempty"` // changed words of word-diff input, in runes of Text
+}
+
+// diffJSON builds the --json model of s's hunks, grouped by file in diff
+// order.
+func diffJSON(s *State) jsonDiff {
+	d := jsonDiff{Refs: s.Refs, Paths: s.Paths, Staged: s.Staged, Files: []jsonFile{}, Hidden: s.HiddenFiles}
+	if d.Refs == nil {
+		d.Refs = []string{}
+	}
+	for i := range s.Hunks {
+		h := &s.Hunks[i]
+		if n := len(d.Files); n == 0 || d.Files[n-1].Path != h.File {
+			d.Files = append(d.Files, jsonFile{
+				Path:      h.File,
+				OldPath:   h.OldFile,
+				Status:    string(h.Status),
+				Generated: s.Generated[h.File],
+				Textconv:  s.Textconv[h.File],
+			})
+		}
+		f := &d.Files[len(d.Files)-1]
+		added, removed := h.Counts()
+		jh := jsonHunk{
+			Label:    h.Label,
+			Header:   h.Header,
+			Comment:  h.Comment,
+			OldStart: h.OldStart,
+			NewStart: h.NewStart,
+			Added:    added,
+			Removed:  removed,
+			Parents:  h.Parents,
+		}
+		for j, dl := range wiff.HunkLines(h, i) {
+			jh.Lines = append(jh.Lines, jsonLine{
+				Op:      string(h.Lines[j].Op),
+				Ops:     h.Lines[j].Ops,
+				Text:    h.Lines[j].Content,
+				Old:     dl.OldLineNo,
+				New:     dl.NewLineNo,
+				Moved:   dl.Moved,
+				Changed: h.Lines[j].Changed,
+			})
+		}
+		f.Hunks = append(f.Hunks, jh)
+		f.Added += added
+		f.Removed += removed
+		d.Added += added
+		d.Removed += removed
+	}
+	return d
+}
+
+// readHunks loads the diff described by s without a screen.
+func (s *State) readHunks() error {
+	raw, err := s.readDiff()
+	if err != nil {
+		return err
+	}
+	s.Hunks, err = s.parseHunks(raw)
+	if s.PipeMode {
+		err = checkInput(raw, err) // no screen to show it on
+	}
+	return err
+}
+
+// printJSON loads the diff described by s and writes it to w as JSON,
+// for scripts and editor plugins.
+func printJSON(w io.Writer, s *State) error {
+	if err := s.readHunks(); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diffJSON(s))
+}
+
+// runJSON prints the diff of opts as JSON, or as a quickfix list with
+// --quickfix, without a screen.
+func runJSON(opts cliOpts, cfg Config) {
+	reserveKeys(cfg.commandKeys()...) // labels as the viewer assigns them
+	s := &State{
+		Refs:          opts.refs,
+		Paths:         opts.paths,
+		Staged:        opts.staged,
+		PipeMode:      isPipe(),
+		NoIndex:       !isPipe() && bothFiles(opts.refs),
+		ContextLines:  opts.contextLines,
+		Config:        cfg,
+		DiffAlgorithm: opts.diffAlgorithm,
+		FindRenames:   opts.findRenames,
+		Excludes:      append(append(cfg.Excludes, loadWiffignore()...), opts.excludes...),
+	}
+	print := printJSON
+	if opts.quickfix {
+		print = printQuickfix
+	}
+	if err := print(os.Stdout, s); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitStatus(err))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	raw := `diff --git a/old.go b/new.go
+similarity index 80%
+rename from old.go
+rename to new.go
+--- a/old.go
++++ b/new.go
+@@ -1,2 +1,2 @@ func f()
+ keep
+-gone
++here
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -3,0 +4,1 @@
++more
+`
+	s := &State{Refs: []string{"main"}}
+	var err error
+	if s.Hunks, err = s.parseHunks([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	d := diffJSON(s)
+	if len(d.Files) != 2 || d.Added != 2 || d.Removed != 1 {
+		t.Fatalf("files %d, +%d -%d", len(d.Files), d.Added, d.Removed)
+	}
+	f := d.Files[0]
+	if f.Path != "new.go" || f.OldPath != "old.go" || f.Status != "R" || f.Hunks[0].Label != s.Hunks[0].Label {
+		t.Errorf("renamed file = %+v", f)
+	}
+	lines := f.Hunks[0].Lines
+	if len(lines) != 3 || lines[1].Op != "-" || lines[1].Text != "gone" || lines[1].Old != 2 || lines[1].New != 0 {
+		t.Errorf("lines = %+v", lines)
+	}
+
+	out, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"old_path":"old.go"`) || strings.Contains(d.Files[1].OldPath, "b") {
+		t.Errorf("json = %s", out)
+	}
+}
+
+func TestDiffJSONCombined(t *testing.T) {
+	raw := `diff --cc a.txt
+index 1111111,2222222..3333333
+--- a/a.txt
++++ b/a.txt
+@@@ -1,1 -1,1 +1,1 @@@
+- ours
+ -theirs
+++merged
+`
+	s := &State{Refs: []string{"main"}}
+	var err error
+	if s.Hunks, err = s.parseHunks([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	h := diffJSON(s).Files[0].Hunks[0]
+	if h.Parents != 2 || len(h.Lines) != 3 {
+		t.Fatalf("hunk = %+v", h)
+	}
+	if l := h.Lines[2]; l.Op != "+" || l.Ops != "++" || l.Text != "merged" || l.New != 1 {
+		t.Errorf("merged line = %+v", l)
+	}
+	if l := h.Lines[1]; l.Op != "-" || l.Ops != " -" || l.Text != "theirs" || l.Old != 0 {
+		t.Errorf("line removed from the second parent = %+v", l)
+	}
+}
@@ -1,72 +1,146 @@
 package main
 
-// KeyBinding defines a single application key binding.
+// KeyBinding is one entry of the keymap: the keys as typed ("j/k",
+// "A+label", "gt/gT", "^W s"), what they do, and the single-key commands
+// among them, which are reserved so they aren't used as hunk labels.
 type KeyBinding struct {
-	Key  rune
-	Name string
+	Keys     string
+	Name     string
+	Reserves string
 }
 
-// All application keybindings. Adding a key here automatically reserves it
-// so it won't be used as a hunk label.
-var keyBindings = []KeyBinding{
-	// Navigation
-	{Key: 'j', Name: "scroll down"},
-	{Key: 'k', Name: "scroll up"},
-	{Key: 'd', Name: "half page down"},
-	{Key: 'u', Name: "half page up"},
-	{Key: 'g', Name: "go to top"},
-	{Key: 'G', Name: "go to bottom"},
-
-	// Modes & toggles
-	{Key: 's', Name: "side-by-side"},
-	{Key: 'n', Name: "line numbers / next match"},
-	{Key: 'w', Name: "wrap"},
-	{Key: 'e', Name: "explorer"},
-	{Key: 'h', Name: "syntax highlight"},
-	{Key: 'b', Name: "diff background"},
-
-	// Full file view
-	{Key: 'f', Name: "toggle full file view"},
-
-	// Yank / patch / copy (pending key prefixes)
-	{Key: 'y', Name: "yank added"},
-	{Key: 'Y', Name: "yank removed"},
-	{Key: 'p', Name: "yank patch"},
-	{Key: 'c', Name: "copy result"},
-
-	// Staging
-	{Key: 'A', Name: "stage/unstage hunk"},
-
-	// Follow mode
-	{Key: 'F', Name: "follow mode"},
-
-	// Search
-	{Key: '/', Name: "search"},
-	{Key: 'N', Name: "prev search match"},
-
-	// Hunk / file navigation (pending key prefixes)
-	{Key: ']', Name: "next hunk/file"},
-	{Key: '[', Name: "prev hunk/file"},
-
-	// Tree mode
-	{Key: 'a', Name: "show all (tree)"},
-
-	// Help
-	{Key: '?', Name: "help"},
-
-	// Actions
-	{Key: 'o', Name: "open in editor"},
-
-	// Watch mode
-	{Key: 'W', Name: "toggle watch mode"},
+// KeyGroup is a section of the keymap.
+type KeyGroup struct {
+	Name string
+	Keys []KeyBinding
+}
 
-	// Misc
-	{Key: 'q', Name: "quit"},
-	{Key: '+', Name: "more context"},
-	{Key: '=', Name: "more context"},
-	{Key: '-', Name: "less context"},
+// keyGroups is the keymap. The help pane, --help and the man page are
+// generated from it, and adding a key here reserves it from hunk labels.
+var keyGroups = []KeyGroup{
+	{"Navigation", []KeyBinding{
+		{"j/k ↓/↑", "Scroll down/up a line (with C, move the cursor line)", "jk"},
+		{"d/u ^D/^U", "Half page down/up", "du"},
+		{"PgDn/PgUp ^F/^B", "Page down/up, keeping page_overlap lines", ""},
+		{"gg/G", "Top/bottom", "gG"},
+		{"zz/zt/zb", "Put the cursor line at the center/top/bottom", "z"},
+		{"←/→", "Scroll sideways (Shift: the right column when unlinked)", ""},
+		{"S", "Link/unlink side-by-side horizontal scrolling", "S"},
+		{"m{a-z}", "Set a mark on the line", "m"},
+		{"'{a-z} ''", "Jump to a mark / back to where the last jump came from", "'"},
+		{"gm", "List the marks", ""},
+		{"C", "Line cursor mode", "C"},
+		{":", "Go to a line of the current file, file:line or a file", ":"},
+		{"V", "While wrapping, j/k move by display rows / whole lines", "V"},
+	}},
+	{"Hunks & files", []KeyBinding{
+		{"]c/[c", "Next/previous hunk", "]["},
+		{"]f/[f Tab/S-Tab", "Next/previous file", ""},
+		{"]t/[t", "Next/previous TODO marker", ""},
+		{"]x/[x", "Next/previous merge conflict region", ""},
+		{"]n/[n", "Next/previous note", ""},
+		{"]d/[d", "Next/previous line with diagnostics", ""},
+		{"]p/[p gp", "Next/previous patch of a series / patch list", ""},
+		{"+/-", "More/less context lines", "+=-"},
+		{"</>", "10 more context lines above/below the hunk", "<>"},
+		{"E", "Expand the hunk up to its neighbours", "E"},
+		{"Enter", "Expand a collapsed file or hunk; in review mode, mark the file done", ""},
+		{"gr", "Review mode: one file at a time, ]f marks it done", ""},
+	}},
+	{"Modes & display", []KeyBinding{
+		{"s", "Side-by-side", "s"},
+		{"n", "Line numbers (next match after a search)", "n"},
+		{"w", "Wrap", "w"},
+		{"e", "File explorer", "e"},
+		{"h", "Syntax highlighting", "h"},
+		{"b", "Diff background tints", "b"},
+		{"f", "Full file view", "f"},
+		{"O", "Full file view of the old/new version", "O"},
+		{"W", "Watch mode: reload on changes", "W"},
+		{"F", "Follow mode: jump to the changes of each reload", "F"},
+		{"P", "Low-bandwidth (plain) mode for slow links", "P"},
+		{"T", "Theme picker with live preview", "T"},
+		{"L", "Syntax language of the current file", "L"},
+		{"gl", "Hunk labels in the gutter / on headers / hidden until needed", ""},
+		{"gc", "Changes only: hide context lines", ""},
+		{"~", "Zen mode: only the diff, for screen sharing", "~"},
+	}},
+	{"Yank & copy", []KeyBinding{
+		{"y+label", "Yank added lines", "y"},
+		{"Y+label", "Yank removed lines", "Y"},
+		{"p+label", "Yank the hunk as a patch", "p"},
+		{"c+label", "Copy the result (new code)", "c"},
+		{"yy", "Yank the cursor line", ""},
+		{"M+label", "Copy as markdown: code block, diff block or forge link", "M"},
+		{"|+label", "Pipe the hunk through a shell command", "|"},
+		{"gE", "Export the hunk or file as an SVG/PNG image", ""},
+		{"gq", "Copy every hunk location as file:line: text (quickfix)", ""},
+	}},
+	{"Staging", []KeyBinding{
+		{"A+label", "Stage/unstage the hunk", "A"},
+		{"gs/gS", "Stage/unstage the file / all files", ""},
+		{"gi", "Show all hunks / only staged / only unstaged", ""},
+		{"a+label", "Apply the hunk to the working tree (piped or two-ref diffs)", "a"},
+		{"K", "Commit the staged changes with a suggested message", "K"},
+		{"gK", "Commit with the suggested subject edited in a prompt, no editor", ""},
+		{".", "Repeat the last hunk action", "."},
+	}},
+	{"Search & lists", []KeyBinding{
+		{"/", "Search (smart-case; Alt-c cycles case, Alt-w whole words)", "/"},
+		{"↑/↓ ^R", "In a prompt: past inputs / search them", ""},
+		{"Tab ^U ^W", "In a prompt: complete (refs, files) / clear / delete a word", ""},
+		{"n/N", "Next/previous match", "N"},
+		{"Esc", "Clear the search or the replace preview, or close the assistant panel", ""},
+		{"%", "Search and replace in added lines, previewed", "%"},
+		{"!", "Write the previewed replacements", "!"},
+		{"#", "TODO marker list", "#"},
+		{"*", "Moved and duplicated lines", "*"},
+		{";", "Note on the line", ";"},
+		{"gd", "Diagnostics list", ""},
+		{"& g&", "Run the check command, hide its panel / show its output", "&"},
+	}},
+	{"Tabs & panes", []KeyBinding{
+		{"gt/gT", "Next/previous tab", ""},
+		{"gn/gx", "Open a diff in a new tab / close the tab", ""},
+		{"go", "Open another diff in place: last commit, staged, recent", ""},
+		{"^W s/v", "Split below/beside on the same diff", ""},
+		{"^W n", "Open a diff in a pane beside", ""},
+		{"^W w/q/o", "Switch panes / close the pane / close the other", ""},
+		{"^W </>/=", "Narrow/widen the old side-by-side column / even columns", ""},
+	}},
+	{"Actions", []KeyBinding{
+		{",", "Menu of the hunk's actions (also right-click on its header)", ","},
+		{"o", "Open in $EDITOR", "o"},
+		{"D", "Open the file in the difftool", "D"},
+		{"B", "Open/copy the forge link of the line", "B"},
+		{"H", "History of the hunk's lines (git log -L)", "H"},
+		{"I", "Ask the assistant command about the hunk or diff", "I"},
+		{"R", "Refresh; in full file view, pick the revision(s)", "R"},
+		{"Q/@", "Record/replay a macro", "Q@"},
+		{"?", "This help", "?"},
+		{"^Z", "Suspend to the shell (fg resumes)", ""},
+		{"q", "Quit", "q"},
+	}},
+	{"File tree", []KeyBinding{
+		{"Tab", "Focus the tree / the diff", ""},
+		{"j/k g/G", "Move / first and last file", ""},
+		{"Enter a", "Show only the file / all files", ""},
+		{"s", "Sort: path, most changed, status", ""},
+		{"/", "Filter by fuzzy path match", ""},
+		{"A/S", "Stage the file / every file", ""},
+		{"o e", "Open in $EDITOR / close the tree", ""},
+	}},
 }
 
+// keyBindings lists every binding of keyGroups, in order.
+var keyBindings = func() []KeyBinding {
+	var all []KeyBinding
+	for _, g := range keyGroups {
+		all = append(all, g.Keys...)
+	}
+	return all
+}()
+
 // reservedKeys is derived from keyBindings. Any rune here is skipped for hunk labels.
 var reservedKeys map[rune]bool
 
@@ -74,19 +148,43 @@ var reservedKeys map[rune]bool
 var availableLabels []rune
 
 func init() {
-	reservedKeys = make(map[rune]bool, len(keyBindings))
+	reserveKeys()
+}
+
+// reserveKeys rebuilds reservedKeys and availableLabels from keyBindings
+// plus the extra keys bound to user commands.
+func reserveKeys(extra ...rune) {
+	reservedKeys = make(map[rune]bool, len(keyBindings)+len(extra))
 	for _, kb := range keyBindings {
-		reservedKeys[kb.Key] = true
+		for _, r := range kb.Reserves {
+			reservedKeys[r] = true
+		}
+	}
+	for _, r := range extra {
+		reservedKeys[r] = true
 	}
-	// Lowercase first, then uppercase for overflow
-	for r := 'a'; r <= 'z'; r++ {
+	availableLabels = nil
+	for _, r := range labelLetters() {
 		if !reservedKeys[r] {
 			availableLabels = append(availableLabels, r)
 		}
 	}
-	for r := 'A'; r <= 'Z'; r++ {
-		if !reservedKeys[r] {
-			availableLabels = append(availableLabels, r)
+}
+
+// labelLetters returns the letters no key binding reserves, lowercase first
+// and then uppercase for overflow.
+func labelLetters() []rune {
+	bound := make(map[rune]bool)
+	for _, kb := range keyBindings {
+		for _, r := range kb.Reserves {
+			bound[r] = true
+		}
+	}
+	var keys []rune
+	for _, r := range "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		if !bound[r] {
+			keys = append(keys, r)
 		}
 	}
+	return keys
 }
@@ -12,7 +12,7 @@ func TestReservedKeysContainsKnownBindings(t *testing.T) {
 }
 
 func TestReservedKeysExcludesUnbound(t *testing.T) {
-	unbound := []rune{'z', 'x', 'Z', 'X'}
+	unbound := []rune{'x', 'Z', 'X'}
 	for _, r := range unbound {
 		if reservedKeys[r] {
 			t.Errorf("expected '%c' to NOT be reserved", r)
@@ -67,3 +67,16 @@ func TestIndexToLabelConsistency(t *testing.T) {
 		}
 	}
 }
+
+func TestReserveKeysExtra(t *testing.T) {
+	defer reserveKeys()
+	reserveKeys('x')
+	if !reservedKeys['x'] {
+		t.Fatal("expected 'x' to be reserved")
+	}
+	for _, r := range availableLabels {
+		if r == 'x' {
+			t.Error("'x' should no longer be a label")
+		}
+	}
+}
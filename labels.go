@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Placements of hunk labels, set with labels in the config and cycled with
+// gl: in the gutter of every row (the default), only on the hunk header
+// (the gutter shrinks to the mark column), or hidden until a key that takes
+// a label is pressed, which shows them on the headers.
+const (
+	labelsGutter = iota
+	labelsHeader
+	labelsHidden
+	labelPlacements
+)
+
+var labelPlacementNames = []string{"gutter", "header", "hidden"}
+
+// labelKeys are the commands followed by a hunk label.
+const labelKeys = "yYpc|MAa"
+
+// parseLabelPlacement returns the placement named name.
+func parseLabelPlacement(name string) (int, error) {
+	if i := slices.Index(labelPlacementNames, name); i >= 0 {
+		return i, nil
+	}
+	return 0, fmt.Errorf("want %s, got %q", strings.Join(labelPlacementNames, ", "), name)
+}
+
+// gutterLabelWidth returns the columns the gutter keeps for labels: the
+// widest label in gutter placement, none otherwise.
+func (s *State) gutterLabelWidth() int {
+	if s.LabelPlacement != labelsGutter {
+		return 0
+	}
+	return s.maxLabelWidth()
+}
+
+// headerLabels reports whether labels are drawn on hunk headers: always in
+// header placement, while a label is awaited when hidden or in zen mode.
+func (s *State) headerLabels() bool {
+	if s.Zen || s.LabelPlacement == labelsHidden {
+		return s.PendingKey != 0 && strings.ContainsRune(labelKeys, s.PendingKey)
+	}
+	return s.LabelPlacement == labelsHeader
+}
+
+// drawHeaderLabel draws the label of a hunk header row, with a check mark
+// when the hunk is staged or applied, and returns the column after it.
+func drawHeaderLabel(s *State, screen tcell.Screen, col, y int, line DisplayLine, maxCol int) int {
+	if !s.headerLabels() || line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+		return col
+	}
+	h := &s.Hunks[line.HunkIdx]
+	if h.Label == "" {
+		return col
+	}
+	style := s.Theme.Label
+	if s.Zen || s.LabelPlacement == labelsHidden {
+		style = style.Reverse(true) // stands out over the header while pending
+	}
+	col = drawText(screen, col, y, h.Label, style, maxCol)
+	if h.Staged || h.Applied {
+		col = drawText(screen, col, y, "✓", s.Theme.DiffAdded, maxCol)
+	}
+	return drawText(screen, col, y, " ", s.Theme.Default, maxCol)
+}
+
+// CycleLabelPlacement moves hunk labels from the gutter to the headers, to
+// hidden until needed, and back.
+func (s *State) CycleLabelPlacement() {
+	s.LabelPlacement = (s.LabelPlacement + 1) % labelPlacements
+	s.BuildLines()
+	s.ClampScroll()
+	switch s.LabelPlacement {
+	case labelsGutter:
+		s.FlashMsg = "Labels in the gutter"
+	case labelsHeader:
+		s.FlashMsg = "Labels on hunk headers"
+	default:
+		s.FlashMsg = "Labels hidden until y, A or another label key"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestLabelPlacement(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(60, 8)
+
+	s := &State{Screen: sim, Width: 60, Height: 8, HL: NewHighlighter(),
+		Hunks: []Hunk{{Label: "q", File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{{Op: '+', Content: "x := 1"}}}}}
+	s.BuildLines()
+	gutter := s.LabelGutter
+	row := func(y int) string { return screenText(sim, 60, y+1)[y] }
+
+	Render(s)
+	if !strings.HasPrefix(row(2), "q │ +1") {
+		t.Errorf("gutter label: %q", row(2))
+	}
+
+	s.CycleLabelPlacement()
+	if s.LabelGutter != gutter-1 {
+		t.Errorf("header labels leave a gutter of %d, want %d", s.LabelGutter, gutter-1)
+	}
+	Render(s)
+	if !strings.Contains(row(2), "│ q +1") || strings.Contains(row(3), "q") {
+		t.Errorf("header labels: %q, %q", row(2), row(3))
+	}
+
+	s.CycleLabelPlacement()
+	Render(s)
+	if strings.Contains(row(2), "q") {
+		t.Errorf("hidden labels drawn: %q", row(2))
+	}
+	HandleKey(s, makeKeyEvent('y'))
+	Render(s)
+	if !strings.Contains(row(2), "│ q +1") {
+		t.Errorf("pending y should show the labels: %q", row(2))
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	Render(s)
+	if strings.Contains(row(2), "q") {
+		t.Errorf("labels stay after the pending key is dropped: %q", row(2))
+	}
+
+	s.CycleLabelPlacement()
+	if s.LabelPlacement != labelsGutter || s.LabelGutter != gutter {
+		t.Errorf("placement %d, gutter %d after a full cycle", s.LabelPlacement, s.LabelGutter)
+	}
+}
+
+func TestParseConfigLabels(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("labels = hidden\n"))
+	if err != nil || cfg.Labels != "hidden" {
+		t.Errorf("labels = %q, %v", cfg.Labels, err)
+	}
+	if _, err := parseConfig(strings.NewReader("labels = left\n")); err == nil {
+		t.Error("labels = left should be an error")
+	}
+}
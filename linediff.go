@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Line diff algorithms accepted by --diff-algorithm. They are used by the
+// built-in engine and passed through to git diff.
+const (
+	algoMyers     = "myers"
+	algoMinimal   = "minimal"
+	algoPatience  = "patience"
+	algoHistogram = "histogram"
+)
+
+// parseDiffAlgorithm validates a --diff-algorithm value. "" keeps the default.
+func parseDiffAlgorithm(v string) (string, error) {
+	switch v {
+	case "", algoMyers, algoMinimal, algoPatience, algoHistogram:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid diff algorithm %q (want myers, minimal, patience or histogram)", v)
+}
+
+// myersMaxCost bounds the work of the Myers search (roughly the square of
+// the edit distance). Past it the remaining region is treated as replaced
+// wholesale, which keeps huge unrelated inputs from exhausting memory.
+const myersMaxCost = 1 << 25
+
+// histogramMaxOccurrences skips lines that repeat more often than this when
+// looking for histogram anchors, like git's implementation.
+const histogramMaxOccurrences = 64
+
+// lineMatch pairs equal lines: index a in the old lines and b in the new.
+type lineMatch struct{ a, b int }
+
+// diffLines returns the pairs of lines algo keeps unchanged between a and b,
+// in increasing order on both sides.
+func diffLines(a, b []string, algo string) []lineMatch {
+	var out []lineMatch
+	switch algo {
+	case algoPatience:
+		patienceDiff(a, b, 0, len(a), 0, len(b), &out)
+	case algoHistogram:
+		histogramDiff(a, b, 0, len(a), 0, len(b), &out)
+	default:
+		myersDiff(a, b, 0, len(a), 0, len(b), &out)
+	}
+	return out
+}
+
+// trimCommon matches the common prefix of a[a0:a1] and b[b0:b1] into out
+// and returns the remaining region plus the length of the common suffix,
+// which the caller matches after diffing the middle.
+func trimCommon(a, b []string, a0, a1, b0, b1 int, out *[]lineMatch) (int, int, int, int, int) {
+	for a0 < a1 && b0 < b1 && a[a0] == b[b0] {
+		*out = append(*out, lineMatch{a0, b0})
+		a0++
+		b0++
+	}
+	n := 0
+	for a1-n > a0 && b1-n > b0 && a[a1-n-1] == b[b1-n-1] {
+		n++
+	}
+	return a0, a1 - n, b0, b1 - n, n
+}
+
+// appendSuffix matches the n common lines ending at a1 and b1.
+func appendSuffix(a1, b1, n int, out *[]lineMatch) {
+	for k := 0; k < n; k++ {
+		*out = append(*out, lineMatch{a1 + k, b1 + k})
+	}
+}
+
+// myersDiff finds a shortest edit script with the greedy O(ND) algorithm.
+func myersDiff(a, b []string, a0, a1, b0, b1 int, out *[]lineMatch) {
+	a0, a1, b0, b1, suffix := trimCommon(a, b, a0, a1, b0, b1, out)
+	defer appendSuffix(a1, b1, suffix, out)
+	n, m := a1-a0, b1-b0
+	if n == 0 || m == 0 {
+		return
+	}
+
+	maxD := n + m
+	off := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// trace[d] holds v[-d..d] as it was before step d
+	var trace [][]int
+	cost := 0
+	for d := 0; d <= maxD; d++ {
+		cost += 2*d + 1
+		if cost > myersMaxCost {
+			return
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[a0+x] == b[b0+y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				myersBacktrack(trace, n, m, a0, b0, out)
+				return
+			}
+		}
+	}
+}
+
+// myersBacktrack walks the saved frontiers back from (n, m) and appends the
+// diagonal moves (equal lines) in order.
+func myersBacktrack(trace [][]int, n, m, a0, b0 int, out *[]lineMatch) {
+	var rev []lineMatch
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		snap := trace[d]
+		at := func(k int) int { return snap[k+d] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, lineMatch{a0 + x, b0 + y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, lineMatch{a0 + x, b0 + y})
+	}
+	for i := len(rev) - 1; i >= 0; i-- {
+		*out = append(*out, rev[i])
+	}
+}
+
+// patienceDiff anchors on lines that occur exactly once on each side,
+// keeps the longest increasing run of them and recurses between anchors.
+// Regions without unique lines fall back to Myers.
+func patienceDiff(a, b []string, a0, a1, b0, b1 int, out *[]lineMatch) {
+	a0, a1, b0, b1, suffix := trimCommon(a, b, a0, a1, b0, b1, out)
+	defer appendSuffix(a1, b1, suffix, out)
+	if a0 == a1 || b0 == b1 {
+		return
+	}
+
+	type occurrence struct{ countA, countB, posA, posB int }
+	occ := make(map[string]*occurrence)
+	for i := a0; i < a1; i++ {
+		o := occ[a[i]]
+		if o == nil {
+			o = &occurrence{}
+			occ[a[i]] = o
+		}
+		o.countA++
+		o.posA = i
+	}
+	for j := b0; j < b1; j++ {
+		if o := occ[b[j]]; o != nil {
+			o.countB++
+			o.posB = j
+		}
+	}
+	var unique []lineMatch
+	for i := a0; i < a1; i++ {
+		if o := occ[a[i]]; o.countA == 1 && o.countB == 1 {
+			unique = append(unique, lineMatch{i, o.posB})
+		}
+	}
+	anchors := longestIncreasing(unique)
+	if len(anchors) == 0 {
+		myersDiff(a, b, a0, a1, b0, b1, out)
+		return
+	}
+	pa, pb := a0, b0
+	for _, an := range anchors {
+		patienceDiff(a, b, pa, an.a, pb, an.b, out)
+		*out = append(*out, an)
+		pa, pb = an.a+1, an.b+1
+	}
+	patienceDiff(a, b, pa, a1, pb, b1, out)
+}
+
+// longestIncreasing returns the longest subsequence of ms (ordered by a)
+// whose b positions also increase.
+func longestIncreasing(ms []lineMatch) []lineMatch {
+	if len(ms) == 0 {
+		return nil
+	}
+	// tails[k] is the index in ms of the smallest tail of a run of length k+1
+	var tails []int
+	prev := make([]int, len(ms))
+	for i, m := range ms {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if ms[tails[mid]].b < m.b {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
+	}
+	run := make([]lineMatch, len(tails))
+	for i, k := tails[len(tails)-1], len(tails)-1; i >= 0; i, k = prev[i], k-1 {
+		run[k] = ms[i]
+	}
+	return run
+}
+
+// histogramDiff anchors on the longest run of equal lines built around the
+// line that occurs least often in the old side, then recurses on either
+// side of it. Regions without a usable anchor fall back to Myers.
+func histogramDiff(a, b []string, a0, a1, b0, b1 int, out *[]lineMatch) {
+	a0, a1, b0, b1, suffix := trimCommon(a, b, a0, a1, b0, b1, out)
+	defer appendSuffix(a1, b1, suffix, out)
+	if a0 == a1 || b0 == b1 {
+		return
+	}
+
+	positions := make(map[string][]int)
+	for i := a0; i < a1; i++ {
+		positions[a[i]] = append(positions[a[i]], i)
+	}
+	bestA, bestB, bestLen, bestCount := -1, -1, 0, histogramMaxOccurrences
+	for j := b0; j < b1; j++ {
+		ps := positions[b[j]]
+		if len(ps) == 0 || len(ps) > bestCount || len(ps) > histogramMaxOccurrences {
+			continue
+		}
+		for _, i := range ps {
+			// Grow the run of equal lines through (i, j)
+			s, t := i, j
+			for s > a0 && t > b0 && a[s-1] == b[t-1] {
+				s--
+				t--
+			}
+			e := i + 1
+			for e < a1 && e-i+j < b1 && a[e] == b[e-i+j] {
+				e++
+			}
+			if len(ps) < bestCount || e-s > bestLen {
+				bestA, bestB, bestLen, bestCount = s, t, e-s, len(ps)
+			}
+		}
+	}
+	if bestA < 0 {
+		myersDiff(a, b, a0, a1, b0, b1, out)
+		return
+	}
+	histogramDiff(a, b, a0, bestA, b0, bestB, out)
+	for k := 0; k < bestLen; k++ {
+		*out = append(*out, lineMatch{bestA + k, bestB + k})
+	}
+	histogramDiff(a, b, bestA+bestLen, a1, bestB+bestLen, b1, out)
+}
+
+// lineEdit is one line of an edit script: op ' ' keeps a[a] (== b[b]),
+// '-' removes a[a] and '+' adds b[b].
+type lineEdit struct {
+	op   rune
+	a, b int
+}
+
+// editScript expands matches into a full edit script. Removals come before
+// additions within each changed region, like git.
+func editScript(a, b []string, matches []lineMatch) []lineEdit {
+	var edits []lineEdit
+	i, j := 0, 0
+	for _, m := range append(matches, lineMatch{len(a), len(b)}) {
+		for ; i < m.a; i++ {
+			edits = append(edits, lineEdit{'-', i, j})
+		}
+		for ; j < m.b; j++ {
+			edits = append(edits, lineEdit{'+', i, j})
+		}
+		if m.a < len(a) {
+			edits = append(edits, lineEdit{' ', i, j})
+			i++
+			j++
+		}
+	}
+	return edits
+}
+
+// unifiedDiff returns a git-style unified diff of a and b with the given
+// number of context lines, or nil when they are equal. The lines end in
+// their newlines; a last line without one is marked "\ No newline at end of
+// file". The output parses with parseDiff.
+func unifiedDiff(oldName, newName string, a, b []string, context int, algo string) []byte {
+	edits := editScript(a, b, diffLines(a, b, algo))
+	var sb strings.Builder
+	end := 0
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		// Extend over changes separated by at most 2*context equal lines
+		last := k
+		for e := k; e < len(edits); e++ {
+			if edits[e].op != ' ' {
+				last = e
+			} else if e-last > 2*context {
+				break
+			}
+		}
+		start := max(k-context, end)
+		end = min(last+context+1, len(edits))
+		if sb.Len() == 0 {
+			oldName = filepath.ToSlash(strings.TrimPrefix(oldName, "/"))
+			newName = filepath.ToSlash(strings.TrimPrefix(newName, "/"))
+			fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", oldName, newName, oldName, newName)
+		}
+		writeUnifiedHunk(&sb, a, b, edits[start:end])
+		k = end
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+	return []byte(sb.String())
+}
+
+// writeUnifiedHunk writes one @@ hunk. A side with no lines is numbered by
+// the line before it, as git does.
+func writeUnifiedHunk(sb *strings.Builder, a, b []string, edits []lineEdit) {
+	oldCount, newCount := 0, 0
+	for _, e := range edits {
+		if e.op != '+' {
+			oldCount++
+		}
+		if e.op != '-' {
+			newCount++
+		}
+	}
+	oldStart, newStart := edits[0].a+1, edits[0].b+1
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, e := range edits {
+		var text string
+		if e.op == '+' {
+			text = b[e.b]
+		} else {
+			text = a[e.a]
+		}
+		sb.WriteRune(e.op)
+		sb.WriteString(text)
+		if !strings.HasSuffix(text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// applyEdits rebuilds both sides from an edit script.
+func applyEdits(a, b []string, edits []lineEdit) (oldSide, newSide []string) {
+	for _, e := range edits {
+		switch e.op {
+		case ' ':
+			oldSide = append(oldSide, a[e.a])
+			newSide = append(newSide, b[e.b])
+		case '-':
+			oldSide = append(oldSide, a[e.a])
+		case '+':
+			newSide = append(newSide, b[e.b])
+		}
+	}
+	return oldSide, newSide
+}
+
+func TestDiffLinesRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d", "}", ""}
+	gen := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+	for _, algo := range []string{algoMyers, algoPatience, algoHistogram} {
+		for n := 0; n < 200; n++ {
+			a, b := gen(), gen()
+			matches := diffLines(a, b, algo)
+			for i, m := range matches {
+				if a[m.a] != b[m.b] || (i > 0 && (m.a <= matches[i-1].a || m.b <= matches[i-1].b)) {
+					t.Fatalf("%s: bad match %v in %v", algo, m, matches)
+				}
+			}
+			oldSide, newSide := applyEdits(a, b, editScript(a, b, matches))
+			if strings.Join(oldSide, "\n") != strings.Join(a, "\n") || strings.Join(newSide, "\n") != strings.Join(b, "\n") {
+				t.Fatalf("%s: edit script does not rebuild the inputs", algo)
+			}
+		}
+	}
+}
+
+func TestMyersIsMinimal(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	// The classic example from Myers' paper has an edit distance of 5
+	if got := len(diffLines(a, b, algoMyers)); got != (len(a)+len(b)-5)/2 {
+		t.Errorf("myers kept %d lines, want %d", got, (len(a)+len(b)-5)/2)
+	}
+}
+
+func TestUnifiedDiffParses(t *testing.T) {
+	a := strings.SplitAfter("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "\n")
+	b := strings.SplitAfter("1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\n", "\n")
+	a, b = a[:len(a)-1], b[:len(b)-1]
+	raw := unifiedDiff("/tmp/a.txt", "/tmp/b.txt", a, b, 1, "")
+	hunks, err := parseDiff(raw)
+	if err != nil {
+		t.Fatalf("parseDiff: %v\n%s", err, raw)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2:\n%s", len(hunks), raw)
+	}
+	if hunks[0].OldStart != 1 || hunks[1].OldStart != 10 || hunks[1].NewStart != 10 {
+		t.Errorf("hunk starts = %d/%d, %d/%d", hunks[0].OldStart, hunks[0].NewStart, hunks[1].OldStart, hunks[1].NewStart)
+	}
+	if unifiedDiff("a", "b", a, a, 3, "") != nil {
+		t.Error("equal inputs should produce no diff")
+	}
+}
+
+func TestUnifiedDiffEmptySide(t *testing.T) {
+	raw := unifiedDiff("a", "b", nil, []string{"x\n", "y\n"}, 3, "")
+	if !strings.Contains(string(raw), "@@ -0,0 +1,2 @@") {
+		t.Errorf("unexpected header:\n%s", raw)
+	}
+	if _, err := parseDiff(raw); err != nil {
+		t.Errorf("parseDiff: %v", err)
+	}
+}
+
+func TestUnifiedDiffNoNewlineAtEnd(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(oldPath, []byte("x\ny"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte("x\ny\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := runNoIndexDiff(oldPath, newPath, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(raw), "@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+y\n") {
+		t.Errorf("adding the final newline:\n%s", raw)
+	}
+	hunks, err := parseDiff(raw)
+	if err != nil {
+		t.Fatalf("parseDiff: %v\n%s", err, raw)
+	}
+	if len(hunks) != 1 || len(hunks[0].Lines) != 3 {
+		t.Errorf("parsed %+v", hunks)
+	}
+	if raw, _ := runNoIndexDiff(oldPath, oldPath, 3, ""); raw != nil {
+		t.Errorf("a file without a final newline differs from itself:\n%s", raw)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// EventJump is posted by the --listen server when an editor asks the view
+// to show a file at a line.
+type EventJump struct {
+	t    time.Time
+	file string
+	line int
+}
+
+func (e *EventJump) When() time.Time { return e.t }
+
+// listenAddr splits a --listen address into a network and an address: a
+// path (with a slash or ending in .sock) is a Unix socket, anything else a
+// TCP port, which must be on the loopback interface (":7777" binds
+// 127.0.0.1).
+func listenAddr(addr string) (network, address string, err error) {
+	if strings.Contains(addr, "/") || strings.HasSuffix(addr, ".sock") {
+		return "unix", addr, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		if _, numErr := strconv.Atoi(addr); numErr != nil {
+			return "", "", fmt.Errorf("want a socket path, host:port or a port, got %q", addr)
+		}
+		host, port = "", addr
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", "", fmt.Errorf("%s is not a loopback address; wiff only listens locally", host)
+	}
+	return "tcp", net.JoinHostPort(host, port), nil
+}
+
+// listen serves the editor protocol on addr until the program exits:
+//
+//	POST /jump?file=<path>&line=<n>   show the file at the line
+//	POST /reload                      reload the diff
+//
+// Requests become events on screen, handled by the main loop. A socket
+// left behind by a wiff that was killed is replaced.
+func listen(screen tcell.Screen, addr string) (net.Listener, error) {
+	network, address, err := listenAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	handler := listenHandler(screen)
+	if network == "unix" {
+		removeStaleSocket(address)
+	} else {
+		handler = loopbackHost(handler)
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("listening for editor commands", "addr", l.Addr().String())
+	go func() { _ = http.Serve(l, handler) }()
+	return l, nil
+}
+
+// removeStaleSocket removes the Unix socket at path when nothing answers on
+// it any more.
+func removeStaleSocket(path string) {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode().Type() != os.ModeSocket {
+		return
+	}
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = c.Close() // another wiff is listening: binding fails, as it should
+		return
+	}
+	logger.Info("removing a stale socket", "path", path)
+	_ = os.Remove(path)
+}
+
+// loopbackHost refuses requests to a TCP port whose Host isn't loopback,
+// which a web page can only send by rebinding its own name to 127.0.0.1.
+func loopbackHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if ip := net.ParseIP(strings.Trim(host, "[]")); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			http.Error(w, "wiff only answers requests for localhost", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listenHandler returns the HTTP handler of the editor protocol. It takes
+// POST requests only, and none from a web page (with an Origin), so that
+// sites open in a browser can't drive the viewer.
+func listenHandler(screen tcell.Screen) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jump", func(w http.ResponseWriter, r *http.Request) {
+		file := r.FormValue("file")
+		line, err := strconv.Atoi(r.FormValue("line"))
+		if r.FormValue("line") == "" {
+			line, err = 0, nil
+		}
+		if file == "" || err != nil || line < 0 {
+			http.Error(w, "want file=<path> and an optional line=<n>", http.StatusBadRequest)
+			return
+		}
+		logger.Debug("editor jump", "file", file, "line", line)
+		_ = screen.PostEvent(&EventJump{t: time.Now(), file: file, line: line})
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("editor reload")
+		_ = screen.PostEvent(&EventReload{t: time.Now(), editor: true})
+		fmt.Fprintln(w, "ok")
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "want POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Origin") != "" {
+			http.Error(w, "wiff doesn't take requests from web pages", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// JumpToLocation shows file (absolute, or relative to the working
+// directory or the repository root) at line: the row of that line on the
+// new side, or the nearest changed row of the file when the line is
+// unchanged. Line 0 shows the start of the file.
+func (s *State) JumpToLocation(file string, line int) {
+	file = s.diffPath(file)
+	best, bestDist := -1, 0
+	for i, dl := range s.Lines {
+		if dl.HunkIdx < 0 || dl.HunkIdx >= len(s.Hunks) || dl.Continuation || s.Hunks[dl.HunkIdx].File != file {
+			continue
+		}
+		n := dl.NewLineNo
+		if s.SideBySide {
+			n = dl.Right.LineNo
+		}
+		if n <= 0 {
+			continue
+		}
+		dist := max(n-line, line-n)
+		if best < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	if best < 0 {
+		s.FlashMsg = file + " has no changes in this diff"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	if line == 0 {
+		s.JumpToFile(file)
+		return
+	}
+	s.JumpTo(best)
+	if bestDist > 0 {
+		s.FlashMsg = fmt.Sprintf("%s:%d is unchanged; showing the nearest change", file, line)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+	}
+}
+
+// diffPath returns file as the diff names it: relative to the repository
+// root for an absolute path or one relative to the working directory
+// below it.
+func (s *State) diffPath(file string) string {
+	for i := range s.Hunks {
+		if s.Hunks[i].File == file {
+			return file
+		}
+	}
+	root, err := repo.Root()
+	if err != nil {
+		return file
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestListenAddr(t *testing.T) {
+	for _, tt := range []struct{ addr, network, address, err string }{
+		{addr: "/tmp/wiff.sock", network: "unix", address: "/tmp/wiff.sock"},
+		{addr: "wiff.sock", network: "unix", address: "wiff.sock"},
+		{addr: "7777", network: "tcp", address: "127.0.0.1:7777"},
+		{addr: ":7777", network: "tcp", address: "127.0.0.1:7777"},
+		{addr: "[::1]:7777", network: "tcp", address: "[::1]:7777"},
+		{addr: "0.0.0.0:7777", err: "not a loopback address"},
+		{addr: "nope", err: "want a socket path"},
+	} {
+		network, address, err := listenAddr(tt.addr)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("listenAddr(%q) error = %v, want %q", tt.addr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || network != tt.network || address != tt.address {
+			t.Errorf("listenAddr(%q) = %s %s %v", tt.addr, network, address, err)
+		}
+	}
+}
+
+func TestListenHandler(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	h := listenHandler(sim)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/jump?file=a.go&line=12", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("jump: %d %s", rec.Code, rec.Body)
+	}
+	if ev, ok := sim.PollEvent().(*EventJump); !ok || ev.file != "a.go" || ev.line != 12 {
+		t.Errorf("jump event = %+v", ev)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/jump?line=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad jump: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/reload", nil))
+	if ev, ok := sim.PollEvent().(*EventReload); !ok || !ev.editor || rec.Code != http.StatusOK {
+		t.Errorf("reload: %d, event %+v", rec.Code, ev)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/jump?file=a.go", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET jump: %d", rec.Code)
+	}
+	req := httptest.NewRequest("POST", "/reload", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("reload from a web page: %d", rec.Code)
+	}
+}
+
+func TestLoopbackHost(t *testing.T) {
+	h := loopbackHost(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for host, want := range map[string]int{
+		"127.0.0.1:7777":     http.StatusOK,
+		"localhost:7777":     http.StatusOK,
+		"[::1]:7777":         http.StatusOK,
+		"localhost":          http.StatusOK,
+		"evil.example:7777":  http.StatusForbidden,
+		"192.168.1.10:7777":  http.StatusForbidden,
+		"localhost.evil.com": http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("POST", "/reload", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Host %s: %d, want %d", host, rec.Code, want)
+		}
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	path := filepath.Join(t.TempDir(), "wiff.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like a wiff that was killed: the socket file stays without a server
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	l, err := listen(sim, path)
+	if err != nil {
+		t.Fatalf("listen on a stale socket: %v", err)
+	}
+	if _, err := listen(sim, path); err == nil {
+		t.Error("a second listener took the socket of a live one")
+	}
+	_ = l.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("closing left the socket: %v", err)
+	}
+}
+
+func TestJumpToLocation(t *testing.T) {
+	var lines []Line
+	for i := 0; i < 40; i++ {
+		lines = append(lines, Line{Op: ' ', Content: "x"})
+	}
+	lines[20] = Line{Op: '+', Content: "new"}
+	s := &State{Width: 80, Height: 10, ContextLines: 3, Hunks: []Hunk{
+		{File: "a.go", OldStart: 1, NewStart: 1, Lines: lines},
+		{File: "b.go", OldStart: 1, NewStart: 1, Lines: []Line{{Op: '+', Content: "b"}}},
+	}}
+	s.BuildLines()
+	s.JumpToLocation("a.go", 21)
+	if got := s.Lines[s.focusLine()]; got.NewLineNo != 21 {
+		t.Errorf("jumped to new line %d, want 21", got.NewLineNo)
+	}
+	s.JumpToLocation("a.go", 500)
+	if got := s.Lines[s.focusLine()]; got.NewLineNo != 40 || !strings.Contains(s.FlashMsg, "nearest") {
+		t.Errorf("jumped to new line %d, flash %q", got.NewLineNo, s.FlashMsg)
+	}
+	s.JumpToLocation("c.go", 1)
+	if !strings.Contains(s.FlashMsg, "c.go has no changes") {
+		t.Errorf("flash %q", s.FlashMsg)
+	}
+}
+
+func TestRemoteEditorArgs(t *testing.T) {
+	t.Setenv("NVIM", "")
+	s := &State{}
+	if args := s.remoteEditorArgs("/r/a b.go", 3); args != nil {
+		t.Errorf("no server: %q", args)
+	}
+	t.Setenv("NVIM", "/run/nvim.0")
+	want := `nvim --server /run/nvim.0 --remote-send <C-\><C-N>:edit +3 /r/a\ b.go<CR>`
+	if got := strings.Join(s.remoteEditorArgs("/r/a b.go", 3), " "); got != want {
+		t.Errorf("$NVIM: %s", got)
+	}
+	s.Config.EditorServer = "emacs work"
+	if got := strings.Join(s.remoteEditorArgs("/r/a.go", 0), " "); got != "emacsclient --no-wait --socket-name=work +1 /r/a.go" {
+		t.Errorf("emacs: %s", got)
+	}
+	if _, err := parseConfig(strings.NewReader("editor_server = nvim\n")); err == nil {
+		t.Error("nvim needs an address")
+	}
+}
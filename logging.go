@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// logger records what wiff does, for debugging: the commands it runs,
+// watcher events, reload timings and failed applies. It discards everything
+// unless --log-file (or --verbose without a TUI) is given.
+var logger = slog.New(slog.DiscardHandler)
+
+// setupLogging sends the log to file, or with verbose to stderr when no TUI
+// draws over it (--json, --command, --script). It returns a func closing
+// the file.
+func setupLogging(file string, verbose, tui bool) (func(), error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	switch {
+	case file != "":
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		logger = slog.New(slog.NewTextHandler(f, opts))
+		logger.Info("start", "version", version, "args", os.Args[1:])
+		return func() { _ = f.Close() }, nil
+	case verbose && !tui:
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	}
+	return func() {}, nil
+}
+
+// logCommand logs cmd, run from start until now, with its error if any.
+// Exit statuses are only debug level: many commands fail as an answer,
+// like git rev-parse outside a repository.
+func logCommand(cmd *exec.Cmd, start time.Time, err error) {
+	attrs := []any{"args", cmd.Args, "took", time.Since(start).Round(time.Microsecond)}
+	if cmd.Dir != "" {
+		attrs = append(attrs, "dir", cmd.Dir)
+	}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	logger.Debug("exec", attrs...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFile(t *testing.T) {
+	defer func() { logger = slog.New(slog.DiscardHandler) }()
+	path := filepath.Join(t.TempDir(), "wiff.log")
+	closeLog, err := setupLogging(path, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "version")
+	logCommand(cmd, time.Now(), nil)
+	reloads.land(reloads.gen) // nothing running: not logged
+	closeLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, want := range []string{"msg=start", "msg=exec", "args=\"[git version]\""} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log lacks %q:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "msg=reload") {
+		t.Errorf("log has a reload that never ran:\n%s", log)
+	}
+}
+
+func TestVerboseOnlyWithoutTUI(t *testing.T) {
+	defer func() { logger = slog.New(slog.DiscardHandler) }()
+	if _, err := setupLogging("", true, true); err != nil {
+		t.Fatal(err)
+	}
+	if logger.Enabled(t.Context(), slog.LevelError) {
+		t.Error("--verbose should not log to stderr under the TUI")
+	}
+	if _, err := setupLogging("", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !logger.Enabled(t.Context(), slog.LevelDebug) {
+		t.Error("--verbose should log debug messages without a TUI")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// lowBandwidthReload is the shortest time between two watch reloads in
+// low-bandwidth mode.
+const lowBandwidthReload = 3 * time.Second
+
+// SetLowBandwidth turns the mode for slow (SSH) links on or off. On, it
+// drops background tints and syntax highlighting, which cost the most
+// escape sequences and per-cell work, stops pointer motion reports and
+// throttles watch reloads (see reloadThrottled). Off, tints and
+// highlighting come back as they were, or as h and b last set them.
+func (s *State) SetLowBandwidth(on bool) {
+	if on == s.LowBandwidth {
+		return
+	}
+	s.LowBandwidth = on
+	if on {
+		s.lowBandwidthSaved = [2]bool{s.DiffBg, s.SyntaxHighlight}
+		s.DiffBg, s.SyntaxHighlight = false, false
+		s.Hover = mouseHover{}
+	} else {
+		s.DiffBg, s.SyntaxHighlight = s.lowBandwidthSaved[0], s.lowBandwidthSaved[1]
+	}
+	if s.Screen != nil {
+		s.enableMouse()
+	}
+}
+
+// enableMouse turns on mouse reporting; in low-bandwidth mode without
+// pointer motion, which would be sent and drawn on every move.
+func (s *State) enableMouse() {
+	if s.LowBandwidth {
+		s.Screen.EnableMouse(tcell.MouseButtonEvents | tcell.MouseDragEvents)
+	} else {
+		s.Screen.EnableMouse()
+	}
+}
+
+// deferRender reports whether drawing can wait for events already queued:
+// in low-bandwidth mode a burst of keys or output is drawn once, after its
+// last event.
+func (s *State) deferRender() bool {
+	return s.LowBandwidth && s.Screen.HasPendingEvent()
+}
+
+// ToggleLowBandwidth switches low-bandwidth mode and says so.
+func (s *State) ToggleLowBandwidth() {
+	s.SetLowBandwidth(!s.LowBandwidth)
+	if s.LowBandwidth {
+		s.FlashMsg = "Low-bandwidth mode: no tints or highlighting, slower reloads"
+	} else {
+		s.FlashMsg = "Low-bandwidth mode off"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// reloadThrottled reports whether a watch reload has to wait. In
+// low-bandwidth mode reloads come at most every lowBandwidthReload; a
+// skipped one is posted again for when the wait is over.
+func (s *State) reloadThrottled() bool {
+	now := time.Now()
+	wait := lowBandwidthReload - now.Sub(s.lastReload)
+	if !s.LowBandwidth || wait <= 0 {
+		s.lastReload = now
+		s.reloadQueued = false
+		return false
+	}
+	if !s.reloadQueued && s.Screen != nil {
+		s.reloadQueued = true
+		time.AfterFunc(wait, func() {
+			_ = s.Screen.PostEvent(&EventReload{t: time.Now()})
+		})
+	}
+	return true
+}
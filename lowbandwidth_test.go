@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestLowBandwidthRestoresTintsAndHighlighting(t *testing.T) {
+	s := &State{DiffBg: true, SyntaxHighlight: false}
+	HandleKey(s, makeKeyEvent('P'))
+	if !s.LowBandwidth || s.DiffBg || s.SyntaxHighlight {
+		t.Fatalf("on: LowBandwidth=%v DiffBg=%v SyntaxHighlight=%v", s.LowBandwidth, s.DiffBg, s.SyntaxHighlight)
+	}
+	HandleKey(s, makeKeyEvent('P'))
+	if s.LowBandwidth || !s.DiffBg || s.SyntaxHighlight {
+		t.Errorf("off: LowBandwidth=%v DiffBg=%v SyntaxHighlight=%v", s.LowBandwidth, s.DiffBg, s.SyntaxHighlight)
+	}
+}
+
+func TestLowBandwidthKeepsToggles(t *testing.T) {
+	s := &State{DiffBg: true, SyntaxHighlight: true}
+	for _, r := range "PhbbP" {
+		HandleKey(s, makeKeyEvent(r))
+	}
+	// h turned highlighting back on, b twice left the tints off
+	if s.LowBandwidth || s.DiffBg || !s.SyntaxHighlight {
+		t.Errorf("off: LowBandwidth=%v DiffBg=%v SyntaxHighlight=%v", s.LowBandwidth, s.DiffBg, s.SyntaxHighlight)
+	}
+}
+
+func TestLowBandwidthDefersRenders(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	s := &State{Screen: sim}
+	_ = sim.PostEvent(tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone))
+	if s.deferRender() {
+		t.Error("renders are never deferred outside low-bandwidth mode")
+	}
+	s.SetLowBandwidth(true)
+	if !s.deferRender() {
+		t.Error("a render with a key queued should wait for it")
+	}
+	sim.PollEvent()
+	if s.deferRender() {
+		t.Error("a render with nothing queued should not wait")
+	}
+}
+
+func TestReloadThrottled(t *testing.T) {
+	s := &State{}
+	if s.reloadThrottled() || s.reloadThrottled() {
+		t.Fatal("reloads are never throttled outside low-bandwidth mode")
+	}
+	s.SetLowBandwidth(true)
+	if !s.reloadThrottled() {
+		t.Fatal("a reload right after another should wait")
+	}
+	s.lastReload = time.Now().Add(-lowBandwidthReload)
+	if s.reloadThrottled() {
+		t.Error("a reload after the wait should go through")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -14,7 +15,115 @@ import (
 
 func main() {
 	opts := parseArgs()
+	tui := !opts.json && !opts.quickfix && opts.command == "" && opts.script == ""
+	closeLog, err := setupLogging(opts.logFile, opts.verbose, tui)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --log-file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: config: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.theme == "" {
+		opts.theme = cfg.Theme
+	}
+
+	if opts.diffAlgorithm == "" {
+		opts.diffAlgorithm = cfg.DiffAlgorithm
+	}
+	if _, err := parseDiffAlgorithm(opts.diffAlgorithm); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.tutor {
+		dir, err := setupTutor()
+		if dir != "" {
+			defer os.RemoveAll(dir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --tutor: %v\n", err)
+			os.Exit(1)
+		}
+		opts.refs, opts.staged = nil, false
+	}
+	if repo, err = pickVCS(opts.vcs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --vcs: %v\n", err)
+		os.Exit(1)
+	}
+	if _, git := repo.(gitVCS); opts.staged && !git {
+		fmt.Fprintf(os.Stderr, "Error: --staged needs git's index, %s has none\n", repo.Name())
+		os.Exit(1)
+	}
+	if opts.refs, opts.paths, err = resolveArgs(opts.refs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.coverageFile != "" {
+		if opts.coverage, err = loadCoverage(opts.coverageFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --coverage: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.diagFile != "" {
+		if opts.diagnostics, err = readDiagnostics(opts.diagFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --diagnostics: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	stopProfiles, err := startProfiles(opts.cpuProfile, opts.memProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer stopProfiles()
 
+	if opts.json || opts.quickfix {
+		runJSON(opts, cfg)
+		return
+	}
+	if opts.script != "" {
+		text, err := readScript(opts.script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --script: %v\n", err)
+			os.Exit(1)
+		}
+		opts.command += text
+	}
+	if opts.command != "" {
+		keys, err := parseKeys(opts.command)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		runScript(opts, cfg, keys)
+		return
+	}
+
+	if opts.theme == "" {
+		// Only here: asking the terminal for its background puts it in raw
+		// mode for a moment, which runs without a terminal must not do
+		opts.theme = defaultTheme()
+	}
+	depth, autoDepth, err := parseColorDepth(opts.color)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if depth == colorTrue && !autoDepth {
+		// tcell enables 24-bit output when COLORTERM asks for it
+		_ = os.Setenv("COLORTERM", "truecolor")
+	}
+
+	keepView := opts.noAltScreen || cfg.NoAltScreen
+	if keepView {
+		disableAltScreen()
+	}
 	screen, err := tcell.NewScreen()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create screen: %v\n", err)
@@ -26,92 +135,267 @@ func main() {
 	}
 	screen.EnableMouse()
 	defer screen.Fini()
-	w, h := screen.Size()
-	state := &State{
-		Refs:            opts.refs,
-		Staged:          opts.staged,
-		Screen:          screen,
-		Width:           w,
-		Height:          h,
-		PipeMode:        isPipe(),
-		SideBySide:      opts.sideBySide,
-		LineNumbers:     !opts.noLineNumbers,
-		ContextLines:    opts.contextLines,
-		TreeOpen:        opts.explorer,
-		TreeFocused:     opts.explorer,
-		Wrap:            !opts.noWrap,
-		SyntaxHighlight: !opts.noSyntax,
-		DiffBg:          !opts.noDiffBg,
-		WatchEnabled:    !isPipe(),
-		Theme:           NewUITheme(opts.theme),
-		HL:              NewHighlighter(),
+	ttyScreen = screen
+	watchSignals(screen)
+	if autoDepth {
+		depth = detectColorDepth(screen)
+	}
+	if depth < colorTrue {
+		screen = newColorScreen(screen, depth)
+	}
+	reserveKeys(cfg.commandKeys()...)
+	state := newState(opts, cfg, screen, depth)
+	// Deferred after screen.Fini, so it runs first and puts the terminal back
+	defer func() { recoverCrash(recover(), state) }()
+	state.SetLowBandwidth(opts.lowBandwidth)
+	state.Debug = opts.debug
+	if dir := seriesDir(opts.refs); dir != "" {
+		if state.Series, err = loadSeriesDir(dir); err != nil {
+			screen.Fini()
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := state.HL.SetBackend(cfg.Highlighter); err != nil {
+		screen.Fini()
+		fmt.Fprintf(os.Stderr, "Error: config: %v\n", err)
+		os.Exit(1)
 	}
-	state.HL.SetTheme(opts.theme)
 
+	runDiagnostics(state)
 	if err := loadDiff(state); err != nil {
 		screen.Fini()
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitStatus(err))
+	}
+	if opts.review {
+		state.ToggleReview()
 	}
 
+	if state.Series != nil && len(state.Series.Patches) > 1 {
+		OpenPatchList(state)
+	}
+	if len(state.Hunks) == 0 && !state.PipeMode && !state.NoRepo && !state.NoIndex {
+		OpenStartMenu(state)
+	}
+	if opts.tutor {
+		tutor = newTutor(state)
+	}
 	Render(state)
 
+	for _, args := range opts.tabs {
+		if err := OpenTab(state, args); err != nil {
+			screen.Fini()
+			fmt.Fprintf(os.Stderr, "Error: --tab %s: %v\n", args, err)
+			os.Exit(1)
+		}
+	}
+	if state.Tabs != nil {
+		state.Tabs.Current = 0
+	}
+	if opts.split != "" {
+		if err := OpenSplit(state, opts.split, true); err != nil {
+			screen.Fini()
+			fmt.Fprintf(os.Stderr, "Error: --split %s: %v\n", opts.split, err)
+			os.Exit(1)
+		}
+		state.Split.Focus = 0
+	}
+
 	if !state.PipeMode {
 		go watchAndUpdate(state)
 	}
+	closeListener := func() {}
+	if opts.listen != "" {
+		l, err := listen(screen, opts.listen)
+		if err != nil {
+			screen.Fini()
+			fmt.Fprintf(os.Stderr, "Error: --listen: %v\n", err)
+			os.Exit(1)
+		}
+		// Closing removes the socket file, which os.Exit would leave behind
+		closeListener = func() { _ = l.Close() }
+		defer closeListener()
+	}
 
+	inputErr := state.InputErr
 	for {
 		ev := screen.PollEvent()
+		lastAction = describeEvent(ev)
 		switch ev := ev.(type) {
 		case *tcell.EventKey:
 			if HandleKey(state, ev) {
+				if inputErr != nil {
+					screen.Fini()
+					stopProfiles()
+					closeListener()
+					os.Exit(exitBadInput)
+				}
+				if keepView {
+					keepFinalView(screen)
+				}
 				return
 			}
-			Render(state)
+			if tutor != nil {
+				tutor.observe(state, ev)
+			}
+			state = state.activeTab().focusedPane()
+			if !state.deferRender() {
+				Render(state)
+			}
+		case *EventSignal:
+			if isStopSignal(ev.sig) {
+				suspend(state)
+				Render(state)
+				break
+			}
+			screen.Fini()
+			stopProfiles()
+			closeListener()
+			os.Exit(signalStatus(ev.sig))
 		case *tcell.EventMouse:
+			x, y := ev.Position()
+			if ev.Buttons() == tcell.ButtonNone {
+				// Pointer motion (or a release): highlight what it is over
+				if !state.LowBandwidth && state.hoverAt(x, y) {
+					Render(state)
+				}
+				break
+			}
+			var ok bool
+			if state, x, y, ok = state.paneAt(x, y); !ok {
+				break
+			}
+			if state.inAssistantPanel(x) {
+				switch ev.Buttons() {
+				case tcell.WheelUp:
+					scrollAssistant(state, -state.Config.wheelStep())
+				case tcell.WheelDown:
+					scrollAssistant(state, state.Config.wheelStep())
+				}
+				Render(state)
+				break
+			}
 			switch ev.Buttons() {
 			case tcell.WheelUp:
-				state.ScrollBy(-3)
+				state.ScrollBy(-state.Config.wheelStep())
+				state.KeepCursorInView()
 				Render(state)
 			case tcell.WheelDown:
-				state.ScrollBy(3)
+				state.ScrollBy(state.Config.wheelStep())
+				state.KeepCursorInView()
 				Render(state)
 			case tcell.Button1:
-				x, y := ev.Position()
 				if state.TreeOpen && x < treeWidth {
 					handleTreeClick(state, y)
-				} else if y < state.Height-1 {
+				} else if y < state.viewRows() {
 					HandleDiffClick(state, x, y)
 				}
 				Render(state)
+			case tcell.Button2: // middle-click
+				if (!state.TreeOpen || x >= treeWidth) && y < state.viewRows() {
+					HandleDiffMiddleClick(state, y)
+				}
+				Render(state)
 			case tcell.Button3: // right-click
-				x, y := ev.Position()
-				if (!state.TreeOpen || x >= treeWidth) && y < state.Height-1 {
+				if (!state.TreeOpen || x >= treeWidth) && y < state.viewRows() {
 					HandleDiffRightClick(state, x, y)
 				}
 				Render(state)
 			}
 		case *tcell.EventResize:
 			w, h := ev.Size()
-			state.Width, state.Height = w, h
-			state.BuildLines()
-			state.ClampScroll()
-			screen.Sync()
+			state.resize(w, h)
+			if !state.LowBandwidth {
+				screen.Sync() // full repaint, skipped on slow links
+			}
 			Render(state)
 		case *EventLabelTimeout:
 			ResolvePendingLabel(state)
 			Render(state)
+		case *EventAssistantOutput:
+			handleAssistantOutput(state, ev)
+			if !state.deferRender() {
+				Render(state)
+			}
+		case *EventCheckOutput:
+			handleCheckOutput(ev)
+			if !state.deferRender() {
+				Render(state)
+			}
+		case *EventCommandDone:
+			showCommandOutput(state, ev.command, ev.output, ev.err)
+			Render(state)
+		case *EventCopied:
+			handleCopied(ev)
+			Render(state)
 		case *EventReload:
-			if state.WatchEnabled {
-				reloadDiff(state)
+			if ev.editor {
+				startReload(state, diffPanes(state))
+				break
+			}
+			if state.reloadThrottled() {
+				break
+			}
+			watchReloads.Add(1)
+			startReload(state, watchedPanes(state))
+		case *EventJump:
+			state.JumpToLocation(ev.file, ev.line)
+			Render(state)
+		case *EventReloadDone:
+			if finishReload(ev) {
 				Render(state)
 			}
 		}
 	}
 }
 
+// newState returns the viewer state for opts on screen, before any diff is
+// loaded.
+func newState(opts cliOpts, cfg Config, screen tcell.Screen, depth colorDepth) *State {
+	w, h := screen.Size()
+	s := &State{
+		Refs:            opts.refs,
+		Paths:           opts.paths,
+		Staged:          opts.staged,
+		Screen:          screen,
+		Width:           w,
+		Height:          h,
+		PipeMode:        isPipe() || seriesDir(opts.refs) != "",
+		NoIndex:         !isPipe() && bothFiles(opts.refs),
+		SideBySide:      opts.sideBySide,
+		LineNumbers:     !opts.noLineNumbers,
+		ContextLines:    opts.contextLines,
+		TreeOpen:        opts.explorer,
+		TreeFocused:     opts.explorer,
+		Wrap:            !opts.noWrap,
+		SyntaxHighlight: !opts.noSyntax,
+		DiffBg:          !opts.noDiffBg && depth >= color256, // tints need more than 16 colors
+		WatchEnabled:    !isPipe() && seriesDir(opts.refs) == "",
+		Theme:           NewUITheme(opts.theme),
+		HL:              NewHighlighter(),
+		Config:          cfg,
+		ColorMoved:      opts.colorMoved || cfg.ColorMoved,
+		ConfirmApply:    opts.confirmApply || cfg.ConfirmApply,
+		DiffAlgorithm:   opts.diffAlgorithm,
+		FindRenames:     opts.findRenames,
+		Excludes:        append(append(cfg.Excludes, loadWiffignore()...), opts.excludes...),
+		Strict:          opts.strict,
+		Coverage:        opts.coverage,
+		Diagnostics:     opts.diagnostics,
+	}
+	s.LabelPlacement, _ = parseLabelPlacement(cfg.Labels)
+	s.SearchCase, _ = parseSearchCase(cfg.SearchCase)
+	s.SearchWord = cfg.SearchWord
+	s.ColumnRatio = loadColumnRatio()
+	s.HL.SetTheme(opts.theme)
+	s.HL.SetOverrides(cfg.Languages)
+	return s
+}
+
 type cliOpts struct {
 	refs          []string
+	paths         []string // limit the diff to these, after "--" or told apart by resolveArgs
 	staged        bool
 	sideBySide    bool
 	noLineNumbers bool
@@ -121,6 +405,34 @@ type cliOpts struct {
 	noDiffBg      bool
 	noSyntax      bool
 	theme         string
+	color         string
+	colorMoved    bool
+	diffAlgorithm string
+	findRenames   string
+	excludes      []string
+	tabs          []string // --tab arguments, one extra tab each
+	split         string   // --split arguments, a diff shown beside the first
+	json          bool     // print the parsed diff as JSON instead of viewing it
+	quickfix      bool     // print the hunk locations as file:line: text
+	command       string   // --command keys replayed without a terminal
+	script        string   // --script file of keys, run after --command
+	lowBandwidth  bool     // --low-bandwidth: fewer redraws for slow links
+	cpuProfile    string   // --cpuprofile file
+	memProfile    string   // --memprofile file, written on exit
+	debug         bool     // --debug overlay
+	vcs           string   // --vcs: git, jj or hg instead of the detected one
+	strict        bool     // --strict: piped input that isn't a diff is an error
+	coverageFile  string   // --coverage report: Go coverprofile, lcov or cobertura
+	coverage      Coverage // the report read from coverageFile
+	diagFile      string   // --diagnostics file: golangci-lint JSON or file:line:col: text
+	diagnostics   []Diagnostic
+	review        bool   // --review: start in review mode
+	noAltScreen   bool   // --no-alt-screen: leave the last view in the scrollback
+	logFile       string // --log-file: debug log of commands, watcher events, reloads
+	verbose       bool   // --verbose: the log on stderr when there is no TUI
+	confirmApply  bool   // --confirm-apply: confirm each stage with its patch
+	tutor         bool   // --tutor: the tutorial on a made-up repository
+	listen        string // --listen: socket or local port for editor jump commands
 }
 
 func parseArgs() cliOpts {
@@ -129,6 +441,10 @@ func parseArgs() cliOpts {
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch {
+		case arg == "--":
+			// The rest are paths; resolveArgs splits them off
+			opts.refs = append(opts.refs, args[i:]...)
+			i = len(args)
 		case arg == "-h" || arg == "--help":
 			printUsage()
 			os.Exit(0)
@@ -137,6 +453,93 @@ func parseArgs() cliOpts {
 			os.Exit(0)
 		case arg == "--themes":
 			ListThemes()
+		case arg == "--man":
+			writeManPage(os.Stdout)
+			os.Exit(0)
+		case arg == "--low-bandwidth":
+			opts.lowBandwidth = true
+		case arg == "--debug":
+			opts.debug = true
+		case arg == "--strict":
+			opts.strict = true
+		case arg == "--review":
+			opts.review = true
+		case arg == "--no-alt-screen":
+			opts.noAltScreen = true
+		case arg == "--verbose":
+			opts.verbose = true
+		case arg == "--confirm-apply":
+			opts.confirmApply = true
+		case arg == "--tutor":
+			opts.tutor = true
+		case arg == "--listen":
+			if i+1 < len(args) {
+				i++
+				opts.listen = args[i]
+			}
+		case strings.HasPrefix(arg, "--listen="):
+			opts.listen = strings.TrimPrefix(arg, "--listen=")
+		case arg == "--log-file":
+			if i+1 < len(args) {
+				i++
+				opts.logFile = args[i]
+			}
+		case strings.HasPrefix(arg, "--log-file="):
+			opts.logFile = strings.TrimPrefix(arg, "--log-file=")
+		case arg == "--coverage":
+			if i+1 < len(args) {
+				i++
+				opts.coverageFile = args[i]
+			}
+		case strings.HasPrefix(arg, "--coverage="):
+			opts.coverageFile = strings.TrimPrefix(arg, "--coverage=")
+		case arg == "--diagnostics":
+			if i+1 < len(args) {
+				i++
+				opts.diagFile = args[i]
+			}
+		case strings.HasPrefix(arg, "--diagnostics="):
+			opts.diagFile = strings.TrimPrefix(arg, "--diagnostics=")
+		case arg == "--vcs":
+			if i+1 < len(args) {
+				i++
+				opts.vcs = args[i]
+			}
+		case strings.HasPrefix(arg, "--vcs="):
+			opts.vcs = strings.TrimPrefix(arg, "--vcs=")
+		case arg == "--cpuprofile" || arg == "--memprofile":
+			if i+1 < len(args) {
+				i++
+				if arg == "--cpuprofile" {
+					opts.cpuProfile = args[i]
+				} else {
+					opts.memProfile = args[i]
+				}
+			}
+		case strings.HasPrefix(arg, "--cpuprofile="):
+			opts.cpuProfile = strings.TrimPrefix(arg, "--cpuprofile=")
+		case strings.HasPrefix(arg, "--memprofile="):
+			opts.memProfile = strings.TrimPrefix(arg, "--memprofile=")
+		case arg == "--json":
+			opts.json = true
+		case arg == "--quickfix":
+			opts.quickfix = true
+		case arg == "--command":
+			if i+1 < len(args) {
+				i++
+				opts.command = args[i]
+			}
+		case strings.HasPrefix(arg, "--command="):
+			opts.command = strings.TrimPrefix(arg, "--command=")
+		case arg == "--script":
+			if i+1 < len(args) {
+				i++
+				opts.script = args[i]
+			}
+		case strings.HasPrefix(arg, "--script="):
+			opts.script = strings.TrimPrefix(arg, "--script=")
+		case arg == "--color-moved":
+			opts.colorMoved = true
 		case arg == "--staged" || arg == "--cached":
 			opts.staged = true
 		case arg == "-t":
@@ -144,6 +547,47 @@ func parseArgs() cliOpts {
 				i++
 				opts.theme = args[i]
 			}
+		case arg == "--color":
+			if i+1 < len(args) {
+				i++
+				opts.color = args[i]
+			}
+		case arg == "--diff-algorithm":
+			if i+1 < len(args) {
+				i++
+				opts.diffAlgorithm = args[i]
+			}
+		case strings.HasPrefix(arg, "--diff-algorithm="):
+			opts.diffAlgorithm = strings.TrimPrefix(arg, "--diff-algorithm=")
+		case arg == "--exclude":
+			if i+1 < len(args) {
+				i++
+				opts.excludes = append(opts.excludes, args[i])
+			}
+		case strings.HasPrefix(arg, "--exclude="):
+			opts.excludes = append(opts.excludes, strings.TrimPrefix(arg, "--exclude="))
+		case arg == "--tab":
+			if i+1 < len(args) {
+				i++
+				opts.tabs = append(opts.tabs, args[i])
+			}
+		case strings.HasPrefix(arg, "--tab="):
+			opts.tabs = append(opts.tabs, strings.TrimPrefix(arg, "--tab="))
+		case arg == "--split":
+			if i+1 < len(args) {
+				i++
+				opts.split = args[i]
+			}
+		case strings.HasPrefix(arg, "--split="):
+			opts.split = strings.TrimPrefix(arg, "--split=")
+		case arg == "--find-renames" || arg == "-M":
+			opts.findRenames = "on"
+		case strings.HasPrefix(arg, "--find-renames="):
+			opts.findRenames = strings.TrimPrefix(arg, "--find-renames=")
+		case strings.HasPrefix(arg, "-M"):
+			opts.findRenames = arg[2:]
+		case strings.HasPrefix(arg, "--color="):
+			opts.color = strings.TrimPrefix(arg, "--color=")
 		case arg == "-s":
 			opts.sideBySide = true
 		case arg == "-e":
@@ -169,64 +613,9 @@ func parseArgs() cliOpts {
 			opts.theme = env
 		}
 	}
-	if opts.theme == "" {
-		opts.theme = "monokai"
-	}
 	return opts
 }
 
-func printUsage() {
-	fmt.Print(`wiff - a terminal diff viewer
-
-Usage: wiff [flags] [ref] [ref2]
-
-Flags:
-  -s          Side-by-side mode
-  -e          Open file explorer
-  -N          Disable line numbers (on by default)
-  -W          Disable line wrapping (on by default)
-  -B          Disable diff background tints (on by default)
-  -S          Disable syntax highlighting (on by default)
-  -U<n>       Context lines (default 3)
-  -t <name>   Color theme (default: monokai, env: WIFF_THEME)
-  --staged    Show staged changes (same as --cached)
-  --cached    Show staged changes (same as --staged)
-  --themes    List available themes
-  -v, --version  Show version
-  -h, --help     Show this help
-
-Arguments:
-  ref         Git ref to diff against (default: unstaged changes)
-  ref1 ref2   Diff between two refs
-
-Examples:
-  wiff              Show unstaged changes
-  wiff HEAD         Diff against HEAD
-  wiff HEAD~3       Diff against 3 commits ago
-  wiff HEAD~3..HEAD Diff a commit range
-  wiff main feature Diff between branches
-  wiff --staged     Show staged changes
-  wiff -s           Side-by-side mode
-  git diff | wiff   Read diff from pipe
-
-Keyboard Shortcuts:
-  j/k         Scroll up/down          s   Toggle side-by-side
-  d/u         Half page down/up       n   Toggle line numbers
-  g/G         Jump to top/bottom      w   Toggle wrap
-  ^D/^U       Half page down/up       e   Toggle file explorer
-  +/-         More/less context       h   Toggle syntax highlight
-  ]c/[c       Next/prev hunk          b   Toggle diff background
-  ]f/[f       Next/prev file          /   Search
-  Tab         Cycle to next file      W   Toggle watch mode
-  Shift+Tab   Cycle to prev file      f   Full file view
-  y+label     Yank added lines        o   Open in $EDITOR
-  Y+label     Yank removed lines      F   Follow mode (watch)
-  p+label     Yank patch              ?   Help overlay
-  c+label     Copy result (new code)  q   Quit
-  A+label     Stage/unstage hunk
-`)
-}
-
 func isPipe() bool {
 	fi, err := os.Stdin.Stat()
 	if err != nil {
@@ -236,43 +625,204 @@ func isPipe() bool {
 }
 
 func loadDiff(s *State) error {
-	var raw []byte
-	var err error
-
-	if s.PipeMode {
-		raw, err = io.ReadAll(os.Stdin)
-		if err != nil {
-			return err
-		}
-	} else {
-		raw, err = runGitDiff(s.Refs, s.ContextLines, s.Staged)
-		if err != nil {
-			return err
+	if !s.PipeMode && !s.NoIndex {
+		// Outside a repository there is no diff to run; the empty view
+		// says so and what to run instead
+		if _, err := repo.Root(); err != nil {
+			s.NoRepo = true
+			s.Hunks = nil
+			buildTree(s)
+			s.BuildLines()
+			return nil
 		}
 	}
+	start := time.Now()
+	raw, err := s.readDiff()
+	if err != nil {
+		return err
+	}
+	if !s.PipeMode {
+		s.Branch, s.Tracking = repo.Branch(), repo.Tracking()
+	}
+	if s.PipeMode && s.Series == nil && isMbox(raw) {
+		s.Series = &Series{Patches: splitMbox(raw)}
+		raw = s.Series.Patches[0].Diff
+	}
 
-	hunks, err := parseDiff(raw)
+	hunks, err := s.parseHunks(raw)
+	if s.PipeMode && s.Series == nil {
+		// Shown in place of the diff, unless --strict fails on it
+		err = checkInput(raw, err)
+		if ie, ok := err.(*InputError); ok && !s.Strict {
+			s.InputErr, err = ie, nil
+		}
+	}
 	if err != nil {
 		return err
 	}
 	s.Hunks = hunks
+	s.IndexSplit = s.loadIndexSplit(hunks)
 	buildTree(s)
+	s.loadFileSizes()
 	s.BuildLines()
 	s.ClampScroll()
+	logger.Info("load", "diff", s.RefDisplay(), "hunks", len(hunks), "took", time.Since(start).Round(time.Microsecond))
 
 	return nil
 }
 
-func runGitDiff(refs []string, contextLines int, staged bool) ([]byte, error) {
-	args := []string{"diff", "--no-color", fmt.Sprintf("-U%d", contextLines)}
-	if staged {
+// readDiff returns the raw diff: the current patch of a series, piped in
+// on stdin, or from runDiff.
+func (s *State) readDiff() ([]byte, error) {
+	if s.Series != nil {
+		return s.Series.Patches[s.Series.Current].Diff, nil
+	}
+	if s.PipeMode {
+		return io.ReadAll(os.Stdin)
+	}
+	return s.runDiff()
+}
+
+// runDiff runs git diff for the refs, or diffs the two files with the
+// built-in engine in no-index mode.
+func (s *State) runDiff() ([]byte, error) {
+	if s.NoIndex {
+		return runNoIndexDiff(s.Refs[0], s.Refs[1], s.ContextLines, s.DiffAlgorithm)
+	}
+	return runDiffCommand(context.Background(), repo.DiffArgs(s))
+}
+
+// gitDiffArgs returns the git diff arguments for the current refs and
+// options. Reloads and context changes rebuild them, so options set on the
+// command line stay in effect.
+func (s *State) gitDiffArgs() []string {
+	args := []string{"diff", "--no-color", fmt.Sprintf("-U%d", s.ContextLines)}
+	if s.DiffAlgorithm != "" {
+		args = append(args, "--diff-algorithm="+s.DiffAlgorithm)
+	}
+	if s.FindRenames != "" {
+		args = append(args, findRenamesArg(s.FindRenames))
+	}
+	if s.Staged {
 		args = append(args, "--staged")
 	}
-	args = append(args, refs...)
+	// textconv drivers convert binary files to text we can show; external
+	// diff drivers print something that isn't a unified diff
+	args = append(args, "--textconv", "--no-ext-diff")
+	args = append(args, s.Refs...)
+	if len(s.Paths) > 0 {
+		args = append(append(args, "--"), s.Paths...)
+	}
+	return args
+}
+
+// findRenamesArg turns a FindRenames setting into the git flag: "on" for
+// git's default similarity, otherwise a threshold such as "50%".
+func findRenamesArg(v string) string {
+	if v == "on" {
+		return "--find-renames"
+	}
+	return "--find-renames=" + v
+}
 
-	cmd := exec.Command("git", args...)
+// parseHunks parses diff output and drops excluded files.
+func (s *State) parseHunks(raw []byte) ([]Hunk, error) {
+	hunks, err := s.parseJob()(raw)
+	if err != nil {
+		return nil, err
+	}
+	return s.keepHunks(hunks), nil
+}
+
+// parseJob returns the parser of the diff's output, for running off the
+// main goroutine. In no-index mode the diff headers can't carry absolute
+// paths, so hunks are named after the new file as given.
+func (s *State) parseJob() func(raw []byte) ([]Hunk, error) {
+	if !s.NoIndex {
+		return parseDiff
+	}
+	file := s.Refs[1]
+	return func(raw []byte) ([]Hunk, error) {
+		hunks, err := parseDiff(raw)
+		for i := range hunks {
+			hunks[i].File = file
+		}
+		return hunks, err
+	}
+}
+
+// keepHunks drops the excluded files of parsed hunks and loads what the
+// view keeps about the files left.
+func (s *State) keepHunks(hunks []Hunk) []Hunk {
+	hunks = s.excludeHunks(hunks)
+	s.Generated = s.generatedFiles(hunks)
+	s.Textconv = s.textconvFiles(hunks)
+	s.Conflicts = s.conflictFiles(hunks)
+	s.Covered = s.Coverage.coverageFiles(hunks)
+	s.DiagLines = diagnosticFiles(s.Diagnostics, hunks)
+	if !s.PipeMode && !s.NoIndex {
+		s.Notes = loadNotes(notesPath())
+	}
+	return hunks
+}
+
+// bothFiles reports whether args are exactly two regular files, which wiff
+// diffs directly instead of treating them as refs.
+func bothFiles(args []string) bool {
+	if len(args) != 2 {
+		return false
+	}
+	for _, a := range args {
+		fi, err := os.Stat(a)
+		if err != nil || !fi.Mode().IsRegular() {
+			return false
+		}
+	}
+	return true
+}
+
+// runNoIndexDiff diffs two files with the built-in engine, so no git
+// repository (or git binary) is needed.
+func runNoIndexDiff(oldPath, newPath string, contextLines int, algo string) ([]byte, error) {
+	a, err := readLines(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readLines(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return unifiedDiff(oldPath, newPath, a, b, contextLines, algo), nil
+}
+
+// readLines returns the lines of a file with their newlines, so that a last
+// line without one differs from the same line with one.
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+// runDiffCommand runs the diff command argv, killing it when ctx is
+// canceled.
+func runDiffCommand(ctx context.Context, argv []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	start := time.Now()
 	out, err := cmd.Output()
+	logCommand(cmd, start, err)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if _, ok := err.(*exec.ExitError); !ok {
 			return nil, err
 		}
@@ -283,7 +833,8 @@ func runGitDiff(refs []string, contextLines int, staged bool) ([]byte, error) {
 // EventReload is a custom tcell event posted by the file watcher to trigger
 // a diff reload on the main goroutine (avoids data races).
 type EventReload struct {
-	t time.Time
+	t      time.Time
+	editor bool // asked for over --listen: every pane reloads, watched or not
 }
 
 func (e *EventReload) When() time.Time { return e.t }
@@ -311,6 +862,37 @@ func hunkFingerprint(h *Hunk) string {
 // reloadDiff re-runs git diff and rebuilds display lines while preserving
 // the user's scroll context (current file + approximate position).
 func reloadDiff(s *State) {
+	raw, err := s.runDiff()
+	if err != nil {
+		return
+	}
+	r, err := s.diffJob().finish(raw)
+	if err != nil {
+		return
+	}
+	applyReload(r, repo.Branch(), repo.Tracking())
+}
+
+// refreshDiff reloads the diff now, as a file change would.
+func refreshDiff(s *State) {
+	if s.PipeMode {
+		s.FlashMsg = "Piped diffs can't be refreshed"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	branch := s.Branch
+	runDiagnostics(s)
+	reloadDiff(s)
+	if s.Branch == branch { // else applyReload says where we are now
+		s.FlashMsg = "Refreshed"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+	}
+}
+
+// applyReload replaces the diff of a pane with the result of a new run,
+// keeping the user's place as reloadDiff describes.
+func applyReload(r reloadResult, branch string, tracking *Tracking) {
+	s := r.pane
 	// Remember where the user is
 	prevFile := s.CurrentFile()
 	prevScroll := s.Scroll
@@ -322,16 +904,16 @@ func reloadDiff(s *State) {
 	}
 	oldHunkCount := len(s.Hunks)
 
-	raw, err := runGitDiff(s.Refs, s.ContextLines, s.Staged)
-	if err != nil {
-		return
+	s.Hunks = s.keepHunks(r.hunks)
+	s.IndexSplit = r.split
+	s.refreshReview()
+	if s.Branch != "" && branch != "" && branch != s.Branch {
+		s.FlashMsg = "Switched to ⎇ " + branch
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
 	}
-	hunks, err := parseDiff(raw)
-	if err != nil {
-		return
-	}
-	s.Hunks = hunks
+	s.Branch, s.Tracking = branch, tracking
 	buildTree(s)
+	s.FileSizes = r.sizes
 	s.BuildLines()
 
 	// Follow mode: find first new hunk and scroll to it
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Mark is a position set with m{a-z}, kept as a line of a file rather than a
+// display row so it survives reloads, wrapping and layout changes.
+type Mark struct {
+	File string
+	Line int  // new-side line number, old-side for a removed line, 0 for the file header
+	Old  bool // Line is on the old side
+	Text string
+}
+
+// lastJumpMark holds the position before the last jump to a mark, for
+// going back with two quotes as in vim.
+const lastJumpMark = '\''
+
+// markAt returns the mark for display row idx, or false when the row is
+// not in a file.
+func (s *State) markAt(idx int) (Mark, bool) {
+	idx = s.logicalStart(idx)
+	if idx < 0 || idx >= len(s.Lines) {
+		return Mark{}, false
+	}
+	var m Mark
+	for i := idx; i >= 0; i-- {
+		if s.Lines[i].Style == StyleFileHeader {
+			m.File = s.Lines[i].Text
+			break
+		}
+	}
+	if m.File == "" {
+		return Mark{}, false
+	}
+	line := s.Lines[idx]
+	newNo, oldNo := line.NewLineNo, line.OldLineNo
+	if s.SideBySide {
+		newNo, oldNo = line.Right.LineNo, line.Left.LineNo
+	}
+	switch {
+	case newNo > 0:
+		m.Line = newNo
+		m.Text, _ = s.LineText(idx, true)
+	case oldNo > 0:
+		m.Line, m.Old = oldNo, true
+		m.Text, _ = s.LineText(idx, false)
+	}
+	return m, true
+}
+
+// markRow returns the display row of m: its line, else the closest line
+// before it in the file, else the file header; -1 when the file isn't shown.
+func (s *State) markRow(m Mark) int {
+	row, file := -1, ""
+	for i, line := range s.Lines {
+		if line.Style == StyleFileHeader {
+			file = line.Text
+			if file == m.File && row < 0 {
+				row = i
+			}
+			continue
+		}
+		if file != m.File || line.Continuation || m.Line == 0 {
+			continue
+		}
+		n := line.NewLineNo
+		if m.Old {
+			n = line.OldLineNo
+		}
+		if s.SideBySide {
+			n = line.Right.LineNo
+			if m.Old {
+				n = line.Left.LineNo
+			}
+		}
+		if n == m.Line && line.Style != StyleReplaced {
+			return i
+		}
+		if n > 0 && n < m.Line {
+			row = i
+		}
+	}
+	return row
+}
+
+// String formats m for messages and the marks list.
+func (m Mark) String() string {
+	if m.Line == 0 {
+		return m.File
+	}
+	if m.Old {
+		return fmt.Sprintf("%s:-%d", m.File, m.Line)
+	}
+	return fmt.Sprintf("%s:%d", m.File, m.Line)
+}
+
+// SetMark sets mark r at the focus line.
+func (s *State) SetMark(r rune) {
+	if r < 'a' || r > 'z' {
+		s.FlashMsg = "Marks are a-z"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	m, ok := s.markAt(s.focusLine())
+	if !ok {
+		return
+	}
+	if s.Marks == nil {
+		s.Marks = make(map[rune]Mark)
+	}
+	s.Marks[r] = m
+	s.FlashMsg = fmt.Sprintf("Mark %c at %s", r, m)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// JumpToMark jumps to mark r, remembering where from in lastJumpMark.
+func (s *State) JumpToMark(r rune) {
+	m, ok := s.Marks[r]
+	if !ok {
+		s.FlashMsg = fmt.Sprintf("Mark %c not set", r)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	row := s.markRow(m)
+	if row < 0 {
+		s.FlashMsg = fmt.Sprintf("Mark %c: %s is not in this view", r, m.File)
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	if from, ok := s.markAt(s.focusLine()); ok {
+		s.Marks[lastJumpMark] = from
+	}
+	s.JumpTo(row)
+}
+
+// openMarksPanel lists the marks with their file, line and text; selecting
+// one jumps to it.
+func openMarksPanel(s *State) {
+	var keys []rune
+	for r := range s.Marks {
+		if r != lastJumpMark {
+			keys = append(keys, r)
+		}
+	}
+	if len(keys) == 0 {
+		s.FlashMsg = "No marks (m{a-z} sets one)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	items := make([]string, len(keys))
+	for i, r := range keys {
+		m := s.Marks[r]
+		items[i] = fmt.Sprintf("%c  %s  %s", r, m, m.Text)
+	}
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("Marks (%d)", len(keys)),
+		Items: items,
+		OnSelect: func(s *State, i int) {
+			s.JumpToMark(keys[i])
+		},
+	})
+}
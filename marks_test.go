@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarksSurviveLayoutChanges(t *testing.T) {
+	s := &State{Height: 10, Width: 80}
+	s.Hunks = []Hunk{
+		{File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{{Op: ' ', Content: "one"}, {Op: '-', Content: "two"}, {Op: '+', Content: "2"}}},
+		bigHunk("b.go", 30),
+	}
+	s.BuildLines()
+
+	removed := s.lineForNew(0, 1) + 1
+	s.JumpTo(removed)
+	s.SetMark('a')
+	if m := s.Marks['a']; m.File != "a.go" || m.Line != 2 || !m.Old || m.Text != "two" {
+		t.Fatalf("mark a = %+v, want a.go old line 2", m)
+	}
+	s.JumpTo(s.lineForNew(1, 20))
+	s.SetMark('b')
+	s.JumpToMark('a')
+	s.JumpToMark(lastJumpMark)
+	if got := s.Lines[s.focusLine()].NewLineNo; got != 20 {
+		t.Errorf("'' landed on new line %d, want 20", got)
+	}
+
+	s.SideBySide = true
+	s.BuildLines()
+	s.JumpToMark('a')
+	if got := s.Lines[s.focusLine()].Left; got.LineNo != 2 {
+		t.Errorf("'a landed on old line %d, want 2", got.LineNo)
+	}
+
+	s.JumpToMark('c')
+	if s.FlashMsg != "Mark c not set" {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+	s.FilterFile = "b.go"
+	s.BuildLines()
+	s.JumpToMark('a')
+	if !strings.Contains(s.FlashMsg, "not in this view") {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+}
+
+func TestMarksPanel(t *testing.T) {
+	s := &State{Height: 20, Width: 80}
+	s.Hunks = []Hunk{bigHunk("b.go", 10)}
+	s.BuildLines()
+	s.JumpTo(s.lineForNew(0, 5))
+	s.SetMark('q')
+	s.JumpTo(s.lineForNew(0, 2))
+	s.SetMark('e')
+
+	openMarksPanel(s)
+	if s.Popup == nil {
+		t.Fatal("no marks panel")
+	}
+	if want := []string{"e  b.go:2  x", "q  b.go:5  x"}; strings.Join(s.Popup.Items, "|") != strings.Join(want, "|") {
+		t.Errorf("items = %q, want %q", s.Popup.Items, want)
+	}
+	s.Popup.OnSelect(s, 1)
+	if got := s.Lines[s.focusLine()].NewLineNo; got != 5 {
+		t.Errorf("selecting q jumped to line %d, want 5", got)
+	}
+}
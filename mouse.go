@@ -0,0 +1,212 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// mouseHover is what the mouse pointer is over, highlighted while it stays
+// there. The zero value is nothing.
+type mouseHover struct {
+	diff  bool // a line of the diff pane
+	tree  bool // a file of the tree
+	crumb bool // a segment of the breadcrumb
+	index int  // into Lines, TreeNodes or the breadcrumb
+}
+
+// HandleMouseMove notes what the pointer at x, y is over and reports
+// whether that changed, so the view needs drawing.
+func HandleMouseMove(s *State, x, y int) bool {
+	var h mouseHover
+	switch {
+	case s.TreeOpen && x < treeWidth:
+		if idx := s.TreeScroll + y - 2; y >= 2 && idx < len(s.TreeNodes) && !s.TreeNodes[idx].IsDir {
+			h = mouseHover{tree: true, index: idx}
+		}
+	case y >= s.viewRows(), s.inAssistantPanel(x):
+	case y == 0 && s.breadcrumbLine() >= 0:
+		if i := crumbAt(s.breadcrumb(), x); i >= 0 {
+			h = mouseHover{crumb: true, index: i}
+		}
+	default:
+		if idx, ok := clickedLine(s, y); ok {
+			h = mouseHover{diff: true, index: idx}
+		}
+	}
+	if h == s.Hover {
+		return false
+	}
+	s.Hover = h
+	return true
+}
+
+// drawHover tints the header of the hunk or file the pointer is over in the
+// diff, or its file in the tree. The breadcrumb underlines its own segment.
+func drawHover(s *State, visible int, sticky bool) {
+	h := s.Hover
+	switch {
+	case h.tree && h.index < len(s.TreeNodes):
+		if row := h.index - s.TreeScroll + 2; row >= 2 && row < visible {
+			tintRow(s, 0, treeWidth-1, row)
+		}
+	case h.diff && h.index < len(s.Lines):
+		line := s.Lines[h.index]
+		idx := h.index
+		if line.Style != StyleFileHeader {
+			if line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) || s.Hunks[line.HunkIdx].StartLine < 0 {
+				return
+			}
+			idx = s.Hunks[line.HunkIdx].StartLine
+		}
+		if row := idx - s.Scroll; row >= 0 && row < visible && !(row == 0 && sticky) {
+			tintRow(s, s.DiffX, s.DiffX+s.DiffWidth, row)
+		}
+	}
+}
+
+// tintRow re-colors the background of columns x0 to x1 of row y with the
+// cursor line's color, keeping what is drawn there.
+func tintRow(s *State, x0, x1, y int) {
+	for x := x0; x < x1; x++ {
+		mainc, combc, style, _ := s.Screen.GetContent(x, y)
+		s.Screen.SetContent(x, y, mainc, combc, style.Background(s.Theme.BgCursor))
+	}
+}
+
+// crumb is a segment of the breadcrumb on the top row of the diff pane:
+// the repository, a directory or the file, and the column it starts at.
+type crumb struct {
+	text string
+	x    int
+	path string // the directory or file; "" for the repository
+	file bool
+}
+
+// crumbSep separates the segments of the breadcrumb.
+const crumbSep = " › "
+
+// breadcrumbLine returns the index in Lines of the file header drawn on the
+// top row of the diff pane, the sticky one or the first line, or -1 when
+// the top row is not a file header.
+func (s *State) breadcrumbLine() int {
+	if i := s.stickyFileHeaderIdx(); i >= 0 {
+		return i
+	}
+	if s.Scroll >= 0 && s.Scroll < len(s.Lines) && s.Lines[s.Scroll].Style == StyleFileHeader {
+		return s.Scroll
+	}
+	return -1
+}
+
+// breadcrumb returns the segments of the top row's file header: the
+// repository (unless the diff is piped or of two files), each directory
+// and the file.
+func (s *State) breadcrumb() []crumb {
+	i := s.breadcrumbLine()
+	if i < 0 {
+		return nil
+	}
+	file := s.Lines[i].Text
+	var crumbs []crumb
+	x := s.DiffX + 3 // past the "── " of the header
+	add := func(text, path string, isFile bool) {
+		if len(crumbs) > 0 {
+			x += utf8.RuneCountInString(crumbSep)
+		}
+		crumbs = append(crumbs, crumb{text: text, x: x, path: path, file: isFile})
+		x += utf8.RuneCountInString(text)
+	}
+	if name := repoName(); name != "" && !s.PipeMode && !s.NoIndex {
+		add(name, "", false)
+	}
+	parts := strings.Split(file, "/")
+	for j, part := range parts[:len(parts)-1] {
+		add(part, strings.Join(parts[:j+1], "/"), false)
+	}
+	add(parts[len(parts)-1], file, true)
+	return crumbs
+}
+
+// crumbAt returns the index of the segment at column x, or -1.
+func crumbAt(crumbs []crumb, x int) int {
+	for i, c := range crumbs {
+		if x >= c.x && x < c.x+utf8.RuneCountInString(c.text) {
+			return i
+		}
+	}
+	return -1
+}
+
+// drawBreadcrumb draws the file name of the top row's header as its
+// breadcrumb, repo › dir › file, and returns the column after it.
+func drawBreadcrumb(s *State, screen tcell.Screen, y, rightEdge int) int {
+	col := s.DiffX + 3
+	for i, c := range s.breadcrumb() {
+		if i > 0 {
+			col = drawText(screen, col, y, crumbSep, s.Theme.Dim, rightEdge)
+		}
+		style := s.Theme.Dim
+		if c.file {
+			style = s.Theme.FileHeader
+		}
+		if s.Hover.crumb && s.Hover.index == i {
+			style = style.Underline(true)
+		}
+		col = drawText(screen, col, y, c.text, style, rightEdge)
+	}
+	return col
+}
+
+// HandleBreadcrumbClick runs the segment of the breadcrumb at column x:
+// the repository shows every file from the top, a directory jumps to its
+// first file, and the file shows only that file (again, every file). It
+// reports whether x was on a segment.
+func HandleBreadcrumbClick(s *State, x int) bool {
+	crumbs := s.breadcrumb()
+	i := crumbAt(crumbs, x)
+	if i < 0 {
+		return false
+	}
+	c := crumbs[i]
+	if c.file {
+		toggleFileFilter(s, c.path)
+		return true
+	}
+	if s.FilterFile != "" {
+		s.FilterFile = ""
+		s.BuildLines()
+	}
+	if c.path == "" {
+		s.MoveTo(0)
+		return true
+	}
+	for _, f := range s.orderedFiles() {
+		if strings.HasPrefix(f, c.path+"/") {
+			s.JumpToFile(f)
+			break
+		}
+	}
+	return true
+}
+
+// hoverAt follows the pointer at screen position x, y over the panes of s,
+// and reports whether the view needs drawing.
+func (s *State) hoverAt(x, y int) bool {
+	p, px, py, ok := s.paneUnder(x, y)
+	changed := false
+	if s.Split != nil {
+		// Only the pane under the pointer keeps a highlight
+		for _, pane := range s.Split.Panes {
+			if pane != p && pane.Hover != (mouseHover{}) {
+				pane.Hover = mouseHover{}
+				changed = true
+			}
+		}
+	}
+	if ok && HandleMouseMove(p, px, py) {
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func breadcrumbState(t *testing.T) (*State, tcell.SimulationScreen) {
+	t.Helper()
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Fini)
+	sim.SetSize(60, 30)
+	hunk := func(label, file string) Hunk {
+		return Hunk{Label: label, File: file, Header: "@@ -1 +1 @@", OldStart: 1, NewStart: 1,
+			Lines: []Line{{Op: '-', Content: "old"}, {Op: '+', Content: "new"}}}
+	}
+	// Piped, so the breadcrumb starts at the directories
+	s := &State{Screen: sim, Width: 60, Height: 30, Theme: NewUITheme("dracula"), PipeMode: true, Hunks: []Hunk{
+		hunk("q", "pkg/a/x.go"), hunk("r", "pkg/b/y.go"), hunk("t", "z.go"),
+	}}
+	s.BuildLines()
+	return s, sim
+}
+
+func TestBreadcrumb(t *testing.T) {
+	s, sim := breadcrumbState(t)
+	Render(s)
+	if got := screenText(sim, 60, 1)[0]; !strings.HasPrefix(got, "── pkg › a › x.go +1 −1 ──") {
+		t.Errorf("top row %q", got)
+	}
+	var texts []string
+	for _, c := range s.breadcrumb() {
+		texts = append(texts, c.text)
+	}
+	if got := strings.Join(texts, "|"); got != "pkg|a|x.go" {
+		t.Errorf("segments %s", got)
+	}
+
+	// The file filters to it, and again shows every file
+	HandleDiffClick(s, s.DiffX+13, 0)
+	if s.FilterFile != "pkg/a/x.go" {
+		t.Fatalf("filter %q after clicking the file", s.FilterFile)
+	}
+	HandleDiffClick(s, s.DiffX+13, 0)
+	if s.FilterFile != "" {
+		t.Fatalf("filter %q after clicking the file again", s.FilterFile)
+	}
+
+	// A directory jumps to its first file
+	for i, l := range s.Lines {
+		if l.Style == StyleFileHeader && l.Text == "z.go" {
+			s.Scroll = i
+		}
+	}
+	if c := s.breadcrumb(); len(c) != 1 || c[0].text != "z.go" {
+		t.Fatalf("breadcrumb of z.go: %+v", c)
+	}
+	s.Scroll = s.Hunks[1].StartLine
+	Render(s)
+	HandleDiffClick(s, s.DiffX+3, 0) // pkg, over the sticky header of y.go
+	if got := s.Lines[s.breadcrumbLine()].Text; got != "pkg/a/x.go" {
+		t.Errorf("clicking pkg showed %s", got)
+	}
+}
+
+func TestHoverHighlightsTheHunkHeader(t *testing.T) {
+	s, sim := breadcrumbState(t)
+	Render(s)
+	h := &s.Hunks[1]
+	content := h.StartLine + 2 // the added line
+	if !HandleMouseMove(s, s.DiffX+10, content-s.Scroll) || HandleMouseMove(s, s.DiffX+11, content-s.Scroll) {
+		t.Fatal("moving onto a line should redraw once")
+	}
+	Render(s)
+	_, _, style, _ := sim.GetContent(s.DiffX+20, h.StartLine-s.Scroll)
+	if _, bg, _ := style.Decompose(); bg != s.Theme.BgCursor {
+		t.Errorf("hunk header background %v, want the cursor line's", bg)
+	}
+	_, _, style, _ = sim.GetContent(s.DiffX+20, s.Hunks[0].StartLine-s.Scroll)
+	if _, bg, _ := style.Decompose(); bg == s.Theme.BgCursor {
+		t.Error("another hunk's header is highlighted")
+	}
+
+	if !HandleMouseMove(s, s.DiffX+4, 0) || !s.Hover.crumb || s.Hover.index != 0 {
+		t.Errorf("hover over the breadcrumb: %+v", s.Hover)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"unicode"
+)
+
+// movedMinAlnum is how many alphanumeric characters a block of lines needs
+// before it counts as moved, the same threshold git uses for --color-moved.
+const movedMinAlnum = 20
+
+// markMovedLines flags removed lines whose content is added elsewhere in
+// the diff (and added lines whose content was removed elsewhere), possibly
+// in another file. Only runs of consecutive such lines with enough
+// alphanumeric content are marked, so stray braces and blank lines that
+// happen to match don't light up.
+func markMovedLines(hunks []Hunk) {
+	removed := make(map[string]bool)
+	added := make(map[string]bool)
+	for _, h := range hunks {
+		for _, l := range h.Lines {
+			switch l.Op {
+			case '-':
+				removed[l.Content] = true
+			case '+':
+				added[l.Content] = true
+			}
+		}
+	}
+
+	for hi := range hunks {
+		lines := hunks[hi].Lines
+		for i := 0; i < len(lines); {
+			l := lines[i]
+			other := added
+			if l.Op == '+' {
+				other = removed
+			} else if l.Op != '-' {
+				i++
+				continue
+			}
+			if !other[l.Content] {
+				i++
+				continue
+			}
+			// Extend the block over consecutive lines of the same op that
+			// also appear on the other side
+			j, alnum := i, 0
+			for j < len(lines) && lines[j].Op == l.Op && other[lines[j].Content] {
+				alnum += countAlnum(lines[j].Content)
+				j++
+			}
+			if alnum >= movedMinAlnum {
+				for k := i; k < j; k++ {
+					lines[k].Moved = true
+				}
+			}
+			i = j
+		}
+	}
+}
+
+// movedBlock is a run of added lines whose content also appears elsewhere
+// in the diff: removed somewhere (moved) or added more than once (copied).
+type movedBlock struct {
+	Hunk    int    // index of the hunk holding the block
+	NewLine int    // new-side line number of the first line
+	Lines   int    // number of lines in the block
+	Source  string // file:line of the matching lines elsewhere
+	Copy    bool   // duplicated among the added lines rather than moved
+}
+
+// movedBlocks lists the moved and copied blocks of added lines, in diff
+// order. Moved blocks are the runs markMovedLines flagged; copied blocks
+// are runs of added lines that are all added elsewhere too, with the same
+// alphanumeric threshold.
+func movedBlocks(hunks []Hunk) []movedBlock {
+	type loc struct {
+		hunk, line int
+	}
+	removedAt := make(map[string]loc)
+	addedAt := make(map[string][]loc)
+	for hi, h := range hunks {
+		oldNo, newNo := h.OldStart, h.NewStart
+		for _, l := range h.Lines {
+			switch l.Op {
+			case '-':
+				if _, ok := removedAt[l.Content]; !ok {
+					removedAt[l.Content] = loc{hi, oldNo}
+				}
+				oldNo++
+			case '+':
+				addedAt[l.Content] = append(addedAt[l.Content], loc{hi, newNo})
+				newNo++
+			default:
+				oldNo++
+				newNo++
+			}
+		}
+	}
+	where := func(l loc) string {
+		return fmt.Sprintf("%s:%d", hunks[l.hunk].File, l.line)
+	}
+
+	var blocks []movedBlock
+	for hi, h := range hunks {
+		newNo := h.NewStart
+		lines := h.Lines
+		for i := 0; i < len(lines); {
+			l := lines[i]
+			if l.Op != '+' {
+				if l.Op != '-' {
+					newNo++
+				}
+				i++
+				continue
+			}
+			start := newNo
+			j := i
+			switch {
+			case l.Moved:
+				for j < len(lines) && lines[j].Op == '+' && lines[j].Moved {
+					j++
+				}
+				blocks = append(blocks, movedBlock{Hunk: hi, NewLine: start, Lines: j - i, Source: where(removedAt[l.Content])})
+			case len(addedAt[l.Content]) > 1:
+				alnum := 0
+				for j < len(lines) && lines[j].Op == '+' && !lines[j].Moved && len(addedAt[lines[j].Content]) > 1 {
+					alnum += countAlnum(lines[j].Content)
+					j++
+				}
+				if alnum >= movedMinAlnum {
+					// Point at the first other place the block's first line is added
+					for _, other := range addedAt[l.Content] {
+						if other != (loc{hi, start}) {
+							blocks = append(blocks, movedBlock{Hunk: hi, NewLine: start, Lines: j - i, Source: where(other), Copy: true})
+							break
+						}
+					}
+				}
+			default:
+				j++
+			}
+			newNo += j - i
+			i = j
+		}
+	}
+	return blocks
+}
+
+// openMovedPanel lists moved and copied blocks of added lines and turns on
+// moved-line coloring so they stand out; selecting one jumps to it.
+func openMovedPanel(s *State) {
+	blocks := movedBlocks(s.Hunks)
+	if len(blocks) == 0 {
+		s.FlashMsg = "No moved or duplicated lines"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.ColorMoved = true
+	items := make([]string, len(blocks))
+	for i, b := range blocks {
+		what := "moved from"
+		if b.Copy {
+			what = "duplicate of"
+		}
+		items[i] = fmt.Sprintf("%s:%d  %d lines %s %s", s.Hunks[b.Hunk].File, b.NewLine, b.Lines, what, b.Source)
+	}
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("Moved and duplicated lines (%d)", len(blocks)),
+		Items: items,
+		OnSelect: func(s *State, i int) {
+			if idx := s.lineForNew(blocks[i].Hunk, blocks[i].NewLine); idx >= 0 {
+				s.JumpTo(idx)
+			} else {
+				s.FlashMsg = "Block is not in the current view"
+				s.FlashExpiry = time.Now().Add(2 * time.Second)
+			}
+		},
+	})
+}
+
+func countAlnum(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestMarkMovedLinesAcrossFiles(t *testing.T) {
+	hunks := []Hunk{
+		{File: "a.go", Lines: []Line{
+			{Op: ' ', Content: "package a"},
+			{Op: '-', Content: "func helper(x int) int {"},
+			{Op: '-', Content: "\treturn x * 2"},
+			{Op: '-', Content: "}"},
+			{Op: '-', Content: "// unrelated removal"},
+		}},
+		{File: "b.go", Lines: []Line{
+			{Op: '+', Content: "func helper(x int) int {"},
+			{Op: '+', Content: "\treturn x * 2"},
+			{Op: '+', Content: "}"},
+			{Op: '+', Content: "var added = true"},
+		}},
+	}
+	markMovedLines(hunks)
+
+	wantA := []bool{false, true, true, true, false}
+	for i, l := range hunks[0].Lines {
+		if l.Moved != wantA[i] {
+			t.Errorf("a.go line %d (%q): Moved = %v, want %v", i, l.Content, l.Moved, wantA[i])
+		}
+	}
+	wantB := []bool{true, true, true, false}
+	for i, l := range hunks[1].Lines {
+		if l.Moved != wantB[i] {
+			t.Errorf("b.go line %d (%q): Moved = %v, want %v", i, l.Content, l.Moved, wantB[i])
+		}
+	}
+}
+
+func TestMarkMovedLinesIgnoresShortBlocks(t *testing.T) {
+	hunks := []Hunk{{Lines: []Line{
+		{Op: '-', Content: "}"},
+		{Op: '-', Content: "x := 1"},
+		{Op: ' ', Content: "ctx"},
+		{Op: '+', Content: "}"},
+		{Op: '+', Content: "x := 1"},
+	}}}
+	markMovedLines(hunks)
+	for i, l := range hunks[0].Lines {
+		if l.Moved {
+			t.Errorf("line %d (%q) should not be marked moved", i, l.Content)
+		}
+	}
+}
+
+func TestMovedBlocks(t *testing.T) {
+	hunks := []Hunk{
+		{File: "a.go", OldStart: 10, NewStart: 10, Lines: []Line{
+			{Op: ' ', Content: "package a"},
+			{Op: '-', Content: "func helper(x int) int {"},
+			{Op: '-', Content: "\treturn x * 2"},
+			{Op: '+', Content: "if err := validate(input); err != nil {"},
+			{Op: '+', Content: "\treturn err"},
+		}},
+		{File: "b.go", OldStart: 1, NewStart: 1, Lines: []Line{
+			{Op: '+', Content: "func helper(x int) int {"},
+			{Op: '+', Content: "\treturn x * 2"},
+			{Op: ' ', Content: "ctx"},
+			{Op: '+', Content: "if err := validate(input); err != nil {"},
+			{Op: '+', Content: "\treturn err"},
+		}},
+	}
+	markMovedLines(hunks)
+	blocks := movedBlocks(hunks)
+	want := []movedBlock{
+		{Hunk: 0, NewLine: 11, Lines: 2, Source: "b.go:4", Copy: true},
+		{Hunk: 1, NewLine: 1, Lines: 2, Source: "a.go:11"},
+		{Hunk: 1, NewLine: 4, Lines: 2, Source: "a.go:11", Copy: true},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("got %+v, want %+v", blocks, want)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("block %d: got %+v, want %+v", i, blocks[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notesFile is where notes on lines are kept, relative to the repository
+// root. It is plain text meant to be committed, so teammates see the notes
+// on their diffs too: one "path:line text" per line, lines of the file as
+// it is in the working tree.
+const notesFile = ".wiff/notes"
+
+// Notes maps files to their noted lines and the notes on them.
+type Notes map[string]map[int]string
+
+// notesPath returns the notes file of the repository, or "" outside one.
+func notesPath() string {
+	root, err := repo.Root()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(root, notesFile)
+}
+
+// loadNotes reads the notes file; a missing file has no notes. Blank lines,
+// '#' comments and lines that aren't "path:line text" are skipped.
+func loadNotes(path string) Notes {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	notes := make(Notes)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		where, text, _ := strings.Cut(line, " ")
+		i := strings.LastIndex(where, ":")
+		if i < 0 {
+			continue
+		}
+		n, err := strconv.Atoi(where[i+1:])
+		if err != nil || n <= 0 {
+			continue
+		}
+		notes.set(where[:i], n, strings.TrimSpace(text))
+	}
+	return notes
+}
+
+// set notes text on line n of file, or removes the note when text is "".
+func (n Notes) set(file string, line int, text string) {
+	if text == "" {
+		delete(n[file], line)
+		if len(n[file]) == 0 {
+			delete(n, file)
+		}
+		return
+	}
+	if n[file] == nil {
+		n[file] = make(map[int]string)
+	}
+	n[file][line] = text
+}
+
+// save writes the notes to path, sorted by file and line, creating the
+// directory as needed. With no notes left the file is removed.
+func (n Notes) save(path string) error {
+	if len(n) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	files := make([]string, 0, len(n))
+	for f := range n {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	var sb strings.Builder
+	sb.WriteString("# wiff notes: path:line text (lines of the working tree)\n")
+	for _, f := range files {
+		lines := make([]int, 0, len(n[f]))
+		for l := range n[f] {
+			lines = append(lines, l)
+		}
+		sort.Ints(lines)
+		for _, l := range lines {
+			fmt.Fprintf(&sb, "%s:%d %s\n", f, l, n[f][l])
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0o644)
+}
+
+// lineNote returns the note on a display row: on its new-side line, for the
+// first row of a line.
+func (s *State) lineNote(line DisplayLine) (string, bool) {
+	if len(s.Notes) == 0 || line.Continuation || line.HunkIdx < 0 || line.HunkIdx >= len(s.Hunks) {
+		return "", false
+	}
+	n := line.NewLineNo
+	if s.SideBySide {
+		n = line.Right.LineNo
+	}
+	text, ok := s.Notes[s.Hunks[line.HunkIdx].File][n]
+	return text, ok && n > 0
+}
+
+// noteTarget returns the file and new-side line of the focus line, or ok
+// false when it isn't a line of the new version.
+func (s *State) noteTarget() (file string, line int, ok bool) {
+	idx := s.focusLine()
+	for idx > 0 && idx < len(s.Lines) && s.Lines[idx].Continuation {
+		idx--
+	}
+	if idx < 0 || idx >= len(s.Lines) {
+		return "", 0, false
+	}
+	dl := s.Lines[idx]
+	n := dl.NewLineNo
+	if s.SideBySide {
+		n = dl.Right.LineNo
+	}
+	if dl.HunkIdx < 0 || dl.HunkIdx >= len(s.Hunks) || n <= 0 {
+		return "", 0, false
+	}
+	return s.Hunks[dl.HunkIdx].File, n, true
+}
+
+// startNote opens the input for a note on the focus line, holding the note
+// already there for editing.
+func startNote(s *State) {
+	if s.PipeMode || s.NoIndex || notesPath() == "" {
+		s.FlashMsg = "Notes need a repository to live in"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	file, line, ok := s.noteTarget()
+	if !ok {
+		s.FlashMsg = "Notes go on lines of the new version"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	// Enter saves the note; an empty note removes the one on the line
+	OpenPrompt(s, &InputPrompt{
+		Label: fmt.Sprintf("note on %s:%d: ", file, line),
+		Input: s.Notes[file][line],
+		OnSubmit: func(s *State, text string) {
+			saveNote(s, file, line, strings.TrimSpace(strings.ReplaceAll(text, "\n", " ")))
+		},
+	})
+}
+
+// saveNote sets the note on line of file and writes the notes file.
+// Notes are read again first, so notes others added meanwhile are kept.
+func saveNote(s *State, file string, line int, text string) {
+	path := notesPath()
+	notes := loadNotes(path)
+	if notes == nil {
+		notes = make(Notes)
+	}
+	notes.set(file, line, text)
+	if err := notes.save(path); err != nil {
+		s.FlashMsg = "Note: " + err.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.Notes = notes
+	if text == "" {
+		s.FlashMsg = fmt.Sprintf("Removed the note on %s:%d", file, line)
+	} else {
+		s.FlashMsg = fmt.Sprintf("Noted %s:%d in %s", file, line, notesFile)
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// noteLines returns the indices of the rows in s.Lines with a note.
+func (s *State) noteLines() []int {
+	var idx []int
+	for i, line := range s.Lines {
+		if _, ok := s.lineNote(line); ok {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// JumpToNote moves to the next (dir > 0) or previous noted line, wrapping
+// around the ends of the diff, and shows its note.
+func (s *State) JumpToNote(dir int) {
+	notes := s.noteLines()
+	if len(notes) == 0 {
+		s.FlashMsg = "No notes on lines of this diff (; adds one)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	target := notes[nextIndex(notes, s.focusLine(), dir)]
+	s.JumpTo(target)
+	text, _ := s.lineNote(s.Lines[target])
+	s.FlashMsg = "✎ " + text
+	s.FlashExpiry = time.Now().Add(4 * time.Second)
+}
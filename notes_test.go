@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestNotesRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".wiff", "notes")
+	notes := make(Notes)
+	notes.set("b.go", 7, "second")
+	notes.set("a.go", 12, "check bounds: n can be 0")
+	notes.set("a.go", 3, "first")
+	if err := notes.save(path); err != nil {
+		t.Fatal(err)
+	}
+	got := loadNotes(path)
+	if len(got) != 2 || got["a.go"][12] != "check bounds: n can be 0" || got["a.go"][3] != "first" || got["b.go"][7] != "second" {
+		t.Errorf("loaded %v", got)
+	}
+
+	got.set("a.go", 3, "")
+	got.set("a.go", 12, "")
+	got.set("b.go", 7, "")
+	if len(got) != 0 {
+		t.Fatalf("removing every note left %v", got)
+	}
+	if err := got.save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("notes file kept without notes: %v", err)
+	}
+}
+
+func TestNoteKey(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	s := &State{Width: 80, Height: 24, Hunks: []Hunk{{File: "a.go", OldStart: 10, NewStart: 10, Lines: []Line{
+		{Op: ' ', Content: "x := 1"},
+		{Op: '+', Content: "y := 2"},
+	}}}}
+	s.BuildLines()
+	s.CursorMode, s.Cursor = true, 4 // the added line, below the file and hunk headers
+	key := func(k tcell.Key, r rune) { HandleKey(s, tcell.NewEventKey(k, r, tcell.ModNone)) }
+	key(tcell.KeyRune, ';')
+	if s.Prompt == nil || s.Prompt.Label != "note on a.go:11: " {
+		t.Fatalf("note prompt %+v", s.Prompt)
+	}
+	for _, r := range "why 2?" {
+		key(tcell.KeyRune, r)
+	}
+	key(tcell.KeyEnter, 0)
+	if got := loadNotes(filepath.Join(dir, notesFile)); got["a.go"][11] != "why 2?" {
+		t.Errorf("notes file has %v", got)
+	}
+	if text, ok := s.lineNote(s.Lines[4]); !ok || text != "why 2?" {
+		t.Errorf("lineNote = %q, %v", text, ok)
+	}
+	s.Cursor = 0
+	s.JumpToNote(1)
+	if s.FlashMsg != "✎ why 2?" {
+		t.Errorf("]n flash = %q", s.FlashMsg)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// panePresets are the editor_pane commands of the terminals wiff knows: a
+// prefix that runs the rest of its arguments in a new split or window.
+var panePresets = map[string]string{
+	"tmux":  "tmux split-window -h",
+	"kitty": "kitty @ launch --type=window --cwd=current",
+}
+
+// paneCommand returns the editor_pane command template, or "" when editors
+// run with the TUI suspended. auto picks tmux or kitty from the
+// environment.
+func (s *State) paneCommand() string {
+	pane := s.Config.EditorPane
+	if pane == "auto" {
+		switch {
+		case os.Getenv("TMUX") != "":
+			pane = "tmux"
+		case os.Getenv("KITTY_WINDOW_ID") != "":
+			pane = "kitty"
+		default:
+			return ""
+		}
+	}
+	if preset, ok := panePresets[pane]; ok {
+		return preset
+	}
+	return pane
+}
+
+// paneArgs returns the command that runs args, an editor command for path
+// at line, in another pane: the template followed by args, or the template
+// alone with {file} and {line} substituted when it has them.
+func paneArgs(tmpl string, args []string, path string, line int) []string {
+	if strings.Contains(tmpl, "{file}") {
+		return expandCommand(tmpl, map[string]string{
+			"file": path,
+			"line": fmt.Sprint(max(line, 1)),
+		})
+	}
+	return append(strings.Fields(tmpl), args...)
+}
+
+// runEditor runs an editor command for path at line: in another pane when
+// editor_pane is set, keeping the diff on screen (the watcher reloads it
+// once the file is saved), or else with the TUI suspended, reloading
+// afterwards.
+func runEditor(s *State, args []string, path string, line int) error {
+	tmpl := s.paneCommand()
+	if tmpl == "" {
+		err := runSuspended(s, args)
+		reloadAfterOpen(s)
+		return err
+	}
+	args = paneArgs(tmpl, args, path, line)
+	cmd := exec.Command(args[0], args[1:]...)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPaneCommand(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("KITTY_WINDOW_ID", "")
+	s := &State{}
+	if got := s.paneCommand(); got != "" {
+		t.Errorf("unset: %q", got)
+	}
+	s.Config.EditorPane = "auto"
+	if got := s.paneCommand(); got != "" {
+		t.Errorf("auto outside tmux and kitty: %q", got)
+	}
+	t.Setenv("KITTY_WINDOW_ID", "1")
+	if got := s.paneCommand(); got != panePresets["kitty"] {
+		t.Errorf("auto in kitty: %q", got)
+	}
+	t.Setenv("TMUX", "/tmp/tmux-0/default,1,0")
+	if got := s.paneCommand(); got != panePresets["tmux"] {
+		t.Errorf("auto in tmux: %q", got)
+	}
+	s.Config.EditorPane = "wezterm cli split-pane --"
+	if got := s.paneCommand(); got != "wezterm cli split-pane --" {
+		t.Errorf("custom: %q", got)
+	}
+}
+
+func TestPaneArgs(t *testing.T) {
+	editor := []string{"vi", "+3", "/r/a.go"}
+	if got := strings.Join(paneArgs("tmux split-window -h", editor, "/r/a.go", 3), " "); got != "tmux split-window -h vi +3 /r/a.go" {
+		t.Errorf("prefix: %s", got)
+	}
+	if got := strings.Join(paneArgs("myterm --at {line} {file}", editor, "/r/a.go", 0), " "); got != "myterm --at 1 /r/a.go" {
+		t.Errorf("template: %s", got)
+	}
+}
+
+func TestParseConfigEditorPane(t *testing.T) {
+	cfg, err := parseConfig(strings.NewReader("editor_pane = tmux\n"))
+	if err != nil || cfg.EditorPane != "tmux" {
+		t.Fatalf("got %q, %v", cfg.EditorPane, err)
+	}
+	if _, err := parseConfig(strings.NewReader("editor_pane =\n")); err == nil {
+		t.Error("empty editor_pane should fail")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pipeSources are the parts of a hunk that | can send to a command.
+var pipeSources = []struct {
+	name string
+	text func(h *Hunk) string
+}{
+	{"added lines", (*Hunk).AddedLines},
+	{"patch", (*Hunk).AsPatch},
+	{"result (new code)", (*Hunk).ResultLines},
+	{"removed lines", (*Hunk).RemovedLines},
+}
+
+// pipeOutputLimit caps how many output lines the result panel shows.
+const pipeOutputLimit = 1000
+
+// startPipe asks which part of the hunk to pipe, then for the command.
+func startPipe(s *State, hunk *Hunk) {
+	items := make([]string, len(pipeSources))
+	for i, src := range pipeSources {
+		items[i] = src.name
+	}
+	label := hunk.Label
+	OpenPopup(s, &Popup{
+		Title: "Pipe hunk " + label,
+		Items: items,
+		OnSelect: func(s *State, idx int) {
+			h := s.HunkByLabel(label)
+			if h == nil {
+				return
+			}
+			// Commands like wc -l expect newline-terminated lines
+			text := pipeSources[idx].text(h)
+			if text != "" && !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			openPipePrompt(s, text)
+		},
+	})
+}
+
+// openPipePrompt asks for the command to pipe text through. The previous
+// command stays in the input for reuse.
+func openPipePrompt(s *State, text string) {
+	OpenPrompt(s, &InputPrompt{
+		Label:   "| ",
+		Input:   s.PipeCmd,
+		History: "pipe",
+		OnSubmit: func(s *State, command string) {
+			if strings.TrimSpace(command) != "" {
+				s.PipeCmd = command
+				runPipeCmd(s, command, text)
+			}
+		},
+	})
+}
+
+// pipeThrough runs command with sh -c, feeding it input, and returns its
+// combined output.
+func pipeThrough(command, input string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	if root, err := repo.Root(); err == nil {
+		cmd.Dir = root
+	}
+	cmd.Stdin = strings.NewReader(input)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err
+}
+
+// runPipeCmd pipes text through command and shows the output in a panel.
+func runPipeCmd(s *State, command, text string) {
+	out, err := pipeThrough(command, text)
+	showCommandOutput(s, "| "+command, out, err)
+}
+
+// showCommandOutput shows the output of a command in a panel titled title.
+// Selecting any line of the panel copies the whole output.
+func showCommandOutput(s *State, title, out string, err error) {
+	out = strings.TrimRight(out, "\n")
+	if out == "" {
+		if err != nil {
+			s.FlashMsg = fmt.Sprintf("%s: %v", title, err)
+		} else {
+			s.FlashMsg = title + ": no output"
+		}
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) > pipeOutputLimit {
+		lines = append(lines[:pipeOutputLimit], fmt.Sprintf("… %d more lines", len(lines)-pipeOutputLimit))
+	}
+	if err != nil {
+		title += " (" + err.Error() + ")"
+	}
+	OpenPopup(s, &Popup{
+		Title: title,
+		Items: lines,
+		OnSelect: func(s *State, _ int) {
+			copyText(s, out+"\n", "Copied command output")
+		},
+	})
+}
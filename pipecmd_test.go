@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPipeThrough(t *testing.T) {
+	out, err := pipeThrough("wc -l", "a\nb\nc\n")
+	if err != nil {
+		t.Fatalf("pipeThrough: %v", err)
+	}
+	if got := strings.TrimSpace(out); got != "3" {
+		t.Errorf("output = %q, want 3", got)
+	}
+	if _, err := pipeThrough("exit 3", ""); err == nil {
+		t.Error("expected error from failing command")
+	}
+}
+
+func TestPipeCmdFlow(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s := &State{Hunks: []Hunk{{Label: "a", Lines: []Line{
+		{Op: '+', Content: "one"},
+		{Op: '-', Content: "gone"},
+		{Op: '+', Content: "two"},
+	}}}}
+	startPipe(s, &s.Hunks[0])
+	if s.Popup == nil {
+		t.Fatal("expected source picker")
+	}
+	s.Popup.OnSelect(s, 0) // added lines
+	if s.Prompt == nil || s.Prompt.Label != "| " {
+		t.Fatalf("expected the command prompt, got %+v", s.Prompt)
+	}
+	s.Popup = nil
+	s.Prompt.Input = "tr a-z A-Z"
+	HandlePromptKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if s.PipeCmd != "tr a-z A-Z" {
+		t.Errorf("PipeCmd = %q, want the command kept for next time", s.PipeCmd)
+	}
+	if s.Popup == nil || len(s.Popup.Items) != 2 || s.Popup.Items[1] != "TWO" {
+		t.Fatalf("unexpected output panel %+v", s.Popup)
+	}
+}
@@ -0,0 +1,125 @@
+package wiff
+
+import "strings"
+
+// Limits of side-by-side alignment. Blocks with more candidate pairs than
+// maxAlignCells are zipped in order instead, and lines less similar than
+// minAlignSimilarity are never put on one row by the matcher.
+const (
+	maxAlignCells      = 200 * 200
+	minAlignSimilarity = 0.5
+)
+
+// alignPair is a row of an aligned block: the index of a removed line and
+// of an added line, -1 for padding.
+type alignPair struct {
+	old, new int
+}
+
+// alignLines lays out a block of removed lines next to a block of added
+// lines. Lines that were edited in place are put on the same row: the
+// pairing keeps both sides in order and has the greatest total similarity,
+// found by dynamic programming like a sequence alignment. The unpaired
+// lines between two pairs are zipped, as before any matching, so a block
+// without similar lines looks the same as a plain zip.
+func alignLines(removes, adds []Line) []alignPair {
+	n, m := len(removes), len(adds)
+	if n == 0 || m == 0 || n*m > maxAlignCells || (n == 1 && m == 1) {
+		return zipLines(0, n, 0, m, nil)
+	}
+	oldGrams := make([]map[string]int, n)
+	for i, l := range removes {
+		oldGrams[i] = bigrams(l.Content)
+	}
+	newGrams := make([]map[string]int, m)
+	for j, l := range adds {
+		newGrams[j] = bigrams(l.Content)
+	}
+
+	// score[i][j] is the best total similarity of removes[i:] and adds[j:]
+	score := make([][]float64, n+1)
+	for i := range score {
+		score[i] = make([]float64, m+1)
+	}
+	sim := make([][]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sim[i] = make([]float64, m)
+		for j := m - 1; j >= 0; j-- {
+			best := max(score[i+1][j], score[i][j+1])
+			s := similarity(removes[i].Content, adds[j].Content, oldGrams[i], newGrams[j])
+			if s >= minAlignSimilarity {
+				sim[i][j] = s
+				best = max(best, score[i+1][j+1]+s)
+			}
+			score[i][j] = best
+		}
+	}
+
+	var rows []alignPair
+	i, j, oi, oj := 0, 0, 0, 0
+	for i < n && j < m {
+		switch {
+		case sim[i][j] > 0 && score[i][j] == score[i+1][j+1]+sim[i][j]:
+			rows = zipLines(oi, i, oj, j, rows)
+			rows = append(rows, alignPair{i, j})
+			i, j = i+1, j+1
+			oi, oj = i, j
+		case score[i][j] == score[i+1][j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return zipLines(oi, n, oj, m, rows)
+}
+
+// zipLines appends rows pairing removed lines [i0, i1) with added lines
+// [j0, j1) in order, padding the shorter side.
+func zipLines(i0, i1, j0, j1 int, rows []alignPair) []alignPair {
+	for k := 0; k < max(i1-i0, j1-j0); k++ {
+		p := alignPair{-1, -1}
+		if i0+k < i1 {
+			p.old = i0 + k
+		}
+		if j0+k < j1 {
+			p.new = j0 + k
+		}
+		rows = append(rows, p)
+	}
+	return rows
+}
+
+// bigrams counts the pairs of adjacent runes of text, without its leading
+// and trailing space.
+func bigrams(text string) map[string]int {
+	r := []rune(strings.TrimSpace(text))
+	grams := make(map[string]int, len(r))
+	for k := 0; k+1 < len(r); k++ {
+		grams[string(r[k:k+2])]++
+	}
+	return grams
+}
+
+// similarity is the Dice coefficient of the bigrams of two lines, from 0
+// for nothing in common to 1 for the same text. Lines too short for a
+// bigram are similar only when equal.
+func similarity(a, b string, ga, gb map[string]int) float64 {
+	total := 0
+	for _, c := range ga {
+		total += c
+	}
+	for _, c := range gb {
+		total += c
+	}
+	if total == 0 {
+		if strings.TrimSpace(a) == strings.TrimSpace(b) {
+			return 1
+		}
+		return 0
+	}
+	common := 0
+	for g, c := range ga {
+		common += min(c, gb[g])
+	}
+	return 2 * float64(common) / float64(total)
+}
@@ -0,0 +1,61 @@
+package wiff
+
+import (
+	"fmt"
+	"testing"
+)
+
+func lines(texts ...string) []Line {
+	out := make([]Line, len(texts))
+	for i, t := range texts {
+		out[i] = Line{Content: t}
+	}
+	return out
+}
+
+func TestAlignLines(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		removes, adds []Line
+		want          string
+	}{
+		{"inserted line above edits",
+			lines("\tx := compute(a, b)", "\treturn x * 2"),
+			lines("\tlog.Println(\"start\")", "\tx := compute(a, b, c)", "\treturn x * 3"),
+			"[{-1 0} {0 1} {1 2}]"},
+		{"removed line between edits",
+			lines("name := user.Name", "// TODO: remove", "age := user.Age"),
+			lines("name := user.FullName", "age := user.AgeYears"),
+			"[{0 0} {1 -1} {2 1}]"},
+		{"nothing similar zips",
+			lines("alpha", "beta"),
+			lines("one", "two", "three"),
+			"[{0 0} {1 1} {-1 2}]"},
+		{"short lines pair when equal",
+			lines("}", "return"),
+			lines("x++", "}"),
+			"[{-1 0} {0 1} {1 -1}]"},
+	} {
+		if got := fmt.Sprint(alignLines(tt.removes, tt.adds)); got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSideBySideAlignsEditedLines(t *testing.T) {
+	h := &Hunk{OldStart: 10, NewStart: 10, Lines: []Line{
+		{Op: '-', Content: "a := load(path)"},
+		{Op: '+', Content: "defer trace()"},
+		{Op: '+', Content: "a := load(path, opts)"},
+	}}
+	rows := HunkSideBySideLines(h, 0)
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+	if rows[0].Left.Text != "" || rows[0].Right.Text != "+defer trace()" || rows[0].Style != StyleAdded {
+		t.Errorf("row 0 = %+v", rows[0])
+	}
+	if rows[1].Left.LineNo != 10 || rows[1].Right.LineNo != 11 || rows[1].Style != StyleRemoved {
+		t.Errorf("row 1 = %+v", rows[1])
+	}
+}
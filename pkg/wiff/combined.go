@@ -0,0 +1,127 @@
+package wiff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A combined diff (git show of a merge, git diff during a conflict) compares
+// the result with several parents at once: "diff --cc" headers, "@@@" hunk
+// headers and one op column per parent. Its lines keep those columns in
+// Line.Ops; Line.Op sums them up ('+' when added against any parent, '-'
+// when removed), so code that only knows line diffs still works.
+
+var combinedHeaderRe = regexp.MustCompile(`^(@@@+) (.*?) @@@+(.*)$`)
+
+// isCombinedHeader reports whether line starts a combined diff file.
+func isCombinedHeader(line string) bool {
+	return strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined ")
+}
+
+// splitCombined splits a diff into runs of file sections, alternating
+// between plain line diffs and combined diffs, in order.
+func splitCombined(data []byte) (sections []string, combined []bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	var cur strings.Builder
+	curCombined := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff ") {
+			if c := isCombinedHeader(line); c != curCombined || c {
+				if cur.Len() > 0 {
+					sections = append(sections, cur.String())
+					combined = append(combined, curCombined)
+				}
+				cur.Reset()
+				curCombined = c
+			}
+		}
+		cur.WriteString(line)
+	}
+	if cur.Len() > 0 {
+		sections = append(sections, cur.String())
+		combined = append(combined, curCombined)
+	}
+	return sections, combined
+}
+
+// parseCombined parses one combined diff file section.
+func parseCombined(section string) []Hunk {
+	lines := strings.Split(strings.TrimSuffix(section, "\n"), "\n")
+	file := strings.TrimPrefix(strings.TrimPrefix(lines[0], "diff --cc "), "diff --combined ")
+	status := byte('M')
+	var hunks []Hunk
+	var h *Hunk
+	for _, line := range lines[1:] {
+		if m := combinedHeaderRe.FindStringSubmatch(line); m != nil {
+			parents := len(m[1]) - 1
+			hunks = append(hunks, Hunk{
+				File:    file,
+				Header:  line,
+				Comment: strings.TrimSpace(m[3]),
+				Status:  status,
+				Parents: parents,
+			})
+			h = &hunks[len(hunks)-1]
+			for _, r := range strings.Fields(m[2]) {
+				n, _, _ := strings.Cut(r[1:], ",")
+				start, _ := strconv.Atoi(n)
+				switch {
+				case r[0] == '+':
+					h.NewStart = start
+				case h.OldStart == 0:
+					h.OldStart = start // first parent
+				}
+			}
+			continue
+		}
+		if h == nil {
+			switch {
+			case strings.HasPrefix(line, "+++ ") && line != "+++ /dev/null":
+				file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			case strings.HasPrefix(line, "new file mode"):
+				status = 'A'
+			case strings.HasPrefix(line, "deleted file mode"):
+				status = 'D'
+			}
+			continue
+		}
+		if len(line) < h.Parents || strings.HasPrefix(line, "\\") {
+			continue
+		}
+		ops := line[:h.Parents]
+		op := ' '
+		switch {
+		case strings.Contains(ops, "+"):
+			op = '+'
+		case strings.Contains(ops, "-"):
+			op = '-'
+		}
+		h.Lines = append(h.Lines, Line{Op: op, Ops: ops, Content: line[h.Parents:]})
+	}
+	for i := range hunks {
+		hunks[i].File = file
+		hunks[i].Status = status
+	}
+	return hunks
+}
+
+// Prefix returns the op columns of the line: Op, or one column per parent
+// in a combined diff.
+func (l Line) Prefix() string {
+	if l.Ops != "" {
+		return l.Ops
+	}
+	return string(l.Op)
+}
+
+// Sides reports whether the line is in the old version (the first parent
+// of a combined diff) and in the new version.
+func (l Line) Sides() (inOld, inNew bool) {
+	if l.Ops == "" {
+		return l.Op != '+', l.Op != '-'
+	}
+	// A blank column means "unchanged" on kept lines but "absent" on
+	// removed ones
+	return l.Ops[0] == '-' || (l.Op != '-' && l.Ops[0] == ' '), l.Op != '-'
+}
@@ -0,0 +1,82 @@
+package wiff
+
+import "testing"
+
+const combinedSample = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1 +1 @@
+-one
++1
+diff --cc describe.c
+index fabadb8,cc95eb0..4866510
+--- a/describe.c
++++ b/describe.c
+@@@ -98,4 -98,3 +98,5 @@@ static int compare
+  }
+  
+- static void describe(char *arg)
+ -static void describe(struct commit *cmit, int last_one)
+++static void describe(char *arg, int last_one)
+  {
+ +	unsigned char sha1[20];
+diff --git a/z.go b/z.go
+--- a/z.go
++++ b/z.go
+@@ -1 +1 @@
+-x
++y
+`
+
+func TestParseCombined(t *testing.T) {
+	hunks, err := Parse([]byte(combinedSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 3 || hunks[0].File != "a.go" || hunks[2].File != "z.go" {
+		t.Fatalf("hunks = %+v", hunks)
+	}
+	if hunks[0].Parents != 0 || hunks[0].Lines[0].Ops != "" {
+		t.Errorf("plain hunk parsed as combined: %+v", hunks[0])
+	}
+	h := hunks[1]
+	if h.File != "describe.c" || h.Parents != 2 || h.OldStart != 98 || h.NewStart != 98 || h.Comment != "static int compare" || h.Status != 'M' {
+		t.Fatalf("combined hunk = %+v", h)
+	}
+	wantOps := []string{"  ", "  ", "- ", " -", "++", "  ", " +"}
+	wantOp := []rune{' ', ' ', '-', '-', '+', ' ', '+'}
+	if len(h.Lines) != len(wantOps) {
+		t.Fatalf("got %d lines, want %d", len(h.Lines), len(wantOps))
+	}
+	for i, l := range h.Lines {
+		if l.Ops != wantOps[i] || l.Op != wantOp[i] {
+			t.Errorf("line %d: ops %q op %q, want %q %q", i, l.Ops, l.Op, wantOps[i], wantOp[i])
+		}
+	}
+	if h.Lines[4].Content != "static void describe(char *arg, int last_one)" {
+		t.Errorf("content = %q", h.Lines[4].Content)
+	}
+	if h.AsPatch() != "@@@ -98,4 -98,3 +98,5 @@@ static int compare\n  }\n  \n- static void describe(char *arg)\n -static void describe(struct commit *cmit, int last_one)\n++static void describe(char *arg, int last_one)\n  {\n +\tunsigned char sha1[20];\n" {
+		t.Errorf("AsPatch() = %q", h.AsPatch())
+	}
+}
+
+func TestCombinedHunkLines(t *testing.T) {
+	hunks, err := Parse([]byte(combinedSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := HunkLines(&hunks[1], 1)
+	// Old numbers follow the first parent: the line removed from the
+	// second parent only and the one added against it only aren't and are
+	// in the first parent, respectively.
+	want := [][2]int{{98, 98}, {99, 99}, {100, 0}, {0, 0}, {0, 100}, {101, 101}, {102, 102}}
+	for i, dl := range lines {
+		if got := [2]int{dl.OldLineNo, dl.NewLineNo}; got != want[i] {
+			t.Errorf("line %d %q: numbers %v, want %v", i, dl.Text, got, want[i])
+		}
+	}
+	if lines[3].Text != " -static void describe(struct commit *cmit, int last_one)" || lines[3].Style != StyleRemoved {
+		t.Errorf("line 3 = %+v", lines[3])
+	}
+}
@@ -0,0 +1,282 @@
+// Package wiff is the diff model behind the wiff viewer: it parses unified
+// diffs into hunks and lays them out as display lines, inline or side by
+// side, wrapped to a width. Tools that want wiff's view of a diff without
+// its terminal UI can build on it.
+package wiff
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bluekeyes/go-gitdiff/gitdiff"
+)
+
+// Hunk is one hunk of a diff. Parse fills in the diff itself; Label,
+// StartLine, Staged, Applied, Above and Below belong to whoever shows it.
+type Hunk struct {
+	Label     string
+	File      string
+	OldFile   string // path before a rename or copy, "" otherwise
+	Header    string // raw @@ header for AsPatch
+	Comment   string // function/context from header (clean display)
+	OldStart  int    // starting line number in old file
+	NewStart  int    // starting line number in new file
+	Lines     []Line
+	StartLine int  // display line of the hunk header, -1 when not shown
+	Staged    bool // true if this hunk has been staged via git apply --cached
+	Applied   bool // true if this hunk has been applied to the working tree
+	Status    byte // file status: 'A'dded, 'M'odified, 'D'eleted, 'R'enamed or 'C'opied
+	Parents   int  // parents of a combined (merge) diff, 0 for a plain diff
+
+	// Extra unchanged lines the viewer reveals around the hunk. They are
+	// display-only and never part of the hunk's patch.
+	Above []string
+	Below []string
+}
+
+// Line represents a single line in a diff hunk
+type Line struct {
+	Op      rune // '+', '-', ' '
+	Content string
+	Moved   bool   // content also appears on the other side of the diff
+	Changed []Span // words changed within the line (word-diff input only)
+	Ops     string // one op column per parent (combined diffs only)
+}
+
+// Parse parses a unified diff (git's, or any tool's without git headers,
+// such as diff -u or svn diff) into hunks, in the order they appear. Hunks
+// are unlabeled; see Label. A git diff --word-diff (plain or porcelain) is
+// read as the line diff it describes, with the changed words of each line
+// in Line.Changed. Combined diffs of merges keep their per-parent op
+// columns in Line.Ops.
+func Parse(data []byte) ([]Hunk, error) {
+	if !bytes.Contains(data, []byte("diff --c")) {
+		return parseLineDiff(data)
+	}
+	var hunks []Hunk
+	sections, combined := splitCombined(data)
+	for i, section := range sections {
+		if combined[i] {
+			hunks = append(hunks, parseCombined(section)...)
+			continue
+		}
+		h, err := parseLineDiff([]byte(section))
+		if err != nil {
+			return nil, err
+		}
+		hunks = append(hunks, h...)
+	}
+	return hunks, nil
+}
+
+// parseLineDiff parses a diff without combined diff sections.
+func parseLineDiff(data []byte) ([]Hunk, error) {
+	var spans [][][]Span
+	if isWordDiff(data) {
+		data, spans = fromWordDiff(data)
+	}
+	if crlfHeaders(string(data)) {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+
+	var hunks []Hunk
+	git := hasGitHeaders(string(data))
+	for _, section := range unifiedSections(string(data)) {
+		files, _, err := gitdiff.Parse(strings.NewReader(section))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !git {
+				file.OldName, file.NewName = stripPrefixes(headerNames(section))
+			}
+			hunks = append(hunks, fileHunks(file)...)
+		}
+	}
+	for i := range hunks {
+		if i >= len(spans) {
+			break
+		}
+		for j := range hunks[i].Lines {
+			if j < len(spans[i]) {
+				hunks[i].Lines[j].Changed = spans[i][j]
+			}
+		}
+	}
+	return hunks, nil
+}
+
+// Label returns the label of hunk idx drawn from alphabet: one character
+// each for the first len(alphabet) hunks, then two, then three and so on,
+// so no two hunks share one. An empty alphabet gives no labels ("").
+func Label(idx int, alphabet []rune) string {
+	n := len(alphabet)
+	if n == 0 || idx < 0 {
+		return ""
+	}
+	// idx+1 in bijective base n: a, b, …, aa, ab, …
+	var label []rune
+	for x := idx + 1; x > 0; x = (x - 1) / n {
+		label = append(label, alphabet[(x-1)%n])
+	}
+	slices.Reverse(label)
+	return string(label)
+}
+
+// fileHunks returns the hunks of a parsed file.
+func fileHunks(file *gitdiff.File) []Hunk {
+	filename := file.NewName
+	if filename == "" || filename == "/dev/null" {
+		filename = file.OldName
+	}
+	status := fileStatus(file)
+	oldFile := ""
+	if status == 'R' || status == 'C' {
+		oldFile = file.OldName
+	}
+	var hunks []Hunk
+	for _, frag := range file.TextFragments {
+		hunks = append(hunks, Hunk{
+			File:     filename,
+			OldFile:  oldFile,
+			Header:   formatHeader(frag),
+			Comment:  strings.TrimSpace(frag.Comment),
+			OldStart: int(frag.OldPosition),
+			NewStart: int(frag.NewPosition),
+			Lines:    parseLines(frag),
+			Status:   status,
+		})
+	}
+	return hunks
+}
+
+// fileStatus returns the git --name-status letter for a parsed file.
+func fileStatus(f *gitdiff.File) byte {
+	switch {
+	case f.IsNew || f.OldName == "" || f.OldName == "/dev/null":
+		return 'A'
+	case f.IsDelete || f.NewName == "" || f.NewName == "/dev/null":
+		return 'D'
+	case f.IsRename:
+		return 'R'
+	case f.IsCopy:
+		return 'C'
+	}
+	return 'M'
+}
+
+func formatHeader(frag *gitdiff.TextFragment) string {
+	comment := ""
+	if frag.Comment != "" {
+		comment = " " + frag.Comment
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@%s",
+		frag.OldPosition, frag.OldLines,
+		frag.NewPosition, frag.NewLines,
+		comment)
+}
+
+func parseLines(frag *gitdiff.TextFragment) []Line {
+	lines := make([]Line, 0, len(frag.Lines))
+	for _, l := range frag.Lines {
+		op := ' '
+		switch l.Op {
+		case gitdiff.OpAdd:
+			op = '+'
+		case gitdiff.OpDelete:
+			op = '-'
+		}
+		lines = append(lines, Line{Op: op, Content: strings.TrimRight(l.Line, "\n")})
+	}
+	return lines
+}
+
+// Counts returns the number of added and removed lines in the hunk.
+func (h *Hunk) Counts() (added, removed int) {
+	for _, l := range h.Lines {
+		switch l.Op {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// AddedLines returns all added lines joined by newlines
+func (h *Hunk) AddedLines() string {
+	return h.filterLines('+')
+}
+
+// RemovedLines returns all removed lines joined by newlines
+func (h *Hunk) RemovedLines() string {
+	return h.filterLines('-')
+}
+
+func (h *Hunk) filterLines(op rune) string {
+	var lines []string
+	for _, l := range h.Lines {
+		if l.Op == op {
+			lines = append(lines, l.Content)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ResultLines returns the new/result version of the code section:
+// context lines plus added lines, without removed lines.
+func (h *Hunk) ResultLines() string {
+	var lines []string
+	for _, l := range h.Lines {
+		if l.Op != '-' {
+			lines = append(lines, l.Content)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NewRange returns the first and last new-side line numbers of the hunk.
+func (h *Hunk) NewRange() (start, end int) {
+	n := 0
+	for _, l := range h.Lines {
+		if l.Op != '-' {
+			n++
+		}
+	}
+	return h.NewStart, h.NewStart + max(n, 1) - 1
+}
+
+// AsPatch formats the hunk as a unified diff patch
+func (h *Hunk) AsPatch() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header)
+	sb.WriteByte('\n')
+	for _, l := range h.Lines {
+		sb.WriteString(l.Prefix())
+		sb.WriteString(l.Content)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// AsFullPatch formats the hunk as a full patch suitable for git apply,
+// including the file header lines that git apply requires.
+func (h *Hunk) AsFullPatch() string {
+	var sb strings.Builder
+	sb.WriteString("diff --git a/")
+	sb.WriteString(h.File)
+	sb.WriteString(" b/")
+	sb.WriteString(h.File)
+	sb.WriteByte('\n')
+	sb.WriteString("--- a/")
+	sb.WriteString(h.File)
+	sb.WriteByte('\n')
+	sb.WriteString("+++ b/")
+	sb.WriteString(h.File)
+	sb.WriteByte('\n')
+	sb.WriteString(h.AsPatch())
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package wiff
+
+import "testing"
+
+const sample = `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,3 @@ func main()
+ one
+-two
++2
+ three
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+`
+
+func TestParse(t *testing.T) {
+	hunks, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	h := hunks[0]
+	if h.File != "a.go" || h.Comment != "func main()" || h.OldStart != 1 || h.NewStart != 1 || h.Status != 'M' {
+		t.Errorf("first hunk = %+v", h)
+	}
+	if h.Label != "" {
+		t.Errorf("Parse should leave hunks unlabeled, got %q", h.Label)
+	}
+	if added, removed := h.Counts(); added != 1 || removed != 1 {
+		t.Errorf("Counts() = %d, %d", added, removed)
+	}
+	if hunks[1].File != "new.txt" || hunks[1].Status != 'A' {
+		t.Errorf("second hunk: file %q status %c", hunks[1].File, hunks[1].Status)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	alphabet := []rune("ab")
+	var got []string
+	for i := range 5 {
+		got = append(got, Label(i, alphabet))
+	}
+	want := []string{"a", "b", "aa", "ab", "ba"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("labels = %q, want %q", got, want)
+		}
+	}
+	if got := Label(7, nil); got != "" {
+		t.Errorf("label from an empty alphabet = %q", got)
+	}
+}
+
+func TestLabelsUnique(t *testing.T) {
+	alphabet := []rune("ilrtvxJUXZ")
+	seen := make(map[string]int)
+	for i := range 10000 {
+		l := Label(i, alphabet)
+		if j, ok := seen[l]; ok {
+			t.Fatalf("hunks %d and %d are both %q", j, i, l)
+		}
+		seen[l] = i
+	}
+	if got := Label(110, alphabet); got != "iii" {
+		t.Errorf("label 110 = %q, want iii", got)
+	}
+}
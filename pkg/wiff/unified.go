@@ -0,0 +1,133 @@
+package wiff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diffs from diff -u, svn, hg without --git and most other tools have no
+// "diff --git" headers: each file starts at its ---/+++ pair, often after
+// lines of their own (svn's Index: and ====, diff -r's "Only in"). gitdiff
+// reads those lines between files as part of the hunk before, so such
+// diffs are split into one section per file and parsed one by one.
+
+var unifiedHunkRe = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// hasGitHeaders reports whether data has git's "diff --git" file headers.
+func hasGitHeaders(data string) bool {
+	return strings.HasPrefix(data, "diff --git ") || strings.Contains(data, "\ndiff --git ")
+}
+
+// unifiedSections splits a diff without git headers into one section per
+// file, each starting with the lines that came before its ---/+++ header.
+// A diff with git headers is returned whole. The line counts of hunk
+// headers decide where a hunk ends, so removed "-- x" and added "++ y"
+// lines aren't taken for a header.
+func unifiedSections(data string) []string {
+	if hasGitHeaders(data) {
+		return []string{data}
+	}
+	lines := strings.SplitAfter(data, "\n")
+	var sections []string
+	start, end := 0, 0 // the current section, and the end of its last hunk
+	header := false
+	oldN, newN := 0, 0
+	pos := 0
+	for i, line := range lines {
+		next := pos + len(line)
+		inHunk := oldN > 0 || newN > 0
+		if inHunk {
+			switch {
+			case strings.HasPrefix(line, " "), strings.TrimRight(line, "\r\n") == "":
+				oldN, newN = oldN-1, newN-1
+			case strings.HasPrefix(line, "-"):
+				oldN--
+			case strings.HasPrefix(line, "+"):
+				newN--
+			default:
+				inHunk = false // a short hunk: the line is read below
+				oldN, newN = 0, 0
+			}
+		}
+		switch {
+		case inHunk:
+			end = next
+		case strings.HasPrefix(line, `\`) && end == pos:
+			end = next // "\ No newline at end of file" after the last line
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if header {
+				sections = append(sections, data[start:end])
+				start = end
+			}
+			header = true
+		case strings.HasPrefix(line, "@@ "):
+			if m := unifiedHunkRe.FindStringSubmatch(line); m != nil {
+				oldN, newN = hunkCount(m[1]), hunkCount(m[2])
+				end = next
+			}
+		}
+		pos = next
+	}
+	return append(sections, data[start:])
+}
+
+// hunkCount returns the line count of a hunk header range, which is 1 when
+// left out.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// crlfHeaders reports whether the file headers of data end in CRLF: the
+// whole diff went through a tool that writes Windows line ends, as opposed
+// to a diff of files that have them.
+func crlfHeaders(data string) bool {
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if strings.HasPrefix(line, "+++ ") {
+			return strings.HasSuffix(line, "\r\n")
+		}
+	}
+	return false
+}
+
+// headerNames returns the old and new paths of the ---/+++ header of a
+// section, without the timestamp or revision after a tab. gitdiff gives
+// both sides the new path when the names of a traditional header differ.
+func headerNames(section string) (oldName, newName string) {
+	for _, line := range strings.Split(section, "\n") {
+		name, _, _ := strings.Cut(line[min(4, len(line)):], "\t")
+		switch {
+		case strings.HasPrefix(line, "--- ") && oldName == "":
+			oldName = name
+		case strings.HasPrefix(line, "+++ ") && oldName != "":
+			return oldName, name
+		}
+	}
+	return oldName, newName
+}
+
+// stripPrefixes drops the directories diff -r and other tools put in front
+// of the old and new paths ("orig/x" and "new/x", or "a/" and "b/"), as
+// patch -p1 does, when they tell the sides apart. Paths that agree on their
+// first directory, such as svn's, are kept.
+func stripPrefixes(oldName, newName string) (string, string) {
+	oldDir, oldRest, oldOK := strings.Cut(oldName, "/")
+	newDir, newRest, newOK := strings.Cut(newName, "/")
+	switch {
+	case oldName == "/dev/null":
+		if newOK && newDir == "b" {
+			return oldName, newRest
+		}
+	case newName == "/dev/null":
+		if oldOK && oldDir == "a" {
+			return oldRest, newName
+		}
+	case oldOK && newOK && oldDir != newDir:
+		return oldRest, newRest
+	}
+	return oldName, newName
+}
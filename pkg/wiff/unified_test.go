@@ -0,0 +1,100 @@
+package wiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSvn(t *testing.T) {
+	diff := `Index: trunk/foo.c
+===================================================================
+--- trunk/foo.c	(revision 12)
++++ trunk/foo.c	(working copy)
+@@ -1,3 +1,3 @@
+ a
+--- b
++++ B
+ c
+Index: trunk/bar.c
+===================================================================
+--- trunk/bar.c	(revision 12)
++++ trunk/bar.c	(working copy)
+@@ -1 +1 @@
+-x
++y
+\ No newline at end of file
+`
+	hunks, err := Parse([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	if h := hunks[0]; h.File != "trunk/foo.c" || h.Status != 'M' || len(h.Lines) != 4 || h.Lines[1].Content != "-- b" {
+		t.Errorf("first hunk = %+v", h)
+	}
+	if h := hunks[1]; h.File != "trunk/bar.c" || len(h.Lines) != 2 {
+		t.Errorf("second hunk = %+v", h)
+	}
+}
+
+func TestParseDiffR(t *testing.T) {
+	diff := `Only in old: gone.txt
+diff -ru old/s/x.txt new/s/x.txt
+--- old/s/x.txt	2026-10-16 12:15:08.894631773 +0000
++++ new/s/x.txt	2026-10-16 12:15:08.894631773 +0000
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+diff -ru old/y.txt new/y.txt
+--- old/y.txt	2026-10-16 12:15:08.894631773 +0000
++++ new/y.txt	2026-10-16 12:15:08.894631773 +0000
+@@ -1 +1 @@
+-1
++2
+`
+	hunks, err := Parse([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 || hunks[0].File != "s/x.txt" || hunks[1].File != "y.txt" {
+		t.Fatalf("hunks = %+v", hunks)
+	}
+	if len(hunks[0].Lines) != 3 {
+		t.Errorf("first hunk has %d lines, want 3", len(hunks[0].Lines))
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	diff := "--- a.txt\r\n+++ b.txt\r\n@@ -1 +1 @@\r\n-x\r\n+y\r\n"
+	hunks, err := Parse([]byte(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 || hunks[0].File != "b.txt" || hunks[0].Lines[1].Content != "y" {
+		t.Fatalf("hunks = %+v", hunks)
+	}
+}
+
+func TestStripPrefixes(t *testing.T) {
+	tests := []struct{ old, new, wantOld, wantNew string }{
+		{"orig/foo.c", "new/foo.c", "foo.c", "foo.c"},
+		{"trunk/foo.c", "trunk/foo.c", "trunk/foo.c", "trunk/foo.c"},
+		{"/dev/null", "b/foo.c", "/dev/null", "foo.c"},
+		{"a/foo.c", "/dev/null", "foo.c", "/dev/null"},
+		{"foo.c", "foo.c", "foo.c", "foo.c"},
+	}
+	for _, tt := range tests {
+		if o, n := stripPrefixes(tt.old, tt.new); o != tt.wantOld || n != tt.wantNew {
+			t.Errorf("stripPrefixes(%q, %q) = %q, %q", tt.old, tt.new, o, n)
+		}
+	}
+}
+
+func TestUnifiedSectionsGit(t *testing.T) {
+	if got := unifiedSections(sample); len(got) != 1 || !strings.HasPrefix(got[0], "diff --git") {
+		t.Errorf("git diff split into %d sections", len(got))
+	}
+}
@@ -0,0 +1,300 @@
+package wiff
+
+// LineStyle is what a display line shows.
+type LineStyle int
+
+const (
+	StyleNormal LineStyle = iota
+	StyleFileHeader
+	StyleHunkHeader
+	StyleAdded
+	StyleRemoved
+	StyleContext
+	StyleCollapsed // placeholder row for a collapsed file
+	StyleReplaced  // replace preview of the added line above
+)
+
+// HalfLine represents one side of a side-by-side display
+type HalfLine struct {
+	Text    string
+	Style   LineStyle
+	LineNo  int
+	Moved   bool
+	Changed []Span // changed words, in runes of Text
+}
+
+// DisplayLine represents a rendered line
+type DisplayLine struct {
+	Text         string
+	Style        LineStyle
+	Label        string // hunk label (a, b, c...)
+	HunkIdx      int    // -1 if not a hunk line
+	OldLineNo    int    // old file line number (0 = none)
+	NewLineNo    int    // new file line number (0 = none)
+	Continuation bool   // wrapped continuation of previous line
+	Moved        bool   // added/removed line detected as moved
+	Added        int    // file and hunk headers: added lines below
+	Removed      int    // file and hunk headers: removed lines below
+	Changed      []Span // changed words (word-diff input), in runes of Text
+	Left         HalfLine
+	Right        HalfLine
+}
+
+// Inline lays out hunks as an inline (unified) view: a header for each
+// file, then each hunk's header and lines. It sets each hunk's StartLine to
+// its header row.
+func Inline(hunks []Hunk) []DisplayLine {
+	return layout(hunks, HunkLines)
+}
+
+// SideBySide lays out hunks like Inline, with each line split into the old
+// (Left) and new (Right) side.
+func SideBySide(hunks []Hunk) []DisplayLine {
+	return layout(hunks, HunkSideBySideLines)
+}
+
+func layout(hunks []Hunk, body func(h *Hunk, idx int) []DisplayLine) []DisplayLine {
+	var lines []DisplayLine
+	var currentFile string
+	for i := range hunks {
+		h := &hunks[i]
+		if h.File != currentFile {
+			if currentFile != "" {
+				lines = append(lines, DisplayLine{Style: StyleNormal})
+			}
+			lines = append(lines, DisplayLine{Text: h.File, Style: StyleFileHeader})
+			currentFile = h.File
+		}
+		lines = append(lines, DisplayLine{Style: StyleNormal})
+		h.StartLine = len(lines)
+		lines = append(lines, DisplayLine{Text: h.Comment, Style: StyleHunkHeader, Label: h.Label, HunkIdx: i})
+		lines = append(lines, body(h, i)...)
+	}
+	return lines
+}
+
+// HunkLines returns the inline rows of the lines of hunk h, number idx,
+// with old and new line numbers.
+func HunkLines(h *Hunk, idx int) []DisplayLine {
+	lines := make([]DisplayLine, 0, len(h.Lines))
+	oldNo := h.OldStart
+	newNo := h.NewStart
+	for _, dl := range h.Lines {
+		style := StyleContext
+		switch dl.Op {
+		case '+':
+			style = StyleAdded
+		case '-':
+			style = StyleRemoved
+		}
+		var oln, nln int
+		inOld, inNew := dl.Sides()
+		if inOld {
+			oln = oldNo
+			oldNo++
+		}
+		if inNew {
+			nln = newNo
+			newNo++
+		}
+		prefix := dl.Prefix()
+		lines = append(lines, DisplayLine{
+			Text:      prefix + dl.Content,
+			Style:     style,
+			HunkIdx:   idx,
+			OldLineNo: oln,
+			NewLineNo: nln,
+			Moved:     dl.Moved,
+			Changed:   shiftSpans(dl.Changed, len(prefix)),
+		})
+	}
+	return lines
+}
+
+// shiftSpans returns spans moved right by n runes (the op column).
+func shiftSpans(spans []Span, n int) []Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	out := make([]Span, len(spans))
+	for i, sp := range spans {
+		out[i] = Span{sp.Start + n, sp.End + n}
+	}
+	return out
+}
+
+// clipSpans returns the parts of spans within runes [from, to), counted
+// from from.
+func clipSpans(spans []Span, from, to int) []Span {
+	var out []Span
+	for _, sp := range spans {
+		start, end := max(sp.Start, from), min(sp.End, to)
+		if start < end {
+			out = append(out, Span{start - from, end - from})
+		}
+	}
+	return out
+}
+
+// HunkSideBySideLines returns the side-by-side rows of the lines of hunk h,
+// number idx. Each run of removed lines is paired with the added lines that
+// follow it; the shorter side is padded with empty halves.
+func HunkSideBySideLines(h *Hunk, idx int) []DisplayLine {
+	var lines []DisplayLine
+	oldNo := h.OldStart
+	newNo := h.NewStart
+	j := 0
+	for j < len(h.Lines) {
+		dl := h.Lines[j]
+
+		if dl.Op == ' ' {
+			// Context: same text on both sides
+			lines = append(lines, DisplayLine{
+				Style:   StyleContext,
+				HunkIdx: idx,
+				Left:    HalfLine{Text: " " + dl.Content, Style: StyleContext, LineNo: oldNo},
+				Right:   HalfLine{Text: " " + dl.Content, Style: StyleContext, LineNo: newNo},
+			})
+			oldNo++
+			newNo++
+			j++
+			continue
+		}
+
+		// Collect consecutive removes
+		var removes []Line
+		var removeNos []int
+		for j < len(h.Lines) && h.Lines[j].Op == '-' {
+			removes = append(removes, h.Lines[j])
+			if inOld, _ := h.Lines[j].Sides(); inOld {
+				removeNos = append(removeNos, oldNo)
+				oldNo++
+			} else {
+				removeNos = append(removeNos, 0) // removed from another parent
+			}
+			j++
+		}
+		// Collect consecutive adds
+		var adds []Line
+		var addNos []int
+		for j < len(h.Lines) && h.Lines[j].Op == '+' {
+			adds = append(adds, h.Lines[j])
+			addNos = append(addNos, newNo)
+			newNo++
+			if inOld, _ := h.Lines[j].Sides(); inOld {
+				oldNo++ // added against another parent only
+			}
+			j++
+		}
+
+		// Put edited lines next to their new version, pad the other rows
+		for _, p := range alignLines(removes, adds) {
+			var left, right HalfLine
+			if k := p.old; k >= 0 {
+				left = HalfLine{Text: "-" + removes[k].Content, Style: StyleRemoved, LineNo: removeNos[k], Moved: removes[k].Moved, Changed: shiftSpans(removes[k].Changed, 1)}
+			}
+			if k := p.new; k >= 0 {
+				right = HalfLine{Text: "+" + adds[k].Content, Style: StyleAdded, LineNo: addNos[k], Moved: adds[k].Moved, Changed: shiftSpans(adds[k].Changed, 1)}
+			}
+			lineStyle := StyleContext
+			if left.Text != "" {
+				lineStyle = StyleRemoved
+			} else if right.Text != "" {
+				lineStyle = StyleAdded
+			}
+			lines = append(lines, DisplayLine{
+				Style:   lineStyle,
+				HunkIdx: idx,
+				Left:    left,
+				Right:   right,
+			})
+		}
+	}
+	return lines
+}
+
+// wraps reports whether a line of this style is wrapped; headers and
+// blank rows never are.
+func wraps(style LineStyle) bool {
+	return style != StyleNormal && style != StyleFileHeader && style != StyleHunkHeader
+}
+
+// Wrap splits inline lines longer than width runes into continuation
+// lines. Only the first part keeps the line numbers.
+func Wrap(lines []DisplayLine, width int) []DisplayLine {
+	var wrapped []DisplayLine
+	for _, line := range lines {
+		runes := []rune(line.Text)
+		if !wraps(line.Style) || len(runes) <= width {
+			wrapped = append(wrapped, line)
+			continue
+		}
+		// First chunk keeps line numbers
+		wrapped = append(wrapped, DisplayLine{
+			Text:      string(runes[:width]),
+			Style:     line.Style,
+			HunkIdx:   line.HunkIdx,
+			OldLineNo: line.OldLineNo,
+			NewLineNo: line.NewLineNo,
+			Moved:     line.Moved,
+			Changed:   clipSpans(line.Changed, 0, width),
+		})
+		for at := width; at < len(runes); at += width {
+			end := min(at+width, len(runes))
+			wrapped = append(wrapped, DisplayLine{
+				Text:         string(runes[at:end]),
+				Style:        line.Style,
+				HunkIdx:      line.HunkIdx,
+				Continuation: true,
+				Moved:        line.Moved,
+				Changed:      clipSpans(line.Changed, at, end),
+			})
+		}
+	}
+	return wrapped
+}
+
+// WrapSideBySide splits side-by-side lines whose halves are longer than
+// width runes into continuation lines, each side wrapping on its own.
+func WrapSideBySide(lines []DisplayLine, width int) []DisplayLine {
+	return WrapSideBySideCols(lines, width, width)
+}
+
+// WrapSideBySideCols is WrapSideBySide for columns of different widths:
+// the old half wraps at leftWidth runes and the new half at rightWidth.
+func WrapSideBySideCols(lines []DisplayLine, leftWidth, rightWidth int) []DisplayLine {
+	var wrapped []DisplayLine
+	for _, line := range lines {
+		leftRunes := []rune(line.Left.Text)
+		rightRunes := []rune(line.Right.Text)
+		if !wraps(line.Style) || (len(leftRunes) <= leftWidth && len(rightRunes) <= rightWidth) {
+			wrapped = append(wrapped, line)
+			continue
+		}
+
+		// First chunk keeps line numbers
+		lEnd := min(leftWidth, len(leftRunes))
+		rEnd := min(rightWidth, len(rightRunes))
+		wrapped = append(wrapped, DisplayLine{
+			Style:   line.Style,
+			Label:   line.Label,
+			HunkIdx: line.HunkIdx,
+			Left:    HalfLine{Text: string(leftRunes[:lEnd]), Style: line.Left.Style, LineNo: line.Left.LineNo, Moved: line.Left.Moved, Changed: clipSpans(line.Left.Changed, 0, lEnd)},
+			Right:   HalfLine{Text: string(rightRunes[:rEnd]), Style: line.Right.Style, LineNo: line.Right.LineNo, Moved: line.Right.Moved, Changed: clipSpans(line.Right.Changed, 0, rEnd)},
+		})
+
+		// Continuation lines
+		for l, r := leftWidth, rightWidth; l < len(leftRunes) || r < len(rightRunes); l, r = l+leftWidth, r+rightWidth {
+			lStart, rStart := min(l, len(leftRunes)), min(r, len(rightRunes))
+			lEnd, rEnd = min(l+leftWidth, len(leftRunes)), min(r+rightWidth, len(rightRunes))
+			wrapped = append(wrapped, DisplayLine{
+				Style:        line.Style,
+				HunkIdx:      line.HunkIdx,
+				Continuation: true,
+				Left:         HalfLine{Text: string(leftRunes[lStart:lEnd]), Style: line.Left.Style, Moved: line.Left.Moved, Changed: clipSpans(line.Left.Changed, lStart, lEnd)},
+				Right:        HalfLine{Text: string(rightRunes[rStart:rEnd]), Style: line.Right.Style, Moved: line.Right.Moved, Changed: clipSpans(line.Right.Changed, rStart, rEnd)},
+			})
+		}
+	}
+	return wrapped
+}
@@ -0,0 +1,63 @@
+package wiff
+
+import "testing"
+
+func TestInlineAndSideBySide(t *testing.T) {
+	hunks, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := Inline(hunks)
+	// header, blank, hunk header, 4 lines, blank, header, blank, hunk header, 1 line
+	if len(lines) != 12 {
+		t.Fatalf("Inline gave %d lines, want 12", len(lines))
+	}
+	if hunks[0].StartLine != 2 || lines[2].Style != StyleHunkHeader {
+		t.Errorf("first hunk StartLine = %d", hunks[0].StartLine)
+	}
+	if l := lines[4]; l.Text != "-two" || l.Style != StyleRemoved || l.OldLineNo != 2 || l.NewLineNo != 0 {
+		t.Errorf("removed line = %+v", l)
+	}
+
+	sbs := SideBySide(hunks)
+	if len(sbs) != 11 {
+		t.Fatalf("SideBySide gave %d lines, want 11 (the change is one row)", len(sbs))
+	}
+	if l := sbs[4]; l.Left.Text != "-two" || l.Right.Text != "+2" || l.Left.LineNo != 2 || l.Right.LineNo != 2 {
+		t.Errorf("paired row = %+v", l)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := []DisplayLine{
+		{Text: "header that is long", Style: StyleFileHeader},
+		{Text: "+abcdefg", Style: StyleAdded, NewLineNo: 7},
+	}
+	got := Wrap(lines, 3)
+	if len(got) != 4 {
+		t.Fatalf("Wrap gave %d lines, want 4", len(got))
+	}
+	if got[1].Text != "+ab" || got[1].NewLineNo != 7 || !got[3].Continuation || got[3].NewLineNo != 0 {
+		t.Errorf("wrapped = %+v", got[1:])
+	}
+
+	sbs := WrapSideBySide([]DisplayLine{{
+		Style: StyleRemoved,
+		Left:  HalfLine{Text: "-abcde", LineNo: 1},
+		Right: HalfLine{Text: "+x", LineNo: 1},
+	}}, 4)
+	if len(sbs) != 2 || sbs[1].Left.Text != "de" || sbs[1].Right.Text != "" || sbs[1].Left.LineNo != 0 {
+		t.Errorf("side-by-side wrapped = %+v", sbs)
+	}
+
+	cols := WrapSideBySideCols([]DisplayLine{{
+		Style: StyleRemoved,
+		Left:  HalfLine{Text: "-abcde", LineNo: 1},
+		Right: HalfLine{Text: "+vwxyz", LineNo: 1},
+	}}, 2, 4)
+	if len(cols) != 3 || cols[0].Left.Text != "-a" || cols[0].Right.Text != "+vwx" ||
+		cols[1].Left.Text != "bc" || cols[1].Right.Text != "yz" || cols[2].Left.Text != "de" || cols[2].Right.Text != "" {
+		t.Errorf("uneven columns wrapped = %+v", cols)
+	}
+}
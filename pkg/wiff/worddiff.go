@@ -0,0 +1,210 @@
+package wiff
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Span is a run of runes [Start, End) of a line that a word diff marked as
+// changed.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// segment is one piece of a word-diff line: ' ' for common text, '-' for
+// removed and '+' for added words.
+type segment struct {
+	op   byte
+	text string
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@(.*)$`)
+
+// isWordDiff reports whether data is a git diff --word-diff=plain or
+// --word-diff=porcelain diff rather than a line diff.
+func isWordDiff(data []byte) bool {
+	// Only git writes word diffs; other tools' lines between files (svn's
+	// Index:) would look like unprefixed context
+	if !hasGitHeaders(string(data)) {
+		return false
+	}
+	inHunk, changes, markers := false, false, false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@ "):
+			inHunk = true
+			continue
+		case strings.HasPrefix(line, "diff "):
+			inHunk = false
+		}
+		if !inHunk || line == "" {
+			continue
+		}
+		switch line[0] {
+		case '+', '-':
+			changes = true
+		case ' ', '\\':
+		default:
+			return true // plain context lines carry no prefix, porcelain has ~
+		}
+		if strings.Contains(line, "[-") || strings.Contains(line, "{+") {
+			markers = true
+		}
+	}
+	return markers && !changes
+}
+
+// fromWordDiff rewrites a word diff as a unified line diff. For every hunk,
+// in order, it also returns the changed spans of each of its lines, in
+// order, offsets counted in runes of the line content. Git prints the text
+// between changed words from the new side, so whitespace next to a removed
+// word can be off by a space on the old side.
+func fromWordDiff(data []byte) ([]byte, [][][]Span) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	porcelain := bytes.Contains(data, []byte("\n~\n")) || bytes.HasSuffix(data, []byte("\n~"))
+
+	var out strings.Builder
+	var spans [][][]Span
+	for i := 0; i < len(lines); {
+		m := hunkHeaderRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			out.WriteString(lines[i])
+			out.WriteByte('\n')
+			i++
+			continue
+		}
+		i++
+		var logical [][]segment
+		for i < len(lines) && !strings.HasPrefix(lines[i], "@@ ") && !strings.HasPrefix(lines[i], "diff ") {
+			if porcelain {
+				var segs []segment
+				for i < len(lines) && lines[i] != "~" && !strings.HasPrefix(lines[i], "@@ ") && !strings.HasPrefix(lines[i], "diff ") {
+					if l := lines[i]; l != "" && l[0] != '\\' {
+						segs = append(segs, segment{l[0], l[1:]})
+					}
+					i++
+				}
+				if i < len(lines) && lines[i] == "~" {
+					i++
+				}
+				logical = append(logical, segs)
+			} else {
+				if !strings.HasPrefix(lines[i], "\\") {
+					logical = append(logical, plainSegments(lines[i]))
+				}
+				i++
+			}
+		}
+		var body strings.Builder
+		var hunkSpans [][]Span
+		oldN, newN := 0, 0
+		for _, segs := range logical {
+			for _, l := range lineDiff(segs) {
+				body.WriteByte(l.op)
+				body.WriteString(l.text)
+				body.WriteByte('\n')
+				hunkSpans = append(hunkSpans, l.spans)
+				if l.op != '+' {
+					oldN++
+				}
+				if l.op != '-' {
+					newN++
+				}
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s,%d +%s,%d @@%s\n", m[1], oldN, m[2], newN, m[3])
+		out.WriteString(body.String())
+		spans = append(spans, hunkSpans)
+	}
+	return []byte(out.String()), spans
+}
+
+// plainSegments splits a --word-diff=plain line at its [-removed-] and
+// {+added+} markers.
+func plainSegments(line string) []segment {
+	var segs []segment
+	for line != "" {
+		del, add := strings.Index(line, "[-"), strings.Index(line, "{+")
+		start, op, end := del, byte('-'), "-]"
+		if del < 0 || (add >= 0 && add < del) {
+			start, op, end = add, '+', "+}"
+		}
+		if start < 0 {
+			break
+		}
+		stop := strings.Index(line[start+2:], end)
+		if stop < 0 {
+			break
+		}
+		if start > 0 {
+			segs = append(segs, segment{' ', line[:start]})
+		}
+		segs = append(segs, segment{op, line[start+2 : start+2+stop]})
+		line = line[start+2+stop+2:]
+	}
+	if line != "" {
+		segs = append(segs, segment{' ', line})
+	}
+	return segs
+}
+
+type diffLine struct {
+	op    byte
+	text  string
+	spans []Span
+}
+
+// lineDiff turns the segments of one line into a context line, or the
+// removed and added versions of the line with their changed words as spans.
+func lineDiff(segs []segment) []diffLine {
+	var oldText, newText strings.Builder
+	var oldSpans, newSpans []Span
+	removed, added, common := false, false, false
+	// Common text comes from the new side, with the whitespace on both sides
+	// of a word only one side has: "a {+b+} c" was "a c", not "a  c".
+	oldGap, newGap := false, false
+	for _, sg := range segs {
+		n := utf8.RuneCountInString(sg.text)
+		switch sg.op {
+		case '-':
+			removed, newGap = true, true
+			at := utf8.RuneCountInString(oldText.String())
+			oldSpans = append(oldSpans, Span{at, at + n})
+			oldText.WriteString(sg.text)
+		case '+':
+			added, oldGap = true, true
+			at := utf8.RuneCountInString(newText.String())
+			newSpans = append(newSpans, Span{at, at + n})
+			newText.WriteString(sg.text)
+		default:
+			common = common || strings.TrimSpace(sg.text) != ""
+			oldText.WriteString(joinSpace(oldText.String(), sg.text, oldGap))
+			newText.WriteString(joinSpace(newText.String(), sg.text, newGap))
+			oldGap, newGap = false, false
+		}
+	}
+	if !removed && !added {
+		return []diffLine{{op: ' ', text: oldText.String()}}
+	}
+	var lines []diffLine
+	if removed || common {
+		lines = append(lines, diffLine{'-', oldText.String(), oldSpans})
+	}
+	if added || common {
+		lines = append(lines, diffLine{'+', newText.String(), newSpans})
+	}
+	return lines
+}
+
+// joinSpace returns text to append after before, without its first rune
+// when that and the end of before are both spaces left around a gap.
+func joinSpace(before, text string, gap bool) string {
+	if !gap || !strings.HasSuffix(before, " ") || !strings.HasPrefix(text, " ") {
+		return text
+	}
+	return text[1:]
+}
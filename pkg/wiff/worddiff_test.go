@@ -0,0 +1,73 @@
+package wiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+const wordDiffPlain = `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,3 +1,4 @@
+hello {+there+} world
+same line
+foo [-bar-]{+qux+} baz
+{+new line+}
+`
+
+const wordDiffPorcelain = `diff --git a/f.txt b/f.txt
+--- a/f.txt
++++ b/f.txt
+@@ -1,3 +1,4 @@
+ hello 
++there
+  world
+~
+ same line
+~
+ foo 
+-bar
++qux
+  baz
+~
++new line
+~
+`
+
+func TestParseWordDiff(t *testing.T) {
+	want := []Line{
+		{Op: '-', Content: "hello world"},
+		{Op: '+', Content: "hello there world", Changed: []Span{{6, 11}}},
+		{Op: ' ', Content: "same line"},
+		{Op: '-', Content: "foo bar baz", Changed: []Span{{4, 7}}},
+		{Op: '+', Content: "foo qux baz", Changed: []Span{{4, 7}}},
+		{Op: '+', Content: "new line", Changed: []Span{{0, 8}}},
+	}
+	for name, diff := range map[string]string{"plain": wordDiffPlain, "porcelain": wordDiffPorcelain} {
+		hunks, err := Parse([]byte(diff))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(hunks) != 1 || !reflect.DeepEqual(hunks[0].Lines, want) {
+			t.Errorf("%s: lines = %+v", name, hunks)
+		}
+	}
+}
+
+func TestIsWordDiff(t *testing.T) {
+	if isWordDiff([]byte(sample)) {
+		t.Error("a line diff read as a word diff")
+	}
+	if !isWordDiff([]byte(wordDiffPlain)) || !isWordDiff([]byte(wordDiffPorcelain)) {
+		t.Error("word diff not detected")
+	}
+}
+
+func TestWrapClipsChangedSpans(t *testing.T) {
+	lines := Wrap([]DisplayLine{{Text: "+abcdefgh", Style: StyleAdded, Changed: []Span{{3, 7}}}}, 5)
+	if len(lines) != 2 ||
+		!reflect.DeepEqual(lines[0].Changed, []Span{{3, 5}}) ||
+		!reflect.DeepEqual(lines[1].Changed, []Span{{0, 2}}) {
+		t.Errorf("wrapped = %+v", lines)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+)
+
+// Popup is a modal list box drawn over the diff. When OnSelect is set the
+// list is a picker: Enter (or a digit for the first nine items) calls it with
+// the chosen index. Without OnSelect it is a read-only, scrollable panel.
+// OnMove, if set, is called whenever the picker cursor moves (for live
+// previews) and OnCancel when the popup is dismissed without a selection.
+type Popup struct {
+	Title    string
+	Items    []string
+	Cursor   int
+	Scroll   int
+	OnSelect func(s *State, idx int)
+	OnMove   func(s *State, idx int)
+	OnCancel func(s *State)
+}
+
+// OpenPopup shows a picker or panel.
+func OpenPopup(s *State, p *Popup) {
+	s.Popup = p
+}
+
+// ClosePopup dismisses the active popup.
+func ClosePopup(s *State) {
+	s.Popup = nil
+}
+
+// cancelPopup dismisses the popup without a selection.
+func cancelPopup(s *State, p *Popup) {
+	ClosePopup(s)
+	if p.OnCancel != nil {
+		p.OnCancel(s)
+	}
+}
+
+// popupRect returns the popup box position and size for the current screen.
+func popupRect(s *State, p *Popup) (x0, y0, w, h int) {
+	w = len([]rune(p.Title)) + 8
+	for _, it := range p.Items {
+		if n := len([]rune(it)) + 6; n > w {
+			w = n
+		}
+	}
+	if w > s.Width-4 {
+		w = s.Width - 4
+	}
+	if w < 10 {
+		w = 10
+	}
+	h = len(p.Items) + 2
+	if h > s.Height-4 {
+		h = s.Height - 4
+	}
+	if h < 3 {
+		h = 3
+	}
+	x0 = (s.Width - w) / 2
+	y0 = (s.Height - h) / 2
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	return
+}
+
+// popupRows returns how many items fit inside the popup box.
+func popupRows(s *State, p *Popup) int {
+	_, _, _, h := popupRect(s, p)
+	return h - 2
+}
+
+// movePopupCursor moves the cursor by delta and keeps it visible.
+func movePopupCursor(s *State, p *Popup, delta int) {
+	if p.OnSelect == nil {
+		// Panels scroll instead of moving a cursor
+		p.Scroll += delta
+		if max := len(p.Items) - popupRows(s, p); p.Scroll > max {
+			p.Scroll = max
+		}
+		if p.Scroll < 0 {
+			p.Scroll = 0
+		}
+		return
+	}
+	p.Cursor += delta
+	if p.Cursor >= len(p.Items) {
+		p.Cursor = len(p.Items) - 1
+	}
+	if p.Cursor < 0 {
+		p.Cursor = 0
+	}
+	rows := popupRows(s, p)
+	if p.Cursor < p.Scroll {
+		p.Scroll = p.Cursor
+	} else if rows > 0 && p.Cursor >= p.Scroll+rows {
+		p.Scroll = p.Cursor - rows + 1
+	}
+	if p.OnMove != nil && len(p.Items) > 0 {
+		p.OnMove(s, p.Cursor)
+	}
+}
+
+// selectPopupItem closes the popup and runs its OnSelect callback.
+func selectPopupItem(s *State, p *Popup, idx int) {
+	if idx < 0 || idx >= len(p.Items) {
+		return
+	}
+	ClosePopup(s)
+	if p.OnSelect != nil {
+		p.OnSelect(s, idx)
+	}
+}
+
+// HandlePopupKey handles key input while a popup is open.
+func HandlePopupKey(s *State, ev *tcell.EventKey) bool {
+	p := s.Popup
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		cancelPopup(s, p)
+	case tcell.KeyEnter:
+		if p.OnSelect != nil {
+			selectPopupItem(s, p, p.Cursor)
+		} else {
+			ClosePopup(s)
+		}
+	case tcell.KeyUp:
+		movePopupCursor(s, p, -1)
+	case tcell.KeyDown:
+		movePopupCursor(s, p, 1)
+	case tcell.KeyCtrlD:
+		movePopupCursor(s, p, popupRows(s, p)/2)
+	case tcell.KeyCtrlU:
+		movePopupCursor(s, p, -popupRows(s, p)/2)
+	case tcell.KeyRune:
+		r := ev.Rune()
+		switch {
+		case r == 'q':
+			cancelPopup(s, p)
+		case r == 'j':
+			movePopupCursor(s, p, 1)
+		case r == 'k':
+			movePopupCursor(s, p, -1)
+		case r >= '1' && r <= '9' && p.OnSelect != nil:
+			selectPopupItem(s, p, int(r-'1'))
+		}
+	}
+	return false
+}
+
+// drawPopup renders the active popup as a bordered box.
+func drawPopup(s *State) {
+	p := s.Popup
+	screen := s.Screen
+	x0, y0, w, h := popupRect(s, p)
+	border := s.Theme.Dim
+	body := s.Theme.Default
+
+	for row := y0; row < y0+h && row < s.Height; row++ {
+		for col := x0; col < x0+w && col < s.Width; col++ {
+			ch := ' '
+			style := body
+			switch {
+			case row == y0 && col == x0:
+				ch, style = '┌', border
+			case row == y0 && col == x0+w-1:
+				ch, style = '┐', border
+			case row == y0+h-1 && col == x0:
+				ch, style = '└', border
+			case row == y0+h-1 && col == x0+w-1:
+				ch, style = '┘', border
+			case row == y0 || row == y0+h-1:
+				ch, style = '─', border
+			case col == x0 || col == x0+w-1:
+				ch, style = '│', border
+			}
+			screen.SetContent(col, row, ch, nil, style)
+		}
+	}
+
+	// Title embedded in the top border
+	col := x0 + 2
+	for _, r := range " " + p.Title + " " {
+		if col >= x0+w-2 {
+			break
+		}
+		screen.SetContent(col, y0, r, nil, s.Theme.Default.Bold(true))
+		col++
+	}
+
+	rows := h - 2
+	for i := 0; i < rows; i++ {
+		idx := p.Scroll + i
+		if idx >= len(p.Items) {
+			break
+		}
+		style := body
+		if p.OnSelect != nil && idx == p.Cursor {
+			style = body.Reverse(true)
+		}
+		y := y0 + 1 + i
+		col := x0 + 1
+		line := " " + p.Items[idx]
+		for _, r := range line {
+			if col >= x0+w-1 {
+				break
+			}
+			screen.SetContent(col, y, r, nil, style)
+			col++
+		}
+		for col < x0+w-1 {
+			screen.SetContent(col, y, ' ', nil, style)
+			col++
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPopupPickerSelect(t *testing.T) {
+	s := &State{Width: 80, Height: 40}
+	picked := -1
+	OpenPopup(s, &Popup{
+		Title:    "pick",
+		Items:    []string{"one", "two", "three"},
+		OnSelect: func(_ *State, idx int) { picked = idx },
+	})
+
+	HandleKey(s, makeKeyEvent('j'))
+	HandleKey(s, makeKeyEvent('j'))
+	HandleKey(s, makeKeyEvent('j')) // clamps at last item
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+
+	if picked != 2 {
+		t.Errorf("picked = %d, want 2", picked)
+	}
+	if s.Popup != nil {
+		t.Error("popup should close after selection")
+	}
+}
+
+func TestPopupDigitSelect(t *testing.T) {
+	s := &State{Width: 80, Height: 40}
+	picked := -1
+	OpenPopup(s, &Popup{
+		Items:    []string{"one", "two"},
+		OnSelect: func(_ *State, idx int) { picked = idx },
+	})
+	HandleKey(s, makeKeyEvent('2'))
+	if picked != 1 {
+		t.Errorf("picked = %d, want 1", picked)
+	}
+}
+
+func TestPopupEscapeCloses(t *testing.T) {
+	s := &State{Width: 80, Height: 40}
+	OpenPopup(s, &Popup{Items: []string{"x"}})
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	if s.Popup != nil {
+		t.Error("Esc should close the popup")
+	}
+}
+
+func TestPopupPanelScrolls(t *testing.T) {
+	s := &State{Width: 80, Height: 10}
+	items := make([]string, 20)
+	OpenPopup(s, &Popup{Items: items})
+	for i := 0; i < 30; i++ {
+		HandleKey(s, makeKeyEvent('j'))
+	}
+	if want := len(items) - popupRows(s, s.Popup); s.Popup.Scroll != want {
+		t.Errorf("Scroll = %d, want %d", s.Popup.Scroll, want)
+	}
+}
+
+func TestOpenFileWithSeveralOpenersShowsPicker(t *testing.T) {
+	s := &State{Width: 80, Height: 40, Config: Config{Openers: []Opener{
+		{Name: "code", Command: "code -g {file}:{line}"},
+		{Name: "idea", Command: "idea --line {line} {file}"},
+	}}}
+	openFile(s, "main.go", 10)
+	if s.Popup == nil || len(s.Popup.Items) != 2 {
+		t.Fatalf("expected an opener picker with 2 items, got %+v", s.Popup)
+	}
+}
+
+func TestPopupPreviewAndCancel(t *testing.T) {
+	s := &State{Width: 80, Height: 40}
+	var moved []int
+	cancelled := false
+	OpenPopup(s, &Popup{
+		Items:    []string{"one", "two", "three"},
+		OnSelect: func(_ *State, idx int) {},
+		OnMove:   func(_ *State, idx int) { moved = append(moved, idx) },
+		OnCancel: func(_ *State) { cancelled = true },
+	})
+	HandleKey(s, makeKeyEvent('j'))
+	HandleKey(s, makeKeyEvent('j'))
+	if len(moved) != 2 || moved[1] != 2 {
+		t.Errorf("OnMove calls = %v, want [1 2]", moved)
+	}
+	HandleKey(s, makeKeyEvent('q'))
+	if !cancelled || s.Popup != nil {
+		t.Errorf("cancelled = %v, popup = %v", cancelled, s.Popup)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// InputPrompt is a single-line input on the row above the status bar: the
+// search, the replace pattern, the command of |, the diff of a new tab, a
+// note. Like a Popup it takes every key until Enter or Esc closes it.
+// Each prompt brings its label and what to do with the input; editing,
+// history and completion are the same for all.
+type InputPrompt struct {
+	Label string
+	Input string
+	// History names the file past inputs are kept in, <name>_history in
+	// the state directory; "" keeps none.
+	History string
+	// Complete returns what Tab can turn the input into, in order.
+	Complete func(s *State, input string) []string
+	// OnChange runs after every change of the input, for live previews.
+	OnChange func(s *State, input string)
+	// OnKey sees each key first and reports whether it handled it.
+	OnKey func(s *State, ev *tcell.EventKey) bool
+	// Hint returns a note for the right end of the bar.
+	Hint     func(s *State) string
+	OnSubmit func(s *State, input string)
+	OnCancel func(s *State)
+
+	history     []string // past inputs, oldest first
+	historyPos  int      // entry shown by Up and Down; len(history) for the typed input
+	draft       string   // the typed input, kept while Up and Down show the history
+	recall      *historyRecall
+	completions []string
+	completion  int
+}
+
+// historyRecall is a reverse search of a prompt's history (Ctrl-R): the
+// text looked for, the entry it found and the input to go back to on Esc.
+type historyRecall struct {
+	query   string
+	pos     int  // index in the history of the entry shown
+	failing bool // no entry has query
+	before  string
+}
+
+// maxHistory is how many inputs a history file keeps.
+const maxHistory = 200
+
+// OpenPrompt shows p, with its history read afresh.
+func OpenPrompt(s *State, p *InputPrompt) {
+	if p.History != "" {
+		p.history = loadHistory(p.History)
+	}
+	p.historyPos = len(p.history)
+	s.Prompt = p
+}
+
+// HandlePromptKey handles a key while a prompt is open. Enter submits the
+// input, Esc cancels, Up and Down go through the history, Ctrl-R searches
+// it, Tab completes, Ctrl-U clears the input and Ctrl-W deletes a word.
+func HandlePromptKey(s *State, ev *tcell.EventKey) {
+	p := s.Prompt
+	if p.recall != nil && p.handleRecallKey(s, ev) {
+		return
+	}
+	if ev.Key() != tcell.KeyTab && ev.Key() != tcell.KeyBacktab {
+		p.completions = nil
+	}
+	if p.OnKey != nil && p.OnKey(s, ev) {
+		return
+	}
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		s.Prompt = nil
+		if p.OnCancel != nil {
+			p.OnCancel(s)
+		}
+	case tcell.KeyEnter:
+		s.Prompt = nil
+		if p.History != "" {
+			addHistory(p.History, p.Input)
+		}
+		if p.OnSubmit != nil {
+			p.OnSubmit(s, p.Input)
+		}
+	case tcell.KeyUp:
+		p.historyStep(s, -1)
+	case tcell.KeyDown:
+		p.historyStep(s, 1)
+	case tcell.KeyCtrlR:
+		p.startRecall(s)
+	case tcell.KeyTab:
+		p.complete(s, 1)
+	case tcell.KeyBacktab:
+		p.complete(s, -1)
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if p.Input != "" {
+			_, size := utf8.DecodeLastRuneInString(p.Input)
+			p.setInput(s, p.Input[:len(p.Input)-size])
+		}
+	case tcell.KeyCtrlU:
+		p.setInput(s, "")
+	case tcell.KeyCtrlW:
+		in := strings.TrimRightFunc(p.Input, unicode.IsSpace)
+		p.setInput(s, in[:strings.LastIndexFunc(in, unicode.IsSpace)+1])
+	case tcell.KeyRune:
+		if ev.Modifiers()&tcell.ModAlt == 0 {
+			p.setInput(s, p.Input+string(ev.Rune()))
+		}
+	}
+}
+
+// setInput replaces the input and tells the prompt's OnChange.
+func (p *InputPrompt) setInput(s *State, input string) {
+	p.Input = input
+	if p.OnChange != nil {
+		p.OnChange(s, input)
+	}
+}
+
+// complete shows the next (delta 1) or previous (-1) completion of the
+// input as it was at the first Tab.
+func (p *InputPrompt) complete(s *State, delta int) {
+	if p.Complete == nil {
+		return
+	}
+	if p.completions == nil {
+		p.completions = p.Complete(s, p.Input)
+		if len(p.completions) == 0 {
+			p.completions = nil
+			return
+		}
+		p.completion = 0
+		if delta < 0 {
+			p.completion = len(p.completions) - 1
+		}
+	} else {
+		p.completion = (p.completion + delta + len(p.completions)) % len(p.completions)
+	}
+	p.setInput(s, p.completions[p.completion])
+}
+
+// historyStep moves through the history, back (Up) for delta -1 and
+// forward (Down) for 1. Past the newest entry is the input being typed.
+func (p *InputPrompt) historyStep(s *State, delta int) {
+	pos := p.historyPos + delta
+	if pos < 0 || pos > len(p.history) {
+		return
+	}
+	if p.historyPos == len(p.history) {
+		p.draft = p.Input
+	}
+	p.historyPos = pos
+	if pos == len(p.history) {
+		p.setInput(s, p.draft)
+	} else {
+		p.setInput(s, p.history[pos])
+	}
+}
+
+// startRecall begins a reverse search of the history, showing the newest
+// entry until something is typed.
+func (p *InputPrompt) startRecall(s *State) {
+	if p.historyPos == len(p.history) {
+		p.draft = p.Input
+	}
+	p.recall = &historyRecall{pos: len(p.history), before: p.Input}
+	p.recallFrom(s, len(p.history)-1)
+}
+
+// recallFrom shows the newest entry of the history at or before index from
+// that contains the recall text. Without one the entry shown stays and the
+// reverse search is failing.
+func (p *InputPrompt) recallFrom(s *State, from int) {
+	rc := p.recall
+	rc.failing = true
+	for i := min(from, len(p.history)-1); i >= 0; i-- {
+		if strings.Contains(p.history[i], rc.query) {
+			rc.pos, rc.failing = i, false
+			p.historyPos = i
+			p.setInput(s, p.history[i])
+			return
+		}
+	}
+}
+
+// handleRecallKey handles a key during a reverse search of the history and
+// reports whether it did: typing narrows it, Ctrl-R finds an older entry
+// and Esc goes back to the input before it. Other keys end the reverse
+// search on the entry found and are then handled by the prompt.
+func (p *InputPrompt) handleRecallKey(s *State, ev *tcell.EventKey) bool {
+	rc := p.recall
+	switch ev.Key() {
+	case tcell.KeyCtrlR:
+		p.recallFrom(s, rc.pos-1)
+	case tcell.KeyEscape:
+		p.recall = nil
+		p.setInput(s, rc.before)
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if rc.query != "" {
+			_, size := utf8.DecodeLastRuneInString(rc.query)
+			rc.query = rc.query[:len(rc.query)-size]
+			p.recallFrom(s, len(p.history)-1)
+		}
+	case tcell.KeyRune:
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			p.recall = nil
+			return false
+		}
+		rc.query += string(ev.Rune())
+		p.recallFrom(s, rc.pos)
+	default:
+		p.recall = nil
+		return false
+	}
+	return true
+}
+
+// label returns the text drawn before the input.
+func (p *InputPrompt) label() string {
+	switch {
+	case p.recall == nil:
+		return p.Label
+	case p.recall.failing:
+		return "(failing history `" + p.recall.query + "'): "
+	}
+	return "(history `" + p.recall.query + "'): "
+}
+
+// drawPrompt draws the open prompt on the row above the status bar, with
+// its hint, or the place in the completions, at the right end when the
+// input leaves room.
+func drawPrompt(s *State) {
+	p := s.Prompt
+	label := p.label()
+	drawSearchBar(s, label, p.Input)
+	hint := ""
+	switch {
+	case len(p.completions) > 1:
+		hint = fmt.Sprintf("Tab %d/%d ", p.completion+1, len(p.completions))
+	case p.recall == nil && p.Hint != nil:
+		hint = p.Hint(s)
+	}
+	x := s.Width - utf8.RuneCountInString(hint)
+	if hint == "" || x <= utf8.RuneCountInString(label+p.Input)+2 {
+		return
+	}
+	drawText(s.Screen, x, max(s.Height-2, 0), hint, s.Theme.Dim, s.Width)
+}
+
+// historyPath returns the file of the history called name, next to the
+// state file, or "" without a home directory.
+func historyPath(name string) string {
+	path := statePath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), name+"_history")
+}
+
+// loadHistory returns the inputs of the history called name, oldest first.
+func loadHistory(name string) []string {
+	f, err := os.Open(historyPath(name))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var history []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+// addHistory appends input to the history called name, moving it to the
+// end if it is there already, and saves the history. The file is read
+// again first, so that inputs of other wiff sessions are kept.
+func addHistory(name, input string) {
+	if strings.TrimSpace(input) == "" || strings.Contains(input, "\n") {
+		return
+	}
+	history := slices.DeleteFunc(loadHistory(name), func(h string) bool { return h == input })
+	history = append(history, input)
+	history = history[max(len(history)-maxHistory, 0):]
+	path := historyPath(name)
+	if path == "" {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0o644)
+	}
+	if err != nil {
+		logger.Warn("saving the history failed", "history", name, "err", err)
+	}
+}
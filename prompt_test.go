@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPromptEditing(t *testing.T) {
+	s := &State{}
+	var changes []string
+	submitted := ""
+	OpenPrompt(s, &InputPrompt{
+		Label:    "> ",
+		OnChange: func(s *State, input string) { changes = append(changes, input) },
+		OnSubmit: func(s *State, input string) { submitted = input },
+	})
+	key := func(k tcell.Key) { HandlePromptKey(s, tcell.NewEventKey(k, 0, tcell.ModNone)) }
+	for _, r := range "git grep  héllo" {
+		HandlePromptKey(s, makeKeyEvent(r))
+	}
+	key(tcell.KeyBackspace2)
+	if s.Prompt.Input != "git grep  héll" {
+		t.Fatalf("Backspace: input %q", s.Prompt.Input)
+	}
+	key(tcell.KeyCtrlW)
+	if s.Prompt.Input != "git grep  " {
+		t.Errorf("^W: input %q", s.Prompt.Input)
+	}
+	key(tcell.KeyCtrlW)
+	if s.Prompt.Input != "git " {
+		t.Errorf("^W over spaces: input %q", s.Prompt.Input)
+	}
+	key(tcell.KeyCtrlU)
+	if s.Prompt.Input != "" || changes[len(changes)-1] != "" {
+		t.Errorf("^U: input %q, last change %q", s.Prompt.Input, changes[len(changes)-1])
+	}
+	HandlePromptKey(s, makeKeyEvent('x'))
+	key(tcell.KeyEnter)
+	if s.Prompt != nil || submitted != "x" {
+		t.Errorf("Enter: prompt %v, submitted %q", s.Prompt, submitted)
+	}
+}
+
+func TestPromptCompletion(t *testing.T) {
+	s := &State{Hunks: []Hunk{
+		{File: "cmd/main.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "a"}}},
+		{File: "main_test.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "b"}}},
+		{File: "README.md", NewStart: 1, Lines: []Line{{Op: '+', Content: "c"}}},
+	}}
+	s.BuildLines()
+	startGoto(s)
+	for _, r := range "main" {
+		HandlePromptKey(s, makeKeyEvent(r))
+	}
+	key := func(k tcell.Key) { HandlePromptKey(s, tcell.NewEventKey(k, 0, tcell.ModNone)) }
+	key(tcell.KeyTab)
+	first := s.Prompt.Input
+	key(tcell.KeyTab)
+	second := s.Prompt.Input
+	if got := []string{first, second}; !slices.Equal(got, []string{"cmd/main.go:", "main_test.go:"}) {
+		t.Fatalf("Tab, Tab: %q", got)
+	}
+	key(tcell.KeyTab)
+	if s.Prompt.Input != first {
+		t.Errorf("Tab wraps to %q, want %q", s.Prompt.Input, first)
+	}
+	key(tcell.KeyBacktab)
+	if s.Prompt.Input != second {
+		t.Errorf("Shift-Tab: %q, want %q", s.Prompt.Input, second)
+	}
+	if got := completeDiffFiles(s, "main_test.go:"); got != nil {
+		t.Errorf("completing after the colon: %q", got)
+	}
+}
+
+func TestGotoInput(t *testing.T) {
+	s := &State{Width: 80, Height: 24, Hunks: []Hunk{
+		{File: "a.go", OldStart: 10, NewStart: 10, Lines: []Line{{Op: '+', Content: "a10"}, {Op: '+', Content: "a11"}}},
+		{File: "b.go", OldStart: 3, NewStart: 3, Lines: []Line{{Op: '+', Content: "b3"}, {Op: '+', Content: "b4"}}},
+	}}
+	s.BuildLines()
+	s.CursorMode = true
+	at := func() (string, int) {
+		dl := s.Lines[s.Cursor]
+		return s.Hunks[dl.HunkIdx].File, dl.NewLineNo
+	}
+
+	gotoInput(s, "b.go:4")
+	if file, line := at(); file != "b.go" || line != 4 {
+		t.Errorf("b.go:4 went to %s:%d", file, line)
+	}
+	gotoInput(s, "3")
+	if file, line := at(); file != "b.go" || line != 3 {
+		t.Errorf("3 in b.go went to %s:%d", file, line)
+	}
+	gotoInput(s, "a.go")
+	if file := s.CurrentFile(); file != "a.go" {
+		t.Errorf("a.go went to %s", file)
+	}
+	gotoInput(s, "a.go:x")
+	if s.FlashMsg == "" {
+		t.Error("a bad line number flashes nothing")
+	}
+}
+
+func TestPromptHistoriesAreSeparate(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s := &State{}
+	enter := func(history, input string) {
+		OpenPrompt(s, &InputPrompt{History: history})
+		for _, r := range input {
+			HandlePromptKey(s, makeKeyEvent(r))
+		}
+		HandlePromptKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	}
+	enter("pipe", "wc -l")
+	enter("goto", "a.go:3")
+	enter("pipe", "  ") // blank inputs are not kept
+	enter("pipe", "sort")
+	if got := loadHistory("pipe"); !slices.Equal(got, []string{"wc -l", "sort"}) {
+		t.Errorf("pipe history = %q", got)
+	}
+	if got := loadHistory("goto"); !slices.Equal(got, []string{"a.go:3"}) {
+		t.Errorf("goto history = %q", got)
+	}
+
+	OpenPrompt(s, &InputPrompt{History: "pipe"})
+	HandlePromptKey(s, tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone))
+	if s.Prompt.Input != "sort" {
+		t.Errorf("Up: input %q, want sort", s.Prompt.Input)
+	}
+}
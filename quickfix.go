@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// quickfixLine returns the location of h in vim's quickfix (and grep's)
+// format, file:line: text, at its first changed line on the new side. The
+// text has the label, the line counts and the function context.
+func quickfixLine(h *Hunk) string {
+	added, removed := h.Counts()
+	text := fmt.Sprintf("[%s] +%d -%d", h.Label, added, removed)
+	if h.Comment != "" {
+		text += " " + h.Comment
+	}
+	return fmt.Sprintf("%s:%d: %s", h.File, firstChangeLine(h), text)
+}
+
+// firstChangeLine returns the line of the new file where the changes of h
+// start, past its leading context.
+func firstChangeLine(h *Hunk) int {
+	line := h.NewStart
+	for _, l := range h.Lines {
+		if l.Op != ' ' {
+			break
+		}
+		line++
+	}
+	return max(line, 1)
+}
+
+// quickfixList returns the locations of every hunk of s, one per line.
+func quickfixList(s *State) string {
+	var sb strings.Builder
+	for i := range s.Hunks {
+		sb.WriteString(quickfixLine(&s.Hunks[i]))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// printQuickfix loads the diff described by s and writes its hunk
+// locations to w, for vim -q, :cexpr or an editor's quickfix list.
+func printQuickfix(w io.Writer, s *State) error {
+	if err := s.readHunks(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, quickfixList(s))
+	return err
+}
+
+// copyQuickfix copies the hunk locations to the clipboard.
+func copyQuickfix(s *State) {
+	if len(s.Hunks) == 0 {
+		s.FlashMsg = "No hunks to list"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	copyText(s, quickfixList(s), fmt.Sprintf("Copied %d hunk locations (file:line: text)", len(s.Hunks)))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQuickfixList(t *testing.T) {
+	raw := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -10,3 +10,3 @@ func f()
+ keep
+ keep
+-gone
++here
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -3,0 +4,1 @@
++more
+`
+	s := &State{}
+	var err error
+	if s.Hunks, err = s.parseHunks([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("a.go:12: [%s] +1 -1 func f()\nb.txt:4: [%s] +1 -0\n", s.Hunks[0].Label, s.Hunks[1].Label)
+	if got := quickfixList(s); got != want {
+		t.Errorf("quickfix list:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// tcell only sends the cells that changed to the terminal, so the cost of
+// a full redraw is building every row: syntax highlighting, search masks and
+// styles. The row cache keeps the cells of each diff row drawn in the last
+// frame; a row that shows the same line under the same view settings is
+// copied instead of drawn again, wherever it moved to when scrolling.
+
+// rowView is what every diff row depends on. A change to any of it
+// redraws all rows.
+type rowView struct {
+	diffX, diffWidth, labelGutter int
+	labelWidth, lineNoWidth       int
+	scrollX, rightScrollX         int
+	lineNumbers, bothLineNumbers  bool
+	wrap, sideBySide, hideOps     bool
+	columnRatio                   int
+	headerLabels, zen             bool
+	diffBg, syntax, colorMoved    bool
+	search                        searchMatcher
+	matches                       bool
+	theme                         UITheme
+	hl                            *Highlighter
+	hlVersion                     int
+	highlightLimit                int
+	todo                          *regexp.Regexp
+}
+
+// rowKey is what a single diff row depends on beyond its rowView: the
+// display line, the hunk's file for the syntax lexer, the check mark of a
+// staged or applied hunk, whether the row is on the current search match,
+// and the gutter's mark and coverage bar. Notes, diagnostics, the index
+// split and coverage change without a rebuild of the lines, so the marks
+// they draw are part of the key.
+type rowKey struct {
+	text, label          string
+	style                LineStyle
+	hunkIdx              int
+	oldLineNo, newLineNo int
+	continuation, moved  bool
+	added, removed       int
+	changed              string
+	left, right          halfKey
+
+	file                   string
+	checked, currentMatch  bool
+	mark                   rune
+	markStyle              tcell.Style
+	covered, coverageKnown bool
+}
+
+// halfKey is a side of a side-by-side row in its rowKey.
+type halfKey struct {
+	text    string
+	style   LineStyle
+	lineNo  int
+	moved   bool
+	changed string
+}
+
+type cachedCell struct {
+	mainc rune
+	combc []rune
+	style tcell.Style
+}
+
+// rowCache holds the rows of the last frame of a view.
+type rowCache struct {
+	view rowView
+	rows map[rowKey][]cachedCell
+}
+
+func (s *State) rowView() rowView {
+	v := rowView{
+		diffX:           s.DiffX,
+		diffWidth:       s.DiffWidth,
+		labelGutter:     s.LabelGutter,
+		labelWidth:      s.gutterLabelWidth(),
+		headerLabels:    s.headerLabels(),
+		zen:             s.Zen,
+		lineNoWidth:     s.lineNoWidth(),
+		scrollX:         s.ScrollX,
+		rightScrollX:    s.RightScrollX(),
+		lineNumbers:     s.LineNumbers,
+		bothLineNumbers: s.Config.BothLineNumbers,
+		wrap:            s.Wrap,
+		sideBySide:      s.SideBySide,
+		columnRatio:     s.columnRatio(),
+		hideOps:         s.Config.HideSplitOps,
+		diffBg:          s.DiffBg,
+		syntax:          s.SyntaxHighlight,
+		colorMoved:      s.ColorMoved,
+		search:          s.searchMatcher(),
+		matches:         len(s.SearchMatches) > 0,
+		theme:           s.Theme,
+		hl:              s.HL,
+		highlightLimit:  s.Config.maxHighlightLine(),
+		todo:            s.todoPattern(),
+	}
+	if s.HL != nil {
+		v.hlVersion = s.HL.Version()
+	}
+	return v
+}
+
+func (s *State) rowKey(line DisplayLine, lineIdx int) rowKey {
+	k := rowKey{
+		text:         line.Text,
+		label:        line.Label,
+		style:        line.Style,
+		hunkIdx:      line.HunkIdx,
+		oldLineNo:    line.OldLineNo,
+		newLineNo:    line.NewLineNo,
+		continuation: line.Continuation,
+		moved:        line.Moved,
+		added:        line.Added,
+		removed:      line.Removed,
+		changed:      spansKey(line.Changed),
+		left:         halfLineKey(line.Left),
+		right:        halfLineKey(line.Right),
+		currentMatch: isCurrentMatchLine(s, lineIdx),
+	}
+	if line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) {
+		h := &s.Hunks[line.HunkIdx]
+		k.file, k.checked = h.File, h.Staged || h.Applied
+	}
+	k.mark, k.markStyle = s.gutterMark(line)
+	k.covered, k.coverageKnown = s.lineCoverage(line)
+	return k
+}
+
+func halfLineKey(h HalfLine) halfKey {
+	return halfKey{text: h.Text, style: h.Style, lineNo: h.LineNo, moved: h.Moved, changed: spansKey(h.Changed)}
+}
+
+// spansKey packs word-diff spans into a string, "" for none.
+func spansKey(spans []Span) string {
+	if len(spans) == 0 {
+		return ""
+	}
+	b := make([]byte, 0, len(spans)*8)
+	for _, sp := range spans {
+		b = strconv.AppendInt(b, int64(sp.Start), 10)
+		b = append(b, '-')
+		b = strconv.AppendInt(b, int64(sp.End), 10)
+		b = append(b, ',')
+	}
+	return string(b)
+}
+
+// startRows begins a frame of the row cache and returns the map the rows
+// of this frame go into.
+func (s *State) startRows() map[rowKey][]cachedCell {
+	if v := s.rowView(); v != s.rows.view {
+		s.rows = rowCache{view: v}
+	}
+	s.stats.rows, s.stats.rowsDrawn = 0, 0
+	return make(map[rowKey][]cachedCell, len(s.rows.rows))
+}
+
+// drawRow draws line at row y of the diff pane, copying the row from the
+// last frame when it showed the same thing. File headers are cheap and
+// always drawn.
+func (s *State) drawRow(y int, line DisplayLine, lineIdx int, frame map[rowKey][]cachedCell) {
+	s.stats.rows++
+	if line.Style == StyleFileHeader {
+		s.stats.rowsDrawn++
+		drawInlineLine(s, y, line, lineIdx)
+		return
+	}
+	key := s.rowKey(line, lineIdx)
+	if cells, ok := s.rows.rows[key]; ok {
+		for i, c := range cells {
+			s.Screen.SetContent(s.DiffX+i, y, c.mainc, c.combc, c.style)
+		}
+		frame[key] = cells
+		return
+	}
+	s.stats.rowsDrawn++
+	if s.SideBySide {
+		drawSideBySideLine(s, y, line, lineIdx)
+	} else {
+		drawInlineLine(s, y, line, lineIdx)
+	}
+	cells := make([]cachedCell, s.DiffWidth)
+	for i := range cells {
+		c := &cells[i]
+		c.mainc, c.combc, c.style, _ = s.Screen.GetContent(s.DiffX+i, y)
+	}
+	frame[key] = cells
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// screenText returns the runes of the top rows of sim, one string per row.
+func screenText(sim tcell.Screen, w, rows int) []string {
+	out := make([]string, rows)
+	for y := range out {
+		var line []rune
+		for x := 0; x < w; x++ {
+			r, _, _, _ := sim.GetContent(x, y)
+			line = append(line, r)
+		}
+		out[y] = string(line)
+	}
+	return out
+}
+
+func TestDrawRowReusesUnchangedRows(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(60, 12)
+
+	var lines []Line
+	for i := 0; i < 30; i++ {
+		lines = append(lines, Line{Op: '+', Content: fmt.Sprintf("x := %d", i)})
+	}
+	s := &State{Screen: sim, Width: 60, Height: 12, HL: NewHighlighter(), SyntaxHighlight: true,
+		Hunks: []Hunk{{Label: "a", File: "a.go", OldStart: 1, NewStart: 1, Lines: lines}}}
+	s.BuildLines()
+
+	Render(s)
+	if s.stats.rowsDrawn != s.stats.rows {
+		t.Fatalf("first frame drew %d of %d rows", s.stats.rowsDrawn, s.stats.rows)
+	}
+	Render(s)
+	if s.stats.rowsDrawn != 1 {
+		t.Errorf("unchanged frame drew %d rows, want only the file header", s.stats.rowsDrawn)
+	}
+
+	// Three new rows at the bottom, and the top row now carries the sticky
+	// hunk label.
+	s.Scroll = 3
+	Render(s)
+	if s.stats.rowsDrawn != 4 {
+		t.Errorf("scrolling by 3 drew %d rows, want 4", s.stats.rowsDrawn)
+	}
+	cached := screenText(sim, 60, 11)
+
+	s.rows = rowCache{}
+	Render(s)
+	if full := screenText(sim, 60, 11); fmt.Sprint(full) != fmt.Sprint(cached) {
+		t.Errorf("cached frame differs from a full redraw:\n%q\n%q", cached, full)
+	}
+
+	s.Hunks[0].Staged = true
+	Render(s)
+	if s.stats.rowsDrawn == 0 {
+		t.Error("staging the hunk should redraw its rows")
+	}
+}
+
+func TestDrawRowRedrawsGutterMarks(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(60, 12)
+
+	s := &State{Screen: sim, Width: 60, Height: 12,
+		Hunks: []Hunk{{Label: "a", File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{
+			{Op: '+', Content: "x := 1"},
+			{Op: '+', Content: "y := 2"},
+		}}}}
+	s.BuildLines()
+	Render(s)
+	hasMark := func(mark rune) bool {
+		for _, row := range screenText(sim, 60, 11) {
+			if strings.ContainsRune(row, mark) {
+				return true
+			}
+		}
+		return false
+	}
+
+	s.Notes = Notes{"a.go": {1: "why?"}}
+	Render(s)
+	if !hasMark('✎') {
+		t.Error("a new note is not drawn on the cached row")
+	}
+	s.DiagLines = LineDiagnostics{"a.go": {2: {{File: "a.go", Line: 2, Severity: "error", Message: "bad"}}}}
+	Render(s)
+	if !hasMark('!') {
+		t.Error("a new diagnostic is not drawn on the cached row")
+	}
+	s.DiagLines = nil
+	Render(s)
+	if hasMark('!') {
+		t.Error("a cleared diagnostic is still drawn")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// resolveArgs separates the refs of a diff's arguments from its paths as git
+// diff does: everything after "--" is a path, and before it the paths start
+// at the first argument naming a file rather than a revision. Each ref is
+// checked with git rev-parse, so a typo is an error saying so instead of an
+// empty diff. Options git diff takes ("-w") stay with the refs. Two files,
+// a patch directory, piped input and other VCSs keep their arguments as is.
+func resolveArgs(args []string) (refs, paths []string, err error) {
+	if i := slices.Index(args, "--"); i >= 0 {
+		refs, paths = args[:i], args[i+1:]
+		if _, git := repo.(gitVCS); git && !isPipe() {
+			for _, ref := range refs {
+				if !strings.HasPrefix(ref, "-") && !validRev(ref) {
+					return nil, nil, unknownRevError(ref, false)
+				}
+			}
+		}
+		return refs, paths, nil
+	}
+	if _, git := repo.(gitVCS); !git || isPipe() || bothFiles(args) || seriesDir(args) != "" {
+		return args, nil, nil
+	}
+	if _, err := repo.Root(); err != nil {
+		return args, nil, nil // outside a repository; the view says so
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			refs = append(refs, arg)
+			continue
+		}
+		_, statErr := os.Stat(arg)
+		isPath := statErr == nil
+		if len(paths) > 0 {
+			if !isPath {
+				return nil, nil, fmt.Errorf("%q is not a file in the working tree (paths of deleted files go after --)", arg)
+			}
+			paths = append(paths, arg)
+			continue
+		}
+		isRev := validRev(arg)
+		switch {
+		case isRev && isPath:
+			return nil, nil, fmt.Errorf("%q is both a revision and a file: use \"wiff %s --\" for the revision or \"wiff -- %s\" for the file", arg, arg, arg)
+		case isRev:
+			refs = append(refs, arg)
+		case isPath:
+			paths = append(paths, arg)
+		default:
+			return nil, nil, unknownRevError(arg, true)
+		}
+	}
+	return refs, paths, nil
+}
+
+// validRev reports whether git knows ref as a revision, or both ends of a
+// range ("a..b", "a...b", an open end meaning HEAD).
+func validRev(ref string) bool {
+	if from, to, ok := splitRange(ref); ok {
+		return (from == "" || validRev(from)) && (to == "" || validRev(to))
+	}
+	if ref == "" {
+		return false
+	}
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{object}")
+	start := time.Now()
+	err := cmd.Run()
+	logCommand(cmd, start, err)
+	return err == nil
+}
+
+// unknownRevError says ref isn't a revision (nor a file, when orPath is
+// set), suggesting the closest branch or tag for a typo.
+func unknownRevError(ref string, orPath bool) error {
+	what := "unknown revision"
+	if orPath {
+		what = "unknown revision or path not in the working tree"
+	}
+	// The typo is in one end of a range
+	bad := ref
+	if from, to, ok := splitRange(ref); ok {
+		bad = to
+		if from != "" && !validRev(from) {
+			bad = from
+		}
+	}
+	if guess := closestRef(bad); guess != "" {
+		return fmt.Errorf("%s: %q (did you mean %s?)", what, bad, strings.Replace(ref, bad, guess, 1))
+	}
+	return fmt.Errorf("%s: %q", what, bad)
+}
+
+// gitRefs returns the short names of the branches, tags and remote
+// branches of the repository, or nil outside of one.
+func gitRefs() []string {
+	out, err := commandOutput("", "git", "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/tags", "refs/remotes")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(out)
+}
+
+// closestRef returns the branch, tag or remote branch nearest to name by
+// edit distance, or "" when none is close enough to be a typo of it.
+func closestRef(name string) string {
+	refs := gitRefs()
+	if refs == nil {
+		return ""
+	}
+	best, bestDist := "", max(len(name)/3, 2)+1
+	for _, ref := range append(refs, "HEAD") {
+		if d := editDistance(name, ref); d < bestDist {
+			best, bestDist = ref, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
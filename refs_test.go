@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestResolveArgs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	for _, name := range []string{"a.txt", "feature"} {
+		if err := os.WriteFile(name, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("add", ".")
+	git("commit", "-qm", "Add files")
+	git("branch", "feature")
+	git("branch", "topic")
+
+	tests := []struct {
+		args        []string
+		refs, paths string
+		err         string
+	}{
+		{args: []string{"HEAD", "--", "a.txt", "gone.txt"}, refs: "HEAD", paths: "a.txt gone.txt"},
+		{args: []string{"--", "a.txt"}, paths: "a.txt"},
+		{args: []string{"main", "a.txt"}, refs: "main", paths: "a.txt"},
+		{args: []string{"a.txt"}, paths: "a.txt"},
+		{args: []string{"main..topic", "-w"}, refs: "main..topic -w"},
+		{args: []string{"feature"}, err: `"feature" is both a revision and a file`},
+		{args: []string{"feature", "--"}, refs: "feature"},
+		{args: []string{"tpoic"}, err: `unknown revision or path not in the working tree: "tpoic" (did you mean topic?)`},
+		{args: []string{"main..tpoic"}, err: `"tpoic" (did you mean main..topic?)`},
+		{args: []string{"nothing-like-it", "--"}, err: `unknown revision: "nothing-like-it"`},
+		{args: []string{"a.txt", "gone.txt"}, err: `"gone.txt" is not a file in the working tree`},
+	}
+	for _, tt := range tests {
+		refs, paths, err := resolveArgs(tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveArgs(%q) error = %v, want %q", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if strings.Join(refs, " ") != tt.refs || strings.Join(paths, " ") != tt.paths {
+			t.Errorf("resolveArgs(%q) = %q, %q, want %q, %q", tt.args, refs, paths, tt.refs, tt.paths)
+		}
+	}
+}
+
+func TestPathsLimitTheDiff(t *testing.T) {
+	s := &State{Refs: []string{"HEAD"}, Paths: []string{"a.txt"}, ContextLines: 3}
+	args := s.gitDiffArgs()
+	if got := strings.Join(args[len(args)-3:], " "); got != "HEAD -- a.txt" {
+		t.Errorf("git diff args end in %q", got)
+	}
+	if got := s.RefDisplay(); got != "HEAD -- a.txt" {
+		t.Errorf("RefDisplay = %q", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{{"main", "main", 0}, {"mian", "main", 2}, {"", "abc", 3}, {"feature", "featrue", 2}, {"kitten", "sitting", 3}} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// reloader runs the diffs of watch reloads off the main goroutine, so a
+// slow git diff doesn't block the keyboard. Each reload gets a generation:
+// starting one cancels the one still running, and a result only lands while
+// its generation is the latest, so only the newest reload ever shows.
+type reloader struct {
+	mu       sync.Mutex
+	gen      int
+	cancel   context.CancelFunc
+	started  time.Time
+	latency  time.Duration // start to landing of the last reload
+	canceled int           // reloads dropped for a newer one
+}
+
+// reloads is the reloader of the viewer's watched tabs and panes.
+var reloads reloader
+
+// reloadJob is the diff of one pane: run with the pane's settings as they
+// were when the reload started, then parsed, with the lines placed on the
+// sides of the index and the files measured, all off the main goroutine.
+type reloadJob struct {
+	pane  *State
+	run   func(ctx context.Context) ([]byte, error)
+	parse func(raw []byte) ([]Hunk, error)
+	split func() *IndexSplit                     // nil without sides of the index
+	sizes func(hunks []Hunk) map[string]FileSize // nil without sides to measure
+}
+
+// reloadResult is the finished job of a pane, for applyReload.
+type reloadResult struct {
+	pane  *State
+	hunks []Hunk // as parsed, excluded files still in
+	split *IndexSplit
+	sizes map[string]FileSize
+}
+
+// EventReloadDone carries the diffs of a finished reload back to the main
+// goroutine.
+type EventReloadDone struct {
+	t        time.Time
+	gen      int
+	branch   string
+	tracking *Tracking
+	results  []reloadResult
+}
+
+func (e *EventReloadDone) When() time.Time { return e.t }
+
+// diffJob captures what s.runDiff and the work after it need, for running
+// them off the main goroutine.
+func (s *State) diffJob() reloadJob {
+	job := reloadJob{pane: s, parse: s.parseJob(), split: s.indexSplitJob(), sizes: s.fileSizesJob()}
+	if s.NoIndex {
+		oldPath, newPath, n, algo := s.Refs[0], s.Refs[1], s.ContextLines, s.DiffAlgorithm
+		job.run = func(context.Context) ([]byte, error) {
+			return runNoIndexDiff(oldPath, newPath, n, algo)
+		}
+		return job
+	}
+	argv := repo.DiffArgs(s)
+	job.run = func(ctx context.Context) ([]byte, error) {
+		return runDiffCommand(ctx, argv)
+	}
+	return job
+}
+
+// finish parses raw, the output of the job's diff, and places its lines on
+// the sides of the index and measures its files.
+func (job reloadJob) finish(raw []byte) (reloadResult, error) {
+	hunks, err := job.parse(raw)
+	if err != nil {
+		return reloadResult{}, err
+	}
+	r := reloadResult{pane: job.pane, hunks: hunks}
+	if job.split != nil && len(hunks) > 0 {
+		r.split = job.split()
+	}
+	if job.sizes != nil {
+		r.sizes = job.sizes(hunks)
+	}
+	return r, nil
+}
+
+// startReload reloads panes, tabs and split panes of s, in the background,
+// canceling a reload still running.
+func startReload(s *State, panes []*State) {
+	var jobs []reloadJob
+	for _, p := range panes {
+		jobs = append(jobs, p.diffJob())
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	ctx, gen := reloads.begin()
+	go func() {
+		done := &EventReloadDone{gen: gen, branch: repo.Branch(), tracking: repo.Tracking()}
+		for _, job := range jobs {
+			raw, err := job.run(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				continue
+			}
+			if r, err := job.finish(raw); err == nil {
+				done.results = append(done.results, r)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		done.t = time.Now()
+		_ = s.Screen.PostEvent(done)
+	}()
+}
+
+// begin starts a new reload generation, canceling the previous one.
+func (r *reloader) begin() (context.Context, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.canceled++
+		logger.Debug("reload canceled", "gen", r.gen)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.gen++
+	r.cancel = cancel
+	r.started = time.Now()
+	return ctx, r.gen
+}
+
+// land reports whether a reload of generation gen is still the latest,
+// and if so marks it finished.
+func (r *reloader) land(gen int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gen != r.gen || r.cancel == nil {
+		return false
+	}
+	r.cancel()
+	r.cancel = nil
+	r.latency = time.Since(r.started)
+	logger.Info("reload", "gen", gen, "took", r.latency.Round(time.Microsecond))
+	return true
+}
+
+// stats returns the latency of the last reload and how many were canceled.
+func (r *reloader) stats() (time.Duration, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.latency, r.canceled
+}
+
+// finishReload applies the diffs of a finished reload, unless a newer one
+// was started in the meantime.
+func finishReload(ev *EventReloadDone) bool {
+	if !reloads.land(ev.gen) {
+		return false
+	}
+	for _, r := range ev.results {
+		applyReload(r, ev.branch, ev.tracking)
+	}
+	return true
+}
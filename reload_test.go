@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestReloaderLandsOnlyTheLatest(t *testing.T) {
+	var r reloader
+	first, gen1 := r.begin()
+	_, gen2 := r.begin()
+	if first.Err() == nil {
+		t.Error("starting a reload should cancel the one running")
+	}
+	if r.land(gen1) {
+		t.Error("a stale reload landed")
+	}
+	if !r.land(gen2) {
+		t.Error("the latest reload didn't land")
+	}
+	if r.land(gen2) {
+		t.Error("a reload landed twice")
+	}
+	if _, canceled := r.stats(); canceled != 1 {
+		t.Errorf("canceled = %d, want 1", canceled)
+	}
+}
+
+func TestFinishReloadAppliesResults(t *testing.T) {
+	s := &State{Width: 80, Height: 24}
+	raw := []byte("diff --git a/f.go b/f.go\n--- a/f.go\n+++ b/f.go\n@@ -1 +1 @@\n-a\n+b\n")
+	r, err := reloadJob{pane: s, parse: parseDiff}.finish(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, gen := reloads.begin()
+	if !finishReload(&EventReloadDone{gen: gen, branch: "main", results: []reloadResult{r}}) {
+		t.Fatal("reload didn't land")
+	}
+	if len(s.Hunks) != 1 || s.Hunks[0].File != "f.go" || s.Branch != "main" {
+		t.Errorf("hunks = %+v, branch %q", s.Hunks, s.Branch)
+	}
+}
+
+func TestReloadJobCarriesSplitAndSizes(t *testing.T) {
+	split := &IndexSplit{}
+	var measured []Hunk
+	job := reloadJob{
+		parse: parseDiff,
+		split: func() *IndexSplit { return split },
+		sizes: func(hunks []Hunk) map[string]FileSize {
+			measured = hunks
+			return map[string]FileSize{"f.go": {}}
+		},
+	}
+	raw := []byte("diff --git a/f.go b/f.go\n--- a/f.go\n+++ b/f.go\n@@ -1 +1 @@\n-a\n+b\n")
+	r, err := job.finish(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.split != split || len(measured) != 1 || len(r.sizes) != 1 {
+		t.Errorf("split %p, measured %d hunks, sizes %v", r.split, len(measured), r.sizes)
+	}
+
+	s := &State{Width: 80, Height: 24}
+	applyReload(reloadResult{pane: s, hunks: r.hunks, split: r.split, sizes: r.sizes}, "", nil)
+	if s.IndexSplit != split || len(s.FileSizes) != 1 {
+		t.Errorf("index split %p, file sizes %v", s.IndexSplit, s.FileSizes)
+	}
+
+	// An empty diff has nothing to place on the sides of the index.
+	if r, _ := job.finish(nil); r.split != nil {
+		t.Error("split an empty diff")
+	}
+}
+
+func TestReloadFlashesBranchSwitch(t *testing.T) {
+	s := &State{Width: 80, Height: 24, Branch: "main"}
+	raw := []byte("diff --git a/f.go b/f.go\n--- a/f.go\n+++ b/f.go\n@@ -1 +1 @@\n-a\n+b\n")
+	r, err := reloadJob{pane: s, parse: parseDiff}.finish(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyReload(r, "feature", &Tracking{Behind: 1})
+	if s.Branch != "feature" || s.Tracking == nil || s.FlashMsg != "Switched to ⎇ feature" {
+		t.Errorf("branch %q, tracking %v, flash %q", s.Branch, s.Tracking, s.FlashMsg)
+	}
+}
+
+func TestRefreshKey(t *testing.T) {
+	s := &State{PipeMode: true}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyRune, 'R', tcell.ModNone))
+	if s.FlashMsg != "Piped diffs can't be refreshed" {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+}
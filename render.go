@@ -2,20 +2,24 @@ package main
 
 import (
 	"fmt"
-	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 )
 
-const lineNoWidth = 5 // "1234 " = 4 digits + space
-
 // applyDiffBg adds a subtle background tint based on the line's diff style.
-func applyDiffBg(s *State, style tcell.Style, ls LineStyle) tcell.Style {
-	switch ls {
-	case StyleAdded:
+// Moved lines get their own tints when color-moved is on.
+func applyDiffBg(s *State, style tcell.Style, ls LineStyle, moved bool) tcell.Style {
+	moved = moved && s.ColorMoved
+	switch {
+	case ls == StyleAdded && moved:
+		return style.Background(s.Theme.BgMovedAdded)
+	case ls == StyleRemoved && moved:
+		return style.Background(s.Theme.BgMovedRemoved)
+	case ls == StyleAdded || ls == StyleReplaced:
 		return style.Background(s.Theme.BgAdded)
-	case StyleRemoved:
+	case ls == StyleRemoved:
 		return style.Background(s.Theme.BgRemoved)
 	default:
 		return style
@@ -24,6 +28,20 @@ func applyDiffBg(s *State, style tcell.Style, ls LineStyle) tcell.Style {
 
 // Render draws the screen
 func Render(s *State) {
+	start := time.Now()
+	defer func() { s.stats.frame = time.Since(start) }()
+	if s.Split != nil {
+		renderSplit(s.Split)
+		return
+	}
+	drawView(s)
+	updateTitle(s)
+	s.Screen.Show()
+}
+
+// drawView draws s onto its screen (the whole screen or a split pane)
+// without showing it.
+func drawView(s *State) {
 	screen := s.Screen
 	screen.Clear()
 	s.updateLayout()
@@ -32,9 +50,17 @@ func Render(s *State) {
 		drawTree(s)
 	}
 
-	visible := s.Height - 1
-	if s.SearchMode {
-		visible-- // reserve one row for the search bar above the status bar
+	visible := s.viewRows()
+	if s.Prompt != nil {
+		visible-- // reserve one row for the prompt above the status bar
+	}
+
+	// Sticky file header: when the current file's header has scrolled off,
+	// pin it to the first row of the diff pane in place of the top line.
+	stickyIdx := s.stickyFileHeaderIdx()
+	firstRow := s.Scroll
+	if stickyIdx >= 0 {
+		firstRow++
 	}
 
 	// Compute sticky hunk label: if the first visible line's hunk header
@@ -43,15 +69,20 @@ func Render(s *State) {
 	stickyLabel := ""
 	stickyHunkIdx := -1
 	if len(s.Hunks) > 0 {
-		hIdx := s.CurrentHunkIndex()
-		if hIdx >= 0 && hIdx < len(s.Hunks) && s.Hunks[hIdx].StartLine < s.Scroll {
+		hIdx := s.hunkIndexAt(firstRow)
+		if hIdx >= 0 && hIdx < len(s.Hunks) && s.Hunks[hIdx].StartLine < firstRow {
 			stickyLabel = s.Hunks[hIdx].Label
 			stickyHunkIdx = hIdx
 		}
 	}
 	stickyUsed := false
 
+	frame := s.startRows()
 	for i := 0; i < visible && s.Scroll+i < len(s.Lines); i++ {
+		if i == 0 && stickyIdx >= 0 {
+			drawFileHeader(s, screen, s.DiffX, 0, s.Lines[stickyIdx], s.DiffX+s.DiffWidth)
+			continue
+		}
 		line := s.Lines[s.Scroll+i]
 
 		// Apply sticky label to the first eligible diff content line
@@ -63,26 +94,95 @@ func Render(s *State) {
 			stickyUsed = true
 		}
 
-		lineIdx := s.Scroll + i
-		if s.SideBySide {
-			drawSideBySideLine(s, i, line, lineIdx)
-		} else {
-			drawInlineLine(s, i, line, lineIdx)
+		s.drawRow(i, line, s.Scroll+i, frame)
+	}
+	s.rows.rows = frame
+	if len(s.Lines) == 0 {
+		drawEmptyState(s, visible)
+	} else if s.SideBySide {
+		// The divider runs on below the last line, to the bottom of the pane
+		for y := len(s.Lines) - s.Scroll; y < visible; y++ {
+			s.Screen.SetContent(s.dividerX(), y, '│', nil, s.Theme.Dim)
 		}
 	}
 
-	if s.SearchMode {
-		drawSearchBar(s)
+	if s.CursorMode {
+		drawCursorLine(s, visible, stickyIdx >= 0)
+	}
+	drawHover(s, visible, stickyIdx >= 0)
+	if s.Config.Scrollbar {
+		drawScrollbar(s, visible)
 	}
+	drawAssistantPanel(s)
+	if s.Prompt != nil {
+		drawPrompt(s)
+	}
+	drawCheckPanel(s)
+	drawTutor(s)
 	drawStatusBar(s)
+	if s.Popup != nil {
+		drawPopup(s)
+	}
 	if s.ShowHelp {
 		drawHelpOverlay(s)
 	}
-	screen.Show()
+	if s.Debug {
+		drawDebugOverlay(s)
+	}
+}
+
+// scrollbarThumb returns the first row and height of the scrollbar thumb for
+// a track of the given height.
+func scrollbarThumb(s *State, track int) (int, int) {
+	total := len(s.Lines)
+	if track <= 0 || total <= track {
+		return 0, track
+	}
+	size := track * track / total
+	if size < 1 {
+		size = 1
+	}
+	pos := 0
+	if max := s.MaxScroll(); max > 0 {
+		pos = s.Scroll * (track - size) / max
+	}
+	return pos, size
+}
+
+// drawScrollbar draws a one-column scrollbar to the right of the diff pane.
+func drawScrollbar(s *State, visible int) {
+	x := s.DiffX + s.DiffWidth
+	pos, size := scrollbarThumb(s, visible)
+	for y := 0; y < visible; y++ {
+		if y >= pos && y < pos+size {
+			s.Screen.SetContent(x, y, '┃', nil, s.Theme.Default.Foreground(s.Theme.Accent))
+		} else {
+			s.Screen.SetContent(x, y, '│', nil, s.Theme.Dim)
+		}
+	}
+}
+
+// drawCursorLine re-colors the background of the cursor row in the diff
+// pane, keeping the characters and foreground already drawn. When j/k move
+// by logical lines, the wrapped rows of the cursor line are colored too.
+func drawCursorLine(s *State, visible int, sticky bool) {
+	last := s.Cursor
+	if s.logicalNav() {
+		for last+1 < len(s.Lines) && s.Lines[last+1].Continuation {
+			last++
+		}
+	}
+	for idx := s.Cursor; idx <= last; idx++ {
+		row := idx - s.Scroll
+		if row < 0 || row >= visible || (row == 0 && sticky) {
+			continue
+		}
+		tintRow(s, s.DiffX, s.DiffX+s.DiffWidth, row)
+	}
 }
 
 // drawFileHeader renders ── filename ────────────
-func drawFileHeader(s *State, screen tcell.Screen, x, y int, text string, rightEdge int) {
+func drawFileHeader(s *State, screen tcell.Screen, x, y int, line DisplayLine, rightEdge int) {
 	col := x
 	// Leading decoration
 	screen.SetContent(col, y, '─', nil, s.Theme.Dim)
@@ -91,16 +191,40 @@ func drawFileHeader(s *State, screen tcell.Screen, x, y int, text string, rightE
 	col++
 	screen.SetContent(col, y, ' ', nil, s.Theme.Dim)
 	col++
-	// Filename
-	for _, r := range text {
-		if col >= rightEdge-1 {
-			break
+	// Filename; on the top row of the pane, as a breadcrumb to click
+	if y == 0 && s.breadcrumbLine() >= 0 {
+		col = drawBreadcrumb(s, screen, y, rightEdge-1)
+	} else {
+		for _, r := range line.Text {
+			if col >= rightEdge-1 {
+				break
+			}
+			screen.SetContent(col, y, r, nil, s.Theme.FileHeader)
+			col++
 		}
-		screen.SetContent(col, y, r, nil, s.Theme.FileHeader)
-		col++
 	}
 	screen.SetContent(col, y, ' ', nil, s.Theme.Dim)
 	col++
+	if line.Added > 0 || line.Removed > 0 {
+		col = drawCounts(s, screen, col, y, line, rightEdge-1)
+		screen.SetContent(col, y, ' ', nil, s.Theme.Dim)
+		col++
+	}
+	switch s.Conflicts[line.Text] {
+	case conflictOpen:
+		col = drawText(screen, col, y, "[conflict] ", s.Theme.DiffRemoved.Bold(true), rightEdge-1)
+	case conflictRerere:
+		col = drawText(screen, col, y, "[resolved by rerere] ", s.Theme.Dim.Italic(true), rightEdge-1)
+	}
+	if driver, ok := s.Textconv[line.Text]; ok {
+		col = drawText(screen, col, y, "["+driver+" textconv] ", s.Theme.Dim.Italic(true), rightEdge-1)
+	}
+	if size, ok := s.FileSizes[line.Text]; ok {
+		if size.Ballooned() {
+			col = drawText(screen, col, y, "▲ ", s.Theme.DiffRemoved.Bold(true), rightEdge-1)
+		}
+		col = drawText(screen, col, y, size.String()+" ", s.Theme.Dim, rightEdge-1)
+	}
 	// Trailing decoration
 	for col < rightEdge {
 		screen.SetContent(col, y, '─', nil, s.Theme.Dim)
@@ -108,13 +232,31 @@ func drawFileHeader(s *State, screen tcell.Screen, x, y int, text string, rightE
 	}
 }
 
+// countsText formats a header's line counts as "+a −r".
+func countsText(line DisplayLine) (added, removed string) {
+	return fmt.Sprintf("+%d", line.Added), fmt.Sprintf("−%d", line.Removed)
+}
+
+// drawCounts draws a header's "+a −r" counts in the added/removed colors and
+// returns the column after them.
+func drawCounts(s *State, screen tcell.Screen, col, y int, line DisplayLine, maxCol int) int {
+	added, removed := countsText(line)
+	col = drawText(screen, col, y, added, s.Theme.DiffAdded, maxCol)
+	col = drawText(screen, col, y, " ", s.Theme.Dim, maxCol)
+	return drawText(screen, col, y, removed, s.Theme.DiffRemoved, maxCol)
+}
+
 // drawGutter draws the label gutter and returns the column position after it.
 // maxLabelWidth is the number of characters reserved for the label text.
 func drawGutter(s *State, screen tcell.Screen, x, y int, line DisplayLine, maxLabelWidth int) int {
+	if s.Zen {
+		return x
+	}
 	col := x
 	labelLen := len([]rune(line.Label))
-	staged := line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) && s.Hunks[line.HunkIdx].Staged
-	if line.Label != "" {
+	staged := line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) &&
+		(s.Hunks[line.HunkIdx].Staged || s.Hunks[line.HunkIdx].Applied)
+	if line.Label != "" && maxLabelWidth > 0 {
 		labelStyle := s.Theme.Label
 		if staged {
 			labelStyle = s.Theme.DiffAdded.Bold(true)
@@ -123,7 +265,7 @@ func drawGutter(s *State, screen tcell.Screen, x, y int, line DisplayLine, maxLa
 			screen.SetContent(col, y, r, nil, labelStyle)
 			col++
 		}
-		// Show checkmark after label for staged hunks
+		// Show checkmark after label for staged (or applied) hunks
 		if staged && labelLen < maxLabelWidth {
 			screen.SetContent(col, y, '✓', nil, s.Theme.DiffAdded)
 			col++
@@ -141,26 +283,55 @@ func drawGutter(s *State, screen tcell.Screen, x, y int, line DisplayLine, maxLa
 			col++
 		}
 	}
-	// " │ " separator
-	screen.SetContent(col, y, ' ', nil, s.Theme.Dim)
+	// " │ " separator, with the row's gutterMark in front. With a coverage
+	// report the bar of an added line is green when tests ran it, red when
+	// they didn't.
+	mark, style := s.gutterMark(line)
+	screen.SetContent(col, y, mark, nil, style)
 	col++
-	screen.SetContent(col, y, '│', nil, s.Theme.Dim)
+	if covered, known := s.lineCoverage(line); !known {
+		screen.SetContent(col, y, '│', nil, s.Theme.Dim)
+	} else if covered {
+		screen.SetContent(col, y, '┃', nil, s.Theme.Dim.Foreground(s.Theme.Added))
+	} else {
+		screen.SetContent(col, y, '┃', nil, s.Theme.Dim.Foreground(s.Theme.Removed))
+	}
 	col++
 	screen.SetContent(col, y, ' ', nil, s.Theme.Dim)
 	col++
 	return col
 }
 
+// gutterMark returns the mark drawn before the gutter's bar: a pencil for
+// a line with a note, a bang for diagnostics, a dot for a TODO marker and
+// the staged mark of a split diff, or a space.
+func (s *State) gutterMark(line DisplayLine) (rune, tcell.Style) {
+	if _, ok := s.lineNote(line); ok {
+		return '✎', s.Theme.Label
+	}
+	if diags := s.lineDiagnostics(line); len(diags) > 0 {
+		return '!', diagnosticStyle(s, diags)
+	}
+	if s.isTodoLine(line) {
+		return '•', s.Theme.Label
+	}
+	if mark, ok := s.indexMark(line); ok {
+		return mark, s.Theme.DiffAdded
+	}
+	return ' ', s.Theme.Dim
+}
+
 // drawLineNo draws a line number (or blank) and returns the column position
 func drawLineNo(s *State, screen tcell.Screen, col, y, num int) int {
+	width := s.lineNoWidth()
 	if num > 0 {
-		str := fmt.Sprintf("%4d ", num)
+		str := fmt.Sprintf("%*d ", width-1, num)
 		for _, r := range str {
 			screen.SetContent(col, y, r, nil, s.Theme.LineNo)
 			col++
 		}
 	} else {
-		for i := 0; i < lineNoWidth; i++ {
+		for i := 0; i < width; i++ {
 			screen.SetContent(col, y, ' ', nil, s.Theme.Default)
 			col++
 		}
@@ -185,12 +356,12 @@ func searchHighlightStyle(s *State, baseStyle tcell.Style, isCurrent bool) tcell
 	return baseStyle.Reverse(true)
 }
 
-// isCurrentMatchLine returns true if lineIdx is the line of the current search match.
+// isCurrentMatchLine returns true if lineIdx is a row of the current search match.
 func isCurrentMatchLine(s *State, lineIdx int) bool {
 	if s.SearchIdx < 0 || s.SearchIdx >= len(s.SearchMatches) {
 		return false
 	}
-	return s.SearchMatches[s.SearchIdx] == lineIdx
+	return matchAt(s, lineIdx) == s.SearchIdx
 }
 
 // drawTextWithHighlight draws text, highlighting search query matches.
@@ -200,31 +371,18 @@ func drawTextWithHighlight(s *State, screen tcell.Screen, col, y int, text strin
 		return drawText(screen, col, y, text, baseStyle, maxCol)
 	}
 
-	isCurrent := isCurrentMatchLine(s, lineIdx)
-	hlStyle := searchHighlightStyle(s, baseStyle, isCurrent)
-
-	runes := []rune(text)
-	lowerRunes := []rune(strings.ToLower(text))
-	queryRunes := []rune(strings.ToLower(s.SearchQuery))
-	qRuneLen := len(queryRunes)
-
-	i := 0
-	for i < len(runes) {
+	hlStyle := searchHighlightStyle(s, baseStyle, isCurrentMatchLine(s, lineIdx))
+	mask := buildSearchMask(s, text)
+	for i, r := range []rune(text) {
 		if col >= maxCol {
 			break
 		}
-		// Check if a match starts at position i
-		if i+qRuneLen <= len(lowerRunes) && string(lowerRunes[i:i+qRuneLen]) == string(queryRunes) {
-			for j := 0; j < qRuneLen && col < maxCol; j++ {
-				screen.SetContent(col, y, runes[i+j], nil, hlStyle)
-				col++
-			}
-			i += qRuneLen
-		} else {
-			screen.SetContent(col, y, runes[i], nil, baseStyle)
-			col++
-			i++
+		style := baseStyle
+		if mask[i] {
+			style = hlStyle
 		}
+		screen.SetContent(col, y, r, nil, style)
+		col++
 	}
 	return col
 }
@@ -253,13 +411,16 @@ func drawSyntaxText(s *State, screen tcell.Screen, col, y int, text string, diff
 	filename := s.Hunks[line.HunkIdx].File
 	content := text
 
-	// For non-continuation lines, first char is the op prefix (+/-/space)
+	// For non-continuation lines, the first chars are the op prefix
+	// (+/-/space, one per parent in a combined diff)
 	opVisible := !line.Continuation && (s.Wrap || s.ScrollX == 0)
-	if opVisible && len(text) > 0 {
-		runes := []rune(text)
-		screen.SetContent(col, y, runes[0], nil, diffStyle)
-		col++
-		content = string(runes[1:])
+	if runes := []rune(text); opVisible && len(runes) > 0 {
+		n := min(opWidth(s, line), len(runes))
+		for _, r := range runes[:n] {
+			screen.SetContent(col, y, r, nil, diffStyle)
+			col++
+		}
+		content = string(runes[n:])
 	}
 
 	// Build search highlight mask over the full text (rune positions)
@@ -278,7 +439,7 @@ func drawSyntaxText(s *State, screen tcell.Screen, col, y int, text string, diff
 			style = style.Dim(true)
 		}
 		if s.DiffBg {
-			style = applyDiffBg(s, style, line.Style)
+			style = applyDiffBg(s, style, line.Style, line.Moved)
 		}
 		for _, r := range span.Text {
 			if col >= maxCol {
@@ -297,26 +458,13 @@ func drawSyntaxText(s *State, screen tcell.Screen, col, y int, text string, diff
 }
 
 // buildSearchMask returns a boolean slice where true indicates the rune at
-// that position in text is part of a case-insensitive search match.
+// that position in text is part of a search match, with the search's case
+// and whole-word options.
 func buildSearchMask(s *State, text string) []bool {
 	if s.SearchQuery == "" || len(s.SearchMatches) == 0 {
 		return nil
 	}
-	runes := []rune(strings.ToLower(text))
-	queryRunes := []rune(strings.ToLower(s.SearchQuery))
-	qLen := len(queryRunes)
-	if qLen == 0 {
-		return nil
-	}
-	mask := make([]bool, len(runes))
-	for i := 0; i <= len(runes)-qLen; i++ {
-		if string(runes[i:i+qLen]) == string(queryRunes) {
-			for j := 0; j < qLen; j++ {
-				mask[i+j] = true
-			}
-		}
-	}
-	return mask
+	return s.searchMatcher().mask(text)
 }
 
 func drawInlineLine(s *State, y int, line DisplayLine, lineIdx int) {
@@ -325,7 +473,7 @@ func drawInlineLine(s *State, y int, line DisplayLine, lineIdx int) {
 
 	// File header: decorative line
 	if line.Style == StyleFileHeader {
-		drawFileHeader(s, screen, s.DiffX, y, line.Text, rightEdge)
+		drawFileHeader(s, screen, s.DiffX, y, line, rightEdge)
 		return
 	}
 
@@ -335,39 +483,66 @@ func drawInlineLine(s *State, y int, line DisplayLine, lineIdx int) {
 		return
 	}
 
+	// Collapsed file placeholder
+	if line.Style == StyleCollapsed {
+		col := drawGutter(s, screen, s.DiffX, y, line, s.gutterLabelWidth())
+		col = drawText(screen, col, y, line.Text, s.Theme.Dim.Italic(true), rightEdge)
+		clearToEnd(s, screen, col, y, rightEdge)
+		return
+	}
+
 	// Hunk header and diff content get the gutter
-	col := drawGutter(s, screen, s.DiffX, y, line, s.maxLabelWidth())
+	col := drawGutter(s, screen, s.DiffX, y, line, s.gutterLabelWidth())
 
 	// Line numbers (if enabled, for diff content lines only, blank for continuations)
 	if s.LineNumbers && line.Style != StyleHunkHeader {
-		lineNo := line.NewLineNo
-		if line.Style == StyleRemoved {
-			lineNo = line.OldLineNo
+		if s.Config.BothLineNumbers {
+			col = drawLineNo(s, screen, col, y, line.OldLineNo)
+			col = drawLineNo(s, screen, col, y, line.NewLineNo)
+		} else {
+			lineNo := line.NewLineNo
+			if line.Style == StyleRemoved {
+				lineNo = line.OldLineNo
+			}
+			col = drawLineNo(s, screen, col, y, lineNo)
 		}
-		col = drawLineNo(s, screen, col, y, lineNo)
+	}
+
+	// Hunk header: "+a −r" before the function context
+	if line.Style == StyleHunkHeader {
+		col = drawHeaderLabel(s, screen, col, y, line, rightEdge)
+		col = drawCounts(s, screen, col, y, line, rightEdge)
+		col = drawText(screen, col, y, "  ", s.Theme.Default, rightEdge)
 	}
 
 	// Text content (apply horizontal scroll when not wrapping)
 	text := line.Text
-	if !s.Wrap && s.ScrollX > 0 && line.Style != StyleHunkHeader {
-		runes := []rune(text)
-		if s.ScrollX < len(runes) {
-			text = string(runes[s.ScrollX:])
-		} else {
-			text = ""
-		}
+	scrolled := !s.Wrap && s.ScrollX > 0 && line.Style != StyleHunkHeader
+	if scrolled {
+		text = scrollText(text, s.ScrollX)
 	}
-	style := getStyle(s, line.Style)
+	style := getStyle(s, line.Style, line.Moved)
 	if s.DiffBg {
-		style = applyDiffBg(s, style, line.Style)
+		style = applyDiffBg(s, style, line.Style, line.Moved)
 	}
-	if s.SyntaxHighlight && s.HL != nil && line.Style != StyleHunkHeader {
+	textCol := col
+	if s.SyntaxHighlight && s.HL != nil && line.Style != StyleHunkHeader && line.Style != StyleReplaced && s.highlightable(text) {
 		col = drawSyntaxText(s, screen, col, y, text, style, rightEdge, line, lineIdx)
 	} else {
 		col = drawTextWithHighlight(s, screen, col, y, text, style, rightEdge, lineIdx)
 	}
+	if scrolled {
+		drawChanged(s, textCol, y, line.Changed, s.ScrollX, rightEdge)
+		drawOps(s, textCol, y, line, s.ScrollX, rightEdge)
+	} else {
+		drawChanged(s, textCol, y, line.Changed, 0, rightEdge)
+		drawOps(s, textCol, y, line, 0, rightEdge)
+	}
+	if scrolled && line.Text != "" {
+		drawScrollMarker(s, textCol, y, line.Style, line.Moved)
+	}
 	if s.DiffBg {
-		bgStyle := applyDiffBg(s, s.Theme.Default, line.Style)
+		bgStyle := applyDiffBg(s, s.Theme.Default, line.Style, line.Moved)
 		for col < rightEdge {
 			screen.SetContent(col, y, ' ', nil, bgStyle)
 			col++
@@ -383,13 +558,17 @@ func drawSideBySideLine(s *State, y int, line DisplayLine, lineIdx int) {
 
 	// File header: decorative line
 	if line.Style == StyleFileHeader {
-		drawFileHeader(s, screen, s.DiffX, y, line.Text, rightEdge)
+		drawFileHeader(s, screen, s.DiffX, y, line, rightEdge)
 		return
 	}
 
-	// Normal lines: same as inline
-	if line.Style == StyleNormal {
+	// Normal lines and placeholders: same as inline, the divider running
+	// through blank rows
+	if line.Style == StyleNormal || line.Style == StyleCollapsed {
 		drawInlineLine(s, y, line, lineIdx)
+		if line.Style == StyleNormal {
+			screen.SetContent(s.dividerX(), y, '│', nil, s.Theme.Dim)
+		}
 		return
 	}
 
@@ -397,19 +576,22 @@ func drawSideBySideLine(s *State, y int, line DisplayLine, lineIdx int) {
 	if line.Style == StyleHunkHeader {
 		lnoExtra := 0
 		if s.LineNumbers {
-			lnoExtra = lineNoWidth
+			lnoExtra = s.lineNoWidth()
 		}
-		colWidth := (s.DiffWidth - s.LabelGutter - 1) / 2
+		colWidth, _ := s.sideBySideCols()
 		contentWidth := colWidth - lnoExtra
 
-		col := drawGutter(s, screen, s.DiffX, y, line, s.maxLabelWidth())
+		col := drawGutter(s, screen, s.DiffX, y, line, s.gutterLabelWidth())
 
 		// Left half
 		if s.LineNumbers {
 			col = drawLineNo(s, screen, col, y, 0)
 		}
-		col = drawText(screen, col, y, line.Text, s.Theme.HunkHeader, s.DiffX+s.LabelGutter+lnoExtra+contentWidth)
 		leftEnd := s.DiffX + s.LabelGutter + lnoExtra + contentWidth
+		col = drawHeaderLabel(s, screen, col, y, line, leftEnd)
+		col = drawCounts(s, screen, col, y, line, leftEnd)
+		col = drawText(screen, col, y, "  ", s.Theme.Default, leftEnd)
+		col = drawText(screen, col, y, line.Text, s.Theme.HunkHeader, leftEnd)
 		for col < leftEnd {
 			screen.SetContent(col, y, ' ', nil, s.Theme.Default)
 			col++
@@ -431,39 +613,39 @@ func drawSideBySideLine(s *State, y int, line DisplayLine, lineIdx int) {
 	// Diff content: split into two columns
 	lnoExtra := 0
 	if s.LineNumbers {
-		lnoExtra = lineNoWidth
+		lnoExtra = s.lineNoWidth()
 	}
-	colWidth := (s.DiffWidth - s.LabelGutter - 1) / 2 // 1 for center divider
-	contentWidth := colWidth - lnoExtra
+	leftWidth, rightWidth := s.sideBySideCols() // and 1 for the center divider
+	contentWidth := leftWidth - lnoExtra
 
-	col := drawGutter(s, screen, s.DiffX, y, line, s.maxLabelWidth())
+	col := drawGutter(s, screen, s.DiffX, y, line, s.gutterLabelWidth())
 
-	// Apply horizontal scroll to text
-	leftText := line.Left.Text
-	rightText := line.Right.Text
-	if s.ScrollX > 0 {
-		if lr := []rune(leftText); s.ScrollX < len(lr) {
-			leftText = string(lr[s.ScrollX:])
-		} else {
-			leftText = ""
-		}
-		if rr := []rune(rightText); s.ScrollX < len(rr) {
-			rightText = string(rr[s.ScrollX:])
-		} else {
-			rightText = ""
-		}
+	// Apply horizontal scroll to text (each column has its own offset when
+	// scrolling is unlinked)
+	leftText, rightText := line.Left.Text, line.Right.Text
+	dropped := 0 // runes of the half texts not drawn: op column and scroll
+	if s.Config.HideSplitOps && !line.Continuation {
+		leftText, rightText = dropOp(leftText), dropOp(rightText)
+		dropped = 1
 	}
+	leftText = scrollText(leftText, s.ScrollX)
+	rightText = scrollText(rightText, s.RightScrollX())
 
 	// Left half: line number + content
 	if s.LineNumbers {
 		col = drawLineNo(s, screen, col, y, line.Left.LineNo)
 	}
-	leftStyle := getStyle(s, line.Left.Style)
+	leftStyle := getStyle(s, line.Left.Style, line.Left.Moved)
+	leftCol := col
 	col = drawHalfContent(s, screen, col, y, leftText, leftStyle, contentWidth, line, true, lineIdx)
+	drawChanged(s, leftCol, y, line.Left.Changed, dropped+s.ScrollX, leftCol+contentWidth)
+	if s.ScrollX > 0 && line.Left.Text != "" {
+		drawScrollMarker(s, leftCol, y, line.Left.Style, line.Left.Moved)
+	}
 	leftEnd := s.DiffX + s.LabelGutter + lnoExtra + contentWidth
 	leftBgStyle := s.Theme.Default
 	if s.DiffBg {
-		leftBgStyle = applyDiffBg(s, leftBgStyle, line.Left.Style)
+		leftBgStyle = applyDiffBg(s, leftBgStyle, line.Left.Style, line.Left.Moved)
 	}
 	for col < leftEnd {
 		screen.SetContent(col, y, ' ', nil, leftBgStyle)
@@ -478,11 +660,16 @@ func drawSideBySideLine(s *State, y int, line DisplayLine, lineIdx int) {
 	if s.LineNumbers {
 		col = drawLineNo(s, screen, col, y, line.Right.LineNo)
 	}
-	rightStyle := getStyle(s, line.Right.Style)
-	col = drawHalfContent(s, screen, col, y, rightText, rightStyle, contentWidth, line, false, lineIdx)
+	rightStyle := getStyle(s, line.Right.Style, line.Right.Moved)
+	rightCol := col
+	col = drawHalfContent(s, screen, col, y, rightText, rightStyle, rightWidth-lnoExtra, line, false, lineIdx)
+	drawChanged(s, rightCol, y, line.Right.Changed, dropped+s.RightScrollX(), rightCol+rightWidth-lnoExtra)
+	if s.RightScrollX() > 0 && line.Right.Text != "" {
+		drawScrollMarker(s, rightCol, y, line.Right.Style, line.Right.Moved)
+	}
 	rightBgStyle := s.Theme.Default
 	if s.DiffBg {
-		rightBgStyle = applyDiffBg(s, rightBgStyle, line.Right.Style)
+		rightBgStyle = applyDiffBg(s, rightBgStyle, line.Right.Style, line.Right.Moved)
 	}
 	for col < rightEdge {
 		screen.SetContent(col, y, ' ', nil, rightBgStyle)
@@ -490,6 +677,92 @@ func drawSideBySideLine(s *State, y int, line DisplayLine, lineIdx int) {
 	}
 }
 
+// drawChanged emphasizes the words a word diff marked as changed, spans
+// over the runes of a line's text drawn from col with its first skip runes
+// left out.
+func drawChanged(s *State, col, y int, spans []Span, skip, maxCol int) {
+	for _, sp := range spans {
+		for i := max(sp.Start, skip); i < sp.End; i++ {
+			x := col + i - skip
+			if x >= maxCol {
+				break
+			}
+			mainc, combc, style, _ := s.Screen.GetContent(x, y)
+			s.Screen.SetContent(x, y, mainc, combc, style.Bold(true).Underline(true))
+		}
+	}
+}
+
+// opWidth returns the number of op columns of line's hunk: one, or one
+// per parent of a combined diff.
+func opWidth(s *State, line DisplayLine) int {
+	if line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) {
+		return max(1, s.Hunks[line.HunkIdx].Parents)
+	}
+	return 1
+}
+
+// drawOps colors each op column of a combined diff line on its own, so
+// a line added against one parent reads differently from one added
+// against all of them. Spans and skip are as in drawChanged.
+func drawOps(s *State, col, y int, line DisplayLine, skip, maxCol int) {
+	n := opWidth(s, line)
+	if n < 2 || line.Continuation || (line.Style != StyleAdded && line.Style != StyleRemoved && line.Style != StyleContext) {
+		return
+	}
+	for i, r := range []rune(line.Text) {
+		if i >= n {
+			break
+		}
+		x := col + i - skip
+		if i < skip || x >= maxCol {
+			continue
+		}
+		ls := StyleContext
+		switch r {
+		case '+':
+			ls = StyleAdded
+		case '-':
+			ls = StyleRemoved
+		}
+		style := getStyle(s, ls, false)
+		if s.DiffBg {
+			style = applyDiffBg(s, style, line.Style, line.Moved)
+		}
+		s.Screen.SetContent(x, y, r, nil, style)
+	}
+}
+
+// dropOp removes the +/-/space prefix from a half-line's text.
+func dropOp(text string) string {
+	if _, size := utf8.DecodeRuneInString(text); size > 0 {
+		return text[size:]
+	}
+	return text
+}
+
+// scrollText drops the first offset runes of text for horizontal scrolling.
+func scrollText(text string, offset int) string {
+	if offset <= 0 {
+		return text
+	}
+	runes := []rune(text)
+	if offset >= len(runes) {
+		return ""
+	}
+	return string(runes[offset:])
+}
+
+// drawScrollMarker draws « over the first text column of a horizontally
+// scrolled line to show that content is hidden to its left.
+func drawScrollMarker(s *State, col, y int, ls LineStyle, moved bool) {
+	style := s.Theme.Dim
+	if s.DiffBg {
+		style = applyDiffBg(s, style, ls, moved)
+	}
+	s.Screen.SetContent(col, y, '«', nil, style)
+}
+
 // drawHalfContent draws one half of a side-by-side line, with optional syntax highlighting.
 func drawHalfContent(s *State, screen tcell.Screen, col, y int, text string, diffStyle tcell.Style, maxChars int, line DisplayLine, isLeft bool, lineIdx int) int {
 	// Build search highlight mask for the half text.
@@ -500,24 +773,24 @@ func drawHalfContent(s *State, screen tcell.Screen, col, y int, text string, dif
 	// text we're drawing.
 	hlMask := buildSearchMask(s, text)
 	isCurrent := isCurrentMatchLine(s, lineIdx)
+	half := line.Left
+	if !isLeft {
+		half = line.Right
+	}
 
-	if s.SyntaxHighlight && s.HL != nil && line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) && text != "" {
+	if s.SyntaxHighlight && s.HL != nil && line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) && half.Style != StyleReplaced && text != "" && s.highlightable(text) {
 		filename := s.Hunks[line.HunkIdx].File
-		dimmed := !s.DiffBg && ((isLeft && line.Left.Style == StyleRemoved) || (!isLeft && line.Right.Style == StyleRemoved))
+		dimmed := !s.DiffBg && half.Style == StyleRemoved
 
 		runes := []rune(text)
 		chars := 0
 		content := text
 
 		// First char is op prefix (+/-/space) for non-continuation lines
-		if !line.Continuation && len(runes) > 0 {
+		if !line.Continuation && !s.Config.HideSplitOps && len(runes) > 0 {
 			opStyle := diffStyle
 			if s.DiffBg {
-				halfStyle := line.Left.Style
-				if !isLeft {
-					halfStyle = line.Right.Style
-				}
-				opStyle = applyDiffBg(s, opStyle, halfStyle)
+				opStyle = applyDiffBg(s, opStyle, half.Style, half.Moved)
 			}
 			screen.SetContent(col, y, runes[0], nil, opStyle)
 			col++
@@ -534,11 +807,7 @@ func drawHalfContent(s *State, screen tcell.Screen, col, y int, text string, dif
 				style = style.Dim(true)
 			}
 			if s.DiffBg {
-				halfStyle := line.Left.Style
-				if !isLeft {
-					halfStyle = line.Right.Style
-				}
-				style = applyDiffBg(s, style, halfStyle)
+				style = applyDiffBg(s, style, half.Style, half.Moved)
 			}
 			for _, r := range span.Text {
 				if chars >= maxChars {
@@ -560,11 +829,7 @@ func drawHalfContent(s *State, screen tcell.Screen, col, y int, text string, dif
 	// Non-syntax path: draw with search highlights
 	baseStyle := diffStyle
 	if s.DiffBg {
-		halfStyle := line.Left.Style
-		if !isLeft {
-			halfStyle = line.Right.Style
-		}
-		baseStyle = applyDiffBg(s, baseStyle, halfStyle)
+		baseStyle = applyDiffBg(s, baseStyle, half.Style, half.Moved)
 	}
 	runes := []rune(text)
 	chars := 0
@@ -583,7 +848,15 @@ func drawHalfContent(s *State, screen tcell.Screen, col, y int, text string, dif
 	return col
 }
 
-func getStyle(s *State, ls LineStyle) tcell.Style {
+func getStyle(s *State, ls LineStyle, moved bool) tcell.Style {
+	if moved && s.ColorMoved {
+		switch ls {
+		case StyleAdded:
+			return s.Theme.MovedAdded
+		case StyleRemoved:
+			return s.Theme.MovedRemoved
+		}
+	}
 	switch ls {
 	case StyleFileHeader:
 		return s.Theme.FileHeader
@@ -593,232 +866,9 @@ func getStyle(s *State, ls LineStyle) tcell.Style {
 		return s.Theme.DiffAdded
 	case StyleRemoved:
 		return s.Theme.DiffRemoved
+	case StyleReplaced:
+		return s.Theme.Label
 	default:
 		return s.Theme.Default
 	}
 }
-
-func drawStatusBar(s *State) {
-	if s.FlashMsg != "" && time.Now().Before(s.FlashExpiry) {
-		y := s.Height - 1
-		msg := " " + s.FlashMsg + " "
-		col := 0
-		for _, r := range msg {
-			if col >= s.Width {
-				break
-			}
-			s.Screen.SetContent(col, y, r, nil, s.Theme.Flash)
-			col++
-		}
-		for col < s.Width {
-			s.Screen.SetContent(col, y, ' ', nil, s.Theme.Flash)
-			col++
-		}
-		return
-	}
-	s.FlashMsg = ""
-
-	var status string
-	if s.PipeMode {
-		status = fmt.Sprintf(" wiff (pipe) • %d hunks", len(s.Hunks))
-	} else {
-		status = fmt.Sprintf(" wiff %s • %d files • %d hunks",
-			s.RefDisplay(), s.UniqueFiles(), len(s.Hunks))
-	}
-
-	if len(s.Hunks) > 0 {
-		added, removed := s.DiffStats()
-		status += fmt.Sprintf(" \u2022 +%d -%d", added, removed)
-	}
-
-	if s.FilterFile != "" && !s.FullFile {
-		status += fmt.Sprintf(" • viewing: %s", s.FilterFile)
-	}
-
-	if s.TreeFocused {
-		status += " [TREE]"
-	}
-
-	if !s.PipeMode && !s.WatchEnabled {
-		status += " [watch off]"
-	}
-
-	if s.FollowMode {
-		status += " [FOLLOW]"
-	}
-
-	if len(s.SearchMatches) > 0 && s.SearchQuery != "" {
-		if s.SearchIdx >= 0 && s.SearchIdx < len(s.SearchMatches) {
-			status += fmt.Sprintf(" \u2022 \"%s\" [%d/%d]", s.SearchQuery, s.SearchIdx+1, len(s.SearchMatches))
-		} else {
-			status += fmt.Sprintf(" \u2022 \"%s\" [%d matches]", s.SearchQuery, len(s.SearchMatches))
-		}
-	}
-
-	if pd := s.PendingDisplay(); pd != "" {
-		status += fmt.Sprintf(" [%s…]", pd)
-	}
-
-	// Right-aligned help
-	help := "(s)plit (n)ums (w)rap (e)xpl (h)l (/)search (+/-)ctx (q)uit"
-	if s.TreeFocused {
-		help = "j/k:nav enter:select a:all tab:diff esc:back q:quit"
-	}
-	pad := s.Width - len(status) - len(help) - 1
-	if pad > 0 {
-		for i := 0; i < pad; i++ {
-			status += " "
-		}
-		status += help
-	}
-
-	y := s.Height - 1
-	col := 0
-	for _, r := range status {
-		if col >= s.Width {
-			break
-		}
-		s.Screen.SetContent(col, y, r, nil, s.Theme.StatusBar)
-		col++
-	}
-	for col < s.Width {
-		s.Screen.SetContent(col, y, ' ', nil, s.Theme.StatusBar)
-		col++
-	}
-}
-
-func drawHelpOverlay(s *State) {
-	const boxW = 60
-	const boxH = 29
-
-	screen := s.Screen
-	styleBorder := s.Theme.Dim
-	styleTitle := s.Theme.Default.Bold(true)
-	styleBody := s.Theme.Default
-
-	// Center the box
-	x0 := (s.Width - boxW) / 2
-	y0 := (s.Height - boxH) / 2
-	if x0 < 0 {
-		x0 = 0
-	}
-	if y0 < 0 {
-		y0 = 0
-	}
-
-	// Fill interior with spaces
-	for row := y0; row < y0+boxH && row < s.Height; row++ {
-		for col := x0; col < x0+boxW && col < s.Width; col++ {
-			screen.SetContent(col, row, ' ', nil, styleBody)
-		}
-	}
-
-	// Draw border
-	// Top edge
-	screen.SetContent(x0, y0, '┌', nil, styleBorder)
-	for col := x0 + 1; col < x0+boxW-1 && col < s.Width; col++ {
-		screen.SetContent(col, y0, '─', nil, styleBorder)
-	}
-	if x0+boxW-1 < s.Width {
-		screen.SetContent(x0+boxW-1, y0, '┐', nil, styleBorder)
-	}
-	// Bottom edge
-	if y0+boxH-1 < s.Height {
-		screen.SetContent(x0, y0+boxH-1, '└', nil, styleBorder)
-		for col := x0 + 1; col < x0+boxW-1 && col < s.Width; col++ {
-			screen.SetContent(col, y0+boxH-1, '─', nil, styleBorder)
-		}
-		if x0+boxW-1 < s.Width {
-			screen.SetContent(x0+boxW-1, y0+boxH-1, '┘', nil, styleBorder)
-		}
-	}
-	// Left and right edges
-	for row := y0 + 1; row < y0+boxH-1 && row < s.Height; row++ {
-		screen.SetContent(x0, row, '│', nil, styleBorder)
-		if x0+boxW-1 < s.Width {
-			screen.SetContent(x0+boxW-1, row, '│', nil, styleBorder)
-		}
-	}
-
-	// Content area starts at (x0+2, y0+1), max width boxW-4
-	cx := x0 + 2
-	contentW := boxW - 4
-
-	// Helper to draw a line of text at a given row within the box
-	drawLine := func(row int, text string, style tcell.Style) {
-		y := y0 + row
-		if y >= s.Height || y < 0 {
-			return
-		}
-		col := cx
-		for _, r := range text {
-			if col >= cx+contentW || col >= s.Width {
-				break
-			}
-			screen.SetContent(col, y, r, nil, style)
-			col++
-		}
-	}
-
-	// Title (centered)
-	title := "wiff - keyboard shortcuts"
-	titleX := x0 + (boxW-len(title))/2
-	if titleRow := y0 + 1; titleRow < s.Height {
-		col := titleX
-		for _, r := range title {
-			if col >= x0+boxW-1 || col >= s.Width {
-				break
-			}
-			screen.SetContent(col, titleRow, r, nil, styleTitle)
-			col++
-		}
-	}
-
-	// Help content lines (row offset from y0)
-	// Box is 24 rows: row 0 border, row 1 title, rows 3-20 content,
-	// row 21 blank, row 22 hint, row 23 border. Max 18 content lines.
-	lines := []string{
-		"Navigation                    Modes & Display",
-		"j/k     scroll up/down        s   side-by-side",
-		"d/u     half page down/up     n   line numbers",
-		"g/G     top/bottom            w   wrap",
-		"^D/^U   half page             e   file explorer",
-		"Tab     next file             h   syntax highlight",
-		"S-Tab   prev file             b   diff background",
-		"                              f   full file view",
-		"Hunks & Files                 W   watch mode",
-		"]c/[c   next/prev hunk        F   follow mode",
-		"]f/[f   next/prev file",
-		"+/-     more/less context     Search",
-		"mouse   scroll + tree click   /   start search",
-		"dbl-clk copy chunk            n   next match",
-		"right-clk copy chunk          N   prev match",
-		"                              Esc clear search",
-		"Yank (copies to clipboard)    Staging",
-		"y+label yank added lines      A+label stage/unstage",
-		"Y+label yank removed lines",
-		"p+label yank as patch         File Tree",
-		"c+label copy result (new)     Tab focus tree",
-		"o       open in $EDITOR       Enter select file",
-		"?       help  q/Esc   quit    a   show all files",
-	}
-
-	startRow := 3
-	for i, line := range lines {
-		drawLine(startRow+i, line, styleBody)
-	}
-
-	// Dismiss hint at the bottom
-	hint := "press any key to close"
-	hintX := x0 + (boxW-len(hint))/2
-	if hintRow := y0 + boxH - 2; hintRow < s.Height {
-		col := hintX
-		for _, r := range hint {
-			if col >= x0+boxW-1 || col >= s.Width {
-				break
-			}
-			screen.SetContent(col, hintRow, r, nil, s.Theme.Dim)
-			col++
-		}
-	}
-}
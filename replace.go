@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// replaceOp is the op character of preview rows, drawn where +/- would be.
+const replaceOp = "»"
+
+// Replace is a search-and-replace over the added lines of the diff. While
+// set, each matching added line is followed by a preview row showing the
+// line after replacement.
+type Replace struct {
+	Pattern *regexp.Regexp
+	With    string // replacement; $1 and ${name} expand submatches
+}
+
+// parseReplace parses sed-style "pattern/replacement" input. A slash inside
+// the pattern is written as \/.
+func parseReplace(input string) (*Replace, error) {
+	var pattern strings.Builder
+	rest := input
+	for {
+		i := strings.IndexByte(rest, '/')
+		if i < 0 {
+			return nil, fmt.Errorf("expected pattern/replacement")
+		}
+		if i > 0 && rest[i-1] == '\\' {
+			pattern.WriteString(rest[:i-1] + "/")
+			rest = rest[i+1:]
+			continue
+		}
+		pattern.WriteString(rest[:i])
+		rest = rest[i+1:]
+		break
+	}
+	if pattern.Len() == 0 {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Replace{Pattern: re, With: rest}, nil
+}
+
+// apply returns text with every match replaced, and whether anything matched.
+func (r *Replace) apply(text string) (string, bool) {
+	if !r.Pattern.MatchString(text) {
+		return text, false
+	}
+	return r.Pattern.ReplaceAllString(text, r.With), true
+}
+
+// replaceEdit is one replacement in a new-side file: line LineNo (1-based)
+// changes from Old to New.
+type replaceEdit struct {
+	LineNo   int
+	Old, New string
+}
+
+// replaceEdits collects the replacements for every added line, by file.
+func (s *State) replaceEdits() (map[string][]replaceEdit, int) {
+	edits := make(map[string][]replaceEdit)
+	count := 0
+	if s.Replace == nil {
+		return edits, 0
+	}
+	for _, h := range s.Hunks {
+		newNo := h.NewStart
+		for _, dl := range h.Lines {
+			if dl.Op == '-' {
+				continue
+			}
+			if dl.Op == '+' {
+				if text, ok := s.Replace.apply(dl.Content); ok {
+					edits[h.File] = append(edits[h.File], replaceEdit{LineNo: newNo, Old: dl.Content, New: text})
+					count++
+				}
+			}
+			newNo++
+		}
+	}
+	return edits, count
+}
+
+// addReplacePreview inserts a preview row after each added line the
+// replace pattern matches.
+func (s *State) addReplacePreview() {
+	if s.Replace == nil {
+		return
+	}
+	lines := make([]DisplayLine, 0, len(s.Lines))
+	for _, line := range s.Lines {
+		lines = append(lines, line)
+		switch {
+		case line.Style == StyleAdded && !s.SideBySide:
+			if text, ok := s.Replace.apply(strings.TrimPrefix(line.Text, "+")); ok {
+				lines = append(lines, DisplayLine{
+					Text:      replaceOp + text,
+					Style:     StyleReplaced,
+					HunkIdx:   line.HunkIdx,
+					NewLineNo: line.NewLineNo,
+				})
+			}
+		case line.Right.Style == StyleAdded && s.SideBySide:
+			if text, ok := s.Replace.apply(strings.TrimPrefix(line.Right.Text, "+")); ok {
+				lines = append(lines, DisplayLine{
+					Style:   StyleReplaced,
+					HunkIdx: line.HunkIdx,
+					Right:   HalfLine{Text: replaceOp + text, Style: StyleReplaced, LineNo: line.Right.LineNo},
+				})
+			}
+		}
+	}
+	s.Lines = lines
+	s.syncStartLines()
+}
+
+// canReplaceInWorktree reports whether the new side of the diff is the
+// working tree, so replacements can be written to the files.
+func canReplaceInWorktree(s *State) bool {
+	if s.PipeMode {
+		return false
+	}
+	if s.NoIndex {
+		return true
+	}
+	_, newRev := s.sideRevs()
+	return newRev == revWorktree
+}
+
+// ApplyReplace writes the previewed replacements to the working tree files.
+// Lines that no longer hold the diffed text are left alone.
+func ApplyReplace(s *State) {
+	if s.Replace == nil {
+		return
+	}
+	if !canReplaceInWorktree(s) {
+		s.FlashMsg = "Replace only applies when the new side is the working tree"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	edits, _ := s.replaceEdits()
+	changed, skipped := 0, 0
+	for file, fileEdits := range edits {
+		path, ok := resolveFile(s, file)
+		if !ok {
+			skipped += len(fileEdits)
+			continue
+		}
+		n, err := replaceInFile(path, fileEdits)
+		if err != nil {
+			s.FlashMsg = fmt.Sprintf("Replace failed for %s: %v", file, err)
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+			return
+		}
+		changed += n
+		skipped += len(fileEdits) - n
+	}
+	s.Replace = nil
+	reloadDiff(s)
+	s.FlashMsg = fmt.Sprintf("Replaced %d lines", changed)
+	if skipped > 0 {
+		s.FlashMsg += fmt.Sprintf(" (%d changed since the diff, skipped)", skipped)
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// replaceInFile applies edits to the file at path and returns how many
+// lines were replaced.
+func replaceInFile(path string, edits []replaceEdit) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(string(data), "\n")
+	n := 0
+	for _, e := range edits {
+		i := e.LineNo - 1
+		if i < 0 || i >= len(lines) || strings.TrimSuffix(lines[i], "\r") != strings.TrimSuffix(e.Old, "\r") {
+			continue
+		}
+		cr := strings.HasSuffix(lines[i], "\r") && !strings.HasSuffix(e.New, "\r")
+		lines[i] = e.New
+		if cr {
+			lines[i] += "\r"
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, os.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
+// StartReplace opens the replace prompt.
+func StartReplace(s *State) {
+	OpenPrompt(s, &InputPrompt{
+		Label:    "replace: ",
+		History:  "replace",
+		OnSubmit: previewReplace,
+		OnCancel: ClearReplace,
+	})
+}
+
+// ClearReplace drops the replace preview.
+func ClearReplace(s *State) {
+	if s.Replace != nil {
+		s.Replace = nil
+		s.BuildLines()
+		s.ClampScroll()
+	}
+}
+
+// previewReplace shows the replacements of the pattern/replacement input
+// on the added lines.
+func previewReplace(s *State, input string) {
+	r, err := parseReplace(input)
+	if err != nil {
+		s.FlashMsg = "Replace: " + err.Error()
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.Replace = r
+	s.BuildLines()
+	s.ClampScroll()
+	_, count := s.replaceEdits()
+	if count == 0 {
+		s.FlashMsg = "Replace: no added lines match"
+	} else if canReplaceInWorktree(s) {
+		s.FlashMsg = fmt.Sprintf("%d lines would change: ! applies, Esc cancels", count)
+	} else {
+		s.FlashMsg = fmt.Sprintf("%d lines would change (preview only)", count)
+	}
+	s.FlashExpiry = time.Now().Add(3 * time.Second)
+}
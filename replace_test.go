@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseReplace(t *testing.T) {
+	r, err := parseReplace(`a\/b(\d)/x$1`)
+	if err != nil {
+		t.Fatalf("parseReplace: %v", err)
+	}
+	if got, ok := r.apply("see a/b7 here"); !ok || got != "see x7 here" {
+		t.Errorf("apply = %q, %v", got, ok)
+	}
+	if _, ok := r.apply("nothing"); ok {
+		t.Error("expected no match")
+	}
+	for _, bad := range []string{"nodelim", "/empty", "(/x"} {
+		if _, err := parseReplace(bad); err == nil {
+			t.Errorf("parseReplace(%q): expected error", bad)
+		}
+	}
+}
+
+func TestReplacePreviewRows(t *testing.T) {
+	s := &State{
+		Hunks: []Hunk{{
+			File: "a.go", Label: "a", NewStart: 10, OldStart: 10,
+			Lines: []Line{
+				{Op: ' ', Content: "keep"},
+				{Op: '-', Content: "colour"},
+				{Op: '+', Content: "colour := 1"},
+				{Op: '+', Content: "other"},
+			},
+		}},
+		Width: 80, Height: 20,
+	}
+	s.Replace, _ = parseReplace("colour/color")
+	s.BuildLines()
+	var preview []DisplayLine
+	for _, l := range s.Lines {
+		if l.Style == StyleReplaced {
+			preview = append(preview, l)
+		}
+	}
+	if len(preview) != 1 {
+		t.Fatalf("expected 1 preview row, got %d", len(preview))
+	}
+	if preview[0].Text != replaceOp+"color := 1" || preview[0].NewLineNo != 11 {
+		t.Errorf("preview = %q at %d", preview[0].Text, preview[0].NewLineNo)
+	}
+	edits, count := s.replaceEdits()
+	if count != 1 || edits["a.go"][0].LineNo != 11 {
+		t.Errorf("edits = %v (%d)", edits, count)
+	}
+}
+
+func TestReplaceInFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := replaceInFile(path, []replaceEdit{
+		{LineNo: 2, Old: "two", New: "2"},
+		{LineNo: 3, Old: "stale", New: "3"},
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("replaceInFile = %d, %v", n, err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "one\n2\nthree\n" {
+		t.Errorf("file = %q", data)
+	}
+}
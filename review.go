@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Review mode shows the diff one file at a time, as pull request review
+// pages do: ]f or Enter marks the file done and moves on to the next one
+// not done yet. A done file that changes on reload is to be looked at
+// again.
+
+// fileDigest identifies the changes of file in the diff, so marking it
+// done lasts only as long as they stay the same.
+func (s *State) fileDigest(file string) string {
+	h := fnv.New64a()
+	for i := range s.Hunks {
+		if s.Hunks[i].File == file {
+			_, _ = h.Write([]byte(s.Hunks[i].AsPatch()))
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// isReviewed reports whether file was marked done in review mode.
+func (s *State) isReviewed(file string) bool {
+	_, ok := s.ReviewDone[file]
+	return ok
+}
+
+// refreshReview follows a reload: files marked done whose changes are gone
+// or changed are to be looked at again, and when the file shown has no
+// changes left the next one not done takes its place.
+func (s *State) refreshReview() {
+	for file, digest := range s.ReviewDone {
+		if !s.hasFile(file) || s.fileDigest(file) != digest {
+			delete(s.ReviewDone, file)
+		}
+	}
+	if s.Review && !s.hasFile(s.FilterFile) {
+		s.FilterFile = s.nextUnreviewed("")
+	}
+}
+
+// hasFile reports whether file has hunks in the diff.
+func (s *State) hasFile(file string) bool {
+	for i := range s.Hunks {
+		if s.Hunks[i].File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// reviewProgress returns how many files are done and how many there are.
+func (s *State) reviewProgress() (done, total int) {
+	for _, f := range s.orderedFiles() {
+		total++
+		if s.isReviewed(f) {
+			done++
+		}
+	}
+	return done, total
+}
+
+// ToggleReview enters review mode on the first file not done yet, or
+// leaves it, showing the whole diff again.
+func (s *State) ToggleReview() {
+	if s.Review {
+		s.Review = false
+		s.FilterFile = ""
+		s.FlashMsg = "Review mode off"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		s.BuildLines()
+		s.ClampScroll()
+		return
+	}
+	if len(s.Hunks) == 0 {
+		return
+	}
+	s.Review = true
+	if s.ReviewDone == nil {
+		s.ReviewDone = make(map[string]string)
+	}
+	file := s.nextUnreviewed("")
+	if file == "" {
+		file = s.orderedFiles()[0]
+	}
+	s.showReviewFile(file)
+}
+
+// nextUnreviewed returns the first file not done after file in diff order,
+// wrapping around, or "" when all are done.
+func (s *State) nextUnreviewed(file string) string {
+	files := s.orderedFiles()
+	start := 0
+	for i, f := range files {
+		if f == file {
+			start = i + 1
+		}
+	}
+	for i := range files {
+		if f := files[(start+i)%len(files)]; f != file && !s.isReviewed(f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// showReviewFile shows file alone, from its top.
+func (s *State) showReviewFile(file string) {
+	s.FilterFile = file
+	if s.FullFile {
+		s.FullFileName = file
+	}
+	s.BuildLines()
+	s.Scroll = 0
+	s.Cursor = 0
+	s.ClampScroll()
+	done, total := s.reviewProgress()
+	s.FlashMsg = fmt.Sprintf("Reviewing %s (%d/%d files done)", file, done, total)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// ReviewDoneNext marks the file shown done and moves to the next one not
+// done yet.
+func (s *State) ReviewDoneNext() {
+	file := s.FilterFile
+	if file == "" { // the tree showed all files meanwhile
+		if next := s.nextUnreviewed(""); next != "" {
+			s.showReviewFile(next)
+		}
+		return
+	}
+	s.ReviewDone[file] = s.fileDigest(file)
+	next := s.nextUnreviewed(file)
+	if next == "" {
+		_, total := s.reviewProgress()
+		s.FlashMsg = fmt.Sprintf("All %s reviewed", plural(total, "file"))
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	s.showReviewFile(next)
+}
+
+// ReviewPrev goes back to the file before the one shown, done or not.
+func (s *State) ReviewPrev() {
+	files := s.orderedFiles()
+	for i, f := range files {
+		if f == s.FilterFile && i > 0 {
+			s.showReviewFile(files[i-1])
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func reviewState() *State {
+	s := &State{Height: 40, Width: 80}
+	s.Hunks = []Hunk{
+		{File: "a.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "a"}}},
+		{File: "b.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "b"}}},
+		{File: "c.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "c"}}},
+	}
+	s.BuildLines()
+	return s
+}
+
+func TestReviewModeAdvances(t *testing.T) {
+	s := reviewState()
+	s.ToggleReview()
+	if !s.Review || s.FilterFile != "a.go" {
+		t.Fatalf("review = %v on %q, want a.go", s.Review, s.FilterFile)
+	}
+	s.ReviewDoneNext()
+	if s.FilterFile != "b.go" || !s.isReviewed("a.go") {
+		t.Fatalf("after ]f on a.go: showing %q, done %v", s.FilterFile, s.ReviewDone)
+	}
+	if got := statusSegments["files"].render(s); got != "1/3 files" {
+		t.Errorf("files segment = %q, want 1/3 files", got)
+	}
+	s.ReviewPrev()
+	if s.FilterFile != "a.go" {
+		t.Errorf("[f shows %q, want a.go", s.FilterFile)
+	}
+	// a.go, b.go and c.go in turn
+	s.ReviewDoneNext()
+	s.ReviewDoneNext()
+	s.ReviewDoneNext()
+	if done, total := s.reviewProgress(); done != 3 || total != 3 {
+		t.Errorf("progress = %d/%d, want 3/3", done, total)
+	}
+	if s.FlashMsg != "All 3 files reviewed" {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+
+	s.ToggleReview()
+	if s.Review || s.FilterFile != "" {
+		t.Errorf("leaving review mode kept the filter %q", s.FilterFile)
+	}
+	if !s.isReviewed("b.go") {
+		t.Error("done files should be kept when leaving review mode")
+	}
+}
+
+func TestReviewEnterMarksDone(t *testing.T) {
+	s := reviewState()
+	s.ToggleReview()
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if s.FilterFile != "b.go" || !s.isReviewed("a.go") {
+		t.Errorf("Enter: showing %q, done %v", s.FilterFile, s.ReviewDone)
+	}
+}
+
+func TestReviewChangedFileIsReviewedAgain(t *testing.T) {
+	s := reviewState()
+	s.ToggleReview()
+	s.ReviewDoneNext() // a.go
+	s.ReviewDoneNext() // b.go
+
+	s.Hunks[0].Lines = append(s.Hunks[0].Lines, Line{Op: '+', Content: "more"})
+	s.Hunks = s.Hunks[:2] // c.go's changes are gone
+	s.refreshReview()
+	if s.isReviewed("a.go") || !s.isReviewed("b.go") {
+		t.Errorf("done after reload = %v, want only b.go", s.ReviewDone)
+	}
+	if s.FilterFile != "a.go" {
+		t.Errorf("shown file after its changes went = %q, want a.go", s.FilterFile)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// scriptKeyNames are the special keys of --command and --script, written
+// in angle brackets (case doesn't matter): <Esc>, <Enter>, <C-w>...
+var scriptKeyNames = map[string]tcell.Key{
+	"esc":   tcell.KeyEscape,
+	"cr":    tcell.KeyEnter,
+	"enter": tcell.KeyEnter,
+	"tab":   tcell.KeyTab,
+	"s-tab": tcell.KeyBacktab,
+	"bs":    tcell.KeyBackspace2,
+	"up":    tcell.KeyUp,
+	"down":  tcell.KeyDown,
+	"left":  tcell.KeyLeft,
+	"right": tcell.KeyRight,
+}
+
+// scriptRuneNames are keys named in angle brackets that type a rune.
+var scriptRuneNames = map[string]rune{
+	"space": ' ',
+	"lt":    '<',
+}
+
+// parseKeys turns a key sequence into key events. Every character is a
+// key press, except <Name> for the keys in scriptKeyNames, <Space>, <lt>
+// and <C-x> for Ctrl-x. A '<' that doesn't start a name is typed as is.
+func parseKeys(keys string) ([]*tcell.EventKey, error) {
+	var evs []*tcell.EventKey
+	runes := []rune(keys)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '<' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '>' {
+				end++
+			}
+			if end < len(runes) && end > i+1 {
+				ev, err := namedKey(string(runes[i+1 : end]))
+				if err != nil {
+					return nil, err
+				}
+				if ev != nil {
+					evs = append(evs, ev)
+					i = end
+					continue
+				}
+			}
+		}
+		evs = append(evs, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	return evs, nil
+}
+
+// namedKey returns the key event for a <name>, nil when name doesn't look
+// like a key name at all, or an error for an unknown one.
+func namedKey(name string) (*tcell.EventKey, error) {
+	lower := strings.ToLower(name)
+	if k, ok := scriptKeyNames[lower]; ok {
+		return tcell.NewEventKey(k, 0, tcell.ModNone), nil
+	}
+	if r, ok := scriptRuneNames[lower]; ok {
+		return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone), nil
+	}
+	if c, ok := strings.CutPrefix(lower, "c-"); ok && len(c) == 1 && c[0] >= 'a' && c[0] <= 'z' {
+		return tcell.NewEventKey(tcell.KeyCtrlA+tcell.Key(c[0]-'a'), 0, tcell.ModCtrl), nil
+	}
+	for _, r := range lower {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key <%s>", name)
+}
+
+// readScript returns the keys of a --script file: its lines joined, with
+// blank lines and # comments dropped.
+func readScript(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var keys strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		keys.WriteString(line)
+	}
+	return keys.String(), nil
+}
+
+// runScript replays keys against the diff of opts on a screen nobody sees,
+// printing each message wiff flashes. A key that quits ends the script
+// early.
+func runScript(opts cliOpts, cfg Config, keys []*tcell.EventKey) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	screen.SetSize(120, 40)
+	if opts.theme == "" {
+		opts.theme = defaultDarkTheme
+	}
+	reserveKeys(cfg.commandKeys()...)
+	s := newState(opts, cfg, screen, colorTrue)
+	if err := loadDiff(s); err != nil {
+		screen.Fini()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitStatus(err))
+	}
+	if s.InputErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", s.InputErr)
+	}
+	replayScript(s, keys, func(msg string) { fmt.Println(msg) })
+	screen.Fini()
+	if s.InputErr != nil {
+		os.Exit(exitBadInput)
+	}
+}
+
+// replayScript feeds keys to s, passing each newly flashed message to
+// report. A label left pending at the end resolves as after a timeout.
+func replayScript(s *State, keys []*tcell.EventKey, report func(string)) {
+	var shown time.Time
+	flash := func() {
+		if s.FlashMsg != "" && !s.FlashExpiry.Equal(shown) {
+			shown = s.FlashExpiry
+			report(s.FlashMsg)
+		}
+	}
+	for _, ev := range keys {
+		quit := HandleKey(s, ev)
+		s = s.activeTab().focusedPane()
+		flash()
+		if quit {
+			return
+		}
+	}
+	cancelLabelTimer()
+	ResolvePendingLabel(s)
+	flash()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKeys(t *testing.T) {
+	evs, err := parseKeys("Aa<C-w>v<Esc><lt>x<>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 9 {
+		t.Fatalf("got %d keys, want 9", len(evs))
+	}
+	if evs[0].Rune() != 'A' || evs[1].Rune() != 'a' {
+		t.Errorf("literal keys = %q %q", evs[0].Rune(), evs[1].Rune())
+	}
+	if evs[2].Key() != tcell.KeyCtrlW || evs[4].Key() != tcell.KeyEscape {
+		t.Errorf("named keys = %v %v", evs[2].Key(), evs[4].Key())
+	}
+	if evs[5].Rune() != '<' || evs[7].Rune() != '<' || evs[8].Rune() != '>' {
+		t.Errorf("<lt> and a bare <> should type themselves")
+	}
+
+	if _, err := parseKeys("<Nope>"); err == nil {
+		t.Errorf("an unknown key name should be an error")
+	}
+}
+
+func TestReadScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# stage two hunks\nAa\n\nAc\r\nq\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := readScript(path)
+	if err != nil || keys != "AaAcq" {
+		t.Errorf("readScript = %q, %v", keys, err)
+	}
+}
+
+func TestReplayScript(t *testing.T) {
+	s := makeTestState(80, false, false, []Line{{Op: '+', Content: "a"}})
+	s.Height = 24
+	s.BuildLines()
+	keys, _ := parseKeys("gx<C-w>vjq<C-w>q")
+
+	var msgs []string
+	replayScript(s, keys, func(msg string) { msgs = append(msgs, msg) })
+	if len(msgs) != 1 || msgs[0] != "Last tab (q quits)" {
+		t.Errorf("messages = %q", msgs)
+	}
+	if s.Split == nil {
+		t.Errorf("keys after q should not run")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+// Built-in scrolling defaults; see Config.PageOverlap and Config.WheelStep.
+const (
+	defaultPageOverlap = 2
+	defaultWheelStep   = 3
+)
+
+// pageOverlap returns how many lines of the last page stay on screen after
+// paging, like the two lines vim's ^F keeps.
+func (c *Config) pageOverlap() int {
+	switch {
+	case c.PageOverlap == 0:
+		return defaultPageOverlap
+	case c.PageOverlap < 0:
+		return 0
+	}
+	return c.PageOverlap
+}
+
+// wheelStep returns how many lines a mouse wheel notch scrolls.
+func (c *Config) wheelStep() int {
+	if c.WheelStep <= 0 {
+		return defaultWheelStep
+	}
+	return c.WheelStep
+}
+
+// scrollOff returns the lines kept between the cursor or a jump target and
+// the edges of the view, at most half of it, as vim does with scrolloff.
+func (s *State) scrollOff() int {
+	return max(min(s.Config.ScrollOff, (s.viewRows()-1)/2), 0)
+}
+
+// PageBy moves by pages pages (negative up), keeping the configured overlap
+// with the page before.
+func (s *State) PageBy(pages int) {
+	s.MoveBy(pages * max(s.viewRows()-s.Config.pageOverlap(), 1))
+}
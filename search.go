@@ -1,17 +1,44 @@
 package main
 
 import (
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 )
 
-// StartSearch enters search mode.
+// StartSearch enters search mode, opening the search prompt. Matches are
+// found as the query is typed.
 func StartSearch(s *State) {
 	s.SearchMode = true
 	s.SearchQuery = ""
 	s.SearchMatches = nil
 	s.SearchIdx = -1
+	OpenPrompt(s, &InputPrompt{
+		Label:   "/",
+		History: "search",
+		OnChange: func(s *State, query string) {
+			s.SearchQuery = query
+			UpdateMatches(s)
+		},
+		OnKey: handleSearchOptionKey,
+		Hint: func(s *State) string {
+			return s.searchOptions() + "  (Alt-c case, Alt-w word, ↑↓ ^R history) "
+		},
+		OnSubmit: func(s *State, _ string) {
+			UpdateMatches(s)
+			if len(s.SearchMatches) > 0 {
+				s.SearchIdx = 0
+				s.JumpTo(s.SearchMatches[0])
+			}
+			EndSearch(s)
+		},
+		OnCancel: ClearSearch,
+	})
 }
 
 // EndSearch exits search mode but keeps matches highlighted.
@@ -27,36 +54,187 @@ func ClearSearch(s *State) {
 	s.SearchIdx = -1
 }
 
-// HandleSearchKey handles key input during search mode.
-// Returns true if the main loop should quit (never, for search).
-func HandleSearchKey(s *State, ev *tcell.EventKey) bool {
-	switch ev.Key() {
-	case tcell.KeyEscape:
-		ClearSearch(s)
+// handleSearchOptionKey changes the search options from the search prompt:
+// Alt-c cycles the case sensitivity and Alt-w toggles whole words.
+func handleSearchOptionKey(s *State, ev *tcell.EventKey) bool {
+	if ev.Key() != tcell.KeyRune || ev.Modifiers()&tcell.ModAlt == 0 {
 		return false
-	case tcell.KeyEnter:
-		UpdateMatches(s)
-		if len(s.SearchMatches) > 0 {
-			s.SearchIdx = 0
-			s.ScrollTo(s.SearchMatches[0])
-		}
-		EndSearch(s)
+	}
+	switch ev.Rune() {
+	case 'c':
+		s.SearchCase = (s.SearchCase + 1) % searchCases
+	case 'w':
+		s.SearchWord = !s.SearchWord
+	default:
 		return false
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if len(s.SearchQuery) > 0 {
-			s.SearchQuery = s.SearchQuery[:len(s.SearchQuery)-1]
-			UpdateMatches(s)
+	}
+	UpdateMatches(s)
+	return true
+}
+
+// searchOptions describes the case and whole-word options of search for
+// the search bar.
+func (s *State) searchOptions() string {
+	opts := []string{"smart-case", "ignore-case", "match-case"}[s.SearchCase]
+	if s.SearchCase == searchSmartCase && !s.searchMatcher().fold {
+		opts += " (match)"
+	}
+	if s.SearchWord {
+		opts += ", word"
+	}
+	return opts
+}
+
+// Case sensitivity of search, set with search_case in the config and
+// cycled with Alt-c in the search bar: smart-case (the default) ignores
+// case unless the query has an uppercase letter.
+const (
+	searchSmartCase = iota
+	searchIgnoreCase
+	searchMatchCase
+	searchCases
+)
+
+var searchCaseNames = []string{"smart", "ignore", "match"}
+
+// parseSearchCase returns the case sensitivity named name.
+func parseSearchCase(name string) (int, error) {
+	if i := slices.Index(searchCaseNames, name); i >= 0 {
+		return i, nil
+	}
+	return 0, fmt.Errorf("want %s, got %q", strings.Join(searchCaseNames, ", "), name)
+}
+
+// searchMatcher is a search query with the options it is matched with.
+type searchMatcher struct {
+	query string // lowercased when fold
+	fold  bool   // ignore case
+	word  bool   // whole words only
+}
+
+// searchMatcher returns the matcher of the current query and options.
+func (s *State) searchMatcher() searchMatcher {
+	fold := s.SearchCase == searchIgnoreCase ||
+		(s.SearchCase == searchSmartCase && !strings.ContainsFunc(s.SearchQuery, unicode.IsUpper))
+	m := searchMatcher{query: s.SearchQuery, fold: fold, word: s.SearchWord}
+	if fold {
+		m.query = foldCase(m.query)
+	}
+	return m
+}
+
+// foldCase lowercases text rune by rune, so rune offsets stay those of
+// text (strings.ToLower may change the number of runes).
+func foldCase(text string) string {
+	return strings.Map(unicode.ToLower, text)
+}
+
+// isWordRune reports whether r is part of a word for whole-word search.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// contains reports whether text has a match.
+func (m searchMatcher) contains(text string) bool {
+	if m.fold {
+		text = foldCase(text)
+	}
+	if !m.word {
+		return strings.Contains(text, m.query)
+	}
+	return m.find([]rune(text), func(int) bool { return true })
+}
+
+// mask returns a slice marking the runes of text that are part of a match,
+// or nil without a query.
+func (m searchMatcher) mask(text string) []bool {
+	if m.query == "" {
+		return nil
+	}
+	if m.fold {
+		text = foldCase(text)
+	}
+	runes := []rune(text)
+	mask := make([]bool, len(runes))
+	qLen := utf8.RuneCountInString(m.query)
+	m.find(runes, func(i int) bool {
+		for j := range qLen {
+			mask[i+j] = true
 		}
 		return false
-	case tcell.KeyRune:
-		s.SearchQuery += string(ev.Rune())
-		UpdateMatches(s)
+	})
+	return mask
+}
+
+// find calls found with the rune offset of each match in runes, already
+// folded, until it returns true, and reports whether it did.
+func (m searchMatcher) find(runes []rune, found func(int) bool) bool {
+	query := []rune(m.query)
+	qLen := len(query)
+	if qLen == 0 {
 		return false
 	}
+	for i := 0; i+qLen <= len(runes); i++ {
+		if !slices.Equal(runes[i:i+qLen], query) {
+			continue
+		}
+		// A word edge of the query must not run on into a word in the text
+		if m.word && ((isWordRune(query[0]) && i > 0 && isWordRune(runes[i-1])) ||
+			(isWordRune(query[qLen-1]) && i+qLen < len(runes) && isWordRune(runes[i+qLen]))) {
+			continue
+		}
+		if found(i) {
+			return true
+		}
+		i += qLen - 1
+	}
 	return false
 }
 
-// UpdateMatches scans s.Lines for SearchQuery matches (case-insensitive).
+// searchMemo remembers which texts a matcher finds, so that rebuilding the
+// lines for a re-wrap or another view looks matches up instead of scanning
+// every line again.
+type searchMemo struct {
+	matcher searchMatcher
+	hits    map[string]bool
+}
+
+// retarget points the memo at m. When m only lengthens the old query with
+// the same options, a text that didn't match still can't, so only the old
+// hits are forgotten. That isn't so for whole words: "foo" is not a word
+// of "food", but "food" is.
+func (mm *searchMemo) retarget(m searchMatcher) {
+	old := mm.matcher
+	switch {
+	case m == old && mm.hits != nil:
+		return
+	case old.query != "" && old.fold == m.fold && !m.word && !old.word && strings.Contains(m.query, old.query):
+		for text, hit := range mm.hits {
+			if hit {
+				delete(mm.hits, text)
+			}
+		}
+	default:
+		mm.hits = make(map[string]bool)
+	}
+	mm.matcher = m
+}
+
+func (mm *searchMemo) contains(text string) bool {
+	if text == "" {
+		return false
+	}
+	hit, ok := mm.hits[text]
+	if !ok {
+		hit = mm.matcher.contains(text)
+		mm.hits[text] = hit
+	}
+	return hit
+}
+
+// UpdateMatches finds the SearchQuery matches in s.Lines, with the case
+// and whole-word options. Matching is by logical line: a line wrapped over several rows
+// matches as a whole, at its first row.
 func UpdateMatches(s *State) {
 	s.SearchMatches = nil
 	s.SearchIdx = -1
@@ -65,18 +243,44 @@ func UpdateMatches(s *State) {
 		return
 	}
 
-	query := strings.ToLower(s.SearchQuery)
-	for i, line := range s.Lines {
-		if strings.Contains(strings.ToLower(line.Text), query) {
-			s.SearchMatches = append(s.SearchMatches, i)
-		} else if line.Left.Text != "" && strings.Contains(strings.ToLower(line.Left.Text), query) {
-			s.SearchMatches = append(s.SearchMatches, i)
-		} else if line.Right.Text != "" && strings.Contains(strings.ToLower(line.Right.Text), query) {
+	s.searchMemo.retarget(s.searchMatcher())
+	for i := 0; i < len(s.Lines); {
+		end := logicalEnd(s, i)
+		if logicalMatch(s, i, end) {
 			s.SearchMatches = append(s.SearchMatches, i)
 		}
+		i = end
 	}
 }
 
+// logicalEnd returns the index after the last wrapped row of the line at i.
+func logicalEnd(s *State, i int) int {
+	end := i + 1
+	for end < len(s.Lines) && s.Lines[end].Continuation {
+		end++
+	}
+	return end
+}
+
+// logicalMatch reports whether the line made of rows [start, end) contains
+// the query, on either side in side-by-side mode.
+func logicalMatch(s *State, start, end int) bool {
+	rows := s.Lines[start:end]
+	text := func(part func(DisplayLine) string) string {
+		if len(rows) == 1 {
+			return part(rows[0])
+		}
+		var sb strings.Builder
+		for _, r := range rows {
+			sb.WriteString(part(r))
+		}
+		return sb.String()
+	}
+	return s.searchMemo.contains(text(func(l DisplayLine) string { return l.Text })) ||
+		s.searchMemo.contains(text(func(l DisplayLine) string { return l.Left.Text })) ||
+		s.searchMemo.contains(text(func(l DisplayLine) string { return l.Right.Text }))
+}
+
 // JumpToNextMatch scrolls to the next search match.
 func JumpToNextMatch(s *State) {
 	if len(s.SearchMatches) == 0 {
@@ -86,7 +290,7 @@ func JumpToNextMatch(s *State) {
 	if s.SearchIdx >= len(s.SearchMatches) {
 		s.SearchIdx = 0
 	}
-	s.ScrollTo(s.SearchMatches[s.SearchIdx])
+	s.JumpTo(s.SearchMatches[s.SearchIdx])
 }
 
 // JumpToPrevMatch scrolls to the previous search match.
@@ -98,22 +302,32 @@ func JumpToPrevMatch(s *State) {
 	if s.SearchIdx < 0 {
 		s.SearchIdx = len(s.SearchMatches) - 1
 	}
-	s.ScrollTo(s.SearchMatches[s.SearchIdx])
+	s.JumpTo(s.SearchMatches[s.SearchIdx])
 }
 
-// IsSearchMatch returns whether a given line index is in SearchMatches.
+// IsSearchMatch returns whether a given line index is part of a match.
 func IsSearchMatch(s *State, lineIdx int) bool {
-	for _, idx := range s.SearchMatches {
-		if idx == lineIdx {
-			return true
+	return matchAt(s, lineIdx) >= 0
+}
+
+// matchAt returns the index in SearchMatches of the match lineIdx belongs
+// to (its first row or a wrapped row after it), or -1.
+func matchAt(s *State, lineIdx int) int {
+	n := sort.SearchInts(s.SearchMatches, lineIdx+1) - 1
+	if n < 0 {
+		return -1
+	}
+	for i := s.SearchMatches[n] + 1; i <= lineIdx; i++ {
+		if i >= len(s.Lines) || !s.Lines[i].Continuation {
+			return -1
 		}
 	}
-	return false
+	return n
 }
 
-// drawSearchBar draws the search input bar at the bottom of the screen,
-// on the row just above the status bar.
-func drawSearchBar(s *State) {
+// drawSearchBar draws an input bar with the given prompt at the bottom of the
+// screen, on the row just above the status bar.
+func drawSearchBar(s *State, prompt, input string) {
 	y := s.Height - 2
 	if y < 0 {
 		y = 0
@@ -123,12 +337,8 @@ func drawSearchBar(s *State) {
 	col := 0
 	barStyle := s.Theme.FileHeader // white + bold
 
-	// Draw "/" prefix
-	screen.SetContent(col, y, '/', nil, barStyle)
-	col++
-
-	// Draw query text
-	for _, r := range s.SearchQuery {
+	// Draw prompt and input text
+	for _, r := range prompt + input {
 		if col >= s.Width-1 {
 			break
 		}
@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"slices"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
 
 func TestUpdateMatchesFindsCorrectLines(t *testing.T) {
 	s := &State{
@@ -35,6 +40,7 @@ func TestUpdateMatchesCaseInsensitive(t *testing.T) {
 			{Text: "hello world", Style: StyleContext},
 			{Text: "HELLO WORLD", Style: StyleContext},
 		},
+		SearchCase: searchIgnoreCase,
 	}
 
 	s.SearchQuery = "HELLO"
@@ -189,3 +195,163 @@ func TestEndSearchKeepsMatches(t *testing.T) {
 		t.Errorf("expected SearchMatches preserved after EndSearch, got %d", len(s.SearchMatches))
 	}
 }
+
+func TestUpdateMatchesWrappedLogicalLine(t *testing.T) {
+	s := &State{
+		Lines: []DisplayLine{
+			{Text: "+hello wo", Style: StyleAdded},
+			{Text: "rld", Style: StyleAdded, Continuation: true},
+			{Text: "+world", Style: StyleAdded},
+		},
+		SearchQuery: "world",
+	}
+	UpdateMatches(s)
+	if len(s.SearchMatches) != 2 || s.SearchMatches[0] != 0 || s.SearchMatches[1] != 2 {
+		t.Fatalf("matches = %v, want [0 2]: a match across the wrap counts once, at its first row", s.SearchMatches)
+	}
+	if !IsSearchMatch(s, 1) {
+		t.Error("the wrapped row should be part of the match")
+	}
+	s.SearchIdx = 0
+	if !isCurrentMatchLine(s, 1) || isCurrentMatchLine(s, 2) {
+		t.Error("the current match should cover its wrapped row only")
+	}
+}
+
+func TestSearchMemoNarrowsOnLongerQuery(t *testing.T) {
+	var m searchMemo
+	m.retarget(searchMatcher{query: "ab", fold: true})
+	if !m.contains("xaby") || m.contains("xy") {
+		t.Fatal("wrong matches for ab")
+	}
+	m.retarget(searchMatcher{query: "abc", fold: true})
+	if _, ok := m.hits["xy"]; !ok {
+		t.Error("a non-match should be kept when the query only grows")
+	}
+	if _, ok := m.hits["xaby"]; ok {
+		t.Error("an old hit should be checked again")
+	}
+	if m.contains("xaby") || !m.contains("abcd") {
+		t.Error("wrong matches for abc")
+	}
+	m.retarget(searchMatcher{query: "b", fold: true})
+	if len(m.hits) != 0 {
+		t.Error("a different query should start over")
+	}
+}
+
+func TestSearchCaseAndWholeWord(t *testing.T) {
+	s := &State{Lines: []DisplayLine{
+		{Text: "+Config config", Style: StyleAdded},
+		{Text: "+configs", Style: StyleAdded},
+		{Text: "+CONFIG_X", Style: StyleAdded},
+	}}
+	tests := []struct {
+		query string
+		cas   int
+		word  bool
+		want  []int
+	}{
+		{"config", searchSmartCase, false, []int{0, 1, 2}},
+		{"Config", searchSmartCase, false, []int{0}}, // an uppercase letter matches case
+		{"Config", searchIgnoreCase, false, []int{0, 1, 2}},
+		{"config", searchMatchCase, false, []int{0, 1}},
+		{"config", searchSmartCase, true, []int{0}}, // not configs or CONFIG_X
+		{"config_x", searchSmartCase, true, []int{2}},
+	}
+	for _, tt := range tests {
+		s.SearchQuery, s.SearchCase, s.SearchWord = tt.query, tt.cas, tt.word
+		UpdateMatches(s)
+		if !slices.Equal(s.SearchMatches, tt.want) {
+			t.Errorf("%q case %s word %v: matches = %v, want %v",
+				tt.query, searchCaseNames[tt.cas], tt.word, s.SearchMatches, tt.want)
+		}
+	}
+}
+
+func TestSearchMaskFollowsOptions(t *testing.T) {
+	s := &State{Lines: []DisplayLine{{Text: "x"}}, SearchMatches: []int{0}, SearchQuery: "ab", SearchWord: true}
+	mask := buildSearchMask(s, "ab abc AB")
+	want := []bool{true, true, false, false, false, false, false, true, true}
+	if !slices.Equal(mask, want) {
+		t.Errorf("whole-word mask = %v, want %v", mask, want)
+	}
+	s.SearchCase = searchMatchCase
+	if mask := buildSearchMask(s, "ab abc AB"); mask[7] {
+		t.Error("match-case should not mark AB")
+	}
+}
+
+func TestSearchBarTogglesOptions(t *testing.T) {
+	s := &State{Lines: []DisplayLine{{Text: "+Foo foo"}, {Text: "+food"}}}
+	StartSearch(s)
+	for _, r := range "foo" {
+		HandlePromptKey(s, makeKeyEvent(r))
+	}
+	if len(s.SearchMatches) != 2 {
+		t.Fatalf("matches = %v, want both lines", s.SearchMatches)
+	}
+	HandlePromptKey(s, tcell.NewEventKey(tcell.KeyRune, 'w', tcell.ModAlt))
+	if !s.SearchWord || !slices.Equal(s.SearchMatches, []int{0}) {
+		t.Errorf("Alt-w: word %v, matches %v", s.SearchWord, s.SearchMatches)
+	}
+	HandlePromptKey(s, tcell.NewEventKey(tcell.KeyRune, 'c', tcell.ModAlt))
+	if s.SearchCase != searchIgnoreCase || s.SearchQuery != "foo" {
+		t.Errorf("Alt-c: case %d, query %q", s.SearchCase, s.SearchQuery)
+	}
+	if got := s.searchOptions(); got != "ignore-case, word" {
+		t.Errorf("options = %q", got)
+	}
+}
+
+func TestSearchHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	s := &State{Lines: []DisplayLine{{Text: "+alpha beta gamma"}}}
+	search := func(query string) {
+		StartSearch(s)
+		for _, r := range query {
+			HandlePromptKey(s, makeKeyEvent(r))
+		}
+		HandlePromptKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	}
+	search("alpha")
+	search("beta")
+	search("alpha") // moves to the end instead of repeating
+	if got := loadHistory("search"); !slices.Equal(got, []string{"beta", "alpha"}) {
+		t.Fatalf("history = %q", got)
+	}
+
+	key := func(k tcell.Key) { HandlePromptKey(s, tcell.NewEventKey(k, 0, tcell.ModNone)) }
+	StartSearch(s)
+	HandlePromptKey(s, makeKeyEvent('g'))
+	key(tcell.KeyUp)
+	key(tcell.KeyUp)
+	if s.SearchQuery != "beta" {
+		t.Errorf("Up twice: query = %q, want beta", s.SearchQuery)
+	}
+	key(tcell.KeyUp)
+	key(tcell.KeyDown)
+	key(tcell.KeyDown)
+	if s.SearchQuery != "g" {
+		t.Errorf("Down past the newest: query = %q, want the typed g", s.SearchQuery)
+	}
+
+	key(tcell.KeyCtrlR)
+	HandlePromptKey(s, makeKeyEvent('e'))
+	if s.SearchQuery != "beta" || s.Prompt.recall.failing {
+		t.Errorf("Ctrl-R e: query = %q, want beta", s.SearchQuery)
+	}
+	HandlePromptKey(s, makeKeyEvent('x'))
+	if !s.Prompt.recall.failing || s.SearchQuery != "beta" {
+		t.Errorf("Ctrl-R ex: failing %v, query %q", s.Prompt.recall.failing, s.SearchQuery)
+	}
+	key(tcell.KeyEscape)
+	if s.Prompt.recall != nil || s.SearchQuery != "g" || !s.SearchMode {
+		t.Errorf("Esc: recall %v, query %q, search mode %v", s.Prompt.recall, s.SearchQuery, s.SearchMode)
+	}
+	key(tcell.KeyCtrlR)
+	key(tcell.KeyEnter)
+	if s.SearchMode || s.SearchQuery != "alpha" || len(s.SearchMatches) != 1 {
+		t.Errorf("Ctrl-R Enter: mode %v, query %q, matches %v", s.SearchMode, s.SearchQuery, s.SearchMatches)
+	}
+}
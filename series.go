@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Patch is one mail of a git format-patch series.
+type Patch struct {
+	Subject string // without the [PATCH n/m] prefix
+	From    string
+	Date    string
+	Diff    []byte
+
+	hunks  []Hunk // parsed on first view, kept with their staged/applied marks
+	parsed bool
+}
+
+// Series is a patch series under review: the viewer shows the hunks of one
+// patch at a time.
+type Series struct {
+	Patches []Patch
+	Current int
+}
+
+// isMbox reports whether data is an mbox of mails, as git format-patch
+// --stdout writes, rather than a bare diff.
+func isMbox(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("From ")) && len(splitMbox(data)) > 0
+}
+
+var patchPrefixRe = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+
+// splitMbox returns the patches mailed in data. A "From " line starts a new
+// mail only when a header follows it, so commit messages quoting one don't
+// split a patch. Mails without a diff, such as a cover letter, are left out.
+func splitMbox(data []byte) []Patch {
+	lines := strings.SplitAfter(string(data), "\n")
+	var patches []Patch
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			if p, ok := parsePatchMail(lines[start:end]); ok {
+				patches = append(patches, p)
+			}
+		}
+	}
+	for i, line := range lines {
+		if strings.HasPrefix(line, "From ") && i+1 < len(lines) && isMailHeader(lines[i+1]) {
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(lines))
+	return patches
+}
+
+func isMailHeader(line string) bool {
+	name, _, ok := strings.Cut(line, ":")
+	return ok && name != "" && !strings.ContainsAny(name, " \t")
+}
+
+// parsePatchMail parses the lines of one mail, "From " line first.
+func parsePatchMail(lines []string) (Patch, bool) {
+	var p Patch
+	dec := new(mime.WordDecoder)
+	i := 1
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "" {
+			break
+		}
+		// Folded header lines continue the one before
+		for i+1 < len(lines) && len(lines[i+1]) > 1 && (lines[i+1][0] == ' ' || lines[i+1][0] == '\t') {
+			i++
+			line += " " + strings.TrimSpace(lines[i])
+		}
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		if decoded, err := dec.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		switch strings.ToLower(name) {
+		case "subject":
+			p.Subject = patchPrefixRe.ReplaceAllString(value, "")
+		case "from":
+			p.From = value
+		case "date":
+			p.Date = value
+		}
+	}
+	body := strings.Join(lines[min(i, len(lines)):], "")
+	at := strings.Index(body, "\ndiff ")
+	if at < 0 {
+		return p, false
+	}
+	diff := body[at+1:]
+	// The signature git format-patch appends: "-- " and git's version
+	if sig := strings.LastIndex(diff, "\n-- \n"); sig >= 0 {
+		diff = diff[:sig+1]
+	}
+	p.Diff = []byte(diff)
+	return p, true
+}
+
+// loadSeriesDir reads the *.patch files of dir, in name order as git
+// format-patch numbers them.
+func loadSeriesDir(dir string) (*Series, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.patch"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var series Series
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		series.Patches = append(series.Patches, splitMbox(data)...)
+	}
+	if len(series.Patches) == 0 {
+		return nil, fmt.Errorf("%s: no patches", dir)
+	}
+	return &series, nil
+}
+
+// seriesDir returns the directory of *.patch files given as the only
+// argument, or "". Other directories stay pathspecs for git diff.
+func seriesDir(args []string) string {
+	if len(args) != 1 {
+		return ""
+	}
+	if fi, err := os.Stat(args[0]); err != nil || !fi.IsDir() {
+		return ""
+	}
+	if files, _ := filepath.Glob(filepath.Join(args[0], "*.patch")); len(files) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// patchTitle names patch i of the series for lists and the status bar.
+func (sr *Series) patchTitle(i int) string {
+	return fmt.Sprintf("%d/%d %s", i+1, len(sr.Patches), sr.Patches[i].Subject)
+}
+
+// ShowPatch switches the view to the hunks of patch i of the series.
+func (s *State) ShowPatch(i int) {
+	sr := s.Series
+	if sr == nil || i < 0 || i >= len(sr.Patches) {
+		return
+	}
+	cur := &sr.Patches[sr.Current]
+	cur.hunks, cur.parsed = s.Hunks, true
+	sr.Current = i
+	p := &sr.Patches[i]
+	if !p.parsed {
+		hunks, err := s.parseHunks(p.Diff)
+		if err != nil {
+			s.FlashMsg = fmt.Sprintf("Patch %d: %v", i+1, err)
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+			return
+		}
+		p.hunks, p.parsed = hunks, true
+	}
+	s.Hunks = p.hunks
+	s.FilterFile = ""
+	buildTree(s)
+	s.BuildLines()
+	s.Scroll = 0
+	s.ClampScroll()
+	UpdateMatches(s)
+	s.FlashMsg = "Patch " + sr.patchTitle(i)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// NextPatch moves delta patches along the series.
+func (s *State) NextPatch(delta int) {
+	if s.Series == nil {
+		s.FlashMsg = "Not a patch series"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	i := s.Series.Current + delta
+	if i < 0 || i >= len(s.Series.Patches) {
+		s.FlashMsg = "No more patches"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.ShowPatch(i)
+}
+
+// OpenPatchList opens the commit list of the series: moving through it
+// previews each patch, Enter stays on it and Esc goes back.
+func OpenPatchList(s *State) {
+	sr := s.Series
+	if sr == nil {
+		s.FlashMsg = "Not a patch series"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	items := make([]string, len(sr.Patches))
+	for i := range sr.Patches {
+		items[i] = sr.patchTitle(i)
+	}
+	orig := sr.Current
+	p := &Popup{
+		Title:  "Patches",
+		Items:  items,
+		Cursor: orig,
+		OnMove: func(s *State, idx int) {
+			s.ShowPatch(idx)
+		},
+		OnSelect: func(s *State, idx int) {
+			s.ShowPatch(idx)
+		},
+		OnCancel: func(s *State) {
+			s.ShowPatch(orig)
+		},
+	}
+	OpenPopup(s, p)
+	movePopupCursor(s, p, 0) // scroll the current patch into view
+}
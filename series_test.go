@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const seriesMbox = `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: A U Thor <author@example.com>
+Date: Mon, 5 Oct 2026 10:00:00 +0200
+Subject: [PATCH 0/2] Cover letter
+
+The series.
+
+From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?J=C3=B6rg?= <jorg@example.com>
+Date: Mon, 5 Oct 2026 10:00:00 +0200
+Subject: [PATCH 1/2] Add a greeting that is long enough
+ to be folded
+
+From here on the message quotes a mbox separator.
+---
+ a.txt | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1,2 @@
+ one
++hello
+-- 
+2.47.0
+
+From 2222222222222222222222222222222222222222 Mon Sep 17 00:00:00 2001
+From: A U Thor <author@example.com>
+Date: Mon, 5 Oct 2026 10:01:00 +0200
+Subject: [PATCH 2/2] Drop b
+
+---
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1 @@
+ keep
+-drop
+-- 
+2.47.0
+
+`
+
+func TestSplitMbox(t *testing.T) {
+	if !isMbox([]byte(seriesMbox)) || isMbox([]byte("diff --git a/a b/a\n")) {
+		t.Fatal("isMbox misdetects")
+	}
+	patches := splitMbox([]byte(seriesMbox))
+	if len(patches) != 2 {
+		t.Fatalf("got %d patches, want 2 (cover letter left out)", len(patches))
+	}
+	p := patches[0]
+	if p.Subject != "Add a greeting that is long enough to be folded" || p.From != "Jörg <jorg@example.com>" {
+		t.Errorf("first patch = %q from %q", p.Subject, p.From)
+	}
+	if !strings.HasPrefix(string(p.Diff), "diff --git a/a.txt") || strings.Contains(string(p.Diff), "2.47.0") {
+		t.Errorf("first diff = %q", p.Diff)
+	}
+	if patches[1].Subject != "Drop b" {
+		t.Errorf("second subject = %q", patches[1].Subject)
+	}
+}
+
+func TestSeriesNavigation(t *testing.T) {
+	dir := t.TempDir()
+	mails := regexp.MustCompile(`\nFrom [0-9]{40} `).Split(seriesMbox, -1)
+	for i, m := range mails {
+		if i > 0 {
+			m = "From 0 " + m
+		}
+		name := filepath.Join(dir, []string{"0000-cover.patch", "0001-add.patch", "0002-drop.patch"}[i])
+		if err := os.WriteFile(name, []byte(m), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if seriesDir([]string{dir}) != dir || seriesDir([]string{t.TempDir()}) != "" {
+		t.Fatal("seriesDir should only take directories with patches")
+	}
+	series, err := loadSeriesDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &State{Height: 20, Width: 80, PipeMode: true, Series: series}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Hunks) != 1 || s.Hunks[0].File != "a.txt" {
+		t.Fatalf("first patch hunks = %+v", s.Hunks)
+	}
+	s.Hunks[0].Applied = true
+
+	s.NextPatch(1)
+	if series.Current != 1 || s.Hunks[0].File != "b.txt" {
+		t.Fatalf("after ]p: patch %d, hunks %+v", series.Current, s.Hunks)
+	}
+	if got := statusSegments["ref"].render(s); got != "wiff patch 2/2 Drop b" {
+		t.Errorf("ref segment = %q", got)
+	}
+	s.NextPatch(1)
+	if series.Current != 1 || s.FlashMsg != "No more patches" {
+		t.Errorf("]p past the end: patch %d, flash %q", series.Current, s.FlashMsg)
+	}
+	s.NextPatch(-1)
+	if !s.Hunks[0].Applied {
+		t.Error("going back to a patch should keep its hunks' marks")
+	}
+}
+
+func TestPatchListPreviewsAndCancels(t *testing.T) {
+	s := &State{Height: 20, Width: 80, PipeMode: true}
+	s.Series = &Series{Patches: splitMbox([]byte(seriesMbox))}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	OpenPatchList(s)
+	if s.Popup == nil || len(s.Popup.Items) != 2 || s.Popup.Items[1] != "2/2 Drop b" {
+		t.Fatalf("popup = %+v", s.Popup)
+	}
+	movePopupCursor(s, s.Popup, 1)
+	if s.Series.Current != 1 {
+		t.Errorf("moving the cursor should preview patch 2, at %d", s.Series.Current)
+	}
+	cancelPopup(s, s.Popup)
+	if s.Series.Current != 0 || s.Hunks[0].File != "a.txt" {
+		t.Errorf("Esc should go back to patch 1, at %d", s.Series.Current)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ttyScreen is the screen on the terminal, set only when wiff runs
+// interactively; Ctrl-Z suspends it.
+var ttyScreen tcell.Screen
+
+// EventSignal carries a signal wiff received to the main loop, which
+// restores the terminal before quitting or stopping.
+type EventSignal struct {
+	t   time.Time
+	sig os.Signal
+}
+
+func (e *EventSignal) When() time.Time { return e.t }
+
+// watchSignals posts the signals that end or stop wiff to screen's event
+// queue, so the main loop handles them between events.
+func watchSignals(screen tcell.Screen) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, append([]os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}, stopSignals...)...)
+	go func() {
+		for sig := range ch {
+			_ = screen.PostEvent(&EventSignal{t: time.Now(), sig: sig})
+		}
+	}()
+}
+
+// isStopSignal reports whether sig asks wiff to stop rather than quit.
+func isStopSignal(sig os.Signal) bool {
+	for _, s := range stopSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+// signalStatus returns the exit status for dying of sig, as shells report
+// it: 128 plus the signal number.
+func signalStatus(sig os.Signal) int {
+	if n, ok := sig.(syscall.Signal); ok {
+		return 128 + int(n)
+	}
+	return 1
+}
+
+// suspend hands the terminal back to the shell and stops wiff, like Ctrl-Z
+// in other full-screen programs; it redraws after the shell resumes it.
+func suspend(s *State) {
+	if ttyScreen == nil {
+		return
+	}
+	if err := ttyScreen.Suspend(); err != nil {
+		s.FlashMsg = "Suspend: " + err.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	stopErr := stopProcess()
+	if err := ttyScreen.Resume(); err != nil {
+		ttyScreen.Fini()
+		fmt.Fprintf(os.Stderr, "Fatal: failed to resume the screen: %v\n", err)
+		os.Exit(1)
+	}
+	if stopErr != nil {
+		s.FlashMsg = "Suspend: " + stopErr.Error()
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+	}
+	// The terminal may have been resized meanwhile
+	s.resize(ttyScreen.Size())
+	ttyScreen.Sync()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestSignalStatus(t *testing.T) {
+	if got := signalStatus(syscall.SIGTERM); got != 128+int(syscall.SIGTERM) {
+		t.Errorf("signalStatus(SIGTERM) = %d", got)
+	}
+	if isStopSignal(syscall.SIGTERM) || isStopSignal(os.Interrupt) {
+		t.Error("SIGTERM and SIGINT should quit, not stop")
+	}
+	for _, sig := range stopSignals {
+		if !isStopSignal(sig) {
+			t.Errorf("%v should stop wiff", sig)
+		}
+	}
+}
+
+func TestSuspendWithoutTerminal(t *testing.T) {
+	// Scripts and tests have no terminal to hand back: Ctrl-Z does nothing
+	s := &State{Height: 10, Width: 40}
+	if HandleKey(s, tcell.NewEventKey(tcell.KeyCtrlZ, 0, tcell.ModCtrl)) {
+		t.Error("Ctrl-Z should not quit")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// snippetFormats are the markdown formats M+label can copy a hunk as.
+var snippetFormats = []struct {
+	name string
+	text func(s *State, h *Hunk) (string, error)
+}{
+	{"```lang block of the new code", func(s *State, h *Hunk) (string, error) {
+		return fenced(s.fenceLanguage(h.File), h.ResultLines()), nil
+	}},
+	{"```diff block of the patch", func(s *State, h *Hunk) (string, error) {
+		return fenced("diff", strings.TrimSuffix(h.AsPatch(), "\n")), nil
+	}},
+	{"forge permalink + snippet", func(s *State, h *Hunk) (string, error) {
+		start, end := h.NewRange()
+		u, err := s.forgeLink(h.File, start, end)
+		if err != nil {
+			return "", err
+		}
+		return u + "\n\n" + fenced(s.fenceLanguage(h.File), h.ResultLines()), nil
+	}},
+}
+
+// fenced wraps text in a markdown code fence. The fence grows past any run
+// of backticks in the text so it can't be closed early.
+func fenced(lang, text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + text + "\n" + fence + "\n"
+}
+
+// fenceLanguage returns the code fence language for file.
+func (s *State) fenceLanguage(file string) string {
+	if s.HL == nil {
+		return ""
+	}
+	return s.HL.FenceLanguage(file)
+}
+
+// openSnippetPicker offers the markdown formats to copy hunk as.
+func openSnippetPicker(s *State, hunk *Hunk) {
+	items := make([]string, len(snippetFormats))
+	for i, f := range snippetFormats {
+		items[i] = f.name
+	}
+	label := hunk.Label
+	OpenPopup(s, &Popup{
+		Title: "Copy hunk " + label + " as markdown",
+		Items: items,
+		OnSelect: func(s *State, idx int) {
+			h := s.HunkByLabel(label)
+			if h == nil {
+				return
+			}
+			text, err := snippetFormats[idx].text(s, h)
+			if err != nil {
+				s.FlashMsg = "Snippet: " + err.Error()
+				s.FlashExpiry = time.Now().Add(2 * time.Second)
+				return
+			}
+			copyText(s, text, fmt.Sprintf("Copied hunk %s as markdown", label))
+		},
+	})
+}
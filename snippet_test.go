@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestFenced(t *testing.T) {
+	if got, want := fenced("go", "x := 1"), "```go\nx := 1\n```\n"; got != want {
+		t.Errorf("fenced = %q, want %q", got, want)
+	}
+	// Backticks in the text lengthen the fence
+	if got, want := fenced("md", "```sh\nls\n```"), "````md\n```sh\nls\n```\n````\n"; got != want {
+		t.Errorf("fenced = %q, want %q", got, want)
+	}
+}
+
+func TestSnippetFormats(t *testing.T) {
+	s := &State{HL: NewHighlighter()}
+	h := &Hunk{File: "main.go", Header: "@@ -1,2 +1,2 @@", NewStart: 4, Lines: []Line{
+		{Op: ' ', Content: "a"},
+		{Op: '-', Content: "b"},
+		{Op: '+', Content: "c"},
+	}}
+	code, _ := snippetFormats[0].text(s, h)
+	if want := "```go\na\nc\n```\n"; code != want {
+		t.Errorf("code block = %q, want %q", code, want)
+	}
+	diff, _ := snippetFormats[1].text(s, h)
+	if want := "```diff\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n```\n"; diff != want {
+		t.Errorf("diff block = %q, want %q", diff, want)
+	}
+	if start, end := h.NewRange(); start != 4 || end != 5 {
+		t.Errorf("newRange = %d-%d, want 4-5", start, end)
+	}
+}
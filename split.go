@@ -0,0 +1,325 @@
+package main
+
+import (
+	"maps"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// windowKey is Ctrl-W, the prefix of the split (window) commands.
+const windowKey = rune(tcell.KeyCtrlW)
+
+// Split shows two panes on one screen, each a State of its own drawn
+// through a paneScreen. Vertical puts them side by side, otherwise the
+// second pane is below the first.
+type Split struct {
+	Panes    [2]*State
+	Focus    int // index of the pane that gets the keys
+	Vertical bool
+
+	screen        tcell.Screen // the whole screen the panes are cut from
+	areas         [2]*paneScreen
+	width, height int
+}
+
+// paneScreen is the part of a screen a split pane draws into. Coordinates
+// are relative to the pane and drawing outside of it is dropped.
+type paneScreen struct {
+	tcell.Screen
+	x, y, w, h int
+}
+
+func (p *paneScreen) SetContent(x, y int, mainc rune, combc []rune, style tcell.Style) {
+	if x < 0 || y < 0 || x >= p.w || y >= p.h {
+		return
+	}
+	p.Screen.SetContent(p.x+x, p.y+y, mainc, combc, style)
+}
+
+func (p *paneScreen) GetContent(x, y int) (rune, []rune, tcell.Style, int) {
+	return p.Screen.GetContent(p.x+x, p.y+y)
+}
+
+func (p *paneScreen) Size() (int, int) {
+	return p.w, p.h
+}
+
+// Clear blanks the pane only.
+func (p *paneScreen) Clear() {
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			p.Screen.SetContent(p.x+x, p.y+y, ' ', nil, tcell.StyleDefault)
+		}
+	}
+}
+
+// Show is left to the split, which shows both panes at once.
+func (p *paneScreen) Show() {}
+
+// layout cuts a w×h screen into the two panes, rebuilding the lines of
+// each pane whose size changed.
+func (sp *Split) layout(w, h int) {
+	sp.width, sp.height = w, h
+	a, b := sp.areas[0], sp.areas[1]
+	if sp.Vertical {
+		left := (w - 1) / 2 // one column for the divider
+		*a = paneScreen{Screen: sp.screen, w: left, h: h}
+		*b = paneScreen{Screen: sp.screen, x: left + 1, w: w - left - 1, h: h}
+	} else {
+		top := h / 2
+		*a = paneScreen{Screen: sp.screen, w: w, h: top}
+		*b = paneScreen{Screen: sp.screen, y: top, w: w, h: h - top}
+	}
+	for i, p := range sp.Panes {
+		if p.Width != sp.areas[i].w || p.Height != sp.areas[i].h {
+			p.Width, p.Height = sp.areas[i].w, sp.areas[i].h
+			p.BuildLines()
+			p.ClampScroll()
+		}
+	}
+}
+
+// renderSplit draws both panes and the divider between side-by-side panes.
+func renderSplit(sp *Split) {
+	sp.screen.Clear()
+	for _, p := range sp.Panes {
+		drawView(p)
+	}
+	if sp.Vertical {
+		p := sp.Panes[sp.Focus]
+		x := sp.areas[1].x - 1
+		for y := 0; y < sp.height; y++ {
+			sp.screen.SetContent(x, y, '│', nil, p.Theme.Dim)
+		}
+	}
+	sp.screen.Show()
+}
+
+// split shows t next to s, to the right when vertical and below otherwise,
+// and gives it the focus.
+func (s *State) split(t *State, vertical bool) {
+	sp := &Split{Panes: [2]*State{s, t}, Focus: 1, Vertical: vertical, screen: s.Screen}
+	for i, p := range sp.Panes {
+		sp.areas[i] = &paneScreen{}
+		p.Split = sp
+		p.Screen = sp.areas[i]
+		p.Tabs = s.Tabs
+	}
+	sp.layout(s.Width, s.Height)
+}
+
+// splitRefused flashes why s can't be split again, reporting true if so.
+func splitRefused(s *State) bool {
+	if s.Split == nil {
+		return false
+	}
+	s.FlashMsg = "Already split (^W q closes a pane)"
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	return true
+}
+
+// SplitPane splits the screen and shows the current diff, from the same
+// spot, in the new pane too; one pane can then show the full file.
+func SplitPane(s *State, vertical bool) {
+	if splitRefused(s) {
+		return
+	}
+	s.split(s.duplicate(), vertical)
+}
+
+// OpenSplit opens a diff of the given arguments (as for a tab) in a new
+// pane next to s.
+func OpenSplit(s *State, args string, vertical bool) error {
+	if splitRefused(s) {
+		return nil
+	}
+	refs, staged := parseTabArgs(args)
+	refs, paths, err := resolveArgs(refs)
+	if err != nil {
+		return err
+	}
+	t := s.newTab(refs, staged)
+	t.Paths = paths
+	if err := loadDiff(t); err != nil {
+		return err
+	}
+	s.split(t, vertical)
+	s.tabs().watch(t)
+	return nil
+}
+
+// duplicate returns a State showing the same diff as s at the same spot.
+// Reloads refill it on its own; pipe mode can't read the diff twice.
+func (s *State) duplicate() *State {
+	t := s.newTab(s.Refs, s.Staged)
+	t.Paths = s.Paths
+	t.NoIndex = s.NoIndex
+	t.PipeMode = s.PipeMode
+	t.WatchEnabled = s.WatchEnabled
+	t.Branch, t.Tracking = s.Branch, s.Tracking
+	t.Hunks = append([]Hunk(nil), s.Hunks...)
+	t.HiddenFiles = s.HiddenFiles
+	t.Generated = s.Generated
+	t.Textconv = s.Textconv
+	t.Expanded = maps.Clone(s.Expanded)
+	t.ExpandedHunks = maps.Clone(s.ExpandedHunks)
+	t.FileSizes = s.FileSizes
+	t.TreeFiles = s.TreeFiles
+	t.FilterFile = s.FilterFile
+	t.IndexSplit, t.IndexFilter = s.IndexSplit, s.IndexFilter
+	t.Review, t.ReviewDone = s.Review, s.ReviewDone
+	t.Marks = s.Marks
+	t.FullFile, t.FullFileName, t.FullFileOld = s.FullFile, s.FullFileName, s.FullFileOld
+	t.FullFileRev, t.FullFileRevSet = s.FullFileRev, s.FullFileRevSet
+	t.FullFilePair, t.FullFilePairRevs = s.FullFilePair, s.FullFilePairRevs
+	t.BuildLines()
+	t.Scroll = s.Scroll
+	t.ClampScroll()
+	return t
+}
+
+// FocusNextPane moves the focus to the other pane of the split.
+func FocusNextPane(s *State) {
+	if s.Split == nil {
+		s.FlashMsg = "No split (^W s or ^W v splits)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.Split.Focus = 1 - s.Split.Focus
+}
+
+// ClosePane closes pane s of its split, or the other pane when only is
+// set. The pane that stays takes the whole screen.
+func ClosePane(s *State, only bool) {
+	sp := s.Split
+	if sp == nil {
+		s.FlashMsg = "No split to close (q quits)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	closed, keep := s, sp.Panes[0]
+	if keep == s {
+		keep = sp.Panes[1]
+	}
+	if only {
+		closed, keep = keep, closed
+	}
+	// closed keeps pointing at the split, now focused on keep, so the
+	// caller finds its way to the pane that stayed
+	sp.Focus = 0
+	if sp.Panes[1] == keep {
+		sp.Focus = 1
+	}
+	keep.Split = nil
+	keep.Screen = sp.screen
+	keep.resize(sp.width, sp.height)
+	if keep.Tabs != nil {
+		for i, t := range keep.Tabs.States {
+			if t == closed {
+				keep.Tabs.States[i] = keep
+			}
+		}
+	}
+}
+
+// focusedPane returns the pane of s's split that has the focus, or s.
+func (s *State) focusedPane() *State {
+	if s.Split == nil {
+		return s
+	}
+	return s.Split.Panes[s.Split.Focus]
+}
+
+// hasFocus reports whether keys go to s: always, unless it's the other
+// pane of a split.
+func (s *State) hasFocus() bool {
+	return s.focusedPane() == s
+}
+
+// panes returns the panes of s's split, or just s.
+func (s *State) panes() []*State {
+	if s.Split == nil {
+		return []*State{s}
+	}
+	return s.Split.Panes[:]
+}
+
+// fullScreen returns the whole screen and its size, which for a split pane
+// is more than the pane's own.
+func (s *State) fullScreen() (tcell.Screen, int, int) {
+	if s.Split != nil {
+		return s.Split.screen, s.Split.width, s.Split.height
+	}
+	return s.Screen, s.Width, s.Height
+}
+
+// resize fits s, or both panes of its split, to a w×h screen.
+func (s *State) resize(w, h int) {
+	if s.Split != nil {
+		s.Split.layout(w, h)
+		return
+	}
+	if s.Width == w && s.Height == h {
+		return
+	}
+	s.Width, s.Height = w, h
+	s.BuildLines()
+	s.ClampScroll()
+}
+
+// paneAt returns the pane at screen position x, y and the position within
+// it, focusing the pane. Outside a split it is s at the same position; ok
+// is false on the divider between side-by-side panes.
+func (s *State) paneAt(x, y int) (p *State, px, py int, ok bool) {
+	p, px, py, ok = s.paneUnder(x, y)
+	if ok && s.Split != nil {
+		for i, pane := range s.Split.Panes {
+			if pane == p {
+				s.Split.Focus = i
+			}
+		}
+	}
+	return p, px, py, ok
+}
+
+// paneUnder is paneAt without focusing the pane, for the pointer moving
+// over it.
+func (s *State) paneUnder(x, y int) (p *State, px, py int, ok bool) {
+	sp := s.Split
+	if sp == nil {
+		return s, x, y, true
+	}
+	for i, a := range sp.areas {
+		if x >= a.x && x < a.x+a.w && y >= a.y && y < a.y+a.h {
+			return sp.Panes[i], x - a.x, y - a.y, true
+		}
+	}
+	return s, x, y, false
+}
+
+// handleWindowKey runs the ^W command r.
+func handleWindowKey(s *State, r rune) {
+	switch r {
+	case 's':
+		SplitPane(s, false)
+	case 'v':
+		SplitPane(s, true)
+	case 'n':
+		if !splitRefused(s) {
+			openTabPrompt(s, true)
+		}
+	case 'w', 'h', 'j', 'k', 'l':
+		FocusNextPane(s)
+	case 'q', 'c':
+		ClosePane(s, false)
+	case 'o':
+		ClosePane(s, true)
+	case '<':
+		s.AdjustColumnRatio(-columnRatioStep)
+	case '>':
+		s.AdjustColumnRatio(columnRatioStep)
+	case '=':
+		s.AdjustColumnRatio(0)
+	}
+}
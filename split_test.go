@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func ctrlW() *tcell.EventKey {
+	return tcell.NewEventKey(tcell.KeyCtrlW, 0, tcell.ModNone)
+}
+
+func TestSplitPaneKeys(t *testing.T) {
+	s := makeTestState(81, false, false, []Line{{Op: '+', Content: "a"}, {Op: '-', Content: "b"}})
+	s.Height = 24
+	s.BuildLines()
+
+	HandleKey(s, ctrlW())
+	if s.PendingDisplay() != "^W" {
+		t.Errorf("PendingDisplay = %q, want ^W", s.PendingDisplay())
+	}
+	HandleKey(s, makeKeyEvent('v'))
+	sp := s.Split
+	if sp == nil || !sp.Vertical || sp.Panes[0] != s || sp.Focus != 1 {
+		t.Fatalf("^W v should split beside s and focus the new pane")
+	}
+	right := sp.Panes[1]
+	if s.Width != 40 || right.Width != 40 || s.Height != 24 || right.Height != 24 {
+		t.Errorf("pane sizes %dx%d and %dx%d, want 40x24 each beside a divider",
+			s.Width, s.Height, right.Width, right.Height)
+	}
+	if len(right.Lines) != len(s.Lines) {
+		t.Errorf("new pane shows %d lines, want the same diff's %d", len(right.Lines), len(s.Lines))
+	}
+
+	HandleKey(right, ctrlW())
+	HandleKey(right, ctrlW())
+	if s.focusedPane() != s || right.hasFocus() {
+		t.Errorf("^W ^W should move the focus back to the first pane")
+	}
+
+	HandleKey(s, ctrlW())
+	HandleKey(s, makeKeyEvent('q'))
+	if right.Split != nil || right.Width != 81 || right.Height != 24 {
+		t.Errorf("after ^W q the other pane should have the whole 81x24 screen, got %dx%d", right.Width, right.Height)
+	}
+	if s.focusedPane() != right {
+		t.Errorf("the closed pane should lead to the one that stayed")
+	}
+}
+
+func TestSplitOnlyAndHorizontal(t *testing.T) {
+	s := makeTestState(80, false, false, []Line{{Op: '+', Content: "a"}})
+	s.Height = 25
+	SplitPane(s, false)
+	below := s.Split.Panes[1]
+	if s.Height != 12 || below.Height != 13 || below.Width != 80 {
+		t.Errorf("pane heights %d and %d, want 12 and 13", s.Height, below.Height)
+	}
+
+	SplitPane(below, true)
+	if below.FlashMsg == "" {
+		t.Errorf("splitting a pane again should be refused with a message")
+	}
+
+	ClosePane(below, true)
+	if below.Split != nil || below.Height != 25 {
+		t.Errorf("^W o should leave the focused pane alone on the screen")
+	}
+}
+
+func TestPaneScreenAndPaneAt(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(21, 5)
+
+	s := &State{Screen: sim, Width: 21, Height: 5}
+	s.split(&State{}, true)
+	right := s.Split.Panes[1]
+
+	right.Screen.SetContent(0, 0, 'a', nil, tcell.StyleDefault)
+	right.Screen.SetContent(10, 0, 'b', nil, tcell.StyleDefault) // past the pane
+	if r, _, _, _ := sim.GetContent(11, 0); r != 'a' {
+		t.Errorf("pane column 0 drew at %q, want 'a' at screen column 11", r)
+	}
+	if w, h := right.Screen.Size(); w != 10 || h != 5 {
+		t.Errorf("pane size = %dx%d, want 10x5", w, h)
+	}
+
+	p, x, y, ok := s.paneAt(13, 2)
+	if !ok || p != right || x != 2 || y != 2 || s.Split.Focus != 1 {
+		t.Errorf("paneAt(13, 2) = %v %d,%d %v", p == right, x, y, ok)
+	}
+	if _, _, _, ok := s.paneAt(10, 2); ok {
+		t.Errorf("the divider column should not belong to a pane")
+	}
+	p, _, _, _ = s.paneAt(3, 4)
+	if p != s || s.Split.Focus != 0 {
+		t.Errorf("clicking the left pane should focus it")
+	}
+}
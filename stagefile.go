@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// fileHunks returns the hunks of file, in diff order.
+func (s *State) fileHunks(file string) []*Hunk {
+	var hunks []*Hunk
+	for i := range s.Hunks {
+		if s.Hunks[i].File == file {
+			hunks = append(hunks, &s.Hunks[i])
+		}
+	}
+	return hunks
+}
+
+// allStaged reports whether every one of hunks is staged.
+func allStaged(hunks []*Hunk) bool {
+	for _, h := range hunks {
+		if !h.Staged {
+			return false
+		}
+	}
+	return len(hunks) > 0
+}
+
+// filesPatch formats hunks as one patch for git apply, with a file header
+// before the first hunk of each file.
+func filesPatch(hunks []*Hunk) string {
+	var sb strings.Builder
+	file := ""
+	for _, h := range hunks {
+		if h.File != file {
+			file = h.File
+			sb.WriteString(h.AsFullPatch())
+			continue
+		}
+		sb.WriteString(h.AsPatch())
+	}
+	return sb.String()
+}
+
+// stageFilesRefused reports whether hunks can't be staged or unstaged as
+// whole files, flashing why. Whole files are staged with git add, so only
+// the unstaged diff (index against the working tree) qualifies.
+func stageFilesRefused(s *State, hunks []*Hunk) bool {
+	if len(hunks) == 0 {
+		s.FlashMsg = "No changes to stage"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return true
+	}
+	for _, h := range hunks {
+		if stageRefused(s, h) {
+			return true
+		}
+	}
+	if oldRev, newRev := s.sideRevs(); s.PipeMode || oldRev != revIndex || newRev != revWorktree {
+		s.FlashMsg = "Whole files are staged from the unstaged diff (wiff with no refs)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return true
+	}
+	for _, h := range hunks {
+		if staleRefused(s, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// stageFiles stages the files of hunks, or unstages them when all their
+// hunks are staged, in a single git command: git add for staging, which
+// also covers new, deleted and binary files, and one reversed patch of the
+// staged hunks for unstaging, which leaves what was staged before wiff
+// alone. It returns false when it failed or was refused.
+func stageFiles(s *State, hunks []*Hunk) bool {
+	if stageFilesRefused(s, hunks) {
+		return false
+	}
+	unstage := allStaged(hunks)
+	var cmd *exec.Cmd
+	if unstage {
+		cmd = exec.Command("git", "apply", "--cached", "-R")
+		cmd.Stdin = strings.NewReader(filesPatch(hunks))
+	} else {
+		args := []string{"add", "--"}
+		seen := make(map[string]bool)
+		for _, h := range hunks {
+			if !seen[h.File] {
+				seen[h.File] = true
+				args = append(args, h.File)
+			}
+		}
+		cmd = exec.Command("git", args...)
+		if root, err := repo.Root(); err == nil {
+			cmd.Dir = root
+		}
+	}
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	if err != nil {
+		action := "Stage"
+		if unstage {
+			action = "Unstage"
+		}
+		logger.Warn(strings.ToLower(action)+" failed", "files", len(hunks), "err", err, "output", string(out))
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		s.FlashMsg = fmt.Sprintf("%s failed: %s", action, firstLine(msg))
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return false
+	}
+	for _, h := range hunks {
+		h.Staged = !unstage
+	}
+	s.refreshIndexSplit()
+	return true
+}
+
+// stagedMsg describes what stageFiles just did to hunks of what.
+func stagedMsg(hunks []*Hunk, what string) string {
+	action := "Staged"
+	if !hunks[0].Staged {
+		action = "Unstaged"
+	}
+	return fmt.Sprintf("%s %s (%d hunks)", action, what, len(hunks))
+}
+
+// StageFile stages every hunk of file, or unstages them all when they all
+// are staged.
+func StageFile(s *State, file string) {
+	if file == "" {
+		return
+	}
+	hunks := s.fileHunks(file)
+	if !stageFiles(s, hunks) {
+		return
+	}
+	s.FlashMsg = stagedMsg(hunks, file)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// confirmStageAll asks before staging every file of the diff, or unstaging
+// them all when everything is staged, listing the files it would touch.
+func confirmStageAll(s *State) {
+	var hunks []*Hunk
+	for i := range s.Hunks {
+		hunks = append(hunks, &s.Hunks[i])
+	}
+	if len(hunks) == 0 {
+		s.FlashMsg = "No changes to stage"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	action := "Stage"
+	if allStaged(hunks) {
+		action = "Unstage"
+	}
+	var files []string
+	for _, h := range hunks {
+		if len(files) == 0 || files[len(files)-1] != h.File {
+			files = append(files, h.File)
+		}
+	}
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("%s all %d files (%d hunks)? Enter confirms, Esc cancels", action, len(files), len(hunks)),
+		Items: files,
+		OnSelect: func(s *State, _ int) {
+			if stageFiles(s, hunks) {
+				s.FlashMsg = stagedMsg(hunks, fmt.Sprintf("%d files", len(files)))
+				s.FlashExpiry = time.Now().Add(2 * time.Second)
+			}
+		},
+		OnCancel: func(s *State) {
+			s.FlashMsg = action + " canceled"
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestStageFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+		return string(out)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := func(n int, edit map[int]string) string {
+		var sb strings.Builder
+		for i := 1; i <= n; i++ {
+			if e, ok := edit[i]; ok {
+				sb.WriteString(e + "\n")
+			} else {
+				sb.WriteString("line\n")
+			}
+		}
+		return sb.String()
+	}
+	git("init", "-q")
+	write("a.txt", lines(30, nil))
+	write("c.txt", "c\n")
+	git("add", ".")
+	git("commit", "-qm", "Add a and c")
+	write("a.txt", lines(30, map[int]string{2: "two", 28: "twenty-eight"}))
+	write("b.txt", "new\n")
+	git("add", "-N", "b.txt")
+	git("rm", "-q", "--cached", "c.txt")
+	git("commit", "-qm", "Untrack c")
+	git("add", "c.txt")
+	os.Remove("c.txt")
+
+	s := &State{Width: 80, Height: 24, ContextLines: 3}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.fileHunks("a.txt")); n != 2 {
+		t.Fatalf("a.txt has %d hunks, want 2", n)
+	}
+
+	StageFile(s, "a.txt")
+	if !allStaged(s.fileHunks("a.txt")) || s.FlashMsg != "Staged a.txt (2 hunks)" {
+		t.Fatalf("after staging a.txt: %q", s.FlashMsg)
+	}
+	staged := git("diff", "--cached", "--stat")
+	if !strings.Contains(staged, "a.txt") || strings.Contains(staged, "b.txt") {
+		t.Errorf("index after gs on a.txt:\n%s", staged)
+	}
+
+	StageFile(s, "a.txt")
+	if s.FlashMsg != "Unstaged a.txt (2 hunks)" || strings.Contains(git("diff", "--cached", "--stat"), "a.txt") {
+		t.Errorf("second gs should unstage a.txt: %q", s.FlashMsg)
+	}
+
+	confirmStageAll(s)
+	if s.Popup == nil || !strings.HasPrefix(s.Popup.Title, "Stage all 3 files") {
+		t.Fatalf("no confirmation: %+v", s.Popup)
+	}
+	HandleKey(s, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if git("diff") != "" {
+		t.Errorf("gS left unstaged changes:\n%s", git("diff"))
+	}
+	if s.FlashMsg != "Staged 3 files (4 hunks)" {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+}
+
+func TestStageFilesRefusesOtherDiffs(t *testing.T) {
+	s := &State{Refs: []string{"HEAD~1", "HEAD"}}
+	s.Hunks = []Hunk{{File: "a.go", Lines: []Line{{Op: '+', Content: "a"}}}}
+	StageFile(s, "a.go")
+	if s.Hunks[0].Staged || !strings.Contains(s.FlashMsg, "unstaged diff") {
+		t.Errorf("flash = %q", s.FlashMsg)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// staleHunk returns why hunk no longer matches the files it would be staged
+// against, or "" when it still does. The working tree must hold its new
+// side where the diff put it; the index, which staging other hunks shifts,
+// must hold the side git apply --cached looks for somewhere in the file.
+func staleHunk(s *State, hunk *Hunk) string {
+	if s.PipeMode || s.NoIndex {
+		return ""
+	}
+	oldRev, newRev := s.sideRevs()
+	if newRev == revWorktree {
+		if want := hunkSide(hunk, '+'); len(want) > 0 {
+			content, err := fileAt(revWorktree, hunk.File)
+			if err != nil {
+				return hunk.File + " is gone from the working tree"
+			}
+			if !linesAt(splitFileLines(content), want, hunk.NewStart) {
+				return hunk.File + " changed in the working tree since the diff was loaded"
+			}
+		}
+	}
+	if oldRev == revIndex {
+		// Once staged, the index holds the new side for -R to find
+		want, file := hunkSide(hunk, '-'), hunk.File
+		if hunk.Staged {
+			want = hunkSide(hunk, '+')
+		} else if hunk.OldFile != "" {
+			file = hunk.OldFile
+		}
+		if len(want) > 0 {
+			content, err := fileAt(revIndex, file)
+			if err != nil || !containsLines(splitFileLines(content), want) {
+				return hunk.File + " changed in the index since the diff was loaded"
+			}
+		}
+	}
+	return ""
+}
+
+// hunkSide returns the lines of one side of hunk: the context lines and
+// those with op ('-' for the old side, '+' for the new).
+func hunkSide(hunk *Hunk, op rune) []string {
+	var lines []string
+	for _, l := range hunk.Lines {
+		if l.Op == ' ' || l.Op == op {
+			lines = append(lines, l.Content)
+		}
+	}
+	return lines
+}
+
+// splitFileLines splits file content into lines without their newlines.
+func splitFileLines(content []byte) []string {
+	text := strings.TrimSuffix(string(content), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// linesAt reports whether want appears in lines starting at 1-based line
+// start.
+func linesAt(lines, want []string, start int) bool {
+	i := max(start-1, 0)
+	return i+len(want) <= len(lines) && slices.Equal(lines[i:i+len(want)], want)
+}
+
+// containsLines reports whether want appears anywhere in lines.
+func containsLines(lines, want []string) bool {
+	for i := 0; i+len(want) <= len(lines); i++ {
+		if slices.Equal(lines[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// staleRefused reports whether hunk is stale, offering to reload the diff
+// rather than letting git apply fail on it or stage the wrong lines.
+func staleRefused(s *State, hunk *Hunk) bool {
+	why := staleHunk(s, hunk)
+	if why == "" {
+		return false
+	}
+	logger.Warn("stale hunk", "file", hunk.File, "hunk", hunk.Label, "reason", why)
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("Hunk %s is stale: %s", hunk.Label, why),
+		Items: []string{"Reload the diff", "Keep this diff"},
+		OnSelect: func(s *State, i int) {
+			if i == 0 {
+				refreshDiff(s)
+			}
+		},
+	})
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStaleHunk(t *testing.T) {
+	h := &Hunk{File: "a.txt", OldStart: 2, NewStart: 2, Lines: []Line{
+		{Op: ' ', Content: "b"}, {Op: '-', Content: "c"}, {Op: '+', Content: "C"},
+	}}
+	if got := hunkSide(h, '-'); strings.Join(got, ",") != "b,c" {
+		t.Errorf("old side = %q", got)
+	}
+	lines := []string{"a", "b", "C", "d"}
+	if !linesAt(lines, hunkSide(h, '+'), h.NewStart) {
+		t.Error("new side should be at line 2")
+	}
+	if linesAt(lines, hunkSide(h, '+'), 3) || linesAt(lines, hunkSide(h, '+'), 4) {
+		t.Error("new side matched at the wrong line")
+	}
+	if !containsLines([]string{"x", "a", "b", "c"}, hunkSide(h, '-')) {
+		t.Error("old side should be found after other lines moved it")
+	}
+}
+
+func TestStageStaleHunk(t *testing.T) {
+	t.Chdir(t.TempDir())
+	git := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=Ann", "-c", "user.email=ann@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	if err := os.WriteFile("a.txt", []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-qm", "Add a")
+	if err := os.WriteFile("a.txt", []byte("one\n2\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &State{Width: 80, Height: 24, ContextLines: 3}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if why := staleHunk(s, &s.Hunks[0]); why != "" {
+		t.Fatalf("fresh hunk is stale: %s", why)
+	}
+
+	// Edited again after the diff was loaded
+	if err := os.WriteFile("a.txt", []byte("one\ntwo 2\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := &s.Hunks[0]
+	handleStageHunk(s, h)
+	if h.Staged || git("diff", "--cached") != "" {
+		t.Fatal("a stale hunk was staged")
+	}
+	if s.Popup == nil || !strings.Contains(s.Popup.Title, "a.txt changed in the working tree") {
+		t.Fatalf("no reload prompt: %+v", s.Popup)
+	}
+
+	// Changed in the index instead
+	ClosePopup(s)
+	if err := os.WriteFile("a.txt", []byte("one\n2\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if why := staleHunk(s, h); why != "" {
+		t.Fatalf("hunk stale after the edit was undone: %s", why)
+	}
+	if err := os.WriteFile("a.txt", []byte("one\nzwei\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "a.txt")
+	if err := os.WriteFile("a.txt", []byte("one\n2\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if why := staleHunk(s, h); !strings.Contains(why, "changed in the index") {
+		t.Errorf("stale reason = %q, want the index", why)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// startRecent is how many recent commits the start menu lists.
+const startRecent = 6
+
+// startChoice is a diff the start menu offers when there are no changes.
+type startChoice struct {
+	label  string
+	refs   []string
+	staged bool
+}
+
+// startChoices returns the diffs worth opening instead of an empty one: the
+// last commit and what changed since, the staged changes (git) and each
+// recent commit on its own.
+func startChoices() []startChoice {
+	last := repo.Base()
+	if last == revIndex {
+		last = repo.Head()
+	}
+	prev := repo.Parent(last)
+	choices := []startChoice{{label: fmt.Sprintf("%-12s the last commit and changes since", prev), refs: []string{prev}}}
+	if _, git := repo.(gitVCS); git {
+		choices = append(choices, startChoice{label: fmt.Sprintf("%-12s staged changes", "--staged"), staged: true})
+	}
+	for _, c := range repo.Recent(startRecent) {
+		choices = append(choices, startChoice{
+			label: fmt.Sprintf("%-12s %s", c.Rev, c.Subject),
+			refs:  []string{repo.Parent(c.Rev), c.Rev},
+		})
+	}
+	return choices
+}
+
+// OpenStartMenu opens the picker of diffs to show instead of the current
+// one; 1-9 pick straight away.
+func OpenStartMenu(s *State) {
+	if s.PipeMode || s.NoRepo {
+		s.FlashMsg = "No other diffs to open here"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	choices := startChoices()
+	items := make([]string, len(choices))
+	for i, c := range choices {
+		items[i] = c.label
+	}
+	OpenPopup(s, &Popup{
+		Title: "Open diff",
+		Items: items,
+		OnSelect: func(s *State, idx int) {
+			c := choices[idx]
+			if err := s.ShowDiff(c.refs, c.staged); err != nil {
+				s.FlashMsg = "Open diff: " + err.Error()
+				s.FlashExpiry = time.Now().Add(3 * time.Second)
+			}
+		},
+	})
+}
+
+// ShowDiff replaces the diff in view with the diff of refs, from the top.
+func (s *State) ShowDiff(refs []string, staged bool) error {
+	oldRefs, oldStaged := s.Refs, s.Staged
+	s.Refs, s.Staged = refs, staged
+	s.FilterFile = ""
+	if err := loadDiff(s); err != nil {
+		s.Refs, s.Staged = oldRefs, oldStaged
+		return err
+	}
+	s.Scroll, s.Cursor = 0, 0
+	s.ClampScroll()
+	UpdateMatches(s)
+	s.FlashMsg = "Showing " + s.RefDisplay()
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	return nil
+}
+
+// drawEmptyState fills the diff pane when there are no lines to show: what
+// was wrong with the piped input, that wiff runs outside a repository, or
+// that there are no changes and where to look instead.
+func drawEmptyState(s *State, visible int) {
+	right := s.DiffX + s.DiffWidth
+	x, y := s.DiffX+2, 1
+	title := s.Theme.Default.Bold(true)
+	line := func(text string, style tcell.Style) {
+		if y < visible {
+			drawText(s.Screen, x, y, text, style, right)
+		}
+		y++
+	}
+	switch {
+	case s.InputErr != nil:
+		line(s.InputErr.Error(), s.Theme.Default.Foreground(s.Theme.Removed).Bold(true))
+		y++
+		if s.InputErr.Head != "" {
+			line("The input starts with:", s.Theme.Dim)
+			for _, l := range strings.Split(s.InputErr.Head, "\n") {
+				line("  "+l, s.Theme.Default)
+			}
+			y++
+		}
+		line("Pipe a unified diff into wiff, such as git diff | wiff. q quits.", s.Theme.Dim)
+	case s.NoRepo:
+		line("Not in a git, jj or hg repository", title)
+		y++
+		line("wiff a.txt b.txt          compare two files", s.Theme.Default)
+		line("git diff | wiff           view a diff from a pipe", s.Theme.Default)
+		line("diff -ru old new | wiff   or from any other tool", s.Theme.Default)
+		y++
+		line("q quits.", s.Theme.Dim)
+	case s.PipeMode:
+		line("No changes in the input", title)
+	default:
+		msg := "No changes: " + s.RefDisplay()
+		if s.Branch != "" {
+			msg += " on ⎇ " + s.Branch
+		}
+		line(msg, title)
+		if s.HiddenFiles > 0 {
+			line(fmt.Sprintf("(%d files hidden by excludes)", s.HiddenFiles), s.Theme.Dim)
+		}
+		y++
+		line("go opens another diff: the last commit, staged changes or a", s.Theme.Dim)
+		line("recent commit. Or run wiff <ref>, wiff --staged, wiff a..b.", s.Theme.Dim)
+	}
+}
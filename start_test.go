@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestStartMenu(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for i, text := range []string{"one\n", "two\n"} {
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "a.txt")
+		git("commit", "-qm", []string{"first", "second"}[i])
+	}
+
+	s := &State{ContextLines: 3}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Hunks) != 0 || s.NoRepo {
+		t.Fatalf("clean tree: %d hunks, NoRepo %v", len(s.Hunks), s.NoRepo)
+	}
+	OpenStartMenu(s)
+	p := s.Popup
+	if p == nil || len(p.Items) != 4 {
+		t.Fatalf("start menu = %+v, want HEAD~1, --staged and two commits", p)
+	}
+	if !strings.HasPrefix(p.Items[0], "HEAD~1") || !strings.HasPrefix(p.Items[1], "--staged") || !strings.HasSuffix(p.Items[2], "second") {
+		t.Errorf("items = %q", p.Items)
+	}
+
+	// 1 opens the last commit in place
+	HandlePopupKey(s, tcell.NewEventKey(tcell.KeyRune, '1', tcell.ModNone))
+	if s.Popup != nil || len(s.Refs) != 1 || s.Refs[0] != "HEAD~1" || len(s.Hunks) != 1 {
+		t.Errorf("after 1: refs %v, %d hunks", s.Refs, len(s.Hunks))
+	}
+}
+
+func TestNoRepo(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(t.TempDir()))
+	s := &State{ContextLines: 3}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.NoRepo {
+		t.Fatal("NoRepo not set outside a repository")
+	}
+	OpenStartMenu(s)
+	if s.Popup != nil {
+		t.Error("start menu opened outside a repository")
+	}
+}
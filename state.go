@@ -1,98 +1,203 @@
 package main
 
 import (
-	"os"
-	"os/exec"
-	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/h0rv/wiff/pkg/wiff"
 )
 
 // State holds the application state
 type State struct {
-	Refs         []string
-	Staged       bool
-	Hunks        []Hunk
-	Scroll       int
-	Height       int
-	Width        int
-	PendingKey   rune
-	PendingLabel string // accumulated label chars for multi-char yank
-	PendingTime  time.Time
-	Screen       tcell.Screen
-	Lines        []DisplayLine
-	PipeMode     bool
-	SideBySide   bool
-	LineNumbers  bool
-	ContextLines int
-	Wrap         bool
-	ScrollX      int
-	WatchEnabled bool
+	Refs          []string
+	Paths         []string // limit the diff to these paths (wiff <ref> -- <path>...)
+	Staged        bool
+	Hunks         []Hunk
+	Scroll        int
+	Height        int
+	Width         int
+	PendingKey    rune
+	PendingLabel  string // accumulated label chars for multi-char yank
+	PendingTime   time.Time
+	Screen        tcell.Screen
+	Lines         []DisplayLine
+	PipeMode      bool
+	Series        *Series           // patch series under review (mbox or format-patch dir), nil otherwise
+	InputErr      *InputError       // piped input that isn't a diff, shown instead of one
+	NoRepo        bool              // run outside any repository, with nothing to diff
+	Strict        bool              // --strict: fail on piped input that isn't a diff
+	NoIndex       bool              // Refs are two plain files diffed with the built-in engine
+	DiffAlgorithm string            // --diff-algorithm for git and the built-in engine; "" = myers
+	FindRenames   string            // --find-renames for git: "" off, "on", or a threshold like "50%"
+	Excludes      []string          // path patterns pruned from the view (see matchExclude)
+	HiddenFiles   int               // files dropped by Excludes in the last load
+	Generated     map[string]bool   // generated files, collapsed until expanded
+	Textconv      map[string]string // files shown through a textconv driver, to the driver
+	Conflicts     map[string]string // unmerged files of a merge in progress, to conflictOpen or conflictRerere
+	Notes         Notes             // notes on lines from the repository's notes file
+	Coverage      Coverage          // --coverage report, nil without one
+	Covered       Coverage          // Coverage of the diff's files, by their paths in the diff
+	IndexSplit    *IndexSplit       // staged and unstaged lines of a ref-to-worktree diff, nil otherwise
+	Diagnostics   []Diagnostic      // --diagnostics file or the diagnostics command's issues
+	DiagLines     LineDiagnostics   // Diagnostics on the diff's files
+	Check         *checkRun         // last run of the check command (&), nil before one
+	CheckPanel    bool              // the check panel is showing above the status bar
+	Assistant     *assistantRun     // run shown in the assistant panel (I), nil when it is closed
+	SideBySide    bool
+	LineNumbers   bool
+	ContextLines  int
+	Wrap          bool
+	ScrollX       int
+	ScrollXRight  int  // right column offset in side-by-side when UnlinkScroll
+	LineNoDigits  int  // digits in the widest line number on display
+	UnlinkScroll  bool // side-by-side columns scroll horizontally on their own
+	WatchEnabled  bool
 
 	Theme UITheme
 
 	SyntaxHighlight bool
 	HL              *Highlighter
 
-	SearchMode    bool   // true when typing a search query
+	SearchMode    bool   // true while the search prompt is open
 	SearchQuery   string // current search text
 	SearchMatches []int  // line indices that match
 	SearchIdx     int    // current match index (-1 if none)
+	SearchCase    int    // searchSmartCase, searchIgnoreCase or searchMatchCase
+	SearchWord    bool   // match whole words only
+	searchMemo    searchMemo
 
-	TreeOpen    bool
-	TreeFiles   []TreeFile
-	TreeNodes   []TreeNode // hierarchical tree for display
-	TreeFocused bool
-	TreeCursor  int
-	TreeScroll  int
-	FilterFile  string // when set, only show hunks for this file
-	DiffX       int    // starting column for diff content (after tree sidebar)
-	DiffWidth   int    // available width for diff content
-	LabelGutter int    // dynamic gutter width: max label chars + 3 (" │ ")
+	Replace *Replace // active search-and-replace preview, nil if none
+
+	PipeCmd string // last command hunks were piped through
+
+	todoRe *regexp.Regexp // compiled todo_markers, see todoPattern
+
+	Tabs *Tabs // the session's tabs when more than one diff was opened
+
+	Split *Split // the split s is a pane of, nil when it has the whole screen
+
+	FileSizes map[string]FileSize // old/new sizes of changed files, see loadFileSizes
+
+	TreeOpen       bool
+	TreeFiles      []TreeFile
+	TreeNodes      []TreeNode // hierarchical tree for display
+	TreeSort       int        // tree sort mode (treeSortPath, ...)
+	TreeQuery      string     // tree search query; narrows TreeNodes
+	TreeSearchMode bool       // true while typing TreeQuery
+	TreeFocused    bool
+	TreeCursor     int
+	TreeScroll     int
+	FilterFile     string // when set, only show hunks for this file
+	IndexFilter    int    // indexFilterAll, or show only hunks with staged or unstaged changes
+	DiffX          int    // starting column for diff content (after tree sidebar)
+	DiffWidth      int    // available width for diff content
+	LabelGutter    int    // dynamic gutter width: max label chars + 3 (" │ ")
+	LabelPlacement int    // labelsGutter, labelsHeader or labelsHidden
+
+	Hover       mouseHover // what the mouse pointer is over
+	ColumnRatio int        // percent of the side-by-side width for the old column; 0 = even
+	ChangesOnly bool       // hide context lines, showing only added and removed ones (gc)
+
+	Zen        bool    // zen mode: only the diff, for screen sharing
+	zenRestore zenView // view settings put back when zen mode ends
+
+	// Review mode: one file at a time, ]f marks it done (see review.go)
+	Review     bool
+	ReviewDone map[string]string // files marked done, to their fileDigest then
+
+	Marks map[rune]Mark // m{a-z} bookmarks, and ' for the spot before the last jump to one
 
 	DiffBg bool // subtle background tints on added/removed lines
 
-	FullFile     bool   // full-file view mode
-	FullFileName string // file being viewed in full-file mode
+	LowBandwidth      bool      // slow-link mode, see SetLowBandwidth
+	lowBandwidthSaved [2]bool   // DiffBg and SyntaxHighlight to restore
+	lastReload        time.Time // last watch reload, for reloadThrottled
+	reloadQueued      bool      // a throttled reload is posted for later
+
+	Debug bool       // --debug: show the render/build/watch overlay
+	stats debugStats // numbers for the debug overlay
+	rows  rowCache   // diff rows of the last frame, see drawRow
+
+	FullFile       bool   // full-file view mode
+	FullFileName   string // file being viewed in full-file mode
+	FullFileOld    bool   // inline full-file mode shows the old version
+	FullFileRev    string // revision inline full-file mode shows when FullFileRevSet
+	FullFileRevSet bool   // FullFileRev overrides the diff side being viewed
+
+	// FullFilePair makes side-by-side full-file mode compare the whole file
+	// at FullFilePairRevs (left, right) instead of the diff's two sides.
+	FullFilePair     bool
+	FullFilePairRevs [2]string
 
 	FollowMode bool // auto-scroll to new changes on watch reload
 
-	ShowHelp    bool
+	Expanded      map[string]bool // collapsed files the user expanded, kept across reloads
+	ExpandedHunks map[string]bool // truncated hunks the user expanded, by hunkKey
+
+	ColorMoved bool // color moved lines distinctly (see markMovedLines)
+
+	ConfirmApply bool // show the patch and git command before staging (--confirm-apply)
+
+	Config   Config
+	Branch   string    // current branch, refreshed on every (re)load
+	Tracking *Tracking // the branch against its upstream, refreshed with Branch
+
+	// Anchor is the display line the last jump targeted. It stands in for
+	// the top row as the "current" position while the view hasn't scrolled
+	// since, so centered or clamped jumps still navigate relative to it.
+	Anchor       int
+	anchorScroll int
+	anchorSet    bool
+
+	CursorMode bool // j/k move a highlighted cursor line instead of scrolling
+	Cursor     int  // display line index of the cursor
+	RowNav     bool // j/k step over wrapped display rows instead of logical lines
+
+	LastAction     HunkAction        // last label-targeted hunk command, for '.'
+	Macro          []*tcell.EventKey // recorded keystrokes, replayed with '@'
+	MacroRecording bool              // true while Q is recording
+	replayingMacro bool
+
+	Popup  *Popup       // active picker or panel, nil when none
+	Prompt *InputPrompt // input open on the row above the status bar, nil when none
+
+	ShowHelp   bool
+	HelpScroll int    // first line of the help pane shown
+	HelpQuery  string // help pane filter
+	HelpSearch bool   // true while typing the help pane filter
+
 	FlashMsg    string
 	FlashExpiry time.Time
 }
 
-// HalfLine represents one side of a side-by-side display
-type HalfLine struct {
-	Text   string
-	Style  LineStyle
-	LineNo int
+// HunkAction is a repeatable hunk command: Cmd is the key that triggered it
+// (y, Y, p, c, A or a) and On records the resulting state of a toggling
+// command (staged for A, applied for a).
+type HunkAction struct {
+	Cmd rune
+	On  bool
 }
 
-// DisplayLine represents a rendered line
-type DisplayLine struct {
-	Text         string
-	Style        LineStyle
-	Label        string // hunk label (a, b, c...)
-	HunkIdx      int    // -1 if not a hunk line
-	OldLineNo    int    // old file line number (0 = none)
-	NewLineNo    int    // new file line number (0 = none)
-	Continuation bool   // wrapped continuation of previous line
-	Left         HalfLine
-	Right        HalfLine
-}
-
-type LineStyle int
+// Display lines come from the view model in pkg/wiff.
+type (
+	HalfLine    = wiff.HalfLine
+	Span        = wiff.Span
+	DisplayLine = wiff.DisplayLine
+	LineStyle   = wiff.LineStyle
+)
 
 const (
-	StyleNormal LineStyle = iota
-	StyleFileHeader
-	StyleHunkHeader
-	StyleAdded
-	StyleRemoved
-	StyleContext
+	StyleNormal     = wiff.StyleNormal
+	StyleFileHeader = wiff.StyleFileHeader
+	StyleHunkHeader = wiff.StyleHunkHeader
+	StyleAdded      = wiff.StyleAdded
+	StyleRemoved    = wiff.StyleRemoved
+	StyleContext    = wiff.StyleContext
+	StyleCollapsed  = wiff.StyleCollapsed
+	StyleReplaced   = wiff.StyleReplaced
 )
 
 // updateLayout computes DiffX and DiffWidth based on tree state
@@ -104,6 +209,12 @@ func (s *State) updateLayout() {
 		s.DiffX = 0
 		s.DiffWidth = s.Width
 	}
+	if w := s.assistantPanelWidth(); w > 0 {
+		s.DiffWidth -= w + 1 // +1 for divider
+	}
+	if s.Config.Scrollbar {
+		s.DiffWidth-- // reserve the rightmost column for the scrollbar
+	}
 	if s.DiffWidth < 1 {
 		s.DiffWidth = 1
 	}
@@ -122,39 +233,49 @@ func (s *State) maxLabelWidth() int {
 }
 
 // computeLabelGutter sets LabelGutter based on the widest hunk label.
-// The gutter consists of the label text plus the " │ " separator (3 chars).
+// The gutter consists of the label text plus the " │ " separator (3 chars);
+// labels placed on hunk headers or hidden leave only the separator, and
+// zen mode has no gutter.
 func (s *State) computeLabelGutter() {
-	s.LabelGutter = s.maxLabelWidth() + 3 // label + " │ "
+	if s.Zen {
+		s.LabelGutter = 0
+		return
+	}
+	s.LabelGutter = s.gutterLabelWidth() + 3 // label + " │ "
 }
 
 // BuildLines creates display lines from hunks
 func (s *State) BuildLines() {
+	start := time.Now()
+	defer func() {
+		s.stats.builds++
+		s.stats.lines = len(s.Lines)
+		s.stats.buildTime = time.Since(start)
+	}()
 	s.updateLayout()
 	s.computeLabelGutter()
 	// Reset all StartLine to prevent stale values when switching views
 	for i := range s.Hunks {
 		s.Hunks[i].StartLine = -1
 	}
-	if s.FullFile && s.FullFileName != "" {
-		if s.SideBySide {
-			s.buildFullFileSideBySideLines()
-			if s.Wrap {
-				s.wrapSideBySideLines()
-			}
-		} else {
-			s.buildFullFileLines()
-			if s.Wrap {
-				s.wrapLines()
-			}
-		}
-	} else if s.SideBySide {
+	switch {
+	case s.FullFile && s.FullFileName != "" && s.SideBySide:
+		s.buildFullFileSideBySideLines()
+	case s.FullFile && s.FullFileName != "":
+		s.buildFullFileLines()
+	case s.SideBySide:
 		s.buildSideBySideLines()
-		if s.Wrap {
-			s.wrapSideBySideLines()
-		}
-	} else {
+	default:
 		s.buildInlineLines()
-		if s.Wrap {
+	}
+	s.addReplacePreview()
+	s.addHeaderCounts()
+	// Line number width is known before wrapping, which depends on it
+	s.computeLineNoDigits()
+	if s.Wrap {
+		if s.SideBySide {
+			s.wrapSideBySideLines()
+		} else {
 			s.wrapLines()
 		}
 	}
@@ -162,90 +283,85 @@ func (s *State) BuildLines() {
 	if s.SearchQuery != "" {
 		UpdateMatches(s)
 	}
+	s.clampCursor()
 }
 
-// sideBySideColWidth returns the character width available for each column
-// in side-by-side mode (including the op prefix character).
-func (s *State) sideBySideColWidth() int {
+// addHeaderCounts fills in the added/removed counts of hunk headers and
+// file headers (the sum over all of the file's hunks, shown or collapsed).
+func (s *State) addHeaderCounts() {
+	type counts struct{ added, removed int }
+	files := make(map[string]counts)
+	hunks := make([]counts, len(s.Hunks))
+	for i := range s.Hunks {
+		a, r := s.Hunks[i].Counts()
+		hunks[i] = counts{a, r}
+		f := files[s.Hunks[i].File]
+		files[s.Hunks[i].File] = counts{f.added + a, f.removed + r}
+	}
+	for i := range s.Lines {
+		l := &s.Lines[i]
+		switch l.Style {
+		case StyleFileHeader:
+			l.Added, l.Removed = files[l.Text].added, files[l.Text].removed
+		case StyleHunkHeader:
+			if l.HunkIdx >= 0 && l.HunkIdx < len(hunks) {
+				l.Added, l.Removed = hunks[l.HunkIdx].added, hunks[l.HunkIdx].removed
+			}
+		}
+	}
+}
+
+// minLineNoDigits is the narrowest line number column. It widens for files
+// with longer line numbers.
+const minLineNoDigits = 4
+
+// computeLineNoDigits sizes the line number column for the largest line
+// number on display.
+func (s *State) computeLineNoDigits() {
+	largest := 0
+	for _, l := range s.Lines {
+		largest = max(largest, l.OldLineNo, l.NewLineNo, l.Left.LineNo, l.Right.LineNo)
+	}
+	s.LineNoDigits = len(strconv.Itoa(largest))
+}
+
+// lineNoWidth returns the width of one line number column, including the
+// trailing space.
+func (s *State) lineNoWidth() int {
+	return max(s.LineNoDigits, minLineNoDigits) + 1
+}
+
+// inlineLineNoWidth returns the width of the inline line number gutter,
+// which holds two columns when both old and new numbers are shown.
+func (s *State) inlineLineNoWidth() int {
+	if s.Config.BothLineNumbers {
+		return 2 * s.lineNoWidth()
+	}
+	return s.lineNoWidth()
+}
+
+// sideBySideTextWidths returns the character width available for the text
+// of the old and new columns in side-by-side mode (including the op prefix
+// character).
+func (s *State) sideBySideTextWidths() (left, right int) {
 	lnoExtra := 0
 	if s.LineNumbers {
-		lnoExtra = lineNoWidth
-	}
-	colWidth := (s.DiffWidth - s.LabelGutter - 1) / 2
-	tw := colWidth - lnoExtra
-	if tw < 1 {
-		tw = 1
+		lnoExtra = s.lineNoWidth()
 	}
-	return tw
+	left, right = s.sideBySideCols()
+	return max(left-lnoExtra, 1), max(right-lnoExtra, 1)
 }
 
 // wrapSideBySideLines splits long half-lines into continuation DisplayLines
 func (s *State) wrapSideBySideLines() {
-	tw := s.sideBySideColWidth()
-	var wrapped []DisplayLine
-	for _, line := range s.Lines {
-		if line.Style == StyleNormal || line.Style == StyleFileHeader || line.Style == StyleHunkHeader {
-			wrapped = append(wrapped, line)
-			continue
-		}
-
-		leftRunes := []rune(line.Left.Text)
-		rightRunes := []rune(line.Right.Text)
-
-		if len(leftRunes) <= tw && len(rightRunes) <= tw {
-			wrapped = append(wrapped, line)
-			continue
-		}
+	left, right := s.sideBySideTextWidths()
+	s.Lines = wiff.WrapSideBySideCols(s.Lines, left, right)
+	s.syncStartLines()
+}
 
-		// First chunk keeps line numbers
-		lEnd := tw
-		if lEnd > len(leftRunes) {
-			lEnd = len(leftRunes)
-		}
-		rEnd := tw
-		if rEnd > len(rightRunes) {
-			rEnd = len(rightRunes)
-		}
-		wrapped = append(wrapped, DisplayLine{
-			Style:   line.Style,
-			Label:   line.Label,
-			HunkIdx: line.HunkIdx,
-			Left:    HalfLine{Text: string(leftRunes[:lEnd]), Style: line.Left.Style, LineNo: line.Left.LineNo},
-			Right:   HalfLine{Text: string(rightRunes[:rEnd]), Style: line.Right.Style, LineNo: line.Right.LineNo},
-		})
-		leftRunes = leftRunes[lEnd:]
-		rightRunes = rightRunes[rEnd:]
-
-		// Continuation lines
-		for len(leftRunes) > 0 || len(rightRunes) > 0 {
-			var lText, rText string
-			if len(leftRunes) > 0 {
-				end := tw
-				if end > len(leftRunes) {
-					end = len(leftRunes)
-				}
-				lText = string(leftRunes[:end])
-				leftRunes = leftRunes[end:]
-			}
-			if len(rightRunes) > 0 {
-				end := tw
-				if end > len(rightRunes) {
-					end = len(rightRunes)
-				}
-				rText = string(rightRunes[:end])
-				rightRunes = rightRunes[end:]
-			}
-			wrapped = append(wrapped, DisplayLine{
-				Style:        line.Style,
-				HunkIdx:      line.HunkIdx,
-				Continuation: true,
-				Left:         HalfLine{Text: lText, Style: line.Left.Style},
-				Right:        HalfLine{Text: rText, Style: line.Right.Style},
-			})
-		}
-	}
-	s.Lines = wrapped
-	// Fix StartLine values after wrapping shifted indices
+// syncStartLines points each hunk's StartLine at its header again after
+// rows were inserted into s.Lines.
+func (s *State) syncStartLines() {
 	for i, line := range s.Lines {
 		if line.Style == StyleHunkHeader && line.Label != "" {
 			if h := s.HunkByLabel(line.Label); h != nil {
@@ -259,7 +375,7 @@ func (s *State) wrapSideBySideLines() {
 func (s *State) textWidth() int {
 	w := s.DiffWidth - s.LabelGutter
 	if s.LineNumbers {
-		w -= lineNoWidth
+		w -= s.inlineLineNoWidth()
 	}
 	if w < 1 {
 		w = 1
@@ -269,62 +385,20 @@ func (s *State) textWidth() int {
 
 // wrapLines splits long lines into continuation DisplayLines
 func (s *State) wrapLines() {
-	tw := s.textWidth()
-	var wrapped []DisplayLine
-	for _, line := range s.Lines {
-		// Don't wrap non-content lines
-		if line.Style == StyleNormal || line.Style == StyleFileHeader || line.Style == StyleHunkHeader {
-			wrapped = append(wrapped, line)
-			continue
-		}
-		runes := []rune(line.Text)
-		if len(runes) <= tw {
-			wrapped = append(wrapped, line)
-			continue
-		}
-		// First chunk keeps line numbers
-		wrapped = append(wrapped, DisplayLine{
-			Text:      string(runes[:tw]),
-			Style:     line.Style,
-			HunkIdx:   line.HunkIdx,
-			OldLineNo: line.OldLineNo,
-			NewLineNo: line.NewLineNo,
-		})
-		runes = runes[tw:]
-		for len(runes) > 0 {
-			end := tw
-			if end > len(runes) {
-				end = len(runes)
-			}
-			wrapped = append(wrapped, DisplayLine{
-				Text:         string(runes[:end]),
-				Style:        line.Style,
-				HunkIdx:      line.HunkIdx,
-				Continuation: true,
-			})
-			runes = runes[end:]
-		}
-	}
-	s.Lines = wrapped
-	// Fix StartLine values after wrapping shifted indices
-	for i, line := range s.Lines {
-		if line.Style == StyleHunkHeader && line.Label != "" {
-			if h := s.HunkByLabel(line.Label); h != nil {
-				h.StartLine = i
-			}
-		}
-	}
+	s.Lines = wiff.Wrap(s.Lines, s.textWidth())
+	s.syncStartLines()
 }
 
 func (s *State) buildInlineLines() {
 	var lines []DisplayLine
 	var currentFile string
+	collapsed := s.collapsedFiles()
 
 	for i := range s.Hunks {
 		h := &s.Hunks[i]
 
 		// Skip hunks not matching the filter
-		if s.FilterFile != "" && h.File != s.FilterFile {
+		if (s.FilterFile != "" && h.File != s.FilterFile) || !s.indexFilterShows(h) {
 			h.StartLine = -1
 			continue
 		}
@@ -341,6 +415,11 @@ func (s *State) buildInlineLines() {
 			currentFile = h.File
 		}
 
+		var skip bool
+		if lines, skip = s.appendCollapsed(lines, collapsed, i); skip {
+			continue
+		}
+
 		// Blank line before hunk
 		lines = append(lines, DisplayLine{Style: StyleNormal})
 
@@ -355,34 +434,14 @@ func (s *State) buildInlineLines() {
 			HunkIdx: i,
 		})
 
-		// Diff lines with line number tracking
-		oldNo := h.OldStart
-		newNo := h.NewStart
-		for _, dl := range h.Lines {
-			style := StyleContext
-			var oln, nln int
-			switch dl.Op {
-			case '+':
-				style = StyleAdded
-				nln = newNo
-				newNo++
-			case '-':
-				style = StyleRemoved
-				oln = oldNo
-				oldNo++
-			default:
-				oln = oldNo
-				nln = newNo
-				oldNo++
-				newNo++
-			}
-			lines = append(lines, DisplayLine{
-				Text:      string(dl.Op) + dl.Content,
-				Style:     style,
-				HunkIdx:   i,
-				OldLineNo: oln,
-				NewLineNo: nln,
-			})
+		if !s.ChangesOnly {
+			lines = append(lines, s.expandedLines(i, true)...)
+		}
+
+		rows, cut := s.truncateRows(s.changedRows(wiff.HunkLines(h, i)), i)
+		lines = append(lines, rows...)
+		if !cut && !s.ChangesOnly {
+			lines = append(lines, s.expandedLines(i, false)...)
 		}
 	}
 
@@ -390,22 +449,24 @@ func (s *State) buildInlineLines() {
 }
 
 func (s *State) buildFullFileLines() {
-	// Read the NEW version of the file from disk
-	root, err := gitRoot()
-	if err != nil {
+	newLines, oldLines, ok := s.fullFileSides(s.FullFileName)
+	if !ok {
+		s.Lines = nil
 		return
 	}
-	path := filepath.Join(root, s.FullFileName)
-	content, err := os.ReadFile(path)
-	if err != nil {
-		// File might be deleted, try git show
-		content, _ = exec.Command("git", "show", "HEAD:"+s.FullFileName).Output()
-		if content == nil {
-			return
-		}
+	// The unchanged lines between hunks come from the side being viewed
+	fileLines := newLines
+	if s.FullFileOld {
+		fileLines = oldLines
+	}
+	// A revision other than the diff sides is shown with the hunks placed
+	// where their new-side lines land in it
+	var align []int
+	revNo := 1
+	if s.FullFileRevSet {
+		fileLines = fileLinesAt(s.FullFileRev, s.FullFileName)
+		align = alignLines(newLines, fileLines, s.DiffAlgorithm)
 	}
-
-	fileLines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
 
 	// Collect hunks for this file and find the first hunk index (for syntax highlighting)
 	type indexedHunk struct {
@@ -438,21 +499,38 @@ func (s *State) buildFullFileLines() {
 	newLineNo := 1 // current position in the new file (1-based)
 	oldLineNo := 1 // tracking old file line numbers
 
+	// pos returns the current position in fileLines
+	pos := func() int {
+		if align != nil {
+			return revNo
+		}
+		if s.FullFileOld {
+			return oldLineNo
+		}
+		return newLineNo
+	}
+
 	for _, fh := range fileHunks {
 		h := fh.hunk
 		hIdx := fh.globalIdx
 
+		oldCount, newCount := hunkCounts(h)
+		oldTop, _ := hunkSpan(h.OldStart, oldCount)
+		newTop, _ := hunkSpan(h.NewStart, newCount)
+		top := newTop
+		switch {
+		case align != nil:
+			top = alignedLine(align, newTop)
+		case s.FullFileOld:
+			top = oldTop
+		}
+
 		// Emit context lines from current position up to this hunk
-		for newLineNo < h.NewStart && newLineNo-1 < len(fileLines) {
-			lines = append(lines, DisplayLine{
-				Text:      " " + fileLines[newLineNo-1],
-				Style:     StyleContext,
-				HunkIdx:   contextHunkIdx,
-				OldLineNo: oldLineNo,
-				NewLineNo: newLineNo,
-			})
+		for pos() < top && pos()-1 < len(fileLines) {
+			lines = append(lines, s.fullFileContext(fileLines, align, contextHunkIdx, oldLineNo, newLineNo, revNo))
 			newLineNo++
 			oldLineNo++
+			revNo++
 		}
 
 		// Blank line before hunk
@@ -469,9 +547,9 @@ func (s *State) buildFullFileLines() {
 			HunkIdx: hIdx,
 		})
 
-		// Walk hunk lines
-		hunkOldNo := h.OldStart
-		hunkNewNo := h.NewStart
+		// Walk hunk lines (an empty side resumes after its start line)
+		hunkOldNo := oldTop
+		hunkNewNo := newTop
 		for _, dl := range h.Lines {
 			switch dl.Op {
 			case ' ':
@@ -490,6 +568,7 @@ func (s *State) buildFullFileLines() {
 					Style:     StyleAdded,
 					HunkIdx:   hIdx,
 					NewLineNo: hunkNewNo,
+					Moved:     dl.Moved,
 				})
 				hunkNewNo++
 			case '-':
@@ -498,6 +577,7 @@ func (s *State) buildFullFileLines() {
 					Style:     StyleRemoved,
 					HunkIdx:   hIdx,
 					OldLineNo: hunkOldNo,
+					Moved:     dl.Moved,
 				})
 				hunkOldNo++
 			}
@@ -509,24 +589,49 @@ func (s *State) buildFullFileLines() {
 		// Update position tracking
 		newLineNo = hunkNewNo
 		oldLineNo = hunkOldNo
+		if align != nil {
+			if next := alignedLine(align, hunkNewNo); next > revNo {
+				revNo = next
+			}
+		}
 	}
 
 	// Emit remaining file lines after the last hunk
-	for newLineNo-1 < len(fileLines) {
-		lines = append(lines, DisplayLine{
-			Text:      " " + fileLines[newLineNo-1],
-			Style:     StyleContext,
-			HunkIdx:   contextHunkIdx,
-			OldLineNo: oldLineNo,
-			NewLineNo: newLineNo,
-		})
+	for pos()-1 < len(fileLines) {
+		lines = append(lines, s.fullFileContext(fileLines, align, contextHunkIdx, oldLineNo, newLineNo, revNo))
 		newLineNo++
 		oldLineNo++
+		revNo++
 	}
 
 	s.Lines = lines
 }
 
+// fullFileContext returns the unchanged line at the current position of the
+// inline full-file view. Lines of a revision other than the diff sides are
+// numbered in that revision only.
+func (s *State) fullFileContext(fileLines []string, align []int, hunkIdx, oldNo, newNo, revNo int) DisplayLine {
+	if align != nil {
+		return DisplayLine{
+			Text:      " " + fileLines[revNo-1],
+			Style:     StyleContext,
+			HunkIdx:   hunkIdx,
+			NewLineNo: revNo,
+		}
+	}
+	pos := newNo
+	if s.FullFileOld {
+		pos = oldNo
+	}
+	return DisplayLine{
+		Text:      " " + fileLines[pos-1],
+		Style:     StyleContext,
+		HunkIdx:   hunkIdx,
+		OldLineNo: oldNo,
+		NewLineNo: newNo,
+	}
+}
+
 // reconstructOldFile derives the old file content from the new file and diff hunks.
 // This is reliable because we always have the new file and the hunk data.
 func (s *State) reconstructOldFile(filename string, newLines []string) []string {
@@ -572,40 +677,53 @@ func (s *State) reconstructOldFile(filename string, newLines []string) []string
 	return old
 }
 
-// readNewFile returns the new version of a file as lines.
-func (s *State) readNewFile(filename string) []string {
-	if s.Staged {
-		out, _ := exec.Command("git", "show", ":"+filename).Output()
-		if out == nil {
-			return nil
+// fullFileSides returns both versions of a file for full-file mode. The new
+// side is read from wherever the diff's new side comes from (working tree,
+// index or a ref); the old side is reconstructed from it and the hunks. A
+// deleted file has no new lines. ok is false when the file can't be read.
+func (s *State) fullFileSides(filename string) (newLines, oldLines []string, ok bool) {
+	_, newRev := s.sideRevs()
+	if s.PipeMode {
+		newRev = revWorktree
+	}
+	content, err := fileAt(newRev, filename)
+	switch {
+	case err == nil:
+		if text := strings.TrimRight(string(content), "\n"); text != "" {
+			newLines = strings.Split(text, "\n")
 		}
-		return strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	case !s.fileDeleted(filename):
+		return nil, nil, false
 	}
-	if len(s.Refs) >= 2 {
-		out, _ := exec.Command("git", "show", s.Refs[1]+":"+filename).Output()
-		if out == nil {
-			return nil
+	return newLines, s.reconstructOldFile(filename, newLines), true
+}
+
+// fileDeleted reports whether the diff removes filename entirely.
+func (s *State) fileDeleted(filename string) bool {
+	found := false
+	for i := range s.Hunks {
+		h := &s.Hunks[i]
+		if h.File != filename {
+			continue
 		}
-		return strings.Split(strings.TrimRight(string(out), "\n"), "\n")
-	}
-	// working tree
-	root, err := gitRoot()
-	if err != nil {
-		return nil
-	}
-	content, err := os.ReadFile(filepath.Join(root, filename))
-	if err != nil {
-		return nil
+		if _, newCount := hunkCounts(h); h.NewStart != 0 || newCount != 0 {
+			return false
+		}
+		found = true
 	}
-	return strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	return found
 }
 
 func (s *State) buildFullFileSideBySideLines() {
-	newLines := s.readNewFile(s.FullFileName)
-	if newLines == nil {
+	if s.FullFilePair {
+		s.buildFullFilePairLines()
+		return
+	}
+	newLines, oldLines, ok := s.fullFileSides(s.FullFileName)
+	if !ok {
+		s.Lines = nil
 		return
 	}
-	oldLines := s.reconstructOldFile(s.FullFileName, newLines)
 
 	// Collect hunks for this file
 	type indexedHunk struct {
@@ -668,72 +786,10 @@ func (s *State) buildFullFileSideBySideLines() {
 			HunkIdx: hIdx,
 		})
 
-		// Walk hunk lines with remove/add pairing
-		hunkOldNo := h.OldStart
-		hunkNewNo := h.NewStart
-		j := 0
-		for j < len(h.Lines) {
-			dl := h.Lines[j]
-
-			if dl.Op == ' ' {
-				lines = append(lines, DisplayLine{
-					Style:   StyleContext,
-					HunkIdx: hIdx,
-					Left:    HalfLine{Text: " " + dl.Content, Style: StyleContext, LineNo: hunkOldNo},
-					Right:   HalfLine{Text: " " + dl.Content, Style: StyleContext, LineNo: hunkNewNo},
-				})
-				hunkOldNo++
-				hunkNewNo++
-				j++
-				continue
-			}
-
-			// Collect consecutive removes
-			var removes []Line
-			var removeNos []int
-			for j < len(h.Lines) && h.Lines[j].Op == '-' {
-				removes = append(removes, h.Lines[j])
-				removeNos = append(removeNos, hunkOldNo)
-				hunkOldNo++
-				j++
-			}
-			// Collect consecutive adds
-			var adds []Line
-			var addNos []int
-			for j < len(h.Lines) && h.Lines[j].Op == '+' {
-				adds = append(adds, h.Lines[j])
-				addNos = append(addNos, hunkNewNo)
-				hunkNewNo++
-				j++
-			}
-
-			// Pair removes and adds
-			maxLen := len(removes)
-			if len(adds) > maxLen {
-				maxLen = len(adds)
-			}
-			for k := 0; k < maxLen; k++ {
-				var left, right HalfLine
-				if k < len(removes) {
-					left = HalfLine{Text: "-" + removes[k].Content, Style: StyleRemoved, LineNo: removeNos[k]}
-				}
-				if k < len(adds) {
-					right = HalfLine{Text: "+" + adds[k].Content, Style: StyleAdded, LineNo: addNos[k]}
-				}
-				lineStyle := StyleContext
-				if left.Text != "" {
-					lineStyle = StyleRemoved
-				} else if right.Text != "" {
-					lineStyle = StyleAdded
-				}
-				lines = append(lines, DisplayLine{
-					Style:   lineStyle,
-					HunkIdx: hIdx,
-					Left:    left,
-					Right:   right,
-				})
-			}
-		}
+		lines = append(lines, wiff.HunkSideBySideLines(h, hIdx)...)
+		oldCount, newCount := hunkCounts(h)
+		hunkOldNo := h.OldStart + oldCount
+		hunkNewNo := h.NewStart + newCount
 
 		// Blank after hunk
 		lines = append(lines, DisplayLine{Style: StyleNormal})
@@ -763,15 +819,96 @@ func (s *State) buildFullFileSideBySideLines() {
 	s.Lines = lines
 }
 
+// buildFullFilePairLines compares the whole file at the two revisions of
+// FullFilePairRevs side by side. Both columns are read from their revision;
+// the lines the diff algorithm keeps line up and each changed run pairs its
+// removed lines with its added ones. The diff's hunks start at their first
+// line when a column shows the diff side they belong to.
+func (s *State) buildFullFilePairLines() {
+	leftRev, rightRev := s.FullFilePairRevs[0], s.FullFilePairRevs[1]
+	left := fileLinesAt(leftRev, s.FullFileName)
+	right := fileLinesAt(rightRev, s.FullFileName)
+
+	contextHunkIdx := -1
+	for i := range s.Hunks {
+		if s.Hunks[i].File == s.FullFileName {
+			contextHunkIdx = i
+			break
+		}
+	}
+
+	lines := []DisplayLine{{Text: s.FullFileName, Style: StyleFileHeader}}
+	i, j := 0, 0
+	// changed pairs up the unmatched lines before left[a] and right[b]
+	changed := func(a, b int) {
+		for i < a || j < b {
+			var l, r HalfLine
+			style := StyleAdded
+			if i < a {
+				l = HalfLine{Text: "-" + left[i], Style: StyleRemoved, LineNo: i + 1}
+				style = StyleRemoved
+				i++
+			}
+			if j < b {
+				r = HalfLine{Text: "+" + right[j], Style: StyleAdded, LineNo: j + 1}
+				j++
+			}
+			lines = append(lines, DisplayLine{Style: style, HunkIdx: contextHunkIdx, Left: l, Right: r})
+		}
+	}
+	for _, m := range diffLines(left, right, s.DiffAlgorithm) {
+		changed(m.a, m.b)
+		lines = append(lines, DisplayLine{
+			Style:   StyleContext,
+			HunkIdx: contextHunkIdx,
+			Left:    HalfLine{Text: " " + left[i], Style: StyleContext, LineNo: i + 1},
+			Right:   HalfLine{Text: " " + right[j], Style: StyleContext, LineNo: j + 1},
+		})
+		i++
+		j++
+	}
+	changed(len(left), len(right))
+
+	oldRev, newRev := s.sideRevs()
+	for k := range s.Hunks {
+		h := &s.Hunks[k]
+		if h.File != s.FullFileName {
+			continue
+		}
+		oldCount, newCount := hunkCounts(h)
+		var top int
+		var lineNo func(DisplayLine) int
+		switch {
+		case rightRev == newRev:
+			top, _ = hunkSpan(h.NewStart, newCount)
+			lineNo = func(dl DisplayLine) int { return dl.Right.LineNo }
+		case leftRev == oldRev:
+			top, _ = hunkSpan(h.OldStart, oldCount)
+			lineNo = func(dl DisplayLine) int { return dl.Left.LineNo }
+		default:
+			continue
+		}
+		for n, dl := range lines {
+			if lineNo(dl) >= top {
+				h.StartLine = n
+				break
+			}
+		}
+	}
+
+	s.Lines = lines
+}
+
 func (s *State) buildSideBySideLines() {
 	var lines []DisplayLine
 	var currentFile string
+	collapsed := s.collapsedFiles()
 
 	for i := range s.Hunks {
 		h := &s.Hunks[i]
 
 		// Skip hunks not matching the filter
-		if s.FilterFile != "" && h.File != s.FilterFile {
+		if (s.FilterFile != "" && h.File != s.FilterFile) || !s.indexFilterShows(h) {
 			h.StartLine = -1
 			continue
 		}
@@ -788,6 +925,11 @@ func (s *State) buildSideBySideLines() {
 			currentFile = h.File
 		}
 
+		var skip bool
+		if lines, skip = s.appendCollapsed(lines, collapsed, i); skip {
+			continue
+		}
+
 		// Blank line before hunk
 		lines = append(lines, DisplayLine{Style: StyleNormal})
 
@@ -802,72 +944,14 @@ func (s *State) buildSideBySideLines() {
 			HunkIdx: i,
 		})
 
-		// Group consecutive removes and adds, emit paired lines
-		oldNo := h.OldStart
-		newNo := h.NewStart
-		j := 0
-		for j < len(h.Lines) {
-			dl := h.Lines[j]
-
-			if dl.Op == ' ' {
-				// Context: same text on both sides
-				lines = append(lines, DisplayLine{
-					Style:   StyleContext,
-					HunkIdx: i,
-					Left:    HalfLine{Text: " " + dl.Content, Style: StyleContext, LineNo: oldNo},
-					Right:   HalfLine{Text: " " + dl.Content, Style: StyleContext, LineNo: newNo},
-				})
-				oldNo++
-				newNo++
-				j++
-				continue
-			}
-
-			// Collect consecutive removes
-			var removes []Line
-			var removeNos []int
-			for j < len(h.Lines) && h.Lines[j].Op == '-' {
-				removes = append(removes, h.Lines[j])
-				removeNos = append(removeNos, oldNo)
-				oldNo++
-				j++
-			}
-			// Collect consecutive adds
-			var adds []Line
-			var addNos []int
-			for j < len(h.Lines) && h.Lines[j].Op == '+' {
-				adds = append(adds, h.Lines[j])
-				addNos = append(addNos, newNo)
-				newNo++
-				j++
-			}
+		if !s.ChangesOnly {
+			lines = append(lines, s.expandedLines(i, true)...)
+		}
 
-			// Pair up removes and adds, pad shorter side
-			maxLen := len(removes)
-			if len(adds) > maxLen {
-				maxLen = len(adds)
-			}
-			for k := 0; k < maxLen; k++ {
-				var left, right HalfLine
-				if k < len(removes) {
-					left = HalfLine{Text: "-" + removes[k].Content, Style: StyleRemoved, LineNo: removeNos[k]}
-				}
-				if k < len(adds) {
-					right = HalfLine{Text: "+" + adds[k].Content, Style: StyleAdded, LineNo: addNos[k]}
-				}
-				lineStyle := StyleContext
-				if left.Text != "" {
-					lineStyle = StyleRemoved
-				} else if right.Text != "" {
-					lineStyle = StyleAdded
-				}
-				lines = append(lines, DisplayLine{
-					Style:   lineStyle,
-					HunkIdx: i,
-					Left:    left,
-					Right:   right,
-				})
-			}
+		rows, cut := s.truncateRows(s.changedRows(wiff.HunkSideBySideLines(h, i)), i)
+		lines = append(lines, rows...)
+		if !cut && !s.ChangesOnly {
+			lines = append(lines, s.expandedLines(i, false)...)
 		}
 	}
 
@@ -886,13 +970,52 @@ func (s *State) ClampScroll() {
 
 // MaxScroll returns the maximum valid scroll position
 func (s *State) MaxScroll() int {
-	visible := s.Height - 1
+	visible := s.viewRows()
 	if len(s.Lines) <= visible {
 		return 0
 	}
 	return len(s.Lines) - visible
 }
 
+// ScrollHorizontal shifts the view sideways by delta columns. In
+// side-by-side mode with unlinked scrolling, right selects the right column
+// (Shift+arrow); otherwise both columns move together.
+func (s *State) ScrollHorizontal(delta int, right bool) {
+	if s.Wrap && !s.SideBySide {
+		return
+	}
+	if s.SideBySide && s.UnlinkScroll && right {
+		s.ScrollXRight = max(s.ScrollXRight+delta, 0)
+		return
+	}
+	s.ScrollX = max(s.ScrollX+delta, 0)
+	if !s.UnlinkScroll {
+		s.ScrollXRight = s.ScrollX
+	}
+}
+
+// RightScrollX returns the horizontal offset of the side-by-side right column.
+func (s *State) RightScrollX() int {
+	if s.UnlinkScroll {
+		return s.ScrollXRight
+	}
+	return s.ScrollX
+}
+
+// ToggleLinkedScroll switches side-by-side columns between scrolling
+// horizontally together and independently. Relinking aligns the right
+// column with the left.
+func (s *State) ToggleLinkedScroll() {
+	s.UnlinkScroll = !s.UnlinkScroll
+	s.ScrollXRight = s.ScrollX
+	if s.UnlinkScroll {
+		s.FlashMsg = "Columns scroll independently (Shift+←/→ for the right)"
+	} else {
+		s.FlashMsg = "Columns scroll together"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
 // ScrollBy adjusts scroll by delta and clamps
 func (s *State) ScrollBy(delta int) {
 	s.Scroll += delta
@@ -905,6 +1028,273 @@ func (s *State) ScrollTo(pos int) {
 	s.ClampScroll()
 }
 
+// JumpTo scrolls so that display line target is visible at the configured
+// jump position (top row below the scrolloff margin, or centered with
+// center_jumps) and anchors navigation to it.
+func (s *State) JumpTo(target int) {
+	target = s.logicalStart(target)
+	pos := target - s.scrollOff()
+	if s.Config.CenterJumps {
+		pos = target - s.viewRows()/2
+	}
+	s.ScrollTo(pos)
+	// The sticky file header covers the top row
+	if target == s.Scroll && s.StickyFileHeader() != "" {
+		s.ScrollTo(s.Scroll - 1)
+	}
+	s.setAnchor(target)
+	if s.CursorMode {
+		s.Cursor = target
+		s.clampCursor()
+	}
+}
+
+// MoveBy moves the cursor line by delta in cursor mode, otherwise scrolls.
+func (s *State) MoveBy(delta int) {
+	if !s.CursorMode {
+		s.ScrollBy(delta)
+		return
+	}
+	s.Cursor += delta
+	s.clampCursor()
+	s.ensureCursorVisible()
+}
+
+// MoveTo moves the cursor (in cursor mode) or the view to display line idx.
+func (s *State) MoveTo(idx int) {
+	if !s.CursorMode {
+		s.ScrollTo(idx)
+		return
+	}
+	s.Cursor = idx
+	s.clampCursor()
+	s.ensureCursorVisible()
+}
+
+// ToggleCursorMode switches between scrolling and a movable cursor line.
+// The cursor starts at the current focus line.
+func (s *State) ToggleCursorMode() {
+	if !s.CursorMode {
+		s.Cursor = s.focusLine()
+		s.CursorMode = true
+		s.clampCursor()
+		s.ensureCursorVisible()
+		return
+	}
+	s.CursorMode = false
+}
+
+func (s *State) clampCursor() {
+	if s.Cursor >= len(s.Lines) {
+		s.Cursor = len(s.Lines) - 1
+	}
+	if s.Cursor < 0 {
+		s.Cursor = 0
+	}
+	if s.logicalNav() {
+		s.Cursor = s.logicalStart(s.Cursor)
+	}
+}
+
+// MoveLines moves by delta lines (j/k). While wrapping, a line is a logical
+// diff line and continuation rows are skipped, unless RowNav is set.
+func (s *State) MoveLines(delta int) {
+	if !s.logicalNav() {
+		s.MoveBy(delta)
+		return
+	}
+	if s.CursorMode {
+		s.MoveTo(s.stepLogical(s.Cursor, delta))
+		return
+	}
+	s.ScrollTo(s.stepLogical(s.Scroll, delta))
+}
+
+// ToggleRowNav switches j/k between logical lines and display rows.
+func (s *State) ToggleRowNav() {
+	s.RowNav = !s.RowNav
+	if s.RowNav {
+		s.FlashMsg = "j/k move by display rows"
+	} else {
+		s.FlashMsg = "j/k move by logical lines"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	if s.CursorMode {
+		s.clampCursor()
+	}
+}
+
+func (s *State) logicalNav() bool {
+	return s.Wrap && !s.RowNav
+}
+
+// logicalStart returns the first display row of the logical line at idx.
+func (s *State) logicalStart(idx int) int {
+	for idx > 0 && idx < len(s.Lines) && s.Lines[idx].Continuation {
+		idx--
+	}
+	return idx
+}
+
+// stepLogical returns the first row of the logical line delta lines away
+// from the one containing idx.
+func (s *State) stepLogical(idx, delta int) int {
+	idx = s.logicalStart(idx)
+	for ; delta > 0; delta-- {
+		next := idx + 1
+		for next < len(s.Lines) && s.Lines[next].Continuation {
+			next++
+		}
+		if next >= len(s.Lines) {
+			break
+		}
+		idx = next
+	}
+	for ; delta < 0 && idx > 0; delta++ {
+		idx = s.logicalStart(idx - 1)
+	}
+	return idx
+}
+
+// ensureCursorVisible scrolls the minimum amount to keep the cursor on
+// screen, scrolloff lines away from its edges.
+func (s *State) ensureCursorVisible() {
+	visible := s.viewRows()
+	if visible < 1 {
+		visible = 1
+	}
+	so := s.scrollOff()
+	if s.Cursor < s.Scroll+so {
+		s.ScrollTo(s.Cursor - so)
+	} else if s.Cursor >= s.Scroll+visible-so {
+		s.ScrollTo(s.Cursor - visible + 1 + so)
+	}
+	// The sticky file header covers the top row
+	if s.Cursor == s.Scroll && s.StickyFileHeader() != "" {
+		s.ScrollTo(s.Scroll - 1)
+	}
+}
+
+// KeepCursorInView moves the cursor into the viewport after the view was
+// scrolled independently (e.g. by the mouse wheel).
+func (s *State) KeepCursorInView() {
+	if !s.CursorMode {
+		return
+	}
+	top, bottom := s.Scroll, s.Scroll+s.viewRows()-1
+	// The margins give way at the ends of the diff
+	if s.Scroll > 0 {
+		top += s.scrollOff()
+	}
+	if s.Scroll < s.MaxScroll() {
+		bottom -= s.scrollOff()
+	}
+	if s.Cursor < top {
+		s.Cursor = top
+	} else if s.Cursor > bottom {
+		s.Cursor = bottom
+	}
+	if s.Cursor == s.Scroll && s.StickyFileHeader() != "" {
+		s.Cursor++
+	}
+	s.clampCursor()
+}
+
+// FocusLineText returns the content of the focus line without its op
+// prefix. In side-by-side mode the new side is preferred. ok is false for
+// headers and blank lines.
+func (s *State) FocusLineText() (string, bool) {
+	idx := s.focusLine()
+	if text, ok := s.LineText(idx, true); ok {
+		return text, true
+	}
+	return s.LineText(idx, false)
+}
+
+// LineText returns the content of display line idx without its op prefix.
+// In side-by-side mode newSide picks the right (new) or left (old) half; it
+// is ignored inline. ok is false for headers and empty halves.
+func (s *State) LineText(idx int, newSide bool) (string, bool) {
+	if idx < 0 || idx >= len(s.Lines) {
+		return "", false
+	}
+	line := s.Lines[idx]
+	if line.Style != StyleAdded && line.Style != StyleRemoved && line.Style != StyleContext {
+		return "", false
+	}
+	text := line.Text
+	if s.SideBySide {
+		half := line.Left
+		if newSide {
+			half = line.Right
+		}
+		if half.Text == "" {
+			return "", false
+		}
+		text = half.Text
+	}
+	if !line.Continuation && text != "" {
+		text = string([]rune(text)[1:])
+	}
+	return text, true
+}
+
+func (s *State) setAnchor(line int) {
+	s.Anchor = line
+	s.anchorScroll = s.Scroll
+	s.anchorSet = true
+}
+
+// focusLine returns the display line navigation is relative to: the last
+// jump target while the view hasn't moved since, otherwise the top row.
+func (s *State) focusLine() int {
+	if s.CursorMode && len(s.Lines) > 0 {
+		return s.Cursor
+	}
+	if s.anchorSet && s.anchorScroll == s.Scroll && s.Anchor >= 0 && s.Anchor < len(s.Lines) {
+		return s.Anchor
+	}
+	return s.Scroll
+}
+
+// nextIndex returns the index in rows (display lines, in increasing order)
+// of the first row after cur for dir > 0, or the last row before it
+// otherwise, wrapping around the ends. rows must not be empty.
+func nextIndex(rows []int, cur, dir int) int {
+	if dir > 0 {
+		for n, i := range rows {
+			if i > cur {
+				return n
+			}
+		}
+		return 0
+	}
+	for n := len(rows) - 1; n >= 0; n-- {
+		if rows[n] < cur {
+			return n
+		}
+	}
+	return len(rows) - 1
+}
+
+// Recenter repositions the view around the focus line, like vim's zz/zt/zb.
+// mode is 'z' (center), 't' (top) or 'b' (bottom).
+func (s *State) Recenter(mode rune) {
+	line := s.focusLine()
+	visible := s.viewRows()
+	switch mode {
+	case 'z':
+		s.ScrollTo(line - visible/2)
+	case 't':
+		s.ScrollTo(line)
+	case 'b':
+		s.ScrollTo(line - visible + 1)
+	default:
+		return
+	}
+	s.setAnchor(line)
+}
+
 // HunkByLabel finds a hunk by its label
 func (s *State) HunkByLabel(label string) *Hunk {
 	for i := range s.Hunks {
@@ -915,6 +1305,18 @@ func (s *State) HunkByLabel(label string) *Hunk {
 	return nil
 }
 
+// lineForNew returns the index in s.Lines of the added or context row for
+// new-side line newNo of hunk hunkIdx, or -1 if it isn't displayed.
+func (s *State) lineForNew(hunkIdx, newNo int) int {
+	for i, l := range s.Lines {
+		if l.HunkIdx == hunkIdx && !l.Continuation && (l.NewLineNo == newNo || l.Right.LineNo == newNo) &&
+			l.Style != StyleReplaced {
+			return i
+		}
+	}
+	return -1
+}
+
 // hasLabelPrefix returns true if any hunk has a label starting with prefix
 // that is longer than prefix itself.
 func (s *State) hasLabelPrefix(prefix string) bool {
@@ -933,10 +1335,14 @@ func (s *State) PendingDisplay() string {
 	if s.PendingKey == 0 {
 		return ""
 	}
+	key := string(s.PendingKey)
+	if s.PendingKey == windowKey {
+		key = "^W"
+	}
 	if s.PendingLabel != "" {
-		return string(s.PendingKey) + " " + s.PendingLabel
+		return key + " " + s.PendingLabel
 	}
-	return string(s.PendingKey)
+	return key
 }
 
 // CurrentFile returns the file path at the current scroll position by walking
@@ -944,7 +1350,7 @@ func (s *State) PendingDisplay() string {
 // accurate than hunk-based detection when the scroll is on a file header or
 // blank line before the first hunk.
 func (s *State) CurrentFile() string {
-	for i := s.Scroll; i >= 0 && i < len(s.Lines); i-- {
+	for i := s.focusLine(); i >= 0 && i < len(s.Lines); i-- {
 		if s.Lines[i].Style == StyleFileHeader {
 			return s.Lines[i].Text
 		}
@@ -956,10 +1362,38 @@ func (s *State) CurrentFile() string {
 	return ""
 }
 
+// StickyFileHeader returns the file name whose header has scrolled off the
+// top of the diff pane, or "" when the header is still visible (or the top
+// line is itself a file header).
+func (s *State) StickyFileHeader() string {
+	if i := s.stickyFileHeaderIdx(); i >= 0 {
+		return s.Lines[i].Text
+	}
+	return ""
+}
+
+// stickyFileHeaderIdx returns the index in Lines of the sticky file header,
+// or -1 when there is none.
+func (s *State) stickyFileHeaderIdx() int {
+	if s.Scroll <= 0 || s.Scroll >= len(s.Lines) {
+		return -1
+	}
+	if s.Lines[s.Scroll].Style == StyleFileHeader {
+		return -1
+	}
+	for i := s.Scroll - 1; i >= 0; i-- {
+		if s.Lines[i].Style == StyleFileHeader {
+			return i
+		}
+	}
+	return -1
+}
+
 // CurrentLineNo returns the new-file line number near the current scroll
 // position, useful for opening an editor at the right line.
 func (s *State) CurrentLineNo() int {
-	for i := s.Scroll; i < len(s.Lines) && i < s.Scroll+5; i++ {
+	top := s.focusLine()
+	for i := top; i < len(s.Lines) && i < top+5; i++ {
 		if s.Lines[i].NewLineNo > 0 {
 			return s.Lines[i].NewLineNo
 		}
@@ -978,8 +1412,14 @@ func (s *State) CurrentLineNo() int {
 // CurrentHunkIndex returns the index of the hunk at current scroll position.
 // Hunks with StartLine == -1 (filtered out) are skipped.
 func (s *State) CurrentHunkIndex() int {
+	return s.hunkIndexAt(s.focusLine())
+}
+
+// hunkIndexAt returns the index of the last visible hunk starting at or
+// before display line idx, falling back to the first visible hunk.
+func (s *State) hunkIndexAt(idx int) int {
 	for i := len(s.Hunks) - 1; i >= 0; i-- {
-		if s.Hunks[i].StartLine >= 0 && s.Hunks[i].StartLine <= s.Scroll {
+		if s.Hunks[i].StartLine >= 0 && s.Hunks[i].StartLine <= idx {
 			return i
 		}
 	}
@@ -997,7 +1437,7 @@ func (s *State) JumpToNextHunk() {
 	idx := s.CurrentHunkIndex()
 	for i := idx + 1; i < len(s.Hunks); i++ {
 		if s.Hunks[i].StartLine >= 0 {
-			s.ScrollTo(s.Hunks[i].StartLine)
+			s.JumpTo(s.Hunks[i].StartLine)
 			return
 		}
 	}
@@ -1011,13 +1451,13 @@ func (s *State) JumpToPrevHunk() {
 	idx := s.CurrentHunkIndex()
 	for i := idx - 1; i >= 0; i-- {
 		if s.Hunks[i].StartLine >= 0 {
-			s.ScrollTo(s.Hunks[i].StartLine)
+			s.JumpTo(s.Hunks[i].StartLine)
 			return
 		}
 	}
 	// Stay at current
 	if idx >= 0 && idx < len(s.Hunks) && s.Hunks[idx].StartLine >= 0 {
-		s.ScrollTo(s.Hunks[idx].StartLine)
+		s.JumpTo(s.Hunks[idx].StartLine)
 	}
 }
 
@@ -1029,7 +1469,7 @@ func (s *State) JumpToNextFile() {
 	currentFile := s.Hunks[s.CurrentHunkIndex()].File
 	for i := s.CurrentHunkIndex() + 1; i < len(s.Hunks); i++ {
 		if s.Hunks[i].File != currentFile && s.Hunks[i].StartLine >= 0 {
-			s.ScrollTo(s.Hunks[i].StartLine)
+			s.JumpTo(s.Hunks[i].StartLine)
 			return
 		}
 	}
@@ -1053,14 +1493,14 @@ func (s *State) JumpToPrevFile() {
 	if targetFile == "" {
 		idx := s.CurrentHunkIndex()
 		if idx >= 0 && idx < len(s.Hunks) && s.Hunks[idx].StartLine >= 0 {
-			s.ScrollTo(s.Hunks[idx].StartLine)
+			s.JumpTo(s.Hunks[idx].StartLine)
 		}
 		return
 	}
 
 	for i, h := range s.Hunks {
 		if h.File == targetFile && h.StartLine >= 0 {
-			s.ScrollTo(s.Hunks[i].StartLine)
+			s.JumpTo(s.Hunks[i].StartLine)
 			return
 		}
 	}
@@ -1088,6 +1528,61 @@ func (s *State) orderedFiles() []string {
 	return files
 }
 
+// enterFullFile picks the file full-file mode shows: the filtered file, or
+// the one at the current position.
+func (s *State) enterFullFile() {
+	if s.FilterFile != "" {
+		s.FullFileName = s.FilterFile
+	} else {
+		s.FullFileName = s.CurrentFile()
+	}
+	if s.FullFileName == "" && len(s.Hunks) > 0 {
+		s.FullFileName = s.Hunks[0].File
+	}
+}
+
+// SetFullFileRev shows the file at rev in inline full-file mode, entering it
+// if needed. Choosing a diff side is the same as toggling with O.
+func (s *State) SetFullFileRev(rev string) {
+	oldRev, newRev := s.sideRevs()
+	if !s.FullFile {
+		s.FullFile = true
+		s.enterFullFile()
+	}
+	s.FullFileRevSet = false
+	switch rev {
+	case newRev:
+		s.FullFileOld = false
+	case oldRev:
+		s.FullFileOld = true
+	default:
+		s.FullFileOld = false
+		s.FullFileRevSet = true
+		s.FullFileRev = rev
+	}
+	s.FlashMsg = "Full file: " + revName(rev)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	s.BuildLines()
+	s.ClampScroll()
+}
+
+// SetFullFilePair compares the file at left and right in side-by-side
+// full-file mode, entering it if needed. Choosing the diff's own sides goes
+// back to the comparison built from the hunks.
+func (s *State) SetFullFilePair(left, right string) {
+	oldRev, newRev := s.sideRevs()
+	if !s.FullFile {
+		s.FullFile = true
+		s.enterFullFile()
+	}
+	s.FullFilePair = left != oldRev || right != newRev
+	s.FullFilePairRevs = [2]string{left, right}
+	s.FlashMsg = "Full file: " + revName(left) + " ↔ " + revName(right)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	s.BuildLines()
+	s.ClampScroll()
+}
+
 // SwitchFullFile changes the full-file view to a different file and rebuilds.
 func (s *State) SwitchFullFile(filename string) {
 	s.FullFileName = filename
@@ -1135,8 +1630,20 @@ func (s *State) DiffStats() (int, int) {
 	return added, removed
 }
 
-// RefDisplay returns a display-friendly version of the ref
+// RefDisplay returns a display-friendly version of the ref, followed by
+// the paths the diff is limited to.
 func (s *State) RefDisplay() string {
+	if len(s.Paths) > 0 {
+		return s.refName() + " -- " + strings.Join(s.Paths, " ")
+	}
+	return s.refName()
+}
+
+// refName names the refs of the diff, or the working copy or index.
+func (s *State) refName() string {
+	if s.NoIndex {
+		return s.Refs[0] + " → " + s.Refs[1]
+	}
 	if s.Staged {
 		if len(s.Refs) > 0 {
 			return strings.Join(s.Refs, "..") + " (staged)"
@@ -1144,6 +1651,9 @@ func (s *State) RefDisplay() string {
 		return "staged"
 	}
 	if len(s.Refs) == 0 {
+		if _, git := repo.(gitVCS); !git {
+			return "working copy"
+		}
 		return "unstaged"
 	}
 	return strings.Join(s.Refs, "..")
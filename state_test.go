@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
 
 func TestRefDisplayUnstaged(t *testing.T) {
 	s := &State{}
@@ -96,6 +103,29 @@ func TestBuildInlineLinesHunkHeader(t *testing.T) {
 	}
 }
 
+func TestBuildLinesHeaderCounts(t *testing.T) {
+	s := &State{Width: 80, Height: 24, Hunks: []Hunk{
+		{File: "a.go", Label: "a", Lines: []Line{{Op: '+', Content: "x"}, {Op: '+', Content: "y"}, {Op: ' ', Content: "z"}}},
+		{File: "a.go", Label: "b", Lines: []Line{{Op: '-', Content: "x"}}},
+		{File: "b.go", Label: "c", Lines: []Line{{Op: '-', Content: "x"}, {Op: '+', Content: "y"}}},
+	}}
+	for _, sbs := range []bool{false, true} {
+		s.SideBySide = sbs
+		s.BuildLines()
+		var got []string
+		for _, l := range s.Lines {
+			if l.Style == StyleFileHeader || l.Style == StyleHunkHeader {
+				added, removed := countsText(l)
+				got = append(got, l.Text+l.Label+added+removed)
+			}
+		}
+		want := []string{"a.go+2−1", "a+2−0", "b+0−1", "b.go+1−1", "c+1−1"}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("side-by-side %v: headers = %q, want %q", sbs, got, want)
+		}
+	}
+}
+
 func TestBuildInlineLinesLineNumbers(t *testing.T) {
 	s := makeTestState(80, false, false, []Line{
 		{Op: ' ', Content: "context"},
@@ -286,3 +316,302 @@ func TestWatchEnabledState(t *testing.T) {
 		t.Error("WatchEnabled should be true")
 	}
 }
+
+func TestStickyFileHeader(t *testing.T) {
+	s := &State{Lines: []DisplayLine{
+		{Text: "a.go", Style: StyleFileHeader},
+		{Style: StyleNormal},
+		{Text: "func a()", Style: StyleHunkHeader},
+		{Text: "+x", Style: StyleAdded},
+		{Style: StyleNormal},
+		{Text: "b.go", Style: StyleFileHeader},
+		{Text: "+y", Style: StyleAdded},
+	}}
+
+	if got := s.StickyFileHeader(); got != "" {
+		t.Errorf("at top: StickyFileHeader() = %q, want empty", got)
+	}
+	s.Scroll = 3
+	if got := s.StickyFileHeader(); got != "a.go" {
+		t.Errorf("inside a.go: StickyFileHeader() = %q, want %q", got, "a.go")
+	}
+	s.Scroll = 5
+	if got := s.StickyFileHeader(); got != "" {
+		t.Errorf("on header row: StickyFileHeader() = %q, want empty", got)
+	}
+	s.Scroll = 6
+	if got := s.StickyFileHeader(); got != "b.go" {
+		t.Errorf("inside b.go: StickyFileHeader() = %q, want %q", got, "b.go")
+	}
+}
+
+func TestJumpToCentersTarget(t *testing.T) {
+	s := &State{Height: 11, Config: Config{CenterJumps: true}}
+	s.Lines = make([]DisplayLine, 100)
+	s.JumpTo(50)
+	if s.Scroll != 45 {
+		t.Errorf("Scroll = %d after centered jump to 50, want 45", s.Scroll)
+	}
+	if got := s.focusLine(); got != 50 {
+		t.Errorf("focusLine() = %d, want 50", got)
+	}
+	s.ScrollBy(1)
+	if got := s.focusLine(); got != s.Scroll {
+		t.Errorf("focusLine() = %d after scrolling, want top row %d", got, s.Scroll)
+	}
+}
+
+func TestScrollOff(t *testing.T) {
+	s := &State{Height: 11, Config: Config{ScrollOff: 3}}
+	s.Lines = make([]DisplayLine, 100)
+	s.JumpTo(50)
+	if s.Scroll != 47 {
+		t.Errorf("Scroll = %d after jump to 50 with scrolloff 3, want 47", s.Scroll)
+	}
+
+	s.ToggleCursorMode()
+	s.MoveBy(5) // cursor 55, view rows 47-56
+	if s.Scroll != 49 {
+		t.Errorf("Scroll = %d with the cursor on 55, want 49 to keep 3 lines below", s.Scroll)
+	}
+	s.ScrollBy(-10)
+	s.KeepCursorInView()
+	if s.Cursor != 45 { // view rows 39-48
+		t.Errorf("Cursor = %d after scrolling up, want 45 (3 above the bottom)", s.Cursor)
+	}
+
+	// Margins give way at the top of the diff
+	s.MoveTo(0)
+	if s.Scroll != 0 || s.Cursor != 0 {
+		t.Errorf("at the top: Scroll, Cursor = %d, %d; want 0, 0", s.Scroll, s.Cursor)
+	}
+
+	// Never more than half the view
+	s.Config.ScrollOff = 50
+	if got := s.scrollOff(); got != 4 {
+		t.Errorf("scrollOff() = %d with 10 view rows, want 4", got)
+	}
+}
+
+func TestPageByKeepsOverlap(t *testing.T) {
+	s := &State{Height: 11}
+	s.Lines = make([]DisplayLine, 100)
+	s.PageBy(1)
+	if s.Scroll != 8 {
+		t.Errorf("Scroll = %d after a page down, want 8 (10 rows less 2 overlap)", s.Scroll)
+	}
+	s.Config.PageOverlap = -1
+	s.PageBy(-1)
+	if s.Scroll != 0 {
+		t.Errorf("Scroll = %d after a page up, want 0", s.Scroll)
+	}
+}
+
+func TestJumpToNextHunkCentered(t *testing.T) {
+	s := makeTestState(80, false, false, []Line{{Op: '+', Content: "a"}})
+	s.Height = 11
+	s.Config.CenterJumps = true
+	second := s.Hunks[0]
+	second.Label = "c"
+	second.NewStart = 40
+	third := second
+	third.Label = "d"
+	third.NewStart = 80
+	s.Hunks = append(s.Hunks, second, third)
+	s.BuildLines()
+	s.JumpToNextHunk()
+	if got := s.CurrentHunkIndex(); got != 1 {
+		t.Fatalf("after first jump CurrentHunkIndex() = %d, want 1", got)
+	}
+	s.JumpToNextHunk()
+	if got := s.CurrentHunkIndex(); got != 2 {
+		t.Errorf("after second jump CurrentHunkIndex() = %d, want 2", got)
+	}
+}
+
+func TestJumpToNextHunkBelowStickyHeader(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(80, 10)
+	var lines []Line
+	for i := range 20 {
+		lines = append(lines, Line{Op: '+', Content: fmt.Sprint("line ", i)})
+	}
+	s := &State{Screen: sim, Width: 80, Height: 10, Theme: NewUITheme(""), Hunks: []Hunk{
+		{File: "a.go", Label: "a", Header: "@@ -1 +1,20 @@", NewStart: 1, Lines: lines},
+		{File: "a.go", Label: "b", Header: "@@ -50 +70,20 @@ func second()", OldStart: 50, NewStart: 70, Lines: lines},
+	}}
+	s.BuildLines()
+	HandleKey(s, makeKeyEvent(']'))
+	HandleKey(s, makeKeyEvent('c'))
+	target := s.Hunks[1].StartLine
+	if s.StickyFileHeader() == "" || target-s.Scroll != 1 {
+		t.Fatalf("]c: target %d, scroll %d, sticky %q", target, s.Scroll, s.StickyFileHeader())
+	}
+	Render(s)
+	// The label the user types next is on the hunk header
+	if row := screenText(sim, 80, 2)[1]; !strings.HasPrefix(row, "b │") {
+		t.Errorf("the hunk header is not on the row below the sticky header: %q", row)
+	}
+}
+
+func TestRecenter(t *testing.T) {
+	s := &State{Height: 11}
+	s.Lines = make([]DisplayLine, 100)
+	s.JumpTo(50)
+	s.Recenter('z')
+	if s.Scroll != 45 {
+		t.Errorf("zz: Scroll = %d, want 45", s.Scroll)
+	}
+	s.Recenter('b')
+	if s.Scroll != 41 {
+		t.Errorf("zb: Scroll = %d, want 41", s.Scroll)
+	}
+	s.Recenter('t')
+	if s.Scroll != 50 {
+		t.Errorf("zt: Scroll = %d, want 50", s.Scroll)
+	}
+}
+
+func TestCursorModeMovesCursor(t *testing.T) {
+	s := &State{Height: 11}
+	s.Lines = make([]DisplayLine, 100)
+	s.ToggleCursorMode()
+	if !s.CursorMode || s.Cursor != 0 {
+		t.Fatalf("cursor mode = %v, cursor = %d", s.CursorMode, s.Cursor)
+	}
+	s.MoveBy(5)
+	if s.Cursor != 5 || s.Scroll != 0 {
+		t.Errorf("after MoveBy(5): cursor=%d scroll=%d, want 5, 0", s.Cursor, s.Scroll)
+	}
+	s.MoveBy(10)
+	if s.Cursor != 15 || s.Scroll != 6 {
+		t.Errorf("after MoveBy(10): cursor=%d scroll=%d, want 15, 6", s.Cursor, s.Scroll)
+	}
+	s.MoveTo(len(s.Lines) - 1)
+	if s.Cursor != 99 || s.Scroll != s.MaxScroll() {
+		t.Errorf("G: cursor=%d scroll=%d", s.Cursor, s.Scroll)
+	}
+	if s.focusLine() != 99 {
+		t.Errorf("focusLine = %d, want cursor 99", s.focusLine())
+	}
+	s.ToggleCursorMode()
+	s.MoveBy(-3)
+	if s.Cursor != 99 || s.Scroll != s.MaxScroll()-3 {
+		t.Errorf("scroll mode: cursor=%d scroll=%d", s.Cursor, s.Scroll)
+	}
+}
+
+func TestFocusLineText(t *testing.T) {
+	s := &State{CursorMode: true}
+	s.Lines = []DisplayLine{
+		{Text: "file.go", Style: StyleFileHeader},
+		{Text: "+added line", Style: StyleAdded},
+	}
+	if _, ok := s.FocusLineText(); ok {
+		t.Error("header line should not be yankable")
+	}
+	s.Cursor = 1
+	if text, ok := s.FocusLineText(); !ok || text != "added line" {
+		t.Errorf("FocusLineText = %q, %v", text, ok)
+	}
+}
+
+func TestFullFileDeletedFile(t *testing.T) {
+	s := &State{Height: 20, Width: 80, FullFile: true, FullFileName: "no/such/deleted.txt"}
+	s.Hunks = []Hunk{{
+		File: "no/such/deleted.txt", OldStart: 1, NewStart: 0,
+		Lines: []Line{{Op: '-', Content: "one"}, {Op: '-', Content: "two"}},
+	}}
+	if !s.fileDeleted("no/such/deleted.txt") {
+		t.Fatal("file should be detected as deleted")
+	}
+	newLines, oldLines, ok := s.fullFileSides("no/such/deleted.txt")
+	if !ok || len(newLines) != 0 || len(oldLines) != 2 {
+		t.Fatalf("fullFileSides = %v, %v, %v", newLines, oldLines, ok)
+	}
+
+	for _, old := range []bool{false, true} {
+		s.FullFileOld = old
+		s.BuildLines()
+		var removed int
+		for _, l := range s.Lines {
+			switch l.Style {
+			case StyleRemoved:
+				removed++
+			case StyleContext:
+				t.Errorf("old=%v: unexpected context line %q", old, l.Text)
+			}
+		}
+		if removed != 2 {
+			t.Errorf("old=%v: removed lines = %d, want 2", old, removed)
+		}
+	}
+}
+
+func TestLineNoWidthGrowsForLongFiles(t *testing.T) {
+	s := &State{DiffWidth: 80, LabelGutter: 4, LineNumbers: true}
+	s.Lines = []DisplayLine{{Style: StyleContext, OldLineNo: 9999, NewLineNo: 12345}}
+	s.computeLineNoDigits()
+	if got := s.lineNoWidth(); got != 6 {
+		t.Errorf("lineNoWidth() = %d, want 6", got)
+	}
+	s.Config.BothLineNumbers = true
+	// 80 - 4 - 2*6 = 64
+	if got := s.textWidth(); got != 64 {
+		t.Errorf("textWidth() = %d, want 64", got)
+	}
+}
+
+func TestFullFilePairComparesWholeRevisions(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := dir+"/a.txt", dir+"/b.txt", dir+"/c.txt"
+	for path, text := range map[string]string{
+		a: "one\ntwo\nthree\n",
+		b: "one\n2\nthree\n",
+		c: "zero\none\nthree\nfour\n",
+	} {
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &State{Refs: []string{a, b}, NoIndex: true, SideBySide: true, Width: 120, Height: 20}
+	s.Hunks = []Hunk{{File: b, OldStart: 2, NewStart: 2, Lines: []Line{{Op: '-', Content: "two"}, {Op: '+', Content: "2"}}}}
+
+	s.SetFullFilePair(revPath+a, revPath+c)
+	if !s.FullFilePair || !s.FullFile {
+		t.Fatalf("comparing other revisions should turn the pair view on")
+	}
+	var rows []string
+	for _, l := range s.Lines[1:] {
+		rows = append(rows, strings.TrimSpace(l.Left.Text)+"|"+strings.TrimSpace(l.Right.Text))
+	}
+	want := []string{"|+zero", "one|one", "-two|", "three|three", "|+four"}
+	if strings.Join(rows, " ") != strings.Join(want, " ") {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+	if s.Hunks[0].StartLine != 3 {
+		t.Errorf("hunk StartLine = %d, want the row of old line 2 (3)", s.Hunks[0].StartLine)
+	}
+
+	s.SetFullFilePair(revPath+a, revPath+b)
+	if s.FullFilePair {
+		t.Errorf("choosing the diff's own sides should go back to the regular view")
+	}
+}
+
+func TestNextIndex(t *testing.T) {
+	rows := []int{3, 8, 20}
+	tests := []struct{ cur, dir, want int }{
+		{0, 1, 0}, {3, 1, 1}, {10, 1, 2}, {20, 1, 0},
+		{10, -1, 1}, {3, -1, 2}, {25, -1, 2},
+	}
+	for _, tt := range tests {
+		if got := nextIndex(rows, tt.cur, tt.dir); got != tt.want {
+			t.Errorf("nextIndex(%v, %d, %d) = %d, want %d", rows, tt.cur, tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// statusSegment is one named piece of the status bar. sep is placed before
+// the segment when it follows another non-empty segment.
+type statusSegment struct {
+	sep    string
+	render func(s *State) string
+}
+
+// statusSegments maps segment names (as used in the status.left and
+// status.right config keys) to their renderers.
+var statusSegments = map[string]statusSegment{
+	"ref": {"", func(s *State) string {
+		if s.Series != nil {
+			return "wiff patch " + s.Series.patchTitle(s.Series.Current)
+		}
+		if s.PipeMode {
+			return "wiff (pipe)"
+		}
+		return "wiff " + s.RefDisplay()
+	}},
+	"tabs": {" ", func(s *State) string {
+		if s.Tabs == nil || len(s.Tabs.States) < 2 {
+			return ""
+		}
+		return fmt.Sprintf("[tab %d/%d]", s.Tabs.Current+1, len(s.Tabs.States))
+	}},
+	"files": {" • ", func(s *State) string {
+		if s.Review {
+			done, total := s.reviewProgress()
+			return fmt.Sprintf("%d/%d files", done, total)
+		}
+		if s.PipeMode {
+			return ""
+		}
+		return fmt.Sprintf("%d files", s.UniqueFiles())
+	}},
+	"hunks": {" • ", func(s *State) string {
+		return fmt.Sprintf("%d hunks", len(s.Hunks))
+	}},
+	"diffstat": {" • ", func(s *State) string {
+		if len(s.Hunks) == 0 {
+			return ""
+		}
+		added, removed := s.DiffStats()
+		return fmt.Sprintf("+%d -%d", added, removed)
+	}},
+	"branch": {" • ", func(s *State) string {
+		if s.Branch == "" {
+			return ""
+		}
+		return "⎇ " + s.Branch + s.Tracking.String()
+	}},
+	"filter": {" • ", func(s *State) string {
+		var parts []string
+		if s.FilterFile != "" && !s.FullFile {
+			parts = append(parts, "viewing: "+s.FilterFile)
+		}
+		if s.IndexFilter != indexFilterAll && s.IndexSplit != nil {
+			parts = append(parts, indexFilterNames[s.IndexFilter]+" only")
+		}
+		if s.ChangesOnly && !s.FullFile {
+			parts = append(parts, "changes only")
+		}
+		return strings.Join(parts, ", ")
+	}},
+	"hidden": {" • ", func(s *State) string {
+		switch s.HiddenFiles {
+		case 0:
+			return ""
+		case 1:
+			return "1 file hidden"
+		}
+		return fmt.Sprintf("%d files hidden", s.HiddenFiles)
+	}},
+	"todos": {" • ", func(s *State) string {
+		switch n := len(s.todoLines()); n {
+		case 0:
+			return ""
+		case 1:
+			return "1 TODO"
+		default:
+			return fmt.Sprintf("%d TODOs", n)
+		}
+	}},
+	"conflicts": {" • ", func(s *State) string {
+		return s.conflictSummary()
+	}},
+	"coverage": {" • ", func(s *State) string {
+		return s.coverageSummary()
+	}},
+	"diagnostics": {" • ", func(s *State) string {
+		return s.diagnosticSummary()
+	}},
+	"check": {" • ", func(s *State) string {
+		if s.Check == nil {
+			return ""
+		}
+		return s.Check.status()
+	}},
+	"tree": {" ", func(s *State) string {
+		if s.TreeFocused {
+			return "[TREE]"
+		}
+		return ""
+	}},
+	"watch": {" ", func(s *State) string {
+		if !s.PipeMode && !s.WatchEnabled {
+			return "[watch off]"
+		}
+		return ""
+	}},
+	"lowbw": {" ", func(s *State) string {
+		if s.LowBandwidth {
+			return "[low-bw]"
+		}
+		return ""
+	}},
+	"follow": {" ", func(s *State) string {
+		if s.FollowMode {
+			return "[FOLLOW]"
+		}
+		return ""
+	}},
+	"macro": {" ", func(s *State) string {
+		if s.MacroRecording {
+			return "[REC]"
+		}
+		return ""
+	}},
+	"search": {" • ", func(s *State) string {
+		if len(s.SearchMatches) == 0 || s.SearchQuery == "" {
+			return ""
+		}
+		if s.SearchIdx >= 0 && s.SearchIdx < len(s.SearchMatches) {
+			return fmt.Sprintf("\"%s\" [%d/%d]", s.SearchQuery, s.SearchIdx+1, len(s.SearchMatches))
+		}
+		return fmt.Sprintf("\"%s\" [%d matches]", s.SearchQuery, len(s.SearchMatches))
+	}},
+	"pending": {" ", func(s *State) string {
+		if pd := s.PendingDisplay(); pd != "" {
+			return "[" + pd + "…]"
+		}
+		return ""
+	}},
+	"hscroll": {" • ", func(s *State) string {
+		if s.ScrollX == 0 && s.RightScrollX() == 0 {
+			return ""
+		}
+		if s.SideBySide && s.UnlinkScroll {
+			return fmt.Sprintf("«%d|%d", s.ScrollX, s.ScrollXRight)
+		}
+		return fmt.Sprintf("«%d", s.ScrollX)
+	}},
+	"percent": {" • ", func(s *State) string {
+		return s.ScrollPercent()
+	}},
+	"line": {" • ", func(s *State) string {
+		return s.LinePosition()
+	}},
+	"file": {" • ", func(s *State) string {
+		return s.FilePosition()
+	}},
+	"position": {" • ", func(s *State) string {
+		parts := []string{s.LinePosition()}
+		if fp := s.FilePosition(); fp != "" {
+			parts = append(parts, fp)
+		}
+		parts = append(parts, s.ScrollPercent())
+		return strings.Join(parts, " · ")
+	}},
+	"clock": {" • ", func(s *State) string {
+		return time.Now().Format("15:04")
+	}},
+	"help": {" ", func(s *State) string {
+		if s.TreeFocused {
+			return "j/k:nav enter:select a:all tab:diff esc:back q:quit"
+		}
+		return "(s)plit (n)ums (w)rap (e)xpl (h)l (/)search (+/-)ctx (q)uit"
+	}},
+}
+
+var (
+	defaultStatusLeft  = []string{"ref", "tabs", "files", "hunks", "diffstat", "hidden", "todos", "conflicts", "coverage", "diagnostics", "check", "filter", "tree", "watch", "lowbw", "follow", "macro", "search", "pending", "hscroll"}
+	defaultStatusRight = []string{"position", "help"}
+)
+
+// statusLeft returns the configured left-hand segments or the defaults.
+func (c *Config) statusLeft() []string {
+	if len(c.StatusLeft) == 0 {
+		return defaultStatusLeft
+	}
+	return c.StatusLeft
+}
+
+// statusRight returns the configured right-hand segments or the defaults.
+func (c *Config) statusRight() []string {
+	if len(c.StatusRight) == 0 {
+		return defaultStatusRight
+	}
+	return c.StatusRight
+}
+
+// renderSegments joins the named segments, skipping unknown names and
+// segments that render empty.
+func renderSegments(s *State, names []string) string {
+	var sb strings.Builder
+	for _, name := range names {
+		seg, ok := statusSegments[name]
+		if !ok {
+			continue
+		}
+		text := seg.render(s)
+		if text == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(seg.sep)
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// LinePosition returns "line N/M" for the top visible display line, or the
+// cursor line in cursor mode.
+func (s *State) LinePosition() string {
+	cur := s.Scroll + 1
+	if s.CursorMode {
+		cur = s.Cursor + 1
+	}
+	if len(s.Lines) == 0 {
+		cur = 0
+	}
+	return fmt.Sprintf("line %d/%d", cur, len(s.Lines))
+}
+
+// FilePosition returns "file N/M" for the file at the scroll position, or ""
+// when the diff is empty.
+func (s *State) FilePosition() string {
+	files := s.orderedFiles()
+	if len(files) == 0 {
+		return ""
+	}
+	cur := s.CurrentFile()
+	for i, f := range files {
+		if f == cur {
+			return fmt.Sprintf("file %d/%d", i+1, len(files))
+		}
+	}
+	return fmt.Sprintf("file -/%d", len(files))
+}
+
+// ScrollPercent returns a vim-style position: "All", "Top", "Bot" or "NN%".
+func (s *State) ScrollPercent() string {
+	max := s.MaxScroll()
+	switch {
+	case max == 0:
+		return "All"
+	case s.Scroll <= 0:
+		return "Top"
+	case s.Scroll >= max:
+		return "Bot"
+	}
+	return fmt.Sprintf("%d%%", s.Scroll*100/max)
+}
+
+func drawStatusBar(s *State) {
+	if s.FlashMsg != "" && time.Now().Before(s.FlashExpiry) {
+		y := s.Height - 1
+		msg := " " + s.FlashMsg + " "
+		col := 0
+		for _, r := range msg {
+			if col >= s.Width {
+				break
+			}
+			s.Screen.SetContent(col, y, r, nil, s.Theme.Flash)
+			col++
+		}
+		for col < s.Width {
+			s.Screen.SetContent(col, y, ' ', nil, s.Theme.Flash)
+			col++
+		}
+		return
+	}
+	s.FlashMsg = ""
+	if s.Zen {
+		return
+	}
+
+	status := " " + renderSegments(s, s.Config.statusLeft())
+
+	// Right-aligned segments; drop trailing ones until the rest fit
+	names := s.Config.statusRight()
+	for len(names) > 0 {
+		right := renderSegments(s, names)
+		pad := s.Width - len([]rune(status)) - len([]rune(right)) - 1
+		if right != "" && pad > 0 {
+			status += strings.Repeat(" ", pad) + right
+			break
+		}
+		names = names[:len(names)-1]
+	}
+
+	style := s.Theme.StatusBar
+	if !s.hasFocus() {
+		style = style.Dim(true) // the other pane of a split
+	}
+	y := s.Height - 1
+	col := 0
+	for _, r := range status {
+		if col >= s.Width {
+			break
+		}
+		s.Screen.SetContent(col, y, r, nil, style)
+		col++
+	}
+	for col < s.Width {
+		s.Screen.SetContent(col, y, ' ', nil, style)
+		col++
+	}
+}
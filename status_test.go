@@ -0,0 +1,135 @@
+package main
+
+import "testing"
+
+func TestRenderSegmentsDefault(t *testing.T) {
+	s := &State{
+		Refs:         []string{"HEAD"},
+		WatchEnabled: true,
+		Hunks: []Hunk{
+			{File: "a.go", Lines: []Line{{Op: '+', Content: "x"}}},
+			{File: "b.go", Lines: []Line{{Op: '-', Content: "y"}}},
+		},
+	}
+	got := renderSegments(s, s.Config.statusLeft())
+	want := "wiff HEAD • 2 files • 2 hunks • +1 -1"
+	if got != want {
+		t.Errorf("renderSegments() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderSegmentsFlagsUseSpace(t *testing.T) {
+	s := &State{PipeMode: true, FollowMode: true, TreeFocused: true}
+	got := renderSegments(s, []string{"ref", "files", "tree", "follow"})
+	want := "wiff (pipe) [TREE] [FOLLOW]"
+	if got != want {
+		t.Errorf("renderSegments() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderSegmentsCustomOrder(t *testing.T) {
+	s := &State{Branch: "main", Hunks: []Hunk{{File: "a.go"}}}
+	got := renderSegments(s, []string{"branch", "bogus", "hunks"})
+	want := "⎇ main • 1 hunks"
+	if got != want {
+		t.Errorf("renderSegments() = %q, want %q", got, want)
+	}
+}
+
+func TestBranchSegmentTracking(t *testing.T) {
+	s := &State{Branch: "main", Tracking: &Tracking{Ahead: 2, Behind: 1}}
+	if got := renderSegments(s, []string{"branch"}); got != "⎇ main ↑2 ↓1" {
+		t.Errorf("diverged: %q", got)
+	}
+	s.Tracking = &Tracking{}
+	if got := renderSegments(s, []string{"branch"}); got != "⎇ main" {
+		t.Errorf("even with upstream: %q", got)
+	}
+}
+
+func TestScrollPercent(t *testing.T) {
+	s := &State{Height: 11}
+	if got := s.ScrollPercent(); got != "All" {
+		t.Errorf("empty: ScrollPercent() = %q, want All", got)
+	}
+	s.Lines = make([]DisplayLine, 30) // MaxScroll = 20
+	if got := s.ScrollPercent(); got != "Top" {
+		t.Errorf("top: ScrollPercent() = %q, want Top", got)
+	}
+	s.Scroll = 10
+	if got := s.ScrollPercent(); got != "50%" {
+		t.Errorf("middle: ScrollPercent() = %q, want 50%%", got)
+	}
+	s.Scroll = 20
+	if got := s.ScrollPercent(); got != "Bot" {
+		t.Errorf("bottom: ScrollPercent() = %q, want Bot", got)
+	}
+}
+
+func TestLinePosition(t *testing.T) {
+	s := &State{Lines: make([]DisplayLine, 728), Scroll: 309}
+	if got := s.LinePosition(); got != "line 310/728" {
+		t.Errorf("LinePosition() = %q, want %q", got, "line 310/728")
+	}
+	s = &State{}
+	if got := s.LinePosition(); got != "line 0/0" {
+		t.Errorf("empty LinePosition() = %q, want %q", got, "line 0/0")
+	}
+}
+
+func TestFilePosition(t *testing.T) {
+	s := &State{
+		Hunks: []Hunk{{File: "a.go"}, {File: "b.go"}, {File: "c.go"}},
+		Lines: []DisplayLine{
+			{Text: "a.go", Style: StyleFileHeader},
+			{Text: "b.go", Style: StyleFileHeader},
+			{Text: "c.go", Style: StyleFileHeader},
+		},
+		Scroll: 1,
+	}
+	if got := s.FilePosition(); got != "file 2/3" {
+		t.Errorf("FilePosition() = %q, want %q", got, "file 2/3")
+	}
+	if got := (&State{}).FilePosition(); got != "" {
+		t.Errorf("empty FilePosition() = %q, want empty", got)
+	}
+}
+
+func TestScrollbarThumb(t *testing.T) {
+	s := &State{Height: 11, Lines: make([]DisplayLine, 100)}
+	pos, size := scrollbarThumb(s, 10)
+	if pos != 0 || size != 1 {
+		t.Errorf("top: thumb = (%d, %d), want (0, 1)", pos, size)
+	}
+	s.Scroll = s.MaxScroll()
+	pos, size = scrollbarThumb(s, 10)
+	if pos+size != 10 {
+		t.Errorf("bottom: thumb ends at %d, want 10", pos+size)
+	}
+	s.Lines = make([]DisplayLine, 5)
+	s.Scroll = 0
+	pos, size = scrollbarThumb(s, 10)
+	if pos != 0 || size != 10 {
+		t.Errorf("short content: thumb = (%d, %d), want full track", pos, size)
+	}
+}
+
+func TestHorizontalScrollSegment(t *testing.T) {
+	s := &State{SideBySide: true}
+	if got := renderSegments(s, []string{"hscroll"}); got != "" {
+		t.Errorf("hscroll at offset 0 = %q, want empty", got)
+	}
+	s.ScrollHorizontal(4, false)
+	if s.ScrollX != 4 || s.RightScrollX() != 4 {
+		t.Fatalf("linked scroll = (%d, %d), want (4, 4)", s.ScrollX, s.RightScrollX())
+	}
+	s.ToggleLinkedScroll()
+	s.ScrollHorizontal(8, true)
+	s.ScrollHorizontal(-8, false)
+	if s.ScrollX != 0 || s.RightScrollX() != 12 {
+		t.Fatalf("unlinked scroll = (%d, %d), want (0, 12)", s.ScrollX, s.RightScrollX())
+	}
+	if got, want := renderSegments(s, []string{"hscroll"}), "«0|12"; got != want {
+		t.Errorf("hscroll = %q, want %q", got, want)
+	}
+}
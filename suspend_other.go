@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// stopSignals is empty: there is no job control to stop wiff.
+var stopSignals []os.Signal
+
+func stopProcess() error {
+	return errors.New("no job control on this system")
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// stopSignals are the signals that stop wiff until the shell continues it.
+var stopSignals = []os.Signal{syscall.SIGTSTP}
+
+// stopProcess stops wiff's process group, as the terminal does on Ctrl-Z
+// outside raw mode, and returns once the shell continues it.
+func stopProcess() error {
+	return syscall.Kill(0, syscall.SIGSTOP)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Tabs is the set of diffs open in one session. Each tab is a State of its
+// own; they share the screen, config, theme and highlighter.
+type Tabs struct {
+	States   []*State
+	Current  int
+	watching bool // the file watcher is running (main starts one unless piped)
+}
+
+// activeTab returns the State of the selected tab (s itself without tabs).
+func (s *State) activeTab() *State {
+	if s.Tabs == nil {
+		return s
+	}
+	return s.Tabs.States[s.Tabs.Current]
+}
+
+// tabs returns the session's tabs, creating them with s as the only tab.
+func (s *State) tabs() *Tabs {
+	if s.Tabs == nil {
+		s.Tabs = &Tabs{States: []*State{s}, watching: !s.PipeMode}
+		for _, p := range s.panes() {
+			p.Tabs = s.Tabs
+		}
+	}
+	return s.Tabs
+}
+
+// watch starts the file watcher for t unless one is already running.
+func (tabs *Tabs) watch(t *State) {
+	if !tabs.watching && t.Screen != nil {
+		tabs.watching = true
+		go watchAndUpdate(t)
+	}
+}
+
+// parseTabArgs parses the arguments of a new tab: refs, two files, paths
+// after --, or --staged/--cached, as on the command line.
+func parseTabArgs(input string) (refs []string, staged bool) {
+	for _, f := range strings.Fields(input) {
+		if f == "--staged" || f == "--cached" {
+			staged = true
+		} else {
+			refs = append(refs, f)
+		}
+	}
+	return refs, staged
+}
+
+// newTab returns a State for a diff of refs that shares s's screen and
+// settings and starts at the top.
+func (s *State) newTab(refs []string, staged bool) *State {
+	screen, w, h := s.fullScreen()
+	return &State{
+		Refs:            refs,
+		Staged:          staged,
+		NoIndex:         bothFiles(refs),
+		Screen:          screen,
+		Width:           w,
+		Height:          h,
+		SideBySide:      s.SideBySide,
+		ColumnRatio:     s.ColumnRatio,
+		ChangesOnly:     s.ChangesOnly,
+		SearchCase:      s.SearchCase,
+		SearchWord:      s.SearchWord,
+		LineNumbers:     s.LineNumbers,
+		ContextLines:    s.ContextLines,
+		Wrap:            s.Wrap,
+		SyntaxHighlight: s.SyntaxHighlight,
+		DiffBg:          s.DiffBg,
+		LowBandwidth:    s.LowBandwidth,
+		Debug:           s.Debug,
+		WatchEnabled:    true,
+		Theme:           s.Theme,
+		HL:              s.HL,
+		Config:          s.Config,
+		ColorMoved:      s.ColorMoved,
+		ConfirmApply:    s.ConfirmApply,
+		LabelPlacement:  s.LabelPlacement,
+		Zen:             s.Zen,
+		zenRestore:      s.zenRestore,
+		DiffAlgorithm:   s.DiffAlgorithm,
+		FindRenames:     s.FindRenames,
+		Excludes:        s.Excludes,
+		Coverage:        s.Coverage,
+		Diagnostics:     s.Diagnostics,
+		Tabs:            s.Tabs,
+
+		lowBandwidthSaved: s.lowBandwidthSaved,
+	}
+}
+
+// OpenTab opens a diff of the given arguments in a new tab after the
+// current one and switches to it.
+func OpenTab(s *State, args string) error {
+	refs, staged := parseTabArgs(args)
+	refs, paths, err := resolveArgs(refs)
+	if err != nil {
+		return err
+	}
+	tabs := s.tabs()
+	t := s.newTab(refs, staged)
+	t.Paths = paths
+	if err := loadDiff(t); err != nil {
+		return err
+	}
+	at := tabs.Current + 1
+	tabs.States = append(tabs.States[:at], append([]*State{t}, tabs.States[at:]...)...)
+	tabs.Current = at
+	tabs.watch(t)
+	return nil
+}
+
+// SwitchTab moves delta tabs along, wrapping around. The tab picks up the
+// current screen size.
+func SwitchTab(s *State, delta int) {
+	if s.Tabs == nil || len(s.Tabs.States) < 2 {
+		s.FlashMsg = "No other tabs (gn opens one)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	tabs := s.Tabs
+	n := len(tabs.States)
+	tabs.Current = ((tabs.Current+delta)%n + n) % n
+	s.showTab(tabs.States[tabs.Current])
+}
+
+// showTab brings tab t to the screen at s's size.
+func (s *State) showTab(t *State) {
+	_, w, h := s.fullScreen()
+	t.resize(w, h)
+	t = t.focusedPane()
+	t.FlashMsg = fmt.Sprintf("Tab %d/%d: %s", t.Tabs.Current+1, len(t.Tabs.States), t.tabTitle())
+	t.FlashExpiry = time.Now().Add(2 * time.Second)
+}
+
+// CloseTab closes the current tab. The last tab can't be closed; q quits.
+func CloseTab(s *State) {
+	if s.Tabs == nil || len(s.Tabs.States) < 2 {
+		s.FlashMsg = "Last tab (q quits)"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	tabs := s.Tabs
+	tabs.States = append(tabs.States[:tabs.Current], tabs.States[tabs.Current+1:]...)
+	tabs.Current = min(tabs.Current, len(tabs.States)-1)
+	s.showTab(tabs.States[tabs.Current])
+}
+
+// tabTitle names the tab after its diff.
+func (s *State) tabTitle() string {
+	if s.PipeMode {
+		return "pipe"
+	}
+	return s.RefDisplay()
+}
+
+// openTabPrompt asks for the diff of a new tab (gn), or of a new pane
+// after ^W n when split. Tab completes refs.
+func openTabPrompt(s *State, split bool) {
+	label := "new tab (refs, --staged): "
+	if split {
+		label = "split (refs, --staged): "
+	}
+	OpenPrompt(s, &InputPrompt{
+		Label:    label,
+		History:  "tab",
+		Complete: completeRefs,
+		OnSubmit: func(s *State, args string) {
+			if split {
+				if err := OpenSplit(s, args, true); err != nil {
+					s.FlashMsg = "Split: " + err.Error()
+					s.FlashExpiry = time.Now().Add(3 * time.Second)
+				}
+			} else if err := OpenTab(s, args); err != nil {
+				s.FlashMsg = "New tab: " + err.Error()
+				s.FlashExpiry = time.Now().Add(3 * time.Second)
+			}
+		},
+	})
+}
+
+// completeRefs completes the last word of input to the branches, tags and
+// remote branches it starts, or to --staged.
+func completeRefs(s *State, input string) []string {
+	head, word := "", input
+	if i := strings.LastIndexByte(input, ' '); i >= 0 {
+		head, word = input[:i+1], input[i+1:]
+	}
+	// The end of a range completes too: main..feat
+	if i := strings.LastIndex(word, ".."); i >= 0 {
+		head, word = head+word[:i+2], word[i+2:]
+	}
+	var out []string
+	for _, ref := range append(gitRefs(), "HEAD", "--staged") {
+		if strings.HasPrefix(ref, word) && ref != word {
+			out = append(out, head+ref)
+		}
+	}
+	return out
+}
+
+// diffPanes returns every tab and split pane whose diff can be run again.
+func diffPanes(s *State) []*State {
+	tabs := []*State{s}
+	if s.Tabs != nil {
+		tabs = s.Tabs.States
+	}
+	var panes []*State
+	for _, t := range tabs {
+		for _, p := range t.panes() {
+			if !p.PipeMode {
+				panes = append(panes, p)
+			}
+		}
+	}
+	return panes
+}
+
+// watchedPanes returns every tab and split pane that reloads after a file
+// change.
+func watchedPanes(s *State) []*State {
+	return slices.DeleteFunc(diffPanes(s), func(p *State) bool { return !p.WatchEnabled })
+}
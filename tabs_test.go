@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTabArgs(t *testing.T) {
+	refs, staged := parseTabArgs("  --staged main ")
+	if !staged || !reflect.DeepEqual(refs, []string{"main"}) {
+		t.Errorf("got %q %v", refs, staged)
+	}
+	refs, staged = parseTabArgs("main...feature")
+	if staged || !reflect.DeepEqual(refs, []string{"main...feature"}) {
+		t.Errorf("got %q %v", refs, staged)
+	}
+}
+
+func TestSwitchAndCloseTabs(t *testing.T) {
+	a := &State{Width: 80, Height: 24}
+	b := &State{Width: 80, Height: 24, Staged: true}
+	c := &State{Width: 40, Height: 10, Refs: []string{"main...feature"}}
+	a.Tabs = &Tabs{States: []*State{a, b, c}}
+	b.Tabs, c.Tabs = a.Tabs, a.Tabs
+
+	HandleKey(a, makeKeyEvent('g'))
+	HandleKey(a, makeKeyEvent('T'))
+	if a.activeTab() != c {
+		t.Fatalf("gT from the first tab should wrap to the last")
+	}
+	if c.Width != 80 || c.Height != 24 {
+		t.Errorf("shown tab size = %dx%d, want the screen's 80x24", c.Width, c.Height)
+	}
+	if c.FlashMsg != "Tab 3/3: main...feature" {
+		t.Errorf("FlashMsg = %q", c.FlashMsg)
+	}
+
+	HandleKey(c, makeKeyEvent('g'))
+	HandleKey(c, makeKeyEvent('t'))
+	if c.activeTab() != a {
+		t.Fatalf("gt from the last tab should wrap to the first")
+	}
+
+	HandleKey(a, makeKeyEvent('g'))
+	HandleKey(a, makeKeyEvent('t'))
+	HandleKey(b, makeKeyEvent('g'))
+	HandleKey(b, makeKeyEvent('x'))
+	if got := a.Tabs.States; len(got) != 2 || got[0] != a || got[1] != c || a.activeTab() != c {
+		t.Errorf("after closing tab 2: %d tabs, current %d", len(got), a.Tabs.Current)
+	}
+}
+
+func TestCloseLastTab(t *testing.T) {
+	s := &State{}
+	CloseTab(s)
+	if s.activeTab() != s || s.FlashMsg == "" {
+		t.Errorf("closing the only tab should be refused with a message")
+	}
+}
+
+func TestPendingGoesToTop(t *testing.T) {
+	s := makeTestState(80, false, false, []Line{{Op: '+', Content: "a"}, {Op: '+', Content: "b"}, {Op: '+', Content: "c"}})
+	s.Height = 4
+	s.BuildLines()
+	s.MoveTo(len(s.Lines) - 1)
+
+	HandleKey(s, makeKeyEvent('g'))
+	if s.PendingKey != 'g' {
+		t.Fatalf("g should wait for a second key")
+	}
+	HandleKey(s, makeKeyEvent('g'))
+	if s.Scroll != 0 || s.PendingKey != 0 {
+		t.Errorf("gg: Scroll = %d, PendingKey = %q", s.Scroll, s.PendingKey)
+	}
+
+	s.MoveTo(len(s.Lines) - 1)
+	HandleKey(s, makeKeyEvent('g'))
+	ResolvePendingLabel(s) // the timeout after a lone g
+	if s.Scroll != 0 || s.PendingKey != 0 {
+		t.Errorf("g timeout: Scroll = %d, PendingKey = %q", s.Scroll, s.PendingKey)
+	}
+}
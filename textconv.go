@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// textconvFiles returns the files in hunks whose diff attribute names a
+// driver with a textconv command (diff=exif with diff.exif.textconv set),
+// mapped to the driver. git diff shows such files converted, so their
+// hunks are text that can't be staged or applied.
+func (s *State) textconvFiles(hunks []Hunk) map[string]string {
+	if _, git := repo.(gitVCS); s.NoIndex || !git {
+		return nil
+	}
+	var files []string
+	seen := make(map[string]bool)
+	for _, h := range hunks {
+		if !seen[h.File] {
+			seen[h.File] = true
+			files = append(files, h.File)
+		}
+	}
+	converted := make(map[string]string)
+	hasTextconv := make(map[string]bool)
+	for f, driver := range checkAttr(files, "diff") {
+		switch driver {
+		case "set", "unset", "unspecified":
+			continue
+		}
+		has, ok := hasTextconv[driver]
+		if !ok {
+			has = gitConfig("diff."+driver+".textconv") != ""
+			hasTextconv[driver] = has
+		}
+		if has {
+			converted[f] = driver
+		}
+	}
+	return converted
+}
+
+// gitConfig returns the value of a git config key, or "" when unset.
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// textconvRefused flashes why hunk can't be staged or applied when its file
+// is shown through a textconv driver, and reports whether it did.
+func textconvRefused(s *State, hunk *Hunk) bool {
+	driver, ok := s.Textconv[hunk.File]
+	if !ok {
+		return false
+	}
+	s.FlashMsg = fmt.Sprintf("Hunk %s is %s textconv output, not the file's content", hunk.Label, driver)
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	return true
+}
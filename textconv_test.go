@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextconvFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "diff.hex.textconv", "xxd"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	attrs := "*.bin diff=hex\n*.dat diff=plain\n*.txt diff\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte(attrs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &State{}
+	got := s.textconvFiles([]Hunk{{File: "a.bin"}, {File: "a.bin"}, {File: "b.dat"}, {File: "c.txt"}})
+	if len(got) != 1 || got["a.bin"] != "hex" {
+		t.Errorf("textconvFiles = %v, want only a.bin through hex", got)
+	}
+}
+
+func TestStageRefusesTextconvHunk(t *testing.T) {
+	s := &State{Textconv: map[string]string{"a.bin": "hex"}}
+	h := &Hunk{Label: "a", File: "a.bin"}
+	handleStageHunk(s, h)
+	if h.Staged || !strings.Contains(s.FlashMsg, "hex textconv") {
+		t.Errorf("staged %v, flash %q", h.Staged, s.FlashMsg)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/styles"
@@ -16,6 +17,7 @@ type UITheme struct {
 	Highlight tcell.Color // from String token
 	Added     tcell.Color // semantic green (kept)
 	Removed   tcell.Color // semantic red (kept)
+	Renamed   tcell.Color // from Number token, renamed/copied files in the tree
 
 	// Pre-built styles
 	Default     tcell.Style
@@ -30,9 +32,17 @@ type UITheme struct {
 	SearchCur   tcell.Style
 	Flash       tcell.Style
 
+	// Moved lines (--color-moved)
+	MovedAdded   tcell.Style
+	MovedRemoved tcell.Style
+
 	// Diff bg tints (computed from theme background)
 	BgAdded   tcell.Color
 	BgRemoved tcell.Color
+	BgCursor  tcell.Color // cursor line in cursor mode
+
+	BgMovedAdded   tcell.Color
+	BgMovedRemoved tcell.Color
 }
 
 // knownStyle returns true if name is a registered chroma style.
@@ -55,6 +65,7 @@ func NewUITheme(name string) UITheme {
 	accent := chromaColor(cs, chroma.Keyword, tcell.ColorAqua)
 	highlight := chromaColor(cs, chroma.LiteralString, tcell.ColorYellow)
 	comment := chromaColor(cs, chroma.Comment, tcell.ColorAqua)
+	renamed := chromaColor(cs, chroma.LiteralNumber, tcell.ColorYellow)
 	fg := chromaColor(cs, chroma.Background, tcell.ColorWhite) // default text foreground
 
 	added := tcell.ColorGreen
@@ -62,12 +73,15 @@ func NewUITheme(name string) UITheme {
 
 	base := tcell.StyleDefault
 	bgAdded, bgRemoved := computeDiffBg(cs)
+	bgCursor := computeCursorBg(cs)
+	bgMovedAdded, bgMovedRemoved := computeMovedBg(cs)
 
 	return UITheme{
 		Accent:    accent,
 		Highlight: highlight,
 		Added:     added,
 		Removed:   removed,
+		Renamed:   renamed,
 
 		Default:     base,
 		Dim:         base.Dim(true),
@@ -81,8 +95,15 @@ func NewUITheme(name string) UITheme {
 		SearchCur:   base.Background(highlight).Foreground(tcell.ColorBlack).Bold(true),
 		Flash:       base.Foreground(added).Bold(true).Reverse(true),
 
+		MovedAdded:   base.Foreground(tcell.ColorTeal).Bold(true),
+		MovedRemoved: base.Foreground(tcell.ColorPurple).Bold(true),
+
 		BgAdded:   bgAdded,
 		BgRemoved: bgRemoved,
+		BgCursor:  bgCursor,
+
+		BgMovedAdded:   bgMovedAdded,
+		BgMovedRemoved: bgMovedRemoved,
 	}
 }
 
@@ -123,6 +144,46 @@ func computeDiffBg(cs *chroma.Style) (bgAdded, bgRemoved tcell.Color) {
 	return
 }
 
+// computeMovedBg calculates tints for moved lines, shifting the theme's
+// background toward blue (moved to) and magenta (moved from).
+func computeMovedBg(cs *chroma.Style) (bgAdded, bgRemoved tcell.Color) {
+	bgEntry := cs.Get(chroma.Background)
+	if !bgEntry.Background.IsSet() {
+		return tcell.NewRGBColor(0x1a, 0x2a, 0x3a), tcell.NewRGBColor(0x32, 0x1a, 0x32)
+	}
+
+	r := int32(bgEntry.Background.Red())
+	g := int32(bgEntry.Background.Green())
+	b := int32(bgEntry.Background.Blue())
+
+	if bgEntry.Background.Brightness() < 0.5 {
+		bgAdded = tcell.NewRGBColor(r, clamp32(g+16), clamp32(b+32))
+		bgRemoved = tcell.NewRGBColor(clamp32(r+24), g, clamp32(b+24))
+	} else {
+		bgAdded = tcell.NewRGBColor(clamp32(r-24), clamp32(g-8), b)
+		bgRemoved = tcell.NewRGBColor(r, clamp32(g-24), b)
+	}
+	return
+}
+
+// computeCursorBg returns a neutral tint of the theme background for the
+// cursor line.
+func computeCursorBg(cs *chroma.Style) tcell.Color {
+	bgEntry := cs.Get(chroma.Background)
+	if !bgEntry.Background.IsSet() {
+		return tcell.NewRGBColor(0x3a, 0x3a, 0x3a)
+	}
+
+	r := int32(bgEntry.Background.Red())
+	g := int32(bgEntry.Background.Green())
+	b := int32(bgEntry.Background.Blue())
+
+	if bgEntry.Background.Brightness() < 0.5 {
+		return tcell.NewRGBColor(clamp32(r+28), clamp32(g+28), clamp32(b+28))
+	}
+	return tcell.NewRGBColor(clamp32(r-24), clamp32(g-24), clamp32(b-24))
+}
+
 // contrastFg returns black or white depending on which contrasts better with bg.
 func contrastFg(bg tcell.Color) tcell.Color {
 	r, g, b := bg.RGB()
@@ -151,3 +212,50 @@ func ListThemes() {
 	}
 	os.Exit(0)
 }
+
+// applyTheme switches the UI and syntax colors to the named chroma style.
+func applyTheme(s *State, name string) {
+	s.Theme = NewUITheme(name)
+	if s.HL != nil {
+		s.HL.SetTheme(name)
+	}
+}
+
+// openThemePicker lists the chroma styles and previews each one as the
+// cursor moves. Enter keeps the theme and saves it to the config file;
+// Esc restores the previous one.
+func openThemePicker(s *State) {
+	prev := ""
+	if s.HL != nil {
+		prev = s.HL.ThemeName()
+	}
+	names := styles.Names()
+	p := &Popup{
+		Title: "Theme",
+		Items: names,
+		OnMove: func(s *State, idx int) {
+			applyTheme(s, names[idx])
+		},
+		OnSelect: func(s *State, idx int) {
+			applyTheme(s, names[idx])
+			if err := SaveConfigValue("theme", names[idx]); err != nil {
+				s.FlashMsg = "Theme " + names[idx] + " (not saved: " + err.Error() + ")"
+			} else {
+				s.FlashMsg = "Theme " + names[idx] + " saved"
+			}
+			s.FlashExpiry = time.Now().Add(2 * time.Second)
+		},
+		OnCancel: func(s *State) {
+			if prev != "" {
+				applyTheme(s, prev)
+			}
+		},
+	}
+	for i, n := range names {
+		if n == prev {
+			p.Cursor = i
+		}
+	}
+	OpenPopup(s, p)
+	movePopupCursor(s, p, 0)
+}
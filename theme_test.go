@@ -91,6 +91,9 @@ func TestNewUIThemeMonokai(t *testing.T) {
 	if theme.Highlight == 0 {
 		t.Error("expected Highlight color to be non-zero")
 	}
+	if want := tcell.NewRGBColor(0xae, 0x81, 0xff); theme.Renamed != want {
+		t.Errorf("expected Renamed color %v from the Number token, got %v", want, theme.Renamed)
+	}
 }
 
 func TestNewUIThemeFallback(t *testing.T) {
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// xterm's window title stack: push the title on start, pop it on exit. tcell
+// does this itself only on the alternate screen.
+const (
+	pushTitle = "\x1b[22;2t"
+	popTitle  = "\x1b[23;2t"
+)
+
+// termTitle is the terminal title last set, sent again only when it changes.
+var termTitle string
+
+// repoName is the name of the repository's root directory, or "" outside
+// one; looked up once.
+var repoName = sync.OnceValue(func() string {
+	root, err := repo.Root()
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(root)
+})
+
+// windowTitle returns the terminal title for s: "wiff: <ref> (<repo>)".
+func windowTitle(s *State) string {
+	ref := s.RefDisplay()
+	switch {
+	case s.Series != nil:
+		ref = "patch " + s.Series.patchTitle(s.Series.Current)
+	case s.PipeMode:
+		ref = "pipe"
+	}
+	if s.PipeMode || s.NoIndex || repoName() == "" {
+		return "wiff: " + ref
+	}
+	return fmt.Sprintf("wiff: %s (%s)", ref, repoName())
+}
+
+// updateTitle sets the terminal title to that of s when it changed.
+func updateTitle(s *State) {
+	if title := windowTitle(s); title != termTitle {
+		termTitle = title
+		s.Screen.SetTitle(title)
+	}
+}
+
+// keepFinalView quits leaving the last view on the main screen, for
+// --no-alt-screen: the cursor goes below it so the shell prompt follows, and
+// the title pushed at start is restored.
+func keepFinalView(screen tcell.Screen) {
+	_, h := screen.Size()
+	screen.ShowCursor(0, h-1)
+	screen.Show()
+	screen.Fini()
+	if stdoutIsTerminal() {
+		fmt.Print("\n" + popTitle)
+	}
+}
+
+// disableAltScreen makes tcell draw on the main screen, keeping what wiff
+// shows in the scrollback, and saves the title tcell won't.
+func disableAltScreen() {
+	_ = os.Setenv("TCELL_ALTSCREEN", "disable")
+	if stdoutIsTerminal() {
+		fmt.Print(pushTitle)
+	}
+}
+
+// stdoutIsTerminal reports whether standard output is the terminal, where
+// the escape sequences and the final newline belong.
+func stdoutIsTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
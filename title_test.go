@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestWindowTitle(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(80, 12)
+
+	s := &State{Screen: sim, Width: 80, Height: 12, HL: NewHighlighter(), PipeMode: true}
+	s.BuildLines()
+	Render(s)
+	if got := sim.GetTitle(); got != "wiff: pipe" {
+		t.Errorf("title = %q, want wiff: pipe", got)
+	}
+
+	s = &State{Screen: sim, Width: 80, Height: 12, HL: NewHighlighter(), NoIndex: true, Refs: []string{"a.txt", "b.txt"}}
+	s.BuildLines()
+	Render(s)
+	if got := sim.GetTitle(); got != "wiff: a.txt → b.txt" {
+		t.Errorf("title = %q, want wiff: a.txt → b.txt", got)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultTodoMarkers are the words flagged on added lines unless the
+// todo_markers config key says otherwise.
+var defaultTodoMarkers = []string{"TODO", "FIXME", "XXX"}
+
+// todoPattern returns the regexp matching any marker as a whole word,
+// compiled once from the config, or nil when markers are turned off.
+func (s *State) todoPattern() *regexp.Regexp {
+	if s.todoRe == nil {
+		markers := s.Config.TodoMarkers
+		if markers == nil {
+			markers = defaultTodoMarkers
+		}
+		if len(markers) == 0 {
+			return nil
+		}
+		quoted := make([]string, len(markers))
+		for i, m := range markers {
+			quoted[i] = regexp.QuoteMeta(m)
+		}
+		s.todoRe = regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return s.todoRe
+}
+
+// isTodoLine reports whether a display row is the first row of an added
+// line containing a marker.
+func (s *State) isTodoLine(line DisplayLine) bool {
+	re := s.todoPattern()
+	if re == nil || line.Continuation {
+		return false
+	}
+	switch {
+	case line.Style == StyleAdded && line.Text != "":
+		return re.MatchString(line.Text)
+	case line.Right.Style == StyleAdded:
+		return re.MatchString(line.Right.Text)
+	}
+	return false
+}
+
+// todoLines returns the indices of the rows in s.Lines flagged as markers.
+func (s *State) todoLines() []int {
+	var idx []int
+	for i, line := range s.Lines {
+		if s.isTodoLine(line) {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// JumpToTodo moves to the next (dir > 0) or previous marker line, wrapping
+// around the ends of the diff.
+func (s *State) JumpToTodo(dir int) {
+	todos := s.todoLines()
+	if len(todos) == 0 {
+		s.FlashMsg = "No TODO markers in added lines"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	s.JumpTo(todos[nextIndex(todos, s.focusLine(), dir)])
+}
+
+// openTodoPanel lists the marker lines; selecting one jumps to it.
+func openTodoPanel(s *State) {
+	todos := s.todoLines()
+	if len(todos) == 0 {
+		s.FlashMsg = "No TODO markers in added lines"
+		s.FlashExpiry = time.Now().Add(2 * time.Second)
+		return
+	}
+	items := make([]string, len(todos))
+	for i, idx := range todos {
+		line := s.Lines[idx]
+		text, lineNo := line.Text, line.NewLineNo
+		if s.SideBySide {
+			text, lineNo = line.Right.Text, line.Right.LineNo
+		}
+		file := ""
+		if line.HunkIdx >= 0 && line.HunkIdx < len(s.Hunks) {
+			file = s.Hunks[line.HunkIdx].File
+		}
+		items[i] = fmt.Sprintf("%s:%d  %s", file, lineNo, strings.TrimSpace(strings.TrimPrefix(text, "+")))
+	}
+	OpenPopup(s, &Popup{
+		Title: fmt.Sprintf("TODO markers (%d)", len(todos)),
+		Items: items,
+		OnSelect: func(s *State, i int) {
+			s.JumpTo(todos[i])
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func todoState(cfg Config) *State {
+	s := &State{
+		Config: cfg,
+		Hunks: []Hunk{{
+			File: "a.go", Label: "a", OldStart: 1, NewStart: 1,
+			Lines: []Line{
+				{Op: ' ', Content: "// TODO: old, not added"},
+				{Op: '+', Content: "x := 1 // TODO: tidy"},
+				{Op: '+', Content: "todoList := nil"},
+				{Op: '-', Content: "// FIXME removed"},
+				{Op: '+', Content: "// XXX hack"},
+			},
+		}},
+		Width: 80, Height: 20,
+	}
+	s.BuildLines()
+	return s
+}
+
+func TestTodoLines(t *testing.T) {
+	s := todoState(Config{})
+	todos := s.todoLines()
+	if len(todos) != 2 {
+		t.Fatalf("expected 2 markers, got %d", len(todos))
+	}
+	if !strings.Contains(s.Lines[todos[0]].Text, "TODO: tidy") || !strings.Contains(s.Lines[todos[1]].Text, "XXX") {
+		t.Errorf("unexpected marker lines %q, %q", s.Lines[todos[0]].Text, s.Lines[todos[1]].Text)
+	}
+
+	s = todoState(Config{TodoMarkers: []string{"hack"}})
+	if n := len(s.todoLines()); n != 1 {
+		t.Errorf("custom markers: expected 1, got %d", n)
+	}
+	s = todoState(Config{TodoMarkers: []string{}})
+	if n := len(s.todoLines()); n != 0 {
+		t.Errorf("markers off: expected 0, got %d", n)
+	}
+}
+
+func TestJumpToTodoWraps(t *testing.T) {
+	s := todoState(Config{})
+	s.CursorMode = true
+	todos := s.todoLines()
+	s.JumpToTodo(1)
+	if s.Cursor != todos[0] {
+		t.Fatalf("first jump: cursor %d, want %d", s.Cursor, todos[0])
+	}
+	s.JumpToTodo(1)
+	s.JumpToTodo(1)
+	if s.Cursor != todos[0] {
+		t.Errorf("expected wrap to first marker, cursor %d", s.Cursor)
+	}
+	s.JumpToTodo(-1)
+	if s.Cursor != todos[1] {
+		t.Errorf("expected wrap back to last marker, cursor %d", s.Cursor)
+	}
+}
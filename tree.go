@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -15,6 +16,7 @@ type TreeFile struct {
 	Path    string
 	Added   int
 	Removed int
+	Status  byte // A, M, D, R or C (0 when unknown)
 }
 
 // TreeNode is a flattened entry for rendering the tree sidebar.
@@ -26,6 +28,7 @@ type TreeNode struct {
 	IsDir   bool
 	Added   int
 	Removed int
+	Status  byte
 }
 
 // dirNode is an intermediate tree structure used to build the hierarchy.
@@ -55,13 +58,16 @@ func (d *dirNode) getOrCreateChild(name string) *dirNode {
 
 // buildTree computes tree file stats from hunks
 func buildTree(s *State) {
-	type stats struct{ add, rem int }
+	type stats struct {
+		add, rem int
+		status   byte
+	}
 	m := make(map[string]*stats)
 	var order []string
 
 	for _, h := range s.Hunks {
 		if _, ok := m[h.File]; !ok {
-			m[h.File] = &stats{}
+			m[h.File] = &stats{status: h.Status}
 			order = append(order, h.File)
 		}
 		for _, l := range h.Lines {
@@ -81,10 +87,195 @@ func buildTree(s *State) {
 			Path:    path,
 			Added:   st.add,
 			Removed: st.rem,
+			Status:  st.status,
+		})
+	}
+
+	s.TreeNodes = s.sortedTreeNodes()
+}
+
+// Tree icon sets for the tree_icons config key.
+var treeIconSets = map[string]map[byte]rune{
+	"letter": {'A': 'A', 'M': 'M', 'D': 'D', 'R': 'R', 'C': 'C'},
+	"symbol": {'A': '+', 'M': '~', 'D': '-', 'R': '→', 'C': '⧉'},
+	// Nerd Font octicons: diff-added, diff-modified, diff-removed, diff-renamed, copy
+	"nerd": {'A': '\uf457', 'M': '\uf459', 'D': '\uf458', 'R': '\uf45a', 'C': '\uf0c5'},
+	"none": {},
+}
+
+// statusIcon returns the icon shown before a file of the given status, or 0
+// for none. An empty set name means the default letters.
+func statusIcon(set string, status byte) rune {
+	if set == "" {
+		set = "letter"
+	}
+	return treeIconSets[set][status]
+}
+
+// Tree sort modes, cycled with s while the tree is focused.
+const (
+	treeSortPath    = iota // directory hierarchy, alphabetical
+	treeSortChanges        // flat, most changed lines first
+	treeSortStatus         // grouped by added/modified/renamed/deleted
+	treeSortModes
+)
+
+var treeSortNames = [treeSortModes]string{"path", "most changed", "status"}
+
+// sortedTreeNodes builds the tree nodes for the current sort mode, keeping
+// only the files that match the tree search query.
+func (s *State) sortedTreeNodes() []TreeNode {
+	files := s.TreeFiles
+	if s.TreeQuery != "" {
+		files = nil
+		for _, tf := range s.TreeFiles {
+			if fuzzyMatch(s.TreeQuery, tf.Path) {
+				files = append(files, tf)
+			}
+		}
+	}
+	switch s.TreeSort {
+	case treeSortChanges:
+		files = append([]TreeFile(nil), files...)
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].Added+files[i].Removed > files[j].Added+files[j].Removed
+		})
+		return flatTreeNodes(files, 0)
+	case treeSortStatus:
+		return statusTreeNodes(files)
+	}
+	return buildTreeNodes(files)
+}
+
+// fuzzyMatch reports whether the runes of query appear in order in path,
+// ignoring case. A plain substring is the tightest such match.
+func fuzzyMatch(query, path string) bool {
+	q := []rune(strings.ToLower(query))
+	for _, r := range strings.ToLower(path) {
+		if len(q) == 0 {
+			break
+		}
+		if r == q[0] {
+			q = q[1:]
+		}
+	}
+	return len(q) == 0
+}
+
+// SetTreeQuery narrows the tree to files matching query and moves the tree
+// cursor, and the diff, to the first match.
+func (s *State) SetTreeQuery(query string) {
+	s.TreeQuery = query
+	s.TreeNodes = s.sortedTreeNodes()
+	s.TreeCursor = 0
+	s.TreeScroll = 0
+	s.EnsureTreeCursorVisible()
+	if query != "" {
+		s.JumpToFile(s.TreeCursorPath())
+	}
+}
+
+// JumpToFile scrolls the diff to the first visible hunk of file.
+func (s *State) JumpToFile(file string) {
+	for _, h := range s.Hunks {
+		if h.File == file && h.StartLine >= 0 {
+			s.JumpTo(h.StartLine)
+			return
+		}
+	}
+}
+
+// HandleTreeSearchKey handles key input while typing a tree search query.
+func HandleTreeSearchKey(s *State, ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		s.TreeSearchMode = false
+		s.SetTreeQuery("")
+	case tcell.KeyEnter:
+		s.TreeSearchMode = false
+	case tcell.KeyUp:
+		treeMoveCursor(s, -1)
+	case tcell.KeyDown:
+		treeMoveCursor(s, 1)
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if q := []rune(s.TreeQuery); len(q) > 0 {
+			s.SetTreeQuery(string(q[:len(q)-1]))
+		}
+	case tcell.KeyRune:
+		s.SetTreeQuery(s.TreeQuery + string(ev.Rune()))
+	}
+}
+
+// flatTreeNodes lists files by full path at the given depth.
+func flatTreeNodes(files []TreeFile, depth int) []TreeNode {
+	nodes := make([]TreeNode, 0, len(files))
+	for _, tf := range files {
+		nodes = append(nodes, TreeNode{
+			Display: tf.Path,
+			Path:    tf.Path,
+			Depth:   depth,
+			Added:   tf.Added,
+			Removed: tf.Removed,
+			Status:  tf.Status,
+		})
+	}
+	return nodes
+}
+
+// statusGroups orders and names the status groups of the status sort mode.
+var statusGroups = []struct {
+	status byte
+	name   string
+}{
+	{'A', "Added"},
+	{'M', "Modified"},
+	{'R', "Renamed"},
+	{'C', "Copied"},
+	{'D', "Deleted"},
+}
+
+// statusTreeNodes groups files under a header per status, alphabetically
+// within each group. Files without a known status count as modified.
+func statusTreeNodes(files []TreeFile) []TreeNode {
+	var nodes []TreeNode
+	for _, g := range statusGroups {
+		var group []TreeFile
+		for _, tf := range files {
+			st := tf.Status
+			if st == 0 {
+				st = 'M'
+			}
+			if st == g.status {
+				group = append(group, tf)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].Path < group[j].Path })
+		nodes = append(nodes, TreeNode{
+			Display: fmt.Sprintf("%s (%d)", g.name, len(group)),
+			IsDir:   true,
 		})
+		nodes = append(nodes, flatTreeNodes(group, 1)...)
 	}
+	return nodes
+}
 
-	s.TreeNodes = buildTreeNodes(s.TreeFiles)
+// CycleTreeSort switches to the next tree sort mode, keeping the cursor on
+// the same file.
+func (s *State) CycleTreeSort() {
+	path := s.TreeCursorPath()
+	s.TreeSort = (s.TreeSort + 1) % treeSortModes
+	s.TreeNodes = s.sortedTreeNodes()
+	for i, idx := range treeFileNodes(s.TreeNodes) {
+		if s.TreeNodes[idx].Path == path {
+			s.TreeCursor = i
+		}
+	}
+	s.EnsureTreeCursorVisible()
+	s.FlashMsg = "Tree sorted by " + treeSortNames[s.TreeSort]
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
 }
 
 // buildTreeNodes converts flat file list into a hierarchical tree,
@@ -156,6 +347,7 @@ func buildTreeNodes(files []TreeFile) []TreeNode {
 				IsDir:   false,
 				Added:   tf.Added,
 				Removed: tf.Removed,
+				Status:  tf.Status,
 			})
 		}
 	}
@@ -287,8 +479,11 @@ func drawTree(s *State) {
 		borderStyle = tcell.StyleDefault.Foreground(s.Theme.Accent)
 	}
 
-	// Header
+	// Header, or the search query while one is set
 	header := fmt.Sprintf(" Files (%d)", len(s.TreeFiles))
+	if s.TreeSearchMode || s.TreeQuery != "" {
+		header = fmt.Sprintf(" %d/%d /%s", len(treeFileNodes(s.TreeNodes)), len(s.TreeFiles), s.TreeQuery)
+	}
 	headerStyle := s.Theme.FileHeader
 	if s.TreeFocused {
 		headerStyle = tcell.StyleDefault.Bold(true).Foreground(s.Theme.Accent)
@@ -301,6 +496,10 @@ func drawTree(s *State) {
 		screen.SetContent(col, 0, r, nil, headerStyle)
 		col++
 	}
+	if s.TreeSearchMode && col < tw {
+		screen.SetContent(col, 0, ' ', nil, tcell.StyleDefault.Reverse(true))
+		col++
+	}
 	for col < tw {
 		screen.SetContent(col, 0, ' ', nil, s.Theme.Default)
 		col++
@@ -383,6 +582,9 @@ func drawTreeNode(s *State, screen tcell.Screen, y int, node TreeNode, width int
 	} else if isActive && !node.IsDir {
 		indicator = '▸'
 		indicatorStyle = rowBg.Foreground(s.Theme.Highlight)
+	} else if !node.IsDir && s.isReviewed(node.Path) {
+		indicator = '✓'
+		indicatorStyle = rowBg.Dim(true)
 	}
 	screen.SetContent(col, y, indicator, nil, indicatorStyle)
 	col++
@@ -416,12 +618,40 @@ func drawTreeNode(s *State, screen tcell.Screen, y int, node TreeNode, width int
 	addStr := fmt.Sprintf("+%d", node.Added)
 	remStr := fmt.Sprintf("-%d", node.Removed)
 	statsLen := len(addStr) + 1 + len(remStr)
-
-	nameStyle := rowBg
+	ballooned := s.FileSizes[node.Path].Ballooned()
+	if ballooned {
+		statsLen += 2 // "▲ "
+	}
+	covStr := s.coveragePercent(node.Path) // new lines covered
+	if covStr != "" {
+		statsLen += len(covStr) + 1
+	}
+
+	// Status icon, colored by change type like the name
+	statusStyle := rowBg
+	switch node.Status {
+	case 'A':
+		statusStyle = rowBg.Foreground(s.Theme.Added)
+	case 'D':
+		statusStyle = rowBg.Foreground(s.Theme.Removed)
+	case 'R', 'C':
+		statusStyle = rowBg.Foreground(s.Theme.Renamed)
+	}
+	if icon := statusIcon(s.Config.TreeIcons, node.Status); icon != 0 && col+2 < width {
+		screen.SetContent(col, y, icon, nil, statusStyle)
+		screen.SetContent(col+1, y, ' ', nil, rowBg)
+		col += 2
+	}
+
+	// Files marked done in review mode are dimmed
+	reviewed := s.isReviewed(node.Path)
+	nameStyle := statusStyle
 	if isFiltered {
 		nameStyle = rowBg.Foreground(s.Theme.Highlight).Bold(true)
 	} else if isActive {
-		nameStyle = rowBg.Bold(true)
+		nameStyle = statusStyle.Bold(true)
+	} else if reviewed {
+		nameStyle = statusStyle.Dim(true)
 	}
 
 	maxName := width - statsLen - col - 1
@@ -448,9 +678,23 @@ func drawTreeNode(s *State, screen tcell.Screen, y int, node TreeNode, width int
 		col++
 	}
 
-	// Stats
-	addStyle := rowBg.Foreground(s.Theme.Added)
-	remStyle := rowBg.Foreground(s.Theme.Removed)
+	// Stats, flagged when the file grew unexpectedly
+	addStyle := rowBg.Foreground(s.Theme.Added).Dim(reviewed)
+	remStyle := rowBg.Foreground(s.Theme.Removed).Dim(reviewed)
+	if ballooned && col+1 < width {
+		screen.SetContent(col, y, '▲', nil, remStyle.Bold(true))
+		screen.SetContent(col+1, y, ' ', nil, rowBg)
+		col += 2
+	}
+	if covStr != "" {
+		for _, r := range covStr + " " {
+			if col >= width {
+				break
+			}
+			screen.SetContent(col, y, r, nil, rowBg.Dim(true))
+			col++
+		}
+	}
 	for _, r := range addStr {
 		if col >= width {
 			break
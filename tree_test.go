@@ -216,3 +216,127 @@ func TestBasename(t *testing.T) {
 		}
 	}
 }
+
+func TestTreeSortChanges(t *testing.T) {
+	s := &State{TreeSort: treeSortChanges, TreeFiles: []TreeFile{
+		{Path: "a.go", Added: 1},
+		{Path: "dir/b.go", Added: 5, Removed: 5},
+		{Path: "c.go", Removed: 3},
+	}}
+	nodes := s.sortedTreeNodes()
+	want := []string{"dir/b.go", "c.go", "a.go"}
+	if len(nodes) != len(want) {
+		t.Fatalf("expected %d nodes, got %d", len(want), len(nodes))
+	}
+	for i, w := range want {
+		if nodes[i].Path != w || nodes[i].IsDir {
+			t.Errorf("node %d: got %q, want %q", i, nodes[i].Path, w)
+		}
+	}
+}
+
+func TestTreeSortStatus(t *testing.T) {
+	s := &State{TreeSort: treeSortStatus, TreeFiles: []TreeFile{
+		{Path: "gone.go", Status: 'D'},
+		{Path: "z.go", Status: 'M'},
+		{Path: "new.go", Status: 'A'},
+		{Path: "a.go"},
+	}}
+	nodes := s.sortedTreeNodes()
+	var got []string
+	for _, n := range nodes {
+		if n.IsDir {
+			got = append(got, n.Display)
+		} else {
+			got = append(got, n.Path)
+		}
+	}
+	want := []string{"Added (1)", "new.go", "Modified (2)", "a.go", "z.go", "Deleted (1)", "gone.go"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("node %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCycleTreeSortKeepsCursor(t *testing.T) {
+	s := &State{TreeFiles: []TreeFile{
+		{Path: "a.go", Added: 1},
+		{Path: "b.go", Added: 9},
+	}}
+	s.TreeNodes = s.sortedTreeNodes()
+	s.TreeCursor = 1 // b.go
+	s.CycleTreeSort()
+	if s.TreeSort != treeSortChanges {
+		t.Fatalf("expected changes sort, got %d", s.TreeSort)
+	}
+	if got := s.TreeCursorPath(); got != "b.go" {
+		t.Errorf("cursor moved to %q", got)
+	}
+}
+
+func TestStatusIcon(t *testing.T) {
+	tests := []struct {
+		set    string
+		status byte
+		want   rune
+	}{
+		{"", 'A', 'A'},
+		{"letter", 'R', 'R'},
+		{"symbol", 'D', '-'},
+		{"symbol", 'R', '→'},
+		{"none", 'M', 0},
+		{"", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := statusIcon(tt.set, tt.status); got != tt.want {
+			t.Errorf("statusIcon(%q, %c) = %q, want %q", tt.set, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		query, path string
+		want        bool
+	}{
+		{"", "a.go", true},
+		{"main", "cmd/main.go", true},
+		{"cmg", "cmd/main.go", true},
+		{"MAIN", "cmd/main.go", true},
+		{"gm", "cmd/main.go", false},
+		{"x", "cmd/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.query, tt.path); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.query, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSetTreeQuery(t *testing.T) {
+	s := &State{
+		TreeFiles: []TreeFile{{Path: "a.go"}, {Path: "src/state.go"}, {Path: "src/tree.go"}},
+		Hunks:     []Hunk{{File: "a.go", StartLine: 0}, {File: "src/tree.go", StartLine: 10}},
+		Lines:     make([]DisplayLine, 20),
+		Height:    5,
+	}
+	s.TreeNodes = s.sortedTreeNodes()
+	s.SetTreeQuery("tre")
+	if n := len(treeFileNodes(s.TreeNodes)); n != 1 {
+		t.Fatalf("expected 1 matching file, got %d", n)
+	}
+	if got := s.TreeCursorPath(); got != "src/tree.go" {
+		t.Errorf("cursor on %q, want src/tree.go", got)
+	}
+	if s.Scroll == 0 {
+		t.Error("expected the diff to jump to the match")
+	}
+	s.SetTreeQuery("")
+	if n := len(treeFileNodes(s.TreeNodes)); n != 3 {
+		t.Errorf("expected all 3 files after clearing, got %d", n)
+	}
+}
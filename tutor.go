@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Tutor walks through the basic keys on a made-up repository, like
+// vimtutor: a panel above the status bar shows one step at a time and the
+// next one comes up once the step's keys have been used.
+type Tutor struct {
+	Step    int
+	typed   []rune // runes typed during the step, for multi-key steps
+	scroll  int    // scroll position at the start of the step
+	sideBy  bool   // side-by-side at the start of the step
+	sawHelp bool
+}
+
+// tutor is the running tutorial, nil outside --tutor.
+var tutor *Tutor
+
+// tutorStep is one prompt of the tutorial and the test that it is done.
+type tutorStep struct {
+	Prompt string
+	Done   func(s *State, t *Tutor) bool
+}
+
+var tutorSteps = []tutorStep{
+	{"Welcome to wiff! This is the diff of tutor.go, a file with a few unstaged changes. " +
+		"j and k (or ↓ and ↑) scroll; d and u move half a page. Press j a few times.",
+		func(s *State, t *Tutor) bool {
+			return s.Scroll >= t.scroll+3 || strings.Count(string(t.typed), "j") >= 3
+		}},
+	{"Changes come in hunks. ]c jumps to the next hunk and [c to the previous one " +
+		"(]f and [f, or Tab, go from file to file). Press ]c.",
+		func(s *State, t *Tutor) bool { return strings.HasSuffix(string(t.typed), "]c") }},
+	{"Each hunk header shows a label letter. y followed by a label yanks the added lines " +
+		"of that hunk to the clipboard (Y the removed ones, p the patch). Press y, then a label.",
+		func(s *State, t *Tutor) bool { return s.LastAction.Cmd == 'y' }},
+	{"A followed by a label stages that hunk alone, like git add -p. It then leaves this " +
+		"diff of unstaged changes; wiff --staged shows it. Press A, then a label.",
+		func(s *State, t *Tutor) bool { return s.LastAction.Cmd == 'A' && s.LastAction.On }},
+	{"s switches between the inline and the side-by-side view. Press s.",
+		func(s *State, t *Tutor) bool { return s.SideBySide != t.sideBy }},
+	{"/ searches the diff. Type /return and press Enter.",
+		func(s *State, t *Tutor) bool { return s.SearchQuery != "" && !s.SearchMode }},
+	{"n and N go to the next and previous match; Esc clears the search. Press n.",
+		func(s *State, t *Tutor) bool { return strings.ContainsRune(string(t.typed), 'n') }},
+	{"? lists every key, and / in that list filters it. Press ?, look around, then close it with q.",
+		func(s *State, t *Tutor) bool { return t.sawHelp && !s.ShowHelp }},
+	{"That's the tour: j/k, ]c, y, A, s, / and ?. Keep exploring, or press q to quit. " +
+		"Run wiff in any repository to see its changes.",
+		func(s *State, t *Tutor) bool { return false }},
+}
+
+// newTutor starts the tutorial at its first step.
+func newTutor(s *State) *Tutor {
+	return &Tutor{scroll: s.Scroll, sideBy: s.SideBySide}
+}
+
+// observe notes a key handled by s and moves to the next step when the
+// current one is done.
+func (t *Tutor) observe(s *State, ev *tcell.EventKey) {
+	if ev.Key() == tcell.KeyRune {
+		t.typed = append(t.typed, ev.Rune())
+	}
+	if s.ShowHelp {
+		t.sawHelp = true
+	}
+	if t.Step < len(tutorSteps)-1 && tutorSteps[t.Step].Done(s, t) {
+		t.Step++
+		t.typed = nil
+		t.sawHelp = false
+		t.scroll, t.sideBy = s.Scroll, s.SideBySide
+	}
+}
+
+// setupTutor creates the repository of the tutorial in a temporary
+// directory and changes to it: tutor.go committed, then edited in a few
+// places. The caller removes the directory.
+func setupTutor() (string, error) {
+	dir, err := os.MkdirTemp("", "wiff-tutor-")
+	if err != nil {
+		return "", err
+	}
+	git := func(args ...string) error {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=wiff", "-c", "user.email=wiff@localhost"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	write := func(text string) error {
+		return os.WriteFile(filepath.Join(dir, "tutor.go"), []byte(text), 0o644)
+	}
+	if err := git("init", "-q"); err != nil {
+		return dir, err
+	}
+	if err := write(tutorOld); err != nil {
+		return dir, err
+	}
+	if err := git("add", "tutor.go"); err != nil {
+		return dir, err
+	}
+	if err := git("commit", "-qm", "Add tutor.go"); err != nil {
+		return dir, err
+	}
+	if err := write(tutorNew); err != nil {
+		return dir, err
+	}
+	return dir, os.Chdir(dir)
+}
+
+// tutorRows returns the rows of the tutorial panel: a title and the
+// wrapped prompt.
+func (s *State) tutorRows() int {
+	if tutor == nil {
+		return 0
+	}
+	return 1 + len(wrapText(tutorSteps[tutor.Step].Prompt, max(s.Width-2, 10)))
+}
+
+// drawTutor draws the tutorial panel above the check panel and status bar.
+func drawTutor(s *State) {
+	rows := s.tutorRows()
+	if rows == 0 {
+		return
+	}
+	top := s.Height - s.statusRows() - s.checkPanelRows() - rows
+	title := fmt.Sprintf("─ tutor %d/%d ", tutor.Step+1, len(tutorSteps))
+	col := drawText(s.Screen, 0, top, title, s.Theme.Dim, s.Width)
+	for ; col < s.Width; col++ {
+		s.Screen.SetContent(col, top, '─', nil, s.Theme.Dim)
+	}
+	for i, line := range wrapText(tutorSteps[tutor.Step].Prompt, max(s.Width-2, 10)) {
+		col := drawText(s.Screen, 1, top+1+i, line, s.Theme.Default, s.Width)
+		s.Screen.SetContent(0, top+1+i, ' ', nil, s.Theme.Default)
+		clearToEnd(s, s.Screen, col, top+1+i, s.Width)
+	}
+}
+
+const tutorOld = `package tutor
+
+import "strings"
+
+// Greet returns a greeting for name.
+func Greet(name string) string {
+	return "Hello, " + name
+}
+
+// Shout returns text in capitals.
+func Shout(text string) string {
+	return strings.ToUpper(text)
+}
+
+// Count returns the number of words in text.
+func Count(text string) int {
+	n := 0
+	for _, w := range strings.Split(text, " ") {
+		if w != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Repeat returns text n times, separated by spaces.
+func Repeat(text string, n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = text
+	}
+	return strings.Join(parts, " ")
+}
+
+// Reverse returns the runes of text in reverse order.
+func Reverse(text string) string {
+	r := []rune(text)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+`
+
+const tutorNew = `package tutor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Greet returns a greeting for name.
+func Greet(name string) string {
+	if name == "" {
+		name = "world"
+	}
+	return fmt.Sprintf("Hello, %s!", name)
+}
+
+// Shout returns text in capitals.
+func Shout(text string) string {
+	return strings.ToUpper(text)
+}
+
+// Count returns the number of words in text.
+func Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+// Repeat returns text n times, separated by spaces.
+func Repeat(text string, n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = text
+	}
+	return strings.Join(parts, " ")
+}
+
+// Reverse returns the runes of text in reverse order.
+func Reverse(text string) string {
+	r := []rune(text)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// Title returns text with the first letter of each word in capitals.
+func Title(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+`
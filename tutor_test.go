@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestTutorWalkthrough(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir()) // keep the search out of the real history
+	dir, err := setupTutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dir, "wiff-tutor-") {
+		t.Errorf("tutor repository in %s", dir)
+	}
+	t.Cleanup(func() { tutor = nil })
+
+	s := &State{Width: 80, Height: 40, ContextLines: 3, LineNumbers: true}
+	if err := loadDiff(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Hunks) < 3 {
+		t.Fatalf("tutor diff has %d hunks", len(s.Hunks))
+	}
+	tutor = newTutor(s)
+	press := func(keys ...*tcell.EventKey) {
+		t.Helper()
+		for _, ev := range keys {
+			HandleKey(s, ev)
+			tutor.observe(s, ev)
+		}
+	}
+	runes := func(text string) (evs []*tcell.EventKey) {
+		for _, r := range text {
+			evs = append(evs, makeKeyEvent(r))
+		}
+		return evs
+	}
+	enter := tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+
+	steps := [][]*tcell.EventKey{
+		runes("jjj"),
+		runes("]c"),
+		runes("y" + s.Hunks[1].Label),
+		runes("A" + s.Hunks[0].Label),
+		runes("s"),
+		append(runes("/return"), enter),
+		runes("n"),
+		runes("?q"),
+	}
+	for i, keys := range steps {
+		if tutor.Step != i {
+			t.Fatalf("at step %d, want %d", tutor.Step, i)
+		}
+		press(keys...)
+	}
+	if tutor.Step != len(tutorSteps)-1 {
+		t.Errorf("tutorial ends at step %d of %d", tutor.Step+1, len(tutorSteps))
+	}
+	if rows := s.tutorRows(); rows < 2 || s.viewRows() != s.Height-1-rows {
+		t.Errorf("tutor panel takes %d rows, diff gets %d", rows, s.viewRows())
+	}
+}
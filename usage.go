@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// usageEntry is one line of --help and the man page: a flag, argument or
+// example and what it does.
+type usageEntry struct {
+	Name string
+	Desc string
+}
+
+// usageSynopsis lists the ways to run wiff.
+var usageSynopsis = []string{
+	"wiff [flags] [ref] [ref2] [--] [path...]",
+	"wiff [flags] fileA fileB",
+	"wiff [flags] patch-dir/",
+}
+
+// flagHelp documents the command-line flags parseArgs reads.
+var flagHelp = []usageEntry{
+	{"-s", "Side-by-side mode"},
+	{"-e", "Open file explorer"},
+	{"-N", "Disable line numbers (on by default)"},
+	{"-W", "Disable line wrapping (on by default)"},
+	{"-B", "Disable diff background tints (on by default)"},
+	{"-S", "Disable syntax highlighting (on by default)"},
+	{"-U<n>", "Context lines (default 3)"},
+	{"-t <name>", "Color theme (default: by terminal background, env: WIFF_THEME)"},
+	{"--color=<n>", "Color depth: auto, truecolor, 256, 16 or none (default: auto)"},
+	{"--color-moved", "Color moved blocks of lines distinctly"},
+	{"--diff-algorithm=<a>", "myers, minimal, patience or histogram"},
+	{"-M, --find-renames[=<n>]", "Detect renames (optional similarity, e.g. 50%)"},
+	{"--exclude <pat>", "Hide matching paths (repeatable, also read from .wiffignore)"},
+	{"--tab <args>", "Open another diff in a tab, e.g. --tab --staged (repeatable)"},
+	{"--split <args>", "Show another diff side by side with the first"},
+	{"--low-bandwidth", "No tints or highlighting, slower reloads (slow SSH links)"},
+	{"--cpuprofile <file>", "Write a CPU profile (go tool pprof)"},
+	{"--memprofile <file>", "Write a heap profile on exit"},
+	{"--debug", "Overlay render and build times, lines, cache and watcher counts"},
+	{"--vcs <name>", "git, jj or hg (default: detected from the repository)"},
+	{"--json", "Print the parsed diff (files, hunks, labels, lines) as JSON"},
+	{"--quickfix", "Print each hunk as file:line: text, for vim -q or a quickfix list"},
+	{"--strict", "Fail (exit 2) on piped input that isn't a diff instead of showing it"},
+	{"--coverage <file>", "Mark covered added lines (Go coverprofile, lcov or cobertura)"},
+	{"--diagnostics <file>", "Mark lines with linter issues (golangci-lint JSON or file:line: text)"},
+	{"--review", "Review one file at a time; ]f or Enter marks it done (also gr)"},
+	{"--no-alt-screen", "Draw on the main screen, leaving the last view in the scrollback"},
+	{"--log-file <file>", "Append a debug log: commands run, watcher events, reloads, failed applies"},
+	{"--verbose", "Log to stderr with --json, --command or --script"},
+	{"--confirm-apply", "Confirm each stage/unstage, showing the git command and patch"},
+	{"--command <keys>", "Run keys without a terminal (e.g. \"AaAcq\"), printing messages"},
+	{"--script <file>", "Like --command with keys read from file (# comments)"},
+	{"--staged", "Show staged changes (same as --cached)"},
+	{"--cached", "Show staged changes (same as --staged)"},
+	{"--listen <addr>", "Take jump/reload requests from editors on a socket or local port"},
+	{"--tutor", "Learn the basic keys step by step on a made-up diff"},
+	{"--themes", "List available themes"},
+	{"--man", "Print this documentation as a man page (roff)"},
+	{"-v, --version", "Show version"},
+	{"-h, --help", "Show this help"},
+}
+
+// argHelp documents the positional arguments.
+var argHelp = []usageEntry{
+	{"ref", "Git ref to diff against (default: unstaged changes)"},
+	{"ref1 ref2", "Diff between two refs"},
+	{"path...", "Limit the diff to these paths; after -- they may be deleted files"},
+	{"patch-dir/", "Review the git format-patch files (*.patch) in a directory"},
+}
+
+// exampleHelp shows common invocations.
+var exampleHelp = []usageEntry{
+	{"wiff", "Show unstaged changes"},
+	{"wiff HEAD", "Diff against HEAD"},
+	{"wiff HEAD~3", "Diff against 3 commits ago"},
+	{"wiff HEAD~3..HEAD", "Diff a commit range"},
+	{"wiff main feature", "Diff between branches"},
+	{"wiff HEAD~3 -- src/", "Diff against 3 commits ago, in src/ only"},
+	{"wiff a.txt b.txt", "Diff two files (no repository needed)"},
+	{"wiff --staged", "Show staged changes"},
+	{"wiff -s", "Side-by-side mode"},
+	{"wiff --tutor", "Take the tutorial"},
+	{"vim -q <(wiff --quickfix)", "Load the changed spots into vim's quickfix list"},
+	{"git diff | wiff", "Read diff from pipe"},
+	{"git format-patch --stdout main | wiff", "Review a patch series (mbox)"},
+}
+
+func printUsage() {
+	writeUsage(os.Stdout)
+}
+
+// writeUsage writes the --help text: synopsis, flags, arguments, examples
+// and the keymap.
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "wiff - a terminal diff viewer\n\n")
+	for i, syn := range usageSynopsis {
+		if i == 0 {
+			fmt.Fprintf(w, "Usage: %s\n", syn)
+		} else {
+			fmt.Fprintf(w, "       %s\n", syn)
+		}
+	}
+	section := func(title string, entries []usageEntry) {
+		fmt.Fprintf(w, "\n%s:\n", title)
+		for _, e := range entries {
+			usageLine(w, "  ", e.Name, e.Desc)
+		}
+	}
+	section("Flags", flagHelp)
+	section("Arguments", argHelp)
+	section("Examples", exampleHelp)
+	fmt.Fprintf(w, "\nKeyboard Shortcuts:\n")
+	for _, g := range keyGroups {
+		fmt.Fprintf(w, "  %s\n", g.Name)
+		for _, kb := range g.Keys {
+			usageLine(w, "    ", kb.Keys, kb.Name)
+		}
+	}
+}
+
+// usageLine writes name and desc in two columns, desc after two spaces
+// when name is too wide for its column.
+func usageLine(w io.Writer, indent, name, desc string) {
+	const nameWidth = 12
+	pad := max(nameWidth-utf8.RuneCountInString(name), 2)
+	fmt.Fprintf(w, "%s%s%s%s\n", indent, name, strings.Repeat(" ", pad), desc)
+}
+
+// writeManPage writes the documentation of printUsage as a roff man page,
+// for packagers: wiff --man > wiff.1
+func writeManPage(w io.Writer) {
+	fmt.Fprintf(w, ".TH WIFF 1 \"\" \"wiff %s\" \"User Commands\"\n", roffEscape(version))
+	fmt.Fprintf(w, ".SH NAME\nwiff \\- a terminal diff viewer\n")
+	fmt.Fprintf(w, ".SH SYNOPSIS\n")
+	for i, syn := range usageSynopsis {
+		if i > 0 {
+			fmt.Fprintf(w, ".br\n")
+		}
+		fmt.Fprintf(w, "%s\n", roffEscape(syn))
+	}
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roffEscape("wiff shows a diff of a git, jj or hg repository, "+
+		"two files or piped input in the terminal, with syntax highlighting, side-by-side mode, "+
+		"live updates and hunk labels: each hunk gets a letter, and keys followed by that letter "+
+		"yank, stage or apply it."))
+	manSection := func(title string, entries []usageEntry) {
+		fmt.Fprintf(w, ".SH %s\n", title)
+		for _, e := range entries {
+			fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(e.Name), roffEscape(e.Desc))
+		}
+	}
+	manSection("OPTIONS", flagHelp)
+	manSection("ARGUMENTS", argHelp)
+	fmt.Fprintf(w, ".SH KEYS\n")
+	for _, g := range keyGroups {
+		fmt.Fprintf(w, ".SS %s\n", roffEscape(g.Name))
+		for _, kb := range g.Keys {
+			fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(kb.Keys), roffEscape(kb.Name))
+		}
+	}
+	manSection("EXAMPLES", exampleHelp)
+	fmt.Fprintf(w, ".SH FILES\n")
+	for _, e := range []usageEntry{
+		{"~/.config/wiff/config", "Configuration ($XDG_CONFIG_HOME/wiff/config, or the path in $WIFF_CONFIG)"},
+		{".wiffignore", "Path patterns hidden from the diff, one per line, at the repository root"},
+		{".wiff/notes", "Notes on lines, one path:line text per line, at the repository root"},
+		{"~/.local/state/wiff/*_history", "Past inputs of the search and other prompts, recalled with Up, Down and Ctrl-R ($XDG_STATE_HOME/wiff)"},
+	} {
+		fmt.Fprintf(w, ".TP\n.I %s\n%s\n", roffEscape(e.Name), roffEscape(e.Desc))
+	}
+	fmt.Fprintf(w, ".SH SEE ALSO\ngit-diff(1)\n")
+}
+
+// roffEscape makes text safe in a roff line: backslashes and hyphens are
+// escaped, and a leading dot or quote can't start a request.
+func roffEscape(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
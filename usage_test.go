@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUsageAndManPageCoverTheKeymap(t *testing.T) {
+	var usage, man bytes.Buffer
+	writeUsage(&usage)
+	writeManPage(&man)
+	for _, f := range flagHelp {
+		if !strings.Contains(usage.String(), f.Name) {
+			t.Errorf("--help lacks %s", f.Name)
+		}
+		if !strings.Contains(man.String(), ".B "+roffEscape(f.Name)+"\n") {
+			t.Errorf("man page lacks %s", f.Name)
+		}
+	}
+	for _, g := range keyGroups {
+		if !strings.Contains(usage.String(), "  "+g.Name+"\n") {
+			t.Errorf("--help lacks group %s", g.Name)
+		}
+		if !strings.Contains(man.String(), ".SS "+roffEscape(g.Name)+"\n") {
+			t.Errorf("man page lacks group %s", g.Name)
+		}
+	}
+	if !strings.HasPrefix(man.String(), ".TH WIFF 1 ") {
+		t.Errorf("man page starts %q", man.String()[:20])
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"--staged", `\-\-staged`},
+		{`a\b`, `a\eb`},
+		{".wiffignore", `\&.wiffignore`},
+		{"'{a-z}", `\&'{a\-z}`},
+	} {
+		if got := roffEscape(tt.in); got != tt.want {
+			t.Errorf("roffEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
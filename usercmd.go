@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserCommand is a command bound to a key in the config:
+//
+//	command.<key> = <command>        run with the TUI suspended
+//	command.<key>.popup = <command>  run in the background, output in a popup
+type UserCommand struct {
+	Key     rune
+	Command string
+	Popup   bool
+}
+
+// minLabelLetters is the fewest label letters user commands may leave: with
+// fewer, hunk labels soon grow long.
+const minLabelLetters = 6
+
+// labelLettersLeft returns how many letters are left for hunk labels once
+// keys are bound to user commands.
+func labelLettersLeft(keys []rune) int {
+	n := 0
+	for _, r := range labelLetters() {
+		if !slices.Contains(keys, r) {
+			n++
+		}
+	}
+	return n
+}
+
+// parseCommandKey parses the part of a command.* config key after the dot.
+func parseCommandKey(name string) (rune, bool, error) {
+	name, popup := strings.CutSuffix(name, ".popup")
+	r := []rune(name)
+	if len(r) != 1 {
+		return 0, false, fmt.Errorf("command key must be a single character, got %q", name)
+	}
+	if reservedKeys[r[0]] {
+		return 0, false, fmt.Errorf("key %q is already bound", name)
+	}
+	return r[0], popup, nil
+}
+
+// commandKeys returns the keys of the configured user commands.
+func (c *Config) commandKeys() []rune {
+	keys := make([]rune, len(c.Commands))
+	for i, uc := range c.Commands {
+		keys[i] = uc.Key
+	}
+	return keys
+}
+
+// userCommand returns the command bound to r, if any.
+func (s *State) userCommand(r rune) (UserCommand, bool) {
+	for _, uc := range s.Config.Commands {
+		if uc.Key == r {
+			return uc, true
+		}
+	}
+	return UserCommand{}, false
+}
+
+// commandVars returns the placeholder values for a user command. The patch
+// of the current hunk is written to a temp file; the returned cleanup
+// removes it.
+func (s *State) commandVars() (map[string]string, func(), error) {
+	ref := repo.Head()
+	if len(s.Refs) > 0 && !s.NoIndex {
+		ref = s.Refs[0]
+	}
+	vars := map[string]string{
+		"file": s.CurrentFile(),
+		"line": fmt.Sprint(max(s.CurrentLineNo(), 1)),
+		"ref":  ref,
+	}
+	cleanup := func() {}
+	if len(s.Hunks) > 0 {
+		f, err := os.CreateTemp("", "wiff-hunk-*.patch")
+		if err != nil {
+			return nil, cleanup, err
+		}
+		_, err = f.WriteString(s.Hunks[s.CurrentHunkIndex()].AsFullPatch())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		cleanup = func() { _ = os.Remove(f.Name()) }
+		if err != nil {
+			cleanup()
+			return nil, func() {}, err
+		}
+		vars["hunk_patch"] = f.Name()
+	}
+	return vars, cleanup, nil
+}
+
+// EventCommandDone is posted when a background user command finishes.
+type EventCommandDone struct {
+	t       time.Time
+	command string
+	output  string
+	err     error
+}
+
+func (e *EventCommandDone) When() time.Time { return e.t }
+
+// runUserCommand runs a user command for the current file and hunk. A
+// command with an {input} placeholder asks for its value first.
+func runUserCommand(s *State, uc UserCommand) {
+	if !strings.Contains(uc.Command, "{input}") {
+		runUserCommandWith(s, uc, nil)
+		return
+	}
+	OpenPrompt(s, &InputPrompt{
+		Label:   uc.Command + ": ",
+		History: "command",
+		OnSubmit: func(s *State, input string) {
+			runUserCommandWith(s, uc, map[string]string{"input": input})
+		},
+	})
+}
+
+// runUserCommandWith runs a user command with the placeholders of the
+// current file and hunk, and extra.
+func runUserCommandWith(s *State, uc UserCommand, extra map[string]string) {
+	vars, cleanup, err := s.commandVars()
+	if err != nil {
+		cleanup()
+		s.FlashMsg = fmt.Sprintf("%s: %v", uc.Command, err)
+		s.FlashExpiry = time.Now().Add(3 * time.Second)
+		return
+	}
+	maps.Copy(vars, extra)
+	args := expandCommand(uc.Command, vars)
+	if len(args) == 0 {
+		cleanup()
+		return
+	}
+
+	if !uc.Popup {
+		defer cleanup()
+		if err := runSuspended(s, args); err != nil {
+			s.FlashMsg = fmt.Sprintf("%s: %v", args[0], err)
+			s.FlashExpiry = time.Now().Add(3 * time.Second)
+		}
+		reloadAfterOpen(s)
+		return
+	}
+
+	s.FlashMsg = "Running " + uc.Command + "…"
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	go func() {
+		defer cleanup()
+		cmd := exec.Command(args[0], args[1:]...)
+		if root, err := repo.Root(); err == nil {
+			cmd.Dir = root
+		}
+		out, err := cmd.CombinedOutput()
+		if s.Screen != nil {
+			_ = s.Screen.PostEvent(&EventCommandDone{t: time.Now(), command: uc.Command, output: string(out), err: err})
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommandVars(t *testing.T) {
+	s := &State{
+		Refs: []string{"main"},
+		Hunks: []Hunk{{File: "a.go", Label: "a", OldStart: 3, NewStart: 3,
+			Lines: []Line{{Op: '+', Content: "added"}}}},
+		Width: 80, Height: 20,
+	}
+	s.BuildLines()
+	vars, cleanup, err := s.commandVars()
+	if err != nil {
+		t.Fatalf("commandVars: %v", err)
+	}
+	if vars["file"] != "a.go" || vars["ref"] != "main" {
+		t.Errorf("vars = %v", vars)
+	}
+	patch, err := os.ReadFile(vars["hunk_patch"])
+	if err != nil || !strings.Contains(string(patch), "+added") {
+		t.Errorf("hunk patch = %q, %v", patch, err)
+	}
+	cleanup()
+	if _, err := os.Stat(vars["hunk_patch"]); !os.IsNotExist(err) {
+		t.Error("expected cleanup to remove the patch file")
+	}
+}
+
+func TestUserCommandInputIsNotExpanded(t *testing.T) {
+	s := &State{Hunks: []Hunk{{File: "a.go", NewStart: 1, Lines: []Line{{Op: '+', Content: "x"}}}}, Width: 80, Height: 20}
+	s.BuildLines()
+	vars, cleanup, err := s.commandVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	vars["input"] = "{file}"
+	for range 20 {
+		if got := expandCommand("grep {input} {file}", vars); strings.Join(got, " ") != "grep {file} a.go" {
+			t.Fatalf("args = %q", got)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VCS is the version control system whose repository the viewer runs in.
+// The diff, file contents, root, branch and applying hunks go through it;
+// git is the reference, jj and hg map the same operations onto their own
+// commands. Features built on git's index or attributes (staging, textconv,
+// generated-file attributes, forge links) stay git-only.
+type VCS interface {
+	// Name is the command and the name of the repository's metadata
+	// directory without the dot: "git", "jj" or "hg".
+	Name() string
+	// DiffArgs returns the command line of the diff s shows.
+	DiffArgs(s *State) []string
+	// Root returns the top-level directory of the repository.
+	Root() (string, error)
+	// Branch names what is checked out, for the status bar, or "".
+	Branch() string
+	// Tracking compares the branch with its upstream, nil without one.
+	Tracking() *Tracking
+	// Show returns the content of file, relative to the root, at rev.
+	Show(rev, file string) ([]byte, error)
+	// Apply applies patch to the working tree, or undoes it when reverse.
+	// The output is the tool's complaint when it fails.
+	Apply(patch string, reverse bool) ([]byte, error)
+	// Head is the revision of the current commit.
+	Head() string
+	// Base is the old side of a diff without refs: the index for git,
+	// the parent of the working copy otherwise.
+	Base() string
+	// Parent is the revision before rev.
+	Parent(rev string) string
+	// Recent returns up to n commits, newest first, from the last one.
+	Recent(n int) []Commit
+	// LineLog returns up to n commits, newest first, that touched lines
+	// start to end of file at rev, following them back through history.
+	LineLog(rev, file string, start, end, n int) ([]Commit, error)
+}
+
+// Tracking is how far a branch and its upstream have diverged: commits
+// only the branch has, and commits only the upstream has.
+type Tracking struct {
+	Ahead, Behind int
+}
+
+// String shows the counts as shell prompts do, " ↑2 ↓1", leaving out
+// zeros: "" when the branch is even with its upstream, or has none.
+func (t *Tracking) String() string {
+	if t == nil {
+		return ""
+	}
+	var sb strings.Builder
+	if t.Ahead > 0 {
+		fmt.Fprintf(&sb, " ↑%d", t.Ahead)
+	}
+	if t.Behind > 0 {
+		fmt.Fprintf(&sb, " ↓%d", t.Behind)
+	}
+	return sb.String()
+}
+
+// Commit is a commit of the history, for the start menu and line history.
+type Commit struct {
+	Rev     string // short hash
+	Subject string
+	Author  string // LineLog only
+	Date    string // relative, LineLog only
+}
+
+// parseCommits parses lines of a hash, a tab and a subject.
+func parseCommits(out string) []Commit {
+	var commits []Commit
+	for _, line := range strings.Split(out, "\n") {
+		if rev, subject, ok := strings.Cut(line, "\t"); ok && rev != "" {
+			commits = append(commits, Commit{Rev: rev, Subject: subject})
+		}
+	}
+	return commits
+}
+
+// parseLineLog parses lines of a hash, author, date and subject separated
+// by tabs.
+func parseLineLog(out string) []Commit {
+	var commits []Commit
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.SplitN(line, "\t", 4)
+		if len(f) == 4 && f[0] != "" {
+			commits = append(commits, Commit{Rev: f[0], Author: f[1], Date: f[2], Subject: f[3]})
+		}
+	}
+	return commits
+}
+
+// repo is the backend of the current repository, set at startup.
+var repo VCS = gitVCS{}
+
+// vcsByName returns the backend called name.
+func vcsByName(name string) (VCS, error) {
+	for _, v := range []VCS{gitVCS{}, jjVCS{}, hgVCS{}} {
+		if v.Name() == name {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown vcs %q (git, jj or hg)", name)
+}
+
+// pickVCS returns the backend named by --vcs, or else the one of the
+// repository the current directory is in.
+func pickVCS(name string) (VCS, error) {
+	if name != "" {
+		return vcsByName(name)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return gitVCS{}, nil
+	}
+	return detectVCS(dir), nil
+}
+
+// detectVCS returns the backend of the repository dir is in: the nearest
+// directory up with a .jj, .hg or .git entry decides, jj first since it
+// shares its directory with a colocated git repository. Outside any
+// repository it is git, which reports the error.
+func detectVCS(dir string) VCS {
+	for {
+		for _, v := range []VCS{jjVCS{}, hgVCS{}, gitVCS{}} {
+			if _, err := os.Stat(filepath.Join(dir, "."+v.Name())); err == nil {
+				return v
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return gitVCS{}
+		}
+		dir = parent
+	}
+}
+
+// rangeRefs returns the two ends of the refs of a diff, to "" for the
+// working copy: two refs, a range whose open ends are head, or one ref
+// against the working copy. ok is false without refs.
+func rangeRefs(refs []string, head string) (from, to string, ok bool) {
+	switch {
+	case len(refs) >= 2:
+		return refs[0], refs[1], true
+	case len(refs) == 1:
+		if from, to, ok := splitRange(refs[0]); ok {
+			return orRev(from, head), orRev(to, head), true
+		}
+		return refs[0], "", true
+	}
+	return "", "", false
+}
+
+// commandOutput runs argv in dir ("" for the current directory) and
+// returns its standard output, trimmed.
+func commandOutput(dir string, argv ...string) (string, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = dir
+	start := time.Now()
+	out, err := cmd.Output()
+	logCommand(cmd, start, err)
+	return strings.TrimSpace(string(out)), err
+}
+
+// patchApply applies patch in the root of v's repository with patch(1),
+// for the backends without a command of their own that can also revert.
+func patchApply(v VCS, patch string, reverse bool) ([]byte, error) {
+	// Short flags, which GNU and BSD patch share: silent, never ask,
+	// and skip patches that look applied already (or reverse)
+	args := []string{"-p1", "-s", "-t", "-N"}
+	if reverse {
+		args = []string{"-p1", "-s", "-t", "-R"}
+	}
+	cmd := exec.Command("patch", args...)
+	if root, err := v.Root(); err == nil {
+		cmd.Dir = root
+	}
+	cmd.Stdin = strings.NewReader(patch)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	return out, err
+}
+
+type gitVCS struct{}
+
+func (gitVCS) Name() string { return "git" }
+
+func (gitVCS) DiffArgs(s *State) []string {
+	return append([]string{"git"}, s.gitDiffArgs()...)
+}
+
+func (gitVCS) Root() (string, error) {
+	return commandOutput("", "git", "rev-parse", "--show-toplevel")
+}
+
+// Branch is the branch name, or the short commit hash when HEAD is
+// detached.
+func (gitVCS) Branch() string {
+	branch, err := commandOutput("", "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return ""
+	}
+	if branch == "HEAD" {
+		if hash, err := commandOutput("", "git", "rev-parse", "--short", "HEAD"); err == nil {
+			return hash
+		}
+	}
+	return branch
+}
+
+func (gitVCS) Tracking() *Tracking {
+	out, err := commandOutput("", "git", "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	if err != nil {
+		return nil
+	}
+	var t Tracking
+	if _, err := fmt.Sscanf(out, "%d\t%d", &t.Ahead, &t.Behind); err != nil {
+		return nil
+	}
+	return &t
+}
+
+func (gitVCS) Show(rev, file string) ([]byte, error) {
+	if rev == revIndex {
+		rev = "" // :file is the staged version
+	}
+	cmd := exec.Command("git", "show", rev+":"+file)
+	start := time.Now()
+	out, err := cmd.Output()
+	logCommand(cmd, start, err)
+	return out, err
+}
+
+func (v gitVCS) Apply(patch string, reverse bool) ([]byte, error) {
+	args := []string{"apply"}
+	if reverse {
+		args = append(args, "-R")
+	}
+	cmd := exec.Command("git", args...)
+	if root, err := v.Root(); err == nil {
+		cmd.Dir = root
+	}
+	cmd.Stdin = strings.NewReader(patch)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logCommand(cmd, start, err)
+	return out, err
+}
+
+func (gitVCS) Head() string { return "HEAD" }
+func (gitVCS) Base() string { return revIndex }
+
+func (gitVCS) Parent(rev string) string { return rev + "~1" }
+
+func (gitVCS) Recent(n int) []Commit {
+	out, _ := commandOutput("", "git", "log", "-n", strconv.Itoa(n), "--format=%h%x09%s")
+	return parseCommits(out)
+}
+
+func (gitVCS) LineLog(rev, file string, start, end, n int) ([]Commit, error) {
+	root, err := gitVCS{}.Root()
+	if err != nil {
+		return nil, err
+	}
+	out, err := commandOutput(root, "git", "log", "--no-patch", "-n", strconv.Itoa(n),
+		"--format=%h%x09%an%x09%ar%x09%s", fmt.Sprintf("-L%d,%d:%s", start, end, file), rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	return parseLineLog(out), nil
+}
+
+// jjVCS is Jujutsu: the working copy is the commit @, snapshotted by every
+// command, so there is no index to stage into.
+type jjVCS struct{}
+
+func (jjVCS) Name() string { return "jj" }
+
+func (jjVCS) DiffArgs(s *State) []string {
+	args := []string{"jj", "diff", "--git", "--color=never", "--no-pager", fmt.Sprintf("--context=%d", s.ContextLines)}
+	if from, to, ok := rangeRefs(s.Refs, "@"); ok {
+		args = append(args, "--from", from, "--to", orRev(to, "@"))
+	}
+	return append(args, s.Paths...)
+}
+
+func (jjVCS) Root() (string, error) {
+	return commandOutput("", "jj", "root")
+}
+
+// Branch is the bookmarks on the working-copy commit, or its change id.
+func (jjVCS) Branch() string {
+	out, err := commandOutput("", "jj", "log", "--no-graph", "--color=never", "-r", "@",
+		"-T", `if(bookmarks, bookmarks.join(" "), change_id.short())`)
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// Tracking is nil: jj's working copy isn't on a bookmark to compare.
+func (jjVCS) Tracking() *Tracking { return nil }
+
+func (jjVCS) Show(rev, file string) ([]byte, error) {
+	return exec.Command("jj", "file", "show", "--no-pager", "-r", rev, "root-file:"+strconv.Quote(file)).Output()
+}
+
+func (v jjVCS) Apply(patch string, reverse bool) ([]byte, error) {
+	return patchApply(v, patch, reverse)
+}
+
+func (jjVCS) Head() string { return "@" }
+func (jjVCS) Base() string { return "@-" }
+
+func (jjVCS) Parent(rev string) string { return rev + "-" }
+
+// Recent starts at @-, the working-copy commit being the diff itself.
+func (jjVCS) Recent(n int) []Commit {
+	out, _ := commandOutput("", "jj", "log", "--no-graph", "--color=never", "-r", fmt.Sprintf("ancestors(@-, %d)", n),
+		"-T", `commit_id.short() ++ "\t" ++ description.first_line() ++ "\n"`)
+	return parseCommits(out)
+}
+
+func (jjVCS) LineLog(rev, file string, start, end, n int) ([]Commit, error) {
+	return nil, errors.New("jj can't follow lines through history")
+}
+
+// hgVCS is Mercurial. Its working directory has no staging area either.
+type hgVCS struct{}
+
+func (hgVCS) Name() string { return "hg" }
+
+func (hgVCS) DiffArgs(s *State) []string {
+	args := []string{"hg", "diff", "--git", "--color", "never", "--pager", "never", fmt.Sprintf("-U%d", s.ContextLines)}
+	if from, to, ok := rangeRefs(s.Refs, "."); ok {
+		args = append(args, "-r", from)
+		if to != "" {
+			args = append(args, "-r", to)
+		}
+	}
+	return append(args, s.Paths...)
+}
+
+func (hgVCS) Root() (string, error) {
+	return commandOutput("", "hg", "root")
+}
+
+// Branch is the active bookmark, or the named branch.
+func (hgVCS) Branch() string {
+	out, err := commandOutput("", "hg", "log", "-r", ".", "-T", "{ifeq(activebookmark, '', branch, activebookmark)}")
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// Tracking is nil: hg branches have no upstream.
+func (hgVCS) Tracking() *Tracking { return nil }
+
+func (hgVCS) Show(rev, file string) ([]byte, error) {
+	// path: patterns are relative to the root
+	return exec.Command("hg", "cat", "-r", rev, "path:"+file).Output()
+}
+
+func (v hgVCS) Apply(patch string, reverse bool) ([]byte, error) {
+	return patchApply(v, patch, reverse)
+}
+
+func (hgVCS) Head() string { return "." }
+func (hgVCS) Base() string { return "." }
+
+func (hgVCS) Parent(rev string) string { return rev + "^" }
+
+func (hgVCS) Recent(n int) []Commit {
+	out, _ := commandOutput("", "hg", "log", "-l", strconv.Itoa(n), "-T", "{node|short}\t{desc|firstline}\n")
+	return parseCommits(out)
+}
+
+func (hgVCS) LineLog(rev, file string, start, end, n int) ([]Commit, error) {
+	root, err := hgVCS{}.Root()
+	if err != nil {
+		return nil, err
+	}
+	out, err := commandOutput(root, "hg", "log", "-l", strconv.Itoa(n), "-r", rev,
+		"-L", fmt.Sprintf("%s,%d:%d", file, start, end),
+		"-T", "{node|short}\t{author|person}\t{date|age}\t{desc|firstline}\n")
+	if err != nil {
+		return nil, err
+	}
+	return parseLineLog(out), nil
+}
+
+// orRev returns rev, or def when rev is "".
+func orRev(rev, def string) string {
+	if rev == "" {
+		return def
+	}
+	return rev
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectVCS(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mkdir := func(dir string) {
+		t.Helper()
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkdir(filepath.Join(root, ".git"))
+	if v := detectVCS(sub); v.Name() != "git" {
+		t.Errorf("git repo detected as %s", v.Name())
+	}
+	mkdir(filepath.Join(root, ".jj"))
+	if v := detectVCS(sub); v.Name() != "jj" {
+		t.Errorf("colocated jj repo detected as %s", v.Name())
+	}
+	mkdir(filepath.Join(root, "a", ".hg"))
+	if v := detectVCS(sub); v.Name() != "hg" {
+		t.Errorf("nested hg repo detected as %s", v.Name())
+	}
+	if _, err := vcsByName("svn"); err == nil {
+		t.Error("vcsByName should reject unknown backends")
+	}
+}
+
+func TestVCSDiffArgs(t *testing.T) {
+	tests := []struct {
+		refs   []string
+		jj, hg []string
+	}{
+		{nil, nil, nil},
+		{[]string{"main"}, []string{"--from", "main", "--to", "@"}, []string{"-r", "main"}},
+		{[]string{"a", "b"}, []string{"--from", "a", "--to", "b"}, []string{"-r", "a", "-r", "b"}},
+		{[]string{"a.."}, []string{"--from", "a", "--to", "@"}, []string{"-r", "a", "-r", "."}},
+	}
+	for _, tt := range tests {
+		s := &State{Refs: tt.refs, ContextLines: 5}
+		jj := append([]string{"jj", "diff", "--git", "--color=never", "--no-pager", "--context=5"}, tt.jj...)
+		if got := (jjVCS{}).DiffArgs(s); !reflect.DeepEqual(got, jj) {
+			t.Errorf("jj %q: %q, want %q", tt.refs, got, jj)
+		}
+		hg := append([]string{"hg", "diff", "--git", "--color", "never", "--pager", "never", "-U5"}, tt.hg...)
+		if got := (hgVCS{}).DiffArgs(s); !reflect.DeepEqual(got, hg) {
+			t.Errorf("hg %q: %q, want %q", tt.refs, got, hg)
+		}
+	}
+	s := &State{Refs: []string{"main"}, ContextLines: 3}
+	if got := (gitVCS{}).DiffArgs(s); got[0] != "git" || !reflect.DeepEqual(got[1:], s.gitDiffArgs()) {
+		t.Errorf("git diff args = %q", got)
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.txt", []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hunks, err := parseDiff([]byte("diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := hunks[0].AsFullPatch()
+	// No jj here: patch(1) runs in the current directory
+	if out, err := (jjVCS{}).Apply(patch, false); err != nil {
+		t.Fatalf("apply: %v: %s", err, out)
+	}
+	if data, _ := os.ReadFile("a.txt"); string(data) != "one\n2\n" {
+		t.Errorf("applied = %q", data)
+	}
+	if out, err := (hgVCS{}).Apply(patch, true); err != nil {
+		t.Fatalf("revert: %v: %s", err, out)
+	}
+	if data, _ := os.ReadFile("a.txt"); string(data) != "one\ntwo\n" {
+		t.Errorf("reverted = %q", data)
+	}
+}
+
+func TestGitTracking(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "base")
+	if tr := (gitVCS{}).Tracking(); tr != nil {
+		t.Errorf("no upstream: Tracking() = %+v", tr)
+	}
+	git("branch", "up")
+	git("branch", "--set-upstream-to=up")
+	git("commit", "-q", "--allow-empty", "-m", "mine")
+	git("commit", "-q", "--allow-empty", "-m", "mine too")
+	if tr := (gitVCS{}).Tracking(); tr == nil || *tr != (Tracking{Ahead: 2}) {
+		t.Errorf("ahead of upstream: Tracking() = %+v", tr)
+	}
+}
@@ -9,39 +9,54 @@ import (
 	"github.com/radovskyb/watcher"
 )
 
-// startWatcher watches for file changes in the git repo and sends
-// notifications on updateCh. The .git directory is excluded.
+// startWatcher watches for file changes in the repository and sends
+// notifications on updateCh. The .git, .jj and .hg directories are
+// excluded: jj and hg write there on every diff. Only the files naming what
+// is checked out are watched in them (see headFiles), so switching branches
+// reloads even when no file in the tree changes.
 func startWatcher(updateCh chan<- struct{}) {
 	w := watcher.New()
 	w.SetMaxEvents(1)
 	w.FilterOps(watcher.Write, watcher.Create, watcher.Remove, watcher.Rename)
 
-	root, err := gitRoot()
+	root, err := repo.Root()
 	if err != nil || root == "" {
 		return
 	}
 
-	w.AddFilterHook(func(_ os.FileInfo, fullPath string) error {
-		if strings.Contains(fullPath, string(filepath.Separator)+".git"+string(filepath.Separator)) ||
-			strings.HasSuffix(fullPath, string(filepath.Separator)+".git") {
-			return watcher.ErrSkip
-		}
-		return nil
-	})
+	heads := make(map[string]bool)
+	headDirs := make(map[string]bool)
+	for _, f := range headFiles(root) {
+		heads[f] = true
+		headDirs[filepath.Dir(f)] = true
+	}
+	w.AddFilterHook(skipVCSDirs(heads))
 
 	if err := w.AddRecursive(root); err != nil {
 		return
 	}
+	// The directories of the head files are watched rather than the files,
+	// which may come and go, such as hg's bookmark file: a watched file
+	// that is removed stops the watcher.
+	for dir := range headDirs {
+		_ = w.Add(dir)
+	}
 
 	go func() {
 		for {
 			select {
-			case <-w.Event:
+			case ev := <-w.Event:
+				if headDirs[ev.Path] {
+					continue // lock files come and go in it on every command
+				}
+				watchEvents.Add(1)
+				logger.Debug("watch", "op", ev.Op.String(), "path", ev.Path)
 				select {
 				case updateCh <- struct{}{}:
 				default:
 				}
-			case <-w.Error:
+			case err := <-w.Error:
+				logger.Warn("watcher stopped", "err", err)
 				return
 			case <-w.Closed:
 				return
@@ -51,3 +66,38 @@ func startWatcher(updateCh chan<- struct{}) {
 
 	_ = w.Start(100 * time.Millisecond)
 }
+
+// skipVCSDirs returns a filter hook that skips the .git, .jj and .hg
+// directories and what is in them, except for the files in heads.
+func skipVCSDirs(heads map[string]bool) watcher.FilterFileHookFunc {
+	return func(_ os.FileInfo, fullPath string) error {
+		if heads[fullPath] {
+			return nil
+		}
+		for _, dir := range []string{".git", ".jj", ".hg"} {
+			sep := string(filepath.Separator)
+			if strings.Contains(fullPath, sep+dir+sep) || strings.HasSuffix(fullPath, sep+dir) {
+				return watcher.ErrSkip
+			}
+		}
+		return nil
+	}
+}
+
+// headFiles returns the files of the repository at root that change when
+// another branch is checked out: git's HEAD, hg's branch and active
+// bookmark. jj has none; moving @ rewrites the working copy.
+func headFiles(root string) []string {
+	switch repo.(type) {
+	case gitVCS:
+		// The git directory isn't .git in worktrees and submodules
+		dir, err := commandOutput(root, "git", "rev-parse", "--absolute-git-dir")
+		if err != nil {
+			return nil
+		}
+		return []string{filepath.Join(dir, "HEAD")}
+	case hgVCS:
+		return []string{filepath.Join(root, ".hg", "branch"), filepath.Join(root, ".hg", "bookmarks.current")}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/radovskyb/watcher"
+)
+
+func TestSkipVCSDirs(t *testing.T) {
+	head := filepath.Join("/r", ".hg", "bookmarks.current")
+	skip := skipVCSDirs(map[string]bool{head: true})
+	for path, want := range map[string]error{
+		head:                                nil,
+		filepath.Join("/r", ".hg"):          watcher.ErrSkip,
+		filepath.Join("/r", ".hg", "wlock"): watcher.ErrSkip,
+		filepath.Join("/r", ".git", "HEAD"): watcher.ErrSkip,
+		filepath.Join("/r", "a.go"):         nil,
+	} {
+		if got := skip(nil, path); got != want {
+			t.Errorf("%s: %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWatchHeadFileCreated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".hg")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	head := filepath.Join(dir, "bookmarks.current")
+	w := watcher.New()
+	w.AddFilterHook(skipVCSDirs(map[string]bool{head: true}))
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = w.Start(10 * time.Millisecond) }()
+	defer w.Close()
+	w.Wait()
+
+	// A lock file is no event, the bookmark file (not there at the start) is
+	for _, f := range []string{"wlock", "bookmarks.current"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Event:
+			if ev.Path == dir {
+				continue
+			}
+			if ev.Path != head || ev.Op != watcher.Create {
+				t.Fatalf("event %v", ev)
+			}
+			return
+		case err := <-w.Error:
+			t.Fatal(err)
+		case <-deadline:
+			t.Fatal("no event for the bookmark file")
+		}
+	}
+}
@@ -392,3 +392,37 @@ func TestWrapLinesUnicode(t *testing.T) {
 		t.Errorf("unicode concatenated text mismatch:\ngot:  %q\nwant: %q", allText, "+"+unicodeContent)
 	}
 }
+
+func TestMoveLinesSkipsContinuations(t *testing.T) {
+	s := makeTestState(40, true, false, []Line{
+		{Op: '+', Content: strings.Repeat("x", 80)},
+		{Op: ' ', Content: "short"},
+	})
+	s.Height = 40
+	s.BuildLines()
+	s.CursorMode = true
+	first := -1
+	for i, l := range s.Lines {
+		if l.Style == StyleAdded {
+			first = i
+			break
+		}
+	}
+	s.Cursor = first
+
+	s.MoveLines(1)
+	if s.Lines[s.Cursor].Continuation || s.Lines[s.Cursor].Style != StyleContext {
+		t.Fatalf("cursor at %d (%+v), want the context line after the wrapped line", s.Cursor, s.Lines[s.Cursor])
+	}
+	s.MoveLines(-1)
+	if s.Cursor != first {
+		t.Errorf("cursor = %d, want %d", s.Cursor, first)
+	}
+
+	// Display-row navigation steps onto the continuation rows
+	s.RowNav = true
+	s.MoveLines(1)
+	if !s.Lines[s.Cursor].Continuation {
+		t.Errorf("row nav: cursor at %d should be a continuation row", s.Cursor)
+	}
+}
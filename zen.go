@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// zenView is the view zen mode turned off, put back when it ends.
+type zenView struct {
+	lineNumbers, treeOpen, treeFocused bool
+}
+
+// ToggleZen switches zen mode, for screen-sharing walkthroughs: the diff
+// takes the whole screen without the gutter, line numbers, status bar or
+// file tree. Flash messages and prompts still show over the last row, and
+// label keys show the labels on hunk headers. Leaving it restores the view.
+func (s *State) ToggleZen() {
+	if s.Zen {
+		s.Zen = false
+		s.LineNumbers = s.zenRestore.lineNumbers
+		s.TreeOpen, s.TreeFocused = s.zenRestore.treeOpen, s.zenRestore.treeFocused
+		s.FlashMsg = "Zen mode off"
+	} else {
+		s.zenRestore = zenView{s.LineNumbers, s.TreeOpen, s.TreeFocused}
+		s.Zen = true
+		s.LineNumbers, s.TreeOpen, s.TreeFocused = false, false, false
+		s.FlashMsg = "Zen mode: ~ brings the gutter, numbers, status bar and tree back"
+	}
+	s.FlashExpiry = time.Now().Add(2 * time.Second)
+	s.BuildLines()
+	s.ClampScroll()
+}
+
+// statusRows returns the rows of the status bar: none in zen mode.
+func (s *State) statusRows() int {
+	if s.Zen {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestZenMode(t *testing.T) {
+	sim := tcell.NewSimulationScreen("")
+	if err := sim.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Fini()
+	sim.SetSize(60, 8)
+
+	s := &State{Screen: sim, Width: 60, Height: 8, HL: NewHighlighter(), LineNumbers: true, TreeOpen: true,
+		Hunks: []Hunk{{Label: "q", File: "a.go", OldStart: 1, NewStart: 1, Lines: []Line{{Op: '+', Content: "x := 1"}}}}}
+	s.BuildLines()
+	rows := s.viewRows()
+
+	HandleKey(s, makeKeyEvent('~'))
+	if !s.Zen || s.LineNumbers || s.TreeOpen || s.LabelGutter != 0 || s.DiffX != 0 {
+		t.Fatalf("zen: %v, numbers %v, tree %v, gutter %d, diff at %d", s.Zen, s.LineNumbers, s.TreeOpen, s.LabelGutter, s.DiffX)
+	}
+	if s.viewRows() != rows+1 {
+		t.Errorf("zen mode shows %d rows, want the status row too (%d)", s.viewRows(), rows+1)
+	}
+	s.FlashMsg = ""
+	Render(s)
+	if got := screenText(sim, 60, 4)[3]; !strings.HasPrefix(got, "+x := 1") {
+		t.Errorf("zen row %q, want the line at the left edge", got)
+	}
+
+	HandleKey(s, makeKeyEvent('~'))
+	if s.Zen || !s.LineNumbers || !s.TreeOpen || s.viewRows() != rows {
+		t.Errorf("leaving zen: numbers %v, tree %v, %d rows", s.LineNumbers, s.TreeOpen, s.viewRows())
+	}
+}